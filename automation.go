@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
@@ -24,6 +25,10 @@ type AutomationConfig struct {
 	BatchSize     int           // default: 16
 	PollInterval  time.Duration // default: 100ms
 	RetryBaseWait time.Duration // default: 1min (base backoff wait)
+
+	CatchUpRateLimit float64 // default: 0 = unlimited (events enqueued per second)
+
+	ProcessedRetention time.Duration // default: 7 days (how long processed events are kept from being enqueued again)
 }
 
 // defaultConfig returns default automation configuration
@@ -36,9 +41,32 @@ func defaultConfig() AutomationConfig {
 		BatchSize:     16,
 		PollInterval:  100 * time.Millisecond,
 		RetryBaseWait: time.Minute,
+
+		ProcessedRetention: 7 * 24 * time.Hour,
 	}
 }
 
+// AutomationMetrics observes automations (e.g. Prometheus histograms labeled by queue)
+type AutomationMetrics interface {
+	// RecordEnqueueDuration records a watcher poll: reading the new events and enqueuing them
+	RecordEnqueueDuration(queueId string, duration time.Duration, success bool)
+	// RecordEnqueuedEvents records the events a watcher poll enqueued (the cursor moved past them)
+	RecordEnqueuedEvents(queueId string, count int)
+}
+
+// NamespaceAutomationMetrics is optionally implemented by AutomationMetrics shared by the automations of
+// several namespaces (e.g. the tenants of a TenantStoreFactory): each automation records through
+// ForNamespace, called with the namespace of its store
+type NamespaceAutomationMetrics interface {
+	ForNamespace(namespace string) AutomationMetrics
+}
+
+// noopAutomationMetrics is a no-op implementation of AutomationMetrics (default)
+type noopAutomationMetrics struct{}
+
+func (noopAutomationMetrics) RecordEnqueueDuration(string, time.Duration, bool) {}
+func (noopAutomationMetrics) RecordEnqueuedEvents(string, int)                  {}
+
 // Startable interface for automations
 type Startable interface {
 	QueueId() string
@@ -59,33 +87,79 @@ func (r *AutomationRegistry[Deps]) RegisterAutomation(f AutomationFactory[Deps])
 	r.factories = append(r.factories, f)
 }
 
-// StartAll creates and starts all automations, returns stop func
-func (r *AutomationRegistry[Deps]) StartAll(ctx context.Context, store dcb.DcbStore, deps Deps) (func(), error) {
-	var automations []Startable
+// StartAll creates and starts all automations, returns their lifecycle handle.
+// deps are validated first: ErrInvalidDeps if a required field is nil or their Validate method fails (see DepsValidator).
+// If any automation fails to start, the already started ones are stopped.
+func (r *AutomationRegistry[Deps]) StartAll(ctx context.Context, store dcb.DcbStore, deps Deps) (*Lifecycle, error) {
+	return r.start(ctx, store, deps, nil)
+}
+
+// Start creates and starts the automations with the given queue ids only (e.g. the one under test),
+// returns their lifecycle handle. Unknown queue ids return ErrUnknownComponent.
+func (r *AutomationRegistry[Deps]) Start(ctx context.Context, store dcb.DcbStore, deps Deps, queueIds ...string) (*Lifecycle, error) {
+	selected := make(map[string]bool, len(queueIds))
+	for _, qid := range queueIds {
+		selected[qid] = true
+	}
+	return r.start(ctx, store, deps, selected)
+}
+
+// start builds every automation and starts the selected ones (all if selected is nil)
+func (r *AutomationRegistry[Deps]) start(ctx context.Context, store dcb.DcbStore, deps Deps, selected map[string]bool) (*Lifecycle, error) {
+	if err := validateDeps(deps); err != nil {
+		return nil, err
+	}
+	l := newLifecycle()
+	stopStarted := func() { l.Stop() }
 	seen := make(map[string]bool)
 	for _, f := range r.factories {
 		a, err := f(store, deps)
 		if err != nil {
+			stopStarted()
 			return nil, err
 		}
 		qid := a.QueueId()
 		if seen[qid] {
+			stopStarted()
 			return nil, fmt.Errorf("duplicate automation queueId: %q", qid)
 		}
 		seen[qid] = true
+		if selected != nil && !selected[qid] {
+			continue
+		}
+		l.handleErrors(a)
 		if err := a.Start(ctx); err != nil {
+			stopStarted()
 			return nil, err
 		}
-		automations = append(automations, a)
+		l.drainErrors(a)
+		l.components = append(l.components, &lifecycleComponent{
+			Startable: a,
+			factory:   func() (Startable, error) { return f(store, deps) },
+			running:   true,
+		})
 	}
-	return func() {
-		for _, a := range automations {
-			a.Stop()
-		}
-		for _, a := range automations {
-			a.Wait()
+	for qid := range selected {
+		if !seen[qid] {
+			stopStarted()
+			return nil, fmt.Errorf("%w: %q", ErrUnknownComponent, qid)
 		}
-	}, nil
+	}
+	return l, nil
+}
+
+// Supervise adds every registered automation to sup, rebuilt from its factory on each restart.
+// Invalid deps make sup.Start return ErrInvalidDeps.
+func (r *AutomationRegistry[Deps]) Supervise(sup *Supervisor, store dcb.DcbStore, deps Deps) {
+	if err := validateDeps(deps); err != nil {
+		sup.Add(func() (Startable, error) { return nil, err })
+		return
+	}
+	for _, f := range r.factories {
+		sup.Add(func() (Startable, error) {
+			return f(store, deps)
+		})
+	}
 }
 
 // Automation watches for events and executes handlers
@@ -98,21 +172,60 @@ type Automation[Deps any] struct {
 	runner        CommandWithEffectRunner[Deps]
 	config        AutomationConfig
 
+	// Trigger: the events of eventType, or those matching query
+	query *dcb.Query // nil = read typeIndex directly
+
 	// FDB
 	db             fdb.Database
+	store          dcb.DcbStore      // the source store, read by query automations
 	typeIndex      subspace.Subspace // dcb's namespace/t/eventType
 	eventsSubspace subspace.Subspace // dcb's namespace/e
+	fetcher        dcb.EventFetcher  // the store's event lookup (and cache), nil = read eventsSubspace directly
 	queueDir       subspace.Subspace // automation namespace/queue
 	cursorKey      fdb.Key           // automation namespace/cursor
+	cursorMetaKey  fdb.Key           // automation namespace/cursor_meta
 	dlqDir         subspace.Subspace // automation namespace/dlq
+	doneDir        subspace.Subspace // automation namespace/done, the events processed successfully
+
+	// DLQ auto-retry (nil = disabled)
+	dlqRetry *DLQRetryPolicy
+
+	// Store the commands run against (nil = source store)
+	targetStore dcb.DcbStore
+
+	// How command reads handle unknown event types
+	unknownEvents UnknownEventPolicy
+
+	metrics AutomationMetrics
+
+	// Time source of leases, backoffs and loop timers
+	clock Clock
+
+	// Shared tailer waking the watcher (nil = the watcher polls every PollInterval)
+	watchGroup *WatchGroup
 
 	// Runtime
 	workerID   [16]byte
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
-	errCh      chan error
-	pollTicker *time.Ticker
+	errs       *errorReporter
+	pollTicker Ticker
+	catchUp    atomic.Pointer[catchUpLimiter] // used by the watcher only, nil = unlimited
+	wake       <-chan struct{}                // new events of the type, from watchGroup (nil = no group)
+	unwatch    func()                         // ends the watchGroup subscription
+
+	// Settings in effect, the configured ones unless overridden (see AutomationOverrides)
+	controlKey   fdb.Key // automation namespace/control
+	pollInterval time.Duration
+	catchUpRate  float64
+	workersMu    sync.Mutex
+	workerStops  []context.CancelFunc // one per running worker
+
+	// Dequeue scans: where the next one starts (nil = queue start) and what they skipped
+	scanMu          sync.Mutex
+	scanFrom        fdb.Key
+	dequeueCounters dequeueCounters
 }
 
 // AutomationOption configures an Automation
@@ -172,6 +285,63 @@ func WithPollInterval[Deps any](d time.Duration) AutomationOption[Deps] {
 	}
 }
 
+// WithCatchUpRateLimit bounds the events the automation enqueues per second, so that replaying history
+// (e.g. a new automation after a deploy) doesn't saturate FDB or the systems its handlers call.
+// The limit applies per process; live traffic below it is not delayed.
+func WithCatchUpRateLimit[Deps any](eventsPerSecond float64) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		if eventsPerSecond > 0 {
+			a.config.CatchUpRateLimit = eventsPerSecond
+		}
+	}
+}
+
+// WithTargetStore makes the automation's commands read from and append to target instead of
+// the source store the trigger events come from (anti-corruption layer between bounded contexts).
+// The source job is acked only after the command succeeded, so delivery is at-least-once:
+// make the command idempotent (e.g. check its own output first, or use an EffectLog).
+func WithTargetStore[Deps any](target dcb.DcbStore) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		a.targetStore = target
+	}
+}
+
+// WithCommandUnknownEventPolicy sets how the automation's commands handle events of types absent from their queries
+func WithCommandUnknownEventPolicy[Deps any](p UnknownEventPolicy) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		a.unknownEvents = p
+	}
+}
+
+// WithAutomationMetrics sets the metrics the automation reports to, through m.ForNamespace if m implements
+// NamespaceAutomationMetrics
+func WithAutomationMetrics[Deps any](m AutomationMetrics) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		if nm, ok := m.(NamespaceAutomationMetrics); ok {
+			m = nm.ForNamespace(a.store.Namespace())
+		}
+		if m != nil {
+			a.metrics = m
+		}
+	}
+}
+
+// WithErrorHandler adds a handler of the automation's background errors (dequeue, enqueue, job bookkeeping, panics).
+// Without handler nor error channel, they are logged with slog.
+func WithErrorHandler[Deps any](h ErrorHandler) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		a.errs.OnError(h)
+	}
+}
+
+// WithErrorChannel makes Errors return a channel buffering size background errors.
+// Errors are dropped when it is full (see ErrorStats): prefer WithErrorHandler.
+func WithErrorChannel[Deps any](size int) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		a.errs.withChannel(size)
+	}
+}
+
 // WithRetryBaseWait sets the base wait time for retry backoff
 func WithRetryBaseWait[Deps any](d time.Duration) AutomationOption[Deps] {
 	return func(a *Automation[Deps]) {
@@ -181,6 +351,16 @@ func WithRetryBaseWait[Deps any](d time.Duration) AutomationOption[Deps] {
 	}
 }
 
+// WithProcessedRetention sets how long the marker of a processed event keeps it from being enqueued again
+// (e.g. by a rewound cursor). Markers older than d are purged while the automation runs.
+func WithProcessedRetention[Deps any](d time.Duration) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		if d > 0 {
+			a.config.ProcessedRetention = d
+		}
+	}
+}
+
 // NewAutomation creates a new automation instance
 func NewAutomation[Deps any](
 	store dcb.DcbStore,
@@ -190,19 +370,80 @@ func NewAutomation[Deps any](
 	handler func(Event) CommandWithEffect[Deps],
 	opts ...AutomationOption[Deps],
 ) (*Automation[Deps], error) {
-	if handler == nil {
-		return nil, errors.New("handler is required")
+	if store == nil {
+		return nil, errors.New("store is required")
 	}
+
+	// Resolve event type name
+	eventType := resolveEventTypeName(eventTypeExample)
+
+	// Create event registry and register the event type
+	registry := newEventRegistry()
+	registry.types[eventType] = reflect.TypeOf(eventTypeExample)
+
+	a, err := newAutomation(store, deps, queueId, registry, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	a.eventType = eventType
+	a.typeIndex = subspace.Sub(store.Namespace()).Sub("t").Sub(eventType)
+	return a, nil
+}
+
+// NewQueryAutomation creates an automation triggered by every event matching query,
+// as expressive as the reads of commands (several items, types and tags).
+// The watcher reads the query through the store, which merges the index ranges of its items.
+//
+// Events of types absent from the query (matched by tags-only items) are passed to the handler
+// with an UnknownEvent as Data.
+func NewQueryAutomation[Deps any](
+	store dcb.DcbStore,
+	deps Deps,
+	queueId string,
+	query *Query,
+	handler func(Event) CommandWithEffect[Deps],
+	opts ...AutomationOption[Deps],
+) (*Automation[Deps], error) {
 	if store == nil {
 		return nil, errors.New("store is required")
 	}
+	if query == nil || len(query.items) == 0 {
+		return nil, errors.New("query is required")
+	}
+	dcbQuery := query.toDcb()
+	if err := dcbQuery.Validate(); err != nil {
+		return nil, fmt.Errorf("automation %q: %w", queueId, err)
+	}
+
+	registry := newEventRegistry()
+	registry.unknown = UnknownEventPolicy{Mode: DeliverUnknownEvent}
+	for _, item := range query.items {
+		registry.registerTypes(item.typeRegistry)
+	}
+
+	a, err := newAutomation(store, deps, queueId, registry, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+	a.query = dcbQuery
+	return a, nil
+}
+
+// newAutomation creates an automation deserializing its events with registry, without its trigger
+func newAutomation[Deps any](
+	store dcb.DcbStore,
+	deps Deps,
+	queueId string,
+	registry eventRegistry,
+	handler func(Event) CommandWithEffect[Deps],
+	opts ...AutomationOption[Deps],
+) (*Automation[Deps], error) {
+	if handler == nil {
+		return nil, errors.New("handler is required")
+	}
 
 	db := store.Database()
 	dcbNamespace := store.Namespace()
-	runner := NewCommandWithEffectRunner(store, deps)
-
-	// Resolve event type name
-	eventType := resolveEventTypeName(eventTypeExample)
 
 	// Build subspaces
 	dcbRoot := subspace.Sub(dcbNamespace)
@@ -214,47 +455,73 @@ func NewAutomation[Deps any](
 		return nil, fmt.Errorf("generate worker ID: %w", err)
 	}
 
-	// Create event registry and register the event type
-	registry := newEventRegistry()
-	registry.types[eventType] = reflect.TypeOf(eventTypeExample)
-
 	a := &Automation[Deps]{
 		queueId:        queueId,
-		eventType:      eventType,
 		eventRegistry:  registry,
 		handler:        handler,
-		runner:         runner,
 		config:         defaultConfig(),
 		db:             db,
-		typeIndex:      dcbRoot.Sub("t").Sub(eventType),
+		store:          store,
 		eventsSubspace: dcbRoot.Sub("e"),
 		queueDir:       automationRoot.Sub("queue"),
 		cursorKey:      automationRoot.Pack(tuple.Tuple{"cursor"}),
+		cursorMetaKey:  automationRoot.Pack(tuple.Tuple{"cursor_meta"}),
+		controlKey:     automationRoot.Pack(tuple.Tuple{"control"}),
 		dlqDir:         automationRoot.Sub("dlq"),
+		doneDir:        automationRoot.Sub("done"),
 		workerID:       workerID,
-		errCh:          make(chan error, 100),
+		errs:           newErrorReporter(queueId),
+		metrics:        noopAutomationMetrics{},
+		clock:          systemClock{},
+	}
+
+	if fetcher, ok := store.(dcb.EventFetcher); ok {
+		a.fetcher = fetcher
 	}
 
 	for _, opt := range opts {
 		opt(a)
 	}
 
+	runnerStore := store
+	if a.targetStore != nil {
+		runnerStore = a.targetStore
+	}
+	a.runner = NewCommandWithEffectRunner(runnerStore, deps, WithUnknownEventPolicyForEffect[Deps](a.unknownEvents))
+
 	return a, nil
 }
 
-// Start begins the automation processing
+// Start begins the automation processing.
+// Its goroutines, and the commands they run, carry pprof labels naming the automation (see ProfileLabelQueueId).
 func (a *Automation[Deps]) Start(ctx context.Context) error {
-	a.ctx, a.cancel = context.WithCancel(ctx)
-	a.pollTicker = time.NewTicker(a.config.PollInterval)
+	a.ctx, a.cancel = context.WithCancel(withProfileLabels(ctx, "automation", a.queueId))
+	a.pollTicker = a.clock.NewTicker(a.config.PollInterval)
+	a.pollInterval, a.catchUpRate = a.config.PollInterval, a.config.CatchUpRateLimit
+	a.catchUp.Store(newCatchUpLimiter(a.config.CatchUpRateLimit, max(time.Second, a.config.PollInterval), a.clock.Now))
+	if a.watchGroup != nil && a.query == nil {
+		a.wake, a.unwatch = a.watchGroup.subscribe(a.db, a.typeIndex)
+	}
 
 	// Start watcher goroutine
 	a.wg.Add(1)
-	go a.runWatcher()
+	goLabeled(a.ctx, "watcher", a.runWatcher)
 
 	// Start worker goroutines
-	for range a.config.NumWorkers {
+	a.scaleWorkers(a.config.NumWorkers)
+
+	// Start control goroutine, applying the overrides stored in FDB
+	a.wg.Add(1)
+	goLabeled(a.ctx, "control", a.runControl)
+
+	// Start DLQ retrier goroutine
+	// Start the purger of the markers of processed events
+	a.wg.Add(1)
+	goLabeled(a.ctx, "processed_purger", a.runProcessedPurger)
+
+	if a.dlqRetry != nil {
 		a.wg.Add(1)
-		go a.runWorker()
+		goLabeled(a.ctx, "dlq_retrier", a.runDLQRetrier)
 	}
 
 	return nil
@@ -270,21 +537,11 @@ func (a *Automation[Deps]) Stop() {
 	}
 }
 
-// Wait blocks until all workers have finished
+// Wait blocks until all workers have finished.
+// With an error channel, it returns the errors left unread in it.
 func (a *Automation[Deps]) Wait() error {
 	a.wg.Wait()
-	close(a.errCh)
-
-	// Collect any errors
-	var errs []error
-	for err := range a.errCh {
-		errs = append(errs, err)
-	}
-
-	if len(errs) > 0 {
-		return errors.Join(errs...)
-	}
-	return nil
+	return errors.Join(a.errs.closeAndDrain()...)
 }
 
 // QueueId returns the queue identifier for this automation
@@ -292,7 +549,22 @@ func (a *Automation[Deps]) QueueId() string {
 	return a.queueId
 }
 
-// Errors returns the error channel for monitoring
+// Running reports whether the automation run loops are alive
+func (a *Automation[Deps]) Running() bool {
+	return a.ctx != nil && a.ctx.Err() == nil
+}
+
+// Errors returns the error channel enabled by WithErrorChannel, closed at once without it
 func (a *Automation[Deps]) Errors() <-chan error {
-	return a.errCh
+	return a.errs.channel()
+}
+
+// OnError adds a handler of the automation's background errors, see WithErrorHandler
+func (a *Automation[Deps]) OnError(h ErrorHandler) {
+	a.errs.OnError(h)
+}
+
+// ErrorStats counts the automation's background errors
+func (a *Automation[Deps]) ErrorStats() ErrorStats {
+	return a.errs.ErrorStats()
 }
@@ -0,0 +1,147 @@
+package fairway
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of an automation: lease expiries, retry backoffs, DLQ timestamps, queue ages,
+// and the tickers and timers of its loops. The system clock by default (see WithClock).
+// Exporters, watch groups and effect logs take one too (WithExportClock, WithWatchGroupClock, WithEffectClock).
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker delivers ticks every period on Chan, like time.Ticker
+type Ticker interface {
+	Chan() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// WithClock replaces the clock of the automation, e.g. with a VirtualClock for deterministic tests (see AutomationSim).
+// Every process running the automation should agree on the time: leases are judged against it.
+func WithClock[Deps any](c Clock) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		if c != nil {
+			a.clock = c
+		}
+	}
+}
+
+// systemClock is the Clock of the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) Chan() <-chan time.Time { return t.C }
+
+// VirtualClock is a Clock whose time only moves with Advance, firing the tickers and timers due on the way.
+// It makes lease expiries, backoffs and DLQ cooldowns reachable in a test without waiting for them.
+type VirtualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*virtualTimer
+}
+
+// virtualTimer is a ticker (period > 0) or a one-shot timer of a VirtualClock
+type virtualTimer struct {
+	clock  *VirtualClock
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewVirtualClock returns a clock set to start
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now returns the time of the clock
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker firing every d of virtual time
+func (c *VirtualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for VirtualClock.NewTicker")
+	}
+	return c.add(d, d)
+}
+
+// After returns a channel receiving the time once d of virtual time passed
+func (c *VirtualClock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0).c
+}
+
+func (c *VirtualClock) add(d, period time.Duration) *virtualTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &virtualTimer{clock: c, at: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock d forward, firing the timers due meanwhile in time order, each at its own time.
+// Like with time.Ticker, a tick is dropped when the previous one was not received yet.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := c.now.Add(d)
+	for {
+		next := -1
+		for i, t := range c.timers {
+			if !t.at.After(target) && (next < 0 || t.at.Before(c.timers[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		t := c.timers[next]
+		c.now = t.at
+		select {
+		case t.c <- t.at:
+		default:
+		}
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+		} else {
+			c.timers = slices.Delete(c.timers, next, next+1)
+		}
+	}
+	c.now = target
+}
+
+func (t *virtualTimer) Chan() <-chan time.Time { return t.c }
+
+// Reset makes the ticker fire every d from now on
+func (t *virtualTimer) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.at, t.period = t.clock.now.Add(d), d
+	if !slices.Contains(t.clock.timers, t) {
+		t.clock.timers = append(t.clock.timers, t)
+	}
+}
+
+// Stop turns the ticker off
+func (t *virtualTimer) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.timers = slices.DeleteFunc(t.clock.timers, func(other *virtualTimer) bool { return other == t })
+}
@@ -0,0 +1,202 @@
+package fairway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// controlRetryWait is how long the control loop waits before watching its key again after an error
+const controlRetryWait = time.Second
+
+// AutomationOverrides replace settings of an automation at runtime, in every process running it,
+// e.g. to throttle a runaway automation without redeploying (see SetAutomationOverrides).
+// Zero fields keep the values the automation was created with.
+type AutomationOverrides struct {
+	PollInterval     Duration `json:"pollInterval,omitempty"`
+	NumWorkers       int      `json:"numWorkers,omitempty"`
+	CatchUpRateLimit float64  `json:"catchUpRateLimit,omitempty"` // events per second, < 0 lifts the configured limit
+}
+
+// controlKey is the key holding the overrides of the automation queueId: automation namespace/control
+func controlKey(store dcb.DcbStore, queueId string) fdb.Key {
+	return subspace.Sub(store.Namespace() + "/" + queueId).Pack(tuple.Tuple{"control"})
+}
+
+// SetAutomationOverrides stores the overrides of the automation queueId. Running instances apply them
+// within a moment, and new ones at start, until they are replaced; zero overrides restore the configured settings.
+// The automation doesn't need to be running in this process: admin endpoints and CLIs only need the store.
+func SetAutomationOverrides(ctx context.Context, store dcb.DcbStore, queueId string, o AutomationOverrides) error {
+	if o.PollInterval < 0 || o.NumWorkers < 0 {
+		return errors.New("overrides must not be negative (zero keeps the configured value)")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	key := controlKey(store, queueId)
+	_, err = store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		if o == (AutomationOverrides{}) {
+			tr.Clear(key)
+		} else {
+			tr.Set(key, value)
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// ReadAutomationOverrides returns the overrides stored for the automation queueId (zero if none)
+func ReadAutomationOverrides(ctx context.Context, store dcb.DcbStore, queueId string) (AutomationOverrides, error) {
+	if err := ctx.Err(); err != nil {
+		return AutomationOverrides{}, err
+	}
+	value, err := store.Database().ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(controlKey(store, queueId)).Get()
+	})
+	if err != nil {
+		return AutomationOverrides{}, err
+	}
+	return decodeOverrides(value.([]byte))
+}
+
+func decodeOverrides(value []byte) (AutomationOverrides, error) {
+	var o AutomationOverrides
+	if value == nil {
+		return o, nil
+	}
+	if err := json.Unmarshal(value, &o); err != nil {
+		return AutomationOverrides{}, fmt.Errorf("invalid automation overrides: %w", err)
+	}
+	return o, nil
+}
+
+// runControl applies the overrides of the control key when the automation starts, then each time the key changes
+func (a *Automation[Deps]) runControl() {
+	defer a.wg.Done()
+	defer a.recoverLoop("control")
+
+	var applied []byte
+	first := true
+	for {
+		value, watch, err := a.watchControl()
+		if err != nil {
+			a.errs.report(fmt.Errorf("watch overrides: %w", err))
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-a.clock.After(controlRetryWait):
+			}
+			continue
+		}
+
+		if first || !bytes.Equal(value, applied) {
+			if o, err := decodeOverrides(value); err != nil {
+				a.errs.report(err)
+			} else {
+				a.applyOverrides(o)
+			}
+			applied, first = value, false
+		}
+
+		changed := make(chan error, 1)
+		go func() { changed <- watch.Get() }()
+		select {
+		case <-a.ctx.Done():
+			watch.Cancel()
+			return
+		case err := <-changed:
+			if err == nil {
+				continue
+			}
+			// e.g. too many watches: the key is read again, later
+			a.errs.report(fmt.Errorf("watch overrides: %w", err))
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-a.clock.After(controlRetryWait):
+			}
+		}
+	}
+}
+
+// watchControl reads the control key and watches it for changes
+func (a *Automation[Deps]) watchControl() ([]byte, fdb.FutureNil, error) {
+	var watch fdb.FutureNil
+	value, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		value, err := tr.Get(a.controlKey).Get()
+		if err != nil {
+			return nil, err
+		}
+		watch = tr.Watch(a.controlKey)
+		return value, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return value.([]byte), watch, nil
+}
+
+// applyOverrides sets the poll interval, catch-up rate limit and number of workers of the running automation:
+// those of o, or the configured ones
+func (a *Automation[Deps]) applyOverrides(o AutomationOverrides) {
+	if a.ctx.Err() != nil {
+		return // stopped: the ticker must stay stopped
+	}
+	pollInterval := a.config.PollInterval
+	if o.PollInterval > 0 {
+		pollInterval = time.Duration(o.PollInterval)
+	}
+	rate := a.config.CatchUpRateLimit
+	if o.CatchUpRateLimit != 0 {
+		rate = max(0, o.CatchUpRateLimit)
+	}
+	numWorkers := a.config.NumWorkers
+	if o.NumWorkers > 0 {
+		numWorkers = o.NumWorkers
+	}
+
+	if pollInterval != a.pollInterval {
+		a.pollTicker.Reset(pollInterval)
+	}
+	if pollInterval != a.pollInterval || rate != a.catchUpRate {
+		a.catchUp.Store(newCatchUpLimiter(rate, max(time.Second, pollInterval), a.clock.Now))
+	}
+	a.pollInterval, a.catchUpRate = pollInterval, rate
+	a.scaleWorkers(numWorkers)
+}
+
+// scaleWorkers starts or stops workers until n run. Stopped workers finish their current job first.
+func (a *Automation[Deps]) scaleWorkers(n int) {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+	for len(a.workerStops) < n {
+		ctx, stop := context.WithCancel(a.ctx)
+		a.workerStops = append(a.workerStops, stop)
+		a.wg.Add(1)
+		goLabeled(ctx, "worker", func() { a.runWorker(ctx) })
+	}
+	for len(a.workerStops) > n {
+		last := len(a.workerStops) - 1
+		a.workerStops[last]()
+		a.workerStops = a.workerStops[:last]
+	}
+}
+
+// NumWorkers returns the number of workers currently running (see AutomationOverrides)
+func (a *Automation[Deps]) NumWorkers() int {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+	return len(a.workerStops)
+}
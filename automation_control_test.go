@@ -0,0 +1,66 @@
+package fairway_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutomationOverrides_AppliedWhileRunning(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent, LastEventMu: &sync.Mutex{}}
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+	assert.Equal(t, 1, automation.NumWorkers())
+
+	// When - an operator scales the workers up and pauses the polls
+	overrides := fairway.AutomationOverrides{NumWorkers: 3, PollInterval: fairway.Duration(time.Hour)}
+	require.NoError(t, fairway.SetAutomationOverrides(ctx, store, queueId, overrides))
+
+	// Then
+	stored, err := fairway.ReadAutomationOverrides(ctx, store, queueId)
+	require.NoError(t, err)
+	assert.Equal(t, overrides, stored)
+	require.Eventually(t, func() bool { return automation.NumWorkers() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	assert.Never(t, func() bool { return handlerCalled.Load() > 0 }, 300*time.Millisecond, 10*time.Millisecond)
+
+	// When - the overrides are cleared
+	require.NoError(t, fairway.SetAutomationOverrides(ctx, store, queueId, fairway.AutomationOverrides{}))
+
+	// Then - the configured settings are back
+	assert.Eventually(t, func() bool { return handlerCalled.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return automation.NumWorkers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	stored, err = fairway.ReadAutomationOverrides(ctx, store, queueId)
+	require.NoError(t, err)
+	assert.Zero(t, stored)
+}
+
+func TestSetAutomationOverrides_RejectsNegativeValues(t *testing.T) {
+	// When
+	err := fairway.SetAutomationOverrides(context.Background(), dcb.SetupTestStore(t), "queue", fairway.AutomationOverrides{NumWorkers: -1})
+
+	// Then
+	assert.Error(t, err)
+}
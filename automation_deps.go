@@ -0,0 +1,86 @@
+package fairway
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidDeps is returned by AutomationRegistry.StartAll, Start and Supervise when deps miss a dependency
+var ErrInvalidDeps = errors.New("invalid automation dependencies")
+
+// requiredTagValue marks a deps field that must not be nil: `fairway:"required"`
+const requiredTagValue = "required"
+
+// DepsValidator is implemented by deps checking more than their fields being set
+// (a config value in range, a client pinging its server...).
+// Validate runs at startup, once every required field is known to be set.
+type DepsValidator interface {
+	Validate() error
+}
+
+// validateDeps reports the exported fields of deps tagged `fairway:"required"` that hold a nil
+// pointer, interface, func, map or chan, then runs its Validate method, if any.
+// Handlers can then use the required deps without nil checks. Fields of other types are not
+// inspected (a zero http.Client is usable), unless a required struct field tags its own fields.
+func validateDeps[Deps any](deps Deps) error {
+	v := reflect.ValueOf(&deps).Elem()
+	name := v.Type().String()
+	var missing []string
+	switch {
+	case !isNilable(v.Kind()):
+		missing = nilFields(v, name, missing)
+	case !v.IsNil():
+		missing = nilFields(reflect.Indirect(v.Elem()), name, missing)
+	case v.Kind() != reflect.Interface || v.NumMethod() > 0: // a nil any is "no deps"
+		missing = append(missing, name)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s is nil", ErrInvalidDeps, strings.Join(missing, ", "))
+	}
+
+	validator, ok := any(deps).(DepsValidator)
+	if !ok {
+		validator, ok = any(&deps).(DepsValidator)
+	}
+	if ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidDeps, name, err)
+		}
+	}
+	return nil
+}
+
+// nilFields appends to missing the path of the required nil fields of the struct v,
+// and of the required struct fields it holds
+func nilFields(v reflect.Value, path string, missing []string) []string {
+	if v.Kind() != reflect.Struct {
+		return missing
+	}
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("fairway") != requiredTagValue {
+			continue
+		}
+		value := v.Field(i)
+		fieldPath := path + "." + field.Name
+		switch {
+		case isNilable(value.Kind()) && value.IsNil():
+			missing = append(missing, fieldPath)
+		case value.Kind() == reflect.Struct:
+			missing = nilFields(value, fieldPath, missing)
+		}
+	}
+	return missing
+}
+
+// isNilable tells the kinds of dependency a handler would call through (slices are fine empty)
+func isNilable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Map, reflect.Chan:
+		return true
+	}
+	return false
+}
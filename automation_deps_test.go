@@ -0,0 +1,108 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mailer interface {
+	Send(to string) error
+}
+
+type stubMailer struct{}
+
+func (stubMailer) Send(string) error { return nil }
+
+type clients struct {
+	Billing func(userID string) error `fairway:"required"`
+	Cache   map[string]string         // not checked
+}
+
+type appDeps struct {
+	Mailer  mailer      `fairway:"required"`
+	Clients clients     `fairway:"required"`
+	HTTP    http.Client // usable zero value, its nil fields are not checked
+	Tracer  *struct{}   // not checked, handlers check it
+	retries *int        `fairway:"required"` // unexported, not checked
+}
+
+type checkedDeps struct {
+	Mailer   mailer `fairway:"required"`
+	MaxBatch int
+}
+
+func (d checkedDeps) Validate() error {
+	if d.MaxBatch <= 0 {
+		return errors.New("MaxBatch must be positive")
+	}
+	return nil
+}
+
+// startDeps starts an empty registry: only deps validation can fail
+func startDeps[Deps any](deps Deps) error {
+	registry := &fairway.AutomationRegistry[Deps]{}
+	lifecycle, err := registry.StartAll(context.Background(), nil, deps)
+	if err == nil {
+		lifecycle.Stop()
+	}
+	return err
+}
+
+func TestAutomationRegistry_RejectsNilDeps(t *testing.T) {
+	// When
+	err := startDeps(appDeps{})
+
+	// Then - every missing field is named
+	require.ErrorIs(t, err, fairway.ErrInvalidDeps)
+	assert.ErrorContains(t, err, "fairway_test.appDeps.Mailer, fairway_test.appDeps.Clients.Billing is nil")
+}
+
+func TestAutomationRegistry_AcceptsCompleteDeps(t *testing.T) {
+	// Given - untagged, third-party and unexported fields left nil
+	deps := appDeps{Mailer: stubMailer{}, Clients: clients{Billing: func(string) error { return nil }}}
+
+	// When
+	err := startDeps(deps)
+
+	// Then
+	assert.NoError(t, err)
+	assert.NoError(t, startDeps(&deps), "pointer deps are checked through")
+	assert.ErrorIs(t, startDeps[*appDeps](nil), fairway.ErrInvalidDeps)
+	assert.NoError(t, startDeps(struct{}{}))
+}
+
+func TestAutomationRegistry_RunsDepsValidate(t *testing.T) {
+	// When
+	err := startDeps(checkedDeps{Mailer: stubMailer{}})
+
+	// Then
+	require.ErrorIs(t, err, fairway.ErrInvalidDeps)
+	assert.ErrorContains(t, err, "MaxBatch must be positive")
+
+	// When - the nil check comes first
+	err = startDeps(checkedDeps{MaxBatch: 10})
+
+	// Then
+	assert.ErrorContains(t, err, "checkedDeps.Mailer is nil")
+	assert.NoError(t, startDeps(checkedDeps{Mailer: stubMailer{}, MaxBatch: 10}))
+}
+
+func TestAutomationRegistry_SuperviseRejectsNilDeps(t *testing.T) {
+	// Given
+	registry := &fairway.AutomationRegistry[appDeps]{}
+	sup := fairway.NewSupervisor()
+	registry.Supervise(sup, nil, appDeps{})
+
+	// When
+	err := sup.Start(context.Background())
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrInvalidDeps)
+	assert.NoError(t, sup.Wait())
+}
@@ -1,9 +1,12 @@
 package fairway
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"iter"
+	"math"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
@@ -12,6 +15,9 @@ import (
 	"github.com/err0r500/fairway/dcb"
 )
 
+// ErrDLQEntryNotFound is returned when replaying a DLQ entry that doesn't exist (or was already replayed)
+var ErrDLQEntryNotFound = errors.New("DLQ entry not found")
+
 // DLQEntry represents a failed job in the dead letter queue
 type DLQEntry struct {
 	Key        fdb.Key
@@ -19,13 +25,29 @@ type DLQEntry struct {
 	EventVS    dcb.Versionstamp
 	Attempts   uint8
 	Error      string
+
+	Resurrections uint8 // times the job was already requeued from the DLQ by a DLQRetryPolicy
+
+	Failures []JobFailure // failed attempts, oldest first, across resurrections (empty for entries written before they were tracked)
+
+	FirstEnqueuedAt time.Time // when the event was first enqueued (zero for entries written before it was tracked)
+}
+
+// firstEnqueuedNs returns FirstEnqueuedAt in unix nano, 0 if unknown
+func (e DLQEntry) firstEnqueuedNs() int64 {
+	if e.FirstEnqueuedAt.IsZero() {
+		return 0
+	}
+	return e.FirstEnqueuedAt.UnixNano()
 }
 
 // DLQ value format:
-// [event_vs:12][attempts:1][error_len:2][error:variable]
+// [event_vs:12][attempts:1][error_len:2][error:variable][resurrections:1][failures:variable][first_enqueued_ns:8]
+// (resurrections, failures and first_enqueued_ns are absent from entries written before they were tracked)
 const dlqHeaderSize = 12 + 1 + 2 // 15 bytes
 
-func encodeDLQ(job *Job, err error) []byte {
+// errorString returns the message of err ("" for nil), truncated to fit a DLQ entry
+func errorString(err error) string {
 	errStr := ""
 	if err != nil {
 		errStr = err.Error()
@@ -33,13 +55,20 @@ func encodeDLQ(job *Job, err error) []byte {
 	if len(errStr) > 65535 {
 		errStr = errStr[:65535]
 	}
+	return errStr
+}
 
-	buf := make([]byte, dlqHeaderSize+len(errStr))
+func encodeDLQ(job *Job, err error) []byte {
+	errStr := errorString(err)
+
+	buf := make([]byte, dlqHeaderSize+len(errStr)+1, dlqHeaderSize+len(errStr)+1+failuresSize(job.Failures)+8)
 	copy(buf[0:12], job.EventVS[:])
 	buf[12] = job.Attempts
 	binary.BigEndian.PutUint16(buf[13:15], uint16(len(errStr)))
 	copy(buf[15:], errStr)
-	return buf
+	buf[len(buf)-1] = job.Resurrections
+	buf = appendFailures(buf, job.Failures)
+	return binary.BigEndian.AppendUint64(buf, uint64(job.FirstEnqueuedNs))
 }
 
 func decodeDLQ(key fdb.Key, value []byte, dlqDir subspace.Subspace) (*DLQEntry, error) {
@@ -58,6 +87,21 @@ func decodeDLQ(key fdb.Key, value []byte, dlqDir subspace.Subspace) (*DLQEntry,
 		Error:    string(value[15 : 15+errLen]),
 	}
 	copy(entry.EventVS[:], value[0:12])
+	if len(value) > dlqHeaderSize+int(errLen) {
+		entry.Resurrections = value[dlqHeaderSize+int(errLen)]
+	}
+	if len(value) > dlqHeaderSize+int(errLen)+1 {
+		failures, rest, err := decodeFailures(value[dlqHeaderSize+int(errLen)+1:])
+		if err != nil {
+			return nil, err
+		}
+		entry.Failures = failures
+		if len(rest) >= 8 {
+			if ns := int64(binary.BigEndian.Uint64(rest[:8])); ns != 0 {
+				entry.FirstEnqueuedAt = time.Unix(0, ns)
+			}
+		}
+	}
 
 	// Extract timestamp from key: dlq/<ts>/<event_vs>
 	keyTuple, err := dlqDir.Unpack(key)
@@ -76,7 +120,7 @@ func decodeDLQ(key fdb.Key, value []byte, dlqDir subspace.Subspace) (*DLQEntry,
 // moveToDLQInTx moves a job to the DLQ within an existing transaction
 func (a *Automation[Deps]) moveToDLQInTx(tr fdb.Transaction, job *Job, err error) error {
 	// DLQ key: dlq/<timestamp>/<event_vs>
-	ts := time.Now().UnixNano()
+	ts := a.clock.Now().UnixNano()
 
 	var txVersion [10]byte
 	copy(txVersion[:], job.EventVS[:10])
@@ -128,7 +172,7 @@ func (a *Automation[Deps]) ReplayDLQ(dlqKey fdb.Key) error {
 	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
 		value := tr.Get(dlqKey).MustGet()
 		if value == nil {
-			return nil, errors.New("DLQ entry not found")
+			return nil, ErrDLQEntryNotFound
 		}
 
 		entry, err := decodeDLQ(dlqKey, value, a.dlqDir)
@@ -136,8 +180,8 @@ func (a *Automation[Deps]) ReplayDLQ(dlqKey fdb.Key) error {
 			return nil, err
 		}
 
-		// Re-enqueue the event
-		if err := a.enqueueInTx(tr, entry.EventVS); err != nil {
+		// Re-enqueue the event, keeping its failure history
+		if err := a.enqueueJobInTx(tr, entry.EventVS, 0, entry.Failures, entry.firstEnqueuedNs()); err != nil {
 			return nil, err
 		}
 
@@ -148,6 +192,69 @@ func (a *Automation[Deps]) ReplayDLQ(dlqKey fdb.Key) error {
 	return err
 }
 
+// ReplayDLQEntry runs the event of a DLQ entry through the current handler synchronously, for manual remediation
+// (e.g. after deploying a fix). On success the entry is removed; on failure the attempt is added to its
+// failure history, it stays in the DLQ and the error is returned. The automation doesn't need to be started.
+func (a *Automation[Deps]) ReplayDLQEntry(ctx context.Context, dlqKey fdb.Key) error {
+	res, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		value := tr.Get(dlqKey).MustGet()
+		if value == nil {
+			return nil, ErrDLQEntryNotFound
+		}
+		return decodeDLQ(dlqKey, value, a.dlqDir)
+	})
+	if err != nil {
+		return err
+	}
+	entry := res.(*DLQEntry)
+
+	processErr := a.runJob(ctx, JobInfo{
+		QueueId:         a.queueId,
+		Position:        entry.EventVS,
+		Attempt:         int(entry.Attempts) + 1,
+		Resurrections:   int(entry.Resurrections),
+		FirstEnqueuedAt: entry.FirstEnqueuedAt,
+	})
+
+	_, err = a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		value := tr.Get(dlqKey).MustGet()
+		if value == nil {
+			return nil, nil // replayed or purged meanwhile
+		}
+		if processErr == nil {
+			tr.Clear(dlqKey)
+			a.markDoneInTx(tr, entry.EventVS)
+			return nil, nil
+		}
+
+		current, err := decodeDLQ(dlqKey, value, a.dlqDir)
+		if err != nil {
+			return nil, err
+		}
+		job := &Job{
+			EventVS:       current.EventVS,
+			Attempts:      current.Attempts,
+			Resurrections: current.Resurrections,
+			Failures: withFailure(current.Failures, JobFailure{
+				Attempt:  current.Attempts,
+				At:       a.clock.Now(),
+				WorkerID: a.workerID,
+				Error:    "replay: " + errorString(processErr),
+			}),
+			FirstEnqueuedNs: current.firstEnqueuedNs(),
+		}
+		tr.Set(dlqKey, encodeDLQ(job, processErr))
+		return nil, nil
+	})
+	if err != nil {
+		if processErr != nil {
+			return errors.Join(processErr, fmt.Errorf("recording replay failure: %w", err))
+		}
+		return fmt.Errorf("removing replayed DLQ entry: %w", err)
+	}
+	return processErr
+}
+
 // PurgeDLQ removes all DLQ entries older than the given time
 func (a *Automation[Deps]) PurgeDLQ(before time.Time) error {
 	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
@@ -162,3 +269,106 @@ func (a *Automation[Deps]) PurgeDLQ(before time.Time) error {
 	})
 	return err
 }
+
+// DLQRetryPolicy automatically requeues DLQ entries so transient outages self-heal.
+// An entry is requeued once it spent Cooldown in the DLQ, or as soon as HealthProbe succeeds,
+// at most MaxResurrections times; after that it stays in the DLQ for an operator.
+type DLQRetryPolicy struct {
+	Cooldown         time.Duration                   // time spent in the DLQ before requeuing (0 = only on HealthProbe success)
+	HealthProbe      func(ctx context.Context) error // optional dependency check, nil error requeues every eligible entry
+	MaxResurrections int                             // requeues per job before giving up (max 255)
+	CheckInterval    time.Duration                   // how often the DLQ is scanned (default: 10s)
+}
+
+// defaultDLQCheckInterval is used when DLQRetryPolicy.CheckInterval is not set
+const defaultDLQCheckInterval = 10 * time.Second
+
+// WithDLQRetry enables automatic requeuing of DLQ entries following the policy
+func WithDLQRetry[Deps any](p DLQRetryPolicy) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		if p.MaxResurrections <= 0 || (p.Cooldown <= 0 && p.HealthProbe == nil) {
+			return
+		}
+		if p.CheckInterval <= 0 {
+			p.CheckInterval = defaultDLQCheckInterval
+		}
+		p.MaxResurrections = min(p.MaxResurrections, math.MaxUint8)
+		a.dlqRetry = &p
+	}
+}
+
+// runDLQRetrier periodically requeues eligible DLQ entries
+func (a *Automation[Deps]) runDLQRetrier() {
+	defer a.wg.Done()
+	defer a.recoverLoop("dlq retrier")
+
+	ticker := a.clock.NewTicker(a.dlqRetry.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+
+		if err := a.retryDLQ(a.ctx, a.clock.Now()); err != nil {
+			a.errs.report(fmt.Errorf("dlq retry: %w", err))
+		}
+	}
+}
+
+// retryDLQ requeues the DLQ entries eligible at now
+func (a *Automation[Deps]) retryDLQ(ctx context.Context, now time.Time) error {
+	var candidates []DLQEntry
+	for entry, err := range a.ListDLQ() {
+		if err != nil {
+			return err
+		}
+		if int(entry.Resurrections) < a.dlqRetry.MaxResurrections {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	healthy := false
+	if a.dlqRetry.HealthProbe != nil {
+		healthy = a.dlqRetry.HealthProbe(ctx) == nil
+	}
+
+	var errs []error
+	for _, entry := range candidates {
+		cooledDown := a.dlqRetry.Cooldown > 0 && now.Sub(entry.EnqueuedAt) >= a.dlqRetry.Cooldown
+		if !healthy && !cooledDown {
+			continue
+		}
+		if err := a.resurrectDLQ(entry.Key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resurrectDLQ moves a DLQ entry back to the queue, incrementing its resurrection count
+func (a *Automation[Deps]) resurrectDLQ(dlqKey fdb.Key) error {
+	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		value := tr.Get(dlqKey).MustGet()
+		if value == nil {
+			return nil, nil // replayed or purged meanwhile
+		}
+
+		entry, err := decodeDLQ(dlqKey, value, a.dlqDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := a.enqueueJobInTx(tr, entry.EventVS, entry.Resurrections+1, entry.Failures, entry.firstEnqueuedNs()); err != nil {
+			return nil, err
+		}
+		tr.Clear(dlqKey)
+		return nil, nil
+	})
+	return err
+}
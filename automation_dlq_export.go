@@ -0,0 +1,86 @@
+package fairway
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// dlqEntryJSON is the JSON document of a DLQ entry, stable for tools that don't link this package
+type dlqEntryJSON struct {
+	QueueId         string           `json:"queueId,omitempty"` // set by ExportDLQ
+	Key             string           `json:"key"`               // hex, the FDB key of the entry
+	Position        dcb.Versionstamp `json:"position"`          // hex, of the event
+	EnqueuedAt      time.Time        `json:"enqueuedAt"`        // in the DLQ
+	FirstEnqueuedAt *time.Time       `json:"firstEnqueuedAt,omitempty"`
+	Attempts        int              `json:"attempts"`
+	Resurrections   int              `json:"resurrections"`
+	Error           string           `json:"error"`
+	Failures        []jobFailureJSON `json:"failures"`
+}
+
+type jobFailureJSON struct {
+	Attempt  int       `json:"attempt"`
+	At       time.Time `json:"at"`
+	WorkerID string    `json:"workerId,omitempty"` // hex, absent for manual replays from another process
+	Error    string    `json:"error"`
+}
+
+func (e DLQEntry) toJSON(queueId string) dlqEntryJSON {
+	doc := dlqEntryJSON{
+		QueueId:       queueId,
+		Key:           hex.EncodeToString(e.Key),
+		Position:      e.EventVS,
+		EnqueuedAt:    e.EnqueuedAt,
+		Attempts:      int(e.Attempts),
+		Resurrections: int(e.Resurrections),
+		Error:         e.Error,
+		Failures:      make([]jobFailureJSON, len(e.Failures)),
+	}
+	if !e.FirstEnqueuedAt.IsZero() {
+		doc.FirstEnqueuedAt = &e.FirstEnqueuedAt
+	}
+	for i, f := range e.Failures {
+		doc.Failures[i] = jobFailureJSON{Attempt: int(f.Attempt), At: f.At, Error: f.Error}
+		if f.WorkerID != ([16]byte{}) {
+			doc.Failures[i].WorkerID = hex.EncodeToString(f.WorkerID[:])
+		}
+	}
+	return doc
+}
+
+// MarshalJSON encodes the entry as a JSON object with camelCase fields; the key, position and worker IDs are hex strings
+func (e DLQEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON(""))
+}
+
+// ExportDLQ writes the automation's DLQ entries to w as newline-delimited JSON (one entry per line,
+// with the queueId), oldest first, and returns how many it wrote.
+// The automation doesn't need to be started: operators and external tools can dump a DLQ from a CLI.
+func (a *Automation[Deps]) ExportDLQ(ctx context.Context, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	written := 0
+	for entry, err := range a.ListDLQ() {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			if err = enc.Encode(entry.toJSON(a.queueId)); err != nil {
+				err = fmt.Errorf("encoding DLQ entry %x: %w", entry.Key, err)
+			}
+		}
+		if err != nil {
+			_ = bw.Flush() // the entries written so far
+			return written, err
+		}
+		written++
+	}
+	return written, bw.Flush()
+}
@@ -1,9 +1,13 @@
 package fairway
 
 import (
-	"crypto/rand"
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
@@ -21,29 +25,58 @@ type Job struct {
 	LeaseVS   dcb.Versionstamp // hybrid clock for lease expiry check
 	OwnerID   [16]byte         // worker that owns this job
 	Attempts  uint8            // number of attempts so far
+
+	Resurrections uint8 // number of times the job was requeued from the DLQ
+
+	Failures []JobFailure // failed attempts, oldest first (at most maxJobFailures)
+
+	// FirstEnqueuedNs is when the event was first enqueued (unix nano), kept across DLQ requeues
+	// (0 for jobs enqueued before it was tracked)
+	FirstEnqueuedNs int64
+}
+
+// JobFailure records a failed processing attempt
+type JobFailure struct {
+	Attempt  uint8 // 1-based, restarts after each resurrection
+	At       time.Time
+	WorkerID [16]byte // worker that ran the attempt (zero for manual replays from another process)
+	Error    string
 }
 
+const (
+	// maxJobFailures bounds the failure history kept with a job (oldest failures are dropped)
+	maxJobFailures = 32
+	// maxJobFailureErrorLen truncates the errors kept in the failure history
+	maxJobFailureErrorLen = 1024
+)
+
 var (
 	ErrNoJobs      = errors.New("no jobs available")
 	ErrLeaseStolen = errors.New("lease was stolen by another worker")
 )
 
-// Job value format (47 bytes total):
-// [vesting_ns:8][expiry_ns:8][lease_vs:12][owner_id:16][attempts:1]
-const jobValueSize = 8 + 8 + 12 + 16 + 1 // 45 bytes
+// Job value format (46 bytes + failure history + 8 bytes):
+// [vesting_ns:8][expiry_ns:8][lease_vs:12][owner_id:16][attempts:1][resurrections:1][failures:variable][first_enqueued_ns:8]
+// (failures and first_enqueued_ns are absent from jobs written before they were tracked)
+const jobValueSize = 8 + 8 + 12 + 16 + 1 + 1 // 46 bytes
+
+// legacyJobValueSize is the size of jobs written before resurrections were tracked
+const legacyJobValueSize = jobValueSize - 1
 
 func encodeJob(j *Job) []byte {
-	buf := make([]byte, jobValueSize)
+	buf := make([]byte, jobValueSize, jobValueSize+failuresSize(j.Failures)+8)
 	binary.BigEndian.PutUint64(buf[0:8], uint64(j.VestingNs))
 	binary.BigEndian.PutUint64(buf[8:16], uint64(j.ExpiryNs))
 	copy(buf[16:28], j.LeaseVS[:])
 	copy(buf[28:44], j.OwnerID[:])
 	buf[44] = j.Attempts
-	return buf
+	buf[45] = j.Resurrections
+	buf = appendFailures(buf, j.Failures)
+	return binary.BigEndian.AppendUint64(buf, uint64(j.FirstEnqueuedNs))
 }
 
 func decodeJob(key fdb.Key, value []byte) (*Job, error) {
-	if len(value) != jobValueSize {
+	if len(value) < legacyJobValueSize {
 		return nil, errors.New("invalid job value size")
 	}
 	j := &Job{
@@ -54,11 +87,115 @@ func decodeJob(key fdb.Key, value []byte) (*Job, error) {
 	}
 	copy(j.LeaseVS[:], value[16:28])
 	copy(j.OwnerID[:], value[28:44])
+	if len(value) >= jobValueSize {
+		j.Resurrections = value[45]
+	}
+	if len(value) > jobValueSize {
+		failures, rest, err := decodeFailures(value[jobValueSize:])
+		if err != nil {
+			return nil, err
+		}
+		j.Failures = failures
+		if len(rest) >= 8 {
+			j.FirstEnqueuedNs = int64(binary.BigEndian.Uint64(rest[:8]))
+		}
+	}
 	return j, nil
 }
 
+// withFailure returns the failure history with a new failure, bounded to maxJobFailures
+func withFailure(failures []JobFailure, f JobFailure) []JobFailure {
+	if len(f.Error) > maxJobFailureErrorLen {
+		f.Error = f.Error[:maxJobFailureErrorLen]
+	}
+	failures = append(failures, f)
+	if len(failures) > maxJobFailures {
+		failures = failures[len(failures)-maxJobFailures:]
+	}
+	return failures
+}
+
+// Failure history format:
+// [count:1] then per failure [attempt:1][at_ns:8][worker_id:16][error_len:2][error:variable]
+const failureHeaderSize = 1 + 8 + 16 + 2
+
+func failuresSize(failures []JobFailure) int {
+	size := 1
+	for _, f := range failures {
+		size += failureHeaderSize + len(f.Error)
+	}
+	return size
+}
+
+func appendFailures(buf []byte, failures []JobFailure) []byte {
+	buf = append(buf, byte(len(failures)))
+	for _, f := range failures {
+		buf = append(buf, f.Attempt)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(f.At.UnixNano()))
+		buf = append(buf, f.WorkerID[:]...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(f.Error)))
+		buf = append(buf, f.Error...)
+	}
+	return buf
+}
+
+// decodeFailures decodes a failure history, returning the bytes following it
+func decodeFailures(buf []byte) ([]JobFailure, []byte, error) {
+	if len(buf) == 0 {
+		return nil, nil, nil
+	}
+	count := int(buf[0])
+	buf = buf[1:]
+	failures := make([]JobFailure, 0, count)
+	for range count {
+		if len(buf) < failureHeaderSize {
+			return nil, nil, errors.New("invalid failure history: truncated")
+		}
+		f := JobFailure{
+			Attempt: buf[0],
+			At:      time.Unix(0, int64(binary.BigEndian.Uint64(buf[1:9]))),
+		}
+		copy(f.WorkerID[:], buf[9:25])
+		errLen := int(binary.BigEndian.Uint16(buf[25:27]))
+		buf = buf[failureHeaderSize:]
+		if len(buf) < errLen {
+			return nil, nil, errors.New("invalid failure history: error truncated")
+		}
+		f.Error = string(buf[:errLen])
+		buf = buf[errLen:]
+		failures = append(failures, f)
+	}
+	return failures, buf, nil
+}
+
+// jobKey is the key of the job of the event at eventVS: queue/<eventVS>.
+// Keys are deterministic, so each event has at most one job in the queue.
+func (a *Automation[Deps]) jobKey(eventVS dcb.Versionstamp) fdb.Key {
+	return a.queueDir.Pack(tuple.Tuple{tupleVersionstamp(eventVS)})
+}
+
+// doneKey is the marker of the event at eventVS once processed successfully: done/<eventVS> = processedAtNs
+func (a *Automation[Deps]) doneKey(eventVS dcb.Versionstamp) fdb.Key {
+	return a.doneDir.Pack(tuple.Tuple{tupleVersionstamp(eventVS)})
+}
+
+func tupleVersionstamp(vs dcb.Versionstamp) tuple.Versionstamp {
+	var txVersion [10]byte
+	copy(txVersion[:], vs[:10])
+	return tuple.Versionstamp{TransactionVersion: txVersion, UserVersion: binary.BigEndian.Uint16(vs[10:12])}
+}
+
+// markDoneInTx records that the event at eventVS was processed, so it is not enqueued again
+// until the marker is purged, after ProcessedRetention (see runProcessedPurger)
+func (a *Automation[Deps]) markDoneInTx(tr fdb.Transaction, eventVS dcb.Versionstamp) {
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(a.clock.Now().UnixNano()))
+	tr.Set(a.doneKey(eventVS), value[:])
+}
+
 // extractEventVSFromJobKey extracts the event versionstamp from a job key
-// Job key format: queue.Pack(tuple.Tuple{eventVS, rand20})
+// Job key format: queue.Pack(tuple.Tuple{eventVS}), or queue.Pack(tuple.Tuple{eventVS, rand20}) for jobs enqueued
+// before keys were deterministic
 func extractEventVSFromJobKey(queueDir subspace.Subspace, key fdb.Key) (dcb.Versionstamp, error) {
 	keyTuple, err := queueDir.Unpack(key)
 	if err != nil {
@@ -81,93 +218,201 @@ func extractEventVSFromJobKey(queueDir subspace.Subspace, key fdb.Key) (dcb.Vers
 
 // enqueueInTx enqueues a job for the given event versionstamp
 func (a *Automation[Deps]) enqueueInTx(tr fdb.Transaction, eventVS dcb.Versionstamp) error {
-	// Convert dcb.Versionstamp to tuple.Versionstamp
-	var txVersion [10]byte
-	copy(txVersion[:], eventVS[:10])
-	userVersion := binary.BigEndian.Uint16(eventVS[10:12])
-	tupleVs := tuple.Versionstamp{TransactionVersion: txVersion, UserVersion: userVersion}
+	return a.enqueueJobInTx(tr, eventVS, 0, nil, 0)
+}
 
-	// Generate random suffix for uniqueness
-	var rand20 [20]byte
-	if _, err := rand.Read(rand20[:]); err != nil {
+// enqueueJobInTx enqueues a fresh job, carrying over how many times it was requeued from the DLQ, its failures
+// and when it was first enqueued (0 = now).
+// Nothing is written when the event already has a job (waiting, leased or backing off), or was processed:
+// the event runs once, even when a rewound cursor or a DLQ requeue enqueues it again.
+func (a *Automation[Deps]) enqueueJobInTx(tr fdb.Transaction, eventVS dcb.Versionstamp, resurrections uint8, failures []JobFailure, firstEnqueuedNs int64) error {
+	done, err := tr.Get(a.doneKey(eventVS)).Get()
+	if err != nil {
 		return err
 	}
+	if done != nil {
+		return nil
+	}
+
+	jobKey := a.jobKey(eventVS)
 
-	// Job key: queue/<eventVS>/<rand20>
-	jobKey := a.queueDir.Pack(tuple.Tuple{tupleVs, rand20[:]})
+	// The prefix range also covers the random-suffixed keys of jobs enqueued before keys were deterministic
+	existing, err := fdb.PrefixRange(jobKey)
+	if err != nil {
+		return err
+	}
+	kvs, err := tr.GetRange(existing, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+	if err != nil {
+		return err
+	}
+	if len(kvs) > 0 {
+		return nil
+	}
 
 	// Job value: metadata only, event fetched from dcb when processing
 	job := &Job{
-		VestingNs: 0, // available immediately
-		ExpiryNs:  0, // no lease yet
-		Attempts:  0,
+		VestingNs:     0, // available immediately
+		ExpiryNs:      0, // no lease yet
+		Attempts:      0,
+		Resurrections: resurrections,
+		Failures:      failures,
+	}
+	job.FirstEnqueuedNs = firstEnqueuedNs
+	if job.FirstEnqueuedNs == 0 {
+		job.FirstEnqueuedNs = a.clock.Now().UnixNano()
 	}
 
 	tr.Set(jobKey, encodeJob(job))
 	return nil
 }
 
-// dequeue attempts to claim a job from the queue
-func (a *Automation[Deps]) dequeue() (*Job, error) {
-	var job *Job
-
-	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
-		now := time.Now().UnixNano()
+// DequeueStats counts the candidates workers examined while looking for a job to claim.
+// Many skipped candidates per claim mean scans are spent on jobs that can't be taken yet
+// (leased or backing off), e.g. a BatchSize too small for the number of jobs in progress.
+type DequeueStats struct {
+	Scans             uint64 // dequeue transactions
+	Claims            uint64 // jobs claimed
+	SkippedLeased     uint64 // candidates leased by another worker
+	SkippedBackingOff uint64 // candidates waiting for their next attempt
+	SkippedMalformed  uint64 // candidates that could not be decoded
+}
 
-		// Range read from queue
-		iter := tr.GetRange(a.queueDir, fdb.RangeOptions{
-			Limit: a.config.BatchSize,
-		}).Iterator()
+// dequeueCounters accumulates DequeueStats across workers
+type dequeueCounters struct {
+	scans, claims, skippedLeased, skippedBackingOff, skippedMalformed atomic.Uint64
+}
 
-		for iter.Advance() {
-			kv, err := iter.Get()
-			if err != nil {
-				return nil, err
-			}
+func (c *dequeueCounters) add(claimed bool, skipped DequeueStats) {
+	c.scans.Add(1)
+	if claimed {
+		c.claims.Add(1)
+	}
+	c.skippedLeased.Add(skipped.SkippedLeased)
+	c.skippedBackingOff.Add(skipped.SkippedBackingOff)
+	c.skippedMalformed.Add(skipped.SkippedMalformed)
+}
 
-			j, err := decodeJob(kv.Key, kv.Value)
-			if err != nil {
-				continue // skip malformed jobs
-			}
+// DequeueStats returns the dequeue counters of this automation instance since it was created
+func (a *Automation[Deps]) DequeueStats() DequeueStats {
+	return DequeueStats{
+		Scans:             a.dequeueCounters.scans.Load(),
+		Claims:            a.dequeueCounters.claims.Load(),
+		SkippedLeased:     a.dequeueCounters.skippedLeased.Load(),
+		SkippedBackingOff: a.dequeueCounters.skippedBackingOff.Load(),
+		SkippedMalformed:  a.dequeueCounters.skippedMalformed.Load(),
+	}
+}
 
-			// Check if job is vested (available)
-			if j.VestingNs > now {
-				continue
-			}
+// dequeue attempts to claim a job from the queue.
+// Each scan reads at most BatchSize candidates, starting after the last candidate of the previous scan
+// and wrapping around to the start of the queue: jobs that can't be claimed (leased, backing off)
+// don't hide the jobs behind them, which are reached by the next scans.
+func (a *Automation[Deps]) dequeue() (*Job, error) {
+	var job *Job
+	var next fdb.Key
+	var skipped DequeueStats
+
+	queueBegin, queueEnd := a.queueDir.FDBRangeKeys()
+	from := a.scanStart()
+	passes := []fdb.KeyRange{{Begin: from, End: queueEnd}}
+	if !bytes.Equal(from, queueBegin.FDBKey()) {
+		passes = append(passes, fdb.KeyRange{Begin: queueBegin, End: from})
+	}
 
-			// Check if job is owned and lease not expired
-			if j.OwnerID != [16]byte{} && j.ExpiryNs > now {
-				continue
+	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		job, next, skipped = nil, from, DequeueStats{}
+		now := a.clock.Now().UnixNano()
+		budget := a.config.BatchSize
+
+		for _, pass := range passes {
+			// Range read from queue
+			iter := tr.GetRange(pass, fdb.RangeOptions{
+				Limit: budget,
+			}).Iterator()
+
+			for iter.Advance() {
+				kv, err := iter.Get()
+				if err != nil {
+					return nil, err
+				}
+				budget--
+				next = append(slices.Clone(kv.Key), 0x00) // the next scan goes on after this candidate
+
+				j, err := decodeJob(kv.Key, kv.Value)
+				if err != nil {
+					skipped.SkippedMalformed++
+					continue // skip malformed jobs
+				}
+
+				// Check if job is vested (available)
+				if j.VestingNs > now {
+					skipped.SkippedBackingOff++
+					continue
+				}
+
+				// Check if job is owned and lease not expired
+				if j.OwnerID != [16]byte{} && j.ExpiryNs > now {
+					skipped.SkippedLeased++
+					continue
+				}
+
+				// Extract event VS from key
+				eventVS, err := extractEventVSFromJobKey(a.queueDir, kv.Key)
+				if err != nil {
+					skipped.SkippedMalformed++
+					continue
+				}
+				j.EventVS = eventVS
+
+				// Claim the job
+				j.OwnerID = a.workerID
+				j.ExpiryNs = now + int64(a.config.LeaseTTL)
+				// LeaseVS would ideally use FDB versionstamp but for simplicity use timestamp
+				binary.BigEndian.PutUint64(j.LeaseVS[:8], uint64(now))
+
+				tr.Set(kv.Key, encodeJob(j))
+				job = j
+				return nil, nil
 			}
 
-			// Extract event VS from key
-			eventVS, err := extractEventVSFromJobKey(a.queueDir, kv.Key)
-			if err != nil {
-				continue
+			if budget == 0 {
+				return nil, ErrNoJobs
 			}
-			j.EventVS = eventVS
-
-			// Claim the job
-			j.OwnerID = a.workerID
-			j.ExpiryNs = now + int64(a.config.LeaseTTL)
-			// LeaseVS would ideally use FDB versionstamp but for simplicity use timestamp
-			binary.BigEndian.PutUint64(j.LeaseVS[:8], uint64(now))
-
-			tr.Set(kv.Key, encodeJob(j))
-			job = j
-			return nil, nil
 		}
 
+		// Every job was examined: start from the same place next time
+		next = from
 		return nil, ErrNoJobs
 	})
 
+	if err == nil || errors.Is(err, ErrNoJobs) {
+		a.setScanStart(next)
+		a.dequeueCounters.add(job != nil, skipped)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return job, nil
 }
 
-// deleteJob removes a completed job
+// scanStart returns the key the next dequeue scan starts from
+func (a *Automation[Deps]) scanStart() fdb.Key {
+	a.scanMu.Lock()
+	defer a.scanMu.Unlock()
+	if a.scanFrom == nil {
+		begin, _ := a.queueDir.FDBRangeKeys()
+		return begin.FDBKey()
+	}
+	return a.scanFrom
+}
+
+func (a *Automation[Deps]) setScanStart(key fdb.Key) {
+	a.scanMu.Lock()
+	defer a.scanMu.Unlock()
+	a.scanFrom = key
+}
+
+// deleteJob removes a completed job, marking its event processed in the same transaction
 func (a *Automation[Deps]) deleteJob(job *Job) error {
 	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
 		// Verify we still own the job
@@ -186,6 +431,7 @@ func (a *Automation[Deps]) deleteJob(job *Job) error {
 		}
 
 		tr.Clear(job.Key)
+		a.markDoneInTx(tr, job.EventVS)
 		return nil, nil
 	})
 	return err
@@ -210,14 +456,21 @@ func (a *Automation[Deps]) retryJob(job *Job, processErr error) error {
 		}
 
 		current.Attempts++
+		current.Failures = withFailure(current.Failures, JobFailure{
+			Attempt:  current.Attempts,
+			At:       a.clock.Now(),
+			WorkerID: a.workerID,
+			Error:    errorString(processErr),
+		})
 		if int(current.Attempts) >= a.config.MaxAttempts {
 			// Move to DLQ
-			return nil, a.moveToDLQInTx(tr, job, processErr)
+			current.EventVS = job.EventVS
+			return nil, a.moveToDLQInTx(tr, current, processErr)
 		}
 
 		// Exponential backoff: 1min, 5min, 25min
 		backoff := a.calculateBackoff(int(current.Attempts))
-		current.VestingNs = time.Now().Add(backoff).UnixNano()
+		current.VestingNs = a.clock.Now().Add(backoff).UnixNano()
 		current.OwnerID = [16]byte{} // release ownership
 		current.ExpiryNs = 0
 
@@ -236,3 +489,104 @@ func (a *Automation[Deps]) calculateBackoff(attempt int) time.Duration {
 	}
 	return base * time.Duration(multiplier)
 }
+
+// queueDepthBatchSize bounds the keys counted per transaction, so deep queues don't hit FDB's transaction time limit
+const queueDepthBatchSize = 10_000
+
+// QueueDepth returns the number of jobs waiting or in progress, for readiness probes and autoscalers.
+// It reads every job key: poll it at probe intervals, not in hot paths.
+func (a *Automation[Deps]) QueueDepth(ctx context.Context) (int, error) {
+	depth := 0
+	begin, end := a.queueDir.FDBRangeKeys()
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		res, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+			return tr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{
+				Limit: queueDepthBatchSize,
+				Mode:  fdb.StreamingModeWantAll,
+			}).GetSliceWithError()
+		})
+		if err != nil {
+			return 0, err
+		}
+		kvs := res.([]fdb.KeyValue)
+		depth += len(kvs)
+		if len(kvs) < queueDepthBatchSize {
+			return depth, nil
+		}
+		begin = append(slices.Clone(kvs[len(kvs)-1].Key), 0x00)
+	}
+}
+
+// maxProcessedPurgeInterval bounds the time between two purges of the markers of processed events
+const maxProcessedPurgeInterval = time.Hour
+
+// runProcessedPurger purges the markers of processed events older than ProcessedRetention, so they don't
+// grow with every event processed. Every process of the automation purges: purges are idempotent.
+func (a *Automation[Deps]) runProcessedPurger() {
+	defer a.wg.Done()
+	defer a.recoverLoop("processed purger")
+
+	ticker := a.clock.NewTicker(min(a.config.ProcessedRetention, maxProcessedPurgeInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+
+		if _, err := a.purgeExpiredProcessed(a.ctx); err != nil {
+			a.errs.report(fmt.Errorf("purge processed: %w", err))
+		}
+	}
+}
+
+// purgeExpiredProcessed removes the markers older than ProcessedRetention at the clock's time
+func (a *Automation[Deps]) purgeExpiredProcessed(ctx context.Context) (int, error) {
+	return a.PurgeProcessed(ctx, a.clock.Now().Add(-a.config.ProcessedRetention))
+}
+
+// PurgeProcessed removes the markers of the events processed before the given time, and returns how many it removed.
+// Markers keep processed events from being enqueued again (e.g. after a cursor rewind), one per event.
+// Running automations purge them after ProcessedRetention; the automation doesn't need to be started.
+func (a *Automation[Deps]) PurgeProcessed(ctx context.Context, before time.Time) (int, error) {
+	return clearRangeWhere(ctx, a.db, a.doneDir, func(value []byte) bool {
+		return len(value) == 8 && int64(binary.BigEndian.Uint64(value)) < before.UnixNano()
+	})
+}
+
+// OldestUnprocessedAge returns how long ago the event of the oldest queued job was committed, on the automation's clock
+// (0 when the queue is empty, or when that event was stored before commit times were recorded)
+func (a *Automation[Deps]) OldestUnprocessedAge(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	res, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.GetRange(a.queueDir, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+	})
+	if err != nil {
+		return 0, err
+	}
+	kvs := res.([]fdb.KeyValue)
+	if len(kvs) == 0 {
+		return 0, nil
+	}
+
+	// Jobs are keyed by event position: the first one holds the oldest event
+	eventVS, err := extractEventVSFromJobKey(a.queueDir, kvs[0].Key)
+	if err != nil {
+		return 0, err
+	}
+	event, err := a.fetchEvent(ctx, eventVS)
+	if err != nil {
+		return 0, fmt.Errorf("fetch oldest queued event: %w", err)
+	}
+	if event.CommittedAt.IsZero() {
+		return 0, nil
+	}
+	return a.clock.Now().Sub(event.CommittedAt), nil
+}
@@ -0,0 +1,90 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AutomationSim drives an automation step by step, in the calling goroutine: each step does what one
+// iteration of a loop of the automation does (a watcher poll, a worker claiming a job, running it,
+// a DLQ retrier pass), and nothing runs in the background.
+//
+// On a VirtualClock (see WithClock), tests script the interleavings of appends, polls, lease expiries,
+// backoffs and DLQ cooldowns, and replay them exactly, where tests of started automations depend on the scheduler.
+// Automations of the same queueId on the same store and clock stand for several processes:
+// a job claimed by one of them can be claimed by another once its lease expired.
+type AutomationSim[Deps any] struct {
+	a *Automation[Deps]
+}
+
+// Simulate returns the step-by-step driver of the automation, which must not be started
+func (a *Automation[Deps]) Simulate() (*AutomationSim[Deps], error) {
+	if a.ctx != nil {
+		return nil, errors.New("automation already started: simulations drive automations that are not")
+	}
+	a.catchUp.Store(newCatchUpLimiter(a.config.CatchUpRateLimit, max(time.Second, a.config.PollInterval), a.clock.Now))
+	return &AutomationSim[Deps]{a: a}, nil
+}
+
+// Poll runs a watcher poll: up to BatchSize events (fewer under WithCatchUpRateLimit, on the clock's time) after the cursor are enqueued, and the cursor moved past them.
+// It returns how many were enqueued.
+func (s *AutomationSim[Deps]) Poll(ctx context.Context) (int, error) {
+	enqueued, _, err := s.a.pollAndEnqueue(ctx)
+	return enqueued, err
+}
+
+// Claim runs a worker's dequeue: the next job available at the clock's time is leased for LeaseTTL.
+// It returns ErrNoJobs when every job is leased or backing off (or after BatchSize candidates, as workers do).
+func (s *AutomationSim[Deps]) Claim() (*Job, error) {
+	return s.a.dequeue()
+}
+
+// Run runs the command of a claimed job, then deletes the job or schedules its next attempt after the backoff
+// (moving it to the DLQ after MaxAttempts), as a worker does. It returns the command's error, joined with
+// ErrLeaseStolen when the outcome couldn't be recorded because another worker claimed the job meanwhile.
+func (s *AutomationSim[Deps]) Run(ctx context.Context, job *Job) error {
+	processErr := s.a.runJob(ctx, job.info(s.a.queueId))
+	var recordErr error
+	if processErr != nil {
+		recordErr = s.a.retryJob(job, processErr)
+	} else {
+		recordErr = s.a.deleteJob(job)
+	}
+	return errors.Join(processErr, recordErr)
+}
+
+// Drain claims and runs the jobs available at the clock's time until none is, and returns how many ran
+// and the errors of those that failed. Failed jobs back off, so the clock must move for them to run again.
+func (s *AutomationSim[Deps]) Drain(ctx context.Context) (int, error) {
+	var errs []error
+	for ran := 0; ; ran++ {
+		if err := ctx.Err(); err != nil {
+			return ran, errors.Join(append(errs, err)...)
+		}
+		job, err := s.Claim()
+		if errors.Is(err, ErrNoJobs) {
+			return ran, errors.Join(errs...)
+		}
+		if err != nil {
+			return ran, errors.Join(append(errs, err)...)
+		}
+		if err := s.Run(ctx, job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// PurgeProcessed runs a pass of the purger at the clock's time, removing the markers of the events processed
+// more than ProcessedRetention ago, and returns how many it removed
+func (s *AutomationSim[Deps]) PurgeProcessed(ctx context.Context) (int, error) {
+	return s.a.purgeExpiredProcessed(ctx)
+}
+
+// RetryDLQ runs a pass of the DLQ retrier at the clock's time, requeuing the entries the DLQRetryPolicy allows
+func (s *AutomationSim[Deps]) RetryDLQ(ctx context.Context) error {
+	if s.a.dlqRetry == nil {
+		return errors.New("automation without DLQ retry policy (see WithDLQRetry)")
+	}
+	return s.a.retryDLQ(ctx, s.a.clock.Now())
+}
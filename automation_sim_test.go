@@ -0,0 +1,218 @@
+package fairway_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutomationSim_ExpiredLeaseIsStolen(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+	ctx := context.Background()
+
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent}
+
+	// Given: two processes running the automation on the same clock
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts := []fairway.AutomationOption[TestDeps]{
+		fairway.WithClock[TestDeps](clock),
+		fairway.WithLeaseTTL[TestDeps](30 * time.Second),
+	}
+	automationA, store := setupTestAutomation(t, dcbNs, queueId, deps, opts...)
+	automationB, _ := setupTestAutomation(t, dcbNs, queueId, deps, opts...)
+	simA, err := automationA.Simulate()
+	require.NoError(t, err)
+	simB, err := automationB.Simulate()
+	require.NoError(t, err)
+
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-sim-lease"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	enqueued, err := simA.Poll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, enqueued)
+
+	// When: A claims the job, stalls past its lease, and B claims it
+	jobA, err := simA.Claim()
+	require.NoError(t, err)
+	_, err = simB.Claim()
+	require.ErrorIs(t, err, fairway.ErrNoJobs, "the lease holds")
+
+	clock.Advance(31 * time.Second)
+	jobB, err := simB.Claim()
+	require.NoError(t, err)
+
+	// Then: A can't record its outcome, B can
+	assert.ErrorIs(t, simA.Run(ctx, jobA), fairway.ErrLeaseStolen)
+	assert.NoError(t, simB.Run(ctx, jobB))
+	assert.Equal(t, int32(2), handlerCalled.Load(), "at-least-once: both ran the command")
+
+	depth, err := automationA.QueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, depth)
+}
+
+func TestAutomationSim_RetriesThenResurrectsFromDLQ(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+	ctx := context.Background()
+
+	failing := &atomic.Bool{}
+	failing.Store(true)
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent, Failing: failing}
+
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithClock[TestDeps](clock),
+		fairway.WithMaxAttempts[TestDeps](2),
+		fairway.WithRetryBaseWait[TestDeps](time.Minute),
+		fairway.WithDLQRetry[TestDeps](fairway.DLQRetryPolicy{Cooldown: 10 * time.Minute, MaxResurrections: 1}),
+	)
+	sim, err := automation.Simulate()
+	require.NoError(t, err)
+
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-sim-dlq"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	_, err = sim.Poll(ctx)
+	require.NoError(t, err)
+
+	// Given: a first attempt failed, and the job backs off
+	ran, err := sim.Drain(ctx)
+	assert.Equal(t, 1, ran)
+	assert.Error(t, err)
+	ran, err = sim.Drain(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, ran, "backing off")
+
+	// When: the second attempt fails too, after the backoff
+	clock.Advance(time.Minute)
+	ran, err = sim.Drain(ctx)
+	assert.Equal(t, 1, ran)
+	assert.Error(t, err)
+
+	// Then: the job waits in the DLQ for the cooldown
+	var entries []fairway.DLQEntry
+	for entry, err := range automation.ListDLQ() {
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 1)
+	assert.Equal(t, clock.Now(), entries[0].EnqueuedAt.UTC())
+
+	require.NoError(t, sim.RetryDLQ(ctx))
+	depth, err := automation.QueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, depth, "cooling down")
+
+	// When: the cooldown passed and the dependency recovered
+	clock.Advance(10 * time.Minute)
+	failing.Store(false)
+	require.NoError(t, sim.RetryDLQ(ctx))
+
+	// Then: the resurrected job runs at once
+	ran, err = sim.Drain(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, int32(3), handlerCalled.Load())
+	for _, err := range automation.ListDLQ() {
+		t.Fatalf("DLQ should be empty (err: %v)", err)
+	}
+}
+
+func TestAutomationSim_RejectsStartedAutomations(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	var lastEvent fairway.Event
+	automation, _ := setupTestAutomation(t, dcbNs, "test-queue", TestDeps{HandlerCalled: &atomic.Int32{}, LastEvent: &lastEvent})
+
+	// Given
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+
+	// When
+	_, err := automation.Simulate()
+
+	// Then
+	assert.Error(t, err)
+}
+
+func TestAutomationSim_QueueAgeAndMetricsFollowTheClock(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	ctx := context.Background()
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: &atomic.Int32{}, LastEvent: &lastEvent}
+
+	// Given: a clock starting now, as commit times are the store's
+	clock := fairway.NewVirtualClock(time.Now())
+	metrics := &recordingAutomationMetrics{enqueued: map[string]int{}}
+	automation, store := setupTestAutomation(t, dcbNs, "test-queue", deps,
+		fairway.WithClock[TestDeps](clock),
+		fairway.WithAutomationMetrics[TestDeps](metrics),
+	)
+	sim, err := automation.Simulate()
+	require.NoError(t, err)
+
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-sim-age"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	enqueued, err := sim.Poll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, enqueued)
+
+	// When
+	clock.Advance(time.Hour)
+
+	// Then: the job is an hour old, and the poll took no time on the clock
+	age, err := automation.OldestUnprocessedAge(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, age, float64(time.Minute))
+	metrics.mu.Lock()
+	assert.Equal(t, []time.Duration{0}, metrics.durations)
+	metrics.mu.Unlock()
+}
+
+func TestVirtualClock_FiresDueTimersInOrder(t *testing.T) {
+	// Given
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := fairway.NewVirtualClock(start)
+	ticker := clock.NewTicker(10 * time.Second)
+	after := clock.After(15 * time.Second)
+
+	// When
+	clock.Advance(12 * time.Second)
+
+	// Then
+	assert.Equal(t, start.Add(10*time.Second), <-ticker.Chan())
+	select {
+	case <-after:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	// When: a tick is left unread
+	clock.Advance(20 * time.Second)
+
+	// Then
+	assert.Equal(t, start.Add(15*time.Second), <-after)
+	assert.Equal(t, start.Add(20*time.Second), <-ticker.Chan(), "the tick at 30s is dropped")
+	assert.Equal(t, start.Add(32*time.Second), clock.Now())
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.Chan():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
@@ -1,15 +1,24 @@
 package fairway_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"github.com/err0r500/fairway"
 	"github.com/err0r500/fairway/dcb"
 	"github.com/google/uuid"
@@ -36,6 +45,7 @@ type TestDeps struct {
 	LastEvent     *fairway.Event
 	LastEventMu   *sync.Mutex
 	ShouldFail    bool
+	Failing       *atomic.Bool // optional, fails while true (ShouldFail that can be switched off)
 	FailCount     *atomic.Int32
 }
 
@@ -55,7 +65,7 @@ func (c *TestCommand) Run(ctx context.Context, ra fairway.EventReadAppenderExten
 		deps.LastEventMu.Unlock()
 	}
 
-	if deps.ShouldFail {
+	if deps.ShouldFail || (deps.Failing != nil && deps.Failing.Load()) {
 		if deps.FailCount != nil {
 			deps.FailCount.Add(1)
 		}
@@ -311,6 +321,596 @@ func TestAutomation_DLQAfterMaxAttempts(t *testing.T) {
 	}, 5*time.Second, 50*time.Millisecond, "job should end up in DLQ")
 }
 
+func TestAutomation_ExportDLQAsJSONLines(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: &atomic.Int32{}, LastEvent: &lastEvent, ShouldFail: true}
+
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithMaxAttempts[TestDeps](2),
+		fairway.WithRetryBaseWait[TestDeps](10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given - two events dead-lettered
+	require.NoError(t, automation.Start(ctx))
+	for _, userID := range []string{"user-1", "user-2"} {
+		dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: userID}))
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+	var entries []fairway.DLQEntry
+	require.Eventually(t, func() bool {
+		entries = entries[:0]
+		for entry, err := range automation.ListDLQ() {
+			if err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		return len(entries) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+	automation.Stop()
+
+	// When
+	var out bytes.Buffer
+	written, err := automation.ExportDLQ(ctx, &out)
+
+	// Then - one JSON object per line, readable without the Go types
+	require.NoError(t, err)
+	assert.Equal(t, 2, written)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &doc))
+		assert.Equal(t, queueId, doc["queueId"])
+		assert.Equal(t, hex.EncodeToString(entries[i].Key), doc["key"])
+		assert.Equal(t, entries[i].EventVS.String(), doc["position"])
+		assert.Equal(t, float64(2), doc["attempts"])
+		assert.Equal(t, entries[i].Error, doc["error"])
+		failures, ok := doc["failures"].([]any)
+		require.True(t, ok)
+		require.Len(t, failures, 2)
+		assert.Equal(t, map[string]any{
+			"attempt":  float64(1),
+			"at":       entries[i].Failures[0].At.Format(time.RFC3339Nano),
+			"workerId": hex.EncodeToString(entries[i].Failures[0].WorkerID[:]),
+			"error":    entries[i].Failures[0].Error,
+		}, failures[0])
+	}
+}
+
+func TestAutomation_ReplayDLQEntryRunsTheCurrentHandler(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	failing := &atomic.Bool{}
+	failing.Store(true)
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+
+	deps := TestDeps{
+		HandlerCalled: handlerCalled,
+		LastEvent:     &lastEvent,
+		Failing:       failing,
+	}
+
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithMaxAttempts[TestDeps](2),
+		fairway.WithRetryBaseWait[TestDeps](10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+
+	// Given: an event in the DLQ after 2 failed attempts
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-replay"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	dlqEntry := func() (fairway.DLQEntry, bool) {
+		for entry, err := range automation.ListDLQ() {
+			if err == nil {
+				return entry, true
+			}
+		}
+		return fairway.DLQEntry{}, false
+	}
+	require.Eventually(t, func() bool {
+		_, ok := dlqEntry()
+		return ok
+	}, 5*time.Second, 20*time.Millisecond, "job should end up in DLQ")
+	automation.Stop()
+	require.NoError(t, automation.Wait())
+
+	entry, _ := dlqEntry()
+	require.Len(t, entry.Failures, 2)
+	for i, f := range entry.Failures {
+		assert.Equal(t, uint8(i+1), f.Attempt)
+		assert.Equal(t, "simulated failure", f.Error)
+		assert.NotEqual(t, [16]byte{}, f.WorkerID)
+		assert.False(t, f.At.IsZero())
+	}
+
+	// When: replayed while the handler still fails
+	err := automation.ReplayDLQEntry(ctx, entry.Key)
+
+	// Then: the entry stays in the DLQ with the replay in its history
+	require.Error(t, err)
+	entry, ok := dlqEntry()
+	require.True(t, ok)
+	require.Len(t, entry.Failures, 3)
+	assert.Equal(t, "replay: simulated failure", entry.Failures[2].Error)
+
+	// When: replayed once the handler is fixed
+	failing.Store(false)
+	calls := handlerCalled.Load()
+	require.NoError(t, automation.ReplayDLQEntry(ctx, entry.Key))
+
+	// Then: the event went through the handler and the entry is removed
+	assert.Equal(t, calls+1, handlerCalled.Load())
+	assert.Equal(t, "user-replay", lastEvent.Data.(TestAutomationEvent).UserID)
+	_, ok = dlqEntry()
+	assert.False(t, ok)
+	assert.ErrorIs(t, automation.ReplayDLQEntry(ctx, entry.Key), fairway.ErrDLQEntryNotFound)
+}
+
+func TestAutomation_QueueDepthAndOldestAge(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	failCount := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: &atomic.Int32{},
+		LastEvent:     &lastEvent,
+		ShouldFail:    true,
+		FailCount:     failCount,
+	}
+
+	// Failed jobs wait a minute before their next attempt: they stay queued
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithRetryBaseWait[TestDeps](time.Minute),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given: an empty queue
+	depth, err := automation.QueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, depth)
+	age, err := automation.OldestUnprocessedAge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, age)
+
+	// When: 3 events can't be processed
+	require.NoError(t, automation.Start(ctx))
+	for i := range 3 {
+		dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: fmt.Sprintf("user-%d", i)}))
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+	require.Eventually(t, func() bool {
+		return failCount.Load() == 3
+	}, 5*time.Second, 20*time.Millisecond, "every job should be attempted")
+
+	// Then
+	depth, err = automation.QueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, depth)
+	age, err = automation.OldestUnprocessedAge(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, age)
+}
+
+func TestAutomation_ReenqueuedEventsKeepASingleJob(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	failCount := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: &atomic.Int32{},
+		LastEvent:     &lastEvent,
+		LastEventMu:   &sync.Mutex{},
+		ShouldFail:    true,
+		FailCount:     failCount,
+	}
+
+	// The failed job waits a minute before its next attempt: it stays queued
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithRetryBaseWait[TestDeps](time.Minute),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given: a queued job for an event
+	require.NoError(t, automation.Start(ctx))
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	require.Eventually(t, func() bool {
+		return failCount.Load() == 1
+	}, 5*time.Second, 20*time.Millisecond, "the job should be attempted")
+
+	// When: the cursor is lost, so the watcher enqueues the event again
+	cursorKey := subspace.Sub(dcbNs + "/" + queueId).Pack(tuple.Tuple{"cursor"})
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		tr.Clear(cursorKey)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		caughtUp, err := automation.CaughtUp()
+		return err == nil && caughtUp
+	}, 5*time.Second, 20*time.Millisecond, "the watcher should read the event again")
+
+	// Then
+	depth, err := automation.QueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, depth)
+	assert.Equal(t, int32(1), failCount.Load(), "the backing off job should be kept")
+}
+
+func TestAutomation_ProcessedEventsAreNotEnqueuedAgain(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+	ctx := context.Background()
+
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	automation, store := setupTestAutomation(t, dcbNs, queueId, TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent},
+		fairway.WithClock[TestDeps](clock),
+		fairway.WithProcessedRetention[TestDeps](30*time.Minute),
+	)
+	sim, err := automation.Simulate()
+	require.NoError(t, err)
+
+	// Given: an event processed successfully
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	_, err = sim.Poll(ctx)
+	require.NoError(t, err)
+	_, err = sim.Drain(ctx)
+	require.NoError(t, err)
+
+	// When: the cursor is lost, so the watcher reads the event again
+	cursorKey := subspace.Sub(dcbNs + "/" + queueId).Pack(tuple.Tuple{"cursor"})
+	clearCursor := func() {
+		_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+			tr.Clear(cursorKey)
+			return nil, nil
+		})
+		require.NoError(t, err)
+	}
+	clearCursor()
+	enqueued, err := sim.Poll(ctx)
+	require.NoError(t, err)
+
+	// Then
+	assert.Zero(t, enqueued)
+	assert.Equal(t, int32(1), handlerCalled.Load())
+
+	// When: its marker expires
+	purged, err := sim.PurgeProcessed(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged, "kept for the retention")
+	clock.Advance(time.Hour)
+	purged, err = sim.PurgeProcessed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	clearCursor()
+
+	// Then: a replay enqueues it again
+	enqueued, err = sim.Poll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, enqueued)
+}
+
+func TestAutomation_JobsBehindBackingOffJobsAreNotStarved(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	handlerCalled := &atomic.Int32{}
+	failing := &atomic.Bool{}
+	failing.Store(true)
+	failCount := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: handlerCalled,
+		LastEvent:     &lastEvent,
+		LastEventMu:   &sync.Mutex{},
+		Failing:       failing,
+		FailCount:     failCount,
+	}
+
+	// Scans read 2 candidates: as many as the jobs backing off at the start of the queue
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithRetryBaseWait[TestDeps](time.Minute),
+		fairway.WithBatchSize[TestDeps](2),
+		fairway.WithNumWorkers[TestDeps](1),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given: 2 jobs backing off
+	require.NoError(t, automation.Start(ctx))
+	for i := range 2 {
+		dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: fmt.Sprintf("user-%d", i)}))
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+	require.Eventually(t, func() bool {
+		return failCount.Load() == 2
+	}, 5*time.Second, 20*time.Millisecond, "every job should be attempted")
+
+	// When: a job is enqueued behind them
+	failing.Store(false)
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-2"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then
+	require.Eventually(t, func() bool {
+		return handlerCalled.Load() == 3
+	}, 5*time.Second, 20*time.Millisecond, "the last job should be claimed")
+	stats := automation.DequeueStats()
+	assert.Equal(t, uint64(3), stats.Claims)
+	assert.Positive(t, stats.SkippedBackingOff)
+	assert.GreaterOrEqual(t, stats.Scans, stats.Claims)
+}
+
+// recordingAutomationMetrics records the enqueue metrics of automations
+type recordingAutomationMetrics struct {
+	mu        sync.Mutex
+	polls     int
+	failures  int
+	enqueued  map[string]int
+	durations []time.Duration
+}
+
+func (m *recordingAutomationMetrics) RecordEnqueueDuration(_ string, d time.Duration, success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.polls++
+	m.durations = append(m.durations, d)
+	if !success {
+		m.failures++
+	}
+}
+
+func (m *recordingAutomationMetrics) RecordEnqueuedEvents(queueId string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued[queueId] += count
+}
+
+// namespacedAutomationMetrics records the enqueue metrics of each namespace apart
+type namespacedAutomationMetrics struct {
+	recordingAutomationMetrics
+	namespaces sync.Map // namespace -> *recordingAutomationMetrics
+}
+
+func (m *namespacedAutomationMetrics) ForNamespace(namespace string) fairway.AutomationMetrics {
+	metrics, _ := m.namespaces.LoadOrStore(namespace, &recordingAutomationMetrics{enqueued: map[string]int{}})
+	return metrics.(*recordingAutomationMetrics)
+}
+
+func (m *recordingAutomationMetrics) snapshot() (int, int, map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.polls, m.failures, maps.Clone(m.enqueued)
+}
+
+func TestAutomation_RecordsEnqueueMetrics(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: handlerCalled,
+		LastEvent:     &lastEvent,
+		LastEventMu:   &sync.Mutex{},
+	}
+	metrics := &recordingAutomationMetrics{enqueued: map[string]int{}}
+
+	// Batches of 2 events: 3 events take 2 polls
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithBatchSize[TestDeps](2),
+		fairway.WithAutomationMetrics[TestDeps](metrics),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given
+	for i := range 3 {
+		dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: fmt.Sprintf("user-%d", i)}))
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+
+	// When
+	require.NoError(t, automation.Start(ctx))
+	require.Eventually(t, func() bool {
+		return handlerCalled.Load() == 3
+	}, 5*time.Second, 20*time.Millisecond, "every event should be processed")
+
+	// Then
+	polls, failures, enqueued := metrics.snapshot()
+	assert.GreaterOrEqual(t, polls, 2)
+	assert.Zero(t, failures)
+	assert.Equal(t, map[string]int{queueId: 3}, enqueued)
+}
+
+func TestAutomation_MetricsRecordThroughTheNamespaceOfTheStore(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: handlerCalled,
+		LastEvent:     &lastEvent,
+		LastEventMu:   &sync.Mutex{},
+	}
+	metrics := &namespacedAutomationMetrics{recordingAutomationMetrics: recordingAutomationMetrics{enqueued: map[string]int{}}}
+
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithAutomationMetrics[TestDeps](metrics),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// When
+	require.NoError(t, automation.Start(ctx))
+	require.Eventually(t, func() bool {
+		return handlerCalled.Load() == 1
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// Then - recorded in the metrics of the store's namespace only
+	namespaced, ok := metrics.namespaces.Load(dcbNs)
+	require.True(t, ok)
+	_, _, enqueued := namespaced.(*recordingAutomationMetrics).snapshot()
+	assert.Equal(t, map[string]int{queueId: 1}, enqueued)
+	polls, _, _ := metrics.snapshot()
+	assert.Zero(t, polls)
+}
+
+func TestAutomation_DLQRetryRequeuesUntilMaxResurrections(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	queueId := "test-queue"
+
+	failCount := &atomic.Int32{}
+	var lastEvent fairway.Event
+
+	deps := TestDeps{
+		HandlerCalled: &atomic.Int32{},
+		LastEvent:     &lastEvent,
+		ShouldFail:    true,
+		FailCount:     failCount,
+	}
+
+	automation, store := setupTestAutomation(t, dcbNs, queueId, deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithMaxAttempts[TestDeps](1),
+		fairway.WithDLQRetry[TestDeps](fairway.DLQRetryPolicy{
+			Cooldown:         10 * time.Millisecond,
+			MaxResurrections: 2,
+			CheckInterval:    10 * time.Millisecond,
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+
+	// Given/When: an event whose handler always fails
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-dlq-retry"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then: requeued twice, then left in the DLQ
+	assert.Eventually(t, func() bool {
+		for entry, err := range automation.ListDLQ() {
+			if err == nil && entry.Resurrections == 2 {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond, "job should end up in DLQ after 2 resurrections")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(3), failCount.Load(), "1 initial attempt + 2 resurrections")
+}
+
+// jobRecordingCommand records the job of each attempt, failing the first one
+type jobRecordingCommand struct {
+	mu   *sync.Mutex
+	jobs *[]fairway.JobInfo
+}
+
+func (c jobRecordingCommand) Run(ctx context.Context, _ fairway.EventReadAppenderExtended, _ TestDeps) error {
+	job, ok := fairway.JobFromContext(ctx)
+	if !ok {
+		return errors.New("no job in context")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.jobs = append(*c.jobs, job)
+	if job.Attempt == 1 {
+		return errors.New("simulated failure")
+	}
+	return nil
+}
+
+func TestAutomation_CommandsSeeTheirJob(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	var mu sync.Mutex
+	var jobs []fairway.JobInfo
+	automation, err := fairway.NewAutomation(store, TestDeps{}, "job-queue", TestAutomationEvent{},
+		func(fairway.Event) fairway.CommandWithEffect[TestDeps] {
+			return jobRecordingCommand{mu: &mu, jobs: &jobs}
+		},
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithRetryBaseWait[TestDeps](10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+	t.Cleanup(automation.Stop)
+
+	// Given
+	before := time.Now()
+	dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-job"}))
+	require.NoError(t, err)
+	recordCtx, positions := dcb.WithAppendedPositions(ctx)
+	require.NoError(t, store.Append(recordCtx, []dcb.Event{dcbEvent}))
+
+	// When - the first attempt fails, the retry succeeds
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(jobs) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Then
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, jobs[0].Attempt)
+	assert.Equal(t, 2, jobs[1].Attempt)
+	for _, job := range jobs {
+		assert.Equal(t, "job-queue", job.QueueId)
+		assert.Equal(t, positions.All()[0], job.Position)
+		assert.False(t, job.FirstEnqueuedAt.Before(before))
+	}
+	assert.Equal(t, jobs[0].FirstEnqueuedAt, jobs[1].FirstEnqueuedAt)
+}
+
 func TestAutomation_NoDuplicateProcessing(t *testing.T) {
 	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
 	queueId := "test-queue"
@@ -404,6 +1004,43 @@ func TestAutomation_LeaseExpiry(t *testing.T) {
 	assert.GreaterOrEqual(t, handlerCalled.Load(), int32(1))
 }
 
+func TestAutomation_CatchUpRateLimit(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{
+		HandlerCalled: handlerCalled,
+		LastEvent:     &lastEvent,
+		LastEventMu:   &sync.Mutex{},
+	}
+
+	// 10 events per second, 10 at once
+	automation, store := setupTestAutomation(t, dcbNs, "rate-limited-queue", deps,
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithCatchUpRateLimit[TestDeps](10),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given - a backlog of 15 events
+	for i := range 15 {
+		dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: fmt.Sprintf("user-%d", i)}))
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+
+	// When
+	require.NoError(t, automation.Start(ctx))
+	t.Cleanup(automation.Stop)
+	time.Sleep(300 * time.Millisecond)
+
+	// Then - the backlog is processed at the limited rate
+	assert.LessOrEqual(t, handlerCalled.Load(), int32(13))
+	require.Eventually(t, func() bool {
+		return handlerCalled.Load() == 15
+	}, 5*time.Second, 20*time.Millisecond, "every event should be processed")
+}
+
 func TestAutomation_MultipleEvents(t *testing.T) {
 	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
 	queueId := "test-queue"
@@ -440,3 +1077,433 @@ func TestAutomation_MultipleEvents(t *testing.T) {
 		return handlerCalled.Load() >= int32(eventCount)
 	}, 3*time.Second, 10*time.Millisecond, "all events should be processed")
 }
+
+func TestAutomationRegistry_StartAllReadyWhenCaughtUp(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent, LastEventMu: &sync.Mutex{}}
+
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	// Given - an event already in the store before startup
+	dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{dcbEvent}))
+
+	registry := &fairway.AutomationRegistry[TestDeps]{}
+	registry.RegisterAutomation(func(store dcb.DcbStore, deps TestDeps) (fairway.Startable, error) {
+		return fairway.NewAutomation(store, deps, "ready-queue", TestAutomationEvent{},
+			func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+				return &TestCommand{Event: ev}
+			},
+			fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		)
+	})
+
+	// When
+	lifecycle, err := registry.StartAll(context.Background(), store, deps)
+	require.NoError(t, err)
+	defer lifecycle.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Then - Ready returns once the cursor passed the existing event
+	require.NoError(t, lifecycle.Ready(ctx))
+	statuses := lifecycle.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "ready-queue", statuses[0].QueueId)
+	assert.True(t, statuses[0].Running)
+	assert.True(t, statuses[0].CaughtUp)
+
+	// Then - the cursor tells which process moved it past the event
+	cursor := statuses[0].Cursor
+	require.NotNil(t, cursor)
+	require.NotNil(t, cursor.Position)
+	assert.Equal(t, dcbEvent.Type, cursor.LastEventType)
+	host, _ := os.Hostname()
+	assert.Equal(t, host, cursor.Host)
+	assert.WithinDuration(t, time.Now(), cursor.UpdatedAt, 5*time.Second)
+}
+
+func TestAutomationRegistry_ManagesComponentsIndividually(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	handlerCalled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handlerCalled, LastEvent: &lastEvent, LastEventMu: &sync.Mutex{}}
+
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	registry := &fairway.AutomationRegistry[TestDeps]{}
+	for _, queueId := range []string{"queue-a", "queue-b"} {
+		registry.RegisterAutomation(func(store dcb.DcbStore, deps TestDeps) (fairway.Startable, error) {
+			return fairway.NewAutomation(store, deps, queueId, TestAutomationEvent{},
+				func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+					return &TestCommand{Event: ev}
+				},
+				fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+			)
+		})
+	}
+
+	// When - only one automation is started
+	lifecycle, err := registry.Start(context.Background(), store, deps, "queue-a")
+	require.NoError(t, err)
+	defer lifecycle.Stop()
+
+	// Then
+	assert.Equal(t, []string{"queue-a"}, lifecycle.List())
+	_, found := lifecycle.Get("queue-b")
+	assert.False(t, found)
+
+	// When - it is stopped, then an event is appended
+	require.NoError(t, lifecycle.StopComponent("queue-a"))
+	dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{dcbEvent}))
+
+	// Then - the event waits
+	assert.Never(t, func() bool { return handlerCalled.Load() > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+	assert.False(t, lifecycle.Status()[0].Running)
+
+	// When - it is started again
+	require.NoError(t, lifecycle.StartComponent(context.Background(), "queue-a"))
+
+	// Then - the event is processed by the new instance
+	assert.Eventually(t, func() bool { return handlerCalled.Load() == 1 }, 3*time.Second, 10*time.Millisecond)
+	assert.True(t, lifecycle.Status()[0].Running)
+	assert.ErrorIs(t, lifecycle.StopComponent("queue-b"), fairway.ErrUnknownComponent)
+}
+
+// startErrorComponent reports an error while starting, through its error handlers
+type startErrorComponent struct {
+	handlers []fairway.ErrorHandler
+}
+
+func (c *startErrorComponent) QueueId() string { return "start-error" }
+func (c *startErrorComponent) Start(context.Context) error {
+	for _, h := range c.handlers {
+		h(errors.New("degraded start"))
+	}
+	return nil
+}
+func (c *startErrorComponent) Stop()                          {}
+func (c *startErrorComponent) Wait() error                    { return nil }
+func (c *startErrorComponent) OnError(h fairway.ErrorHandler) { c.handlers = append(c.handlers, h) }
+func (c *startErrorComponent) ErrorStats() fairway.ErrorStats { return fairway.ErrorStats{} }
+
+func TestAutomationRegistry_ComponentsCanReportErrorsWhileRestarting(t *testing.T) {
+	// Given
+	registry := &fairway.AutomationRegistry[TestDeps]{}
+	registry.RegisterAutomation(func(dcb.DcbStore, TestDeps) (fairway.Startable, error) {
+		return &startErrorComponent{}, nil
+	})
+	lifecycle, err := registry.StartAll(context.Background(), dcb.SetupTestStore(t), TestDeps{})
+	require.NoError(t, err)
+	defer lifecycle.Stop()
+	require.NoError(t, lifecycle.StopComponent("start-error"))
+
+	// When
+	restarted := make(chan error, 1)
+	go func() { restarted <- lifecycle.StartComponent(context.Background(), "start-error") }()
+
+	// Then
+	select {
+	case err := <-restarted:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("StartComponent deadlocked")
+	}
+	assert.ErrorContains(t, lifecycle.Status()[0].LastError, "degraded start")
+}
+
+func TestAutomationRegistry_ErrorsReportedAfterStopAreDropped(t *testing.T) {
+	// Given - a stopped lifecycle
+	component := &startErrorComponent{}
+	registry := &fairway.AutomationRegistry[TestDeps]{}
+	registry.RegisterAutomation(func(dcb.DcbStore, TestDeps) (fairway.Startable, error) {
+		return component, nil
+	})
+	lifecycle, err := registry.StartAll(context.Background(), dcb.SetupTestStore(t), TestDeps{})
+	require.NoError(t, err)
+	reportedWhileRunning := lifecycle.ErrorStats()
+	lifecycle.Stop()
+
+	// When - the component reports a late error
+	assert.NotPanics(t, func() { component.handlers[0](errors.New("late")) })
+
+	// Then
+	stats := lifecycle.ErrorStats()
+	assert.Equal(t, reportedWhileRunning.Dropped+1, stats.Dropped)
+	var unread []error
+	for err := range lifecycle.Err() {
+		unread = append(unread, err)
+	}
+	require.Len(t, unread, 1)
+	assert.ErrorContains(t, unread[0], "degraded start")
+}
+
+func TestAutomationRegistry_StartUnknownQueueId(t *testing.T) {
+	// Given
+	registry := &fairway.AutomationRegistry[TestDeps]{}
+
+	// When
+	_, err := registry.Start(context.Background(), dcb.SetupTestStore(t), TestDeps{}, "missing")
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrUnknownComponent)
+}
+
+// TestTranslatedEvent is what the anti-corruption layer appends to the target context
+type TestTranslatedEvent struct {
+	UserID string
+}
+
+func (e TestTranslatedEvent) Tags() []string {
+	return []string{"member:" + e.UserID}
+}
+
+// translateCommand appends the translated trigger event, unless it already did (at-least-once delivery)
+type translateCommand struct {
+	UserID string
+}
+
+func (c translateCommand) Run(ctx context.Context, ra fairway.EventReadAppenderExtended, _ TestDeps) error {
+	alreadyTranslated := false
+	if err := ra.ReadEvents(ctx, fairway.QueryItems(
+		fairway.NewQueryItem().Types(TestTranslatedEvent{}).Tags("member:"+c.UserID),
+	), func(fairway.Event) bool {
+		alreadyTranslated = true
+		return false
+	}); err != nil {
+		return err
+	}
+	if alreadyTranslated {
+		return nil
+	}
+	return ra.AppendEvents(ctx, fairway.NewEvent(TestTranslatedEvent{UserID: c.UserID}))
+}
+
+func TestAutomation_TargetStoreReceivesCommandEvents(t *testing.T) {
+	sourceNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	targetNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+
+	db := fdb.MustOpenDefault()
+	source := dcb.NewDcbStore(db, sourceNs)
+	target := dcb.NewDcbStore(db, targetNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			for _, ns := range []string{sourceNs, targetNs} {
+				tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(ns), End: fdb.Key(ns + "\xff")})
+			}
+			return nil, nil
+		})
+	})
+
+	automation, err := fairway.NewAutomation(source, TestDeps{}, "acl-queue", TestAutomationEvent{},
+		func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+			return translateCommand{UserID: ev.Data.(TestAutomationEvent).UserID}
+		},
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithTargetStore[TestDeps](target),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+	defer automation.Stop()
+
+	// Given/When: an event in the source context
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-acl"}))
+	require.NoError(t, source.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then: the translated event lands in the target context only
+	assert.Eventually(t, func() bool {
+		return len(dcb.CollectEvents(t, target.ReadAll(ctx))) == 1
+	}, 3*time.Second, 10*time.Millisecond, "translated event should be appended to the target store")
+	sourceEvents := dcb.CollectEvents(t, source.ReadAll(ctx))
+	require.Len(t, sourceEvents, 1)
+	assert.Equal(t, "TestAutomationEvent", sourceEvents[0].Type)
+}
+
+func TestAutomation_QueryTriggers(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	// Given - an automation on a vip's events, and on any event of a member (whatever its type)
+	var mu sync.Mutex
+	var triggers []any
+	automation, err := fairway.NewQueryAutomation(store, TestDeps{}, "query-queue",
+		fairway.QueryItems(
+			fairway.NewQueryItem().Types(TestAutomationEvent{}).Tags("user:vip"),
+			fairway.NewQueryItem().Tags("member:m1"),
+		),
+		func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+			mu.Lock()
+			defer mu.Unlock()
+			triggers = append(triggers, ev.Data)
+			return nil
+		},
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, automation.Start(ctx))
+	defer automation.Stop()
+
+	// When
+	for _, data := range []any{
+		TestAutomationEvent{UserID: "regular"},
+		TestAutomationEvent{UserID: "vip"},
+		TestTranslatedEvent{UserID: "m1"},
+	} {
+		dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(data))
+		require.NoError(t, err)
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+
+	// Then - only the matching events triggered it, in order
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(triggers) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	caughtUp, err := automation.CaughtUp()
+	require.NoError(t, err)
+	assert.True(t, caughtUp)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, TestAutomationEvent{UserID: "vip"}, triggers[0])
+	unknown, ok := triggers[1].(fairway.UnknownEvent)
+	require.True(t, ok, "events of types absent from the query are delivered as UnknownEvent")
+	assert.Equal(t, "TestTranslatedEvent", unknown.Type)
+}
+
+func TestNewQueryAutomation_RejectsInvalidQueries(t *testing.T) {
+	store := dcb.NewDcbStore(fdb.MustOpenDefault(), "unused")
+	handler := func(fairway.Event) fairway.CommandWithEffect[TestDeps] { return nil }
+
+	_, err := fairway.NewQueryAutomation(store, TestDeps{}, "q", fairway.QueryItems(), handler)
+	assert.Error(t, err)
+
+	_, err = fairway.NewQueryAutomation(store, TestDeps{}, "q", fairway.QueryItems(fairway.NewQueryItem()), handler)
+	assert.ErrorIs(t, err, dcb.ErrInvalidQuery)
+}
+
+func TestAutomation_ErrorHandlerReceivesBackgroundErrors(t *testing.T) {
+	// Given - a handler panicking on every event
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	reported := make(chan error, 10)
+	automation, err := fairway.NewAutomation(store, TestDeps{}, "panicking", TestAutomationEvent{},
+		func(fairway.Event) fairway.CommandWithEffect[TestDeps] { panic("handler bug") },
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithErrorHandler[TestDeps](func(err error) { reported <- err }),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, automation.Start(ctx))
+
+	// When
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then - the panic reaches the handler, not a channel nobody reads
+	select {
+	case err := <-reported:
+		assert.ErrorContains(t, err, "worker panic: handler bug")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+	automation.Stop()
+	assert.NoError(t, automation.Wait())
+	assert.Equal(t, uint64(1), automation.ErrorStats().Reported)
+	_, open := <-automation.Errors()
+	assert.False(t, open, "no error channel without WithErrorChannel")
+}
+
+// labelRecordingCommand records the pprof labels of its context
+type labelRecordingCommand struct {
+	labels chan<- map[string]string
+}
+
+func (c labelRecordingCommand) Run(ctx context.Context, _ fairway.EventReadAppenderExtended, _ TestDeps) error {
+	labels := map[string]string{}
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	c.labels <- labels
+	return nil
+}
+
+func TestAutomation_CommandsCarryProfileLabels(t *testing.T) {
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	// Given
+	labels := make(chan map[string]string, 1)
+	automation, err := fairway.NewAutomation(store, TestDeps{}, "profiled-queue", TestAutomationEvent{},
+		func(fairway.Event) fairway.CommandWithEffect[TestDeps] { return labelRecordingCommand{labels: labels} },
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, automation.Start(ctx))
+	t.Cleanup(automation.Stop)
+
+	// When
+	dcbEvent, _ := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then
+	select {
+	case got := <-labels:
+		assert.Equal(t, map[string]string{
+			fairway.ProfileLabelComponent: "automation",
+			fairway.ProfileLabelQueueId:   "profiled-queue",
+		}, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command not run")
+	}
+}
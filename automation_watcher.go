@@ -1,6 +1,7 @@
 package fairway
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 
@@ -10,36 +11,86 @@ import (
 	"github.com/err0r500/fairway/dcb"
 )
 
-// runWatcher polls for new events and enqueues them
+// runWatcher polls for new events and enqueues them.
+// In a WatchGroup, it only polls on ticks while it may be behind, and when the group reports new events.
 func (a *Automation[Deps]) runWatcher() {
 	defer a.wg.Done()
+	defer a.recoverLoop("watcher")
+	if a.unwatch != nil {
+		defer a.unwatch()
+	}
 
+	behind := true
+	poll := func() {
+		_, more, err := a.pollAndEnqueue(a.ctx)
+		if err != nil {
+			a.errs.report(fmt.Errorf("poll and enqueue: %w", err))
+		}
+		behind = more || err != nil
+	}
 	for {
 		select {
 		case <-a.ctx.Done():
 			return
-		case <-a.pollTicker.C:
-			if err := a.pollAndEnqueue(); err != nil {
-				select {
-				case a.errCh <- fmt.Errorf("poll and enqueue: %w", err):
-				default:
-				}
+		case <-a.wake: // never ready without group
+			poll()
+		case <-a.pollTicker.Chan():
+			if a.wake == nil || behind {
+				poll()
 			}
 		}
 	}
 }
 
-// pollAndEnqueue reads new events from type index and enqueues them
-func (a *Automation[Deps]) pollAndEnqueue() error {
+// maxEnqueueTxBytes bounds what a watcher transaction writes, well below FDB's 10MB transaction limit
+// (FDB recommends staying under 1MB): larger batches are enqueued over several polls
+const maxEnqueueTxBytes = 1 << 20
+
+// enqueueBatchLimit is the number of events a watcher transaction enqueues at most: BatchSize,
+// bounded by maxEnqueueTxBytes
+func (a *Automation[Deps]) enqueueBatchLimit() int {
+	key := len(a.jobKey(dcb.Versionstamp{}))
+	// per job: the key and value written, and the conflict range of the existing job lookup
+	jobSize := 3*key + jobValueSize + 1 + 8
+	return max(1, min(a.config.BatchSize, maxEnqueueTxBytes/jobSize))
+}
+
+// pollAndEnqueue reads new events and enqueues them, recording the poll in the automation's metrics.
+// It returns how many it enqueued, and whether more events may be waiting: the poll filled its batch,
+// or was skipped because the catch-up rate limit is reached.
+func (a *Automation[Deps]) pollAndEnqueue(ctx context.Context) (int, bool, error) {
+	catchUp := a.catchUp.Load()
+	limit := catchUp.allowance(a.enqueueBatchLimit())
+	if limit == 0 {
+		return 0, true, nil
+	}
+
+	start := a.clock.Now()
+	var enqueued int
+	var err error
+	if a.query != nil {
+		enqueued, err = a.pollQueryAndEnqueue(ctx, limit)
+	} else {
+		enqueued, err = a.pollTypeAndEnqueue(limit)
+	}
+	catchUp.spend(enqueued)
+
+	a.metrics.RecordEnqueueDuration(a.queueId, a.clock.Now().Sub(start), err == nil)
+	if enqueued > 0 {
+		a.metrics.RecordEnqueuedEvents(a.queueId, enqueued)
+	}
+	return enqueued, enqueued >= limit, err
+}
+
+// pollTypeAndEnqueue reads up to limit new events from type index and enqueues them.
+// The jobs and the cursor are written in the same transaction: the cursor never moves past an event not enqueued.
+func (a *Automation[Deps]) pollTypeAndEnqueue(limit int) (int, error) {
+	enqueued := 0
 	_, err := a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		enqueued = 0
+
 		// 1. Read cursor
-		cursorValue := tr.Get(a.cursorKey).MustGet()
-		var cursor *dcb.Versionstamp
-		if cursorValue != nil && len(cursorValue) == 12 {
-			var vs dcb.Versionstamp
-			copy(vs[:], cursorValue)
-			cursor = &vs
-		}
+		cursor := decodeCursor(tr.Get(a.cursorKey).MustGet())
 
 		// 2. Build range for type index
 		var r fdb.Range
@@ -54,7 +105,7 @@ func (a *Automation[Deps]) pollAndEnqueue() error {
 		}
 
 		// 3. Read from type index
-		kvs := tr.GetRange(r, fdb.RangeOptions{Limit: a.config.BatchSize}).GetSliceOrPanic()
+		kvs := tr.GetRange(r, fdb.RangeOptions{Limit: limit}).GetSliceOrPanic()
 
 		if len(kvs) == 0 {
 			return nil, nil
@@ -72,16 +123,140 @@ func (a *Automation[Deps]) pollAndEnqueue() error {
 				return nil, err
 			}
 			lastVS = vs
+			enqueued++
 		}
 
 		// 5. Update cursor (same tx = atomic)
 		if lastVS != (dcb.Versionstamp{}) {
-			tr.Set(a.cursorKey, lastVS[:])
+			setCursorInTx(tr, a.cursorKey, a.cursorMetaKey, lastVS, a.eventType, a.clock.Now())
 		}
 
 		return nil, nil
 	})
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return enqueued, nil
+}
+
+// pollQueryAndEnqueue reads up to limit next events matching the query from the store and enqueues them.
+// Versionstamps only grow, so the events read after the cursor are a prefix of those still to come.
+// The enqueue transaction checks the cursor didn't move meanwhile (e.g. by the watcher of another process),
+// and writes the jobs with the cursor.
+func (a *Automation[Deps]) pollQueryAndEnqueue(ctx context.Context, limit int) (int, error) {
+	cursorValue, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(a.cursorKey).Get()
+	})
+	if err != nil {
+		return 0, err
+	}
+	cursor := decodeCursor(cursorValue.([]byte))
+
+	var positions []dcb.Versionstamp
+	var lastType string
+	for event, err := range a.store.Read(ctx, *a.query, &dcb.ReadOptions{After: cursor, Limit: limit}) {
+		if err != nil {
+			return 0, fmt.Errorf("read query: %w", err)
+		}
+		positions = append(positions, event.Position)
+		lastType = event.Type
+	}
+	if len(positions) == 0 {
+		return 0, nil
+	}
+
+	enqueued := 0
+	_, err = a.db.Transact(func(tr fdb.Transaction) (any, error) {
+		enqueued = 0
+		current := decodeCursor(tr.Get(a.cursorKey).MustGet())
+		if !sameCursor(current, cursor) {
+			return nil, nil // already enqueued
+		}
+		for _, vs := range positions {
+			if err := a.enqueueInTx(tr, vs); err != nil {
+				return nil, err
+			}
+		}
+		setCursorInTx(tr, a.cursorKey, a.cursorMetaKey, positions[len(positions)-1], lastType, a.clock.Now())
+		enqueued = len(positions)
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return enqueued, nil
+}
+
+// Cursor returns the automation's cursor: the last event enqueued, when and by which host
+func (a *Automation[Deps]) Cursor() (CursorInfo, error) {
+	return readCursorInfo(a.db, a.cursorKey, a.cursorMetaKey)
+}
+
+// decodeCursor decodes the value of the cursor key (nil = nothing enqueued yet)
+func decodeCursor(value []byte) *dcb.Versionstamp {
+	if len(value) != 12 {
+		return nil
+	}
+	var vs dcb.Versionstamp
+	copy(vs[:], value)
+	return &vs
+}
+
+// sameCursor reports whether two decoded cursors are equal
+func sameCursor(a, b *dcb.Versionstamp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// CaughtUp reports whether the cursor has reached the last event of the watched type (or query)
+func (a *Automation[Deps]) CaughtUp() (bool, error) {
+	if a.query != nil {
+		return a.queryCaughtUp()
+	}
+
+	caughtUp, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		kvs := tr.GetRange(a.typeIndex, fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceOrPanic()
+		if len(kvs) == 0 {
+			return true, nil // nothing to process
+		}
+		last := extractVersionstampFromTypeIndex(a.typeIndex, kvs[0].Key)
+
+		cursor := decodeCursor(tr.Get(a.cursorKey).MustGet())
+		if cursor == nil {
+			return false, nil
+		}
+		return cursor.Compare(last) >= 0, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return caughtUp.(bool), nil
+}
+
+// queryCaughtUp reports whether the cursor has reached the last event matching the query.
+// The last event is read before the cursor, which only moves forward.
+func (a *Automation[Deps]) queryCaughtUp() (bool, error) {
+	var last *dcb.Versionstamp
+	for event, err := range a.store.Read(context.Background(), *a.query, &dcb.ReadOptions{Reverse: true, Limit: 1}) {
+		if err != nil {
+			return false, err
+		}
+		last = &event.Position
+	}
+	if last == nil {
+		return true, nil // nothing to process
+	}
+
+	cursorValue, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(a.cursorKey).Get()
+	})
+	if err != nil {
+		return false, err
+	}
+	cursor := decodeCursor(cursorValue.([]byte))
+	return cursor != nil && cursor.Compare(*last) >= 0, nil
 }
 
 // rangeAfterVersionstamp creates an FDB range that starts after the given versionstamp
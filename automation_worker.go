@@ -1,21 +1,61 @@
 package fairway
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"github.com/err0r500/fairway/dcb"
 )
 
-// runWorker is the main worker loop
-func (a *Automation[Deps]) runWorker() {
+// JobInfo describes the automation job a command runs for, see JobFromContext
+type JobInfo struct {
+	QueueId  string
+	Position dcb.Versionstamp // position of the event that triggered the job
+	// Attempt is the 1-based number of this attempt, restarting when the job is requeued from the DLQ
+	// (Attempt > 1 is a retry)
+	Attempt       int
+	Resurrections int // times the job was requeued from the DLQ
+	// FirstEnqueuedAt is when the event was first enqueued, kept across retries and DLQ requeues
+	// (zero for jobs enqueued before it was tracked)
+	FirstEnqueuedAt time.Time
+}
+
+type jobInfoKey struct{}
+
+// JobFromContext returns the automation job the current command runs for,
+// so commands can adjust on retries (e.g. skip non-critical side effects)
+func JobFromContext(ctx context.Context) (JobInfo, bool) {
+	job, ok := ctx.Value(jobInfoKey{}).(JobInfo)
+	return job, ok
+}
+
+// info returns the job description exposed to commands
+func (j *Job) info(queueId string) JobInfo {
+	info := JobInfo{
+		QueueId:       queueId,
+		Position:      j.EventVS,
+		Attempt:       int(j.Attempts) + 1,
+		Resurrections: int(j.Resurrections),
+	}
+	if j.FirstEnqueuedNs != 0 {
+		info.FirstEnqueuedAt = time.Unix(0, j.FirstEnqueuedNs)
+	}
+	return info
+}
+
+// runWorker is the main worker loop, until ctx (the automation's, or the worker's when scaled down) is done.
+// The job in progress runs to completion.
+func (a *Automation[Deps]) runWorker(ctx context.Context) {
 	defer a.wg.Done()
+	defer a.recoverLoop("worker")
 
 	for {
 		select {
-		case <-a.ctx.Done():
+		case <-ctx.Done():
 			return
 		default:
 		}
@@ -23,17 +63,14 @@ func (a *Automation[Deps]) runWorker() {
 		job, err := a.dequeue()
 		if err == ErrNoJobs {
 			select {
-			case <-a.ctx.Done():
+			case <-ctx.Done():
 				return
-			case <-a.pollTicker.C:
+			case <-a.pollTicker.Chan():
 				continue
 			}
 		}
 		if err != nil {
-			select {
-			case a.errCh <- fmt.Errorf("dequeue: %w", err):
-			default:
-			}
+			a.errs.report(fmt.Errorf("dequeue: %w", err))
 			continue
 		}
 
@@ -41,64 +78,73 @@ func (a *Automation[Deps]) runWorker() {
 	}
 }
 
+// recoverLoop turns a panicking loop into a reported error and stops the automation,
+// so that Wait returns and a Supervisor can restart it
+func (a *Automation[Deps]) recoverLoop(loop string) {
+	if r := recover(); r != nil {
+		a.errs.report(fmt.Errorf("%s panic: %v", loop, r))
+		a.cancel()
+	}
+}
+
 // processJob handles a single job
 func (a *Automation[Deps]) processJob(job *Job) {
+	if processErr := a.runJob(a.ctx, job.info(a.queueId)); processErr != nil {
+		a.handleJobFailure(job, processErr)
+		return
+	}
+
+	// Success - delete the job
+	if err := a.deleteJob(job); err != nil {
+		a.errs.report(fmt.Errorf("delete job after success: %w", err))
+	}
+}
+
+// runJob fetches the event of the job and runs the command the handler returns for it (nothing if nil)
+func (a *Automation[Deps]) runJob(ctx context.Context, job JobInfo) error {
 	// Fetch event from dcb using versionstamp
-	storedEvent, err := a.fetchEvent(job.EventVS)
+	storedEvent, err := a.fetchEvent(ctx, job.Position)
 	if err != nil {
-		a.handleJobFailure(job, fmt.Errorf("fetch event: %w", err))
-		return
+		return fmt.Errorf("fetch event: %w", err)
 	}
 
 	// Deserialize event using registry
-	event, err := a.eventRegistry.deserialize(storedEvent.Event)
+	event, ok, err := a.eventRegistry.decode(storedEvent.Event)
 	if err != nil {
-		a.handleJobFailure(job, fmt.Errorf("deserialize: %w", err))
-		return
+		return fmt.Errorf("deserialize: %w", err)
 	}
+	if !ok {
+		return nil
+	}
+	event.CommittedAt = storedEvent.CommittedAt
+	event.Position = storedEvent.Position
+	event.Superseded = storedEvent.Superseded
+	event.Sequence = storedEvent.Sequence
 
 	// Call handler to get command
 	cmd := a.handler(event)
 	if cmd == nil {
-		// Handler returned nil, just delete the job
-		if err := a.deleteJob(job); err != nil {
-			select {
-			case a.errCh <- fmt.Errorf("delete job: %w", err):
-			default:
-			}
-		}
-		return
+		return nil
 	}
 
-	// Execute command
-	processErr := a.runner.RunWithEffect(a.ctx, cmd)
-
-	if processErr != nil {
-		a.handleJobFailure(job, processErr)
-		return
-	}
-
-	// Success - delete the job
-	if err := a.deleteJob(job); err != nil {
-		select {
-		case a.errCh <- fmt.Errorf("delete job after success: %w", err):
-		default:
-		}
-	}
+	// Execute command, exposing the trigger position to effect helpers and the job to the command
+	ctx = context.WithValue(withTriggerPosition(ctx, job.Position), jobInfoKey{}, job)
+	return a.runner.RunWithEffect(ctx, cmd)
 }
 
 // handleJobFailure handles a failed job processing attempt
 func (a *Automation[Deps]) handleJobFailure(job *Job, processErr error) {
 	if err := a.retryJob(job, processErr); err != nil {
-		select {
-		case a.errCh <- fmt.Errorf("retry job: %w (original: %w)", err, processErr):
-		default:
-		}
+		a.errs.report(fmt.Errorf("retry job: %w (original: %w)", err, processErr))
 	}
 }
 
 // fetchEvent retrieves an event from dcb by versionstamp
-func (a *Automation[Deps]) fetchEvent(vs dcb.Versionstamp) (dcb.StoredEvent, error) {
+func (a *Automation[Deps]) fetchEvent(ctx context.Context, vs dcb.Versionstamp) (dcb.StoredEvent, error) {
+	if a.fetcher != nil {
+		return a.fetcher.FetchEvent(ctx, vs)
+	}
+
 	var result dcb.StoredEvent
 
 	_, err := a.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
@@ -115,43 +161,9 @@ func (a *Automation[Deps]) fetchEvent(vs dcb.Versionstamp) (dcb.StoredEvent, err
 			return nil, fmt.Errorf("event not found at versionstamp %x", vs[:])
 		}
 
-		// Decode event (type, tags, data)
-		eventTuple, err := tuple.Unpack(encodedValue)
-		if err != nil {
-			return nil, fmt.Errorf("unpack event: %w", err)
-		}
-
-		if len(eventTuple) != 3 {
-			return nil, fmt.Errorf("expected 3-tuple, got %d elements", len(eventTuple))
-		}
-
-		eventType, ok := eventTuple[0].(string)
-		if !ok {
-			return nil, fmt.Errorf("type field is %T, expected string", eventTuple[0])
-		}
-
-		var tags []string
-		if eventTuple[1] != nil {
-			tagsTuple, ok := eventTuple[1].(tuple.Tuple)
-			if !ok {
-				return nil, fmt.Errorf("tags field is %T, expected tuple", eventTuple[1])
-			}
-			tags = make([]string, len(tagsTuple))
-			for i, t := range tagsTuple {
-				tags[i] = t.(string)
-			}
-		}
-
-		eventData, ok := eventTuple[2].([]byte)
-		if !ok {
-			return nil, fmt.Errorf("data field is %T, expected []byte", eventTuple[2])
-		}
-
-		result = dcb.StoredEvent{
-			Event:    dcb.Event{Type: eventType, Tags: tags, Data: eventData},
-			Position: vs,
-		}
-		return nil, nil
+		var err error
+		result, err = dcb.DecodeStoredEvent(vs, encodedValue)
+		return nil, err
 	})
 
 	return result, err
@@ -0,0 +1,51 @@
+package fairway
+
+import "time"
+
+// catchUpLimiter is a token bucket bounding the events a polling loop processes per second,
+// so that catching up on a backlog doesn't saturate FDB or downstream systems.
+// It holds the events of one window at most: a loop idle for a while doesn't burst through the backlog.
+// A nil limiter allows everything. It is used by a single goroutine.
+type catchUpLimiter struct {
+	rate   float64 // events per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newCatchUpLimiter returns a limiter of eventsPerSecond allowing the events of window at once, on the time of now,
+// nil when eventsPerSecond <= 0
+func newCatchUpLimiter(eventsPerSecond float64, window time.Duration, now func() time.Time) *catchUpLimiter {
+	if eventsPerSecond <= 0 {
+		return nil
+	}
+	burst := max(1, eventsPerSecond*window.Seconds())
+	return &catchUpLimiter{rate: eventsPerSecond, burst: burst, tokens: burst, last: now(), now: now}
+}
+
+// allowance returns how many of limit events may be processed now, 0 when the loop must wait (see delay)
+func (l *catchUpLimiter) allowance(limit int) int {
+	if l == nil {
+		return limit
+	}
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	return max(0, min(limit, int(l.tokens)))
+}
+
+// spend takes n processed events from the bucket
+func (l *catchUpLimiter) spend(n int) {
+	if l != nil {
+		l.tokens -= float64(n)
+	}
+}
+
+// delay returns how long until an event is allowed
+func (l *catchUpLimiter) delay() time.Duration {
+	if l == nil || l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
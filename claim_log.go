@@ -0,0 +1,183 @@
+package fairway
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/google/uuid"
+)
+
+// ErrClaimLost is returned when an execution records the outcome of a key it no longer holds:
+// its claim expired and another execution claimed the key (see WithEffectIntentTTL and WithIdempotencyClaimTTL)
+var ErrClaimLost = errors.New("claim taken over by another execution")
+
+// Claim states
+const (
+	claimPending byte = 0
+	claimDone    byte = 1
+)
+
+// Claim value format:
+// [state:1][at_ns:8][token:16]
+// Values written before claims carried a token (9 bytes) hold the zero token.
+const (
+	claimValueSize       = 1 + 8 + 16
+	legacyClaimValueSize = 1 + 8
+)
+
+// claimLog records keys claimed by an execution, then done, in FDB: the protocol of EffectLog and IdempotencyStore.
+// Each claim carries the token of its execution, so an execution whose claim was taken over after the TTL
+// can't complete or release the claim of the execution that took it.
+type claimLog struct {
+	db   fdb.Database
+	dir  subspace.Subspace
+	ttl  time.Duration // 0 = claims never expire
+	held error         // returned, wrapped, while another execution holds the key
+	now  func() time.Time
+}
+
+// claimValue is a decoded claim
+type claimValue struct {
+	state byte
+	at    time.Time // of the claim, or of its completion
+	token uuid.UUID
+}
+
+func decodeClaim(value []byte) (claimValue, bool) {
+	if len(value) != claimValueSize && len(value) != legacyClaimValueSize {
+		return claimValue{}, false
+	}
+	c := claimValue{state: value[0], at: time.Unix(0, int64(binary.BigEndian.Uint64(value[1:9])))}
+	copy(c.token[:], value[9:])
+	return c, true
+}
+
+func encodeClaim(state byte, at time.Time, token uuid.UUID) []byte {
+	buf := make([]byte, claimValueSize)
+	buf[0] = state
+	binary.BigEndian.PutUint64(buf[1:9], uint64(at.UnixNano()))
+	copy(buf[9:], token[:])
+	return buf
+}
+
+// claim claims key for the execution of token, returns false if the key is done
+// and l.held while another execution holds an unexpired claim
+func (l *claimLog) claim(key fdb.Key, token uuid.UUID) (bool, error) {
+	claimed, err := l.db.Transact(func(tr fdb.Transaction) (any, error) {
+		now := l.now()
+		if current, ok := decodeClaim(tr.Get(key).MustGet()); ok {
+			if current.state == claimDone {
+				return false, nil
+			}
+			if l.ttl == 0 || now.Sub(current.at) < l.ttl {
+				return false, fmt.Errorf("%w (claimed at %s)", l.held, current.at.Format(time.RFC3339Nano))
+			}
+		}
+		tr.Set(key, encodeClaim(claimPending, now, token))
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed.(bool), nil
+}
+
+// doneInTx marks key done within tr, if token holds it (or already completed it): ErrClaimLost otherwise
+func (l *claimLog) doneInTx(tr fdb.Transaction, key fdb.Key, token uuid.UUID) error {
+	current, ok := decodeClaim(tr.Get(key).MustGet())
+	if !ok || current.token != token {
+		return ErrClaimLost
+	}
+	if current.state == claimPending {
+		tr.Set(key, encodeClaim(claimDone, l.now(), token))
+	}
+	return nil
+}
+
+// done marks key done, if token holds it
+func (l *claimLog) done(key fdb.Key, token uuid.UUID) error {
+	_, err := l.db.Transact(func(tr fdb.Transaction) (any, error) {
+		return nil, l.doneInTx(tr, key, token)
+	})
+	return err
+}
+
+// release clears the claim of a failed execution, if token holds it: ErrClaimLost otherwise
+func (l *claimLog) release(key fdb.Key, token uuid.UUID) error {
+	_, err := l.db.Transact(func(tr fdb.Transaction) (any, error) {
+		current, ok := decodeClaim(tr.Get(key).MustGet())
+		if !ok || current.token != token || current.state != claimPending {
+			return nil, ErrClaimLost
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// isDone reports whether key is done
+func (l *claimLog) isDone(key fdb.Key) (bool, error) {
+	value, err := l.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(key).Get()
+	})
+	if err != nil {
+		return false, err
+	}
+	current, ok := decodeClaim(value.([]byte))
+	return ok && current.state == claimDone, nil
+}
+
+// purge removes the keys done before the given time, and returns how many it removed
+func (l *claimLog) purge(ctx context.Context, before time.Time) (int, error) {
+	return clearRangeWhere(ctx, l.db, l.dir, func(value []byte) bool {
+		c, ok := decodeClaim(value)
+		return ok && c.state == claimDone && c.at.Before(before)
+	})
+}
+
+// purgeBatchSize bounds the keys scanned per transaction by clearRangeWhere
+const purgeBatchSize = 10_000
+
+// clearRangeWhere clears the keys of dir whose value matches, a batch per transaction, and returns how many it cleared
+func clearRangeWhere(ctx context.Context, db fdb.Database, dir subspace.Subspace, match func(value []byte) bool) (int, error) {
+	cleared := 0
+	begin, end := dir.FDBRangeKeys()
+	for {
+		if err := ctx.Err(); err != nil {
+			return cleared, err
+		}
+		var scanned []fdb.KeyValue
+		res, err := db.Transact(func(tr fdb.Transaction) (any, error) {
+			kvs, err := tr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{
+				Limit: purgeBatchSize,
+				Mode:  fdb.StreamingModeWantAll,
+			}).GetSliceWithError()
+			if err != nil {
+				return nil, err
+			}
+			scanned = kvs
+			n := 0
+			for _, kv := range kvs {
+				if match(kv.Value) {
+					tr.Clear(kv.Key)
+					n++
+				}
+			}
+			return n, nil
+		})
+		if err != nil {
+			return cleared, err
+		}
+		cleared += res.(int)
+		if len(scanned) < purgeBatchSize {
+			return cleared, nil
+		}
+		begin = append(slices.Clone(scanned[len(scanned)-1].Key), 0x00)
+	}
+}
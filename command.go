@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/avast/retry-go/v4"
 	"github.com/err0r500/fairway/dcb"
+	"golang.org/x/sync/errgroup"
 )
 
 // PURE COMMANDS
@@ -26,12 +30,21 @@ type RetryableCommand interface {
 // CommandRunner runs pure Commands
 type CommandRunner interface {
 	RunPure(ctx context.Context, command Command) error
+	// RunPureIdempotent runs the command once per key (see WithIdempotencyStore):
+	// a duplicate of an applied command returns nil without running it.
+	// The key is stamped on the events the command appends (see WithIdempotencyKey).
+	RunPureIdempotent(ctx context.Context, key string, command Command) error
+	// RunBatch runs the commands with RunPure concurrently (see WithBatchParallelism), for imports and
+	// admin scripts: each command is applied on its own, and the result reports the error of each.
+	RunBatch(ctx context.Context, commands []Command, opts ...BatchOption) BatchResult
 }
 
 // commandRunner is the concrete implementation of CommandRunner
 type commandRunner struct {
-	store     dcb.DcbStore
-	retryOpts []retry.Option
+	store       dcb.DcbStore
+	retryOpts   []retry.Option
+	classifiers []ErrorClassifier
+	idempotency IdempotencyStore
 }
 
 // CommandRunnerOption configures CommandRunner
@@ -44,22 +57,32 @@ func WithRetryOptions(opts ...retry.Option) CommandRunnerOption {
 	}
 }
 
+// ErrorClassifier reports whether a failed command may succeed when run again (e.g. IsTransientStoreError)
+type ErrorClassifier func(err error) bool
+
+// WithRetryClassifiers also retries the errors accepted by any of the classifiers, besides ErrAppendConditionFailed.
+// They apply to the runner-level retry options (replacing their retry.RetryIf), not to RetryableCommands.
+// Only classify errors of commands that are safe to run again: every attempt runs the whole command.
+func WithRetryClassifiers(classifiers ...ErrorClassifier) CommandRunnerOption {
+	return func(cr *commandRunner) {
+		cr.classifiers = append(cr.classifiers, classifiers...)
+	}
+}
+
+// WithIdempotencyStore sets the store deduplicating RunPureIdempotent calls
+func WithIdempotencyStore(s IdempotencyStore) CommandRunnerOption {
+	return func(cr *commandRunner) {
+		cr.idempotency = s
+	}
+}
+
 // NewCommandRunner creates a command runner
 // By default, retries 3 times with exponential backoff on ErrAppendConditionFailed.
 // Pass WithRetryOptions() to customize or disable (use retry.Attempts(1) for no retry).
 func NewCommandRunner(store dcb.DcbStore, opts ...CommandRunnerOption) CommandRunner {
 	cr := &commandRunner{
-		store: store,
-		retryOpts: []retry.Option{
-			retry.Attempts(4), // initial attempt + 3 retries
-			retry.Delay(10 * time.Millisecond),
-			retry.DelayType(retry.BackOffDelay),
-			retry.MaxDelay(500 * time.Millisecond),
-			retry.RetryIf(func(err error) bool {
-				// Only retry on append condition failed
-				return errors.Is(err, dcb.ErrAppendConditionFailed)
-			}),
-		},
+		store:     store,
+		retryOpts: conditionRetryOptions(4, 10*time.Millisecond, 500*time.Millisecond), // initial attempt + 3 retries
 	}
 	for _, opt := range opts {
 		opt(cr)
@@ -67,13 +90,62 @@ func NewCommandRunner(store dcb.DcbStore, opts ...CommandRunnerOption) CommandRu
 	return cr
 }
 
+// conditionRetryOptions retries with exponential backoff, only on ErrAppendConditionFailed
+func conditionRetryOptions(attempts uint, delay, maxDelay time.Duration) []retry.Option {
+	return []retry.Option{
+		retry.Attempts(attempts),
+		retry.Delay(delay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.MaxDelay(maxDelay),
+		retry.RetryIf(func(err error) bool {
+			// Only retry on append condition failed
+			return errors.Is(err, dcb.ErrAppendConditionFailed)
+		}),
+	}
+}
+
+// transientFDBErrors are the FoundationDB error codes of failures a new transaction may not hit
+// (timeouts, conflicts, throttling). commit_unknown_result (1021) is left out: the append may have committed.
+var transientFDBErrors = map[int]bool{
+	1004: true, // timed_out
+	1007: true, // transaction_too_old
+	1009: true, // future_version
+	1020: true, // not_committed
+	1031: true, // transaction_timed_out
+	1037: true, // process_behind
+	1040: true, // proxy_memory_limit_exceeded
+	1051: true, // batch_transaction_throttled
+	1078: true, // grv_proxy_memory_limit_exceeded
+	1213: true, // tag_throttled
+}
+
+// IsTransientStoreError is an ErrorClassifier accepting the FoundationDB errors of transient failures
+// (timeouts, conflicts, throttling) that surfaced out of the store's own transaction retries
+func IsTransientStoreError(err error) bool {
+	var fdbErr fdb.Error
+	return errors.As(err, &fdbErr) && transientFDBErrors[fdbErr.Code]
+}
+
+// retryIf retries ErrAppendConditionFailed and the errors accepted by a classifier
+func (cr *commandRunner) retryIf(err error) bool {
+	if errors.Is(err, dcb.ErrAppendConditionFailed) {
+		return true
+	}
+	return slices.ContainsFunc(cr.classifiers, func(classify ErrorClassifier) bool {
+		return classify(err)
+	})
+}
+
 // RunPure executes a command with automatic retry on ErrAppendConditionFailed
+// (and the errors of WithRetryClassifiers)
 // Priority: command-level config > runner-level config
 func (cr *commandRunner) RunPure(ctx context.Context, cmd Command) error {
 	// Check if command provides custom retry options
 	opts := cr.retryOpts
 	if retryable, ok := cmd.(RetryableCommand); ok {
 		opts = retryable.RetryOptions()
+	} else if len(cr.classifiers) > 0 {
+		opts = append(slices.Clone(opts), retry.RetryIf(cr.retryIf))
 	}
 
 	return retry.Do(func() error {
@@ -81,6 +153,20 @@ func (cr *commandRunner) RunPure(ctx context.Context, cmd Command) error {
 	}, opts...)
 }
 
+// RunPureIdempotent runs the command with RunPure, once per key.
+// Concurrent duplicates wait for the first execution; a failed execution can be retried with the same key.
+// An empty key runs the command without deduplication.
+func (cr *commandRunner) RunPureIdempotent(ctx context.Context, key string, cmd Command) error {
+	return runIdempotent(ctx, cr.idempotency, key, func(ctx context.Context) error {
+		return cr.RunPure(ctx, cmd)
+	})
+}
+
+// RunBatch runs the commands with RunPure concurrently, with bounded parallelism
+func (cr *commandRunner) RunBatch(ctx context.Context, cmds []Command, opts ...BatchOption) BatchResult {
+	return runBatch(ctx, cmds, cr.RunPure, opts...)
+}
+
 // COMMANDS WITH SIDE EFFECTS
 // CommandWithEffect represents a command that can perform side effects
 // using injected dependencies, while also interacting with the event store
@@ -93,13 +179,17 @@ type CommandWithEffect[Deps any] interface {
 type CommandWithEffectRunner[Deps any] interface {
 	CommandRunner
 	RunWithEffect(ctx context.Context, command CommandWithEffect[Deps]) error
+	// RunWithEffectIdempotent runs the command once per key, like RunPureIdempotent
+	RunWithEffectIdempotent(ctx context.Context, key string, command CommandWithEffect[Deps]) error
 }
 
 // commandWithEffectRunner is the concrete implementation of CommandWithEffectRunner
 type commandWithEffectRunner[Deps any] struct {
-	store     dcb.DcbStore
-	deps      Deps
-	retryOpts []retry.Option
+	store         dcb.DcbStore
+	deps          Deps
+	retryOpts     []retry.Option
+	unknownEvents UnknownEventPolicy
+	idempotency   IdempotencyStore
 }
 
 // CommandWithEffectRunnerOption configures CommandWithEffectRunner
@@ -112,6 +202,20 @@ func WithRetryOptionsForEffect[Deps any](opts ...retry.Option) CommandWithEffect
 	}
 }
 
+// WithUnknownEventPolicyForEffect sets how command reads handle events of types absent from their query
+func WithUnknownEventPolicyForEffect[Deps any](p UnknownEventPolicy) CommandWithEffectRunnerOption[Deps] {
+	return func(cr *commandWithEffectRunner[Deps]) {
+		cr.unknownEvents = p
+	}
+}
+
+// WithIdempotencyStoreForEffect sets the store deduplicating RunPureIdempotent and RunWithEffectIdempotent calls
+func WithIdempotencyStoreForEffect[Deps any](s IdempotencyStore) CommandWithEffectRunnerOption[Deps] {
+	return func(cr *commandWithEffectRunner[Deps]) {
+		cr.idempotency = s
+	}
+}
+
 // NewCommandWithEffectRunner creates a command runner with dependency injection
 // By default, NO RETRY (side effects may not be idempotent).
 // Use WithRetryOptionsForEffect() to enable retry when safe.
@@ -143,6 +247,25 @@ func (cr *commandWithEffectRunner[Deps]) RunPure(ctx context.Context, cmd Comman
 	}, opts...)
 }
 
+// RunPureIdempotent runs the command with RunPure, once per key
+func (cr *commandWithEffectRunner[Deps]) RunPureIdempotent(ctx context.Context, key string, cmd Command) error {
+	return runIdempotent(ctx, cr.idempotency, key, func(ctx context.Context) error {
+		return cr.RunPure(ctx, cmd)
+	})
+}
+
+// RunBatch runs the commands with RunPure concurrently, with bounded parallelism
+func (cr *commandWithEffectRunner[Deps]) RunBatch(ctx context.Context, cmds []Command, opts ...BatchOption) BatchResult {
+	return runBatch(ctx, cmds, cr.RunPure, opts...)
+}
+
+// RunWithEffectIdempotent runs the command with RunWithEffect, once per key
+func (cr *commandWithEffectRunner[Deps]) RunWithEffectIdempotent(ctx context.Context, key string, cmd CommandWithEffect[Deps]) error {
+	return runIdempotent(ctx, cr.idempotency, key, func(ctx context.Context) error {
+		return cr.RunWithEffect(ctx, cmd)
+	})
+}
+
 // RunWithEffect executes a command with side effects using injected dependencies
 // Priority: command-level config > runner-level config
 func (cr *commandWithEffectRunner[Deps]) RunWithEffect(ctx context.Context, cmd CommandWithEffect[Deps]) error {
@@ -155,12 +278,16 @@ func (cr *commandWithEffectRunner[Deps]) RunWithEffect(ctx context.Context, cmd
 	}
 
 	return retry.Do(func() error {
-		return cmd.Run(ctx, newReadAppenderExtended(cr.store), cr.deps)
+		ra := newReadAppenderExtended(cr.store)
+		ra.eventRegistry.unknown = cr.unknownEvents
+		return cmd.Run(ctx, ra, cr.deps)
 	}, opts...)
 }
 
 type EventReadAppender interface {
 	EventsReader
+	// ReadEventsParallel reads the queries of the handlers concurrently
+	ReadEventsParallel(ctx context.Context, handlers ...*EventHandler) error
 	AppendEvents(ctx context.Context, event Event, remainingEvents ...Event) error
 }
 
@@ -171,7 +298,7 @@ type EventReadAppenderExtended interface {
 
 // readRecord tracks a single read operation for condition reconstruction
 type readRecord struct {
-	query                  dcb.Query
+	query                   dcb.Query
 	highestSeenVersionstamp *dcb.Versionstamp
 }
 
@@ -192,7 +319,7 @@ func newReadAppender(store dcb.DcbStore) EventReadAppender {
 // newReadAppender creates a ReadAppender with given store
 // it tracks the last versionstamp consumed by the command
 // and injects it directly when using append
-func newReadAppenderExtended(store dcb.DcbStore) EventReadAppenderExtended {
+func newReadAppenderExtended(store dcb.DcbStore) *commandReadAppender {
 	return &commandReadAppender{
 		store:         store,
 		eventRegistry: newEventRegistry(),
@@ -200,7 +327,7 @@ func newReadAppenderExtended(store dcb.DcbStore) EventReadAppenderExtended {
 }
 
 // ReadEvents reads events using the eventHandler's query and dispatches to handlers
-func (ra *commandReadAppender) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc) error {
+func (ra *commandReadAppender) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error {
 	if handler == nil {
 		return nil
 	}
@@ -210,24 +337,96 @@ func (ra *commandReadAppender) ReadEvents(ctx context.Context, query *Query, han
 		ra.eventRegistry.registerTypes(item.typeRegistry)
 	}
 
+	record, err := ra.read(ctx, query, handler, opts)
+	if err != nil {
+		return err
+	}
+
+	// Record this read for condition generation
+	ra.reads = append(ra.reads, record)
+	return nil
+}
+
+// ReadEventsParallel issues the reads of the handlers concurrently, each in its own transaction.
+// Every handler receives the events of its own query, called from its own goroutine:
+// handlers must not share state without synchronization.
+// All the reads protect the next AppendEvents, as if they were made one after the other.
+func (ra *commandReadAppender) ReadEventsParallel(ctx context.Context, handlers ...*EventHandler) error {
+	// Types are registered before reading, the registry is only read concurrently
+	for _, h := range handlers {
+		if h == nil || h.Handle == nil {
+			continue
+		}
+		for _, item := range h.Query.items {
+			ra.eventRegistry.registerTypes(item.typeRegistry)
+		}
+	}
+
+	records := make([]*readRecord, len(handlers))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, h := range handlers {
+		if h == nil || h.Handle == nil {
+			continue
+		}
+		g.Go(func() error {
+			record, err := ra.read(gctx, h.Query, h.Handle, h.Opts)
+			if err != nil {
+				return err
+			}
+			records[i] = &record
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record != nil {
+			ra.reads = append(ra.reads, *record)
+		}
+	}
+	return nil
+}
+
+// read dispatches the events matching the query to handler and returns what the read saw.
+// The query types must already be registered.
+func (ra *commandReadAppender) read(ctx context.Context, query *Query, handler EventHandlerFunc, opts []ReadOption) (readRecord, error) {
+	settings := newReadSettings(opts)
+	if settings.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+		defer cancel()
+	}
+
 	// Convert fairway Query to dcb Query
 	dcbQuery := query.toDcb()
-	var highestVs *dcb.Versionstamp
-	isReverse := query.opts != nil && query.opts.Reverse
+	readOpts := settings.readOptions(query)
+	isReverse := readOpts.Reverse
+
+	// A read starting after a position saw everything up to it
+	highestVs := readOpts.After
 
-	for dcbStoredEvent, err := range ra.store.Read(ctx, *dcbQuery, query.opts) {
+	for dcbStoredEvent, err := range ra.store.Read(ctx, *dcbQuery, &readOpts) {
 		if err != nil {
 			// context errors already have context
 			if ctx.Err() != nil {
-				return ctx.Err()
+				return readRecord{}, ctx.Err()
 			}
-			return fmt.Errorf("reading events: %s", err)
+			return readRecord{}, fmt.Errorf("reading events: %s", err)
+		}
+
+		pos := dcbStoredEvent.Position
+		if settings.until != nil && pos.Compare(*settings.until) > 0 {
+			if isReverse {
+				continue // newer than the bound, older ones follow
+			}
+			break
 		}
 
 		// Track versionstamp for append condition
 		// For reverse reads: track highest seen (first event is highest)
 		// For forward reads: track last yielded (which equals highest in ordered stream)
-		pos := dcbStoredEvent.Position
 		if isReverse {
 			if highestVs == nil || pos.Compare(*highestVs) > 0 {
 				highestVs = &pos
@@ -237,10 +436,17 @@ func (ra *commandReadAppender) ReadEvents(ctx context.Context, query *Query, han
 		}
 
 		// Deserialize dcb.Event → Event
-		ev, err := ra.eventRegistry.deserialize(dcbStoredEvent.Event)
+		ev, ok, err := ra.eventRegistry.decode(dcbStoredEvent.Event)
 		if err != nil {
-			return fmt.Errorf("deserializing event at position %x: %s", dcbStoredEvent.Position[:], err)
+			return readRecord{}, fmt.Errorf("deserializing event at position %x: %w", dcbStoredEvent.Position[:], err)
+		}
+		if !ok {
+			continue
 		}
+		ev.CommittedAt = dcbStoredEvent.CommittedAt
+		ev.Position = dcbStoredEvent.Position
+		ev.Superseded = dcbStoredEvent.Superseded
+		ev.Sequence = dcbStoredEvent.Sequence
 
 		// Dispatch Event to handler
 		if !handler(ev) {
@@ -248,18 +454,15 @@ func (ra *commandReadAppender) ReadEvents(ctx context.Context, query *Query, han
 		}
 	}
 
-	// Record this read for condition generation
-	ra.reads = append(ra.reads, readRecord{
+	return readRecord{
 		query:                   *dcbQuery,
 		highestSeenVersionstamp: highestVs,
-	})
-
-	return nil
+	}, nil
 }
 
 // AppendEventsNoCondition appends events without any condition (even if there was a Read previously)
 func (ra *commandReadAppender) AppendEventsNoCondition(ctx context.Context, event Event, remainingEvents ...Event) error {
-	dcbEvents, err := serializeEvents(append([]Event{event}, remainingEvents...))
+	dcbEvents, err := serializeEvents(stampIdempotencyKey(ctx, append([]Event{event}, remainingEvents...)))
 	if err != nil {
 		return err
 	}
@@ -269,7 +472,7 @@ func (ra *commandReadAppender) AppendEventsNoCondition(ctx context.Context, even
 
 // AppendEvents appends events with conditional check using tracked versionstamp
 func (ra *commandReadAppender) AppendEvents(ctx context.Context, event Event, remainingEvents ...Event) error {
-	dcbEvents, err := serializeEvents(append([]Event{event}, remainingEvents...))
+	dcbEvents, err := serializeEvents(stampIdempotencyKey(ctx, append([]Event{event}, remainingEvents...)))
 	if err != nil {
 		return err
 	}
@@ -279,16 +482,62 @@ func (ra *commandReadAppender) AppendEvents(ctx context.Context, event Event, re
 		return ra.store.Append(ctx, dcbEvents)
 	}
 
-	// Build one condition per read
-	conditions := make([]dcb.AppendCondition, len(ra.reads))
-	for i, r := range ra.reads {
-		conditions[i] = dcb.AppendCondition{
-			Query: r.query,
-			After: r.highestSeenVersionstamp,
+	return ra.store.Append(ctx, dcbEvents, ra.condition())
+}
+
+// condition merges every read of the command into a single append condition:
+// the union of their items, each bounded by the position its own read reached.
+// An item read several times keeps the earliest position, the command may have decided on what the first read saw.
+func (ra *commandReadAppender) condition() dcb.AppendCondition {
+	var items []dcb.QueryItem
+	seen := make(map[string]int) // item identity -> index in items
+	for _, r := range ra.reads {
+		for _, item := range r.itemsWithPositions().Items {
+			id := queryItemIdentity(item)
+			if i, ok := seen[id]; ok {
+				items[i].After = earlierVersionstamp(items[i].After, item.After)
+				continue
+			}
+			seen[id] = len(items)
+			items = append(items, item)
+		}
+	}
+	return dcb.AppendCondition{Query: dcb.Query{Items: items}}
+}
+
+// itemsWithPositions returns the read query with each item carrying the position up to which
+// the read saw events, so that items stay correctly bounded when combined with other reads.
+// Every item shares the read's position (unless it was read from a later one): a read that stopped early (or ran in reverse) may not
+// have reached events matching some of its items, so their own last match is not a safe bound.
+func (r readRecord) itemsWithPositions() dcb.Query {
+	items := make([]dcb.QueryItem, len(r.query.Items))
+	for i, item := range r.query.Items {
+		// An item read from its own position never saw anything before it
+		if item.After == nil || (r.highestSeenVersionstamp != nil && r.highestSeenVersionstamp.Compare(*item.After) > 0) {
+			item.After = r.highestSeenVersionstamp
 		}
+		items[i] = item
 	}
+	return dcb.Query{Items: items}
+}
 
-	return ra.store.Append(ctx, dcbEvents, conditions...)
+// queryItemIdentity identifies the events an item matches, regardless of the order of its types and tags
+func queryItemIdentity(item dcb.QueryItem) string {
+	types := slices.Sorted(slices.Values(item.Types))
+	tags := slices.Sorted(slices.Values(item.Tags))
+	tagKeys := slices.Sorted(slices.Values(item.TagKeys))
+	return strings.Join(types, "\x00") + "\x01" + strings.Join(tags, "\x00") + "\x01" + strings.Join(tagKeys, "\x00")
+}
+
+// earlierVersionstamp returns the earlier of two optional positions (nil = from the start)
+func earlierVersionstamp(a, b *dcb.Versionstamp) *dcb.Versionstamp {
+	if a == nil || b == nil {
+		return nil
+	}
+	if b.Compare(*a) < 0 {
+		return b
+	}
+	return a
 }
 
 func serializeEvents(events []Event) ([]dcb.Event, error) {
@@ -0,0 +1,102 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultBatchParallelism is the number of commands RunBatch runs at once by default
+const defaultBatchParallelism = 8
+
+// BatchOption configures RunBatch
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	parallelism int
+	stopOnError bool
+}
+
+// WithBatchParallelism sets the number of commands run at once (default: 8)
+func WithBatchParallelism(n int) BatchOption {
+	return func(c *batchConfig) {
+		if n > 0 {
+			c.parallelism = n
+		}
+	}
+}
+
+// WithBatchStopOnError stops starting commands after the first failure: the commands not started
+// fail with ErrBatchStopped. By default, every command runs whatever the others' outcome.
+func WithBatchStopOnError() BatchOption {
+	return func(c *batchConfig) {
+		c.stopOnError = true
+	}
+}
+
+// ErrBatchStopped is the error of the commands RunBatch didn't start, after a failure (see WithBatchStopOnError)
+var ErrBatchStopped = errors.New("batch stopped before the command")
+
+// BatchResult is the outcome of the commands of a RunBatch, in order
+type BatchResult struct {
+	Errors []error // the error of each command, nil when it succeeded
+}
+
+// Failed returns the number of commands that failed
+func (r BatchResult) Failed() int {
+	failed := 0
+	for _, err := range r.Errors {
+		if err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of the failed commands, prefixed with their index (nil when all succeeded)
+func (r BatchResult) Err() error {
+	var errs []error
+	for i, err := range r.Errors {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("command %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runBatch runs the commands concurrently with run, with bounded parallelism.
+// Commands not started when ctx is done fail with its error.
+func runBatch(ctx context.Context, cmds []Command, run func(context.Context, Command) error, opts ...BatchOption) BatchResult {
+	cfg := batchConfig{parallelism: defaultBatchParallelism}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	result := BatchResult{Errors: make([]error, len(cmds))}
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var g errgroup.Group
+	g.SetLimit(cfg.parallelism)
+	for i, cmd := range cmds {
+		g.Go(func() error {
+			if stopCtx.Err() != nil {
+				if err := ctx.Err(); err != nil {
+					result.Errors[i] = err
+				} else {
+					result.Errors[i] = ErrBatchStopped
+				}
+				return nil
+			}
+			result.Errors[i] = run(ctx, cmd)
+			if result.Errors[i] != nil && cfg.stopOnError {
+				stop()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return result
+}
@@ -0,0 +1,70 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBatch_RunsEveryCommandAndReportsEachError(t *testing.T) {
+	t.Parallel()
+
+	// Given - 20 commands, the 7th failing, and a parallelism of 3
+	store := dcb.SetupTestStore(t)
+	errRejected := errors.New("rejected")
+	var running, maxRunning atomic.Int32
+	cmds := make([]fairway.Command, 20)
+	for i := range cmds {
+		cmds[i] = commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			if i == 6 {
+				return errRejected
+			}
+			return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: i}))
+		})
+	}
+
+	// When
+	result := fairway.NewCommandRunner(store).RunBatch(t.Context(), cmds, fairway.WithBatchParallelism(3))
+
+	// Then
+	require.Len(t, result.Errors, 20)
+	assert.ErrorIs(t, result.Errors[6], errRejected)
+	assert.Equal(t, 1, result.Failed())
+	assert.ErrorContains(t, result.Err(), "command 6: rejected")
+	assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 19)
+}
+
+func TestRunBatch_StopOnError(t *testing.T) {
+	t.Parallel()
+
+	// Given - commands run one at a time, the first one failing
+	store := dcb.SetupTestStore(t)
+	errRejected := errors.New("rejected")
+	var ran atomic.Int32
+	cmds := []fairway.Command{
+		commandFunc(func(context.Context, fairway.EventReadAppender) error { ran.Add(1); return errRejected }),
+		commandFunc(func(context.Context, fairway.EventReadAppender) error { ran.Add(1); return nil }),
+	}
+
+	// When
+	result := fairway.NewCommandRunner(store).RunBatch(t.Context(), cmds,
+		fairway.WithBatchParallelism(1), fairway.WithBatchStopOnError())
+
+	// Then
+	assert.ErrorIs(t, result.Errors[0], errRejected)
+	assert.ErrorIs(t, result.Errors[1], fairway.ErrBatchStopped)
+	assert.Equal(t, int32(1), ran.Load())
+}
@@ -3,8 +3,11 @@ package fairway_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"iter"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/avast/retry-go/v4"
@@ -68,13 +71,13 @@ func TestConditionalAppend_AfterRead(tt *testing.T) {
 		require.Len(tt, store.AppendCalls[0].Conditions, 1, "single read = single condition")
 
 		condition := store.AppendCalls[0].Conditions[0]
-		require.NotNil(tt, condition.After, "condition should have versionstamp")
-		assert.Equal(tt, lastVersionstamp, *condition.After, "should use last read versionstamp")
-		assert.Len(tt, condition.Query.Items, 1, "condition should include query")
+		require.Len(tt, condition.Query.Items, 1, "condition should include query")
+		require.NotNil(tt, condition.Query.Items[0].After, "condition should have versionstamp")
+		assert.Equal(tt, lastVersionstamp, *condition.Query.Items[0].After, "should use last read versionstamp")
 	})
 }
 
-func TestMultipleReads_GeneratesMultipleConditions(tt *testing.T) {
+func TestMultipleReads_MergedIntoSingleCondition(tt *testing.T) {
 	rapid.Check(tt, func(t *rapid.T) {
 		// Given - Command with multiple reads
 		storedEvents := RandomStoredEvents(t, 5)
@@ -116,19 +119,85 @@ func TestMultipleReads_GeneratesMultipleConditions(tt *testing.T) {
 		err := runner.RunPure(context.Background(), cmdFunc)
 		require.NoError(tt, err)
 
-		// Then - Should generate 2 conditions (one per read)
+		// Then - Should generate a single condition with the items of both reads
 		require.Len(tt, store.AppendCalls, 1)
-		require.Len(tt, store.AppendCalls[0].Conditions, 2, "2 reads = 2 conditions")
+		require.Len(tt, store.AppendCalls[0].Conditions, 1, "2 reads = 1 condition")
+		items := store.AppendCalls[0].Conditions[0].Query.Items
+		require.Len(tt, items, 2, "one item per read")
+
+		// Both items have same versionstamp (mock returns same events)
+		for i, item := range items {
+			require.NotNil(tt, item.After, "item %d should have versionstamp", i)
+			assert.Equal(tt, lastVersionstamp, *item.After)
+		}
+
+		// First item has 3 types, second has 1
+		assert.Len(tt, items[0].Types, 3)
+		assert.Len(tt, items[1].Types, 1)
+	})
+}
+
+func TestMultipleReads_MergedConditionRejectsWhatEachReadWould(tt *testing.T) {
+	eventTypes := []any{TestEventA{}, TestEventB{}, TestEventC{}}
 
-		// Both conditions have same versionstamp (mock returns same events)
-		for i, cond := range store.AppendCalls[0].Conditions {
-			require.NotNil(tt, cond.After, "condition %d should have versionstamp", i)
-			assert.Equal(tt, lastVersionstamp, *cond.After)
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given - Reads of random queries, each reaching a random position
+		type read struct {
+			query dcb.Query
+			pos   dcb.Versionstamp
+		}
+		var reads []read
+		store := &mockStore{}
+		store.ReadFunc = func(ctx context.Context, query dcb.Query, opts *dcb.ReadOptions) iter.Seq2[dcb.StoredEvent, error] {
+			pos := dcb.Versionstamp{rapid.Byte().Draw(t, "position")}
+			reads = append(reads, read{query: query, pos: pos})
+			return func(yield func(dcb.StoredEvent, error) bool) {
+				yield(dcb.StoredEvent{
+					Event:    dcb.Event{Type: "TestEventA", Data: []byte(`{"occurredAt":"2024-01-01T00:00:00Z","data":{"Value":"a"}}`)},
+					Position: pos,
+				}, nil)
+			}
 		}
+		runner := fairway.NewCommandRunner(store)
 
-		// First condition has 3 types, second has 1
-		assert.Len(tt, store.AppendCalls[0].Conditions[0].Query.Items[0].Types, 3)
-		assert.Len(tt, store.AppendCalls[0].Conditions[1].Query.Items[0].Types, 1)
+		impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+			for range rapid.IntRange(1, 3).Draw(t, "reads") {
+				var items []fairway.QueryItem
+				for range rapid.IntRange(1, 2).Draw(t, "items") {
+					types := rapid.SliceOfNDistinct(rapid.SampledFrom(eventTypes), 1, 3, func(e any) string { return fmt.Sprintf("%T", e) }).Draw(t, "types")
+					items = append(items, fairway.NewQueryItem().Types(types...))
+				}
+				if err := ra.ReadEvents(ctx, fairway.QueryItems(items...), func(fairway.Event) bool { return true }); err != nil {
+					return err
+				}
+			}
+			// When
+			return ra.AppendEvents(ctx, fairway.NewEvent(TestEventC{Flag: true}))
+		})
+		require.NoError(t, runner.RunPure(context.Background(), impl))
+
+		// Then - An event conflicts with the merged condition iff it conflicts with the condition of one of the reads
+		require.Len(t, store.AppendCalls, 1)
+		require.Len(t, store.AppendCalls[0].Conditions, 1)
+		merged := store.AppendCalls[0].Conditions[0].Query.Items
+		for _, eventType := range []string{"TestEventA", "TestEventB", "TestEventC"} {
+			for p := range 256 {
+				pos := dcb.Versionstamp{byte(p)}
+
+				perRead := false
+				for _, r := range reads {
+					for _, item := range r.query.Items {
+						perRead = perRead || (slices.Contains(item.Types, eventType) && pos.Compare(r.pos) > 0)
+					}
+				}
+				mergedConflicts := false
+				for _, item := range merged {
+					require.NotNil(t, item.After)
+					mergedConflicts = mergedConflicts || (slices.Contains(item.Types, eventType) && pos.Compare(*item.After) > 0)
+				}
+				assert.Equal(t, perRead, mergedConflicts, "%s at %x", eventType, pos[:1])
+			}
+		}
 	})
 }
 
@@ -201,8 +270,9 @@ func TestHandlerStopsEarly_VersionstampFromLastYielded(tt *testing.T) {
 
 		condition := store.AppendCalls[0].Condition
 		require.NotNil(tt, condition)
-		require.NotNil(tt, condition.After)
-		assert.Equal(tt, expectedVersionstamp, *condition.After, "should use versionstamp from last yielded event")
+		require.Len(tt, condition.Query.Items, 1)
+		require.NotNil(tt, condition.Query.Items[0].After)
+		assert.Equal(tt, expectedVersionstamp, *condition.Query.Items[0].After, "should use versionstamp from last yielded event")
 	})
 }
 
@@ -357,6 +427,65 @@ func TestRunPure_PropagatesAppendErrors(t *testing.T) {
 	assert.ErrorIs(t, err, expectedErr)
 }
 
+func TestRunPure_RetriesClassifiedErrors(t *testing.T) {
+	// Given - an append failing once on a transient FDB error
+	transient := fmt.Errorf("append: %w", fdb.Error{Code: 1031}) // transaction_timed_out
+	attempts := 0
+	store := &mockStore{
+		AppendFunc: func(context.Context, []dcb.Event, []dcb.AppendCondition) error {
+			attempts++
+			if attempts == 1 {
+				return transient
+			}
+			return nil
+		},
+	}
+	runner := fairway.NewCommandRunner(store,
+		fairway.WithRetryOptions(retry.Attempts(3), retry.Delay(time.Millisecond)),
+		fairway.WithRetryClassifiers(fairway.IsTransientStoreError),
+	)
+	cmd := &testCommand{T: t, EventsToAppend: []any{TestEventA{Value: "test"}}}
+
+	// When
+	err := runner.RunPure(context.Background(), cmd)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRunPure_DoesNotRetryUnclassifiedErrors(t *testing.T) {
+	// Given - commit_unknown_result: the append may have committed
+	attempts := 0
+	store := &mockStore{
+		AppendFunc: func(context.Context, []dcb.Event, []dcb.AppendCondition) error {
+			attempts++
+			return fdb.Error{Code: 1021}
+		},
+	}
+	runner := fairway.NewCommandRunner(store,
+		fairway.WithRetryOptions(retry.Attempts(3), retry.Delay(time.Millisecond)),
+		fairway.WithRetryClassifiers(fairway.IsTransientStoreError),
+	)
+	cmd := &testCommand{T: t, EventsToAppend: []any{TestEventA{Value: "test"}}}
+
+	// When
+	err := runner.RunPure(context.Background(), cmd)
+
+	// Then
+	var fdbErr fdb.Error
+	require.ErrorAs(t, err, &fdbErr)
+	assert.Equal(t, 1021, fdbErr.Code)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestIsTransientStoreError(t *testing.T) {
+	assert.True(t, fairway.IsTransientStoreError(fdb.Error{Code: 1020}))
+	assert.True(t, fairway.IsTransientStoreError(fmt.Errorf("wrapped: %w", fdb.Error{Code: 1213})))
+	assert.False(t, fairway.IsTransientStoreError(fdb.Error{Code: 1021}))
+	assert.False(t, fairway.IsTransientStoreError(dcb.ErrStoreOverloaded))
+}
+
 func TestRunWithEffect_PassesDependencies(t *testing.T) {
 	type Deps struct {
 		Value string
@@ -654,15 +783,17 @@ func TestMultipleReads_DetectsRaceOnEarlierQuery(t *testing.T) {
 		}
 	}
 
-	// Append fails because condition[0] (queryA, After:vsA) sees vsInserted
+	// Append fails because the queryA item (After:vsA) sees vsInserted
 	store.AppendFunc = func(ctx context.Context, events []dcb.Event, conds []dcb.AppendCondition) error {
 		// Simulate: an event matching queryA was inserted at vsInserted (between vsA and vsB)
-		// Condition 0: queryA with After=vsA -> FAILS (vsInserted > vsA matches queryA)
+		// queryA item with After=vsA -> FAILS (vsInserted > vsA matches queryA)
 		// If we only used vsB, this conflict would be missed!
 		for _, cond := range conds {
-			if cond.After != nil && *cond.After == vsA {
-				// This condition catches the race
-				return dcb.ErrAppendConditionFailed
+			for _, item := range cond.Query.Items {
+				if item.After != nil && *item.After == vsA {
+					// This item catches the race
+					return dcb.ErrAppendConditionFailed
+				}
 			}
 		}
 		return nil
@@ -697,11 +828,13 @@ func TestMultipleReads_DetectsRaceOnEarlierQuery(t *testing.T) {
 	// Should fail - race detected on queryA
 	assert.ErrorIs(t, err, dcb.ErrAppendConditionFailed)
 
-	// Verify 2 conditions were generated
+	// Verify a single condition keeps the position of each read
 	require.Len(t, store.AppendCalls, 1)
-	require.Len(t, store.AppendCalls[0].Conditions, 2)
-	assert.Equal(t, vsA, *store.AppendCalls[0].Conditions[0].After)
-	assert.Equal(t, vsB, *store.AppendCalls[0].Conditions[1].After)
+	require.Len(t, store.AppendCalls[0].Conditions, 1)
+	items := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items, 2)
+	assert.Equal(t, vsA, *items[0].After)
+	assert.Equal(t, vsB, *items[1].After)
 }
 
 func TestReadAppendReadAppend(t *testing.T) {
@@ -754,25 +887,205 @@ func TestReadAppendReadAppend(t *testing.T) {
 
 	require.Len(t, store.AppendCalls, 2)
 
-	// First append: 1 read = 1 condition
-	require.Len(t, store.AppendCalls[0].Conditions, 1, "first append has 1 condition (1 read)")
-	cond1 := store.AppendCalls[0].Conditions[0]
-	require.NotNil(t, cond1.After)
-	assert.Equal(t, vs2, *cond1.After)
-	assert.Len(t, cond1.Query.Items[0].Types, 2, "first read queried 2 types")
-
-	// Second append: 2 reads = 2 conditions
-	require.Len(t, store.AppendCalls[1].Conditions, 2, "second append has 2 conditions (2 reads)")
-	cond2a := store.AppendCalls[1].Conditions[0]
-	cond2b := store.AppendCalls[1].Conditions[1]
-
-	require.NotNil(t, cond2a.After)
-	assert.Equal(t, vs2, *cond2a.After)
-	assert.Len(t, cond2a.Query.Items[0].Types, 2, "first read queried 2 types")
-
-	require.NotNil(t, cond2b.After)
-	assert.Equal(t, vs2, *cond2b.After)
-	assert.Len(t, cond2b.Query.Items[0].Types, 1, "second read queried 1 type")
+	// First append: 1 read = 1 item
+	require.Len(t, store.AppendCalls[0].Conditions, 1)
+	items1 := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items1, 1, "first append has 1 item (1 read)")
+	require.NotNil(t, items1[0].After)
+	assert.Equal(t, vs2, *items1[0].After)
+	assert.Len(t, items1[0].Types, 2, "first read queried 2 types")
+
+	// Second append: 2 reads = 2 items of a single condition
+	require.Len(t, store.AppendCalls[1].Conditions, 1)
+	items2 := store.AppendCalls[1].Conditions[0].Query.Items
+	require.Len(t, items2, 2, "second append has 2 items (2 reads)")
+
+	require.NotNil(t, items2[0].After)
+	assert.Equal(t, vs2, *items2[0].After)
+	assert.Len(t, items2[0].Types, 2, "first read queried 2 types")
+
+	require.NotNil(t, items2[1].After)
+	assert.Equal(t, vs2, *items2[1].After)
+	assert.Len(t, items2[1].Types, 1, "second read queried 1 type")
+}
+
+func TestAppendEvents_QueryItemsCarryTheirReadPosition(t *testing.T) {
+	vs1 := dcb.Versionstamp{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	since := dcb.Versionstamp{0, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	store := &mockStore{
+		ReadEvents: []dcb.StoredEvent{
+			{Event: dcb.Event{Type: "TestEventA", Data: []byte(`{"occurredAt":"2024-01-01T00:00:00Z","data":{"Value":"a"}}`)}, Position: vs1},
+		},
+	}
+	runner := fairway.NewCommandRunner(store)
+
+	impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if err := ra.ReadEvents(ctx,
+			fairway.QueryItems(
+				fairway.NewQueryItem().Types(TestEventA{}),
+				fairway.NewQueryItem().Types(TestEventB{}).After(since),
+			),
+			func(fairway.Event) bool { return true }); err != nil {
+			return err
+		}
+		return ra.AppendEvents(ctx, fairway.NewEvent(TestEventC{Flag: true}))
+	})
+
+	require.NoError(t, runner.RunPure(context.Background(), impl))
+
+	// Then - each item is bounded by the position its read reached
+	require.Len(t, store.AppendCalls, 1)
+	items := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items, 2)
+	for _, item := range items {
+		require.NotNil(t, item.After)
+		assert.Equal(t, vs1, *item.After)
+	}
+}
+
+func TestReadEvents_PerCallOptions(t *testing.T) {
+	vs1 := dcb.Versionstamp{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	store := &mockStore{
+		ReadEvents: []dcb.StoredEvent{
+			{Event: dcb.Event{Type: "TestEventA", Data: []byte(`{"occurredAt":"2024-01-01T00:00:00Z","data":{"Value":"a"}}`)}, Position: vs1},
+		},
+	}
+	runner := fairway.NewCommandRunner(store)
+	query := fairway.QueryItems(fairway.NewQueryItem().Types(TestEventA{}))
+
+	// When - the same query is read with the latest event only, then fully
+	impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if err := ra.ReadEvents(ctx, query, func(fairway.Event) bool { return true }, fairway.ReadLatest(1)); err != nil {
+			return err
+		}
+		return ra.ReadEvents(ctx, query, func(fairway.Event) bool { return true })
+	})
+	require.NoError(t, runner.RunPure(context.Background(), impl))
+
+	// Then - options only applied to the call they were passed to
+	require.Len(t, store.ReadCalls, 2)
+	assert.Equal(t, &dcb.ReadOptions{Reverse: true, Limit: 1}, store.ReadCalls[0].Opts)
+	assert.Equal(t, &dcb.ReadOptions{}, store.ReadCalls[1].Opts)
+}
+
+func TestReadEvents_EmptyReadAfterPositionBoundsCondition(t *testing.T) {
+	since := dcb.Versionstamp{0, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	store := &mockStore{}
+	runner := fairway.NewCommandRunner(store)
+
+	// Given - nothing happened since the position the command reads from
+	impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if err := ra.ReadEvents(ctx,
+			fairway.QueryItems(fairway.NewQueryItem().Types(TestEventA{})),
+			func(fairway.Event) bool { return true },
+			fairway.ReadAfter(since)); err != nil {
+			return err
+		}
+		// When
+		return ra.AppendEvents(ctx, fairway.NewEvent(TestEventB{Count: 1}))
+	})
+	require.NoError(t, runner.RunPure(context.Background(), impl))
+
+	// Then - the condition ignores the events before the position
+	require.Len(t, store.AppendCalls, 1)
+	items := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].After)
+	assert.Equal(t, since, *items[0].After)
+}
+
+func TestReadEventsParallel_ProtectsEveryQuery(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendEvent := func(data any) {
+		ev, err := fairway.ToDcbEvent(fairway.NewEvent(data))
+		require.NoError(t, err)
+		require.NoError(t, store.Append(ctx, []dcb.Event{ev}))
+	}
+	appendEvent(TestEventA{Value: "a"})
+	appendEvent(TestEventB{Count: 1})
+	runner := fairway.NewCommandRunner(store, fairway.WithRetryOptions(retry.Attempts(1)))
+
+	var values []string
+	var counts []int
+	impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if err := ra.ReadEventsParallel(ctx,
+			fairway.NewEventHandler(fairway.QueryItems(fairway.NewQueryItem().Types(TestEventA{})), func(e fairway.Event) bool {
+				values = append(values, e.Data.(TestEventA).Value)
+				return true
+			}),
+			fairway.NewEventHandler(fairway.QueryItems(fairway.NewQueryItem().Types(TestEventB{})), func(e fairway.Event) bool {
+				counts = append(counts, e.Data.(TestEventB).Count)
+				return true
+			}),
+		); err != nil {
+			return err
+		}
+
+		// a concurrent writer changes the first stream
+		appendEvent(TestEventA{Value: "concurrent"})
+		return ra.AppendEvents(ctx, fairway.NewEvent(TestEventC{Flag: true}))
+	})
+
+	// When
+	err := runner.RunPure(ctx, impl)
+
+	// Then - each handler got its own events, the append is protected by both reads
+	assert.Equal(t, []string{"a"}, values)
+	assert.Equal(t, []int{1}, counts)
+	assert.ErrorIs(t, err, dcb.ErrAppendConditionFailed)
+}
+
+func TestAppendEvents_ItemReadTwiceKeepsEarliestPosition(t *testing.T) {
+	vsA := dcb.Versionstamp{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	vsB := dcb.Versionstamp{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	// Given - the same events are read twice, the store moved between the reads
+	readCount := 0
+	store := &mockStore{}
+	store.ReadFunc = func(ctx context.Context, query dcb.Query, opts *dcb.ReadOptions) iter.Seq2[dcb.StoredEvent, error] {
+		readCount++
+		pos := vsA
+		if readCount > 1 {
+			pos = vsB
+		}
+		return func(yield func(dcb.StoredEvent, error) bool) {
+			yield(dcb.StoredEvent{
+				Event:    dcb.Event{Type: "TestEventA", Data: []byte(`{"occurredAt":"2024-01-01T00:00:00Z","data":{"Value":"a"}}`)},
+				Position: pos,
+			}, nil)
+		}
+	}
+	runner := fairway.NewCommandRunner(store)
+
+	impl := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if err := ra.ReadEvents(ctx,
+			fairway.QueryItems(fairway.NewQueryItem().Types(TestEventA{}, TestEventB{})),
+			func(fairway.Event) bool { return true }); err != nil {
+			return err
+		}
+		if err := ra.ReadEvents(ctx,
+			fairway.QueryItems(fairway.NewQueryItem().Types(TestEventB{}, TestEventA{})),
+			func(fairway.Event) bool { return true }); err != nil {
+			return err
+		}
+		// When
+		return ra.AppendEvents(ctx, fairway.NewEvent(TestEventC{Flag: true}))
+	})
+
+	require.NoError(t, runner.RunPure(context.Background(), impl))
+
+	// Then - the item appears once, bounded by the first read's position
+	require.Len(t, store.AppendCalls, 1)
+	require.Len(t, store.AppendCalls[0].Conditions, 1)
+	items := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].After)
+	assert.Equal(t, vsA, *items[0].After)
 }
 
 // mockStore provides controllable DcbStore for testing
@@ -1183,7 +1496,8 @@ func TestReverseRead_TracksHighestVersionstamp(t *testing.T) {
 	require.Len(t, store.AppendCalls, 1)
 	require.Len(t, store.AppendCalls[0].Conditions, 1)
 
-	cond := store.AppendCalls[0].Conditions[0]
-	require.NotNil(t, cond.After)
-	assert.Equal(t, vs3, *cond.After, "should track highest versionstamp (vs3), not last yielded in reverse")
+	items := store.AppendCalls[0].Conditions[0].Query.Items
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].After)
+	assert.Equal(t, vs3, *items[0].After, "should track highest versionstamp (vs3), not last yielded in reverse")
 }
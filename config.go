@@ -0,0 +1,231 @@
+package fairway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// envPrefix prefixes every environment variable read by ConfigFromEnv
+const envPrefix = "FAIRWAY_"
+
+// Duration is a time.Duration read from strings such as "100ms" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a Go duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a Go duration string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config holds deployment-tunable settings for the store, command runners and automations.
+// Zero values of optional store limits keep the store defaults.
+type Config struct {
+	// Store
+	Namespace                 string `json:"namespace" env:"NAMESPACE"`
+	ClusterFile               string `json:"clusterFile" env:"CLUSTER_FILE"` // empty = FDB default cluster file
+	MaxTransactionBytes       int    `json:"maxTransactionBytes" env:"MAX_TRANSACTION_BYTES"`
+	MaxConcurrentTransactions int    `json:"maxConcurrentTransactions" env:"MAX_CONCURRENT_TRANSACTIONS"`
+	MaxQueuedTransactions     int    `json:"maxQueuedTransactions" env:"MAX_QUEUED_TRANSACTIONS"`
+
+	// Command runner retry policy
+	CommandRetryAttempts int      `json:"commandRetryAttempts" env:"COMMAND_RETRY_ATTEMPTS"`
+	CommandRetryDelay    Duration `json:"commandRetryDelay" env:"COMMAND_RETRY_DELAY"`
+	CommandRetryMaxDelay Duration `json:"commandRetryMaxDelay" env:"COMMAND_RETRY_MAX_DELAY"`
+
+	// Automations
+	AutomationNumWorkers    int      `json:"automationNumWorkers" env:"AUTOMATION_NUM_WORKERS"`
+	AutomationLeaseTTL      Duration `json:"automationLeaseTTL" env:"AUTOMATION_LEASE_TTL"`
+	AutomationGracePeriod   Duration `json:"automationGracePeriod" env:"AUTOMATION_GRACE_PERIOD"`
+	AutomationMaxAttempts   int      `json:"automationMaxAttempts" env:"AUTOMATION_MAX_ATTEMPTS"`
+	AutomationBatchSize     int      `json:"automationBatchSize" env:"AUTOMATION_BATCH_SIZE"`
+	AutomationPollInterval  Duration `json:"automationPollInterval" env:"AUTOMATION_POLL_INTERVAL"`
+	AutomationRetryBaseWait Duration `json:"automationRetryBaseWait" env:"AUTOMATION_RETRY_BASE_WAIT"`
+	AutomationCatchUpRate   int      `json:"automationCatchUpRate" env:"AUTOMATION_CATCH_UP_RATE"` // events per second, 0 = unlimited
+}
+
+// DefaultConfig returns the framework defaults
+func DefaultConfig() Config {
+	automation := defaultConfig()
+	return Config{
+		CommandRetryAttempts:    4,
+		CommandRetryDelay:       Duration(10 * time.Millisecond),
+		CommandRetryMaxDelay:    Duration(500 * time.Millisecond),
+		AutomationNumWorkers:    automation.NumWorkers,
+		AutomationLeaseTTL:      Duration(automation.LeaseTTL),
+		AutomationGracePeriod:   Duration(automation.GracePeriod),
+		AutomationMaxAttempts:   automation.MaxAttempts,
+		AutomationBatchSize:     automation.BatchSize,
+		AutomationPollInterval:  Duration(automation.PollInterval),
+		AutomationRetryBaseWait: Duration(automation.RetryBaseWait),
+	}
+}
+
+// ConfigFromEnv returns the defaults overridden by FAIRWAY_* environment variables
+// (e.g. FAIRWAY_NAMESPACE, FAIRWAY_AUTOMATION_POLL_INTERVAL=250ms), validated.
+func ConfigFromEnv() (Config, error) {
+	c := DefaultConfig()
+
+	v := reflect.ValueOf(&c).Elem()
+	var errs []error
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name := envPrefix + field.Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setConfigField(v.Field(i), raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	return c, c.Validate()
+}
+
+// ConfigFromFile returns the defaults overridden by the JSON file at path, validated.
+// Durations are written as strings ("100ms", "1m").
+func ConfigFromFile(path string) (Config, error) {
+	c := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Config{}, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return c, c.Validate()
+}
+
+// setConfigField parses raw into a string, int or Duration field
+func setConfigField(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(raw)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case Duration:
+		var d Duration
+		if err := d.UnmarshalText([]byte(raw)); err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	if c.Namespace == "" {
+		errs = append(errs, errors.New("namespace is required"))
+	}
+	if c.MaxTransactionBytes < 0 || c.MaxTransactionBytes > dcb.MaxTransactionBytes {
+		errs = append(errs, fmt.Errorf("maxTransactionBytes must be between 0 and %d", dcb.MaxTransactionBytes))
+	}
+	if c.MaxConcurrentTransactions < 0 {
+		errs = append(errs, errors.New("maxConcurrentTransactions must be >= 0"))
+	}
+	if c.MaxQueuedTransactions < 0 {
+		errs = append(errs, errors.New("maxQueuedTransactions must be >= 0"))
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"commandRetryAttempts", c.CommandRetryAttempts},
+		{"automationNumWorkers", c.AutomationNumWorkers},
+		{"automationMaxAttempts", c.AutomationMaxAttempts},
+		{"automationBatchSize", c.AutomationBatchSize},
+	} {
+		if f.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be > 0", f.name))
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		value Duration
+	}{
+		{"commandRetryDelay", c.CommandRetryDelay},
+		{"commandRetryMaxDelay", c.CommandRetryMaxDelay},
+		{"automationLeaseTTL", c.AutomationLeaseTTL},
+		{"automationGracePeriod", c.AutomationGracePeriod},
+		{"automationPollInterval", c.AutomationPollInterval},
+		{"automationRetryBaseWait", c.AutomationRetryBaseWait},
+	} {
+		if f.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be > 0", f.name))
+		}
+	}
+	if c.AutomationCatchUpRate < 0 {
+		errs = append(errs, errors.New("automationCatchUpRate must be >= 0"))
+	}
+	if c.AutomationMaxAttempts > 255 {
+		errs = append(errs, errors.New("automationMaxAttempts must be <= 255"))
+	}
+	return errors.Join(errs...)
+}
+
+// StoreOptions returns the store options matching the configuration
+func (c Config) StoreOptions() []dcb.StoreOption {
+	so := dcb.StoreOptions{}
+	return []dcb.StoreOption{
+		so.WithMaxTransactionBytes(c.MaxTransactionBytes),
+		so.WithMaxConcurrentTransactions(c.MaxConcurrentTransactions, c.MaxQueuedTransactions),
+	}
+}
+
+// CommandRunnerOptions returns the command runner options matching the configuration
+func (c Config) CommandRunnerOptions() []CommandRunnerOption {
+	return []CommandRunnerOption{WithRetryOptions(c.RetryOptions()...)}
+}
+
+// RetryOptions returns the configured command retry policy as retry-go options
+func (c Config) RetryOptions() []retry.Option {
+	return conditionRetryOptions(
+		uint(c.CommandRetryAttempts),
+		time.Duration(c.CommandRetryDelay),
+		time.Duration(c.CommandRetryMaxDelay),
+	)
+}
+
+// AutomationOptionsFromConfig returns the automation options matching the configuration
+func AutomationOptionsFromConfig[Deps any](c Config) []AutomationOption[Deps] {
+	return []AutomationOption[Deps]{
+		WithNumWorkers[Deps](c.AutomationNumWorkers),
+		WithLeaseTTL[Deps](time.Duration(c.AutomationLeaseTTL)),
+		WithGracePeriod[Deps](time.Duration(c.AutomationGracePeriod)),
+		WithMaxAttempts[Deps](c.AutomationMaxAttempts),
+		WithBatchSize[Deps](c.AutomationBatchSize),
+		WithPollInterval[Deps](time.Duration(c.AutomationPollInterval)),
+		WithRetryBaseWait[Deps](time.Duration(c.AutomationRetryBaseWait)),
+		WithCatchUpRateLimit[Deps](float64(c.AutomationCatchUpRate)),
+	}
+}
@@ -0,0 +1,78 @@
+package fairway_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFromEnv_OverridesDefaults(t *testing.T) {
+	// Given
+	t.Setenv("FAIRWAY_NAMESPACE", "myapp")
+	t.Setenv("FAIRWAY_AUTOMATION_NUM_WORKERS", "8")
+	t.Setenv("FAIRWAY_AUTOMATION_POLL_INTERVAL", "250ms")
+	t.Setenv("FAIRWAY_AUTOMATION_CATCH_UP_RATE", "500")
+
+	// When
+	c, err := fairway.ConfigFromEnv()
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "myapp", c.Namespace)
+	assert.Equal(t, 8, c.AutomationNumWorkers)
+	assert.Equal(t, fairway.Duration(250*time.Millisecond), c.AutomationPollInterval)
+	assert.Equal(t, 500, c.AutomationCatchUpRate)
+	assert.Equal(t, fairway.DefaultConfig().AutomationBatchSize, c.AutomationBatchSize)
+}
+
+func TestConfigFromEnv_ReportsAllInvalidValues(t *testing.T) {
+	// Given
+	t.Setenv("FAIRWAY_NAMESPACE", "myapp")
+	t.Setenv("FAIRWAY_AUTOMATION_BATCH_SIZE", "many")
+	t.Setenv("FAIRWAY_COMMAND_RETRY_DELAY", "soon")
+
+	// When
+	_, err := fairway.ConfigFromEnv()
+
+	// Then
+	assert.ErrorContains(t, err, "FAIRWAY_AUTOMATION_BATCH_SIZE")
+	assert.ErrorContains(t, err, "FAIRWAY_COMMAND_RETRY_DELAY")
+}
+
+func TestConfigFromFile(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), "fairway.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"namespace": "myapp",
+		"maxConcurrentTransactions": 64,
+		"commandRetryMaxDelay": "2s"
+	}`), 0o600))
+
+	// When
+	c, err := fairway.ConfigFromFile(path)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 64, c.MaxConcurrentTransactions)
+	assert.Equal(t, fairway.Duration(2*time.Second), c.CommandRetryMaxDelay)
+}
+
+func TestConfigValidate(t *testing.T) {
+	// Given
+	c := fairway.DefaultConfig()
+	c.AutomationNumWorkers = 0
+	c.MaxQueuedTransactions = -1
+
+	// When
+	err := c.Validate()
+
+	// Then - every problem is reported
+	assert.ErrorContains(t, err, "namespace is required")
+	assert.ErrorContains(t, err, "automationNumWorkers must be > 0")
+	assert.ErrorContains(t, err, "maxQueuedTransactions must be >= 0")
+}
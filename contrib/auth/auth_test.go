@@ -0,0 +1,147 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/contrib/auth"
+	"github.com/err0r500/fairway/testing/given"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokens(t *testing.T, opts ...auth.TokensOption) auth.Tokens {
+	tokens, err := auth.NewTokens([]byte("test-secret"), opts...)
+	require.NoError(t, err)
+	return tokens
+}
+
+func TestAuth_RegisterLoginLogout(t *testing.T) {
+	t.Parallel()
+	tokens := newTokens(t)
+	store, server, httpClient := given.FreshSetup(t, auth.RegisterCommands(tokens))
+	protected := httptest.NewServer(auth.Middleware(tokens, fairway.NewReader(store))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := auth.ClaimsFrom(r.Context())
+			_, _ = w.Write([]byte(claims.UserId))
+		})))
+	t.Cleanup(protected.Close)
+
+	// Given - a registered user
+	resp, err := httpClient.R().
+		SetBody(map[string]any{"id": "user-1", "email": "jane@example.com", "password": "s3cret"}).
+		Post(server.URL + "/auth/register")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode())
+
+	// When - they log in
+	var login auth.LoginResponse
+	resp, err = httpClient.R().
+		SetBody(map[string]any{"email": "jane@example.com", "password": "s3cret"}).
+		SetResult(&login).
+		Post(server.URL + "/auth/login")
+
+	// Then - their token authenticates them
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	resp, err = httpClient.R().SetHeader("Authorization", "Token "+login.Token).Get(protected.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "user-1", string(resp.Bytes()))
+
+	// When - they log out
+	resp, err = httpClient.R().SetHeader("Authorization", "Bearer "+login.Token).Post(server.URL + "/auth/logout")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode())
+
+	// Then - the token is revoked
+	resp, err = httpClient.R().SetHeader("Authorization", "Token "+login.Token).Get(protected.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+}
+
+func TestAuth_RejectsWrongPasswordsAndTakenEmails(t *testing.T) {
+	t.Parallel()
+	store, server, httpClient := given.FreshSetup(t, auth.RegisterCommands(newTokens(t)))
+
+	// Given
+	given.EventsInStore(store, fairway.NewEvent(auth.UserRegistered{
+		UserId: "user-1", Email: "jane@example.com", HashedPassword: mustHash(t, "s3cret"),
+	}))
+
+	// When
+	loginResp, err := httpClient.R().
+		SetBody(map[string]any{"email": "jane@example.com", "password": "wrong"}).
+		Post(server.URL + "/auth/login")
+	require.NoError(t, err)
+	registerResp, err := httpClient.R().
+		SetBody(map[string]any{"id": "user-2", "email": "jane@example.com", "password": "other"}).
+		Post(server.URL + "/auth/register")
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, http.StatusUnauthorized, loginResp.StatusCode())
+	assert.Equal(t, http.StatusConflict, registerResp.StatusCode())
+}
+
+func TestCommands_LoginWithTheCurrentCredentials(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := given.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store)
+
+	// Given - a user who changed their email and password
+	require.NoError(t, runner.RunPure(ctx, auth.Register("user-1", "jane@example.com", "s3cret")))
+	require.NoError(t, runner.RunPure(ctx, auth.ChangeEmail("user-1", "jane@example.org")))
+	require.NoError(t, runner.RunPure(ctx, auth.ChangePassword("user-1", "n3w")))
+
+	// When/Then - only the current credentials log in
+	assert.ErrorIs(t, runner.RunPure(ctx, auth.Login("s1", "jane@example.com", "n3w")), auth.ErrInvalidCredentials)
+	assert.ErrorIs(t, runner.RunPure(ctx, auth.Login("s2", "jane@example.org", "s3cret")), auth.ErrInvalidCredentials)
+	login := auth.Login("s3", "jane@example.org", "n3w")
+	require.NoError(t, runner.RunPure(ctx, login))
+	assert.Equal(t, "user-1", login.UserId())
+
+	// Then - the released email can be registered again
+	assert.NoError(t, runner.RunPure(ctx, auth.Register("user-2", "jane@example.com", "other")))
+}
+
+func TestTokens_RejectExpiredAndForgedTokens(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	tokens := newTokens(t)
+	expiring := newTokens(t, auth.WithTokenTTL(time.Nanosecond))
+	forger, err := auth.NewTokens([]byte("other-secret"))
+	require.NoError(t, err)
+
+	valid, err := tokens.Issue("user-1", "session-1")
+	require.NoError(t, err)
+	expired, err := expiring.Issue("user-1", "")
+	require.NoError(t, err)
+	forged, err := forger.Issue("user-1", "")
+	require.NoError(t, err)
+	time.Sleep(time.Second) // expirations are in seconds
+
+	// When
+	claims, validErr := tokens.Validate(valid)
+	_, expiredErr := tokens.Validate(expired)
+	_, forgedErr := tokens.Validate(forged)
+
+	// Then
+	require.NoError(t, validErr)
+	assert.Equal(t, "user-1", claims.UserId)
+	assert.Equal(t, "session-1", claims.SessionId)
+	assert.ErrorIs(t, expiredErr, auth.ErrInvalidToken)
+	assert.ErrorIs(t, forgedErr, auth.ErrInvalidToken)
+}
+
+func mustHash(t *testing.T, password string) string {
+	hashed, err := auth.HashPassword(password)
+	require.NoError(t, err)
+	return hashed
+}
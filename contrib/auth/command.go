@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/err0r500/fairway"
+)
+
+var (
+	// ErrUserExists is returned when registering a user id already taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrEmailTaken is returned when registering or changing to an email another user logs in with
+	ErrEmailTaken = errors.New("email already taken")
+	// ErrInvalidCredentials is returned by Login for unknown emails and wrong passwords alike
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Register returns the command registering the credentials of a new user.
+// The password is hashed once, here: retries of the command don't hash it again.
+func Register(userId, email, password string) fairway.Command {
+	hashed, err := HashPassword(password)
+	return registerCommand{userId: userId, email: email, hashedPassword: hashed, hashErr: err}
+}
+
+type registerCommand struct {
+	userId         string
+	email          string
+	hashedPassword string
+	hashErr        error
+}
+
+func (cmd registerCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	if cmd.hashErr != nil {
+		return cmd.hashErr
+	}
+
+	if _, err := ReadCredentials(ctx, ev, cmd.userId); err == nil {
+		return ErrUserExists
+	} else if !errors.Is(err, ErrUnknownUser) {
+		return err
+	}
+	if owner, err := emailOwner(ctx, ev, cmd.email); err != nil {
+		return err
+	} else if owner != "" {
+		return ErrEmailTaken
+	}
+
+	return ev.AppendEvents(ctx, fairway.NewEvent(UserRegistered{
+		UserId:         cmd.userId,
+		Email:          cmd.email,
+		HashedPassword: cmd.hashedPassword,
+	}))
+}
+
+// ChangeEmail returns the command moving the login of the user to email
+func ChangeEmail(userId, email string) fairway.Command {
+	return changeEmailCommand{userId: userId, email: email}
+}
+
+type changeEmailCommand struct {
+	userId string
+	email  string
+}
+
+func (cmd changeEmailCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	creds, err := ReadCredentials(ctx, ev, cmd.userId)
+	if err != nil {
+		return err
+	}
+	if creds.Email == cmd.email {
+		return nil
+	}
+	if owner, err := emailOwner(ctx, ev, cmd.email); err != nil {
+		return err
+	} else if owner != "" {
+		return ErrEmailTaken
+	}
+
+	return ev.AppendEvents(ctx, fairway.NewEvent(EmailChanged{
+		UserId:        cmd.userId,
+		PreviousEmail: creds.Email,
+		NewEmail:      cmd.email,
+	}))
+}
+
+// ChangePassword returns the command replacing the password of the user, hashed once like Register's.
+// The user's sessions stay active: log them out to revoke their tokens.
+func ChangePassword(userId, password string) fairway.Command {
+	hashed, err := HashPassword(password)
+	return changePasswordCommand{userId: userId, hashedPassword: hashed, hashErr: err}
+}
+
+type changePasswordCommand struct {
+	userId         string
+	hashedPassword string
+	hashErr        error
+}
+
+func (cmd changePasswordCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	if cmd.hashErr != nil {
+		return cmd.hashErr
+	}
+	if _, err := ReadCredentials(ctx, ev, cmd.userId); err != nil {
+		return err
+	}
+
+	return ev.AppendEvents(ctx, fairway.NewEvent(PasswordChanged{
+		UserId:         cmd.userId,
+		HashedPassword: cmd.hashedPassword,
+	}))
+}
+
+// Login returns the command starting the session sessionId (e.g. a random UUID) if password is that of
+// the user logging in with email, ErrInvalidCredentials otherwise.
+// Once run, issue the session's token for its UserId with Tokens.Issue.
+func Login(sessionId, email, password string) *LoginCommand {
+	return &LoginCommand{sessionId: sessionId, email: email, password: password}
+}
+
+// LoginCommand is the command returned by Login
+type LoginCommand struct {
+	sessionId string
+	email     string
+	password  string
+
+	userId string // set once run
+}
+
+// UserId returns the user who logged in, empty until the command ran successfully
+func (cmd *LoginCommand) UserId() string {
+	return cmd.userId
+}
+
+func (cmd *LoginCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	creds, err := ReadCredentialsByEmail(ctx, ev, cmd.email)
+	if errors.Is(err, ErrUnknownUser) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+	if !PasswordMatches(creds.HashedPassword, cmd.password) {
+		return ErrInvalidCredentials
+	}
+
+	cmd.userId = creds.UserId
+	return ev.AppendEvents(ctx, fairway.NewEvent(LoggedIn{UserId: creds.UserId, SessionId: cmd.sessionId}))
+}
+
+// Logout returns the command ending the session, revoking its tokens where Middleware checks sessions.
+// Ending a session already ended does nothing.
+func Logout(sessionId string) fairway.Command {
+	return logoutCommand{sessionId: sessionId}
+}
+
+type logoutCommand struct {
+	sessionId string
+}
+
+func (cmd logoutCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	session, err := ReadSession(ctx, ev, cmd.sessionId)
+	if err != nil {
+		return err
+	}
+	if !session.Active {
+		return nil
+	}
+
+	return ev.AppendEvents(ctx, fairway.NewEvent(LoggedOut{UserId: session.UserId, SessionId: session.SessionId}))
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/err0r500/fairway"
+)
+
+var (
+	// ErrUnknownUser is returned for users that never registered
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrUnknownSession is returned for sessions that never started
+	ErrUnknownSession = errors.New("unknown session")
+)
+
+// Credentials is the current login of a user
+type Credentials struct {
+	UserId         string
+	Email          string
+	HashedPassword string
+}
+
+// Session is the state of a session started by Login
+type Session struct {
+	SessionId string
+	UserId    string
+	Active    bool // false once logged out
+}
+
+// ReadCredentials returns the credentials of the user, ErrUnknownUser if they never registered
+func ReadCredentials(ctx context.Context, reader fairway.EventsReader, userId string) (Credentials, error) {
+	var creds *Credentials
+	if err := reader.ReadEvents(ctx,
+		fairway.QueryItems(
+			fairway.NewQueryItem().
+				Types(UserRegistered{}, EmailChanged{}, PasswordChanged{}).
+				Tags(UserIdTag(userId)),
+		),
+		func(e fairway.Event) bool {
+			switch data := e.Data.(type) {
+			case UserRegistered:
+				creds = &Credentials{UserId: data.UserId, Email: data.Email, HashedPassword: data.HashedPassword}
+			case EmailChanged:
+				if creds != nil {
+					creds.Email = data.NewEmail
+				}
+			case PasswordChanged:
+				if creds != nil {
+					creds.HashedPassword = data.HashedPassword
+				}
+			}
+			return true
+		}); err != nil {
+		return Credentials{}, err
+	}
+
+	if creds == nil {
+		return Credentials{}, ErrUnknownUser
+	}
+	return *creds, nil
+}
+
+// ReadCredentialsByEmail returns the credentials of the user currently logging in with email,
+// ErrUnknownUser if there is none
+func ReadCredentialsByEmail(ctx context.Context, reader fairway.EventsReader, email string) (Credentials, error) {
+	userId, err := emailOwner(ctx, reader, email)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if userId == "" {
+		return Credentials{}, ErrUnknownUser
+	}
+	return ReadCredentials(ctx, reader, userId)
+}
+
+// emailOwner returns the user currently logging in with email, empty if none
+func emailOwner(ctx context.Context, reader fairway.EventsReader, email string) (string, error) {
+	owner := ""
+	err := reader.ReadEvents(ctx,
+		fairway.QueryItems(
+			fairway.NewQueryItem().
+				Types(UserRegistered{}, EmailChanged{}).
+				Tags(EmailTag(email)),
+		),
+		func(e fairway.Event) bool {
+			switch data := e.Data.(type) {
+			case UserRegistered:
+				owner = data.UserId
+			case EmailChanged:
+				if data.NewEmail == email {
+					owner = data.UserId
+				} else if owner == data.UserId {
+					owner = "" // released
+				}
+			}
+			return true
+		})
+	return owner, err
+}
+
+// ReadSession returns the state of the session, ErrUnknownSession if it never started
+func ReadSession(ctx context.Context, reader fairway.EventsReader, sessionId string) (Session, error) {
+	var session *Session
+	if err := reader.ReadEvents(ctx,
+		fairway.QueryItems(
+			fairway.NewQueryItem().
+				Types(LoggedIn{}, LoggedOut{}).
+				Tags(SessionIdTag(sessionId)),
+		),
+		func(e fairway.Event) bool {
+			switch data := e.Data.(type) {
+			case LoggedIn:
+				session = &Session{SessionId: data.SessionId, UserId: data.UserId, Active: true}
+			case LoggedOut:
+				session = &Session{SessionId: data.SessionId, UserId: data.UserId, Active: false}
+			}
+			return false
+		},
+		fairway.ReadLatest(1)); err != nil {
+		return Session{}, err
+	}
+
+	if session == nil {
+		return Session{}, ErrUnknownSession
+	}
+	return *session, nil
+}
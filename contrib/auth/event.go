@@ -0,0 +1,86 @@
+package auth
+
+import "github.com/err0r500/fairway/dcb"
+
+var (
+	userIdTag    = dcb.TagKey("user_id")
+	emailTag     = dcb.TagKey("email")
+	sessionIdTag = dcb.TagKey("session_id")
+)
+
+func UserIdTag(id string) string {
+	return userIdTag.Equals(id)
+}
+
+func EmailTag(email string) string {
+	return emailTag.Equals(email)
+}
+
+func SessionIdTag(id string) string {
+	return sessionIdTag.Equals(id)
+}
+
+// The events of this package are named "auth.<struct name>" whatever the naming strategy of the application,
+// so that they don't collide with its own events (an application's UserRegistered carrying a profile, for instance).
+
+// UserRegistered records the credentials of a new user
+type UserRegistered struct {
+	UserId         string `json:"userId"`
+	Email          string `json:"email"`
+	HashedPassword string `json:"hashedPassword"`
+}
+
+func (UserRegistered) TypeString() string { return "auth.UserRegistered" }
+
+func (e UserRegistered) Tags() []string {
+	return []string{UserIdTag(e.UserId), EmailTag(e.Email)}
+}
+
+// EmailChanged moves the login of a user to another email, releasing the previous one
+type EmailChanged struct {
+	UserId        string `json:"userId"`
+	PreviousEmail string `json:"previousEmail"`
+	NewEmail      string `json:"newEmail"`
+}
+
+func (EmailChanged) TypeString() string { return "auth.EmailChanged" }
+
+func (e EmailChanged) Tags() []string {
+	return []string{UserIdTag(e.UserId), EmailTag(e.PreviousEmail), EmailTag(e.NewEmail)}
+}
+
+// PasswordChanged replaces the password of a user
+type PasswordChanged struct {
+	UserId         string `json:"userId"`
+	HashedPassword string `json:"hashedPassword"`
+}
+
+func (PasswordChanged) TypeString() string { return "auth.PasswordChanged" }
+
+func (e PasswordChanged) Tags() []string {
+	return []string{UserIdTag(e.UserId)}
+}
+
+// LoggedIn starts a session: tokens issued for it are valid until LoggedOut
+type LoggedIn struct {
+	UserId    string `json:"userId"`
+	SessionId string `json:"sessionId"`
+}
+
+func (LoggedIn) TypeString() string { return "auth.LoggedIn" }
+
+func (e LoggedIn) Tags() []string {
+	return []string{UserIdTag(e.UserId), SessionIdTag(e.SessionId)}
+}
+
+// LoggedOut ends a session, revoking its tokens
+type LoggedOut struct {
+	UserId    string `json:"userId"`
+	SessionId string `json:"sessionId"`
+}
+
+func (LoggedOut) TypeString() string { return "auth.LoggedOut" }
+
+func (e LoggedOut) Tags() []string {
+	return []string{UserIdTag(e.UserId), SessionIdTag(e.SessionId)}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/utils"
+	"github.com/google/uuid"
+)
+
+type registerReq struct {
+	Id       string `json:"id" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type loginReq struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse is the body of POST /auth/login
+type LoginResponse struct {
+	Token string `json:"token"`
+}
+
+// RegisterCommands returns the function registering the authentication endpoints, with tokens issued by tokens:
+//   - POST /auth/register {id, email, password}: 201 Created, 409 Conflict if the id or email is taken
+//   - POST /auth/login {email, password}: LoginResponse, the token of a new session; 401 Unauthorized otherwise
+//   - POST /auth/logout: 204 No Content, ends the session of the request's token
+func RegisterCommands(tokens Tokens) func(*fairway.HttpChangeRegistry) {
+	return func(registry *fairway.HttpChangeRegistry) {
+		registry.RegisterCommand("POST /auth/register", registerHandler,
+			fairway.WithRouteSummary("registers the credentials of a new user"),
+			fairway.WithRouteCommands(registerCommand{}),
+			fairway.WithRouteRequest(registerReq{}),
+		)
+		registry.RegisterCommand("POST /auth/login", loginHandler(tokens),
+			fairway.WithRouteSummary("starts a session"),
+			fairway.WithRouteCommands(&LoginCommand{}),
+			fairway.WithRouteRequest(loginReq{}),
+			fairway.WithRouteResponse(LoginResponse{}),
+		)
+		registry.RegisterCommand("POST /auth/logout", logoutHandler(tokens),
+			fairway.WithRouteSummary("ends the session of the token"),
+			fairway.WithRouteCommands(logoutCommand{}),
+		)
+	}
+}
+
+func registerHandler(runner fairway.CommandRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req registerReq
+		if err := utils.JsonParse(r, &req); err != nil {
+			fairway.WriteError(w, err)
+			return
+		}
+
+		if err := runner.RunPure(r.Context(), Register(req.Id, req.Email, req.Password)); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func loginHandler(tokens Tokens) func(fairway.CommandRunner) http.HandlerFunc {
+	return func(runner fairway.CommandRunner) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req loginReq
+			if err := utils.JsonParse(r, &req); err != nil {
+				fairway.WriteError(w, err)
+				return
+			}
+
+			sessionId := uuid.NewString()
+			cmd := Login(sessionId, req.Email, req.Password)
+			if err := runner.RunPure(r.Context(), cmd); err != nil {
+				writeError(w, err)
+				return
+			}
+			token, err := tokens.Issue(cmd.UserId(), sessionId)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(LoginResponse{Token: token})
+		}
+	}
+}
+
+func logoutHandler(tokens Tokens) func(fairway.CommandRunner) http.HandlerFunc {
+	return func(runner fairway.CommandRunner) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, err := tokens.FromRequest(r)
+			if err == nil && claims.SessionId == "" {
+				err = fmt.Errorf("%w: no session", ErrInvalidToken)
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+
+			if err := runner.RunPure(r.Context(), Logout(claims.SessionId)); err != nil {
+				writeError(w, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// writeError answers the errors of this package with their status, others as fairway.WriteError
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrInvalidCredentials):
+		fairway.WriteProblem(w, fairway.NewProblem(http.StatusUnauthorized, ""))
+	case errors.Is(err, ErrUserExists), errors.Is(err, ErrEmailTaken):
+		fairway.WriteProblem(w, fairway.NewProblem(http.StatusConflict, err.Error()))
+	case errors.Is(err, ErrUnknownUser), errors.Is(err, ErrUnknownSession):
+		fairway.WriteProblem(w, fairway.NewProblem(http.StatusNotFound, err.Error()))
+	default:
+		fairway.WriteError(w, err)
+	}
+}
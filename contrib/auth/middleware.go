@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/err0r500/fairway"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims, see ClaimsFrom
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFrom returns the claims of the request authenticated by Middleware
+func ClaimsFrom(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// Middleware authenticates requests with the token of their Authorization header (see Tokens.FromRequest),
+// and passes the claims to next in the request context (see ClaimsFrom). Requests without a valid token
+// are answered 401 Unauthorized.
+//
+// With a reader, tokens issued for a session are only accepted while the session is active (see Logout).
+// This costs a read per request: use a fairway.NewCachedReader to trade some revocation delay for memory hits.
+func Middleware(tokens Tokens, reader fairway.EventsReader) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := tokens.FromRequest(r)
+			if err == nil && claims.SessionId != "" && reader != nil {
+				err = checkSession(r.Context(), reader, claims)
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// checkSession fails with ErrInvalidToken unless the session of claims is active and belongs to their user
+func checkSession(ctx context.Context, reader fairway.EventsReader, claims Claims) error {
+	session, err := ReadSession(ctx, reader, claims.SessionId)
+	if errors.Is(err, ErrUnknownSession) || (err == nil && (!session.Active || session.UserId != claims.UserId)) {
+		return fmt.Errorf("%w: session ended", ErrInvalidToken)
+	}
+	return err
+}
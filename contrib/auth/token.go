@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for missing, malformed, expired or forged tokens
+var ErrInvalidToken = errors.New("invalid token")
+
+// defaultTokenTTL is how long tokens are valid by default
+const defaultTokenTTL = 24 * time.Hour
+
+// Claims identify the user a token was issued to
+type Claims struct {
+	UserId    string
+	SessionId string // empty for tokens issued outside of a session (see Login)
+	ExpiresAt time.Time
+}
+
+// Tokens issues and validates JWTs signed with HMAC-SHA256
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// TokensOption configures Tokens
+type TokensOption func(*Tokens)
+
+// WithTokenTTL sets how long issued tokens are valid (default: 24h)
+func WithTokenTTL(d time.Duration) TokensOption {
+	return func(t *Tokens) {
+		if d > 0 {
+			t.ttl = d
+		}
+	}
+}
+
+// NewTokens returns tokens signed with secret, which must not be empty
+func NewTokens(secret []byte, opts ...TokensOption) (Tokens, error) {
+	if len(secret) == 0 {
+		return Tokens{}, errors.New("auth: no token secret provided")
+	}
+	t := Tokens{secret: secret, ttl: defaultTokenTTL}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t, nil
+}
+
+// Issue returns a token for the user, bound to sessionId if not empty
+func (t Tokens) Issue(userId, sessionId string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userId,
+		"exp":     time.Now().Add(t.ttl).Unix(),
+	}
+	if sessionId != "" {
+		claims["session_id"] = sessionId
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// Validate returns the claims of token, ErrInvalidToken if it wasn't issued by t or expired
+func (t Tokens) Validate(token string) (Claims, error) {
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (any, error) {
+		return t.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mapClaims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+	userId, ok := mapClaims["user_id"].(string)
+	if !ok || userId == "" {
+		return Claims{}, fmt.Errorf("%w: no user", ErrInvalidToken)
+	}
+	sessionId, _ := mapClaims["session_id"].(string)
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return Claims{UserId: userId, SessionId: sessionId, ExpiresAt: exp.Time}, nil
+}
+
+// FromRequest validates the token of the Authorization header, "Token <jwt>" or "Bearer <jwt>"
+func (t Tokens) FromRequest(r *http.Request) (Claims, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || (scheme != "Token" && scheme != "Bearer") {
+		return Claims{}, fmt.Errorf("%w: missing or malformed authorization header", ErrInvalidToken)
+	}
+	return t.Validate(token)
+}
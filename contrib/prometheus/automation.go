@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/err0r500/fairway"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// AutomationMetrics are the collectors of the automations of a process. Pass them to fairway.WithAutomationMetrics:
+// each automation records through ForNamespace, with the namespace of its store as "namespace" label.
+type AutomationMetrics struct {
+	enqueueDuration *prom.HistogramVec // namespace, queue_id, status
+	enqueuedEvents  *prom.CounterVec   // namespace, queue_id
+}
+
+// NewAutomationMetrics creates the collectors and registers them with reg (e.g. prometheus.DefaultRegisterer)
+func NewAutomationMetrics(reg prom.Registerer, opts ...Option) (*AutomationMetrics, error) {
+	s := settings{prefix: defaultPrefix, buckets: prom.ExponentialBuckets(0.001, 2, 15)}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	m := &AutomationMetrics{
+		enqueueDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    s.prefix + "_automation_enqueue_duration_seconds",
+			Help:    "Duration of watcher polls, by namespace, queue and status (success or error)",
+			Buckets: s.buckets,
+		}, []string{"namespace", "queue_id", "status"}),
+		enqueuedEvents: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_automation_events_enqueued_total",
+			Help: "Events enqueued by watcher polls, by namespace and queue",
+		}, []string{"namespace", "queue_id"}),
+	}
+	for _, c := range []prom.Collector{m.enqueueDuration, m.enqueuedEvents} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ForNamespace returns the metrics of the automations of namespace (see fairway.NamespaceAutomationMetrics)
+func (m *AutomationMetrics) ForNamespace(namespace string) fairway.AutomationMetrics {
+	return namespaceAutomationMetrics{m: m, namespace: namespace}
+}
+
+// RecordEnqueueDuration and RecordEnqueuedEvents record with an empty namespace,
+// for automations that don't call ForNamespace (e.g. behind a wrapper of the metrics)
+func (m *AutomationMetrics) RecordEnqueueDuration(queueId string, d time.Duration, success bool) {
+	m.ForNamespace("").RecordEnqueueDuration(queueId, d, success)
+}
+
+func (m *AutomationMetrics) RecordEnqueuedEvents(queueId string, count int) {
+	m.ForNamespace("").RecordEnqueuedEvents(queueId, count)
+}
+
+// namespaceAutomationMetrics records the metrics of the automations of a namespace
+type namespaceAutomationMetrics struct {
+	m         *AutomationMetrics
+	namespace string
+}
+
+var _ fairway.NamespaceAutomationMetrics = (*AutomationMetrics)(nil)
+
+func (n namespaceAutomationMetrics) RecordEnqueueDuration(queueId string, d time.Duration, success bool) {
+	n.m.enqueueDuration.WithLabelValues(n.namespace, queueId, status(success)).Observe(d.Seconds())
+}
+
+func (n namespaceAutomationMetrics) RecordEnqueuedEvents(queueId string, count int) {
+	n.m.enqueuedEvents.WithLabelValues(n.namespace, queueId).Add(float64(count))
+}
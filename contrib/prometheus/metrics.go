@@ -0,0 +1,200 @@
+// Package prometheus records the metrics of dcb stores as Prometheus collectors labeled by namespace,
+// so that the stores of a multi-tenant process (see fairway.TenantStoreFactory) can be told apart.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultPrefix is the prefix of the metric names by default
+const defaultPrefix = "dcb"
+
+// StoreMetrics are the collectors of the stores of a process. Pass them to dcb.StoreOptions.WithMetrics:
+// each store records through ForNamespace, with its namespace as "namespace" label.
+type StoreMetrics struct {
+	appendDuration *prom.HistogramVec // namespace, status
+	appendedEvents *prom.CounterVec   // namespace
+	readDuration   *prom.HistogramVec // namespace, status
+	readEvents     *prom.CounterVec   // namespace
+	errors         *prom.CounterVec   // namespace, operation, type
+	conflicts      *prom.CounterVec   // namespace, query_shape
+	inFlight       *prom.GaugeVec     // namespace
+	queueWait      *prom.HistogramVec // namespace
+	eventCache     *prom.CounterVec   // namespace, result
+}
+
+// Option configures StoreMetrics
+type Option func(*settings)
+
+type settings struct {
+	prefix  string
+	buckets []float64
+}
+
+// WithPrefix sets the prefix of the metric names (default: "dcb", e.g. dcb_append_duration_seconds)
+func WithPrefix(prefix string) Option {
+	return func(s *settings) {
+		if prefix != "" {
+			s.prefix = prefix
+		}
+	}
+}
+
+// WithBuckets sets the buckets of the duration histograms, in seconds (default: 1ms to ~16s, doubling)
+func WithBuckets(buckets []float64) Option {
+	return func(s *settings) {
+		if len(buckets) > 0 {
+			s.buckets = buckets
+		}
+	}
+}
+
+// NewStoreMetrics creates the collectors and registers them with reg (e.g. prometheus.DefaultRegisterer).
+// The series of a namespace appear with the first store of that namespace.
+func NewStoreMetrics(reg prom.Registerer, opts ...Option) (*StoreMetrics, error) {
+	s := settings{prefix: defaultPrefix, buckets: prom.ExponentialBuckets(0.001, 2, 15)}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	m := &StoreMetrics{
+		appendDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    s.prefix + "_append_duration_seconds",
+			Help:    "Duration of appends, by namespace and status (success or error)",
+			Buckets: s.buckets,
+		}, []string{"namespace", "status"}),
+		appendedEvents: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_events_appended_total",
+			Help: "Events appended, by namespace",
+		}, []string{"namespace"}),
+		readDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    s.prefix + "_read_duration_seconds",
+			Help:    "Duration of reads, by namespace and status (success or error)",
+			Buckets: s.buckets,
+		}, []string{"namespace", "status"}),
+		readEvents: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_events_read_total",
+			Help: "Events read, by namespace",
+		}, []string{"namespace"}),
+		errors: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_errors_total",
+			Help: "Failed operations, by namespace, operation and error type",
+		}, []string{"namespace", "operation", "type"}),
+		conflicts: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_append_conflicts_total",
+			Help: "Append conditions that failed, by namespace and query shape (see dcb.QueryShape)",
+		}, []string{"namespace", "query_shape"}),
+		inFlight: prom.NewGaugeVec(prom.GaugeOpts{
+			Name: s.prefix + "_in_flight_transactions",
+			Help: "Transactions in flight, by namespace",
+		}, []string{"namespace"}),
+		queueWait: prom.NewHistogramVec(prom.HistogramOpts{
+			Name:    s.prefix + "_queue_wait_duration_seconds",
+			Help:    "Time waited for a transaction slot, by namespace",
+			Buckets: s.buckets,
+		}, []string{"namespace"}),
+		eventCache: prom.NewCounterVec(prom.CounterOpts{
+			Name: s.prefix + "_event_cache_lookups_total",
+			Help: "Event cache lookups, by namespace and result (hit or miss)",
+		}, []string{"namespace", "result"}),
+	}
+	for _, c := range m.collectors() {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *StoreMetrics) collectors() []prom.Collector {
+	return []prom.Collector{
+		m.appendDuration, m.appendedEvents, m.readDuration, m.readEvents,
+		m.errors, m.conflicts, m.inFlight, m.queueWait, m.eventCache,
+	}
+}
+
+// ForNamespace returns the metrics of the store of namespace (see dcb.NamespaceMetrics)
+func (m *StoreMetrics) ForNamespace(namespace string) dcb.Metrics {
+	return namespaceMetrics{m: m, namespace: namespace}
+}
+
+// RecordAppendDuration and the other dcb.Metrics methods record with an empty namespace,
+// for stores that don't call ForNamespace (e.g. behind a wrapper of the metrics)
+func (m *StoreMetrics) RecordAppendDuration(d time.Duration, success bool) {
+	m.ForNamespace("").RecordAppendDuration(d, success)
+}
+
+func (m *StoreMetrics) RecordAppendEvents(count int) { m.ForNamespace("").RecordAppendEvents(count) }
+
+func (m *StoreMetrics) RecordReadDuration(d time.Duration, success bool) {
+	m.ForNamespace("").RecordReadDuration(d, success)
+}
+
+func (m *StoreMetrics) RecordReadEvents(count int) { m.ForNamespace("").RecordReadEvents(count) }
+
+func (m *StoreMetrics) RecordError(operation, errorType string) {
+	m.ForNamespace("").RecordError(operation, errorType)
+}
+
+// namespaceMetrics records the metrics of a namespace
+type namespaceMetrics struct {
+	m         *StoreMetrics
+	namespace string
+}
+
+var (
+	_ dcb.NamespaceMetrics   = (*StoreMetrics)(nil)
+	_ dcb.ConflictMetrics    = namespaceMetrics{}
+	_ dcb.ConcurrencyMetrics = namespaceMetrics{}
+	_ dcb.EventCacheMetrics  = namespaceMetrics{}
+)
+
+func status(success bool) string {
+	if success {
+		return "success"
+	}
+	return "error"
+}
+
+func (n namespaceMetrics) RecordAppendDuration(d time.Duration, success bool) {
+	n.m.appendDuration.WithLabelValues(n.namespace, status(success)).Observe(d.Seconds())
+}
+
+func (n namespaceMetrics) RecordAppendEvents(count int) {
+	n.m.appendedEvents.WithLabelValues(n.namespace).Add(float64(count))
+}
+
+func (n namespaceMetrics) RecordReadDuration(d time.Duration, success bool) {
+	n.m.readDuration.WithLabelValues(n.namespace, status(success)).Observe(d.Seconds())
+}
+
+func (n namespaceMetrics) RecordReadEvents(count int) {
+	n.m.readEvents.WithLabelValues(n.namespace).Add(float64(count))
+}
+
+func (n namespaceMetrics) RecordError(operation, errorType string) {
+	n.m.errors.WithLabelValues(n.namespace, operation, errorType).Inc()
+}
+
+func (n namespaceMetrics) RecordAppendConflict(queryShape string) {
+	n.m.conflicts.WithLabelValues(n.namespace, queryShape).Inc()
+}
+
+func (n namespaceMetrics) RecordInFlight(count int) {
+	n.m.inFlight.WithLabelValues(n.namespace).Set(float64(count))
+}
+
+func (n namespaceMetrics) RecordQueueWait(d time.Duration) {
+	n.m.queueWait.WithLabelValues(n.namespace).Observe(d.Seconds())
+}
+
+func (n namespaceMetrics) RecordEventCacheHit() {
+	n.m.eventCache.WithLabelValues(n.namespace, "hit").Inc()
+}
+
+func (n namespaceMetrics) RecordEventCacheMiss() {
+	n.m.eventCache.WithLabelValues(n.namespace, "miss").Inc()
+}
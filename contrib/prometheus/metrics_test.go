@@ -0,0 +1,100 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	fairwayprom "github.com/err0r500/fairway/contrib/prometheus"
+	"github.com/err0r500/fairway/dcb"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterValue returns the value of the counter name with the given labels, 0 if it has no such series
+func counterValue(t *testing.T, reg *prom.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestStoreMetrics_LabelsTheSeriesOfEachNamespace(t *testing.T) {
+	t.Parallel()
+
+	// Given - two stores sharing the metrics, as the tenants of a process
+	ctx := context.Background()
+	reg := prom.NewRegistry()
+	metrics, err := fairwayprom.NewStoreMetrics(reg)
+	require.NoError(t, err)
+	first, second := dcb.SetupTestStore(t), dcb.SetupTestStore(t)
+	dcb.StoreOptions{}.WithMetrics(metrics)(first)
+	dcb.StoreOptions{}.WithMetrics(metrics)(second)
+
+	// When
+	require.NoError(t, first.Append(ctx, []dcb.Event{{Type: "item_added"}, {Type: "item_added"}}))
+	require.NoError(t, second.Append(ctx, []dcb.Event{{Type: "item_added"}}))
+	condition := dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}}
+	conflictErr := second.Append(ctx, []dcb.Event{{Type: "item_added"}}, condition)
+
+	// Then
+	assert.True(t, errors.Is(conflictErr, dcb.ErrAppendConditionFailed))
+	assert.Equal(t, 2.0, counterValue(t, reg, "dcb_events_appended_total", map[string]string{"namespace": first.Namespace()}))
+	assert.Equal(t, 1.0, counterValue(t, reg, "dcb_events_appended_total", map[string]string{"namespace": second.Namespace()}))
+	assert.Equal(t, 0.0, counterValue(t, reg, "dcb_append_conflicts_total", map[string]string{"namespace": first.Namespace()}))
+	assert.Equal(t, 1.0, counterValue(t, reg, "dcb_append_conflicts_total", map[string]string{
+		"namespace": second.Namespace(), "query_shape": "item_added",
+	}))
+}
+
+func TestStoreMetrics_RegisterOncePerPrefix(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	reg := prom.NewRegistry()
+	_, err := fairwayprom.NewStoreMetrics(reg)
+	require.NoError(t, err)
+
+	// When
+	_, sameErr := fairwayprom.NewStoreMetrics(reg)
+	_, otherErr := fairwayprom.NewStoreMetrics(reg, fairwayprom.WithPrefix("archive_dcb"))
+
+	// Then
+	var already prom.AlreadyRegisteredError
+	assert.ErrorAs(t, sameErr, &already)
+	assert.NoError(t, otherErr)
+}
+
+func TestAutomationMetrics_LabelsTheSeriesOfEachNamespace(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	reg := prom.NewRegistry()
+	metrics, err := fairwayprom.NewAutomationMetrics(reg)
+	require.NoError(t, err)
+
+	// When - the automations of two tenants record through their namespace
+	metrics.ForNamespace("tenant-a").RecordEnqueuedEvents("send_welcome", 2)
+	metrics.ForNamespace("tenant-b").RecordEnqueuedEvents("send_welcome", 1)
+	metrics.ForNamespace("tenant-b").RecordEnqueueDuration("send_welcome", time.Millisecond, true)
+
+	// Then
+	assert.Equal(t, 2.0, counterValue(t, reg, "dcb_automation_events_enqueued_total", map[string]string{"namespace": "tenant-a", "queue_id": "send_welcome"}))
+	assert.Equal(t, 1.0, counterValue(t, reg, "dcb_automation_events_enqueued_total", map[string]string{"namespace": "tenant-b", "queue_id": "send_welcome"}))
+}
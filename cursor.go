@@ -0,0 +1,66 @@
+package fairway
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// CursorInfo describes the cursor of an automation or exporter, telling operators whether it moves
+// and which process moves it
+type CursorInfo struct {
+	Position      *dcb.Versionstamp // last event enqueued or exported, nil before the first one
+	UpdatedAt     time.Time         // when the cursor last moved (zero when it moved before this was tracked)
+	LastEventType string            // type of the event at Position
+	Host          string            // host of the process that last moved the cursor
+}
+
+// processHost is the host reported in the cursors this process moves
+var processHost = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return host
+}()
+
+// setCursorInTx moves the cursor to vs, with its metadata (now, the event type and the host).
+// The metadata lives beside the 12-byte position, so processes not writing it still read the cursor.
+func setCursorInTx(tr fdb.Transaction, cursorKey, metaKey fdb.Key, vs dcb.Versionstamp, eventType string, now time.Time) {
+	tr.Set(cursorKey, vs[:])
+	tr.Set(metaKey, tuple.Tuple{now.UnixNano(), eventType, processHost}.Pack())
+}
+
+// readCursorInfo reads the cursor and its metadata
+func readCursorInfo(db fdb.Database, cursorKey, metaKey fdb.Key) (CursorInfo, error) {
+	res, err := db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		position := tr.Get(cursorKey)
+		meta := tr.Get(metaKey)
+
+		info := CursorInfo{Position: decodeCursor(position.MustGet())}
+		value := meta.MustGet()
+		if value == nil {
+			return info, nil
+		}
+		t, err := tuple.Unpack(value)
+		if err != nil {
+			return nil, fmt.Errorf("cursor metadata: %w", err)
+		}
+		if len(t) != 3 {
+			return nil, fmt.Errorf("cursor metadata: expected 3-tuple, got %d elements", len(t))
+		}
+		updatedNs, _ := t[0].(int64)
+		info.UpdatedAt = time.Unix(0, updatedNs)
+		info.LastEventType, _ = t[1].(string)
+		info.Host, _ = t[2].(string)
+		return info, nil
+	})
+	if err != nil {
+		return CursorInfo{}, err
+	}
+	return res.(CursorInfo), nil
+}
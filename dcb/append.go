@@ -2,16 +2,25 @@ package dcb
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"golang.org/x/sync/errgroup"
 )
 
 // Append atomically appends events with optional condition checking
-// Returns error if condition fails or any other error occurs
+// Returns error if condition fails or any other error occurs.
+// Events of a batch are stored in slice order: they share the transaction version
+// and event i gets i as user version (see Versionstamp.BatchIndex).
 func (s fdbStore) Append(ctx context.Context, events []Event, conditions ...AppendCondition) error {
 	return s.appendInternal(ctx, events, conditions, nil)
 }
@@ -27,32 +36,133 @@ func (s fdbStore) appendInternal(ctx context.Context, events []Event, conditions
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if err := s.checkFormat(); err != nil {
+		return err
+	}
 
 	if len(events) == 0 {
 		s.metrics.RecordError("append", "empty_events")
 		return ErrEmptyEvents
 	}
 
-	start := time.Now()
+	// Run pre-append hooks on a copy so enrichment never leaks into the caller's events
+	if len(s.appendHooks) > 0 {
+		events = cloneEvents(events)
+		for _, hook := range s.appendHooks {
+			if err := hook(ctx, events); err != nil {
+				s.metrics.RecordError("append", "hook_rejected")
+				return fmt.Errorf("append hook: %w", err)
+			}
+		}
+	}
+
+	// Validate condition queries upfront so callers get every problem at once
+	for i, cond := range conditions {
+		if err := cond.Query.Validate(); err != nil {
+			s.metrics.RecordError("append", "invalid_query")
+			return fmt.Errorf("condition %d: %w", i, err)
+		}
+	}
 
 	// Validate events
-	for _, event := range events {
+	for i, event := range events {
 		if event.Type == "" {
 			s.logger.Error("event validation", errors.New("event with empty string type provided"))
 			return errors.New("event must have a type")
 		}
-		// Ensure no duplicate tags
-		tagSet := make(map[string]bool)
 		for _, tag := range event.Tags {
-			if tagSet[tag] {
-				s.logger.Error("event validation", errors.New("event has duplicate tags"))
+			// Enforce canonical tag encoding
+			if err := ValidateTag(tag); err != nil {
+				s.metrics.RecordError("append", "invalid_tag")
+				return fmt.Errorf("event %d: %w", i, err)
+			}
+		}
+		if err := s.checkMetadata(event.Metadata); err != nil {
+			s.metrics.RecordError("append", "invalid_metadata")
+			return fmt.Errorf("event %d: %w", i, err)
+		}
+	}
+
+	// Store tags sorted and without duplicates, as the tag tree indexes them
+	events = canonicalizeTags(events)
+
+	if err := s.checkEventLimits(events); err != nil {
+		s.metrics.RecordError("append", "event_limit_exceeded")
+		return err
+	}
+
+	// Guard against FDB's transaction size limit before hitting an opaque FDB error
+	subsets := tagSubsets(events)
+	sizes, total := s.encodedSizes(events, subsets)
+	if total > s.maxTxBytes || len(events) > MaxEventsPerTransaction {
+		if !s.autoSplit || len(conditions) > 0 {
+			s.metrics.RecordError("append", "transaction_too_large")
+			if len(events) > MaxEventsPerTransaction {
+				// batch indexes would wrap around and break the intra-batch ordering
+				return fmt.Errorf("%w: %w: %d events (limit %d per transaction)",
+					ErrTransactionTooLarge, ErrTooManyEvents, len(events), MaxEventsPerTransaction)
 			}
-			tagSet[tag] = true
+			return fmt.Errorf("%w: %d events encode to %d bytes (limit %d bytes)",
+				ErrTransactionTooLarge, len(events), total, s.maxTxBytes)
+		}
+		return s.commitSplit(ctx, events, subsets, sizes)
+	}
+
+	return s.commit(ctx, events, subsets, conditions, afterQueryHook)
+}
+
+// tagSubsets returns the tag subsets of each event (see generateAllSubsets), computed once per append
+// for both the size estimate and the writes of every attempt
+func tagSubsets(events []Event) [][][]string {
+	subsets := make([][][]string, len(events))
+	for i, event := range events {
+		subsets[i] = generateAllSubsets(event.Tags)
+	}
+	return subsets
+}
+
+// commitSplit commits an unconditional batch across as many transactions as needed.
+// Atomicity only holds per chunk: a failure leaves the earlier chunks committed.
+func (s fdbStore) commitSplit(ctx context.Context, events []Event, subsets [][][]string, sizes []int) error {
+	chunkStart, chunkBytes := 0, 0
+	for i, size := range sizes {
+		if size > s.maxTxBytes {
+			s.metrics.RecordError("append", "transaction_too_large")
+			return fmt.Errorf("%w: event %d alone encodes to %d bytes (limit %d bytes)",
+				ErrTransactionTooLarge, i, size, s.maxTxBytes)
 		}
+		if chunkBytes+size > s.maxTxBytes || i-chunkStart == MaxEventsPerTransaction {
+			if err := s.commit(ctx, events[chunkStart:i], subsets[chunkStart:i], nil, nil); err != nil {
+				return fmt.Errorf("committing events %d to %d: %w", chunkStart, i-1, err)
+			}
+			chunkStart, chunkBytes = i, 0
+		}
+		chunkBytes += size
+	}
+
+	if err := s.commit(ctx, events[chunkStart:], subsets[chunkStart:], nil, nil); err != nil {
+		return fmt.Errorf("committing events %d to %d: %w", chunkStart, len(events)-1, err)
+	}
+	return nil
+}
+
+// commit writes events, with their tag subsets, and checks conditions in a single FDB transaction
+func (s fdbStore) commit(ctx context.Context, events []Event, subsets [][][]string, conditions []AppendCondition, afterQueryHook func(exists bool)) error {
+	release, err := s.acquireSlot(ctx, "append")
+	if err != nil {
+		return err
 	}
+	defer release()
+
+	start := time.Now()
+	var committedAt time.Time
+	recorder := appendedPositionsFrom(ctx)
+	hooks := transactionHooksFrom(ctx)
+	var conflicts []Query // the conditions that failed, in the last attempt
+	var conflictsMu sync.Mutex
 
 	// Execute append in transaction
-	_, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+	res, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
 		// Best-effort check for context cancellation
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -60,6 +170,7 @@ func (s fdbStore) appendInternal(ctx context.Context, events []Event, conditions
 
 		// Check append conditions concurrently if specified
 		if len(conditions) > 0 {
+			conflicts = nil
 			g, _ := errgroup.WithContext(ctx)
 			for _, cond := range conditions {
 				cond := cond
@@ -69,6 +180,9 @@ func (s fdbStore) appendInternal(ctx context.Context, events []Event, conditions
 						return err
 					}
 					if exists {
+						conflictsMu.Lock()
+						conflicts = append(conflicts, cond.Query)
+						conflictsMu.Unlock()
 						return ErrAppendConditionFailed
 					}
 					return nil
@@ -82,13 +196,36 @@ func (s fdbStore) appendInternal(ctx context.Context, events []Event, conditions
 			}
 		}
 
-		// Append each event
+		for _, hook := range hooks {
+			if err := hook.Write(tr); err != nil {
+				return nil, err
+			}
+		}
+
+		// New namespaces get their format with their first events
+		if err := s.recordFormatInTx(tr); err != nil {
+			return nil, err
+		}
+		if s.mirror != nil {
+			if err := s.mirror.recordFormatInTx(tr); err != nil {
+				return nil, err
+			}
+		}
+
+		// Append each event, stamped with the time of this (possibly retried) attempt
+		// (wall clock only, as read back from the store)
+		committedAt = time.Now().Round(0)
 		for i, event := range events {
-			if err := s.appendSingle(tr, event, uint16(i)); err != nil {
+			if err := s.appendSingle(tr, event, subsets[i], uint16(i), committedAt); err != nil {
 				return nil, err
 			}
 		}
 
+		// Committed versionstamp is only needed by post-append hooks and position recorders
+		if len(s.postAppendHooks) > 0 || recorder != nil {
+			return tr.GetVersionstamp(), nil
+		}
+
 		// Transaction commits when Transact returns nil
 		return nil, nil
 	})
@@ -96,45 +233,132 @@ func (s fdbStore) appendInternal(ctx context.Context, events []Event, conditions
 	duration := time.Since(start)
 	success := err == nil
 
+	if traced := s.tracedEvents(events); len(traced) > 0 {
+		s.traceAppend(traced, conditions, err)
+	}
+
 	s.metrics.RecordAppendDuration(duration, success)
+	if errors.Is(err, ErrAppendConditionFailed) {
+		s.recordConflicts(conflicts)
+	}
 	if success {
 		s.metrics.RecordAppendEvents(len(events))
-		s.logger.Info("append completed", "event_count", len(events), "duration", duration)
+		s.logCompleted("append", "append completed", duration, "event_count", len(events))
 	} else {
 		s.logger.Error("append failed", err, "event_count", len(events), "duration", duration)
 	}
 
+	if success {
+		for _, hook := range hooks {
+			if hook.Committed != nil {
+				hook.Committed()
+			}
+		}
+	}
+
+	if success && (len(s.postAppendHooks) > 0 || recorder != nil) {
+		if err := s.notifyCommitted(ctx, events, res.(fdb.FutureKey), committedAt, recorder); err != nil {
+			// the events are stored: only the caller's recorder misses their positions
+			s.logger.Error("resolving committed versionstamp", err)
+		}
+	}
+
 	return err
 }
 
-// appendSingle writes a single event with all its indexes
-func (s fdbStore) appendSingle(tr fdb.Transaction, event Event, batchIndex uint16) error {
+// notifyCommitted resolves the committed versionstamp, records the positions and notifies post-append hooks
+func (s fdbStore) notifyCommitted(ctx context.Context, events []Event, vsFuture fdb.FutureKey, committedAt time.Time, recorder *AppendedPositions) error {
+	txVersion, err := vsFuture.Get()
+	if err != nil {
+		return err
+	}
+
+	stored := make([]StoredEvent, len(events))
+	for i, event := range events {
+		var pos Versionstamp
+		copy(pos[:10], txVersion)
+		binary.BigEndian.PutUint16(pos[10:12], uint16(i))
+		stored[i] = StoredEvent{Event: event, Position: pos, CommittedAt: committedAt}
+	}
+
+	if recorder != nil {
+		positions := make([]Versionstamp, len(stored))
+		for i, ev := range stored {
+			positions[i] = ev.Position
+		}
+		recorder.record(positions)
+	}
+
+	for _, hook := range s.postAppendHooks {
+		hook(ctx, stored)
+	}
+	return nil
+}
+
+// encodedSizes estimates the bytes each event, with its tag subsets, adds to a transaction (keys and values of all indexes)
+func (s fdbStore) encodedSizes(events []Event, subsets [][][]string) ([]int, int) {
+	sizes := make([]int, len(events))
+	total := 0
+	for i, event := range events {
+		size := len(encodeEvent(event, math.MaxInt64)) // the commit time is not known yet, at most 8 bytes
+
+		// Primary and type index keys
+		size += len(s.events.Bytes()) + versionstampKeyOverhead
+		size += len(s.byType.Bytes()) + len(event.Type) + tupleElementOverhead + versionstampKeyOverhead
+
+		// One tag tree key per tag subset
+		for _, subset := range subsets[i] {
+			size += len(s.byTag.Bytes()) + len(eventsInTagSubspace) + len(event.Type) + 2*tupleElementOverhead + versionstampKeyOverhead
+			for _, tag := range subset {
+				size += len(tag) + tupleElementOverhead
+			}
+		}
+		if s.hints != nil {
+			size += s.hints.markersSize(event.Tags)
+		}
+		if s.mirror != nil {
+			mirrored, _ := s.mirror.encodedSizes(events[i:i+1], subsets[i:i+1])
+			size += mirrored[0]
+		}
+
+		sizes[i] = size
+		total += size
+	}
+	return sizes, total
+}
+
+// appendSingle writes a single event with all its indexes, and to the mirror store while migrating (see Migration)
+func (s fdbStore) appendSingle(tr fdb.Transaction, event Event, subsets [][]string, batchIndex uint16, committedAt time.Time) error {
 	// Create incomplete versionstamp
 	vs := tuple.IncompleteVersionstamp(batchIndex)
 
-	// 1. Write primary event storage (encode type, tags, and data together)
-	// Convert []string tags to tuple.Tuple for encoding
-	tagsTuple := make(tuple.Tuple, len(event.Tags))
-	for i, tag := range event.Tags {
-		tagsTuple[i] = tag
+	eventValue := encodeEvent(event, committedAt.UnixNano())
+
+	if err := s.writeEvent(tr, event, subsets, vs, eventValue); err != nil {
+		return err
 	}
-	eventValue := tuple.Tuple{event.Type, tagsTuple, event.Data}.Pack()
-	eventKey, err := s.events.PackWithVersionstamp(tuple.Tuple{vs})
-	if err != nil {
+	if s.mirror != nil {
+		// same batch index in the same transaction: the copy gets the same position
+		return s.mirror.writeEvent(tr, event, subsets, vs, eventValue)
+	}
+	return nil
+}
+
+// writeEvent writes the encoded event at vs with all its indexes, a tag tree key per tag subset.
+// vs is incomplete for appends (set by the commit), complete for events copied at their position.
+func (s fdbStore) writeEvent(tr fdb.Transaction, event Event, subsets [][]string, vs tuple.Versionstamp, eventValue []byte) error {
+	// 1. Write primary event storage
+	if err := setVersionstampKey(tr, s.events, tuple.Tuple{vs}, eventValue); err != nil {
 		return err
 	}
-	tr.SetVersionstampedKey(eventKey, eventValue)
 
 	// 2. Write to type index
-	typeKey, err := s.byType.Sub(event.Type).PackWithVersionstamp(tuple.Tuple{vs})
-	if err != nil {
+	if err := setVersionstampKey(tr, s.byType.Sub(event.Type), tuple.Tuple{vs}, nil); err != nil {
 		return err
 	}
-	tr.SetVersionstampedKey(typeKey, nil)
 
 	// 3. Write to tag tree (all subsets with alphabetical ordering)
 	// Only write tag indexes if event has tags
-	subsets := generateAllSubsets(event.Tags)
 	for _, subset := range subsets {
 		tagPath := make(tuple.Tuple, 0, len(subset)+3)
 		for _, tag := range subset {
@@ -142,18 +366,40 @@ func (s fdbStore) appendSingle(tr fdb.Transaction, event Event, batchIndex uint1
 		}
 		tagPath = append(tagPath, eventsInTagSubspace, event.Type, vs)
 
-		tagKey, err := s.byTag.PackWithVersionstamp(tagPath)
-		if err != nil {
+		if err := setVersionstampKey(tr, s.byTag, tagPath, nil); err != nil {
 			return err
 		}
-		tr.SetVersionstampedKey(tagKey, nil)
+	}
+
+	// 4. Mark the tags for existence hints
+	if s.hints != nil {
+		s.hints.mark(tr, event.Tags)
 	}
 
 	return nil
 }
 
+// setVersionstampKey sets the key of ss for t, a tuple holding a versionstamp:
+// versionstamped by the commit when incomplete, as is when complete
+func setVersionstampKey(tr fdb.Transaction, ss subspace.Subspace, t tuple.Tuple, value []byte) error {
+	incomplete, err := t.HasIncompleteVersionstamp()
+	if err != nil {
+		return err
+	}
+	if !incomplete {
+		tr.Set(ss.Pack(t), value)
+		return nil
+	}
+	key, err := ss.PackWithVersionstamp(t)
+	if err != nil {
+		return err
+	}
+	tr.SetVersionstampedKey(key, value)
+	return nil
+}
+
 // queryExists checks if any events match the query
-func (s fdbStore) queryExists(tr fdb.ReadTransaction, query Query, after *Versionstamp) (bool, error) {
+func (s fdbStore) queryExists(tr fdb.Transaction, query Query, after *Versionstamp) (bool, error) {
 	for _, item := range query.Items {
 		exists, err := s.queryItemExists(tr, item, after)
 		if err != nil {
@@ -167,7 +413,14 @@ func (s fdbStore) queryExists(tr fdb.ReadTransaction, query Query, after *Versio
 }
 
 // queryItemExists checks if any events match a single query item
-func (s fdbStore) queryItemExists(tr fdb.ReadTransaction, item QueryItem, after *Versionstamp) (bool, error) {
+func (s fdbStore) queryItemExists(tr fdb.Transaction, item QueryItem, after *Versionstamp) (bool, error) {
+	if s.hints != nil {
+		ruledOut, err := s.hints.rulesOut(tr, item)
+		if err != nil || ruledOut {
+			return false, err
+		}
+	}
+
 	ranges, err := s.buildQueryRanges(tr, item, after)
 	if err != nil {
 		return false, err
@@ -183,3 +436,60 @@ func (s fdbStore) queryItemExists(tr fdb.ReadTransaction, item QueryItem, after
 
 	return false, nil
 }
+
+// cloneEvents copies events with their tags and metadata, for hooks to modify in place.
+// Data is shared: hooks replace it rather than writing to it.
+func cloneEvents(events []Event) []Event {
+	cloned := slices.Clone(events)
+	for i := range cloned {
+		cloned[i].Tags = slices.Clone(cloned[i].Tags)
+		cloned[i].Metadata = maps.Clone(cloned[i].Metadata)
+	}
+	return cloned
+}
+
+// encodeEvent packs the type, tags, data, commit time (Unix nanoseconds) and metadata of event as a tuple.
+// The metadata is a tuple of its keys and values, sorted by key, left out when empty.
+func encodeEvent(event Event, committedAt int64) []byte {
+	// Convert []string tags to tuple.Tuple for encoding
+	tagsTuple := make(tuple.Tuple, len(event.Tags))
+	for i, tag := range event.Tags {
+		tagsTuple[i] = tag
+	}
+	value := tuple.Tuple{event.Type, tagsTuple, event.Data, committedAt}
+	if len(event.Metadata) == 0 {
+		return value.Pack()
+	}
+	keys := slices.Sorted(maps.Keys(event.Metadata))
+	metadata := make(tuple.Tuple, 0, 2*len(keys))
+	for _, key := range keys {
+		metadata = append(metadata, key, event.Metadata[key])
+	}
+	return append(value, metadata).Pack()
+}
+
+// checkMetadata verifies that metadata can be stored in the namespace
+func (s fdbStore) checkMetadata(metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	version := s.format.version.Load()
+	if version < metadataFormatVersion {
+		// the namespace may have been upgraded by another process since it was checked
+		current, err := s.formatVersion()
+		if err != nil {
+			return fmt.Errorf("reading storage format: %w", err)
+		}
+		version = int64(current)
+		s.format.version.Store(version)
+	}
+	if version < metadataFormatVersion {
+		return fmt.Errorf("%w: storing metadata requires format %d, namespace %q has format %d", ErrUpgradeRequired, metadataFormatVersion, s.namespace, version)
+	}
+	for key := range metadata {
+		if key == "" {
+			return fmt.Errorf("%w: empty key", ErrInvalidMetadata)
+		}
+	}
+	return nil
+}
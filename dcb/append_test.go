@@ -2,10 +2,17 @@ package dcb_test
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 	"github.com/err0r500/fairway/dcb"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"pgregory.net/rapid"
 )
 
@@ -240,6 +247,56 @@ func TestAppendMultipleConditions_SecondFails(tt *testing.T) {
 	})
 }
 
+func TestAppendCondition_PerItemAfter(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - item A was read up to its event, item B's event came later and was never seen
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "A"}}))
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "B"}}))
+	stored := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	posA, posB := stored[0].Position, stored[1].Position
+
+	// When
+	onlyA := store.Append(ctx, []dcb.Event{{Type: "C"}}, dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"A"}, After: &posA},
+		{Types: []string{"B"}, After: &posB},
+	}}})
+	bothFromA := store.Append(ctx, []dcb.Event{{Type: "C"}}, dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"A"}, After: &posA},
+		{Types: []string{"B"}, After: &posA},
+	}}})
+
+	// Then - each item is only checked after its own position
+	assert.NoError(tt, onlyA)
+	assert.ErrorIs(tt, bothFromA, dcb.ErrAppendConditionFailed)
+}
+
+func TestRead_PerItemAfter(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "A"}, {Type: "B"}}))
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "A"}, {Type: "B"}}))
+	first := dcb.CollectEvents(tt, store.ReadAll(ctx))[1].Position
+
+	// When - A items from the start, B items after the first batch
+	events := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"A"}},
+		{Types: []string{"B"}, After: &first},
+	}}, nil))
+
+	// Then
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	assert.Equal(tt, []string{"A", "A", "B"}, types)
+}
+
 func TestAppendNoConditions(tt *testing.T) {
 	tt.Parallel()
 	rapid.Check(tt, func(t *rapid.T) {
@@ -257,3 +314,340 @@ func TestAppendNoConditions(tt *testing.T) {
 		assert.Len(t, storedEvents, 1)
 	})
 }
+
+func TestAppendHook_EnrichesAndRejects(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a hook adding a tag and rejecting events of type "forbidden"
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	errForbidden := errors.New("forbidden")
+	dcb.StoreOptions{}.WithAppendHook(func(_ context.Context, events []dcb.Event) error {
+		for i := range events {
+			if events[i].Type == "forbidden" {
+				return errForbidden
+			}
+			events[i].Tags[0] = "enriched"
+			events[i].Metadata["actor"] = "hook"
+		}
+		return nil
+	})(store)
+
+	// When
+	original := []dcb.Event{{Type: "allowed", Tags: []string{"original"}, Metadata: map[string]string{"actor": "user:42"}}}
+	okErr := store.Append(ctx, original)
+	rejectedErr := store.Append(ctx, []dcb.Event{{Type: "forbidden"}})
+
+	// Then
+	assert.NoError(tt, okErr)
+	assert.ErrorIs(tt, rejectedErr, errForbidden)
+	assert.Equal(tt, []string{"original"}, original[0].Tags, "caller's events are left untouched")
+	assert.Equal(tt, map[string]string{"actor": "user:42"}, original[0].Metadata)
+	storedEvents := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	assert.Len(tt, storedEvents, 1)
+	assert.Equal(tt, []string{"enriched"}, storedEvents[0].Tags)
+	assert.Equal(tt, map[string]string{"actor": "hook"}, storedEvents[0].Metadata)
+}
+
+func TestPostAppendHook_ReceivesAssignedPositions(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		ctx := context.Background()
+		store := dcb.SetupTestStore(tt)
+		var notified []dcb.StoredEvent
+		dcb.StoreOptions{}.WithPostAppendHook(func(_ context.Context, events []dcb.StoredEvent) {
+			notified = append(notified, events...)
+		})(store)
+
+		// When
+		events := dcb.RandomEvents(t)
+		err := store.Append(ctx, events)
+
+		// Then - hook sees exactly what was stored
+		assert.NoError(t, err)
+		assert.Equal(t, dcb.CollectEvents(tt, store.ReadAll(ctx)), notified)
+	})
+}
+
+func TestAppendTooLarge_ReturnsErrTransactionTooLarge(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a store with a tiny transaction budget
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithMaxTransactionBytes(1000)(store)
+
+	// When
+	err := store.Append(ctx, []dcb.Event{{Type: "big", Data: make([]byte, 2000)}})
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrTransactionTooLarge)
+	assert.Empty(tt, dcb.CollectEvents(tt, store.ReadAll(ctx)))
+}
+
+func TestAppendAutoSplit_CommitsUnconditionalBatchInChunks(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	opts := dcb.StoreOptions{}
+	opts.WithMaxTransactionBytes(1000)(store)
+	opts.WithAutoSplit()(store)
+
+	events := make([]dcb.Event, 10)
+	for i := range events {
+		events[i] = dcb.Event{Type: "chunked", Data: make([]byte, 300)}
+	}
+
+	// When
+	err := store.Append(ctx, events)
+	condErr := store.Append(ctx, events, dcb.AppendCondition{
+		Query: dcb.Query{Items: []dcb.QueryItem{{Types: []string{"other"}}}},
+	})
+
+	// Then - unconditional batch is split, conditional one is refused
+	assert.NoError(tt, err)
+	assert.ErrorIs(tt, condErr, dcb.ErrTransactionTooLarge)
+	storedEvents := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	assert.Len(tt, storedEvents, len(events))
+	assert.True(tt, dcb.EventsAreStriclyOrdered(storedEvents))
+}
+
+func TestAppend_RecordsCommitTime(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	before := time.Now()
+
+	// When
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "timed"}, {Type: "timed"}}))
+	after := time.Now()
+
+	// Then - every event of the transaction shares the store's commit time
+	storedEvents := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, storedEvents, 2)
+	assert.Equal(tt, storedEvents[0].CommittedAt, storedEvents[1].CommittedAt)
+	assert.False(tt, storedEvents[0].CommittedAt.Before(before))
+	assert.False(tt, storedEvents[0].CommittedAt.After(after))
+}
+
+func TestAppend_StoresMetadata(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	metadata := map[string]string{"correlation_id": "req-1", "actor": "user:42", "empty": ""}
+
+	// When
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "with_metadata", Tags: []string{"list:1"}, Data: []byte("{}"), Metadata: metadata},
+		{Type: "without_metadata", Tags: []string{"list:1"}},
+	}))
+	emptyKeyErr := store.Append(ctx, []dcb.Event{{Type: "with_metadata", Metadata: map[string]string{"": "x"}}})
+
+	// Then - metadata is read back, whatever the read path
+	for name, events := range map[string][]dcb.StoredEvent{
+		"ReadAll": dcb.CollectEvents(tt, store.ReadAll(ctx)),
+		"Read":    dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"list:1"}}}}, nil)),
+	} {
+		require.Len(tt, events, 2, name)
+		assert.Equal(tt, metadata, events[0].Metadata, name)
+		assert.Equal(tt, []byte("{}"), events[0].Data, name)
+		assert.Nil(tt, events[1].Metadata, name)
+	}
+	assert.ErrorIs(tt, emptyKeyErr, dcb.ErrInvalidMetadata)
+}
+
+func TestRead_EventsWithoutCommitTime(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - an event stored before commit times were recorded (type, tags, data)
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		key, err := subspace.Sub(store.Namespace()).Sub("e").PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)})
+		if err != nil {
+			return nil, err
+		}
+		tr.SetVersionstampedKey(key, tuple.Tuple{"legacy", tuple.Tuple{}, []byte("{}")}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+
+	// When
+	storedEvents := dcb.CollectEvents(tt, store.ReadAll(ctx))
+
+	// Then
+	require.Len(tt, storedEvents, 1)
+	assert.Equal(tt, "legacy", storedEvents[0].Type)
+	assert.True(tt, storedEvents[0].CommittedAt.IsZero())
+}
+
+func TestAppendBatch_PositionsFollowSliceOrder(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		ctx := context.Background()
+		store := dcb.SetupTestStore(tt)
+		events := dcb.RandomEvents(t)
+
+		// When
+		require.NoError(t, store.Append(ctx, events))
+
+		// Then - one transaction, user versions are the slice indexes
+		storedEvents := dcb.CollectEvents(tt, store.ReadAll(ctx))
+		require.Len(t, storedEvents, len(events))
+		for i, stored := range storedEvents {
+			assert.Equal(t, events[i], stored.Event)
+			assert.Equal(t, uint16(i), stored.Position.BatchIndex())
+			assert.True(t, stored.Position.SameTransaction(storedEvents[0].Position))
+		}
+	})
+}
+
+func TestAppendTooManyEvents_ReturnsErrTooManyEvents(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - more events than a transaction has user versions
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	events := make([]dcb.Event, dcb.MaxEventsPerTransaction+1)
+	for i := range events {
+		events[i] = dcb.Event{Type: "tiny"}
+	}
+
+	// When
+	err := store.Append(ctx, events)
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrTooManyEvents)
+	assert.ErrorIs(tt, err, dcb.ErrTransactionTooLarge)
+	assert.Empty(tt, dcb.CollectEvents(tt, store.ReadAll(ctx)))
+}
+
+func TestAppend_RecordsPositionsInContext(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		store := dcb.SetupTestStore(tt)
+		first, second := dcb.RandomEvents(t), dcb.RandomEvents(t)
+		ctx, positions := dcb.WithAppendedPositions(context.Background())
+
+		// When - two appends with the recording context
+		require.NoError(t, store.Append(ctx, first))
+		require.NoError(t, store.Append(ctx, second))
+
+		// Then - every appended event is recorded, in order
+		storedEvents := dcb.CollectEvents(tt, store.ReadAll(context.Background()))
+		expected := make([]dcb.Versionstamp, len(storedEvents))
+		for i, stored := range storedEvents {
+			expected[i] = stored.Position
+		}
+		assert.Equal(t, expected, positions.All())
+		last, ok := positions.Last()
+		assert.True(t, ok)
+		assert.Equal(t, expected[len(expected)-1], last)
+	})
+}
+
+// conflictMetrics records the shapes of the failed append conditions
+type conflictMetrics struct {
+	mu     sync.Mutex
+	shapes []string
+}
+
+func (m *conflictMetrics) RecordAppendDuration(time.Duration, bool) {}
+func (m *conflictMetrics) RecordAppendEvents(int)                   {}
+func (m *conflictMetrics) RecordReadDuration(time.Duration, bool)   {}
+func (m *conflictMetrics) RecordReadEvents(int)                     {}
+func (m *conflictMetrics) RecordError(string, string)               {}
+func (m *conflictMetrics) RecordAppendConflict(shape string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shapes = append(m.shapes, shape)
+}
+
+func TestAppendConflict_RecordsTheShapeOfTheFailedCondition(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	metrics := &conflictMetrics{}
+	dcb.StoreOptions{}.WithMetrics(metrics)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "user_registered", Tags: []string{"email:a@b.c"}}}))
+
+	// When - the first condition fails, the second passes
+	err := store.Append(ctx, []dcb.Event{{Type: "user_registered", Tags: []string{"email:a@b.c"}}},
+		dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{
+			{Types: []string{"user_registered", "user_email_changed"}, Tags: []string{"email:a@b.c"}},
+		}}},
+		dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{{Types: []string{"user_deleted"}}}}},
+	)
+
+	// Then
+	require.ErrorIs(tt, err, dcb.ErrAppendConditionFailed)
+	assert.Equal(tt, []string{"user_email_changed|user_registered[email:*]"}, metrics.shapes)
+}
+
+func TestQueryShape_OmitsTagValues(t *testing.T) {
+	t.Parallel()
+
+	shape := dcb.QueryShape(dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"item_removed", "item_added"}, Tags: []string{"list:1", "archived"}},
+		{Tags: []string{"list:2"}},
+		{Types: []string{"item_added", "item_removed"}, Tags: []string{"archived", "list:3"}},
+	}})
+
+	assert.Equal(t, "*[list:*] + item_added|item_removed[archived,list:*]", shape)
+}
+
+func TestAppend_TransactionHooksCommitWithTheEvents(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	key := subspace.Sub(store.Namespace() + "/hooked").Pack(tuple.Tuple{"state"})
+	committed := 0
+	ctx := dcb.WithTransactionHook(context.Background(), dcb.TransactionHook{
+		Write: func(tr fdb.Transaction) error {
+			tr.Set(key, []byte("written"))
+			return nil
+		},
+		Committed: func() { committed++ },
+	})
+
+	// When
+	require.NoError(t, store.Append(ctx, []dcb.Event{{Type: "Hooked"}}))
+
+	// Then
+	value, err := store.Database().ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(key).Get()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("written"), value)
+	assert.Equal(t, 1, committed)
+}
+
+func TestAppend_FailingTransactionHookAbortsTheAppend(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	errRejected := errors.New("rejected")
+	ctx := dcb.WithTransactionHook(context.Background(), dcb.TransactionHook{
+		Write: func(fdb.Transaction) error { return errRejected },
+	})
+
+	// When
+	err := store.Append(ctx, []dcb.Event{{Type: "Hooked"}})
+
+	// Then
+	assert.ErrorIs(t, err, errRejected)
+	assert.Empty(t, dcb.CollectEvents(t, store.ReadAll(context.Background())))
+}
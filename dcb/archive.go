@@ -0,0 +1,412 @@
+package dcb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+var (
+	// ErrArchiveDisabled is returned by ArchiveEvents for a store without archive tier
+	ErrArchiveDisabled = errors.New("archive tier not enabled")
+	// ErrObjectNotFound is returned by ObjectStore.Get for missing objects
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+const (
+	// archiveSegmentSize is the maximum number of events per archive segment
+	archiveSegmentSize = 10_000
+	// archiveDeleteBatch is the number of archived events deleted per transaction
+	archiveDeleteBatch = 1000
+	// archiveManifestName is the name of the manifest object
+	archiveManifestName = "manifest.json"
+)
+
+// errArchiveMoved aborts a live read started while events were being archived
+var errArchiveMoved = errors.New("events archived during read")
+
+// ObjectStore holds the archive tier, e.g. an S3 or GCS bucket under a prefix.
+// Names are slash-separated paths relative to the archive root.
+type ObjectStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+	// Get returns ErrObjectNotFound (possibly wrapped) when no object has the name
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// ArchiveManifest lists the archive segments, in position order
+type ArchiveManifest struct {
+	Segments []ArchiveSegment `json:"segments"`
+}
+
+// ArchiveSegment is a gzipped NDJSON object holding consecutive archived events
+type ArchiveSegment struct {
+	Name  string       `json:"name"`
+	First Versionstamp `json:"first"`
+	Last  Versionstamp `json:"last"`
+	Count int          `json:"count"`
+}
+
+// ArchiveReport summarizes an ArchiveEvents run
+type ArchiveReport struct {
+	Events   int // events moved to the archive tier
+	Segments int // segments written
+}
+
+// archivedEvent is the NDJSON line of an archived event
+type archivedEvent struct {
+	Position    Versionstamp      `json:"position"`
+	Type        string            `json:"type"`
+	Tags        []string          `json:"tags,omitempty"`
+	Data        []byte            `json:"data"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CommittedAt int64             `json:"committedAt"`
+	Superseded  *Supersession     `json:"superseded,omitempty"`
+	Sequence    int64             `json:"sequence,omitempty"`
+}
+
+// archiveTier is the object store archived events are moved to
+type archiveTier struct {
+	objects ObjectStore
+	until   fdb.Key // the position up to which events were moved, set atomically with their deletion
+}
+
+// WithArchive lets ArchiveEvents move old events to objects, and makes ReadAll
+// transparently read the archived events before the live ones (full-history replays).
+// Read, FetchEvent and append conditions only see the live events.
+func (StoreOptions) WithArchive(objects ObjectStore) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.archive = &archiveTier{
+			objects: objects,
+			until:   subspace.Sub(e.namespace).Sub("a").Pack(tuple.Tuple{"until"}),
+		}
+	}
+}
+
+// ArchiveEvents moves the events committed before the given time to the archive tier:
+// they are written to segments listed in the manifest, then deleted from the store.
+// Only a prefix of the log is archived: it stops at the first event committed after before.
+//
+// Archived events no longer match reads nor append conditions: only archive events no decision depends on anymore.
+// Run a single ArchiveEvents at a time per namespace; an interrupted run is completed by the next one.
+// With WithSequences, the events are numbered first: archived events keep their sequence.
+func ArchiveEvents(ctx context.Context, store DcbStore, before time.Time) (ArchiveReport, error) {
+	s, ok := store.(*fdbStore)
+	if !ok || s.archive == nil {
+		return ArchiveReport{}, ErrArchiveDisabled
+	}
+
+	if s.sequences != nil {
+		// number the events before they leave the namespace, they couldn't be afterwards
+		if _, err := AssignSequences(ctx, s); err != nil {
+			return ArchiveReport{}, err
+		}
+	}
+
+	var report ArchiveReport
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		manifest, err := s.archive.manifest(ctx)
+		if err != nil {
+			return report, fmt.Errorf("reading archive manifest: %w", err)
+		}
+		pending := manifest.last() // events up to it are archived, maybe not deleted yet
+
+		events, err := s.archivable(ctx, before, pending)
+		if err != nil {
+			return report, fmt.Errorf("reading archivable events: %w", err)
+		}
+		if len(events) == 0 {
+			s.logger.Info("events archived", "event_count", report.Events, "segment_count", report.Segments)
+			return report, nil
+		}
+
+		if pending == nil || events[0].Position.Compare(*pending) > 0 {
+			segment, err := s.archive.putSegment(ctx, events)
+			if err != nil {
+				return report, fmt.Errorf("writing archive segment: %w", err)
+			}
+			manifest.Segments = append(manifest.Segments, segment)
+			if err := s.archive.putManifest(ctx, manifest); err != nil {
+				return report, fmt.Errorf("writing archive manifest: %w", err)
+			}
+			report.Segments++
+			report.Events += len(events)
+		}
+
+		for start := 0; start < len(events); start += archiveDeleteBatch {
+			chunk := events[start:min(start+archiveDeleteBatch, len(events))]
+			if _, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+				return nil, s.deleteArchived(tr, chunk)
+			}); err != nil {
+				return report, fmt.Errorf("deleting archived events: %w", err)
+			}
+			if s.cache != nil {
+				positions := make([]Versionstamp, len(chunk))
+				for i, event := range chunk {
+					positions[i] = event.Position
+				}
+				s.cache.remove(positions...)
+			}
+		}
+	}
+}
+
+// archivable returns the first events of the log to archive: up to pending (archived by an interrupted run),
+// then those committed before the given time
+func (s fdbStore) archivable(ctx context.Context, before time.Time, pending *Versionstamp) ([]StoredEvent, error) {
+	res, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		kvs, err := tr.GetRange(s.events, fdb.RangeOptions{Limit: archiveSegmentSize}).GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+
+		var events []StoredEvent
+		leftovers := false // the events are those archived by an interrupted run, only their deletion is left
+		for i, kv := range kvs {
+			vs := extractVersionstamp(kv.Key)
+			leftover := pending != nil && vs.Compare(*pending) <= 0
+			if i == 0 {
+				leftovers = leftover
+			} else if leftover != leftovers {
+				break // complete the interrupted run before archiving more
+			}
+
+			event, committedAt, err := decodeEvent(ctx, kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("event at versionstamp %x: %w", vs[:], err)
+			}
+			stored := StoredEvent{Event: event, Position: vs, CommittedAt: committedAt}
+			if stored, err = s.withSequence(tr, stored); err != nil {
+				return nil, err
+			}
+			if !leftover {
+				if !committedAt.Before(before) {
+					break
+				}
+				if stored, err = s.withSupersession(tr, stored); err != nil {
+					return nil, err
+				}
+			}
+			events = append(events, stored)
+		}
+		return events, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.([]StoredEvent), nil
+}
+
+// deleteArchived clears the events with their indexes and supersessions, and moves the archive boundary past them
+func (s fdbStore) deleteArchived(tr fdb.Transaction, events []StoredEvent) error {
+	for _, event := range events {
+		vs := event.Position.tupleVersionstamp()
+		tr.Clear(s.events.Pack(tuple.Tuple{vs}))
+		tr.Clear(s.byType.Sub(event.Type).Pack(tuple.Tuple{vs}))
+		for _, subset := range generateAllSubsets(event.Tags) {
+			tagPath := make(tuple.Tuple, 0, len(subset)+3)
+			for _, tag := range subset {
+				tagPath = append(tagPath, tag)
+			}
+			tr.Clear(s.byTag.Pack(append(tagPath, eventsInTagSubspace, event.Type, vs)))
+		}
+		tr.Clear(s.supersessionKey(event.Position))
+		if s.sequences != nil {
+			s.sequences.clearSequence(tr, event)
+		}
+	}
+
+	last := events[len(events)-1].Position
+	until, err := s.archive.readUntil(tr)
+	if err != nil {
+		return err
+	}
+	if until == nil || last.Compare(*until) > 0 {
+		tr.Set(s.archive.until, last[:])
+	}
+	return nil
+}
+
+// readAllWithArchive yields the archived events, then the live ones.
+// The live read checks no events were archived since the archive was read, else the new segments are read first.
+func (s fdbStore) readAllWithArchive(ctx context.Context, emit func(StoredEvent) bool) error {
+	var archived *Versionstamp // the archived events were yielded up to it
+	for {
+		until, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+			return s.archive.readUntil(tr)
+		})
+		if err != nil {
+			return err
+		}
+		if u := until.(*Versionstamp); u != nil && !samePosition(u, archived) {
+			more, err := s.emitArchived(ctx, archived, *u, emit)
+			if err != nil || !more {
+				return err
+			}
+			archived = u
+		}
+
+		err = s.readAllLive(ctx, archived, emit)
+		if !errors.Is(err, errArchiveMoved) {
+			return err
+		}
+	}
+}
+
+// emitArchived yields the archived events after from (nil = from the start) up to until.
+// Returns false once emit asked to stop.
+func (s fdbStore) emitArchived(ctx context.Context, from *Versionstamp, until Versionstamp, emit func(StoredEvent) bool) (bool, error) {
+	manifest, err := s.archive.manifest(ctx)
+	if err != nil {
+		return false, fmt.Errorf("reading archive manifest: %w", err)
+	}
+	for _, segment := range manifest.Segments {
+		if from != nil && segment.Last.Compare(*from) <= 0 {
+			continue
+		}
+		if segment.First.Compare(until) > 0 {
+			break
+		}
+		events, err := s.archive.segment(ctx, segment)
+		if err != nil {
+			return false, fmt.Errorf("reading archive segment %s: %w", segment.Name, err)
+		}
+		for _, event := range events {
+			if (from != nil && event.Position.Compare(*from) <= 0) || event.Position.Compare(until) > 0 {
+				continue
+			}
+			if !emit(event) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// readUntil returns the position up to which events were moved to the archive (nil = none)
+func (a *archiveTier) readUntil(tr fdb.ReadTransaction) (*Versionstamp, error) {
+	value, err := tr.Get(a.until).Get()
+	if err != nil || len(value) != len(Versionstamp{}) {
+		return nil, err
+	}
+	var vs Versionstamp
+	copy(vs[:], value)
+	return &vs, nil
+}
+
+// manifest returns the archive manifest, empty if none was written yet
+func (a *archiveTier) manifest(ctx context.Context) (ArchiveManifest, error) {
+	var manifest ArchiveManifest
+	data, err := a.objects.Get(ctx, archiveManifestName)
+	if errors.Is(err, ErrObjectNotFound) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, json.Unmarshal(data, &manifest)
+}
+
+func (a *archiveTier) putManifest(ctx context.Context, manifest ArchiveManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return a.objects.Put(ctx, archiveManifestName, data)
+}
+
+// putSegment writes events as a gzipped NDJSON segment named after its positions
+func (a *archiveTier) putSegment(ctx context.Context, events []StoredEvent) (ArchiveSegment, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, event := range events {
+		line := archivedEvent{
+			Position:   event.Position,
+			Type:       event.Type,
+			Tags:       event.Tags,
+			Data:       event.Data,
+			Metadata:   event.Metadata,
+			Superseded: event.Superseded,
+			Sequence:   event.Sequence,
+		}
+		if !event.CommittedAt.IsZero() {
+			line.CommittedAt = event.CommittedAt.UnixNano()
+		}
+		if err := enc.Encode(line); err != nil {
+			return ArchiveSegment{}, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return ArchiveSegment{}, err
+	}
+
+	segment := ArchiveSegment{
+		First: events[0].Position,
+		Last:  events[len(events)-1].Position,
+		Count: len(events),
+	}
+	segment.Name = fmt.Sprintf("segments/%s-%s.ndjson.gz", segment.First, segment.Last)
+	return segment, a.objects.Put(ctx, segment.Name, buf.Bytes())
+}
+
+// segment reads the events of a segment
+func (a *archiveTier) segment(ctx context.Context, segment ArchiveSegment) ([]StoredEvent, error) {
+	data, err := a.objects.Get(ctx, segment.Name)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]StoredEvent, 0, segment.Count)
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, MaxTransactionBytes*2) // a line holds at most one event, base64-encoded
+	for scanner.Scan() {
+		var line archivedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", len(events)+1, err)
+		}
+		event := StoredEvent{
+			Event:      Event{Type: line.Type, Tags: line.Tags, Data: line.Data, Metadata: line.Metadata},
+			Position:   line.Position,
+			Superseded: line.Superseded,
+			Sequence:   line.Sequence,
+		}
+		if line.CommittedAt != 0 {
+			event.CommittedAt = time.Unix(0, line.CommittedAt)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// last returns the last archived position (nil = nothing archived)
+func (m ArchiveManifest) last() *Versionstamp {
+	if len(m.Segments) == 0 {
+		return nil
+	}
+	return &m.Segments[len(m.Segments)-1].Last
+}
+
+// samePosition reports whether two optional positions are equal
+func samePosition(a, b *Versionstamp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,136 @@
+package dcb_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryObjects is an in-memory ObjectStore
+type memoryObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryObjects() *memoryObjects {
+	return &memoryObjects{objects: make(map[string][]byte)}
+}
+
+func (m *memoryObjects) Put(_ context.Context, name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[name] = data
+	return nil
+}
+
+func (m *memoryObjects) Get(_ context.Context, name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", dcb.ErrObjectNotFound, name)
+	}
+	return data, nil
+}
+
+func TestArchive_MovesOldEventsOutOfTheStore(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events before and after the cutoff
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	objects := newMemoryObjects()
+	dcb.StoreOptions{}.WithArchive(objects)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "order_placed", Tags: []string{"order:1"}, Data: []byte(`{"n":1}`)},
+		{Type: "order_placed", Tags: []string{"order:2"}, Data: []byte(`{"n":2}`)},
+	}))
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:3"}}}))
+	before := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, before, 3)
+
+	// When
+	report, err := dcb.ArchiveEvents(ctx, store, cutoff)
+
+	// Then - the old events are only in the archive tier
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.ArchiveReport{Events: 2, Segments: 1}, report)
+	live := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"order_placed"}}}}, nil))
+	assert.Equal(tt, before[2:], live)
+	assert.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:1"}}}, dcb.AppendCondition{
+		Query: dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"order:1"}}}},
+	}))
+
+	// Then - ReadAll includes the archive tier
+	all := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, all, 4)
+	assert.Equal(tt, before, all[:3])
+	assert.True(tt, dcb.EventsAreStriclyOrdered(all))
+}
+
+func TestArchive_IsIncremental(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a first archive run
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	objects := newMemoryObjects()
+	dcb.StoreOptions{}.WithArchive(objects)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:1"}}}))
+	_, err := dcb.ArchiveEvents(ctx, store, time.Now())
+	require.NoError(tt, err)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:2"}}}))
+	before := dcb.CollectEvents(tt, store.ReadAll(ctx))
+
+	// When
+	report, err := dcb.ArchiveEvents(ctx, store, time.Now())
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.ArchiveReport{Events: 1, Segments: 1}, report)
+	assert.Equal(tt, before, dcb.CollectEvents(tt, store.ReadAll(ctx)))
+	assert.Len(tt, objects.objects, 3) // the manifest and two segments
+}
+
+func TestArchive_KeepsSupersessions(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithArchive(newMemoryObjects())(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:1"}}}))
+	event := dcb.CollectEvents(tt, store.ReadAll(ctx))[0]
+	require.NoError(tt, store.Supersede(ctx, event.Position, "duplicate"))
+
+	// When
+	_, err := dcb.ArchiveEvents(ctx, store, time.Now())
+
+	// Then
+	require.NoError(tt, err)
+	all := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, all, 1)
+	require.NotNil(tt, all[0].Superseded)
+	assert.Equal(tt, "duplicate", all[0].Superseded.Reason)
+}
+
+func TestArchive_RequiresArchiveTier(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	_, err := dcb.ArchiveEvents(context.Background(), store, time.Now())
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrArchiveDisabled)
+}
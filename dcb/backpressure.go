@@ -0,0 +1,127 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStoreOverloaded is returned when the store's transaction queue is full
+var ErrStoreOverloaded = errors.New("store overloaded: too many queued transactions")
+
+// ConcurrencyMetrics is optionally implemented by Metrics to observe the concurrency limiter
+type ConcurrencyMetrics interface {
+	RecordInFlight(count int)
+	RecordQueueWait(duration time.Duration)
+}
+
+// limiter caps concurrent in-flight FDB transactions.
+// Callers beyond the cap wait for a slot, up to maxQueued waiters (0 = unbounded).
+type limiter struct {
+	slots     chan struct{}
+	maxQueued int64
+	queued    atomic.Int64
+}
+
+func newLimiter(maxInFlight, maxQueued int) *limiter {
+	return &limiter{
+		slots:     make(chan struct{}, maxInFlight),
+		maxQueued: int64(maxQueued),
+	}
+}
+
+// acquire blocks until a slot is free, the queue is full or ctx is done.
+// The returned func must be called to release the slot.
+func (l *limiter) acquire(ctx context.Context, metrics Metrics) (func(), error) {
+	cm, _ := metrics.(ConcurrencyMetrics)
+	release := func() {
+		<-l.slots
+		if cm != nil {
+			cm.RecordInFlight(len(l.slots))
+		}
+	}
+
+	// Fast path: free slot
+	select {
+	case l.slots <- struct{}{}:
+		if cm != nil {
+			cm.RecordInFlight(len(l.slots))
+		}
+		return release, nil
+	default:
+	}
+
+	if n := l.queued.Add(1); l.maxQueued > 0 && n > l.maxQueued {
+		l.queued.Add(-1)
+		return nil, ErrStoreOverloaded
+	}
+	defer l.queued.Add(-1)
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		if cm != nil {
+			cm.RecordQueueWait(time.Since(start))
+			cm.RecordInFlight(len(l.slots))
+		}
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquireSlot reserves a transaction slot if the store has a concurrency limit
+func (s fdbStore) acquireSlot(ctx context.Context, operation string) (func(), error) {
+	if s.limiter == nil {
+		return func() {}, nil
+	}
+	release, err := s.limiter.acquire(ctx, s.metrics)
+	if errors.Is(err, ErrStoreOverloaded) {
+		s.metrics.RecordError(operation, "overloaded")
+	}
+	return release, err
+}
+
+// readSlot is the slot of a read, released while the read yields: consumers appending or reading
+// in their loop don't wait for the slot of their own read
+type readSlot struct {
+	store     fdbStore
+	operation string
+	release   func() // nil while not held
+}
+
+// acquireReadSlot reserves a transaction slot for a read
+func (s fdbStore) acquireReadSlot(ctx context.Context, operation string) (*readSlot, error) {
+	release, err := s.acquireSlot(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	return &readSlot{store: s, operation: operation, release: release}, nil
+}
+
+// yield wraps the yield of the read: the slot is released while the consumer runs,
+// and acquired again before the read resumes (failing the read if it can't be)
+func (r *readSlot) yield(ctx context.Context, yield func(StoredEvent, error) bool) func(StoredEvent, error) bool {
+	return func(event StoredEvent, err error) bool {
+		r.close()
+		if !yield(event, err) {
+			return false
+		}
+		release, err := r.store.acquireSlot(ctx, r.operation)
+		if err != nil {
+			yield(StoredEvent{}, err)
+			return false
+		}
+		r.release = release
+		return true
+	}
+}
+
+// close releases the slot, if held
+func (r *readSlot) close() {
+	if r.release != nil {
+		r.release()
+		r.release = nil
+	}
+}
@@ -0,0 +1,79 @@
+package dcb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_BoundsInFlightAndQueue(t *testing.T) {
+	// Given - 1 slot, 1 queued waiter allowed
+	l := newLimiter(1, 1)
+	ctx := context.Background()
+
+	release1, err := l.acquire(ctx, noopMetrics{})
+	require.NoError(t, err)
+
+	acquired := make(chan func())
+	go func() {
+		release2, err := l.acquire(ctx, noopMetrics{})
+		assert.NoError(t, err)
+		acquired <- release2
+	}()
+	require.Eventually(t, func() bool { return l.queued.Load() == 1 }, time.Second, time.Millisecond)
+
+	// When - a third caller arrives while the queue is full
+	_, err = l.acquire(ctx, noopMetrics{})
+
+	// Then - it is rejected, and the waiter gets the slot once released
+	assert.ErrorIs(t, err, ErrStoreOverloaded)
+	release1()
+	release2 := <-acquired
+	release2()
+	assert.Len(t, l.slots, 0)
+}
+
+func TestLimiter_WaitHonorsContext(t *testing.T) {
+	// Given - the only slot is taken
+	l := newLimiter(1, 0)
+	release, err := l.acquire(context.Background(), noopMetrics{})
+	require.NoError(t, err)
+	defer release()
+
+	// When
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx, noopMetrics{})
+
+	// Then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(0), l.queued.Load())
+}
+
+func TestRead_ReleasesItsSlotWhileYielding(t *testing.T) {
+	// Given - a single slot, and events to read
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store := SetupTestStore(t)
+	StoreOptions{}.WithMaxConcurrentTransactions(1, 0)(store)
+	require.NoError(t, store.Append(ctx, []Event{{Type: "a"}, {Type: "a"}}))
+
+	// When - each event is handled with a read and an append
+	var errs []error
+	for _, err := range store.Read(ctx, Query{Items: []QueryItem{{Types: []string{"a"}}}}, nil) {
+		errs = append(errs, err)
+		for _, err := range store.ReadAll(ctx) {
+			errs = append(errs, err)
+		}
+		errs = append(errs, store.Append(ctx, []Event{{Type: "b"}}))
+	}
+
+	// Then - nothing waits for the slot of the outer read
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Len(t, store.limiter.slots, 0)
+}
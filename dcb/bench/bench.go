@@ -0,0 +1,393 @@
+// Package bench benchmarks a dcb store with your own workload: event shapes, tag cardinalities
+// and query mixes, run by concurrent workers, with latency percentiles reported per operation.
+//
+//	report, err := bench.Run(ctx, store, bench.Config{
+//		Concurrency: 20,
+//		Duration:    time.Minute,
+//		Events:      []bench.EventShape{{Type: "order_placed", PayloadBytes: 512, Tags: []bench.TagShape{{Key: "customer", Cardinality: 10_000}}}},
+//		Queries:     []bench.QueryShape{{Name: "customer", Query: customerQuery}},
+//		ReadRatio:   0.8,
+//		SeedEvents:  100_000,
+//	})
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ErrInvalidConfig is returned by Run when the config can't be benchmarked
+var ErrInvalidConfig = errors.New("invalid benchmark config")
+
+const (
+	defaultConcurrency    = 50
+	defaultReportInterval = time.Second
+	seedBatchSize         = 100
+)
+
+// Config describes a benchmark: the workers, the events they append and the queries they read
+type Config struct {
+	Concurrency    int                               // concurrent workers, default 50
+	Duration       time.Duration                     // measured duration, 0 = until ctx is done
+	ReportInterval time.Duration                     // interval of the Progress calls, default 1s
+	Progress       func(Progress)                    // called every ReportInterval while running, nil = no progress
+	OnError        func(operation string, err error) // called with each failed operation, nil = ignored
+
+	Events     []EventShape // appended events, picked by weight
+	Queries    []QueryShape // issued queries, picked by weight
+	ReadRatio  float64      // share of operations that are reads (0..1), when there are both events and queries
+	ReadLimit  int          // max events per read, 0 = unlimited
+	SeedEvents int          // events appended from Events before measuring, so reads have something to find
+	// Seed appends custom seed data, after SeedEvents, nil = none
+	Seed func(ctx context.Context, store dcb.DcbStore) error
+}
+
+// EventShape is a kind of append: a batch of events of a type, with a payload size and tags
+type EventShape struct {
+	Name         string  // operation name in the report, default Type
+	Type         string  // event type
+	Weight       float64 // relative frequency among Events (all 0 = equally frequent)
+	PayloadBytes int     // size of the event data
+	BatchSize    int     // events per append, default 1
+	Tags         []TagShape
+	// Condition returns the append condition of the events, nil = unconditional
+	Condition func(events []dcb.Event) *dcb.AppendCondition
+}
+
+// TagShape is a tag "Key:value", which value is drawn among Cardinality distinct values
+type TagShape struct {
+	Key         string
+	Cardinality int // distinct values, 0 = a new value per event
+}
+
+// QueryShape is a kind of read
+type QueryShape struct {
+	Name   string           // operation name in the report, default its index in Queries
+	Weight float64          // relative frequency among Queries (all 0 = equally frequent)
+	Query  func() dcb.Query // returns the query of each read, safe for concurrent use
+}
+
+// Value returns a random value of the tag, as appended: queries use it to hit existing events.
+// Tags of Cardinality 0 only have their first value, "Key:0", returned
+func (t TagShape) Value() string {
+	return t.Key + ":" + strconv.Itoa(rand.IntN(max(t.Cardinality, 1)))
+}
+
+// Progress is reported every ReportInterval while the benchmark runs
+type Progress struct {
+	Elapsed    time.Duration
+	Operations uint64  // operations completed
+	Events     uint64  // events appended
+	Errors     uint64  // operations failed
+	Rate       float64 // operations per second since the previous report
+}
+
+// Report is the outcome of a benchmark
+type Report struct {
+	Elapsed    time.Duration
+	Events     uint64                    // events appended, seed excluded
+	Operations map[string]LatencySummary // per operation: "append:<name>" and "read:<name>"
+}
+
+// Total returns the number of operations completed, failed ones included
+func (r Report) Total() int {
+	total := 0
+	for _, s := range r.Operations {
+		total += s.Count + s.Errors
+	}
+	return total
+}
+
+// Run seeds the store then runs the workload of cfg until cfg.Duration elapses or ctx is done.
+// A benchmark ended by ctx is not an error: the report covers what ran.
+func Run(ctx context.Context, store dcb.DcbStore, cfg Config) (Report, error) {
+	cfg = withDefaults(cfg)
+	if err := validate(cfg); err != nil {
+		return Report{}, err
+	}
+	b := newBenchmark(cfg)
+
+	if err := b.seed(ctx, store); err != nil {
+		return Report{}, err
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.work(ctx, store)
+		}()
+	}
+
+	ticker := time.NewTicker(cfg.ReportInterval)
+	defer ticker.Stop()
+	var last uint64
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return b.report(time.Since(start)), nil
+		case <-ticker.C:
+			if cfg.Progress == nil {
+				continue
+			}
+			ops := b.ops.Load()
+			cfg.Progress(Progress{
+				Elapsed:    time.Since(start),
+				Operations: ops,
+				Events:     b.events.Load(),
+				Errors:     b.errors.Load(),
+				Rate:       float64(ops-last) / cfg.ReportInterval.Seconds(),
+			})
+			last = ops
+		}
+	}
+}
+
+func withDefaults(cfg Config) Config {
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.ReportInterval == 0 {
+		cfg.ReportInterval = defaultReportInterval
+	}
+	events := make([]EventShape, len(cfg.Events))
+	for i, shape := range cfg.Events {
+		if shape.Name == "" {
+			shape.Name = shape.Type
+		}
+		if shape.BatchSize == 0 {
+			shape.BatchSize = 1
+		}
+		events[i] = shape
+	}
+	cfg.Events = events
+	queries := make([]QueryShape, len(cfg.Queries))
+	for i, shape := range cfg.Queries {
+		if shape.Name == "" {
+			shape.Name = strconv.Itoa(i)
+		}
+		queries[i] = shape
+	}
+	cfg.Queries = queries
+	return cfg
+}
+
+func validate(cfg Config) error {
+	switch {
+	case len(cfg.Events) == 0 && len(cfg.Queries) == 0:
+		return fmt.Errorf("%w: no events nor queries", ErrInvalidConfig)
+	case cfg.Concurrency < 0, cfg.Duration < 0, cfg.ReportInterval < 0, cfg.ReadLimit < 0, cfg.SeedEvents < 0:
+		return fmt.Errorf("%w: negative concurrency, duration, interval, read limit or seed", ErrInvalidConfig)
+	case cfg.ReadRatio < 0 || cfg.ReadRatio > 1:
+		return fmt.Errorf("%w: read ratio %v not within 0..1", ErrInvalidConfig, cfg.ReadRatio)
+	case cfg.SeedEvents > 0 && len(cfg.Events) == 0:
+		return fmt.Errorf("%w: seed events without event shapes", ErrInvalidConfig)
+	}
+	for _, shape := range cfg.Events {
+		if shape.Type == "" || shape.Weight < 0 || shape.PayloadBytes < 0 || shape.BatchSize < 0 {
+			return fmt.Errorf("%w: event shape %q: empty type or negative weight, payload or batch size", ErrInvalidConfig, shape.Name)
+		}
+		for _, tag := range shape.Tags {
+			if tag.Key == "" || tag.Cardinality < 0 {
+				return fmt.Errorf("%w: event shape %q: empty tag key or negative cardinality", ErrInvalidConfig, shape.Name)
+			}
+		}
+	}
+	for _, shape := range cfg.Queries {
+		if shape.Query == nil || shape.Weight < 0 {
+			return fmt.Errorf("%w: query shape %q: no query or negative weight", ErrInvalidConfig, shape.Name)
+		}
+	}
+	return nil
+}
+
+// benchmark is the state shared by the workers of a Run
+type benchmark struct {
+	cfg       Config
+	events    atomic.Uint64
+	ops       atomic.Uint64
+	errors    atomic.Uint64
+	sequence  atomic.Uint64 // values of the tags of Cardinality 0
+	payloads  [][]byte      // per event shape
+	pickEvent picker
+	pickQuery picker
+	recorders map[string]*latencyRecorder
+}
+
+func newBenchmark(cfg Config) *benchmark {
+	b := &benchmark{
+		cfg:       cfg,
+		payloads:  make([][]byte, len(cfg.Events)),
+		pickEvent: newPicker(len(cfg.Events), func(i int) float64 { return cfg.Events[i].Weight }),
+		pickQuery: newPicker(len(cfg.Queries), func(i int) float64 { return cfg.Queries[i].Weight }),
+		recorders: map[string]*latencyRecorder{},
+	}
+	for i, shape := range cfg.Events {
+		b.payloads[i] = make([]byte, shape.PayloadBytes)
+		b.recorders[appendOperation(shape)] = newLatencyRecorder()
+	}
+	for _, shape := range cfg.Queries {
+		b.recorders[readOperation(shape)] = newLatencyRecorder()
+	}
+	return b
+}
+
+func appendOperation(shape EventShape) string { return "append:" + shape.Name }
+func readOperation(shape QueryShape) string   { return "read:" + shape.Name }
+
+// seed appends SeedEvents events, picked like the workload's, then runs the custom Seed
+func (b *benchmark) seed(ctx context.Context, store dcb.DcbStore) error {
+	for remaining := b.cfg.SeedEvents; remaining > 0; {
+		batch := make([]dcb.Event, 0, min(remaining, seedBatchSize))
+		for len(batch) < cap(batch) {
+			i := b.pickEvent.pick()
+			batch = append(batch, b.event(i))
+		}
+		if err := store.Append(ctx, batch); err != nil {
+			return fmt.Errorf("seeding events: %w", err)
+		}
+		remaining -= len(batch)
+	}
+	if b.cfg.Seed != nil {
+		if err := b.cfg.Seed(ctx, store); err != nil {
+			return fmt.Errorf("seeding: %w", err)
+		}
+	}
+	return nil
+}
+
+// event builds an event of the shape at index i, with its tag values drawn
+func (b *benchmark) event(i int) dcb.Event {
+	shape := b.cfg.Events[i]
+	tags := make([]string, len(shape.Tags))
+	for j, tag := range shape.Tags {
+		if tag.Cardinality == 0 {
+			tags[j] = tag.Key + ":" + strconv.FormatUint(b.sequence.Add(1), 10)
+			continue
+		}
+		tags[j] = tag.Value()
+	}
+	return dcb.Event{Type: shape.Type, Tags: tags, Data: b.payloads[i]}
+}
+
+// work runs operations until ctx is done
+func (b *benchmark) work(ctx context.Context, store dcb.DcbStore) {
+	for ctx.Err() == nil {
+		op, d, err := b.operation(ctx, store)
+		if ctx.Err() != nil {
+			return // interrupted operations would skew the tail
+		}
+		b.recorders[op].record(d, err)
+		b.ops.Add(1)
+		if err != nil {
+			b.errors.Add(1)
+			if b.cfg.OnError != nil {
+				b.cfg.OnError(op, err)
+			}
+		}
+	}
+}
+
+// operation runs a read or an append, picked by ReadRatio and weight, and returns its name and outcome
+func (b *benchmark) operation(ctx context.Context, store dcb.DcbStore) (string, time.Duration, error) {
+	read := len(b.cfg.Events) == 0 || (len(b.cfg.Queries) > 0 && rand.Float64() < b.cfg.ReadRatio)
+	if read {
+		return b.read(ctx, store)
+	}
+	return b.append(ctx, store)
+}
+
+func (b *benchmark) read(ctx context.Context, store dcb.DcbStore) (string, time.Duration, error) {
+	shape := b.cfg.Queries[b.pickQuery.pick()]
+	query := shape.Query()
+
+	start := time.Now()
+	var err error
+	for _, readErr := range store.Read(ctx, query, &dcb.ReadOptions{Limit: b.cfg.ReadLimit}) {
+		if readErr != nil {
+			err = readErr
+		}
+	}
+	return readOperation(shape), time.Since(start), err
+}
+
+func (b *benchmark) append(ctx context.Context, store dcb.DcbStore) (string, time.Duration, error) {
+	i := b.pickEvent.pick()
+	shape := b.cfg.Events[i]
+	events := make([]dcb.Event, shape.BatchSize)
+	for j := range events {
+		events[j] = b.event(i)
+	}
+	var conditions []dcb.AppendCondition
+	if shape.Condition != nil {
+		if condition := shape.Condition(events); condition != nil {
+			conditions = append(conditions, *condition)
+		}
+	}
+
+	start := time.Now()
+	err := store.Append(ctx, events, conditions...)
+	d := time.Since(start)
+	if err == nil {
+		b.events.Add(uint64(len(events)))
+	}
+	return appendOperation(shape), d, err
+}
+
+func (b *benchmark) report(elapsed time.Duration) Report {
+	report := Report{Elapsed: elapsed, Events: b.events.Load(), Operations: map[string]LatencySummary{}}
+	for op, recorder := range b.recorders {
+		report.Operations[op] = recorder.summary()
+	}
+	return report
+}
+
+// picker picks indexes at random, in proportion to their weight
+type picker struct {
+	cumulative []float64 // nil = equally likely
+	n          int
+}
+
+func newPicker(n int, weight func(i int) float64) picker {
+	p := picker{n: n}
+	total := 0.0
+	cumulative := make([]float64, n)
+	for i := range n {
+		total += weight(i)
+		cumulative[i] = total
+	}
+	if total > 0 {
+		p.cumulative = cumulative
+	}
+	return p
+}
+
+func (p picker) pick() int {
+	if p.cumulative == nil {
+		return rand.IntN(p.n)
+	}
+	target := rand.Float64() * p.cumulative[p.n-1]
+	for i, c := range p.cumulative {
+		if target < c {
+			return i
+		}
+	}
+	return p.n - 1
+}
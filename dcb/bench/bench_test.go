@@ -0,0 +1,118 @@
+package bench_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/err0r500/fairway/dcb/bench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_ReportsLatenciesPerOperation(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+	user := bench.TagShape{Key: "user", Cardinality: 5}
+	var progress []bench.Progress
+
+	// When
+	report, err := bench.Run(context.Background(), store, bench.Config{
+		Concurrency:    4,
+		Duration:       300 * time.Millisecond,
+		ReportInterval: 100 * time.Millisecond,
+		Progress:       func(p bench.Progress) { progress = append(progress, p) },
+		Events:         []bench.EventShape{{Type: "user_renamed", PayloadBytes: 64, BatchSize: 2, Tags: []bench.TagShape{user}}},
+		Queries: []bench.QueryShape{{Name: "user", Query: func() dcb.Query {
+			return dcb.Query{Items: []dcb.QueryItem{{Tags: []string{user.Value()}}}}
+		}}},
+		ReadRatio:  0.5,
+		SeedEvents: 20,
+	})
+
+	// Then
+	require.NoError(tt, err)
+	assert.ElementsMatch(tt, []string{"append:user_renamed", "read:user"}, keys(report.Operations))
+	for op, summary := range report.Operations {
+		assert.Positive(tt, summary.Count, op)
+		assert.Zero(tt, summary.Errors, op)
+		assert.LessOrEqual(tt, summary.P50, summary.P99, op)
+		assert.LessOrEqual(tt, summary.P99, summary.Max, op)
+	}
+	assert.Equal(tt, 2*uint64(report.Operations["append:user_renamed"].Count), report.Events)
+	assert.NotEmpty(tt, progress)
+
+	stored := dcb.CollectEvents(tt, store.Read(context.Background(), dcb.Query{Items: []dcb.QueryItem{{Types: []string{"user_renamed"}}}}, nil))
+	assert.Len(tt, stored, 20+int(report.Events))
+}
+
+func TestRun_DrawsTagValuesWithinCardinality(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	_, err := bench.Run(context.Background(), store, bench.Config{
+		Events: []bench.EventShape{{
+			Type: "item_added",
+			Tags: []bench.TagShape{{Key: "list", Cardinality: 3}, {Key: "item"}},
+		}},
+		SeedEvents:  50,
+		Concurrency: 1,
+		Duration:    time.Nanosecond,
+	})
+
+	// Then
+	require.NoError(tt, err)
+	stored := dcb.CollectEvents(tt, store.Read(context.Background(), dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}, nil))
+	require.GreaterOrEqual(tt, len(stored), 50)
+	lists, items := map[string]bool{}, map[string]bool{}
+	for _, e := range stored {
+		for _, tag := range e.Tags {
+			if strings.HasPrefix(tag, "list:") {
+				lists[tag] = true
+			} else {
+				items[tag] = true
+			}
+		}
+	}
+	assert.Subset(tt, []string{"list:0", "list:1", "list:2"}, keys(lists))
+	assert.Len(tt, items, len(stored), "a new item tag per event")
+}
+
+func TestRun_RejectsInvalidConfigs(tt *testing.T) {
+	tt.Parallel()
+
+	query := func() dcb.Query { return dcb.Query{} }
+	for name, cfg := range map[string]bench.Config{
+		"no events nor queries":  {},
+		"read ratio above 1":     {Queries: []bench.QueryShape{{Query: query}}, ReadRatio: 1.5},
+		"seed without events":    {Queries: []bench.QueryShape{{Query: query}}, SeedEvents: 10},
+		"event without type":     {Events: []bench.EventShape{{}}},
+		"negative cardinality":   {Events: []bench.EventShape{{Type: "t", Tags: []bench.TagShape{{Key: "k", Cardinality: -1}}}}},
+		"query shape without fn": {Queries: []bench.QueryShape{{Name: "q"}}},
+	} {
+		tt.Run(name, func(tt *testing.T) {
+			tt.Parallel()
+
+			// When
+			_, err := bench.Run(context.Background(), nil, cfg)
+
+			// Then
+			assert.ErrorIs(tt, err, bench.ErrInvalidConfig)
+		})
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxLatencySamples bounds the memory used by a latency recorder (reservoir sampling beyond that)
+const maxLatencySamples = 100_000
+
+// LatencySummary is the count, error count and latency percentiles of an operation
+type LatencySummary struct {
+	Count  int // successful operations
+	Errors int // failed operations, excluded from the percentiles
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	P999   time.Duration
+	Max    time.Duration
+}
+
+func (s LatencySummary) String() string {
+	if s.Count == 0 {
+		return fmt.Sprintf("ops=0 errors=%d", s.Errors)
+	}
+	return fmt.Sprintf("ops=%d errors=%d p50=%s p90=%s p99=%s p999=%s max=%s",
+		s.Count, s.Errors, s.P50, s.P90, s.P99, s.P999, s.Max)
+}
+
+// latencyRecorder keeps a bounded uniform sample of operation latencies
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	seen    int
+	errors  int
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: make([]time.Duration, 0, 1024)}
+}
+
+func (r *latencyRecorder) record(d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.seen++
+	if len(r.samples) < maxLatencySamples {
+		r.samples = append(r.samples, d)
+		return
+	}
+	if i := rand.IntN(r.seen); i < maxLatencySamples {
+		r.samples[i] = d
+	}
+}
+
+func (r *latencyRecorder) summary() LatencySummary {
+	r.mu.Lock()
+	sorted := slices.Clone(r.samples)
+	summary := LatencySummary{Count: r.seen, Errors: r.errors}
+	r.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return summary
+	}
+	slices.Sort(sorted)
+	p := func(q float64) time.Duration {
+		return sorted[min(len(sorted)-1, int(q*float64(len(sorted))))]
+	}
+	summary.P50, summary.P90, summary.P99, summary.P999 = p(0.50), p(0.90), p(0.99), p(0.999)
+	summary.Max = sorted[len(sorted)-1]
+	return summary
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"iter"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
@@ -18,9 +21,29 @@ import (
 var (
 	ErrEmptyEvents           = errors.New("events slice is empty")
 	ErrAppendConditionFailed = errors.New("append condition failed")
-	ErrInvalidQuery          = errors.New("query must have at least one type or tag")
+	ErrInvalidQuery          = errors.New("invalid query")
+	ErrTransactionTooLarge   = errors.New("transaction too large")
+	ErrTooManyEvents         = errors.New("too many events in transaction")
+	ErrInvalidMetadata       = errors.New("invalid event metadata")
 )
 
+// MaxTransactionBytes is FDB's hard limit on the size of a single transaction
+const MaxTransactionBytes = 10_000_000
+
+// MaxEventsPerTransaction is the number of distinct positions a transaction can assign:
+// each event of a batch gets its index as the 2-byte user version of the versionstamp
+const MaxEventsPerTransaction = 1 << 16
+
+const (
+	// versionstampKeyOverhead covers the tuple-encoded versionstamp (type code + 12 bytes)
+	versionstampKeyOverhead = 13
+	// tupleElementOverhead covers a tuple string's type code and terminator
+	tupleElementOverhead = 2
+)
+
+// MaxQueryItemTags is the maximum number of tags a single QueryItem may require
+const MaxQueryItemTags = 16
+
 var (
 	eventsInTagSubspace = "_e"
 )
@@ -38,6 +61,10 @@ type Event struct {
 	Type string
 	Tags []string
 	Data []byte
+	// Metadata holds headers about the event rather than its payload (correlation ID, actor...),
+	// stored with it but neither indexed nor queryable. nil when the event has none.
+	// Namespaces must be in format 3 or later to store it (see Upgrader).
+	Metadata map[string]string
 }
 
 // Versionstamp is a 12-byte globally unique, monotonically increasing value
@@ -53,6 +80,21 @@ func (v Versionstamp) String() string {
 	return hex.EncodeToString(v[:])
 }
 
+// tupleVersionstamp converts v for packing in tuple keys
+func (v Versionstamp) tupleVersionstamp() tuple.Versionstamp {
+	var txVersion [10]byte
+	copy(txVersion[:], v[:10])
+	return tuple.Versionstamp{TransactionVersion: txVersion, UserVersion: binary.BigEndian.Uint16(v[10:12])}
+}
+
+// laterVersionstamp returns the later of two optional versionstamps
+func laterVersionstamp(a, b *Versionstamp) *Versionstamp {
+	if a == nil || (b != nil && b.Compare(*a) > 0) {
+		return b
+	}
+	return a
+}
+
 // AppendCondition defines a condition that must be satisfied for an append to succeed
 type AppendCondition struct {
 	Query Query
@@ -63,16 +105,20 @@ type AppendCondition struct {
 type QueryItem struct {
 	Types []string // OR semantics: match any of these types
 	Tags  []string // AND semantics: must have all these tags
+	// TagKeys: AND semantics, must have a structured tag of each of these keys, whatever its value (see TagKey).
+	// Reads find the values of each key in the tag tree, then read every combination of them.
+	TagKeys []string
+	After   *Versionstamp // Optional: only match events strictly AFTER this versionstamp (combined with the read or condition After, the later one wins)
 }
 
-// hasTypesOnly returns true if query has types but no tags
+// hasNoTypeNorTags returns true if query has neither types, tags nor tag keys
 func (q QueryItem) hasNoTypeNorTags() bool {
-	return len(q.Types) == 0 && len(q.Tags) == 0
+	return len(q.Types) == 0 && len(q.Tags) == 0 && len(q.TagKeys) == 0
 }
 
 // hasTypesOnly returns true if query has types but no tags
 func (q QueryItem) hasTypesOnly() bool {
-	return len(q.Types) > 0 && len(q.Tags) == 0
+	return len(q.Types) > 0 && len(q.Tags) == 0 && len(q.TagKeys) == 0
 }
 
 // hasTypesAndTags returns true if query has both types and tags
@@ -80,11 +126,67 @@ func (q QueryItem) hasTypesAndTags() bool {
 	return len(q.Types) > 0 && len(q.Tags) > 0
 }
 
+// validate returns all structural problems of the item, each wrapping ErrInvalidQuery
+func (q QueryItem) validate() []error {
+	if q.hasNoTypeNorTags() {
+		return []error{fmt.Errorf("%w: must have at least one type, tag or tag key", ErrInvalidQuery)}
+	}
+
+	var errs []error
+	for i, typ := range q.Types {
+		if typ == "" {
+			errs = append(errs, fmt.Errorf("%w: type %d is empty", ErrInvalidQuery, i))
+		}
+	}
+
+	if n := len(q.Tags) + len(q.TagKeys); n > MaxQueryItemTags {
+		errs = append(errs, fmt.Errorf("%w: %d tags and tag keys exceeds maximum of %d", ErrInvalidQuery, n, MaxQueryItemTags))
+	}
+
+	seen := make(map[string]bool, len(q.Tags))
+	for i, tag := range q.Tags {
+		if tag == "" {
+			errs = append(errs, fmt.Errorf("%w: tag %d is empty", ErrInvalidQuery, i))
+			continue
+		}
+		if seen[tag] {
+			errs = append(errs, fmt.Errorf("%w: duplicate tag %q", ErrInvalidQuery, tag))
+		}
+		seen[tag] = true
+	}
+
+	seenKeys := make(map[string]bool, len(q.TagKeys))
+	for i, key := range q.TagKeys {
+		if err := validateTagKey(key); err != nil {
+			errs = append(errs, fmt.Errorf("%w: tag key %d: %w", ErrInvalidQuery, i, err))
+			continue
+		}
+		if seenKeys[key] {
+			errs = append(errs, fmt.Errorf("%w: duplicate tag key %q", ErrInvalidQuery, key))
+		}
+		seenKeys[key] = true
+	}
+
+	return errs
+}
+
 // Query represents a union of query items (OR semantics between items)
 type Query struct {
 	Items []QueryItem
 }
 
+// Validate reports every structural problem of the query at once.
+// Each problem is prefixed with the index of the offending item and wraps ErrInvalidQuery.
+func (q Query) Validate() error {
+	var errs []error
+	for i, item := range q.Items {
+		for _, err := range item.validate() {
+			errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // ReadOptions configures how events are read
 type ReadOptions struct {
 	Limit   int           // Maximum number of events to return (0 = unlimited)
@@ -96,6 +198,14 @@ type ReadOptions struct {
 type StoredEvent struct {
 	Event
 	Position Versionstamp
+	// CommittedAt is the store's clock when the event was committed, independent of producer clocks
+	// (zero for events stored before it was recorded)
+	CommittedAt time.Time
+	// Superseded is set when the event was superseded (see Superseder), nil otherwise
+	Superseded *Supersession
+	// Sequence is the event's logical sequence number in the namespace (see WithSequences),
+	// 0 when sequences are disabled or the event isn't numbered yet
+	Sequence int64
 }
 
 // fdbStore provides lock-free event storage with dual-index structure
@@ -108,50 +218,159 @@ type fdbStore struct {
 	byType subspace.Subspace // Type index: (type, versionstamp) -> nil
 	byTag  subspace.Subspace // Tag tree: (tag1, tag2, ..., type, versionstamp) -> nil
 
+	superseded subspace.Subspace // Supersessions: (versionstamp) -> (reason, superseded at)
+
 	// Observability
-	metrics Metrics
-	logger  Logger
+	metrics          Metrics
+	logger           Logger
+	logLevel         LogLevel
+	logSampling      map[string]float64 // operation -> fraction of its successes logged (absent = all)
+	slowLogThreshold time.Duration      // successes are only logged when slower (0 = all)
+
+	// Transaction size protection
+	maxTxBytes int
+	autoSplit  bool
+
+	// Per event type limits (see EventLimits)
+	eventLimits        map[string]EventLimits
+	defaultEventLimits EventLimits
+
+	// Backpressure (nil = unlimited)
+	limiter *limiter
+
+	// Extension points around Append
+	appendHooks     []AppendHook
+	postAppendHooks []PostAppendHook
+
+	// Decoded events by position (nil = disabled)
+	cache *eventCache
+
+	// Per-tag existence markers (nil = disabled)
+	hints *existenceHints
+
+	// Logical sequence numbers of the events (nil = disabled)
+	sequences *sequences
+
+	// Object storage old events are moved to (nil = disabled)
+	archive *archiveTier
+
+	// Storage format verification of the namespace
+	format *formatCheck
+
+	// Store every append is copied to, in the same transaction (nil = none, see Migration)
+	mirror *fdbStore
+
+	// Debug tracing (nil = disabled)
+	debugSampler DebugSampler
+	redactor     DataRedactor // nil = payloads are logged verbatim
 }
 
 func (s *fdbStore) Database() fdb.Database { return s.db }
 func (s *fdbStore) Namespace() string      { return s.namespace }
 
 // NewDcbStore creates a new event store with the given database and namespace
-func NewDcbStore(db fdb.Database, namespace string, opts ...func(o *fdbStore)) DcbStore {
+func NewDcbStore(db fdb.Database, namespace string, opts ...StoreOption) DcbStore {
 	store := newConcreteEventStore(db, namespace)
 
 	for _, oFn := range opts {
 		oFn(store)
 	}
 
+	// Operations check again while this fails
+	if err := store.checkFormat(); err != nil {
+		store.logger.Error("storage format check failed", err, "namespace", namespace)
+	}
+
 	return store
 }
 
+// StoreOption configures the store, see StoreOptions
+type StoreOption = func(s *fdbStore)
+
 type StoreOptions struct{}
 
 func (StoreOptions) WithLogger(l Logger) func(s *fdbStore) {
 	return func(e *fdbStore) {
-		e.logger = l
+		e.logger = withLevel(l, e.logLevel)
 	}
 }
 
+// WithMetrics records the store's operations to m, through m.ForNamespace if m implements NamespaceMetrics
 func (StoreOptions) WithMetrics(m Metrics) func(s *fdbStore) {
 	return func(e *fdbStore) {
+		if nm, ok := m.(NamespaceMetrics); ok {
+			m = nm.ForNamespace(e.namespace)
+		}
 		e.metrics = m
 	}
 }
 
+// WithMaxTransactionBytes lowers the size above which Append returns ErrTransactionTooLarge (default: MaxTransactionBytes)
+func (StoreOptions) WithMaxTransactionBytes(n int) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		if n > 0 && n <= MaxTransactionBytes {
+			e.maxTxBytes = n
+		}
+	}
+}
+
+// WithAutoSplit lets unconditional appends exceeding the transaction size be split across
+// several transactions. The batch is then no longer atomic as a whole.
+// Conditional appends are never split.
+func (StoreOptions) WithAutoSplit() func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.autoSplit = true
+	}
+}
+
+// WithMaxConcurrentTransactions caps in-flight FDB transactions (appends and reads) at maxInFlight.
+// Callers beyond the cap wait for a slot; when maxQueued > 0 and that many callers are already
+// waiting, further calls fail fast with ErrStoreOverloaded.
+// A Read holds its slot while reading, not while the caller handles an event: read loops may read and append.
+func (StoreOptions) WithMaxConcurrentTransactions(maxInFlight, maxQueued int) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		if maxInFlight > 0 {
+			e.limiter = newLimiter(maxInFlight, maxQueued)
+		}
+	}
+}
+
+// AppendHook runs before the append transaction, in registration order.
+// It may mutate the events in place (enrichment) or reject the append by returning an error.
+// It receives a copy of the caller's events, tags and metadata included; their Data is shared.
+type AppendHook func(ctx context.Context, events []Event) error
+
+// PostAppendHook runs after a successful commit with the events and their assigned positions.
+type PostAppendHook func(ctx context.Context, events []StoredEvent)
+
+// WithAppendHook registers a hook called before every append (validation, enrichment, policy enforcement)
+func (StoreOptions) WithAppendHook(h AppendHook) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.appendHooks = append(e.appendHooks, h)
+	}
+}
+
+// WithPostAppendHook registers a hook called after every committed append (cache invalidation, notifications)
+func (StoreOptions) WithPostAppendHook(h PostAppendHook) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.postAppendHooks = append(e.postAppendHooks, h)
+	}
+}
+
 // concrete instance is only used in concurrency tests (testing from same package), not exposed publicly
 func newConcreteEventStore(db fdb.Database, namespace string) *fdbStore {
 	root := subspace.Sub(namespace)
 	return &fdbStore{
-		db:        db,
-		namespace: namespace,
-		events:    root.Sub("e"),
-		byType:    root.Sub("t"),
-		byTag:     root.Sub("g"),
-		metrics:   noopMetrics{},
-		logger:    noopLogger{},
+		db:         db,
+		namespace:  namespace,
+		events:     root.Sub("e"),
+		byType:     root.Sub("t"),
+		byTag:      root.Sub("g"),
+		superseded: root.Sub("s"),
+		format:     newFormatCheck(namespace),
+		metrics:    noopMetrics{},
+		logger:     noopLogger{},
+		maxTxBytes: MaxTransactionBytes,
 	}
 }
 
@@ -214,9 +433,28 @@ func (s fdbStore) discoverTypesInTagSubspace(tr fdb.ReadTransaction, eventsSubsp
 func (s fdbStore) buildQueryRanges(tr fdb.ReadTransaction, item QueryItem, after *Versionstamp) ([]fdb.Range, error) {
 	// Validate: must have at least one type or tag
 	if item.hasNoTypeNorTags() {
-		return nil, ErrInvalidQuery
+		return nil, fmt.Errorf("%w: must have at least one type, tag or tag key", ErrInvalidQuery)
+	}
+
+	// Tag keys: the ranges of each combination of their values (the merge skips events found twice)
+	if len(item.TagKeys) > 0 {
+		items, err := expandTagKeys(item, func(key string) ([]string, error) { return s.discoverTagsOfKey(tr, key) })
+		if err != nil {
+			return nil, err
+		}
+		var ranges []fdb.Range
+		for _, concrete := range items {
+			itemRanges, err := s.buildQueryRanges(tr, concrete, after)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, itemRanges...)
+		}
+		return ranges, nil
 	}
 
+	after = laterVersionstamp(after, item.After)
+
 	var ranges []fdb.Range
 
 	// Case 1: Type-only queries (no tags)
@@ -274,10 +512,72 @@ func (s fdbStore) buildQueryRanges(tr fdb.ReadTransaction, item QueryItem, after
 	return ranges, nil
 }
 
+// expandTagKeys replaces the tag keys of item by the tags of each key in use (found by tagsOfKey):
+// one item per combination of values. An item with no tag of one of its keys in use expands to none.
+func expandTagKeys(item QueryItem, tagsOfKey func(key string) ([]string, error)) ([]QueryItem, error) {
+	items := []QueryItem{{Types: item.Types, Tags: item.Tags, After: item.After}}
+	for _, key := range item.TagKeys {
+		tags, err := tagsOfKey(key)
+		if err != nil {
+			return nil, err
+		}
+		var expanded []QueryItem
+		for _, it := range items {
+			for _, tag := range tags {
+				expanded = append(expanded, QueryItem{Types: it.Types, Tags: CanonicalTags(append(slices.Clone(it.Tags), tag)), After: it.After})
+			}
+		}
+		items = expanded
+	}
+	return items, nil
+}
+
+// discoverTagsOfKey returns the structured tags of key in use, e.g. ["status:closed", "status:open"] for "status".
+// Every tag of an event heads a subtree of the tag tree: it reads the first key of each, jumping over the rest.
+// The keys read conflict with appends adding a value, so append conditions stay exact.
+func (s fdbStore) discoverTagsOfKey(tr fdb.ReadTransaction, key string) ([]string, error) {
+	// Packed strings end with a 0x00 terminator: without it, the prefix of every tag starting with "key:"
+	packed := s.byTag.Pack(tuple.Tuple{key + TagSeparator})
+	begin := packed[:len(packed)-1]
+	end, err := fdb.Strinc(begin)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for {
+		kvs, err := tr.GetRange(fdb.KeyRange{Begin: begin, End: fdb.Key(end)}, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		if len(kvs) == 0 {
+			return tags, nil
+		}
+
+		keyTuple, err := s.byTag.Unpack(kvs[0].Key)
+		if err != nil {
+			return nil, err
+		}
+		tag, ok := keyTuple[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected tag tree key %s", kvs[0].Key)
+		}
+		tags = append(tags, tag)
+
+		_, next := s.byTag.Sub(tag).FDBRangeKeys()
+		begin = next.FDBKey()
+	}
+}
+
 // extractVersionstamp extracts the versionstamp from an index key
 // For index keys like (type, _events, versionstamp) or (tag1, tag2, ..., _events, type, versionstamp),
 // the versionstamp is the last element in the tuple
 func extractVersionstamp(key fdb.Key) Versionstamp {
+	// The versionstamp element ends the key: read it in place rather than unpacking the whole key
+	if n := len(key) - 1 - len(Versionstamp{}); n >= 0 && key[n] == tupleVersionstampCode {
+		return Versionstamp(key[n+1:])
+	}
+
 	// Unpack the tuple to get the versionstamp element
 	unpacked, err := tuple.Unpack(key)
 	if err != nil || len(unpacked) == 0 {
@@ -108,6 +108,69 @@ func TestVersionstampString(tt *testing.T) {
 	assert.Equal(tt, "0102030405060708090a0b0c", str)
 }
 
+func TestQueryValidate_Valid(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	q := dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"a"}},
+		{Tags: []string{"x", "y"}},
+		{Types: []string{"a", "b"}, Tags: []string{"x"}},
+	}}
+
+	// When/Then
+	assert.NoError(tt, q.Validate())
+}
+
+func TestQueryValidate_ReportsAllProblems(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - one problem per item
+	tooManyTags := make([]string, dcb.MaxQueryItemTags+1)
+	for i := range tooManyTags {
+		tooManyTags[i] = string(rune('a' + i))
+	}
+	q := dcb.Query{Items: []dcb.QueryItem{
+		{},
+		{Types: []string{""}},
+		{Tags: []string{"x", "x"}},
+		{Tags: []string{""}},
+		{Tags: tooManyTags},
+	}}
+
+	// When
+	err := q.Validate()
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrInvalidQuery)
+	assert.ErrorContains(tt, err, "item 0: "+dcb.ErrInvalidQuery.Error())
+	assert.ErrorContains(tt, err, "item 1: ")
+	assert.ErrorContains(tt, err, "type 0 is empty")
+	assert.ErrorContains(tt, err, `item 2: `)
+	assert.ErrorContains(tt, err, `duplicate tag "x"`)
+	assert.ErrorContains(tt, err, "item 3: ")
+	assert.ErrorContains(tt, err, "tag 0 is empty")
+	assert.ErrorContains(tt, err, "item 4: ")
+	assert.ErrorContains(tt, err, "exceeds maximum")
+}
+
+func TestAppendRejectsInvalidConditionQuery(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	err := store.Append(context.Background(),
+		[]dcb.Event{{Type: "a"}},
+		dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"x", "x"}}}}})
+
+	// Then - rejected before any write
+	assert.ErrorIs(tt, err, dcb.ErrInvalidQuery)
+	assert.ErrorContains(tt, err, "condition 0: item 0: ")
+	assert.Empty(tt, dcb.CollectEvents(tt, store.ReadAll(context.Background())))
+}
+
 // ============================================================================
 // INTEGRATION - Append then Read
 // ============================================================================
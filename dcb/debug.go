@@ -0,0 +1,81 @@
+package dcb
+
+import "slices"
+
+// DebugTag marks events whose payload is logged when debug tracing is enabled
+const DebugTag = "debug"
+
+// DebugSampler decides whether an event is traced
+type DebugSampler func(Event) bool
+
+// DataRedactor returns the payload of an event as it may appear in logs (e.g. with personal data masked)
+type DataRedactor func(Event) []byte
+
+// HasDebugTag is the default DebugSampler: it traces events tagged with DebugTag
+func HasDebugTag(e Event) bool {
+	return slices.Contains(e.Tags, DebugTag)
+}
+
+// WithDebugTracing logs full payloads (at Debug level) of appended and read events selected by sample,
+// along with the append conditions evaluated for them. A nil sampler defaults to HasDebugTag.
+// Untraced events cost a single predicate call.
+func (StoreOptions) WithDebugTracing(sample DebugSampler) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		if sample == nil {
+			sample = HasDebugTag
+		}
+		e.debugSampler = sample
+	}
+}
+
+// WithDataRedactor makes the store log payloads through r instead of verbatim
+func (StoreOptions) WithDataRedactor(r DataRedactor) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.redactor = r
+	}
+}
+
+// loggedData returns the payload of e as it may be logged
+func (s fdbStore) loggedData(e Event) string {
+	if s.redactor == nil {
+		return string(e.Data)
+	}
+	return string(s.redactor(e))
+}
+
+// tracedEvents returns the events selected by the debug sampler
+func (s fdbStore) tracedEvents(events []Event) []Event {
+	if s.debugSampler == nil {
+		return nil
+	}
+	var traced []Event
+	for _, e := range events {
+		if s.debugSampler(e) {
+			traced = append(traced, e)
+		}
+	}
+	return traced
+}
+
+// traceAppend logs the payloads of traced events and the conditions they were checked against
+func (s fdbStore) traceAppend(traced []Event, conditions []AppendCondition, err error) {
+	for _, cond := range conditions {
+		s.logger.Debug("append condition evaluated", "query", cond.Query, "after", cond.After, "error", err)
+	}
+	for _, e := range traced {
+		s.logger.Debug("append traced", "type", e.Type, "tags", e.Tags, "data", s.loggedData(e), "error", err)
+	}
+}
+
+// traceRead wraps yield to log the payload of traced events along with the query that matched them
+func (s fdbStore) traceRead(query Query, yield func(StoredEvent, error) bool) func(StoredEvent, error) bool {
+	if s.debugSampler == nil {
+		return yield
+	}
+	return func(e StoredEvent, err error) bool {
+		if err == nil && s.debugSampler(e.Event) {
+			s.logger.Debug("read traced", "query", query, "position", e.Position, "type", e.Type, "tags", e.Tags, "data", s.loggedData(e.Event))
+		}
+		return yield(e, err)
+	}
+}
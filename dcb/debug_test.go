@@ -0,0 +1,55 @@
+package dcb_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger keeps the messages logged at debug level
+type recordingLogger struct {
+	mu     sync.Mutex
+	debugs []string
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debugs = append(l.debugs, msg)
+}
+func (l *recordingLogger) Info(string, ...any)  {}
+func (l *recordingLogger) Warn(string, ...any)  {}
+func (l *recordingLogger) Error(string, ...any) {}
+
+func TestDebugTracing_OnlyTracesSampledEvents(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	logger := &recordingLogger{}
+	dcb.StoreOptions{}.WithLogger(logger)(store)
+	dcb.StoreOptions{}.WithDebugTracing(nil)(store)
+
+	// When - an untraced append, then a traced conditional append
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "plain", Tags: []string{"a"}}}))
+	assert.Empty(tt, logger.debugs)
+
+	condition := dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{{Types: []string{"nothing"}}}}}
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "traced", Tags: []string{dcb.DebugTag}}}, condition))
+	dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"plain", "traced"}}}}, nil))
+
+	// Then
+	assert.Equal(tt, []string{"append condition evaluated", "append traced", "read traced"}, logger.debugs)
+}
+
+func TestHasDebugTag(tt *testing.T) {
+	tt.Parallel()
+
+	assert.True(tt, dcb.HasDebugTag(dcb.Event{Tags: []string{"a", dcb.DebugTag}}))
+	assert.False(tt, dcb.HasDebugTag(dcb.Event{Tags: []string{"a"}}))
+}
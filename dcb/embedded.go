@@ -0,0 +1,578 @@
+package dcb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"go.etcd.io/bbolt"
+)
+
+// embeddedFetchBatch is the number of events fetched per read transaction:
+// events are yielded outside transactions, so handlers can append while reading
+const embeddedFetchBatch = 256
+
+// EmbeddedStore is a DcbStore kept in a local bbolt file, for prototypes and edge deployments
+// running without a FoundationDB cluster.
+//
+// Appends are serialized (single writer), so append conditions are checked exactly;
+// queries are answered from simple type and tag indexes and are slower than with FoundationDB.
+// Database returns a zero fdb.Database: features storing their own state in FoundationDB
+// (automations, idempotency, effects, snapshots) are not available.
+type EmbeddedStore interface {
+	DcbStore
+	Close() error
+}
+
+// embeddedStore buckets:
+// namespace/e: (position) -> encoded event
+// namespace/t: (type, position) -> nil
+// namespace/g: (tag, position) -> type
+// namespace/s: (position) -> supersession
+type embeddedStore struct {
+	db        *bbolt.DB
+	namespace string
+}
+
+// embeddedRecord is the stored form of an event
+type embeddedRecord struct {
+	Type        string            `json:"type"`
+	Tags        []string          `json:"tags,omitempty"`
+	Data        []byte            `json:"data"`
+	CommittedAt int64             `json:"at"`
+	Metadata    map[string]string `json:"meta,omitempty"`
+}
+
+// embeddedSupersession is the stored form of a supersession
+type embeddedSupersession struct {
+	Reason string `json:"reason"`
+	At     int64  `json:"at"`
+}
+
+var (
+	embeddedEvents = []byte("e")
+	embeddedByType = []byte("t")
+	embeddedByTag  = []byte("g")
+	embeddedSuper  = []byte("s")
+)
+
+// OpenEmbeddedStore opens (or creates) the store kept in the file at path.
+// A file can hold several namespaces, but only one process can open it at a time.
+func OpenEmbeddedStore(path, namespace string) (EmbeddedStore, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{embeddedEvents, embeddedByType, embeddedByTag, embeddedSuper} {
+			if _, err := root.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating namespace %q: %w", namespace, err)
+	}
+
+	return &embeddedStore{db: db, namespace: namespace}, nil
+}
+
+func (s *embeddedStore) Database() fdb.Database { return fdb.Database{} }
+func (s *embeddedStore) Namespace() string      { return s.namespace }
+func (s *embeddedStore) Close() error           { return s.db.Close() }
+
+// embeddedBuckets are the buckets of the store's namespace in a transaction
+type embeddedBuckets struct {
+	root, events, byType, byTag, superseded *bbolt.Bucket
+}
+
+func (s *embeddedStore) buckets(tx *bbolt.Tx) embeddedBuckets {
+	root := tx.Bucket([]byte(s.namespace))
+	return embeddedBuckets{
+		root:       root,
+		events:     root.Bucket(embeddedEvents),
+		byType:     root.Bucket(embeddedByType),
+		byTag:      root.Bucket(embeddedByTag),
+		superseded: root.Bucket(embeddedSuper),
+	}
+}
+
+// embeddedPosition is the position of the i-th event of the seq-th append
+func embeddedPosition(seq uint64, i int) Versionstamp {
+	var vs Versionstamp
+	binary.BigEndian.PutUint64(vs[2:10], seq)
+	binary.BigEndian.PutUint16(vs[10:12], uint16(i))
+	return vs
+}
+
+// indexPrefix is the prefix of the index keys of a type or tag: [len:2][name]
+func indexPrefix(name string) []byte {
+	prefix := make([]byte, 2, 2+len(name)+len(Versionstamp{}))
+	binary.BigEndian.PutUint16(prefix, uint16(len(name)))
+	return append(prefix, name...)
+}
+
+func indexKey(name string, vs Versionstamp) []byte {
+	return append(indexPrefix(name), vs[:]...)
+}
+
+// Append atomically appends events, checking the conditions in the same write transaction
+func (s *embeddedStore) Append(ctx context.Context, events []Event, conditions ...AppendCondition) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return ErrEmptyEvents
+	}
+	if len(transactionHooksFrom(ctx)) > 0 {
+		return ErrTransactionHooksUnsupported
+	}
+	if len(events) > MaxEventsPerTransaction {
+		return fmt.Errorf("%w: %w: %d events (limit %d per transaction)",
+			ErrTransactionTooLarge, ErrTooManyEvents, len(events), MaxEventsPerTransaction)
+	}
+	for i, cond := range conditions {
+		if err := cond.Query.Validate(); err != nil {
+			return fmt.Errorf("condition %d: %w", i, err)
+		}
+	}
+	for i, event := range events {
+		if event.Type == "" {
+			return errors.New("event must have a type")
+		}
+		for _, tag := range event.Tags {
+			if err := ValidateTag(tag); err != nil {
+				return fmt.Errorf("event %d: %w", i, err)
+			}
+		}
+		if _, ok := event.Metadata[""]; ok {
+			return fmt.Errorf("event %d: %w: empty key", i, ErrInvalidMetadata)
+		}
+	}
+	events = canonicalizeTags(events)
+
+	committedAt := time.Now().Round(0)
+	positions := make([]Versionstamp, len(events))
+	if err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		for _, cond := range conditions {
+			if b.exists(cond.Query, cond.After) {
+				return ErrAppendConditionFailed
+			}
+		}
+
+		seq, err := b.root.NextSequence()
+		if err != nil {
+			return err
+		}
+		for i, event := range events {
+			positions[i] = embeddedPosition(seq, i)
+			if err := b.put(event, positions[i], committedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if recorder := appendedPositionsFrom(ctx); recorder != nil {
+		recorder.record(positions)
+	}
+	return nil
+}
+
+// put writes an event and its indexes
+func (b embeddedBuckets) put(event Event, vs Versionstamp, committedAt time.Time) error {
+	value, err := json.Marshal(embeddedRecord{Type: event.Type, Tags: event.Tags, Data: event.Data, CommittedAt: committedAt.UnixNano(), Metadata: event.Metadata})
+	if err != nil {
+		return err
+	}
+	if err := b.events.Put(vs[:], value); err != nil {
+		return err
+	}
+	if err := b.byType.Put(indexKey(event.Type, vs), nil); err != nil {
+		return err
+	}
+	for _, tag := range event.Tags {
+		if err := b.byTag.Put(indexKey(tag, vs), []byte(event.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exists reports whether an event matches the query after the position
+func (b embeddedBuckets) exists(query Query, after *Versionstamp) bool {
+	for _, item := range query.Items {
+		found := false
+		b.matchItem(item, after, func(Versionstamp) bool {
+			found = true
+			return false
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// matchItem calls fn with the position of every event matching item after the position,
+// in index order, until fn returns false
+func (b embeddedBuckets) matchItem(item QueryItem, after *Versionstamp, fn func(Versionstamp) bool) {
+	if len(item.TagKeys) > 0 {
+		// the callers merge the positions of the items, the ones found twice included
+		items, _ := expandTagKeys(item, func(key string) ([]string, error) { return b.tagsOfKey(key), nil })
+		for _, concrete := range items {
+			stopped := false
+			b.matchItem(concrete, after, func(vs Versionstamp) bool {
+				stopped = !fn(vs)
+				return !stopped
+			})
+			if stopped {
+				return
+			}
+		}
+		return
+	}
+
+	after = laterVersionstamp(after, item.After)
+	if len(item.Tags) == 0 {
+		for _, typ := range item.Types {
+			if !scanIndex(b.byType, typ, after, func(vs Versionstamp, _ []byte) bool { return fn(vs) }) {
+				return
+			}
+		}
+		return
+	}
+
+	// scan the events with the first tag, the other tags are point lookups
+	scanIndex(b.byTag, item.Tags[0], after, func(vs Versionstamp, typ []byte) bool {
+		if len(item.Types) > 0 && !slices.Contains(item.Types, string(typ)) {
+			return true
+		}
+		for _, tag := range item.Tags[1:] {
+			if b.byTag.Get(indexKey(tag, vs)) == nil {
+				return true
+			}
+		}
+		return fn(vs)
+	})
+}
+
+// tagsOfKey returns the structured tags of key in use. The index is ordered by tag length first:
+// it jumps from tag to tag over the whole index.
+func (b embeddedBuckets) tagsOfKey(key string) []string {
+	var tags []string
+	c := b.byTag.Cursor()
+	for k, _ := c.First(); len(k) >= 2; {
+		n := int(binary.BigEndian.Uint16(k))
+		if len(k) < 2+n {
+			break
+		}
+		tag := string(k[2 : 2+n])
+		if strings.HasPrefix(tag, key+TagSeparator) {
+			tags = append(tags, tag)
+		}
+		end := prefixEnd(indexPrefix(tag))
+		if end == nil {
+			break
+		}
+		k, _ = c.Seek(end)
+	}
+	return tags
+}
+
+// scanIndex calls fn with the positions indexed under name after the position, in ascending order,
+// until fn returns false. Returns false if fn stopped the scan.
+func scanIndex(index *bbolt.Bucket, name string, after *Versionstamp, fn func(Versionstamp, []byte) bool) bool {
+	prefix := indexPrefix(name)
+	seek := prefix
+	if after != nil {
+		seek = indexKey(name, *after)
+	}
+
+	c := index.Cursor()
+	for k, v := c.Seek(seek); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if len(k) != len(prefix)+len(Versionstamp{}) {
+			continue
+		}
+		vs := Versionstamp(k[len(prefix):])
+		if after != nil && vs.Compare(*after) <= 0 {
+			continue
+		}
+		if !fn(vs, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExplainQuery returns the index ranges a Read of query would scan, with the number of keys in each.
+// Items with tags scan the events of their first tag, checking the others for each.
+// Items with tag keys scan those of each combination of values of the keys.
+func (s *embeddedStore) ExplainQuery(ctx context.Context, query Query) (QueryPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return QueryPlan{}, err
+	}
+	if err := query.Validate(); err != nil {
+		return QueryPlan{}, err
+	}
+
+	var plan QueryPlan
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		for _, item := range query.Items {
+			itemPlan := QueryItemPlan{Item: item, Index: "type"}
+			items := []QueryItem{item}
+			if len(item.TagKeys) > 0 {
+				itemPlan.Index = "tag"
+				items, _ = expandTagKeys(item, func(key string) ([]string, error) { return b.tagsOfKey(key), nil })
+			}
+			for _, concrete := range items {
+				if len(concrete.Tags) == 0 {
+					for _, typ := range concrete.Types {
+						itemPlan.Ranges = append(itemPlan.Ranges, explainIndex(b.byType, "t", typ, concrete.After))
+					}
+				} else {
+					itemPlan.Index = "tag"
+					itemPlan.Ranges = append(itemPlan.Ranges, explainIndex(b.byTag, "g", concrete.Tags[0], concrete.After))
+				}
+			}
+			plan.Items = append(plan.Items, itemPlan)
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// explainIndex describes the scan of the keys indexed under name
+func explainIndex(index *bbolt.Bucket, bucket, name string, after *Versionstamp) RangePlan {
+	prefix := indexPrefix(name)
+	r := RangePlan{Path: []string{bucket, name}, Begin: prefix, End: prefixEnd(prefix)}
+	if after != nil {
+		r.Path = append(r.Path, "after "+after.String())
+	}
+	scanIndex(index, name, after, func(Versionstamp, []byte) bool {
+		if r.Keys == ExplainKeyLimit {
+			r.More = true
+			return false
+		}
+		r.Keys++
+		return true
+	})
+	return r
+}
+
+// prefixEnd returns the first key after every key starting with prefix
+func prefixEnd(prefix []byte) []byte {
+	end := bytes.TrimRight(prefix, "\xff")
+	if len(end) == 0 {
+		return nil
+	}
+	end = slices.Clone(end)
+	end[len(end)-1]++
+	return end
+}
+
+// Read returns events matching the query as an iterator sequence.
+// The matching positions are collected first, then events are fetched in batches.
+func (s *embeddedStore) Read(ctx context.Context, query Query, opts *ReadOptions) iter.Seq2[StoredEvent, error] {
+	return func(yield func(StoredEvent, error) bool) {
+		if err := ctx.Err(); err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+		if err := query.Validate(); err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+		if opts == nil {
+			opts = &ReadOptions{}
+		}
+
+		var positions []Versionstamp
+		if err := s.db.View(func(tx *bbolt.Tx) error {
+			b := s.buckets(tx)
+			seen := make(map[Versionstamp]bool)
+			for _, item := range query.Items {
+				b.matchItem(item, opts.After, func(vs Versionstamp) bool {
+					if !seen[vs] {
+						seen[vs] = true
+						positions = append(positions, vs)
+					}
+					return true
+				})
+			}
+			return nil
+		}); err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+
+		slices.SortFunc(positions, Versionstamp.Compare)
+		if opts.Reverse {
+			slices.Reverse(positions)
+		}
+		if opts.Limit > 0 && len(positions) > opts.Limit {
+			positions = positions[:opts.Limit]
+		}
+
+		for chunk := range slices.Chunk(positions, embeddedFetchBatch) {
+			if err := ctx.Err(); err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+			events, err := s.fetch(chunk)
+			if err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+			for _, event := range events {
+				if !yield(event, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FetchEvent returns the event at position
+func (s *embeddedStore) FetchEvent(ctx context.Context, position Versionstamp) (StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return StoredEvent{}, err
+	}
+	events, err := s.fetch([]Versionstamp{position})
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	return events[0], nil
+}
+
+// fetch returns the events at the positions
+func (s *embeddedStore) fetch(positions []Versionstamp) ([]StoredEvent, error) {
+	events := make([]StoredEvent, 0, len(positions))
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		for _, vs := range positions {
+			value := b.events.Get(vs[:])
+			if value == nil {
+				return fmt.Errorf("event at versionstamp %x: not found", vs[:])
+			}
+			event, err := b.decode(vs, value)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// ReadAll returns all events in the store as an iterator sequence, ordered by position
+func (s *embeddedStore) ReadAll(ctx context.Context) iter.Seq2[StoredEvent, error] {
+	return func(yield func(StoredEvent, error) bool) {
+		var after []byte
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+
+			events := make([]StoredEvent, 0, embeddedFetchBatch)
+			if err := s.db.View(func(tx *bbolt.Tx) error {
+				b := s.buckets(tx)
+				c := b.events.Cursor()
+				k, v := c.First()
+				if after != nil {
+					k, v = c.Seek(after)
+					if bytes.Equal(k, after) {
+						k, v = c.Next()
+					}
+				}
+				for ; k != nil && len(events) < embeddedFetchBatch; k, v = c.Next() {
+					event, err := b.decode(Versionstamp(k), v)
+					if err != nil {
+						return err
+					}
+					events = append(events, event)
+				}
+				return nil
+			}); err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+
+			for _, event := range events {
+				if !yield(event, nil) {
+					return
+				}
+			}
+			if len(events) < embeddedFetchBatch {
+				return
+			}
+			last := events[len(events)-1].Position
+			after = last[:]
+		}
+	}
+}
+
+// decode decodes the event at vs with its supersession
+func (b embeddedBuckets) decode(vs Versionstamp, value []byte) (StoredEvent, error) {
+	var record embeddedRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return StoredEvent{}, fmt.Errorf("event at versionstamp %x: %w", vs[:], err)
+	}
+	if len(record.Data) == 0 {
+		record.Data = nil // as read back from FoundationDB
+	}
+	event := StoredEvent{
+		Event:       Event{Type: record.Type, Tags: record.Tags, Data: record.Data, Metadata: record.Metadata},
+		Position:    vs,
+		CommittedAt: time.Unix(0, record.CommittedAt),
+	}
+	if value := b.superseded.Get(vs[:]); value != nil {
+		var supersession embeddedSupersession
+		if err := json.Unmarshal(value, &supersession); err != nil {
+			return StoredEvent{}, fmt.Errorf("supersession of versionstamp %x: %w", vs[:], err)
+		}
+		event.Superseded = &Supersession{Reason: supersession.Reason, At: time.Unix(0, supersession.At)}
+	}
+	return event, nil
+}
+
+// Supersede marks the event at position as superseded
+func (s *embeddedStore) Supersede(ctx context.Context, position Versionstamp, reason string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		if b.events.Get(position[:]) == nil {
+			return fmt.Errorf("%w at versionstamp %x", ErrEventNotFound, position[:])
+		}
+		if b.superseded.Get(position[:]) != nil {
+			return nil
+		}
+		value, err := json.Marshal(embeddedSupersession{Reason: reason, At: time.Now().UnixNano()})
+		if err != nil {
+			return err
+		}
+		return b.superseded.Put(position[:], value)
+	})
+}
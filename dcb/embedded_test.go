@@ -0,0 +1,202 @@
+package dcb_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func setupEmbeddedStore(t *testing.T) dcb.EmbeddedStore {
+	t.Helper()
+	store, err := dcb.OpenEmbeddedStore(filepath.Join(t.TempDir(), "events.db"), "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// matches reports whether the event matches the query item (reference implementation)
+func matches(e dcb.Event, item dcb.QueryItem) bool {
+	if len(item.Types) > 0 && !slices.Contains(item.Types, e.Type) {
+		return false
+	}
+	for _, tag := range item.Tags {
+		if !slices.Contains(e.Tags, tag) {
+			return false
+		}
+	}
+	for _, key := range item.TagKeys {
+		if !slices.ContainsFunc(e.Tags, func(tag string) bool { return strings.HasPrefix(tag, key+dcb.TagSeparator) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEmbeddedStore_ReadsMatchingEvents(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		ctx := context.Background()
+		store := setupEmbeddedStore(tt)
+		events := dcb.RandomEvents(t)
+		require.NoError(t, store.Append(ctx, events[:len(events)/2]))
+		require.NoError(t, store.Append(ctx, events[len(events)/2:]))
+		item := dcb.QueryItem{
+			Types:   rapid.SliceOfNDistinct(rapid.SampledFrom([]string{"item_updated", "task_created", "order_placed"}), 0, 2, rapid.ID[string]).Draw(t, "types"),
+			Tags:    rapid.SliceOfNDistinct(rapid.SampledFrom([]string{"list:1", "user:123", "tenant:abc"}), 1, 2, rapid.ID[string]).Draw(t, "tags"),
+			TagKeys: rapid.SliceOfNDistinct(rapid.SampledFrom([]string{"list", "user", "tenant"}), 0, 2, rapid.ID[string]).Draw(t, "tag keys"),
+		}
+		if len(item.TagKeys) == 0 {
+			item.TagKeys = nil
+		}
+		reverse := rapid.Bool().Draw(t, "reverse")
+
+		// When
+		read := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{item}}, &dcb.ReadOptions{Reverse: reverse}))
+
+		// Then - exactly the matching events, in order
+		all := dcb.CollectEvents(tt, store.ReadAll(ctx))
+		assert.True(t, dcb.EventsAreStriclyOrdered(all))
+		var expected []dcb.StoredEvent
+		for _, e := range all {
+			if matches(e.Event, item) {
+				expected = append(expected, e)
+			}
+		}
+		if reverse {
+			slices.Reverse(expected)
+		}
+		assert.Equal(t, expected, read)
+	})
+}
+
+func TestEmbeddedStore_AppendCondition(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := setupEmbeddedStore(t)
+	query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"list_created"}, Tags: []string{"list:1"}}}}
+	require.NoError(t, store.Append(ctx, []dcb.Event{{Type: "list_created", Tags: []string{"list:1"}}}))
+	stored := dcb.CollectEvents(t, store.ReadAll(ctx))
+
+	// When - the condition saw nothing
+	err := store.Append(ctx, []dcb.Event{{Type: "list_created", Tags: []string{"list:1"}}}, dcb.AppendCondition{Query: query})
+
+	// Then
+	assert.ErrorIs(t, err, dcb.ErrAppendConditionFailed)
+
+	// When - the condition saw the event
+	err = store.Append(ctx, []dcb.Event{{Type: "list_renamed", Tags: []string{"list:1"}}}, dcb.AppendCondition{Query: query, After: &stored[0].Position})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(ctx)), 2)
+}
+
+func TestEmbeddedStore_ReadOptions(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := setupEmbeddedStore(t)
+	for range 5 {
+		require.NoError(t, store.Append(ctx, []dcb.Event{{Type: "item_added", Tags: []string{"list:1"}}}))
+	}
+	all := dcb.CollectEvents(t, store.ReadAll(ctx))
+	query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}
+
+	// When
+	latest := dcb.CollectEvents(t, store.Read(ctx, query, &dcb.ReadOptions{Reverse: true, Limit: 1}))
+	after := dcb.CollectEvents(t, store.Read(ctx, query, &dcb.ReadOptions{After: &all[2].Position}))
+
+	// Then
+	assert.Equal(t, all[4:], latest)
+	assert.Equal(t, all[3:], after)
+}
+
+func TestEmbeddedStore_PersistsAcrossReopens(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := dcb.OpenEmbeddedStore(path, "test")
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, []dcb.Event{{Type: "list_created", Tags: []string{"list:1"}, Data: []byte("{}"), Metadata: map[string]string{"actor": "user:42"}}}))
+	require.NoError(t, store.Close())
+
+	// When
+	store, err = dcb.OpenEmbeddedStore(path, "test")
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Append(ctx, []dcb.Event{{Type: "list_renamed", Tags: []string{"list:1"}}}))
+
+	// Then - positions keep increasing
+	all := dcb.CollectEvents(t, store.ReadAll(ctx))
+	require.Len(t, all, 2)
+	assert.Equal(t, []byte("{}"), all[0].Data)
+	assert.Equal(t, map[string]string{"actor": "user:42"}, all[0].Metadata)
+	assert.Nil(t, all[1].Metadata)
+	assert.True(t, dcb.EventsAreStriclyOrdered(all))
+}
+
+func TestEmbeddedStore_ExplainQuery(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := setupEmbeddedStore(t)
+	require.NoError(t, store.Append(ctx, []dcb.Event{
+		{Type: "item_added", Tags: []string{"list:1"}},
+		{Type: "item_added", Tags: []string{"list:2"}},
+	}))
+	explainer, ok := store.(dcb.QueryExplainer)
+	require.True(t, ok)
+
+	// When
+	plan, err := explainer.ExplainQuery(ctx, dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"item_added"}},
+		{Types: []string{"item_added"}, Tags: []string{"list:1"}},
+	}})
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, plan.Items, 2)
+	assert.Equal(t, []string{"t", "item_added"}, plan.Items[0].Ranges[0].Path)
+	assert.Equal(t, 2, plan.Items[0].Ranges[0].Keys)
+	assert.Equal(t, []string{"g", "list:1"}, plan.Items[1].Ranges[0].Path)
+	assert.Equal(t, 1, plan.Items[1].Ranges[0].Keys)
+}
+
+func TestEmbeddedStore_Supersede(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := setupEmbeddedStore(t)
+	require.NoError(t, store.Append(ctx, []dcb.Event{
+		{Type: "price_set", Tags: []string{"product:1"}},
+		{Type: "price_set", Tags: []string{"product:1"}},
+	}))
+	positions := dcb.CollectEvents(t, store.ReadAll(ctx))
+
+	// When
+	err := store.(dcb.Superseder).Supersede(ctx, positions[0].Position, "typo")
+
+	// Then
+	require.NoError(t, err)
+	read := dcb.CollectEvents(t, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"price_set"}}}}, nil))
+	require.Len(t, read, 2)
+	require.NotNil(t, read[0].Superseded)
+	assert.Equal(t, "typo", read[0].Superseded.Reason)
+	assert.Nil(t, read[1].Superseded)
+	assert.ErrorIs(t, store.(dcb.Superseder).Supersede(ctx, dcb.Versionstamp{1}, "typo"), dcb.ErrEventNotFound)
+}
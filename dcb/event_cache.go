@@ -0,0 +1,174 @@
+package dcb
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// EventCacheMetrics is optionally implemented by Metrics to observe the event cache hit rate
+type EventCacheMetrics interface {
+	RecordEventCacheHit()
+	RecordEventCacheMiss()
+}
+
+// EventFetcher is implemented by stores able to return the event at a position
+// (e.g. the trigger event of an automation job)
+type EventFetcher interface {
+	FetchEvent(ctx context.Context, position Versionstamp) (StoredEvent, error)
+}
+
+// WithEventCache keeps up to maxEvents recently fetched events in process, keyed by position.
+// Reads served from the cache skip the event lookup, only the indexes are read.
+// Events only change through Upgrade, which rewrites the tags of namespaces in a format before 2:
+// those namespaces are read without the cache. Events deleted by ArchiveEvents leave the indexes
+// in the same transaction, and FetchEvent checks the archive boundary before using the cache.
+// ReadAll streams the whole log without the cache, so full scans don't evict hot events.
+// Cached events are shared by every read returning them: callers must not mutate their Tags or Data.
+func (StoreOptions) WithEventCache(maxEvents int) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		if maxEvents > 0 {
+			e.cache = &eventCache{
+				maxEvents: maxEvents,
+				entries:   make(map[Versionstamp]*list.Element),
+				lru:       list.New(),
+			}
+		}
+	}
+}
+
+// eventCache is an LRU of decoded events
+type eventCache struct {
+	mu        sync.Mutex
+	maxEvents int
+	entries   map[Versionstamp]*list.Element
+	lru       *list.List // of StoredEvent, most recently used first
+}
+
+func (c *eventCache) get(vs Versionstamp) (StoredEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[vs]
+	if !ok {
+		return StoredEvent{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(StoredEvent), true
+}
+
+func (c *eventCache) put(event StoredEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[event.Position]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[event.Position] = c.lru.PushFront(event)
+	if c.lru.Len() > c.maxEvents {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(StoredEvent).Position)
+	}
+}
+
+// remove drops the events at the given positions
+func (c *eventCache) remove(positions ...Versionstamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, vs := range positions {
+		if elem, ok := c.entries[vs]; ok {
+			c.lru.Remove(elem)
+			delete(c.entries, vs)
+		}
+	}
+}
+
+// clear drops every event
+func (c *eventCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// caching returns the cache, nil when disabled or when the namespace format may have tags rewritten by Upgrade
+func (s fdbStore) caching() *eventCache {
+	if s.cache == nil || s.format.version.Load() < canonicalTagsFormatVersion {
+		return nil
+	}
+	return s.cache
+}
+
+// cachedEvent returns the cached event at vs, recording the lookup
+func (s fdbStore) cachedEvent(vs Versionstamp) (StoredEvent, bool) {
+	cache := s.caching()
+	if cache == nil {
+		return StoredEvent{}, false
+	}
+	event, ok := cache.get(vs)
+	if cm, isCacheMetrics := s.metrics.(EventCacheMetrics); isCacheMetrics {
+		if ok {
+			cm.RecordEventCacheHit()
+		} else {
+			cm.RecordEventCacheMiss()
+		}
+	}
+	return event, ok
+}
+
+// FetchEvent returns the event at position, from the cache when enabled.
+// Its supersession and sequence are always read from the store: they can change after the event was cached.
+func (s fdbStore) FetchEvent(ctx context.Context, position Versionstamp) (StoredEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return StoredEvent{}, err
+	}
+	if err := s.checkFormat(); err != nil {
+		return StoredEvent{}, err
+	}
+
+	release, err := s.acquireSlot(ctx, "read")
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	defer release()
+
+	event, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		if err := s.uncacheArchived(tr, position); err != nil {
+			return nil, err
+		}
+		event, err := s.fetchEvent(ctx, tr, position)
+		if err != nil {
+			return nil, err
+		}
+		if event, err = s.withSupersession(tr, event); err != nil {
+			return nil, err
+		}
+		return s.withSequence(tr, event)
+	})
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	return event.(StoredEvent), nil
+}
+
+// uncacheArchived drops the event at position from the cache when it was archived, by any process:
+// its lookup then reports it missing
+func (s fdbStore) uncacheArchived(tr fdb.ReadTransaction, position Versionstamp) error {
+	if s.cache == nil || s.archive == nil {
+		return nil
+	}
+	until, err := s.archive.readUntil(tr)
+	if err != nil {
+		return err
+	}
+	if until != nil && position.Compare(*until) <= 0 {
+		s.cache.remove(position)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package dcb_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheMetrics counts event cache lookups
+type cacheMetrics struct {
+	hits, misses atomic.Int32
+}
+
+func (m *cacheMetrics) RecordAppendDuration(time.Duration, bool) {}
+func (m *cacheMetrics) RecordAppendEvents(int)                   {}
+func (m *cacheMetrics) RecordReadDuration(time.Duration, bool)   {}
+func (m *cacheMetrics) RecordReadEvents(int)                     {}
+func (m *cacheMetrics) RecordError(string, string)               {}
+func (m *cacheMetrics) RecordEventCacheHit()                     { m.hits.Add(1) }
+func (m *cacheMetrics) RecordEventCacheMiss()                    { m.misses.Add(1) }
+
+func TestEventCache_ServesRepeatedReads(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	metrics := &cacheMetrics{}
+	dcb.StoreOptions{}.WithMetrics(metrics)(store)
+	dcb.StoreOptions{}.WithEventCache(2)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "item_added", Tags: []string{"list:1"}, Data: []byte("1")},
+		{Type: "item_added", Tags: []string{"list:1"}, Data: []byte("2")},
+		{Type: "item_added", Tags: []string{"list:1"}, Data: []byte("3")},
+	}))
+	query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}
+
+	// When - the latest two events are read twice
+	first := dcb.CollectEvents(tt, store.Read(ctx, query, &dcb.ReadOptions{Reverse: true, Limit: 2}))
+	second := dcb.CollectEvents(tt, store.Read(ctx, query, &dcb.ReadOptions{Reverse: true, Limit: 2}))
+
+	// Then - the second read was served from the cache
+	assert.Equal(tt, first, second)
+	assert.Equal(tt, int32(2), metrics.misses.Load())
+	assert.Equal(tt, int32(2), metrics.hits.Load())
+
+	// When - fetched by position
+	fetched, err := store.FetchEvent(ctx, first[0].Position)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, first[0], fetched)
+	assert.Equal(tt, int32(3), metrics.hits.Load())
+
+	// When - every event is read: the first one evicts the least recently used (the second)
+	all := dcb.CollectEvents(tt, store.Read(ctx, query, nil))
+
+	// Then
+	require.Len(tt, all, 3)
+	assert.Equal(tt, []byte("1"), all[0].Data)
+	assert.Equal(tt, int32(4), metrics.misses.Load())
+	assert.Equal(tt, int32(4), metrics.hits.Load())
+}
+
+func TestEventCache_EventsArchivedByAnotherProcessAreNotServed(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - an event cached by a process
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	objects := newMemoryObjects()
+	dcb.StoreOptions{}.WithArchive(objects)(store)
+	dcb.StoreOptions{}.WithEventCache(10)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "order_placed", Tags: []string{"order:1"}}}))
+	events := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"order_placed"}}}}, nil))
+	require.Len(tt, events, 1)
+
+	// When - another process archives it
+	other := dcb.NewDcbStore(store.Database(), store.Namespace(), dcb.StoreOptions{}.WithArchive(objects))
+	_, err := dcb.ArchiveEvents(ctx, other, time.Now().Add(time.Hour))
+	require.NoError(tt, err)
+
+	// Then
+	_, err = store.FetchEvent(ctx, events[0].Position)
+	assert.Error(tt, err)
+}
+
+func TestEventCache_TagsRewrittenByAnUpgradeAreNotServedStale(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a legacy namespace read by a process with a cache
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithEventCache(10)(store)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		key, err := subspace.Sub(store.Namespace()).Sub("e").PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)})
+		if err != nil {
+			return nil, err
+		}
+		tr.SetVersionstampedKey(key, tuple.Tuple{"legacy", tuple.Tuple{"list:2", "cart:1"}, []byte("{}")}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+	events := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, events, 1)
+	before, err := store.FetchEvent(ctx, events[0].Position)
+	require.NoError(tt, err)
+
+	// When - another process upgrades it
+	_, err = dcb.NewDcbStore(store.Database(), store.Namespace()).(dcb.Upgrader).Upgrade(ctx)
+	require.NoError(tt, err)
+
+	// Then
+	after, err := store.FetchEvent(ctx, events[0].Position)
+	require.NoError(tt, err)
+	assert.Equal(tt, []string{"list:2", "cart:1"}, before.Tags)
+	assert.Equal(tt, []string{"cart:1", "list:2"}, after.Tags)
+}
@@ -0,0 +1,315 @@
+package dcb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+// Type codes of the tuple layer, for the elements of event values and keys
+const (
+	tupleBytesCode        = 0x01
+	tupleStringCode       = 0x02
+	tupleNestedCode       = 0x05
+	tupleIntZeroCode      = 0x14
+	tupleVersionstampCode = 0x33
+)
+
+// maxPooledDecoderBytes keeps decoders grown by huge tags out of the pool
+const maxPooledDecoderBytes = 64 << 10
+
+// eventDecoder holds the scratch buffers of decodeEvent, reused across events through decoderPool
+type eventDecoder struct {
+	text []byte // the type, the tags then the metadata keys and values, unescaped
+	ends []int  // end offset in text of the type, then of each tag, metadata key and value
+}
+
+var decoderPool = sync.Pool{New: func() any {
+	return &eventDecoder{text: make([]byte, 0, 256), ends: make([]int, 0, 8)}
+}}
+
+// decodeEvent decodes an event value: its type, tags, data, commit time and metadata packed as a tuple.
+//
+// The layout being known, it is decoded in place rather than through tuple.Unpack: the type, tags and metadata
+// share a single string allocation, and the data is copied once. Nothing in the event aliases encodedValue.
+// Values it doesn't expect are left to tuple.Unpack, which reports what is wrong with them.
+func decodeEvent(ctx context.Context, encodedValue []byte) (Event, time.Time, error) {
+	d := decoderPool.Get().(*eventDecoder)
+	event, committedAt, ok := d.decode(encodedValue)
+	if cap(d.text) <= maxPooledDecoderBytes {
+		decoderPool.Put(d)
+	}
+	if ok {
+		return event, committedAt, nil
+	}
+	return decodeEventTuple(ctx, encodedValue)
+}
+
+// DecodeStoredEvent decodes the value of the event at position, as stored in the events subspace of a store.
+// It serves the components reading that subspace directly, e.g. to scan it by ranges.
+func DecodeStoredEvent(position Versionstamp, encodedValue []byte) (StoredEvent, error) {
+	event, committedAt, err := decodeEvent(context.Background(), encodedValue)
+	if err != nil {
+		return StoredEvent{}, err
+	}
+	return StoredEvent{Event: event, Position: position, CommittedAt: committedAt}, nil
+}
+
+// decode decodes b, false when it isn't a (string, tuple of strings, bytes[, non-negative int[, tuple of strings]]) tuple
+func (d *eventDecoder) decode(b []byte) (Event, time.Time, bool) {
+	d.text, d.ends = d.text[:0], d.ends[:0]
+
+	// type
+	if len(b) == 0 || b[0] != tupleStringCode {
+		return Event{}, time.Time{}, false
+	}
+	n, ok := d.appendUnescaped(b[1:])
+	if !ok {
+		return Event{}, time.Time{}, false
+	}
+	d.ends = append(d.ends, len(d.text))
+	b = b[1+n:]
+
+	// tags
+	n, ok = d.appendStrings(b)
+	if !ok {
+		return Event{}, time.Time{}, false
+	}
+	b = b[n:]
+	tagsEnd := len(d.ends)
+
+	// data
+	if len(b) == 0 || b[0] != tupleBytesCode {
+		return Event{}, time.Time{}, false
+	}
+	data, n, ok := unescapeBytes(b[1:])
+	if !ok {
+		return Event{}, time.Time{}, false
+	}
+	b = b[1+n:]
+
+	// commit time, absent from events stored before it was recorded
+	var committedAt time.Time
+	if len(b) > 0 {
+		ns, n, ok := decodeNonNegativeInt(b)
+		if !ok {
+			return Event{}, time.Time{}, false
+		}
+		committedAt = time.Unix(0, ns)
+		b = b[n:]
+	}
+
+	// metadata, absent from events without
+	if len(b) > 0 {
+		n, ok := d.appendStrings(b)
+		if !ok || n != len(b) || (len(d.ends)-tagsEnd)%2 != 0 {
+			return Event{}, time.Time{}, false
+		}
+	}
+
+	text := string(d.text)
+	event := Event{Type: text[:d.ends[0]], Tags: make([]string, tagsEnd-1), Data: data}
+	for i := range event.Tags {
+		event.Tags[i] = text[d.ends[i]:d.ends[i+1]]
+	}
+	if len(d.ends) > tagsEnd {
+		event.Metadata = make(map[string]string, (len(d.ends)-tagsEnd)/2)
+		for i := tagsEnd; i < len(d.ends); i += 2 {
+			event.Metadata[text[d.ends[i-1]:d.ends[i]]] = text[d.ends[i]:d.ends[i+1]]
+		}
+	}
+	return event, committedAt, true
+}
+
+// appendStrings appends the strings of the nested tuple starting b to d.text, recording their ends.
+// It returns the length of the tuple with its terminator.
+func (d *eventDecoder) appendStrings(b []byte) (int, bool) {
+	if len(b) == 0 || b[0] != tupleNestedCode {
+		return 0, false
+	}
+	consumed := 1
+	for {
+		if consumed == len(b) {
+			return 0, false
+		}
+		if b[consumed] == 0x00 {
+			if consumed+1 < len(b) && b[consumed+1] == 0xFF {
+				return 0, false // nil element
+			}
+			return consumed + 1, true
+		}
+		if b[consumed] != tupleStringCode {
+			return 0, false
+		}
+		n, ok := d.appendUnescaped(b[consumed+1:])
+		if !ok {
+			return 0, false
+		}
+		d.ends = append(d.ends, len(d.text))
+		consumed += 1 + n
+	}
+}
+
+// appendUnescaped appends the string or bytes element starting b, up to its 0x00 terminator, to d.text.
+// It returns the length of the element with its terminator.
+func (d *eventDecoder) appendUnescaped(b []byte) (int, bool) {
+	consumed := 0
+	for {
+		i := bytes.IndexByte(b[consumed:], 0x00)
+		if i < 0 {
+			return 0, false
+		}
+		d.text = append(d.text, b[consumed:consumed+i]...)
+		consumed += i + 1
+		if consumed < len(b) && b[consumed] == 0xFF { // escaped 0x00
+			d.text = append(d.text, 0x00)
+			consumed++
+			continue
+		}
+		return consumed, true
+	}
+}
+
+// unescapeBytes returns the bytes element starting b, and its length with its terminator.
+// The element is copied out of b, unescaped.
+func unescapeBytes(b []byte) ([]byte, int, bool) {
+	end := findTupleTerminator(b)
+	if end < 0 {
+		return nil, 0, false
+	}
+	if end == 0 {
+		return nil, 1, true
+	}
+	element := b[:end]
+	if bytes.IndexByte(element, 0x00) < 0 {
+		return bytes.Clone(element), end + 1, true
+	}
+	return bytes.ReplaceAll(element, []byte{0x00, 0xFF}, []byte{0x00}), end + 1, true
+}
+
+// findTupleTerminator returns the index of the 0x00 ending the escaped element starting b, -1 if none
+func findTupleTerminator(b []byte) int {
+	for offset := 0; ; {
+		i := bytes.IndexByte(b[offset:], 0x00)
+		if i < 0 {
+			return -1
+		}
+		offset += i
+		if offset+1 < len(b) && b[offset+1] == 0xFF {
+			offset += 2
+			continue
+		}
+		return offset
+	}
+}
+
+// decodeNonNegativeInt decodes the integer element starting b, and returns its length
+func decodeNonNegativeInt(b []byte) (int64, int, bool) {
+	n := int(b[0]) - tupleIntZeroCode
+	if n < 0 || n > 8 || len(b) < 1+n {
+		return 0, 0, false
+	}
+	var u uint64
+	for _, c := range b[1 : 1+n] {
+		u = u<<8 | uint64(c)
+	}
+	if u > math.MaxInt64 {
+		return 0, 0, false
+	}
+	return int64(u), 1 + n, true
+}
+
+// decodeEventTuple decodes an event value through tuple.Unpack
+func decodeEventTuple(ctx context.Context, encodedValue []byte) (Event, time.Time, error) {
+	// Decode event (type, tags, data, commit time, metadata)
+	eventTuple, err := tuple.Unpack(encodedValue)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Event{}, time.Time{}, ctx.Err()
+		}
+		return Event{}, time.Time{}, err
+	}
+
+	// Events stored before the commit time was recorded are 3-tuples, events without metadata 4-tuples
+	if len(eventTuple) < 3 || len(eventTuple) > 5 {
+		if ctx.Err() != nil {
+			return Event{}, time.Time{}, ctx.Err()
+		}
+		return Event{}, time.Time{}, fmt.Errorf("expected 3 to 5-tuple, got %d elements", len(eventTuple))
+	}
+
+	// Extract type
+	eventType, ok := eventTuple[0].(string)
+	if !ok {
+		if ctx.Err() != nil {
+			return Event{}, time.Time{}, ctx.Err()
+		}
+		return Event{}, time.Time{}, fmt.Errorf("type field is %T, expected string", eventTuple[0])
+	}
+
+	// Extract tags (comes as tuple.Tuple which is []interface{})
+	var tags []string
+	if eventTuple[1] != nil {
+		tagsTuple, ok := eventTuple[1].(tuple.Tuple)
+		if !ok {
+			if ctx.Err() != nil {
+				return Event{}, time.Time{}, ctx.Err()
+			}
+			return Event{}, time.Time{}, fmt.Errorf("event type %q: tags field is %T, expected tuple", eventType, eventTuple[1])
+		}
+
+		tags = make([]string, len(tagsTuple))
+		for i, t := range tagsTuple {
+			tag, ok := t.(string)
+			if !ok {
+				return Event{}, time.Time{}, fmt.Errorf("event type %q: tag %d is %T, expected string", eventType, i, t)
+			}
+			tags[i] = tag
+		}
+	}
+
+	// Extract data
+	eventData, ok := eventTuple[2].([]byte)
+	if !ok {
+		if ctx.Err() != nil {
+			return Event{}, time.Time{}, ctx.Err()
+		}
+		return Event{}, time.Time{}, fmt.Errorf("event type %q tags %v: data field is %T, expected []byte", eventType, tags, eventTuple[2])
+	}
+
+	var committedAt time.Time
+	if len(eventTuple) >= 4 {
+		ns, ok := eventTuple[3].(int64)
+		if !ok {
+			return Event{}, time.Time{}, fmt.Errorf("event type %q: commit time field is %T, expected int64", eventType, eventTuple[3])
+		}
+		committedAt = time.Unix(0, ns)
+	}
+
+	var metadata map[string]string
+	if len(eventTuple) == 5 {
+		metadataTuple, ok := eventTuple[4].(tuple.Tuple)
+		if !ok || len(metadataTuple)%2 != 0 {
+			return Event{}, time.Time{}, fmt.Errorf("event type %q: metadata field is %T, expected tuple of keys and values", eventType, eventTuple[4])
+		}
+		metadata = make(map[string]string, len(metadataTuple)/2)
+		for i := 0; i < len(metadataTuple); i += 2 {
+			key, keyOk := metadataTuple[i].(string)
+			value, valueOk := metadataTuple[i+1].(string)
+			if !keyOk || !valueOk {
+				return Event{}, time.Time{}, fmt.Errorf("event type %q: metadata entry %d is (%T, %T), expected strings", eventType, i/2, metadataTuple[i], metadataTuple[i+1])
+			}
+			metadata[key] = value
+		}
+		if len(metadata) == 0 {
+			metadata = nil
+		}
+	}
+
+	return Event{Type: eventType, Tags: tags, Data: eventData, Metadata: metadata}, committedAt, nil
+}
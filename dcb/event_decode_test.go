@@ -0,0 +1,127 @@
+//go:build test
+
+package dcb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestDecodeEvent_MatchesTupleUnpack(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		// Given - any event, escaped bytes, metadata and legacy 3-tuples included
+		tags := rapid.SliceOf(rapid.String()).Draw(t, "tags")
+		tagsTuple := make(tuple.Tuple, len(tags))
+		for i, tag := range tags {
+			tagsTuple[i] = tag
+		}
+		value := tuple.Tuple{
+			rapid.StringN(1, -1, -1).Draw(t, "type"),
+			tagsTuple,
+			rapid.SliceOf(rapid.Byte()).Draw(t, "data"),
+			rapid.Int64().Draw(t, "committedAt"),
+		}
+		switch rapid.IntRange(0, 2).Draw(t, "layout") {
+		case 0:
+			value = value[:3]
+		case 1:
+			metadata := tuple.Tuple{}
+			for _, s := range rapid.SliceOfN(rapid.String(), 0, 8).Draw(t, "metadata") {
+				metadata = append(metadata, s, s)
+			}
+			value = append(value, metadata)
+		}
+		encoded := value.Pack()
+
+		// When
+		event, committedAt, err := decodeEvent(context.Background(), encoded)
+
+		// Then
+		require.NoError(t, err)
+		expected, expectedCommittedAt, err := decodeEventTuple(context.Background(), encoded)
+		require.NoError(t, err)
+		assert.Equal(t, expected, event)
+		assert.True(t, expectedCommittedAt.Equal(committedAt))
+	})
+}
+
+func TestDecodeEvent_CopiesDataOutOfTheValue(t *testing.T) {
+	// Given
+	encoded := tuple.Tuple{"item_added", tuple.Tuple{"cart:1"}, []byte("{}"), int64(1)}.Pack()
+
+	// When
+	event, _, err := decodeEvent(context.Background(), encoded)
+	clear(encoded)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), event.Data)
+}
+
+func TestDecodeStoredEvent_CarriesThePositionAndCommitTime(t *testing.T) {
+	// Given
+	position := Versionstamp{1, 2, 3}
+	encoded := tuple.Tuple{"item_added", tuple.Tuple{"cart:1"}, []byte("{}"), int64(42), tuple.Tuple{"actor", "bob"}}.Pack()
+
+	// When
+	stored, err := DecodeStoredEvent(position, encoded)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, position, stored.Position)
+	assert.Equal(t, time.Unix(0, 42), stored.CommittedAt)
+	assert.Equal(t, Event{Type: "item_added", Tags: []string{"cart:1"}, Data: []byte("{}"), Metadata: map[string]string{"actor": "bob"}}, stored.Event)
+
+	_, err = DecodeStoredEvent(position, tuple.Tuple{"item_added", tuple.Tuple{int64(1)}, []byte{}}.Pack())
+	assert.Error(t, err, "non-string tag")
+}
+
+func TestDecodeEvent_ReportsMalformedValues(t *testing.T) {
+	for name, value := range map[string]tuple.Tuple{
+		"missing data":        {"item_added", tuple.Tuple{}},
+		"tag not string":      {"item_added", tuple.Tuple{int64(1)}, []byte{}},
+		"data not bytes":      {"item_added", tuple.Tuple{}, "data"},
+		"time not int":        {"item_added", tuple.Tuple{}, []byte{}, "now"},
+		"trailing fields":     {"item_added", tuple.Tuple{}, []byte{}, int64(1), tuple.Tuple{}, int64(2)},
+		"metadata not tuple":  {"item_added", tuple.Tuple{}, []byte{}, int64(1), int64(2)},
+		"metadata key only":   {"item_added", tuple.Tuple{}, []byte{}, int64(1), tuple.Tuple{"actor"}},
+		"metadata not string": {"item_added", tuple.Tuple{}, []byte{}, int64(1), tuple.Tuple{"actor", int64(1)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// When
+			_, _, err := decodeEvent(context.Background(), value.Pack())
+
+			// Then
+			assert.Error(t, err)
+		})
+	}
+}
+
+// BenchmarkDecodeEvent compares decoding an event in place with decoding it through tuple.Unpack
+func BenchmarkDecodeEvent(b *testing.B) {
+	encoded := tuple.Tuple{
+		"item_added",
+		tuple.Tuple{"list:123", "item:456", "status:pending"},
+		[]byte(`{"name":"milk","quantity":2}`),
+		time.Now().UnixNano(),
+	}.Pack()
+
+	b.Run("in place", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _, _ = decodeEvent(context.Background(), encoded)
+		}
+	})
+	b.Run("tuple.Unpack", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_, _, _ = decodeEventTuple(context.Background(), encoded)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package dcb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrEventLimitExceeded is wrapped by the *EventLimitError Append returns for an event over its limits
+var ErrEventLimitExceeded = errors.New("event limit exceeded")
+
+// EventLimits bounds the events of a type, so that a single buggy producer can't bloat the store:
+// the tag tree indexes every subset of an event's tags, so its size doubles with each tag.
+// Zero values are unlimited.
+type EventLimits struct {
+	MaxDataBytes int // size of Event.Data
+	MaxTags      int // distinct tags
+}
+
+// EventLimitError reports the event of an append over one of its limits
+type EventLimitError struct {
+	Index int    // the event's index in the batch
+	Type  string // the event's type
+	Limit string // "data_bytes" or "tags"
+	Value int    // the event's size or tag count
+	Max   int    // the limit
+}
+
+func (e *EventLimitError) Error() string {
+	return fmt.Sprintf("%s: event %d of type %q has %d %s (limit %d)", ErrEventLimitExceeded, e.Index, e.Type, e.Value, e.Limit, e.Max)
+}
+
+func (e *EventLimitError) Unwrap() error { return ErrEventLimitExceeded }
+
+// WithEventLimits bounds the events of eventType. It overrides WithDefaultEventLimits for that type.
+func (StoreOptions) WithEventLimits(eventType string, limits EventLimits) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		if e.eventLimits == nil {
+			e.eventLimits = make(map[string]EventLimits)
+		}
+		e.eventLimits[eventType] = limits
+	}
+}
+
+// WithDefaultEventLimits bounds the events of the types without their own limits (see WithEventLimits)
+func (StoreOptions) WithDefaultEventLimits(limits EventLimits) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.defaultEventLimits = limits
+	}
+}
+
+// checkEventLimits returns an *EventLimitError for the first event over its type's limits.
+// Tags are counted once canonicalized, as they are indexed.
+func (s fdbStore) checkEventLimits(events []Event) error {
+	for i, event := range events {
+		limits, ok := s.eventLimits[event.Type]
+		if !ok {
+			limits = s.defaultEventLimits
+		}
+		if limits.MaxDataBytes > 0 && len(event.Data) > limits.MaxDataBytes {
+			return &EventLimitError{Index: i, Type: event.Type, Limit: "data_bytes", Value: len(event.Data), Max: limits.MaxDataBytes}
+		}
+		if limits.MaxTags > 0 && len(event.Tags) > limits.MaxTags {
+			return &EventLimitError{Index: i, Type: event.Type, Limit: "tags", Value: len(event.Tags), Max: limits.MaxTags}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package dcb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLimits_RejectEventsOverTheLimitsOfTheirType(tt *testing.T) {
+	tt.Parallel()
+
+	for name, tc := range map[string]struct {
+		event    dcb.Event
+		expected *dcb.EventLimitError
+	}{
+		"within limits": {
+			event: dcb.Event{Type: "item_added", Tags: []string{"a", "b", "a"}, Data: []byte("1234")},
+		},
+		"payload too large": {
+			event:    dcb.Event{Type: "item_added", Data: []byte("12345")},
+			expected: &dcb.EventLimitError{Index: 1, Type: "item_added", Limit: "data_bytes", Value: 5, Max: 4},
+		},
+		"too many tags": {
+			event:    dcb.Event{Type: "item_added", Tags: []string{"a", "b", "c"}},
+			expected: &dcb.EventLimitError{Index: 1, Type: "item_added", Limit: "tags", Value: 3, Max: 2},
+		},
+		"default limits": {
+			event:    dcb.Event{Type: "item_removed", Tags: []string{"a", "b", "c", "d", "e"}},
+			expected: &dcb.EventLimitError{Index: 1, Type: "item_removed", Limit: "tags", Value: 5, Max: 4},
+		},
+	} {
+		tt.Run(name, func(tt *testing.T) {
+			tt.Parallel()
+
+			// Given
+			store := dcb.SetupTestStore(tt)
+			dcb.StoreOptions{}.WithDefaultEventLimits(dcb.EventLimits{MaxTags: 4})(store)
+			dcb.StoreOptions{}.WithEventLimits("item_added", dcb.EventLimits{MaxDataBytes: 4, MaxTags: 2})(store)
+			valid := dcb.Event{Type: "item_removed", Data: []byte(strings.Repeat("x", 100))}
+
+			// When
+			err := store.Append(context.Background(), []dcb.Event{valid, tc.event})
+
+			// Then
+			if tc.expected == nil {
+				require.NoError(tt, err)
+				return
+			}
+			var limitErr *dcb.EventLimitError
+			require.ErrorAs(tt, err, &limitErr)
+			assert.Equal(tt, tc.expected, limitErr)
+			assert.ErrorIs(tt, err, dcb.ErrEventLimitExceeded)
+			assert.Empty(tt, dcb.CollectEvents(tt, store.ReadAll(context.Background())))
+		})
+	}
+}
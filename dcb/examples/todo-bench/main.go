@@ -21,11 +21,13 @@ var (
 	concurrency = flag.Int("concurrency", 50, "number of concurrent scenarios")
 	fdbCluster  = flag.String("fdb-cluster", "", "FDB cluster file path (default: use FDB_CLUSTER_FILE env)")
 	metricsPort = flag.Int("metrics-port", 8080, "port for metrics and pprof")
-	mode        = flag.String("mode", "todo", "benchmark mode: todo or write")
-	duration    = flag.Duration("duration", 30*time.Second, "write benchmark duration (0 = run until signal)")
-	reportEvery = flag.Duration("report-interval", time.Second, "write benchmark reporting interval")
+	mode        = flag.String("mode", "todo", "benchmark mode: todo, write, read or mixed")
+	duration    = flag.Duration("duration", 30*time.Second, "write/read/mixed benchmark duration (0 = run until signal)")
+	reportEvery = flag.Duration("report-interval", time.Second, "write/read/mixed benchmark reporting interval")
 	payloadSize = flag.Int("payload-size", 128, "write benchmark payload size in bytes")
 	batchSize   = flag.Int("batch-size", 1, "write benchmark events per append")
+	maxInFlight = flag.Int("max-in-flight", 0, "max concurrent FDB transactions (0 = unlimited)")
+	maxQueued   = flag.Int("max-queued", 0, "max callers waiting for a transaction slot (0 = unbounded)")
 )
 
 func main() {
@@ -42,18 +44,28 @@ func main() {
 	}
 
 	// Create event store with observability
-	store := dcb.NewDcbStore(db, "todo-bench", dcb.StoreOptions{}.WithMetrics(prometheusMetrics{}))
+	storeOpts := dcb.StoreOptions{}
+	store := dcb.NewDcbStore(db, "todo-bench",
+		storeOpts.WithMetrics(prometheusMetrics{}),
+		storeOpts.WithMaxConcurrentTransactions(*maxInFlight, *maxQueued),
+	)
 
 	// Start metrics server
 	go startMetricsServer(*metricsPort)
 
-	if *mode != "todo" && *mode != "write" {
-		log.Fatalf("unsupported mode: %s (expected todo or write)", *mode)
-	}
-
-	if *mode == "write" {
+	switch *mode {
+	case "todo":
+	case "write":
 		runWriteBenchmark(store)
 		return
+	case "read":
+		runWorkloadBenchmark(store, "read", 1)
+		return
+	case "mixed":
+		runWorkloadBenchmark(store, "mixed", *readRatio)
+		return
+	default:
+		log.Fatalf("unsupported mode: %s (expected todo, write, read or mixed)", *mode)
 	}
 
 	// Run benchmark
@@ -109,7 +121,6 @@ var (
 	totalScenariosCompleted atomic.Uint64
 	totalAppends            atomic.Uint64
 	totalReads              atomic.Uint64
-	writeEventID            atomic.Uint64
 )
 
 // runScenarios continuously runs scenarios until context is cancelled
@@ -337,131 +348,3 @@ func startMetricsServer(port int) {
 		log.Fatalf("Failed to start metrics server: %v", err)
 	}
 }
-
-func runWriteBenchmark(store dcb.DcbStore) {
-	if *batchSize < 1 {
-		log.Fatalf("batch-size must be >= 1")
-	}
-	if *payloadSize < 0 {
-		log.Fatalf("payload-size must be >= 0")
-	}
-
-	var (
-		writesTotal atomic.Uint64
-		errorsTotal atomic.Uint64
-	)
-
-	payload := make([]byte, *payloadSize)
-
-	ctx := context.Background()
-	cancel := func() {}
-	if *duration > 0 {
-		ctx, cancel = context.WithTimeout(ctx, *duration)
-	} else {
-		ctx, cancel = context.WithCancel(ctx)
-	}
-	defer cancel()
-
-	sigChan := make(chan os.Signal, 2)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		sigCount := 0
-		for range sigChan {
-			sigCount++
-			if sigCount == 1 {
-				log.Println("Shutting down... (press Ctrl+C again to force)")
-				cancel()
-				go func() {
-					select {
-					case <-time.After(10 * time.Second):
-						log.Println("Forcing exit after shutdown timeout")
-						os.Exit(1)
-					case <-ctx.Done():
-					}
-				}()
-				continue
-			}
-			log.Println("Forcing exit")
-			os.Exit(1)
-		}
-	}()
-
-	log.Printf("Starting write benchmark: concurrency=%d batch=%d payload=%dB duration=%s",
-		*concurrency, *batchSize, *payloadSize, *duration)
-
-	var wg sync.WaitGroup
-	for i := 0; i < *concurrency; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			runWriteWorker(ctx, store, workerID, payload, &writesTotal, &errorsTotal)
-		}(i)
-	}
-
-	start := time.Now()
-	ticker := time.NewTicker(*reportEvery)
-	defer ticker.Stop()
-
-	var lastTotal uint64
-	for {
-		select {
-		case <-ctx.Done():
-			wg.Wait()
-			elapsed := time.Since(start).Seconds()
-			total := writesTotal.Load()
-			avg := float64(total) / elapsed
-			log.Printf("Write benchmark complete: total=%d events avg=%.0f events/sec errors=%d",
-				total, avg, errorsTotal.Load())
-			return
-		case <-ticker.C:
-			total := writesTotal.Load()
-			delta := total - lastTotal
-			lastTotal = total
-			rate := float64(delta) / reportEvery.Seconds()
-			elapsed := time.Since(start).Seconds()
-			avg := float64(total) / elapsed
-			log.Printf("Writes/sec: %.0f (avg %.0f) total=%d errors=%d",
-				rate, avg, total, errorsTotal.Load())
-		}
-	}
-}
-
-func runWriteWorker(
-	ctx context.Context,
-	store dcb.DcbStore,
-	workerID int,
-	payload []byte,
-	writesTotal *atomic.Uint64,
-	errorsTotal *atomic.Uint64,
-) {
-	events := make([]dcb.Event, 0, *batchSize)
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		events = events[:0]
-		for i := 0; i < *batchSize; i++ {
-			eventID := writeEventID.Add(1)
-			events = append(events, dcb.Event{
-				Type: "bench_write",
-				Tags: []string{
-					fmt.Sprintf("worker:%d", workerID),
-					fmt.Sprintf("event:%d", eventID),
-				},
-				Data: payload,
-			})
-		}
-
-		if err := appendEvents(ctx, store, events, nil); err != nil {
-			errCount := errorsTotal.Add(1)
-			if errCount <= 5 || errCount%1000 == 0 {
-				log.Printf("append error (count=%d): %v", errCount, err)
-			}
-			continue
-		}
-		writesTotal.Add(uint64(len(events)))
-	}
-}
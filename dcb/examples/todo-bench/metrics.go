@@ -65,6 +65,18 @@ var (
 		Help: "Total number of events read from the store",
 	})
 
+	// Backpressure metrics
+	inFlightTransactions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dcb_in_flight_transactions",
+		Help: "Number of FDB transactions currently in flight",
+	})
+
+	queueWaitLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "dcb_queue_wait_duration_seconds",
+		Help:    "Histogram of time spent waiting for a transaction slot",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15), // 1ms to ~16s
+	})
+
 	// Gauge for current metrics (for debugging)
 	activeScenarios = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "dcb_active_scenarios",
@@ -122,3 +134,11 @@ func (prometheusMetrics) RecordReadEvents(count int) {
 }
 
 func (prometheusMetrics) RecordError(operation string, errorType string) {}
+
+func (prometheusMetrics) RecordInFlight(count int) {
+	inFlightTransactions.Set(float64(count))
+}
+
+func (prometheusMetrics) RecordQueueWait(duration time.Duration) {
+	queueWaitLatency.Observe(duration.Seconds())
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/err0r500/fairway/dcb/bench"
+)
+
+var (
+	readRatio      = flag.Float64("read-ratio", 0.8, "mixed benchmark share of operations that are reads (0..1)")
+	conditionalPct = flag.Float64("conditional-pct", 0.5, "mixed benchmark share of appends carrying an append condition (0..1)")
+	seedLists      = flag.Int("seed-lists", 100, "read/mixed benchmark number of lists seeded before measuring")
+	seedItems      = flag.Int("seed-items", 50, "read/mixed benchmark max items per seeded list (list sizes vary from 1 to this)")
+	readLimit      = flag.Int("read-limit", 0, "read/mixed benchmark max events per read (0 = unlimited)")
+)
+
+// readShapes covers tag-only, type-only, tag+type and multi-item queries with varying result sizes
+func readShapes(list bench.TagShape) []bench.QueryShape {
+	return []bench.QueryShape{
+		{Name: "list_tag", Query: func() dcb.Query {
+			// whole list: 1 to seed-items events
+			return dcb.Query{Items: []dcb.QueryItem{{Tags: []string{list.Value()}}}}
+		}},
+		{Name: "list_status", Query: func() dcb.Query {
+			// two tags intersection: a subset of the list
+			return dcb.Query{Items: []dcb.QueryItem{{Tags: []string{list.Value(), "status:pending"}}}}
+		}},
+		{Name: "list_typed", Query: func() dcb.Query {
+			// tag + type: only the list header
+			return dcb.Query{Items: []dcb.QueryItem{{Types: []string{"list_created"}, Tags: []string{list.Value()}}}}
+		}},
+		{Name: "type_only", Query: func() dcb.Query {
+			// type index scan: grows with the store, bounded by read-limit
+			return dcb.Query{Items: []dcb.QueryItem{{Types: []string{"list_created"}}}}
+		}},
+		{Name: "multi_item", Query: func() dcb.Query {
+			// OR of two lists, as a decision model spanning aggregates would do
+			return dcb.Query{Items: []dcb.QueryItem{
+				{Types: []string{"item_inserted", "item_deleted"}, Tags: []string{list.Value()}},
+				{Types: []string{"item_inserted", "item_deleted"}, Tags: []string{list.Value()}},
+			}}
+		}},
+	}
+}
+
+func listTag(list int) string { return fmt.Sprintf("list:%d", list) }
+
+// benchContext returns a context cancelled on SIGINT/SIGTERM, bench.Run stops after -duration
+func benchContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for range sigChan {
+			log.Println("Shutting down...")
+			cancel()
+		}
+	}()
+	return ctx, cancel
+}
+
+// seedReadData appends seed-lists lists of varying sizes so reads have something to find
+func seedReadData(ctx context.Context, store dcb.DcbStore) error {
+	log.Printf("Seeding %d lists with up to %d items each", *seedLists, *seedItems)
+	for list := range *seedLists {
+		events := []dcb.Event{{Type: "list_created", Tags: []string{listTag(list)}}}
+		for item := range 1 + rand.IntN(*seedItems) {
+			events = append(events, dcb.Event{
+				Type: "item_inserted",
+				Tags: []string{listTag(list), fmt.Sprintf("item:seed-%d", item), "status:pending"},
+				Data: fmt.Appendf(nil, `{"timestamp":%d}`, time.Now().Unix()),
+			})
+		}
+		if err := store.Append(ctx, events); err != nil {
+			return fmt.Errorf("seeding list %d: %w", list, err)
+		}
+	}
+	return nil
+}
+
+// itemInserted inserts an item in a random list, conditioned on the list not being deleted when conditional
+func itemInserted(list bench.TagShape, name string, weight float64, conditional bool) bench.EventShape {
+	shape := bench.EventShape{
+		Name:         name,
+		Type:         "item_inserted",
+		Weight:       weight,
+		PayloadBytes: 32,
+		Tags:         []bench.TagShape{list, {Key: "item"}, {Key: "status", Cardinality: 1}},
+	}
+	if conditional {
+		shape.Condition = func(events []dcb.Event) *dcb.AppendCondition {
+			return &dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{
+				{Types: []string{"list_deleted"}, Tags: events[0].Tags[:1]},
+			}}}
+		}
+	}
+	return shape
+}
+
+// runWorkloadBenchmark runs the read (readShare = 1) or mixed workload and reports latency percentiles per operation
+func runWorkloadBenchmark(store dcb.DcbStore, name string, readShare float64) {
+	if *seedLists < 1 || *seedItems < 1 {
+		log.Fatalf("seed-lists and seed-items must be >= 1")
+	}
+	if *conditionalPct < 0 || *conditionalPct > 1 {
+		log.Fatalf("conditional-pct must be within 0..1")
+	}
+
+	ctx, cancel := benchContext()
+	defer cancel()
+
+	list := bench.TagShape{Key: "list", Cardinality: *seedLists}
+	log.Printf("Starting %s benchmark: concurrency=%d read-ratio=%.2f conditional-pct=%.2f duration=%s",
+		name, *concurrency, readShare, *conditionalPct, *duration)
+
+	report, err := bench.Run(ctx, store, bench.Config{
+		Concurrency:    *concurrency,
+		Duration:       *duration,
+		ReportInterval: *reportEvery,
+		Progress: func(p bench.Progress) {
+			log.Printf("Ops/sec: %.0f total=%d", p.Rate, p.Operations)
+		},
+		Events: []bench.EventShape{
+			itemInserted(list, "item_inserted", 1-*conditionalPct, false),
+			itemInserted(list, "item_inserted_conditional", *conditionalPct, true),
+		},
+		Queries:   readShapes(list),
+		ReadRatio: readShare,
+		ReadLimit: *readLimit,
+		Seed:      seedReadData,
+	})
+	if err != nil {
+		log.Fatalf("%s benchmark: %v", name, err)
+	}
+	logReport(name, report)
+}
+
+// runWriteBenchmark runs unconditional appends of batch-size events of payload-size bytes
+func runWriteBenchmark(store dcb.DcbStore) {
+	ctx, cancel := benchContext()
+	defer cancel()
+
+	log.Printf("Starting write benchmark: concurrency=%d batch=%d payload=%dB duration=%s",
+		*concurrency, *batchSize, *payloadSize, *duration)
+
+	var (
+		lastEvents  uint64
+		errorsTotal atomic.Uint64
+	)
+	report, err := bench.Run(ctx, store, bench.Config{
+		Concurrency:    *concurrency,
+		Duration:       *duration,
+		ReportInterval: *reportEvery,
+		Progress: func(p bench.Progress) {
+			log.Printf("Writes/sec: %.0f (avg %.0f) total=%d errors=%d",
+				float64(p.Events-lastEvents)/reportEvery.Seconds(), float64(p.Events)/p.Elapsed.Seconds(), p.Events, p.Errors)
+			lastEvents = p.Events
+		},
+		OnError: func(_ string, err error) {
+			if errCount := errorsTotal.Add(1); errCount <= 5 || errCount%1000 == 0 {
+				log.Printf("append error (count=%d): %v", errCount, err)
+			}
+		},
+		Events: []bench.EventShape{{
+			Type:         "bench_write",
+			PayloadBytes: *payloadSize,
+			BatchSize:    *batchSize,
+			Tags:         []bench.TagShape{{Key: "worker", Cardinality: *concurrency}, {Key: "event"}},
+		}},
+	})
+	if err != nil {
+		log.Fatalf("write benchmark: %v", err)
+	}
+	log.Printf("Write benchmark complete: total=%d events avg=%.0f events/sec",
+		report.Events, float64(report.Events)/report.Elapsed.Seconds())
+	logReport("write", report)
+}
+
+// logReport logs the operation count and latency percentiles of each operation
+func logReport(name string, report bench.Report) {
+	log.Printf("%s benchmark complete: total=%d ops avg=%.0f ops/sec",
+		name, report.Total(), float64(report.Total())/report.Elapsed.Seconds())
+	operations := make([]string, 0, len(report.Operations))
+	for op := range report.Operations {
+		operations = append(operations, op)
+	}
+	slices.Sort(operations)
+	for _, op := range operations {
+		log.Printf("  %-32s %s", op, report.Operations[op])
+	}
+}
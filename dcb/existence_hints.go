@@ -0,0 +1,149 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+// ErrExistenceHintsDisabled is returned by BackfillExistenceHints for a store without existence hints
+var ErrExistenceHintsDisabled = errors.New("existence hints not enabled")
+
+// backfillBatchSize is the number of events marked per backfill transaction
+const backfillBatchSize = 1000
+
+// existenceHints are per-tag markers, set the first time an event with the tag is appended.
+// A missing marker proves no event carries the tag: conditions on it are checked with a single point read.
+// The markers are only trusted once complete, i.e. once BackfillExistenceHints marked the events appended before.
+type existenceHints struct {
+	markers subspace.Subspace // (tag) -> nil
+	ready   fdb.Key           // set once every event stored has its tags marked
+	trusted *atomic.Bool      // ready was seen set (it is never cleared)
+}
+
+// WithExistenceHints maintains a marker per tag, so append conditions on tags no event carries
+// (e.g. "is this email taken?") are checked with a single point read instead of scanning the tag tree.
+// Conditions whose tags are all marked fall back to the range scans.
+//
+// Markers are only used once BackfillExistenceHints has run for the namespace,
+// and every process appending to it must enable the option: appends without it don't write markers.
+func (StoreOptions) WithExistenceHints() func(s *fdbStore) {
+	return func(e *fdbStore) {
+		root := subspace.Sub(e.namespace).Sub("x")
+		e.hints = &existenceHints{
+			markers: root.Sub("m"),
+			ready:   root.Pack(tuple.Tuple{"ready"}),
+			trusted: &atomic.Bool{},
+		}
+	}
+}
+
+// BackfillExistenceHints marks the tags of the events already stored, then lets the store use the markers.
+// It is idempotent and safe to run while events are appended by stores with existence hints enabled.
+func BackfillExistenceHints(ctx context.Context, store DcbStore) error {
+	s, ok := store.(*fdbStore)
+	if !ok || s.hints == nil {
+		return ErrExistenceHintsDisabled
+	}
+
+	var after fdb.Key
+	marked := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		res, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+			begin, end := s.events.FDBRangeKeys()
+			if after != nil {
+				begin = append(after[:len(after):len(after)], 0x00)
+			}
+			kvs, err := tr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{Limit: backfillBatchSize}).GetSliceWithError()
+			if err != nil {
+				return nil, err
+			}
+			for _, kv := range kvs {
+				event, _, err := decodeEvent(ctx, kv.Value)
+				if err != nil {
+					return nil, fmt.Errorf("event at key %x: %w", kv.Key, err)
+				}
+				s.hints.mark(tr, event.Tags)
+			}
+			if len(kvs) < backfillBatchSize {
+				tr.Set(s.hints.ready, nil)
+			}
+			return kvs, nil
+		})
+		if err != nil {
+			return fmt.Errorf("backfilling existence hints after %d events: %w", marked, err)
+		}
+		kvs := res.([]fdb.KeyValue)
+		marked += len(kvs)
+		if len(kvs) < backfillBatchSize {
+			s.logger.Info("existence hints backfilled", "event_count", marked)
+			return nil
+		}
+		after = kvs[len(kvs)-1].Key
+	}
+}
+
+// mark sets the markers of tags not marked yet.
+// Marked tags are left untouched, so appends don't conflict with conditions reading their markers.
+func (h *existenceHints) mark(tr fdb.Transaction, tags []string) {
+	futures := make([]fdb.FutureByteSlice, len(tags))
+	for i, tag := range tags {
+		futures[i] = tr.Snapshot().Get(h.markers.Pack(tuple.Tuple{tag}))
+	}
+	for i, tag := range tags {
+		if futures[i].MustGet() == nil {
+			tr.Set(h.markers.Pack(tuple.Tuple{tag}), nil)
+		}
+	}
+}
+
+// rulesOut reports whether the markers prove no event matches item: one of its tags was never appended.
+// The missing marker is added to the read conflicts, so an event appended with the tag concurrently
+// fails the transaction. Marked tags add no conflict: the range scans that follow check them.
+func (h *existenceHints) rulesOut(tr fdb.Transaction, item QueryItem) (bool, error) {
+	if len(item.Tags) == 0 {
+		return false, nil
+	}
+	if !h.trusted.Load() {
+		ready, err := tr.Snapshot().Get(h.ready).Get()
+		if err != nil {
+			return false, err
+		}
+		if ready == nil {
+			return false, nil
+		}
+		h.trusted.Store(true)
+	}
+
+	futures := make([]fdb.FutureByteSlice, len(item.Tags))
+	for i, tag := range item.Tags {
+		futures[i] = tr.Snapshot().Get(h.markers.Pack(tuple.Tuple{tag}))
+	}
+	for i, tag := range item.Tags {
+		marker, err := futures[i].Get()
+		if err != nil {
+			return false, err
+		}
+		if marker == nil {
+			return true, tr.AddReadConflictKey(h.markers.Pack(tuple.Tuple{tag}))
+		}
+	}
+	return false, nil
+}
+
+// markersSize estimates the bytes the markers of tags add to a transaction
+func (h *existenceHints) markersSize(tags []string) int {
+	size := 0
+	for _, tag := range tags {
+		size += len(h.markers.Bytes()) + len(tag) + tupleElementOverhead
+	}
+	return size
+}
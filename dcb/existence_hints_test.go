@@ -0,0 +1,142 @@
+package dcb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func emailTaken(email string) dcb.AppendCondition {
+	return dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"UserRegistered"}, Tags: []string{dcb.TagEquals("email", email)}},
+	}}}
+}
+
+func registered(email string) dcb.Event {
+	return dcb.Event{Type: "UserRegistered", Tags: []string{dcb.TagEquals("email", email)}}
+}
+
+func TestExistenceHints_ConditionsOnUnmarkedTags(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithExistenceHints()(store)
+	require.NoError(tt, dcb.BackfillExistenceHints(ctx, store))
+
+	// When - the email was never used
+	err := store.Append(ctx, []dcb.Event{registered("a@x.io")}, emailTaken("a@x.io"))
+
+	// Then
+	require.NoError(tt, err)
+
+	// When - the email is now taken
+	err = store.Append(ctx, []dcb.Event{registered("a@x.io")}, emailTaken("a@x.io"))
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrAppendConditionFailed)
+}
+
+func TestExistenceHints_MarkedTagsFallBackToRangeScans(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - the tag is marked by an event of another type
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithExistenceHints()(store)
+	require.NoError(tt, dcb.BackfillExistenceHints(ctx, store))
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "InvitationSent", Tags: []string{dcb.TagEquals("email", "a@x.io")}},
+	}))
+
+	// When
+	err := store.Append(ctx, []dcb.Event{registered("a@x.io")}, emailTaken("a@x.io"))
+
+	// Then - the range scan found no UserRegistered
+	assert.NoError(tt, err)
+}
+
+func TestExistenceHints_BackfillMarksStoredEvents(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events appended before the hints were enabled
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{registered("a@x.io")}))
+	dcb.StoreOptions{}.WithExistenceHints()(store)
+
+	// When
+	err := dcb.BackfillExistenceHints(ctx, store)
+
+	// Then
+	require.NoError(tt, err)
+	assert.ErrorIs(tt, store.Append(ctx, []dcb.Event{registered("a@x.io")}, emailTaken("a@x.io")), dcb.ErrAppendConditionFailed)
+	assert.NoError(tt, store.Append(ctx, []dcb.Event{registered("b@x.io")}, emailTaken("b@x.io")))
+}
+
+func TestExistenceHints_UnusedUntilBackfilled(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events without markers, hints enabled but not backfilled
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{registered("a@x.io")}))
+	dcb.StoreOptions{}.WithExistenceHints()(store)
+
+	// When
+	err := store.Append(ctx, []dcb.Event{registered("a@x.io")}, emailTaken("a@x.io"))
+
+	// Then - the missing marker was not trusted
+	assert.ErrorIs(tt, err, dcb.ErrAppendConditionFailed)
+}
+
+func TestExistenceHints_BackfillRequiresHints(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	err := dcb.BackfillExistenceHints(context.Background(), store)
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrExistenceHintsDisabled)
+}
+
+func TestExistenceHints_SameOutcomeAsRangeScans(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given - the same events in a store with hints and one without
+		ctx := context.Background()
+		plain := dcb.SetupTestStore(tt)
+		hinted := dcb.SetupTestStore(tt)
+		dcb.StoreOptions{}.WithExistenceHints()(hinted)
+		require.NoError(t, dcb.BackfillExistenceHints(ctx, hinted))
+		events := dcb.RandomEvents(t)
+		require.NoError(t, plain.Append(ctx, events))
+		require.NoError(t, hinted.Append(ctx, events))
+		item := dcb.QueryItem{Tags: []string{dcb.RandomEventTag(t)}}
+		if rapid.Bool().Draw(t, "withType") {
+			item.Types = []string{dcb.RandomEventType(t)}
+		}
+		cond := dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{item}}}
+		event := dcb.RandomEvent(t)
+
+		// When
+		plainErr := plain.Append(ctx, []dcb.Event{event}, cond)
+		hintedErr := hinted.Append(ctx, []dcb.Event{event}, cond)
+
+		// Then
+		assert.Equal(t, errors.Is(plainErr, dcb.ErrAppendConditionFailed), errors.Is(hintedErr, dcb.ErrAppendConditionFailed))
+		if !errors.Is(plainErr, dcb.ErrAppendConditionFailed) {
+			assert.NoError(t, plainErr)
+			assert.NoError(t, hintedErr)
+		}
+	})
+}
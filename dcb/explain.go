@@ -0,0 +1,178 @@
+package dcb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// ExplainKeyLimit caps the keys counted per range by ExplainQuery
+const ExplainKeyLimit = 10_000
+
+// QueryExplainer is implemented by stores able to describe how they would read a query
+type QueryExplainer interface {
+	// ExplainQuery returns the ranges a Read of query would scan, without reading events
+	ExplainQuery(ctx context.Context, query Query) (QueryPlan, error)
+}
+
+// QueryPlan describes the ranges scanned to read a query, item by item
+type QueryPlan struct {
+	Items []QueryItemPlan
+}
+
+// QueryItemPlan describes the ranges scanned for a query item
+type QueryItemPlan struct {
+	Item  QueryItem
+	Index string // "type" or "tag"
+	// DiscoveredTypes are the types found under the tags of a tags-only item:
+	// discovering them scans every key of the tag subtree before reading
+	DiscoveredTypes []string
+	Ranges          []RangePlan
+}
+
+// RangePlan is a range of index keys scanned (and merged with the others) by a read
+type RangePlan struct {
+	Path  []string // index path, e.g. ["t", "OrderPlaced"] or ["g", "cart:1", "_e", "OrderPlaced"]
+	Begin []byte
+	End   []byte
+	Keys  int  // index keys in the range, counted up to ExplainKeyLimit
+	More  bool // the range holds more than ExplainKeyLimit keys
+}
+
+// Keys returns the number of index keys scanned by the plan (a lower bound if a range holds more than ExplainKeyLimit)
+func (p QueryPlan) Keys() int {
+	total := 0
+	for _, item := range p.Items {
+		for _, r := range item.Ranges {
+			total += r.Keys
+		}
+	}
+	return total
+}
+
+// String renders the plan, one line per range
+func (p QueryPlan) String() string {
+	var b strings.Builder
+	for i, item := range p.Items {
+		fmt.Fprintf(&b, "item %d: types %v tags %v", i, item.Item.Types, item.Item.Tags)
+		if item.Item.TagKeys != nil {
+			fmt.Fprintf(&b, " tag keys %v", item.Item.TagKeys)
+		}
+		fmt.Fprintf(&b, " (%s index)\n", item.Index)
+		if item.DiscoveredTypes != nil {
+			fmt.Fprintf(&b, "  discovered types %v\n", item.DiscoveredTypes)
+		}
+		for _, r := range item.Ranges {
+			more := ""
+			if r.More {
+				more = "+"
+			}
+			fmt.Fprintf(&b, "  %s: %d%s keys\n", strings.Join(r.Path, "/"), r.Keys, more)
+		}
+	}
+	return b.String()
+}
+
+// ExplainQuery returns the ranges a Read of query would scan, with the number of keys in each
+func (s fdbStore) ExplainQuery(ctx context.Context, query Query) (QueryPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return QueryPlan{}, err
+	}
+	if err := query.Validate(); err != nil {
+		return QueryPlan{}, err
+	}
+
+	plan, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		var plan QueryPlan
+		for i, item := range query.Items {
+			itemPlan, err := s.explainItem(tr, item)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			plan.Items = append(plan.Items, itemPlan)
+		}
+		return plan, nil
+	})
+	if err != nil {
+		return QueryPlan{}, err
+	}
+	return plan.(QueryPlan), nil
+}
+
+func (s fdbStore) explainItem(tr fdb.ReadTransaction, item QueryItem) (QueryItemPlan, error) {
+	if len(item.TagKeys) > 0 {
+		return s.explainTagKeysItem(tr, item)
+	}
+
+	ranges, err := s.buildQueryRanges(tr, item, nil)
+	if err != nil {
+		return QueryItemPlan{}, err
+	}
+
+	// buildQueryRanges returns one range per type, in this order
+	plan := QueryItemPlan{Item: item, Index: "type"}
+	types := item.Types
+	var prefix []string
+	if !item.hasTypesOnly() {
+		plan.Index = "tag"
+		prefix = append([]string{"g"}, sortTags(item.Tags)...)
+		prefix = append(prefix, eventsInTagSubspace)
+		if len(item.Types) == 0 {
+			sub := s.byTag
+			for _, tag := range sortTags(item.Tags) {
+				sub = sub.Sub(tag)
+			}
+			if types, err = s.discoverTypesInTagSubspace(tr, sub.Sub(eventsInTagSubspace)); err != nil {
+				return QueryItemPlan{}, err
+			}
+			plan.DiscoveredTypes = append([]string{}, types...)
+		}
+	} else {
+		prefix = []string{"t"}
+	}
+
+	for i, r := range ranges {
+		begin, end := r.FDBRangeKeySelectors()
+		kvs, err := tr.GetRange(r, fdb.RangeOptions{Limit: ExplainKeyLimit + 1, Mode: fdb.StreamingModeWantAll}).GetSliceWithError()
+		if err != nil {
+			return QueryItemPlan{}, err
+		}
+		rangePlan := RangePlan{
+			Path:  append(append([]string{}, prefix...), types[i]),
+			Begin: begin.FDBKeySelector().Key.FDBKey(),
+			End:   end.FDBKeySelector().Key.FDBKey(),
+			Keys:  min(len(kvs), ExplainKeyLimit),
+			More:  len(kvs) > ExplainKeyLimit,
+		}
+		if item.After != nil {
+			rangePlan.Path = append(rangePlan.Path, "after "+item.After.String())
+		}
+		plan.Ranges = append(plan.Ranges, rangePlan)
+	}
+	return plan, nil
+}
+
+// explainTagKeysItem explains the items a tag keys item expands to, in a single plan
+func (s fdbStore) explainTagKeysItem(tr fdb.ReadTransaction, item QueryItem) (QueryItemPlan, error) {
+	items, err := expandTagKeys(item, func(key string) ([]string, error) { return s.discoverTagsOfKey(tr, key) })
+	if err != nil {
+		return QueryItemPlan{}, err
+	}
+	plan := QueryItemPlan{Item: item, Index: "tag"}
+	for _, concrete := range items {
+		concretePlan, err := s.explainItem(tr, concrete)
+		if err != nil {
+			return QueryItemPlan{}, err
+		}
+		for _, typ := range concretePlan.DiscoveredTypes {
+			if !slices.Contains(plan.DiscoveredTypes, typ) {
+				plan.DiscoveredTypes = append(plan.DiscoveredTypes, typ)
+			}
+		}
+		plan.Ranges = append(plan.Ranges, concretePlan.Ranges...)
+	}
+	return plan, nil
+}
@@ -0,0 +1,62 @@
+package dcb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainQuery_DescribesScannedRanges(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "item_added", Tags: []string{"list:1"}},
+		{Type: "item_added", Tags: []string{"list:1"}},
+		{Type: "list_renamed", Tags: []string{"list:1"}},
+		{Type: "item_added", Tags: []string{"list:2"}},
+	}))
+	query := dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"item_added"}},
+		{Tags: []string{"list:1"}},
+	}}
+
+	// When
+	plan, err := store.ExplainQuery(ctx, query)
+
+	// Then - the type index range, then one tag subtree range per discovered type
+	require.NoError(tt, err)
+	require.Len(tt, plan.Items, 2)
+
+	assert.Equal(tt, "type", plan.Items[0].Index)
+	require.Len(tt, plan.Items[0].Ranges, 1)
+	assert.Equal(tt, []string{"t", "item_added"}, plan.Items[0].Ranges[0].Path)
+	assert.Equal(tt, 3, plan.Items[0].Ranges[0].Keys)
+
+	assert.Equal(tt, "tag", plan.Items[1].Index)
+	assert.ElementsMatch(tt, []string{"item_added", "list_renamed"}, plan.Items[1].DiscoveredTypes)
+	require.Len(tt, plan.Items[1].Ranges, 2)
+	for _, r := range plan.Items[1].Ranges {
+		assert.Equal(tt, []string{"g", "list:1", "_e"}, r.Path[:3])
+	}
+	assert.Equal(tt, 6, plan.Keys())
+	assert.Contains(tt, plan.String(), "g/list:1/_e/list_renamed: 1 keys")
+}
+
+func TestExplainQuery_RejectsInvalidQueries(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	_, err := store.ExplainQuery(context.Background(), dcb.Query{Items: []dcb.QueryItem{{}}})
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrInvalidQuery)
+}
@@ -0,0 +1,340 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+var (
+	// ErrIncompatibleFormat is returned by every operation of a store whose namespace was written
+	// in a storage format this version cannot read (upgraded by a newer version)
+	ErrIncompatibleFormat = errors.New("incompatible storage format")
+	// ErrUpgradeRequired is returned by every operation of a store whose namespace must be upgraded first, see Upgrader
+	ErrUpgradeRequired = errors.New("storage format upgrade required")
+)
+
+const (
+	// FormatVersion is the storage format written by this version.
+	//  1. namespaces written before the format was versioned
+	//  2. event tags stored in canonical form (see CanonicalTags)
+	//  3. event metadata stored after the commit time (see Event.Metadata)
+	FormatVersion = 3
+	// minFormatVersion is the oldest storage format this version reads and appends to
+	minFormatVersion = 1
+	// legacyFormatVersion is the format of namespaces holding events but no format version
+	legacyFormatVersion = 1
+	// canonicalTagsFormatVersion is the first format storing canonical tags, that Upgrade never rewrites
+	canonicalTagsFormatVersion = 2
+	// metadataFormatVersion is the first format storing event metadata, that older versions cannot decode
+	metadataFormatVersion = 3
+	// upgradeBatchSize is the number of events rewritten per transaction by an upgrade
+	upgradeBatchSize = 500
+)
+
+// Upgrader is implemented by stores able to upgrade the storage format of their namespace
+type Upgrader interface {
+	// Upgrade runs the format upgrades from the namespace's format up to FormatVersion, in order.
+	// Each upgrade is resumable: an interrupted Upgrade is completed by the next one.
+	// Deploy the new version everywhere before upgrading: processes of older versions then fail with ErrIncompatibleFormat.
+	Upgrade(ctx context.Context) (UpgradeReport, error)
+}
+
+// UpgradeReport lists the format upgrades Upgrade applied
+type UpgradeReport struct {
+	From, To int      // storage format before and after
+	Applied  []string // upgrades applied, in order
+}
+
+// formatUpgrade migrates a namespace from format from to from+1
+type formatUpgrade struct {
+	from        int
+	description string
+	apply       func(ctx context.Context, s fdbStore) error
+}
+
+// formatUpgrades are the registered format upgrades, one per format change
+var formatUpgrades = []formatUpgrade{
+	{from: 1, description: "store event tags in canonical form", apply: canonicalizeStoredTags},
+	{from: 2, description: "allow event metadata", apply: func(context.Context, fdbStore) error { return nil }},
+}
+
+// formatCheck remembers that the namespace format was verified, shared by copies of the store
+type formatCheck struct {
+	key      fdb.Key // (format version), tuple-packed
+	mu       sync.Mutex
+	ok       atomic.Bool
+	version  atomic.Int64 // the verified format
+	recorded atomic.Bool  // the format key is known to be written
+}
+
+func newFormatCheck(namespace string) *formatCheck {
+	return &formatCheck{key: subspace.Sub(namespace).Sub("f").Pack(tuple.Tuple{"version"})}
+}
+
+// checkFormat verifies the namespace format once, without writing: a namespace without format key
+// has the current format when empty (Append records it), the legacy one otherwise.
+// Failures are not remembered: the next operation checks again.
+func (s fdbStore) checkFormat() error {
+	if s.format.ok.Load() {
+		return nil
+	}
+	s.format.mu.Lock()
+	defer s.format.mu.Unlock()
+	if s.format.ok.Load() {
+		return nil
+	}
+
+	version, err := s.formatVersion()
+	if err != nil {
+		return fmt.Errorf("reading storage format: %w", err)
+	}
+	if err := checkFormatVersion(s.namespace, version); err != nil {
+		s.metrics.RecordError("format", "incompatible")
+		return err
+	}
+	if version < FormatVersion {
+		s.logger.Warn("storage format upgrade pending", "namespace", s.namespace, "version", version, "latest", FormatVersion)
+	}
+	s.format.version.Store(int64(version))
+	s.format.ok.Store(true)
+	return nil
+}
+
+// checkFormatVersion fails for formats this version cannot read and append to
+func checkFormatVersion(namespace string, version int) error {
+	if version > FormatVersion {
+		return fmt.Errorf("%w: namespace %q has format %d, this version supports up to %d", ErrIncompatibleFormat, namespace, version, FormatVersion)
+	}
+	if version < minFormatVersion {
+		return fmt.Errorf("%w: namespace %q has format %d, this version reads from %d", ErrUpgradeRequired, namespace, version, minFormatVersion)
+	}
+	return nil
+}
+
+// formatVersion returns the format of the namespace, in a read-only transaction
+func (s fdbStore) formatVersion() (int, error) {
+	res, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		version, recorded, err := s.readFormatVersion(tr)
+		if recorded {
+			s.format.recorded.Store(true)
+		}
+		return version, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.(int), nil
+}
+
+// readFormatVersion returns the format of the namespace and whether it is recorded. Missing, it is
+// the current format for empty namespaces, the legacy one for namespaces written before versioning.
+func (s fdbStore) readFormatVersion(tr fdb.ReadTransaction) (int, bool, error) {
+	value, err := tr.Get(s.format.key).Get()
+	if err != nil {
+		return 0, false, err
+	}
+	if value != nil {
+		version, err := decodeFormatVersion(value)
+		return version, err == nil, err
+	}
+
+	kvs, err := tr.GetRange(s.events, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(kvs) > 0 {
+		return legacyFormatVersion, false, nil
+	}
+	return FormatVersion, false, nil
+}
+
+// recordFormatInTx records the format of the namespace within tr, the transaction writing its first events,
+// when it is missing. Once the key is known to be written, it reads nothing.
+func (s fdbStore) recordFormatInTx(tr fdb.Transaction) error {
+	if s.format.recorded.Load() {
+		return nil
+	}
+	version, recorded, err := s.readFormatVersion(tr)
+	if err != nil {
+		return fmt.Errorf("reading storage format: %w", err)
+	}
+	if recorded {
+		s.format.recorded.Store(true)
+		return nil
+	}
+	tr.Set(s.format.key, tuple.Tuple{int64(version)}.Pack())
+	return nil
+}
+
+func decodeFormatVersion(value []byte) (int, error) {
+	t, err := tuple.Unpack(value)
+	if err != nil {
+		return 0, fmt.Errorf("format version: %w", err)
+	}
+	if len(t) != 1 {
+		return 0, fmt.Errorf("format version: expected 1-tuple, got %d elements", len(t))
+	}
+	version, ok := t[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("format version is %T, expected int64", t[0])
+	}
+	return int(version), nil
+}
+
+// Upgrade runs the format upgrades from the namespace's format up to FormatVersion.
+// Run a single Upgrade at a time per namespace.
+func (s fdbStore) Upgrade(ctx context.Context) (UpgradeReport, error) {
+	res, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+		version, recorded, err := s.readFormatVersion(tr)
+		if err != nil {
+			return nil, err
+		}
+		if !recorded {
+			tr.Set(s.format.key, tuple.Tuple{int64(version)}.Pack())
+		}
+		return version, nil
+	})
+	if err != nil {
+		return UpgradeReport{}, fmt.Errorf("reading storage format: %w", err)
+	}
+	version := res.(int)
+	if version > FormatVersion {
+		return UpgradeReport{From: version, To: version}, checkFormatVersion(s.namespace, version)
+	}
+
+	report := UpgradeReport{From: version, To: version}
+	for _, upgrade := range formatUpgrades {
+		if upgrade.from != report.To {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		s.logger.Info("storage format upgrade started", "namespace", s.namespace, "from", upgrade.from, "upgrade", upgrade.description)
+		if err := upgrade.apply(ctx, s); err != nil {
+			return report, fmt.Errorf("upgrading format %d (%s): %w", upgrade.from, upgrade.description, err)
+		}
+		_, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.Set(s.format.key, tuple.Tuple{int64(upgrade.from + 1)}.Pack())
+			return nil, nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("recording format %d: %w", upgrade.from+1, err)
+		}
+		report.To = upgrade.from + 1
+		report.Applied = append(report.Applied, upgrade.description)
+	}
+
+	if report.To != FormatVersion {
+		return report, fmt.Errorf("%w: no upgrade from format %d", ErrUpgradeRequired, report.To)
+	}
+	s.format.version.Store(FormatVersion)
+	s.format.ok.Store(true)
+	if s.cache != nil && len(report.Applied) > 0 {
+		s.cache.clear() // events cached in the previous format
+	}
+	s.logger.Info("storage format upgrade completed", "namespace", s.namespace, "from", report.From, "to", report.To)
+	return report, nil
+}
+
+// canonicalizeStoredTags rewrites the events stored with unsorted or duplicate tags (format 1 to 2).
+// Tag tree paths of duplicate tags are cleared, the other index entries already use sorted tags.
+func canonicalizeStoredTags(ctx context.Context, s fdbStore) error {
+	var after *Versionstamp
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		res, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+			var r fdb.Range = s.events
+			if after != nil {
+				var err error
+				if r, err = rangeAfterVersionstamp(s.events, *after); err != nil {
+					return nil, err
+				}
+			}
+			kvs, err := tr.GetRange(r, fdb.RangeOptions{Limit: upgradeBatchSize}).GetSliceWithError()
+			if err != nil {
+				return nil, err
+			}
+			if len(kvs) == 0 {
+				return (*Versionstamp)(nil), nil
+			}
+
+			for _, kv := range kvs {
+				if err := s.canonicalizeEventTags(tr, kv); err != nil {
+					return nil, err
+				}
+			}
+			last := extractVersionstamp(kvs[len(kvs)-1].Key)
+			return &last, nil
+		})
+		if err != nil {
+			return err
+		}
+		if after = res.(*Versionstamp); after == nil {
+			return nil
+		}
+	}
+}
+
+// canonicalizeEventTags rewrites the stored event if its tags are not in canonical form.
+// The rest of the encoded tuple is kept as is.
+func (s fdbStore) canonicalizeEventTags(tr fdb.Transaction, kv fdb.KeyValue) error {
+	vs := extractVersionstamp(kv.Key)
+	t, err := tuple.Unpack(kv.Value)
+	if err != nil || len(t) < 2 {
+		return fmt.Errorf("event at versionstamp %x: malformed", vs[:])
+	}
+	eventType, typeOk := t[0].(string)
+	tagsTuple, tagsOk := t[1].(tuple.Tuple)
+	if !typeOk || (!tagsOk && t[1] != nil) {
+		return fmt.Errorf("event at versionstamp %x: malformed", vs[:])
+	}
+	tags := make([]string, 0, len(tagsTuple))
+	for _, element := range tagsTuple {
+		tag, ok := element.(string)
+		if !ok {
+			return fmt.Errorf("event at versionstamp %x: malformed", vs[:])
+		}
+		tags = append(tags, tag)
+	}
+	if isCanonical(tags) {
+		return nil
+	}
+
+	canonical := CanonicalTags(tags)
+	canonicalTuple := make(tuple.Tuple, len(canonical))
+	for i, tag := range canonical {
+		canonicalTuple[i] = tag
+	}
+	t[1] = canonicalTuple
+	tr.Set(kv.Key, t.Pack())
+
+	// Paths holding a tag twice only exist for duplicate tags
+	kept := make(map[string]bool)
+	for _, subset := range generateAllSubsets(canonical) {
+		kept[strings.Join(subset, "\x00")] = true
+	}
+	for _, subset := range generateAllSubsets(tags) {
+		if kept[strings.Join(subset, "\x00")] {
+			continue
+		}
+		tagPath := make(tuple.Tuple, 0, len(subset)+3)
+		for _, tag := range subset {
+			tagPath = append(tagPath, tag)
+		}
+		tr.Clear(s.byTag.Pack(append(tagPath, eventsInTagSubspace, eventType, vs.tupleVersionstamp())))
+	}
+	return nil
+}
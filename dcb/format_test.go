@@ -0,0 +1,173 @@
+package dcb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formatKey(store dcb.DcbStore) fdb.Key {
+	return subspace.Sub(store.Namespace()).Sub("f").Pack(tuple.Tuple{"version"})
+}
+
+func TestFormat_NewNamespacesGetTheCurrentFormat(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "item_added", Tags: []string{"a"}}}))
+
+	// Then
+	value, err := store.Database().ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(formatKey(store)).Get()
+	})
+	require.NoError(tt, err)
+	assert.Equal(tt, tuple.Tuple{int64(dcb.FormatVersion)}.Pack(), value)
+	report, err := store.Upgrade(ctx)
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.UpgradeReport{From: dcb.FormatVersion, To: dcb.FormatVersion}, report)
+}
+
+func TestFormat_ReadsDoNotRecordTheFormat(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	reopened := dcb.NewDcbStore(store.Database(), store.Namespace())
+
+	// When
+	for _, err := range reopened.ReadAll(ctx) {
+		require.NoError(tt, err)
+	}
+	_, fetchErr := reopened.(dcb.EventFetcher).FetchEvent(ctx, dcb.Versionstamp{1})
+
+	// Then
+	assert.Error(tt, fetchErr) // nothing at that position
+	value, err := store.Database().ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(formatKey(store)).Get()
+	})
+	require.NoError(tt, err)
+	assert.Nil(tt, value)
+}
+
+func TestFormat_NewerFormatsAreRejected(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a namespace upgraded by a newer version
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		tr.Set(formatKey(store), tuple.Tuple{int64(dcb.FormatVersion + 1)}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+
+	// When
+	appendErr := store.Append(ctx, []dcb.Event{{Type: "item_added", Tags: []string{"a"}}})
+	var readErr error
+	for _, err := range store.ReadAll(ctx) {
+		readErr = err
+	}
+	_, upgradeErr := store.Upgrade(ctx)
+
+	// Then
+	assert.ErrorIs(tt, appendErr, dcb.ErrIncompatibleFormat)
+	assert.ErrorIs(tt, readErr, dcb.ErrIncompatibleFormat)
+	assert.ErrorIs(tt, upgradeErr, dcb.ErrIncompatibleFormat)
+}
+
+func TestFormat_UpgradeCanonicalizesLegacyTags(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a namespace written before the format was versioned, with unsorted and duplicate tags
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		key, err := subspace.Sub(store.Namespace()).Sub("e").PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)})
+		if err != nil {
+			return nil, err
+		}
+		tr.SetVersionstampedKey(key, tuple.Tuple{"legacy", tuple.Tuple{"list:2", "cart:1", "list:2"}, []byte("{}")}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+	require.Len(tt, dcb.CollectEvents(tt, store.ReadAll(ctx)), 1) // legacy formats are still readable
+
+	// When
+	report, err := store.Upgrade(ctx)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, 1, report.From)
+	assert.Equal(tt, dcb.FormatVersion, report.To)
+	assert.Len(tt, report.Applied, 2)
+	events := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, events, 1)
+	assert.Equal(tt, []string{"cart:1", "list:2"}, events[0].Tags)
+	assert.Equal(tt, []byte("{}"), events[0].Data)
+	normalization, err := dcb.CheckTagNormalization(ctx, store)
+	require.NoError(tt, err)
+	assert.Zero(tt, normalization.NonCanonical)
+}
+
+func TestFormat_UpgradeReportsMalformedLegacyTags(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a legacy event whose tags are not all strings
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		key, err := subspace.Sub(store.Namespace()).Sub("e").PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)})
+		if err != nil {
+			return nil, err
+		}
+		tr.SetVersionstampedKey(key, tuple.Tuple{"legacy", tuple.Tuple{"list:2", int64(1)}, []byte("{}")}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+
+	// When
+	_, err = store.Upgrade(ctx)
+
+	// Then - the upgrade fails instead of panicking
+	require.Error(tt, err)
+	assert.Contains(tt, err.Error(), "malformed")
+}
+
+func TestFormat_MetadataRequiresUpgrade(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - a namespace in the format preceding event metadata
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		tr.Set(formatKey(store), tuple.Tuple{int64(2)}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+	event := dcb.Event{Type: "item_added", Metadata: map[string]string{"actor": "user:42"}}
+
+	// When
+	beforeErr := store.Append(ctx, []dcb.Event{event})
+	report, upgradeErr := store.Upgrade(ctx)
+	afterErr := store.Append(ctx, []dcb.Event{event})
+
+	// Then
+	assert.ErrorIs(tt, beforeErr, dcb.ErrUpgradeRequired)
+	require.NoError(tt, upgradeErr)
+	assert.Equal(tt, dcb.UpgradeReport{From: 2, To: dcb.FormatVersion, Applied: []string{"allow event metadata"}}, report)
+	require.NoError(tt, afterErr)
+	events := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	require.Len(tt, events, 1)
+	assert.Equal(tt, event.Metadata, events[0].Metadata)
+}
@@ -0,0 +1,381 @@
+package dcb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"sync/atomic"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+var (
+	// ErrMigrationUnsupported is returned by NewMigration for stores it cannot migrate between
+	ErrMigrationUnsupported = errors.New("namespace migration not supported")
+	// ErrMigrationDiverged is returned by Cutover when the target namespace does not match the source
+	ErrMigrationDiverged = errors.New("namespaces diverged")
+)
+
+const (
+	// migrationBatchSize is the number of events copied or compared per transaction
+	migrationBatchSize = 500
+	// maxDivergenceExamples is the number of diverging positions reported by Verify
+	maxDivergenceExamples = 100
+)
+
+// Migration moves the events of a namespace to another one of the same database, without downtime:
+//
+//  1. serve the application with Store(): every append is written to both namespaces in the same transaction,
+//     so the copies get the same positions (cursors and positions kept by consumers stay valid)
+//  2. Backfill copies the events committed before, at their positions
+//  3. Verify compares both namespaces, Cutover switches the reads of Store() to the target
+//
+// Once every process reads the target, the application moves to a store of the target namespace
+// and the source namespace can be cleared.
+type Migration struct {
+	from, to *fdbStore
+	dual     *fdbStore // from, mirroring its appends to to
+
+	backfilledKey fdb.Key // last source position copied by Backfill
+	cutoverKey    fdb.Key // when Cutover succeeded
+	cutOver       atomic.Bool
+}
+
+// MigrationDivergence reports the differences Verify found between the namespaces
+type MigrationDivergence struct {
+	Compared  int            // positions compared
+	Missing   int            // events of the source missing from the target
+	Extra     int            // events of the target missing from the source
+	Different int            // events stored at the same position with different contents
+	Examples  []Versionstamp // first diverging positions
+}
+
+// Diverged reports whether the namespaces differ
+func (d MigrationDivergence) Diverged() bool {
+	return d.Missing+d.Extra+d.Different > 0
+}
+
+// NewMigration prepares the migration of the events of from to to, stores of distinct namespaces in the same database.
+// A store with an archive tier cannot be migrated: its archived events are not in the namespace anymore.
+func NewMigration(from, to DcbStore) (*Migration, error) {
+	source, ok := from.(*fdbStore)
+	if !ok || source.archive != nil {
+		return nil, fmt.Errorf("%w: source must be an FDB store without archive tier", ErrMigrationUnsupported)
+	}
+	target, ok := to.(*fdbStore)
+	if !ok {
+		return nil, fmt.Errorf("%w: target must be an FDB store", ErrMigrationUnsupported)
+	}
+	if source.namespace == target.namespace {
+		return nil, fmt.Errorf("%w: source and target are both namespace %q", ErrMigrationUnsupported, source.namespace)
+	}
+
+	// Backfilled events are copied as stored: the target must not start in a newer format than the source
+	for _, store := range []*fdbStore{source, target} {
+		version, err := store.formatVersion()
+		if err != nil {
+			return nil, fmt.Errorf("reading storage format: %w", err)
+		}
+		if version != FormatVersion {
+			return nil, fmt.Errorf("%w: namespace %q has format %d, upgrade it before migrating", ErrUpgradeRequired, store.namespace, version)
+		}
+	}
+
+	dual := *source
+	dual.mirror = target
+	state := subspace.Sub(target.namespace).Sub("m")
+	m := &Migration{
+		from:          source,
+		to:            target,
+		dual:          &dual,
+		backfilledKey: state.Pack(tuple.Tuple{"backfilled"}),
+		cutoverKey:    state.Pack(tuple.Tuple{"cutover"}),
+	}
+
+	cutover, err := source.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(m.cutoverKey).Get()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading migration state: %w", err)
+	}
+	m.cutOver.Store(cutover.([]byte) != nil)
+	return m, nil
+}
+
+// Store returns the store the application uses during the migration.
+// Appends and supersessions are written to both namespaces, append conditions are checked on the source.
+// Reads are served by the source until Cutover, by the target after.
+func (m *Migration) Store() DcbStore {
+	return migratingStore{m}
+}
+
+// CutOver reports whether the reads are served by the target
+func (m *Migration) CutOver() bool {
+	return m.cutOver.Load()
+}
+
+// Backfill copies the events of the source missing from the target, with their supersessions.
+// It returns the number of events copied. It is resumable: an interrupted run is continued by the next one.
+func (m *Migration) Backfill(ctx context.Context) (int, error) {
+	copied := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+
+		res, err := m.from.db.Transact(func(tr fdb.Transaction) (any, error) {
+			return m.backfillBatch(ctx, tr)
+		})
+		if err != nil {
+			return copied, fmt.Errorf("backfilling namespace %q: %w", m.to.namespace, err)
+		}
+		batch := res.(backfillBatch)
+		copied += batch.copied
+		if batch.done {
+			m.from.logger.Info("namespace backfill completed", "from", m.from.namespace, "to", m.to.namespace, "copied", copied)
+			return copied, nil
+		}
+	}
+}
+
+// backfillBatch is the outcome of a backfill transaction
+type backfillBatch struct {
+	copied int
+	done   bool
+}
+
+// backfillBatch copies the next source events, up to the batch size and the transaction size
+func (m *Migration) backfillBatch(ctx context.Context, tr fdb.Transaction) (backfillBatch, error) {
+	if err := m.to.recordFormatInTx(tr); err != nil {
+		return backfillBatch{}, err
+	}
+	r, err := m.afterCursor(tr, m.from.events)
+	if err != nil {
+		return backfillBatch{}, err
+	}
+	kvs, err := tr.GetRange(r, fdb.RangeOptions{Limit: migrationBatchSize}).GetSliceWithError()
+	if err != nil {
+		return backfillBatch{}, err
+	}
+
+	var batch backfillBatch
+	size := 0
+	examined := 0
+	for _, kv := range kvs {
+		vs := extractVersionstamp(kv.Key)
+		tupleVs := vs.tupleVersionstamp()
+
+		existing, err := tr.Get(m.to.events.Pack(tuple.Tuple{tupleVs})).Get()
+		if err != nil {
+			return backfillBatch{}, err
+		}
+		if existing == nil {
+			event, _, err := decodeEvent(ctx, kv.Value)
+			if err != nil {
+				return backfillBatch{}, fmt.Errorf("event at versionstamp %x: %w", vs[:], err)
+			}
+			subsets := generateAllSubsets(event.Tags)
+			sizes, _ := m.to.encodedSizes([]Event{event}, [][][]string{subsets})
+			if examined > 0 && size+sizes[0] > m.to.maxTxBytes/2 {
+				break // the rest goes to the next transaction
+			}
+			size += sizes[0]
+
+			if err := m.to.writeEvent(tr, event, subsets, tupleVs, kv.Value); err != nil {
+				return backfillBatch{}, err
+			}
+			batch.copied++
+		}
+
+		supersession, err := tr.Get(m.from.supersessionKey(vs)).Get()
+		if err != nil {
+			return backfillBatch{}, err
+		}
+		if supersession != nil {
+			tr.Set(m.to.supersessionKey(vs), supersession)
+		}
+
+		tr.Set(m.backfilledKey, vs[:])
+		examined++
+	}
+
+	batch.done = examined == len(kvs) && len(kvs) < migrationBatchSize
+	return batch, nil
+}
+
+// afterCursor returns the range of ss after the last position copied by Backfill
+func (m *Migration) afterCursor(tr fdb.ReadTransaction, ss subspace.Subspace) (fdb.Range, error) {
+	cursor, err := tr.Get(m.backfilledKey).Get()
+	if err != nil {
+		return nil, err
+	}
+	if len(cursor) != len(Versionstamp{}) {
+		return ss, nil
+	}
+	return rangeAfterVersionstamp(ss, Versionstamp(cursor))
+}
+
+// Verify compares the events stored in both namespaces, position by position
+func (m *Migration) Verify(ctx context.Context) (MigrationDivergence, error) {
+	var divergence MigrationDivergence
+	var after *Versionstamp
+	for {
+		if err := ctx.Err(); err != nil {
+			return divergence, err
+		}
+
+		res, err := m.from.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+			return m.verifyBatch(tr, after, &divergence)
+		})
+		if err != nil {
+			return divergence, fmt.Errorf("verifying namespace %q: %w", m.to.namespace, err)
+		}
+		if after = res.(*Versionstamp); after == nil {
+			return divergence, nil
+		}
+	}
+}
+
+// verifyBatch compares the next positions of both namespaces, adding the differences to divergence.
+// It returns the last position compared, nil once both namespaces are exhausted.
+func (m *Migration) verifyBatch(tr fdb.ReadTransaction, after *Versionstamp, divergence *MigrationDivergence) (*Versionstamp, error) {
+	page := func(ss subspace.Subspace) ([]fdb.KeyValue, error) {
+		var r fdb.Range = ss
+		if after != nil {
+			var err error
+			if r, err = rangeAfterVersionstamp(ss, *after); err != nil {
+				return nil, err
+			}
+		}
+		return tr.GetRange(r, fdb.RangeOptions{Limit: migrationBatchSize}).GetSliceWithError()
+	}
+	source, err := page(m.from.events)
+	if err != nil {
+		return nil, err
+	}
+	target, err := page(m.to.events)
+	if err != nil {
+		return nil, err
+	}
+
+	// Positions past a full page may be on the next page of the other namespace: compare up to the lowest full page end
+	var bound *Versionstamp
+	for _, kvs := range [][]fdb.KeyValue{source, target} {
+		if len(kvs) == migrationBatchSize {
+			last := extractVersionstamp(kvs[len(kvs)-1].Key)
+			if bound == nil || last.Compare(*bound) < 0 {
+				bound = &last
+			}
+		}
+	}
+	within := func(vs Versionstamp) bool {
+		return bound == nil || vs.Compare(*bound) <= 0
+	}
+	diverge := func(vs Versionstamp, count *int) {
+		*count++
+		if len(divergence.Examples) < maxDivergenceExamples {
+			divergence.Examples = append(divergence.Examples, vs)
+		}
+	}
+
+	i, j := 0, 0
+	for {
+		var sourceVs, targetVs *Versionstamp
+		if i < len(source) {
+			if vs := extractVersionstamp(source[i].Key); within(vs) {
+				sourceVs = &vs
+			}
+		}
+		if j < len(target) {
+			if vs := extractVersionstamp(target[j].Key); within(vs) {
+				targetVs = &vs
+			}
+		}
+
+		switch {
+		case sourceVs == nil && targetVs == nil:
+			return bound, nil
+		case targetVs == nil || (sourceVs != nil && sourceVs.Compare(*targetVs) < 0):
+			diverge(*sourceVs, &divergence.Missing)
+			i++
+		case sourceVs == nil || targetVs.Compare(*sourceVs) < 0:
+			diverge(*targetVs, &divergence.Extra)
+			j++
+		default:
+			if !bytes.Equal(source[i].Value, target[j].Value) {
+				diverge(*sourceVs, &divergence.Different)
+			}
+			i++
+			j++
+		}
+		divergence.Compared++
+	}
+}
+
+// Cutover verifies the namespaces and switches the reads of Store() to the target.
+// It returns ErrMigrationDiverged, with the divergence, when they differ: backfill again before retrying.
+// The cutover is persisted: migrations created afterwards read the target from the start.
+func (m *Migration) Cutover(ctx context.Context) (MigrationDivergence, error) {
+	divergence, err := m.Verify(ctx)
+	if err != nil {
+		return divergence, err
+	}
+	if divergence.Diverged() {
+		return divergence, fmt.Errorf("%w: %d missing, %d extra, %d different", ErrMigrationDiverged, divergence.Missing, divergence.Extra, divergence.Different)
+	}
+
+	_, err = m.from.db.Transact(func(tr fdb.Transaction) (any, error) {
+		at := make([]byte, 8)
+		binary.BigEndian.PutUint64(at, uint64(time.Now().UnixNano()))
+		tr.Set(m.cutoverKey, at)
+		return nil, nil
+	})
+	if err != nil {
+		return divergence, fmt.Errorf("persisting cutover: %w", err)
+	}
+	m.cutOver.Store(true)
+	m.from.logger.Info("namespace cutover completed", "from", m.from.namespace, "to", m.to.namespace, "events", divergence.Compared)
+	return divergence, nil
+}
+
+// migratingStore is the store served during a migration, see Migration.Store
+type migratingStore struct {
+	m *Migration
+}
+
+// reader is the store serving reads
+func (s migratingStore) reader() *fdbStore {
+	if s.m.cutOver.Load() {
+		return s.m.to
+	}
+	return s.m.from
+}
+
+func (s migratingStore) Append(ctx context.Context, events []Event, conditions ...AppendCondition) error {
+	return s.m.dual.Append(ctx, events, conditions...)
+}
+
+func (s migratingStore) Read(ctx context.Context, query Query, opts *ReadOptions) iter.Seq2[StoredEvent, error] {
+	return s.reader().Read(ctx, query, opts)
+}
+
+func (s migratingStore) ReadAll(ctx context.Context) iter.Seq2[StoredEvent, error] {
+	return s.reader().ReadAll(ctx)
+}
+
+func (s migratingStore) FetchEvent(ctx context.Context, position Versionstamp) (StoredEvent, error) {
+	return s.reader().FetchEvent(ctx, position)
+}
+
+func (s migratingStore) Supersede(ctx context.Context, position Versionstamp, reason string) error {
+	return s.m.dual.Supersede(ctx, position, reason)
+}
+
+func (s migratingStore) Database() fdb.Database { return s.m.from.db }
+func (s migratingStore) Namespace() string      { return s.m.from.namespace }
@@ -0,0 +1,132 @@
+package dcb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigration_CopiesEventsAtTheirPositions(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events committed before the migration, and one superseded
+	ctx := context.Background()
+	from := dcb.SetupTestStore(tt)
+	to := dcb.SetupTestStore(tt)
+	require.NoError(tt, from.Append(ctx, []dcb.Event{
+		{Type: "price_set", Tags: []string{"product:1"}, Data: []byte("10")},
+		{Type: "price_set", Tags: []string{"product:2"}, Data: []byte("20")},
+	}))
+	before := dcb.CollectEvents(tt, from.ReadAll(ctx))
+	require.NoError(tt, from.Supersede(ctx, before[0].Position, "typo"))
+
+	migration, err := dcb.NewMigration(from, to)
+	require.NoError(tt, err)
+	store := migration.Store()
+
+	// When - the application appends while the backfill runs
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}, Data: []byte("11")}}))
+	copied, err := migration.Backfill(ctx)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, 2, copied)
+	divergence, err := migration.Verify(ctx)
+	require.NoError(tt, err)
+	assert.False(tt, divergence.Diverged())
+	assert.Equal(tt, 3, divergence.Compared)
+
+	source := dcb.CollectEvents(tt, from.ReadAll(ctx))
+	target := dcb.CollectEvents(tt, to.ReadAll(ctx))
+	assert.Equal(tt, source, target)
+	query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"price_set"}, Tags: []string{"product:1"}}}}
+	assert.Equal(tt, dcb.CollectEvents(tt, from.Read(ctx, query, nil)), dcb.CollectEvents(tt, to.Read(ctx, query, nil)))
+	require.NotNil(tt, target[0].Superseded)
+	assert.Equal(tt, "typo", target[0].Superseded.Reason)
+}
+
+func TestMigration_BackfillIsIdempotent(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	from := dcb.SetupTestStore(tt)
+	to := dcb.SetupTestStore(tt)
+	require.NoError(tt, from.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}}}))
+	migration, err := dcb.NewMigration(from, to)
+	require.NoError(tt, err)
+	_, err = migration.Backfill(ctx)
+	require.NoError(tt, err)
+
+	// When
+	copied, err := migration.Backfill(ctx)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Zero(tt, copied)
+	assert.Len(tt, dcb.CollectEvents(tt, to.ReadAll(ctx)), 1)
+}
+
+func TestMigration_CutoverRefusesDivergedNamespaces(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events not backfilled yet
+	ctx := context.Background()
+	from := dcb.SetupTestStore(tt)
+	to := dcb.SetupTestStore(tt)
+	require.NoError(tt, from.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}}}))
+	migration, err := dcb.NewMigration(from, to)
+	require.NoError(tt, err)
+
+	// When
+	divergence, err := migration.Cutover(ctx)
+
+	// Then
+	require.ErrorIs(tt, err, dcb.ErrMigrationDiverged)
+	assert.Equal(tt, 1, divergence.Missing)
+	assert.Len(tt, divergence.Examples, 1)
+	assert.False(tt, migration.CutOver())
+}
+
+func TestMigration_CutoverSwitchesReadsToTheTarget(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	from := dcb.SetupTestStore(tt)
+	to := dcb.SetupTestStore(tt)
+	require.NoError(tt, from.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}}}))
+	migration, err := dcb.NewMigration(from, to)
+	require.NoError(tt, err)
+	_, err = migration.Backfill(ctx)
+	require.NoError(tt, err)
+
+	// When
+	_, err = migration.Cutover(ctx)
+
+	// Then - reads come from the target, even for migrations created afterwards
+	require.NoError(tt, err)
+	assert.True(tt, migration.CutOver())
+	require.NoError(tt, to.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:2"}}}))
+	assert.Len(tt, dcb.CollectEvents(tt, migration.Store().ReadAll(ctx)), 2)
+
+	resumed, err := dcb.NewMigration(from, to)
+	require.NoError(tt, err)
+	assert.True(tt, resumed.CutOver())
+}
+
+func TestMigration_RejectsTheSameNamespace(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	_, err := dcb.NewMigration(store, store)
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrMigrationUnsupported)
+}
@@ -1,6 +1,12 @@
 package dcb
 
-import "time"
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+)
 
 // Logger defines the logging interface for the EventStore.
 type Logger interface {
@@ -18,6 +24,95 @@ func (noopLogger) Info(string, ...any)  {}
 func (noopLogger) Warn(string, ...any)  {}
 func (noopLogger) Error(string, ...any) {}
 
+// LogLevel is the minimum level of the messages the store logs
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota // every message (default)
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// leveledLogger drops the messages below its level
+type leveledLogger struct {
+	Logger
+	level LogLevel
+}
+
+func (l leveledLogger) Debug(msg string, keysAndValues ...any) {
+	if l.level <= LogLevelDebug {
+		l.Logger.Debug(msg, keysAndValues...)
+	}
+}
+
+func (l leveledLogger) Info(msg string, keysAndValues ...any) {
+	if l.level <= LogLevelInfo {
+		l.Logger.Info(msg, keysAndValues...)
+	}
+}
+
+func (l leveledLogger) Warn(msg string, keysAndValues ...any) {
+	if l.level <= LogLevelWarn {
+		l.Logger.Warn(msg, keysAndValues...)
+	}
+}
+
+// withLevel returns l dropping the messages below level
+func withLevel(l Logger, level LogLevel) Logger {
+	if leveled, ok := l.(leveledLogger); ok {
+		l = leveled.Logger
+	}
+	if level <= LogLevelDebug {
+		return l
+	}
+	return leveledLogger{Logger: l, level: level}
+}
+
+// WithLogLevel drops the messages of the store below level, e.g. LogLevelWarn keeps slow operations and failures
+func (StoreOptions) WithLogLevel(level LogLevel) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.logLevel = level
+		e.logger = withLevel(e.logger, level)
+	}
+}
+
+// WithLogSampling logs the given fraction (0 to 1) of the successful operations of a kind:
+// "append", "read" or "read_all". Failures are always logged.
+func (StoreOptions) WithLogSampling(operation string, rate float64) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		sampling := maps.Clone(e.logSampling)
+		if sampling == nil {
+			sampling = make(map[string]float64)
+		}
+		sampling[operation] = min(max(rate, 0), 1)
+		e.logSampling = sampling
+	}
+}
+
+// WithSlowOperationLogging logs successful operations only when they take at least threshold, at Warn level.
+// Failures are always logged.
+func (StoreOptions) WithSlowOperationLogging(threshold time.Duration) func(s *fdbStore) {
+	return func(e *fdbStore) {
+		e.slowLogThreshold = threshold
+	}
+}
+
+// logCompleted logs a successful operation, subject to the slow operation threshold and the sampling of its kind
+func (s fdbStore) logCompleted(operation, msg string, duration time.Duration, keysAndValues ...any) {
+	keysAndValues = append(keysAndValues, "duration", duration)
+	if s.slowLogThreshold > 0 {
+		if duration >= s.slowLogThreshold {
+			s.logger.Warn(msg, append(keysAndValues, "slow_threshold", s.slowLogThreshold)...)
+		}
+		return
+	}
+	if rate, sampled := s.logSampling[operation]; sampled && rand.Float64() >= rate {
+		return
+	}
+	s.logger.Info(msg, keysAndValues...)
+}
+
 // Metrics defines the observability interface for the EventStore.
 type Metrics interface {
 	// Append metrics
@@ -40,3 +135,63 @@ func (noopMetrics) RecordAppendEvents(int)                   {}
 func (noopMetrics) RecordReadDuration(time.Duration, bool)   {}
 func (noopMetrics) RecordReadEvents(int)                     {}
 func (noopMetrics) RecordError(string, string)               {}
+
+// NamespaceMetrics is optionally implemented by Metrics shared by the stores of several namespaces
+// (e.g. the tenants of a TenantStoreFactory), to tell them apart: each store records through
+// ForNamespace(its namespace), which returns the metrics labeled with it. The returned Metrics
+// implement the optional interfaces (ConflictMetrics...) the store should record to.
+type NamespaceMetrics interface {
+	ForNamespace(namespace string) Metrics
+}
+
+// ConflictMetrics is optionally implemented by Metrics to find the hot append conditions:
+// RecordAppendConflict is called with the QueryShape of each condition that failed an append
+// with ErrAppendConditionFailed (FoundationDB's own conflicts are retried and not recorded).
+type ConflictMetrics interface {
+	RecordAppendConflict(queryShape string)
+}
+
+// recordConflicts reports the shapes of the failed conditions to ConflictMetrics
+func (s fdbStore) recordConflicts(conflicts []Query) {
+	cm, isConflictMetrics := s.metrics.(ConflictMetrics)
+	if !isConflictMetrics {
+		return
+	}
+	for _, q := range conflicts {
+		cm.RecordAppendConflict(QueryShape(q))
+	}
+}
+
+// QueryShape describes a query without its tag values, e.g. "item_added|item_removed[list:*]",
+// so metrics labeled by shape keep a bounded cardinality.
+// Types and tags are sorted, items are joined with " + ", "*" stands for any type.
+// Bare tags (without value) are kept as is: identifiers should be structured tags (see Tag).
+func QueryShape(q Query) string {
+	items := make([]string, 0, len(q.Items))
+	for _, item := range q.Items {
+		shape := "*"
+		if len(item.Types) > 0 {
+			types := slices.Clone(item.Types)
+			slices.Sort(types)
+			shape = strings.Join(slices.Compact(types), "|")
+		}
+
+		tags := make([]string, 0, len(item.Tags))
+		for _, tag := range item.Tags {
+			if key, _, structured := strings.Cut(tag, TagSeparator); structured {
+				tag = key + TagSeparator + "*"
+			}
+			tags = append(tags, tag)
+		}
+		for _, key := range item.TagKeys {
+			tags = append(tags, key+TagSeparator+"*")
+		}
+		if len(tags) > 0 {
+			slices.Sort(tags)
+			shape += "[" + strings.Join(slices.Compact(tags), ",") + "]"
+		}
+		items = append(items, shape)
+	}
+	slices.Sort(items)
+	return strings.Join(slices.Compact(items), " + ")
+}
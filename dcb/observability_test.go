@@ -0,0 +1,144 @@
+package dcb_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// levelLogger keeps the messages logged at each level, as "level: message"
+type levelLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *levelLogger) log(level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+": "+msg)
+}
+
+func (l *levelLogger) Debug(msg string, _ ...any) { l.log("debug", msg) }
+func (l *levelLogger) Info(msg string, _ ...any)  { l.log("info", msg) }
+func (l *levelLogger) Warn(msg string, _ ...any)  { l.log("warn", msg) }
+func (l *levelLogger) Error(msg string, _ ...any) { l.log("error", msg) }
+
+// appendAndRead appends an event then reads it back
+func appendAndRead(tt *testing.T, store dcb.DcbStore) {
+	ctx := context.Background()
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "item_added", Tags: []string{"a"}}}))
+	dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}, nil))
+}
+
+func TestLogLevel_DropsMessagesBelowTheLevel(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - the level set before the logger
+	store := dcb.SetupTestStore(tt)
+	logger := &levelLogger{}
+	dcb.StoreOptions{}.WithLogLevel(dcb.LogLevelWarn)(store)
+	dcb.StoreOptions{}.WithLogger(logger)(store)
+
+	// When
+	appendAndRead(tt, store)
+	err := store.Append(context.Background(), []dcb.Event{{Type: ""}})
+
+	// Then
+	require.Error(tt, err)
+	assert.Equal(tt, []string{"error: event validation"}, logger.messages)
+}
+
+func TestLogSampling_AppliesToItsOperationOnly(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+	logger := &levelLogger{}
+	dcb.StoreOptions{}.WithLogger(logger)(store)
+	dcb.StoreOptions{}.WithLogSampling("append", 0)(store)
+
+	// When
+	appendAndRead(tt, store)
+
+	// Then
+	assert.Equal(tt, []string{"info: read completed"}, logger.messages)
+}
+
+func TestSlowOperationLogging_OnlyLogsSlowOperations(tt *testing.T) {
+	tt.Parallel()
+
+	for name, tc := range map[string]struct {
+		threshold time.Duration
+		expected  []string
+	}{
+		"fast operations": {threshold: time.Hour, expected: nil},
+		"slow operations": {threshold: time.Nanosecond, expected: []string{"warn: append completed", "warn: read completed"}},
+	} {
+		tt.Run(name, func(tt *testing.T) {
+			tt.Parallel()
+
+			// Given
+			store := dcb.SetupTestStore(tt)
+			logger := &levelLogger{}
+			dcb.StoreOptions{}.WithLogger(logger)(store)
+			dcb.StoreOptions{}.WithSlowOperationLogging(tc.threshold)(store)
+
+			// When
+			appendAndRead(tt, store)
+
+			// Then
+			assert.Equal(tt, tc.expected, logger.messages)
+		})
+	}
+}
+
+// namespaceMetrics counts the appended events of each namespace
+type namespaceMetrics struct {
+	mu       sync.Mutex
+	appended map[string]int
+}
+
+// namespaceRecorder records the metrics of a namespace
+type namespaceRecorder struct {
+	*namespaceMetrics
+	namespace string
+}
+
+func (m *namespaceMetrics) ForNamespace(namespace string) dcb.Metrics {
+	return namespaceRecorder{namespaceMetrics: m, namespace: namespace}
+}
+
+func (m *namespaceMetrics) RecordAppendDuration(time.Duration, bool) {}
+func (m *namespaceMetrics) RecordAppendEvents(int)                   {}
+func (m *namespaceMetrics) RecordReadDuration(time.Duration, bool)   {}
+func (m *namespaceMetrics) RecordReadEvents(int)                     {}
+func (m *namespaceMetrics) RecordError(string, string)               {}
+
+func (r namespaceRecorder) RecordAppendEvents(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.appended[r.namespace] += count
+}
+
+func TestNamespaceMetrics_LabelsEachStore(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - two stores sharing the metrics
+	ctx := context.Background()
+	metrics := &namespaceMetrics{appended: map[string]int{}}
+	first, second := dcb.SetupTestStore(tt), dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithMetrics(metrics)(first)
+	dcb.StoreOptions{}.WithMetrics(metrics)(second)
+
+	// When
+	require.NoError(tt, first.Append(ctx, []dcb.Event{{Type: "item_added"}, {Type: "item_added"}}))
+	require.NoError(tt, second.Append(ctx, []dcb.Event{{Type: "item_added"}}))
+
+	// Then
+	assert.Equal(tt, map[string]int{first.Namespace(): 2, second.Namespace(): 1}, metrics.appended)
+}
@@ -0,0 +1,114 @@
+package dcb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrInvalidPositionToken is returned when a position token cannot be decoded
+var ErrInvalidPositionToken = errors.New("invalid position token")
+
+// positionTokenVersion prefixes tokens so the encoding can evolve without breaking clients
+const positionTokenVersion byte = 1
+
+// BatchIndex returns the index of the event in the batch it was appended with (the user version)
+func (v Versionstamp) BatchIndex() uint16 {
+	return binary.BigEndian.Uint16(v[10:12])
+}
+
+// SameTransaction reports whether both positions were assigned by the same append
+func (v Versionstamp) SameTransaction(other Versionstamp) bool {
+	return [10]byte(v[:10]) == [10]byte(other[:10])
+}
+
+// MarshalText encodes the versionstamp as hex (same as String)
+func (v Versionstamp) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText decodes a hex-encoded versionstamp
+func (v *Versionstamp) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(v) {
+		return fmt.Errorf("versionstamp must be %d hex characters, got %d", hex.EncodedLen(len(v)), len(text))
+	}
+	_, err := hex.Decode(v[:], text)
+	return err
+}
+
+// Token returns an opaque URL-safe token for the position, suitable for HTTP headers,
+// pagination cursors and SSE Last-Event-ID. Decode it with ParsePositionToken.
+func (v Versionstamp) Token() string {
+	buf := make([]byte, 1+len(v))
+	buf[0] = positionTokenVersion
+	copy(buf[1:], v[:])
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// ParsePositionToken decodes a token produced by Versionstamp.Token
+func ParsePositionToken(token string) (Versionstamp, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Versionstamp{}, fmt.Errorf("%w: %s", ErrInvalidPositionToken, err)
+	}
+
+	var v Versionstamp
+	if len(buf) != 1+len(v) {
+		return Versionstamp{}, fmt.Errorf("%w: unexpected length %d", ErrInvalidPositionToken, len(buf))
+	}
+	if buf[0] != positionTokenVersion {
+		return Versionstamp{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidPositionToken, buf[0])
+	}
+
+	copy(v[:], buf[1:])
+	return v, nil
+}
+
+// AppendedPositions collects the positions of the events appended with a context returned by
+// WithAppendedPositions, so callers of code that only returns an error (e.g. commands) can learn where their events landed
+type AppendedPositions struct {
+	mu        sync.Mutex
+	positions []Versionstamp
+}
+
+type appendedPositionsKey struct{}
+
+// WithAppendedPositions returns a context recording the positions of the events appended with it
+func WithAppendedPositions(ctx context.Context) (context.Context, *AppendedPositions) {
+	p := &AppendedPositions{}
+	return context.WithValue(ctx, appendedPositionsKey{}, p), p
+}
+
+// appendedPositionsFrom returns the recorder attached to the context, nil if none
+func appendedPositionsFrom(ctx context.Context) *AppendedPositions {
+	p, _ := ctx.Value(appendedPositionsKey{}).(*AppendedPositions)
+	return p
+}
+
+// All returns the recorded positions, in append order
+func (p *AppendedPositions) All() []Versionstamp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.positions)
+}
+
+// Last returns the position of the last appended event, false if nothing was appended
+func (p *AppendedPositions) Last() (Versionstamp, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.positions) == 0 {
+		return Versionstamp{}, false
+	}
+	return p.positions[len(p.positions)-1], true
+}
+
+func (p *AppendedPositions) record(positions []Versionstamp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.positions = append(p.positions, positions...)
+}
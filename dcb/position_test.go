@@ -0,0 +1,63 @@
+package dcb_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func randomVersionstamp(t *rapid.T) dcb.Versionstamp {
+	var vs dcb.Versionstamp
+	copy(vs[:], rapid.SliceOfN(rapid.Byte(), 12, 12).Draw(t, "vsBytes"))
+	return vs
+}
+
+func TestPositionToken_RoundTrip(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		vs := randomVersionstamp(t)
+
+		// When
+		token := vs.Token()
+		parsed, err := dcb.ParsePositionToken(token)
+
+		// Then - URL-safe and lossless
+		require.NoError(t, err)
+		assert.Equal(t, vs, parsed)
+		assert.Equal(t, token, url.QueryEscape(token))
+	})
+}
+
+func TestParsePositionToken_RejectsGarbage(tt *testing.T) {
+	tt.Parallel()
+
+	for _, token := range []string{"", "not base64!", "AQID", dcb.Versionstamp{}.String()} {
+		_, err := dcb.ParsePositionToken(token)
+		assert.ErrorIs(tt, err, dcb.ErrInvalidPositionToken, "token %q", token)
+	}
+}
+
+func TestVersionstamp_JSONRoundTrip(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		vs := randomVersionstamp(t)
+
+		// When
+		data, err := json.Marshal(struct{ Position dcb.Versionstamp }{vs})
+		require.NoError(t, err)
+		var decoded struct{ Position dcb.Versionstamp }
+		err = json.Unmarshal(data, &decoded)
+
+		// Then - encoded as hex string
+		require.NoError(t, err)
+		assert.Equal(t, vs, decoded.Position)
+		assert.Contains(t, string(data), vs.String())
+	})
+}
@@ -0,0 +1,167 @@
+package dcb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query terms of the text syntax
+const (
+	queryTermType   = "type"
+	queryTermTag    = "tag"
+	queryTermTagKey = "tagkey"
+	queryTermAfter  = "after"
+	queryItemSep    = '|'
+)
+
+// ParseQuery parses a query written as text, for CLIs, admin APIs and dashboards where queries
+// don't come from Go code. Items are separated by "|" (OR), and made of whitespace-separated terms:
+//
+//	type:ItemAdded type:ItemRemoved tag:cart:42 | type:CartCleared tagkey:cart after:<hex position>
+//
+// Types of an item match any of them, tags all of them, and tag keys a tag of each of them, whatever its value. Values containing whitespace, "|" or quotes are
+// written as Go quoted strings (tag:"name:Jane Doe"). The parsed query is validated (see Query.Validate).
+// Errors wrap ErrInvalidQuery.
+func ParseQuery(s string) (Query, error) {
+	p := queryParser{input: s}
+	var q Query
+	item := QueryItem{}
+	empty := true
+	for {
+		p.skipSpace()
+		if p.done() || p.peek() == queryItemSep {
+			if empty {
+				return Query{}, p.errorf("empty query item")
+			}
+			q.Items = append(q.Items, item)
+			if p.done() {
+				break
+			}
+			p.pos++ // the separator
+			item, empty = QueryItem{}, true
+			continue
+		}
+
+		if err := p.term(&item); err != nil {
+			return Query{}, err
+		}
+		empty = false
+	}
+
+	if err := q.Validate(); err != nil {
+		return Query{}, fmt.Errorf("parse query %q: %w", s, err)
+	}
+	return q, nil
+}
+
+// String writes the query in the text syntax read by ParseQuery
+func (q Query) String() string {
+	items := make([]string, len(q.Items))
+	for i, item := range q.Items {
+		items[i] = item.String()
+	}
+	return strings.Join(items, " | ")
+}
+
+// String writes the item in the text syntax read by ParseQuery
+func (q QueryItem) String() string {
+	terms := make([]string, 0, len(q.Types)+len(q.Tags)+len(q.TagKeys)+1)
+	for _, typ := range q.Types {
+		terms = append(terms, queryTermType+":"+quoteQueryValue(typ))
+	}
+	for _, tag := range q.Tags {
+		terms = append(terms, queryTermTag+":"+quoteQueryValue(tag))
+	}
+	for _, key := range q.TagKeys {
+		terms = append(terms, queryTermTagKey+":"+quoteQueryValue(key))
+	}
+	if q.After != nil {
+		terms = append(terms, queryTermAfter+":"+q.After.String())
+	}
+	return strings.Join(terms, " ")
+}
+
+// quoteQueryValue quotes the values ParseQuery can't read bare
+func quoteQueryValue(v string) string {
+	if v == "" || v[0] == '"' || strings.ContainsFunc(v, func(r rune) bool {
+		return r == queryItemSep || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// queryParser reads the text syntax of queries
+type queryParser struct {
+	input string
+	pos   int
+}
+
+func (p *queryParser) done() bool { return p.pos >= len(p.input) }
+
+func (p *queryParser) peek() byte { return p.input[p.pos] }
+
+func (p *queryParser) skipSpace() {
+	p.pos += len(p.input[p.pos:]) - len(strings.TrimLeftFunc(p.input[p.pos:], unicode.IsSpace))
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: parse %q at offset %d: %s", ErrInvalidQuery, p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+// term reads a "name:value" term into item
+func (p *queryParser) term(item *QueryItem) error {
+	start := p.pos
+	name, _, found := strings.Cut(p.input[p.pos:], ":")
+	if !found || name == "" || strings.ContainsFunc(name, func(r rune) bool { return r == queryItemSep || unicode.IsSpace(r) }) {
+		return p.errorf("expected type:, tag:, tagkey: or after:")
+	}
+	p.pos += len(name) + 1
+
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	switch name {
+	case queryTermType:
+		item.Types = append(item.Types, value)
+	case queryTermTag:
+		item.Tags = append(item.Tags, value)
+	case queryTermTagKey:
+		item.TagKeys = append(item.TagKeys, value)
+	case queryTermAfter:
+		var after Versionstamp
+		if err := after.UnmarshalText([]byte(value)); err != nil {
+			p.pos = start
+			return p.errorf("after: %s", err)
+		}
+		item.After = &after
+	default:
+		p.pos = start
+		return p.errorf("unknown term %q, expected type:, tag:, tagkey: or after:", name)
+	}
+	return nil
+}
+
+// value reads a bare value, up to whitespace or the item separator, or a quoted one
+func (p *queryParser) value() (string, error) {
+	rest := p.input[p.pos:]
+	if strings.HasPrefix(rest, `"`) {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", p.errorf("unterminated quoted value")
+		}
+		p.pos += len(quoted)
+		value, _ := strconv.Unquote(quoted)
+		return value, nil
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool { return r == queryItemSep || unicode.IsSpace(r) })
+	if end < 0 {
+		end = len(rest)
+	}
+	p.pos += end
+	return rest[:end], nil
+}
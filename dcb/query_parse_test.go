@@ -0,0 +1,76 @@
+package dcb_test
+
+import (
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestParseQuery(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	after := dcb.Versionstamp{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2}
+
+	// When
+	q, err := dcb.ParseQuery(`type:ItemAdded type:ItemRemoved tag:cart:42 |  type:CartCleared tag:"name:Jane Doe" tagkey:cart after:` + after.String())
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"ItemAdded", "ItemRemoved"}, Tags: []string{"cart:42"}},
+		{Types: []string{"CartCleared"}, Tags: []string{"name:Jane Doe"}, TagKeys: []string{"cart"}, After: &after},
+	}}, q)
+}
+
+func TestParseQuery_RejectsInvalidQueries(tt *testing.T) {
+	tt.Parallel()
+
+	for _, s := range []string{
+		"",
+		"type:A |",
+		"| type:A",
+		"type:A || type:B",
+		"ItemAdded",
+		"kind:A",
+		`tag:"unterminated`,
+		"type:A after:nothex",
+		"tag:",
+	} {
+		_, err := dcb.ParseQuery(s)
+		assert.ErrorIs(tt, err, dcb.ErrInvalidQuery, "query %q", s)
+	}
+}
+
+func TestParseQuery_RoundTrip(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		value := rapid.StringN(1, 20, -1)
+		item := rapid.Custom(func(t *rapid.T) dcb.QueryItem {
+			item := dcb.QueryItem{
+				Types: rapid.SliceOfN(value, 1, 3).Draw(t, "types"),
+				Tags:  rapid.SliceOfNDistinct(value, 0, 3, rapid.ID).Draw(t, "tags"),
+			}
+			if len(item.Tags) == 0 {
+				item.Tags = nil // as parsed
+			}
+			if rapid.Bool().Draw(t, "after") {
+				after := dcb.Versionstamp(rapid.SliceOfN(rapid.Byte(), 12, 12).Draw(t, "position"))
+				item.After = &after
+			}
+			return item
+		})
+		q := dcb.Query{Items: rapid.SliceOfN(item, 1, 3).Draw(t, "items")}
+
+		// When
+		parsed, err := dcb.ParseQuery(q.String())
+
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, q, parsed)
+	})
+}
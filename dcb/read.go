@@ -1,6 +1,7 @@
 package dcb
 
 import (
+	"bytes"
 	"container/heap"
 	"context"
 	"encoding/binary"
@@ -22,16 +23,35 @@ func (s fdbStore) Read(ctx context.Context, query Query, opts *ReadOptions) iter
 			return
 		}
 
+		if err := query.Validate(); err != nil {
+			s.metrics.RecordError("read", "invalid_query")
+			yield(StoredEvent{}, err)
+			return
+		}
+		if err := s.checkFormat(); err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+
 		if opts == nil {
 			opts = &ReadOptions{}
 		}
+		yield = s.traceRead(query, yield)
+
+		slot, err := s.acquireReadSlot(ctx, "read")
+		if err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+		defer slot.close()
+		yieldEvent := slot.yield(ctx, yield)
 
 		start := time.Now()
 		eventCount := 0
 
 		// Execute read in transaction
-		_, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
-			count, err := s.readEvents(ctx, tr, query, opts.After, opts, yield)
+		_, err = s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+			count, err := s.readEvents(ctx, tr, query, opts.After, opts, yieldEvent)
 			eventCount = count
 			return nil, err
 		})
@@ -42,7 +62,7 @@ func (s fdbStore) Read(ctx context.Context, query Query, opts *ReadOptions) iter
 		s.metrics.RecordReadDuration(duration, success)
 		if success {
 			s.metrics.RecordReadEvents(eventCount)
-			s.logger.Info("read completed", "event_count", eventCount, "duration", duration)
+			s.logCompleted("read", "read completed", duration, "event_count", eventCount)
 		} else {
 			s.logger.Error("read failed", err, "duration", duration)
 			yield(StoredEvent{}, err)
@@ -97,15 +117,13 @@ func (ri *rangeIterator) advance() (bool, error) {
 }
 
 // fetchEvent retrieves the full event data for a given versionstamp.
+// Cached events are returned without reading the store, fetched ones are cached.
 func (s fdbStore) fetchEvent(ctx context.Context, tr fdb.ReadTransaction, vs Versionstamp) (StoredEvent, error) {
-	// Convert 12-byte versionstamp to tuple.Versionstamp for packing
-	var txVersion [10]byte
-	copy(txVersion[:], vs[:10])
-	userVersion := binary.BigEndian.Uint16(vs[10:12])
-	tupleVs := tuple.Versionstamp{TransactionVersion: txVersion, UserVersion: userVersion}
+	if event, ok := s.cachedEvent(vs); ok {
+		return event, nil
+	}
 
-	eventKey := s.events.Pack(tuple.Tuple{tupleVs})
-	encodedValue := tr.Get(eventKey).MustGet()
+	encodedValue := tr.Get(s.eventKey(vs)).MustGet()
 
 	if encodedValue == nil {
 		// Event not found (shouldn't happen)
@@ -115,12 +133,51 @@ func (s fdbStore) fetchEvent(ctx context.Context, tr fdb.ReadTransaction, vs Ver
 		return StoredEvent{}, fmt.Errorf("versionstamp %x: no data in events subspace", vs[:])
 	}
 
-	event, err := decodeEvent(ctx, encodedValue)
+	event, committedAt, err := decodeEvent(ctx, encodedValue)
 	if err != nil {
 		return StoredEvent{}, fmt.Errorf("decoding event at versionstamp %x: %s", vs[:], err)
 	}
 
-	return StoredEvent{Event: *event, Position: vs}, nil
+	stored := StoredEvent{Event: event, Position: vs, CommittedAt: committedAt}
+	if cache := s.caching(); cache != nil {
+		cache.put(stored)
+	}
+	return stored, nil
+}
+
+// eventKey returns the key of the event at vs, packed like s.events.Pack(tuple.Tuple{vs.tupleVersionstamp()})
+// without going through a tuple
+func (s fdbStore) eventKey(vs Versionstamp) fdb.Key {
+	prefix := s.events.Bytes()
+	key := make(fdb.Key, len(prefix)+1+len(vs))
+	n := copy(key, prefix)
+	key[n] = tupleVersionstampCode
+	copy(key[n+1:], vs[:])
+	return key
+}
+
+// eventPosition returns the versionstamp of an events subspace key, the inverse of eventKey
+func (s fdbStore) eventPosition(key fdb.Key) (Versionstamp, error) {
+	prefix := s.events.Bytes()
+	if len(key) == len(prefix)+1+len(Versionstamp{}) && key[len(prefix)] == tupleVersionstampCode && bytes.HasPrefix(key, prefix) {
+		return Versionstamp(key[len(prefix)+1:]), nil
+	}
+
+	keyTuple, err := s.events.Unpack(key)
+	if err != nil {
+		return Versionstamp{}, fmt.Errorf("unpacking: %s", err)
+	}
+	if len(keyTuple) != 1 {
+		return Versionstamp{}, errors.New("invalid event key")
+	}
+	tupleVs, ok := keyTuple[0].(tuple.Versionstamp)
+	if !ok {
+		return Versionstamp{}, errors.New("invalid versionstamp in key")
+	}
+	var vs Versionstamp
+	copy(vs[:10], tupleVs.TransactionVersion[:])
+	binary.BigEndian.PutUint16(vs[10:12], tupleVs.UserVersion)
+	return vs, nil
 }
 
 // readEvents reads events from the transaction using k-way merge for streaming.
@@ -159,15 +216,21 @@ func (s fdbStore) readEvents(
 		}
 	}
 
+	superseded, err := s.anySuperseded(tr)
+	if err != nil {
+		return 0, fmt.Errorf("checking supersessions: %s", err)
+	}
+
 	// Build heap from all iterators (min-heap for forward, max-heap for reverse)
-	h := &vsHeap{reverse: opts.Reverse}
-	heap.Init(h)
+	h := &vsHeap{items: make([]heapItem, len(allIterators)), reverse: opts.Reverse}
 	for i, ri := range allIterators {
-		heap.Push(h, heapItem{iter: ri, index: i})
+		h.items[i] = heapItem{iter: ri, index: i}
 	}
+	heap.Init(h)
 
 	// K-way merge with deduplication
-	var lastEmitted *Versionstamp
+	var lastEmitted Versionstamp
+	emitted := false
 	eventCount := 0
 
 	for h.Len() > 0 {
@@ -178,24 +241,17 @@ func (s fdbStore) readEvents(
 		default:
 		}
 
-		// Pop iterator with smallest versionstamp
-		item := heap.Pop(h).(heapItem)
-		ri := item.iter
-		currentVS := ri.currentVS
+		// Iterator with smallest versionstamp
+		currentVS := h.items[0].iter.currentVS
 
 		// Deduplicate: skip if same as last emitted
-		if lastEmitted != nil && currentVS.Compare(*lastEmitted) == 0 {
-			// Advance and re-push if not exhausted
-			advanced, err := ri.advance()
-			if err != nil {
+		if emitted && currentVS.Compare(lastEmitted) == 0 {
+			if err := h.advanceTop(); err != nil {
 				if ctx.Err() != nil {
 					return eventCount, ctx.Err()
 				}
 				return eventCount, fmt.Errorf("advancing iterator: %s", err)
 			}
-			if advanced {
-				heap.Push(h, item)
-			}
 			continue
 		}
 
@@ -204,12 +260,20 @@ func (s fdbStore) readEvents(
 		if err != nil {
 			return eventCount, err
 		}
+		if superseded {
+			if storedEvent, err = s.withSupersession(tr, storedEvent); err != nil {
+				return eventCount, err
+			}
+		}
+		if storedEvent, err = s.withSequence(tr, storedEvent); err != nil {
+			return eventCount, err
+		}
 
 		if !yield(storedEvent, nil) {
 			return eventCount, nil
 		}
 
-		lastEmitted = &currentVS
+		lastEmitted, emitted = currentVS, true
 		eventCount++
 
 		// Check limit
@@ -217,156 +281,184 @@ func (s fdbStore) readEvents(
 			return eventCount, nil
 		}
 
-		// Advance this iterator and re-push if not exhausted
-		advanced, err := ri.advance()
-		if err != nil {
+		if err := h.advanceTop(); err != nil {
 			if ctx.Err() != nil {
 				return eventCount, ctx.Err()
 			}
 			return eventCount, fmt.Errorf("advancing iterator: %s", err)
 		}
-		if advanced {
-			heap.Push(h, item)
-		}
 	}
 
 	return eventCount, nil
 }
 
-func decodeEvent(ctx context.Context, encodedValue []byte) (*Event, error) {
-	// Decode event (type, tags, data)
-	eventTuple, err := tuple.Unpack(encodedValue)
-	if err != nil {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+// ReadAll returns all events in the store as an iterator sequence, ordered by versionstamp.
+// Efficiently handles millions of events by streaming directly from the events subspace.
+// With an archive tier (see WithArchive), the archived events come first.
+func (s fdbStore) ReadAll(ctx context.Context) iter.Seq2[StoredEvent, error] {
+	return func(yield func(StoredEvent, error) bool) {
+		if err := ctx.Err(); err != nil {
+			yield(StoredEvent{}, err)
+			return
 		}
-		return nil, err
-	}
-
-	if len(eventTuple) != 3 {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+		if err := s.checkFormat(); err != nil {
+			yield(StoredEvent{}, err)
+			return
 		}
-		return nil, fmt.Errorf("expected 3-tuple, got %d elements", len(eventTuple))
-	}
 
-	// Extract type
-	eventType, ok := eventTuple[0].(string)
-	if !ok {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+		slot, err := s.acquireReadSlot(ctx, "read_all")
+		if err != nil {
+			yield(StoredEvent{}, err)
+			return
 		}
-		return nil, fmt.Errorf("type field is %T, expected string", eventTuple[0])
-	}
+		defer slot.close()
+		yieldEvent := slot.yield(ctx, yield)
 
-	// Extract tags (comes as tuple.Tuple which is []interface{})
-	var tags []string
-	if eventTuple[1] != nil {
-		tagsTuple, ok := eventTuple[1].(tuple.Tuple)
-		if !ok {
-			if ctx.Err() != nil {
-				return nil, ctx.Err()
+		start := time.Now()
+		eventCount := 0
+		emit := func(event StoredEvent) bool {
+			if !yieldEvent(event, nil) {
+				return false
 			}
-			return nil, fmt.Errorf("event type %q: tags field is %T, expected tuple", eventType, eventTuple[1])
+			eventCount++
+			return true
 		}
 
-		tags = make([]string, len(tagsTuple))
-		for i, t := range tagsTuple {
-			tags[i] = t.(string)
+		if s.archive != nil {
+			err = s.readAllWithArchive(ctx, emit)
+		} else {
+			err = s.readAllLive(ctx, nil, emit)
 		}
-	}
 
-	// Extract data
-	eventData, ok := eventTuple[2].([]byte)
-	if !ok {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
+		duration := time.Since(start)
+		success := err == nil
+
+		s.metrics.RecordReadDuration(duration, success)
+		if success {
+			s.metrics.RecordReadEvents(eventCount)
+			s.logCompleted("read_all", "read all completed", duration, "event_count", eventCount)
+		} else {
+			s.logger.Error("read all failed", err, "duration", duration)
+			yield(StoredEvent{}, err)
 		}
-		return nil, fmt.Errorf("event type %q tags %v: data field is %T, expected []byte", eventType, tags, eventTuple[2])
 	}
-
-	return &Event{Type: eventType, Tags: tags, Data: eventData}, nil
 }
 
-// ReadAll returns all events in the store as an iterator sequence, ordered by versionstamp.
-// Efficiently handles millions of events by streaming directly from the events subspace.
-func (s fdbStore) ReadAll(ctx context.Context) iter.Seq2[StoredEvent, error] {
-	return func(yield func(StoredEvent, error) bool) {
-		if err := ctx.Err(); err != nil {
-			yield(StoredEvent{}, err)
-			return
+// readAllLive emits the events of the events subspace, in order.
+// With an archive tier, it fails with errArchiveMoved if events were archived after archived (the archive boundary the caller emitted).
+func (s fdbStore) readAllLive(ctx context.Context, archived *Versionstamp, emit func(StoredEvent) bool) error {
+	eventCount := 0
+	_, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		if s.archive != nil {
+			until, err := s.archive.readUntil(tr)
+			if err != nil {
+				return nil, err
+			}
+			if !samePosition(until, archived) {
+				return nil, errArchiveMoved
+			}
 		}
 
-		start := time.Now()
-		eventCount := 0
+		// Scan entire events subspace
+		rangeOpts := fdb.RangeOptions{
+			Limit: 1000, // Batch size hint for efficient streaming
+		}
+
+		// Supersessions and sequences are keyed by versionstamp too: they are merged in the same order
+		supersessions := newPositionedValues(tr, s.superseded, rangeOpts)
+		var sequences *positionedValues
+		if s.sequences != nil {
+			sequences = newPositionedValues(tr, s.sequences.byPosition, rangeOpts)
+		}
 
-		_, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
-			// Scan entire events subspace
-			rangeOpts := fdb.RangeOptions{
-				Limit: 1000, // Batch size hint for efficient streaming
+		iter := tr.GetRange(s.events, rangeOpts).Iterator()
+		for iter.Advance() {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
 			}
 
-			iter := tr.GetRange(s.events, rangeOpts).Iterator()
-			for iter.Advance() {
-				select {
-				case <-ctx.Done():
+			kv, err := iter.Get()
+			if err != nil {
+				if ctx.Err() != nil {
 					return nil, ctx.Err()
-				default:
-				}
-
-				kv, err := iter.Get()
-				if err != nil {
-					if ctx.Err() != nil {
-						return nil, ctx.Err()
-					}
-					return nil, fmt.Errorf("reading event %d from events subspace: %s", eventCount, err)
 				}
+				return nil, fmt.Errorf("reading event %d from events subspace: %s", eventCount, err)
+			}
 
-				// Extract versionstamp from key
-				keyTuple, err := s.events.Unpack(kv.Key)
-				if err != nil {
-					return nil, fmt.Errorf("unpacking event key at position %d: %s", eventCount, err)
-				}
-				if len(keyTuple) != 1 {
-					return nil, errors.New("invalid event key")
-				}
-				tupleVs, ok := keyTuple[0].(tuple.Versionstamp)
-				if !ok {
-					return nil, errors.New("invalid versionstamp in key")
-				}
+			// Extract versionstamp from key
+			vs, err := s.eventPosition(kv.Key)
+			if err != nil {
+				return nil, fmt.Errorf("event key at position %d: %s", eventCount, err)
+			}
 
-				// Convert to our Versionstamp type
-				var vs Versionstamp
-				copy(vs[:10], tupleVs.TransactionVersion[:])
-				binary.BigEndian.PutUint16(vs[10:12], tupleVs.UserVersion)
+			// Decode event
+			event, committedAt, err := decodeEvent(ctx, kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("event %d at versionstamp %x: %s", eventCount, vs[:], err)
+			}
 
-				// Decode event
-				storedEvent, err := decodeEvent(ctx, kv.Value)
+			stored := StoredEvent{Event: event, Position: vs, CommittedAt: committedAt}
+			supersession, err := supersessions.valueAt(s.supersessionKey(vs))
+			if err != nil {
+				return nil, fmt.Errorf("reading supersessions at position %d: %s", eventCount, err)
+			}
+			if supersession != nil {
+				if stored.Superseded, err = decodeSupersession(supersession); err != nil {
+					return nil, fmt.Errorf("supersession of versionstamp %x: %s", vs[:], err)
+				}
+			}
+			if sequences != nil {
+				sequence, err := sequences.valueAt(s.sequences.positionKey(vs))
 				if err != nil {
-					return nil, fmt.Errorf("event %d at versionstamp %x: %s", eventCount, vs[:], err)
+					return nil, fmt.Errorf("reading sequences at position %d: %s", eventCount, err)
 				}
-
-				if !yield(StoredEvent{Event: *storedEvent, Position: vs}, nil) {
-					return nil, nil
+				if sequence != nil {
+					if stored.Sequence, err = decodeSequence(sequence); err != nil {
+						return nil, fmt.Errorf("sequence of versionstamp %x: %s", vs[:], err)
+					}
 				}
-				eventCount++
 			}
 
-			return nil, nil
-		})
+			if !emit(stored) {
+				return nil, nil
+			}
+			eventCount++
+		}
 
-		duration := time.Since(start)
-		success := err == nil
+		return nil, nil
+	})
+	return err
+}
 
-		s.metrics.RecordReadDuration(duration, success)
-		if success {
-			s.metrics.RecordReadEvents(eventCount)
-			s.logger.Info("read all completed", "event_count", eventCount, "duration", duration)
-		} else {
-			s.logger.Error("read all failed", err, "duration", duration)
-			yield(StoredEvent{}, err)
+// positionedValues walks a subspace keyed by versionstamp alongside the events, in the same order
+type positionedValues struct {
+	iter *fdb.RangeIterator
+	next *fdb.KeyValue
+	left bool
+}
+
+func newPositionedValues(tr fdb.ReadTransaction, r fdb.Range, opts fdb.RangeOptions) *positionedValues {
+	return &positionedValues{iter: tr.GetRange(r, opts).Iterator(), left: true}
+}
+
+// valueAt returns the value at key, nil if none. Keys must be asked in increasing order.
+func (p *positionedValues) valueAt(key fdb.Key) ([]byte, error) {
+	for p.left && (p.next == nil || bytes.Compare(p.next.Key, key) < 0) {
+		if p.left = p.iter.Advance(); !p.left {
+			break
 		}
+		kv, err := p.iter.Get()
+		if err != nil {
+			return nil, err
+		}
+		p.next = &kv
 	}
+	if p.next != nil && bytes.Equal(p.next.Key, key) {
+		return p.next.Value, nil
+	}
+	return nil, nil
 }
 
 // rangeIterator wraps FDB iterator with current state for k-way merge
@@ -412,3 +504,18 @@ func (h *vsHeap) Pop() any {
 	h.items = old[0 : n-1]
 	return item
 }
+
+// advanceTop advances the iterator on top of the heap, dropping it once exhausted.
+// The heap is fixed in place rather than popped and pushed back, which would box an item per event.
+func (h *vsHeap) advanceTop() error {
+	advanced, err := h.items[0].iter.advance()
+	if err != nil {
+		return err
+	}
+	if advanced {
+		heap.Fix(h, 0)
+	} else {
+		heap.Pop(h)
+	}
+	return nil
+}
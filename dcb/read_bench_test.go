@@ -0,0 +1,75 @@
+package dcb_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/require"
+)
+
+const benchReadEvents = 100_000
+
+// seedBenchRead appends benchReadEvents events spread over 100 lists, in batches
+func seedBenchRead(b *testing.B) dcb.DcbStore {
+	store := dcb.SetupTestStore(b)
+	ctx := context.Background()
+	const batchSize = 1000
+	for batch := range benchReadEvents / batchSize {
+		events := make([]dcb.Event, batchSize)
+		for i := range events {
+			n := batch*batchSize + i
+			events[i] = dcb.Event{
+				Type: "item_added",
+				Tags: []string{fmt.Sprintf("list:%d", n%100), fmt.Sprintf("item:%d", n), "status:pending"},
+				Data: fmt.Appendf(nil, `{"name":"item %d","quantity":%d}`, n, n%10),
+			}
+		}
+		require.NoError(b, store.Append(ctx, events))
+	}
+	return store
+}
+
+// BenchmarkRead_100kEvents reads 100k events by type, then all of them, reporting the allocations per read of the 100k
+func BenchmarkRead_100kEvents(b *testing.B) {
+	store := seedBenchRead(b)
+	ctx := context.Background()
+
+	for name, read := range map[string]func() int{
+		"Read": func() int {
+			// pages of 10k events, each read in its own transaction
+			query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"item_added"}}}}
+			count := 0
+			opts := &dcb.ReadOptions{Limit: 10_000}
+			for {
+				page := 0
+				for event, err := range store.Read(ctx, query, opts) {
+					require.NoError(b, err)
+					opts.After = &event.Position
+					page++
+				}
+				count += page
+				if page < opts.Limit {
+					return count
+				}
+			}
+		},
+		"ReadAll": func() int {
+			count := 0
+			for _, err := range store.ReadAll(ctx) {
+				require.NoError(b, err)
+				count++
+			}
+			return count
+		},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				require.Equal(b, benchReadEvents, read())
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchReadEvents), "ns/event")
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package dcb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+var (
+	// ErrSequencesDisabled is returned by AssignSequences and PositionOfSequence for a store without sequences
+	ErrSequencesDisabled = errors.New("sequences not enabled")
+	// ErrSequenceNotFound is returned by PositionOfSequence for a number not assigned (yet)
+	ErrSequenceNotFound = errors.New("sequence not found")
+)
+
+// sequenceBatchSize is the number of events numbered per AssignSequences transaction
+const sequenceBatchSize = 1000
+
+// sequences number the events of the namespace 1, 2, 3... in position order.
+// Numbers are assigned lazily by AssignSequences, appends don't write them: they would all conflict on the counter.
+type sequences struct {
+	byPosition subspace.Subspace // (versionstamp) -> sequence, 8 bytes big endian
+	byNumber   subspace.Subspace // (sequence) -> versionstamp
+	head       fdb.Key           // last numbered versionstamp and its sequence, 12 + 8 bytes
+}
+
+// WithSequences gives the events of the namespace a logical sequence number, a plain increasing int64
+// for consumers that don't want 12-byte positions (Kafka offsets, SQL sinks...).
+// Numbers are assigned after the fact by AssignSequences: reads return StoredEvent.Sequence = 0
+// for the events it hasn't numbered yet. Each numbered event costs a point read on Read.
+func (StoreOptions) WithSequences() func(s *fdbStore) {
+	return func(e *fdbStore) {
+		root := subspace.Sub(e.namespace).Sub("n")
+		e.sequences = &sequences{
+			byPosition: root.Sub("p"),
+			byNumber:   root.Sub("q"),
+			head:       root.Pack(tuple.Tuple{"head"}),
+		}
+	}
+}
+
+// AssignSequences numbers the events appended since its last run, in position order, and returns how many it numbered.
+// Run it periodically (or before reading with sequences): it is idempotent, and concurrent runs
+// don't assign a number twice, they retry. Numbers increase with positions and have no gaps,
+// except for events archived (see ArchiveEvents) before being numbered.
+func AssignSequences(ctx context.Context, store DcbStore) (int, error) {
+	s, ok := store.(*fdbStore)
+	if !ok || s.sequences == nil {
+		return 0, ErrSequencesDisabled
+	}
+
+	assigned := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return assigned, err
+		}
+		res, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+			return s.sequences.assignBatch(tr, s)
+		})
+		if err != nil {
+			return assigned, fmt.Errorf("assigning sequences after %d events: %w", assigned, err)
+		}
+		n := res.(int)
+		assigned += n
+		if n < sequenceBatchSize {
+			if assigned > 0 {
+				s.logger.Info("sequences assigned", "event_count", assigned)
+			}
+			return assigned, nil
+		}
+	}
+}
+
+// assignBatch numbers the next events after the head.
+// The head is read with a conflict, so concurrent runs serialize, and the events with a snapshot read:
+// events appended concurrently commit at later positions, the next batch numbers them.
+func (q *sequences) assignBatch(tr fdb.Transaction, s *fdbStore) (int, error) {
+	head, err := tr.Get(q.head).Get()
+	if err != nil {
+		return 0, err
+	}
+	var last int64
+	r := fdb.Range(s.events)
+	if head != nil {
+		if len(head) != 20 {
+			return 0, fmt.Errorf("invalid sequence head of %d bytes", len(head))
+		}
+		if r, err = rangeAfterVersionstamp(s.events, Versionstamp(head[:12])); err != nil {
+			return 0, err
+		}
+		last = int64(binary.BigEndian.Uint64(head[12:]))
+	}
+
+	kvs, err := tr.Snapshot().GetRange(r, fdb.RangeOptions{Limit: sequenceBatchSize}).GetSliceWithError()
+	if err != nil || len(kvs) == 0 {
+		return 0, err
+	}
+	var vs Versionstamp
+	for _, kv := range kvs {
+		if vs, err = s.eventPosition(kv.Key); err != nil {
+			return 0, err
+		}
+		last++
+		tr.Set(q.positionKey(vs), binary.BigEndian.AppendUint64(nil, uint64(last)))
+		tr.Set(q.byNumber.Pack(tuple.Tuple{last}), vs[:])
+	}
+	tr.Set(q.head, binary.BigEndian.AppendUint64(vs[:], uint64(last)))
+	return len(kvs), nil
+}
+
+func (q *sequences) positionKey(vs Versionstamp) fdb.Key {
+	return q.byPosition.Pack(tuple.Tuple{vs.tupleVersionstamp()})
+}
+
+// PositionOfSequence returns the position of the event numbered sequence, e.g. to resume reading
+// after an integer checkpoint. ErrSequenceNotFound when the number isn't assigned, or was archived.
+func PositionOfSequence(ctx context.Context, store DcbStore, sequence int64) (Versionstamp, error) {
+	s, ok := store.(*fdbStore)
+	if !ok || s.sequences == nil {
+		return Versionstamp{}, ErrSequencesDisabled
+	}
+	if err := ctx.Err(); err != nil {
+		return Versionstamp{}, err
+	}
+
+	value, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(s.sequences.byNumber.Pack(tuple.Tuple{sequence})).Get()
+	})
+	if err != nil {
+		return Versionstamp{}, err
+	}
+	position := value.([]byte)
+	if position == nil {
+		return Versionstamp{}, fmt.Errorf("%w: %d", ErrSequenceNotFound, sequence)
+	}
+	if len(position) != len(Versionstamp{}) {
+		return Versionstamp{}, fmt.Errorf("sequence %d: invalid position of %d bytes", sequence, len(position))
+	}
+	return Versionstamp(position), nil
+}
+
+// withSequence sets the sequence of event, read in tr (unchanged without sequences).
+// The event is copied: cached events are shared and sequences are never cached.
+func (s fdbStore) withSequence(tr fdb.ReadTransaction, event StoredEvent) (StoredEvent, error) {
+	if s.sequences == nil {
+		return event, nil
+	}
+	value, err := tr.Get(s.sequences.positionKey(event.Position)).Get()
+	if err != nil || value == nil {
+		return event, err
+	}
+	if event.Sequence, err = decodeSequence(value); err != nil {
+		return StoredEvent{}, fmt.Errorf("sequence of versionstamp %x: %w", event.Position[:], err)
+	}
+	return event, nil
+}
+
+// clearSequence removes the numbering of an event deleted from the namespace
+func (q *sequences) clearSequence(tr fdb.Transaction, event StoredEvent) {
+	tr.Clear(q.positionKey(event.Position))
+	if event.Sequence != 0 {
+		tr.Clear(q.byNumber.Pack(tuple.Tuple{event.Sequence}))
+	}
+}
+
+func decodeSequence(value []byte) (int64, error) {
+	if len(value) != 8 {
+		return 0, fmt.Errorf("expected 8 bytes, got %d", len(value))
+	}
+	return int64(binary.BigEndian.Uint64(value)), nil
+}
@@ -0,0 +1,165 @@
+package dcb_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counted(n int) []dcb.Event {
+	events := make([]dcb.Event, n)
+	for i := range events {
+		events[i] = dcb.Event{Type: "Counted", Tags: []string{"counter:1"}}
+	}
+	return events
+}
+
+func sequencesOf(events []dcb.StoredEvent) []int64 {
+	sequences := make([]int64, len(events))
+	for i, e := range events {
+		sequences[i] = e.Sequence
+	}
+	return sequences
+}
+
+var countedQuery = dcb.Query{Items: []dcb.QueryItem{{Types: []string{"Counted"}}}}
+
+func TestSequences_NumberEventsInPositionOrder(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithSequences()(store)
+	require.NoError(tt, store.Append(ctx, counted(2)))
+	require.NoError(tt, store.Append(ctx, counted(1)))
+
+	// When
+	assigned, err := dcb.AssignSequences(ctx, store)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, 3, assigned)
+	assert.Equal(tt, []int64{1, 2, 3}, sequencesOf(dcb.CollectEvents(tt, store.Read(ctx, countedQuery, nil))))
+
+	// When - events appended since are numbered on the next run only
+	require.NoError(tt, store.Append(ctx, counted(2)))
+	before := sequencesOf(dcb.CollectEvents(tt, store.Read(ctx, countedQuery, nil)))
+	assigned, err = dcb.AssignSequences(ctx, store)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, []int64{1, 2, 3, 0, 0}, before)
+	assert.Equal(tt, 2, assigned)
+	assert.Equal(tt, []int64{1, 2, 3, 4, 5}, sequencesOf(dcb.CollectEvents(tt, store.ReadAll(ctx))))
+	assert.Equal(tt, []int64{5, 4, 3, 2, 1}, sequencesOf(dcb.CollectEvents(tt, store.Read(ctx, countedQuery, &dcb.ReadOptions{Reverse: true}))))
+}
+
+func TestSequences_ConcurrentRunsAssignEachNumberOnce(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithSequences()(store)
+	require.NoError(tt, store.Append(ctx, counted(50)))
+
+	// When
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	total := 0
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assigned, err := dcb.AssignSequences(ctx, store)
+			assert.NoError(tt, err)
+			mu.Lock()
+			total += assigned
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// Then
+	assert.Equal(tt, 50, total)
+	sequences := sequencesOf(dcb.CollectEvents(tt, store.ReadAll(ctx)))
+	for i, sequence := range sequences {
+		assert.Equal(tt, int64(i+1), sequence)
+	}
+}
+
+func TestPositionOfSequence(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithSequences()(store)
+	require.NoError(tt, store.Append(ctx, counted(3)))
+	_, err := dcb.AssignSequences(ctx, store)
+	require.NoError(tt, err)
+	events := dcb.CollectEvents(tt, store.ReadAll(ctx))
+
+	// When
+	position, err := dcb.PositionOfSequence(ctx, store, 2)
+
+	// Then - reading after it resumes at the third event
+	require.NoError(tt, err)
+	assert.Equal(tt, events[1].Position, position)
+	after := dcb.CollectEvents(tt, store.Read(ctx, countedQuery, &dcb.ReadOptions{After: &position}))
+	assert.Equal(tt, []int64{3}, sequencesOf(after))
+
+	// When
+	_, err = dcb.PositionOfSequence(ctx, store, 4)
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrSequenceNotFound)
+}
+
+func TestSequences_Disabled(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, counted(1)))
+
+	// When
+	_, assignErr := dcb.AssignSequences(ctx, store)
+	_, positionErr := dcb.PositionOfSequence(ctx, store, 1)
+
+	// Then
+	assert.ErrorIs(tt, assignErr, dcb.ErrSequencesDisabled)
+	assert.ErrorIs(tt, positionErr, dcb.ErrSequencesDisabled)
+	assert.Equal(tt, []int64{0}, sequencesOf(dcb.CollectEvents(tt, store.Read(ctx, countedQuery, nil))))
+}
+
+func TestSequences_ArchivedEventsKeepTheirSequence(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - events not numbered yet
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithSequences()(store)
+	dcb.StoreOptions{}.WithArchive(newMemoryObjects())(store)
+	require.NoError(tt, store.Append(ctx, counted(2)))
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(tt, store.Append(ctx, counted(1)))
+
+	// When
+	report, err := dcb.ArchiveEvents(ctx, store, cutoff)
+
+	// Then - numbered before being archived
+	require.NoError(tt, err)
+	assert.Equal(tt, 2, report.Events)
+	assert.Equal(tt, []int64{1, 2, 3}, sequencesOf(dcb.CollectEvents(tt, store.ReadAll(ctx))))
+	_, err = dcb.PositionOfSequence(ctx, store, 1)
+	assert.ErrorIs(tt, err, dcb.ErrSequenceNotFound)
+}
@@ -0,0 +1,109 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+// ErrEventNotFound is returned by Supersede when no event is stored at the position
+var ErrEventNotFound = errors.New("event not found")
+
+// Superseder is implemented by stores able to mark stored events as superseded
+type Superseder interface {
+	// Supersede marks the event at position as superseded, e.g. corrected by a later event or deleted.
+	// The event is still stored and returned by reads, with its Superseded field set:
+	// projections decide how to honor it (skip it, undo it, show it struck through...).
+	// Superseding an event twice keeps the first supersession.
+	Supersede(ctx context.Context, position Versionstamp, reason string) error
+}
+
+// Supersession records why and when an event was superseded
+type Supersession struct {
+	Reason string
+	At     time.Time // the store's clock when the event was superseded
+}
+
+// Supersede marks the event at position as superseded.
+// Supersessions are stored apart from the events (which never change once committed):
+// reads check them only while at least one exists in the namespace.
+func (s fdbStore) Supersede(ctx context.Context, position Versionstamp, reason string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.checkFormat(); err != nil {
+		return err
+	}
+
+	_, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+		event := tr.Get(s.events.Pack(tuple.Tuple{position.tupleVersionstamp()}))
+		marker := tr.Get(s.supersessionKey(position))
+		if event.MustGet() == nil {
+			return nil, fmt.Errorf("%w at versionstamp %x", ErrEventNotFound, position[:])
+		}
+		if marker.MustGet() != nil {
+			return nil, nil
+		}
+		supersession := tuple.Tuple{reason, time.Now().UnixNano()}.Pack()
+		tr.Set(s.supersessionKey(position), supersession)
+		if s.mirror != nil {
+			tr.Set(s.mirror.supersessionKey(position), supersession)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		s.logger.Error("supersede failed", err, "position", position.String())
+		return err
+	}
+	s.logger.Info("event superseded", "position", position.String(), "reason", reason)
+	return nil
+}
+
+// supersessionKey is the key of the supersession of the event at vs
+func (s fdbStore) supersessionKey(vs Versionstamp) fdb.Key {
+	return s.superseded.Pack(tuple.Tuple{vs.tupleVersionstamp()})
+}
+
+// anySuperseded reports whether an event of the namespace was superseded,
+// so reads of namespaces without supersessions skip the per-event lookups
+func (s fdbStore) anySuperseded(tr fdb.ReadTransaction) (bool, error) {
+	kvs, err := tr.GetRange(s.superseded, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+	return len(kvs) > 0, err
+}
+
+// withSupersession sets the supersession of event, read in tr.
+// The event is copied: cached events are shared and supersessions are never cached.
+func (s fdbStore) withSupersession(tr fdb.ReadTransaction, event StoredEvent) (StoredEvent, error) {
+	value, err := tr.Get(s.supersessionKey(event.Position)).Get()
+	if err != nil || value == nil {
+		return event, err
+	}
+	event.Superseded, err = decodeSupersession(value)
+	if err != nil {
+		return StoredEvent{}, fmt.Errorf("supersession of versionstamp %x: %w", event.Position[:], err)
+	}
+	return event, nil
+}
+
+func decodeSupersession(value []byte) (*Supersession, error) {
+	t, err := tuple.Unpack(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(t) != 2 {
+		return nil, fmt.Errorf("expected 2-tuple, got %d elements", len(t))
+	}
+	reason, ok := t[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("reason field is %T, expected string", t[0])
+	}
+	ns, ok := t[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("time field is %T, expected int64", t[1])
+	}
+	return &Supersession{Reason: reason, At: time.Unix(0, ns)}, nil
+}
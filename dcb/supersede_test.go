@@ -0,0 +1,101 @@
+package dcb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupersede_FlagsEventOnEveryRead(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "price_set", Tags: []string{"product:1"}, Data: []byte("10")},
+		{Type: "price_set", Tags: []string{"product:1"}, Data: []byte("100")},
+	}))
+	query := dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"product:1"}}}}
+	before := dcb.CollectEvents(tt, store.Read(ctx, query, nil))
+	require.Len(tt, before, 2)
+
+	// When - the typo is superseded
+	err := store.Supersede(ctx, before[1].Position, "typo")
+
+	// Then
+	require.NoError(tt, err)
+	read := dcb.CollectEvents(tt, store.Read(ctx, query, nil))
+	all := dcb.CollectEvents(tt, store.ReadAll(ctx))
+	fetched, err := store.FetchEvent(ctx, before[1].Position)
+	require.NoError(tt, err)
+	for _, events := range [][]dcb.StoredEvent{read, all} {
+		require.Len(tt, events, 2)
+		assert.Nil(tt, events[0].Superseded)
+		require.NotNil(tt, events[1].Superseded)
+		assert.Equal(tt, "typo", events[1].Superseded.Reason)
+		assert.Equal(tt, before[1].Event, events[1].Event)
+	}
+	require.NotNil(tt, fetched.Superseded)
+	assert.Equal(tt, "typo", fetched.Superseded.Reason)
+	assert.False(tt, fetched.Superseded.At.IsZero())
+}
+
+func TestSupersede_KeepsFirstSupersession(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}}}))
+	event := dcb.CollectEvents(tt, store.ReadAll(ctx))[0]
+	require.NoError(tt, store.Supersede(ctx, event.Position, "typo"))
+
+	// When
+	err := store.Supersede(ctx, event.Position, "deleted")
+
+	// Then
+	require.NoError(tt, err)
+	fetched, err := store.FetchEvent(ctx, event.Position)
+	require.NoError(tt, err)
+	assert.Equal(tt, "typo", fetched.Superseded.Reason)
+}
+
+func TestSupersede_UnknownPosition(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	err := store.Supersede(context.Background(), dcb.Versionstamp{1}, "typo")
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrEventNotFound)
+}
+
+func TestSupersede_NotHiddenByEventCache(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - the event is cached before being superseded
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	dcb.StoreOptions{}.WithEventCache(10)(store)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "price_set", Tags: []string{"product:1"}}}))
+	query := dcb.Query{Items: []dcb.QueryItem{{Types: []string{"price_set"}}}}
+	cached := dcb.CollectEvents(tt, store.Read(ctx, query, nil))
+	require.Len(tt, cached, 1)
+
+	// When
+	require.NoError(tt, store.Supersede(ctx, cached[0].Position, "deleted"))
+
+	// Then
+	read := dcb.CollectEvents(tt, store.Read(ctx, query, nil))
+	require.Len(tt, read, 1)
+	require.NotNil(tt, read[0].Superseded)
+	assert.Equal(tt, "deleted", read[0].Superseded.Reason)
+	assert.Nil(tt, cached[0].Superseded)
+}
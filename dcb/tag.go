@@ -0,0 +1,167 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidTag is returned when a tag is not in canonical encoding
+var ErrInvalidTag = errors.New("invalid tag")
+
+// TagSeparator separates the key from the value in a structured tag
+const TagSeparator = ":"
+
+// Tag is a structured key:value tag. Its value may be empty ("key:").
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// NewTag creates a structured tag
+func NewTag(key, value string) Tag {
+	return Tag{Key: key, Value: value}
+}
+
+// String returns the canonical encoding of the tag, "key:value" ("key:" for an empty value)
+func (t Tag) String() string {
+	return t.Key + TagSeparator + t.Value
+}
+
+// Validate checks that the tag encodes canonically
+func (t Tag) Validate() error {
+	return validateTagKey(t.Key)
+}
+
+// validateTagKey checks that key can start a tag
+func validateTagKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty key", ErrInvalidTag)
+	}
+	if strings.ContainsFunc(key, unicode.IsSpace) {
+		return fmt.Errorf("%w: key %q contains whitespace", ErrInvalidTag, key)
+	}
+	if strings.Contains(key, TagSeparator) {
+		return fmt.Errorf("%w: key %q contains separator %q", ErrInvalidTag, key, TagSeparator)
+	}
+	return nil
+}
+
+// ParseTag decodes a structured tag, splitting key and value on the first separator.
+// Returns ErrInvalidTag if the string is not in canonical encoding, or is a bare tag (without separator).
+func ParseTag(s string) (Tag, error) {
+	key, value, structured := strings.Cut(s, TagSeparator)
+	if !structured {
+		return Tag{}, fmt.Errorf("%w: %q is a bare tag, without separator %q", ErrInvalidTag, s, TagSeparator)
+	}
+	t := Tag{Key: key, Value: value}
+	if err := t.Validate(); err != nil {
+		return Tag{}, fmt.Errorf("%w (tag %q)", err, s)
+	}
+	return t, nil
+}
+
+// ValidateTag checks that s is a canonical tag: a structured tag (see ParseTag) or a bare key
+func ValidateTag(s string) error {
+	key, _, _ := strings.Cut(s, TagSeparator)
+	if err := validateTagKey(key); err != nil {
+		return fmt.Errorf("%w (tag %q)", err, s)
+	}
+	return nil
+}
+
+// TagKey is a reusable tag key, e.g. TagKey("cart").Equals(id).
+// In queries, QueryItem.TagKeys match the events with a tag of the key, whatever its value.
+type TagKey string
+
+// Equals returns the encoded tag matching this key with the given value ("key:" for an empty value)
+func (k TagKey) Equals(value string) string {
+	return Tag{Key: string(k), Value: value}.String()
+}
+
+// Tag returns the structured tag for this key with the given value
+func (k TagKey) Tag(value string) Tag {
+	return Tag{Key: string(k), Value: value}
+}
+
+// TagEquals returns the encoded tag for key and value, ready to use in events and queries
+func TagEquals(key, value string) string {
+	return TagKey(key).Equals(value)
+}
+
+// CanonicalTags returns tags sorted and without duplicates, the form events are stored in
+// (and the order of the tag tree paths)
+func CanonicalTags(tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	canonical := sortTags(tags)
+	return slices.Compact(canonical)
+}
+
+// isCanonical reports whether tags are sorted and without duplicates
+func isCanonical(tags []string) bool {
+	for i := 1; i < len(tags); i++ {
+		if tags[i-1] >= tags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalTags returns the event's tags in canonical form.
+// Events appended since tags are normalized already hold them, older events may not.
+func (e Event) CanonicalTags() []string {
+	return CanonicalTags(e.Tags)
+}
+
+// canonicalizeTags returns events with their tags in canonical form, copying the slice only if needed
+func canonicalizeTags(events []Event) []Event {
+	copied := false
+	for i, event := range events {
+		if isCanonical(event.Tags) {
+			continue
+		}
+		if !copied {
+			events = slices.Clone(events)
+			copied = true
+		}
+		events[i].Tags = CanonicalTags(event.Tags)
+	}
+	return events
+}
+
+// TagNormalizationReport lists the stored events whose tags are not in canonical form,
+// appended before tags were normalized at append
+type TagNormalizationReport struct {
+	Events       int            // events checked
+	NonCanonical int            // events with unsorted or duplicate tags
+	Positions    []Versionstamp // positions of the first non-canonical events, up to MaxReportedPositions
+}
+
+// MaxReportedPositions caps the positions listed by a TagNormalizationReport
+const MaxReportedPositions = 100
+
+// CheckTagNormalization scans every stored event for tags not in canonical form.
+// Reads don't depend on the stored order (the indexes always use sorted tags), but consumers
+// comparing StoredEvent.Tags should use CanonicalTags for the reported events.
+func CheckTagNormalization(ctx context.Context, store DcbStore) (TagNormalizationReport, error) {
+	var report TagNormalizationReport
+	for event, err := range store.ReadAll(ctx) {
+		if err != nil {
+			return report, err
+		}
+		report.Events++
+		if isCanonical(event.Tags) {
+			continue
+		}
+		report.NonCanonical++
+		if len(report.Positions) < MaxReportedPositions {
+			report.Positions = append(report.Positions, event.Position)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,217 @@
+package dcb_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestTagRoundTrip(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		key := rapid.StringMatching(`[a-z_]{1,10}`).Draw(t, "key")
+		value := rapid.String().Draw(t, "value")
+
+		// When
+		parsed, err := dcb.ParseTag(dcb.TagEquals(key, value))
+
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, dcb.NewTag(key, value), parsed)
+	})
+}
+
+func TestTagKey(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	cart := dcb.TagKey("cart")
+
+	// When/Then
+	assert.Equal(tt, "cart:42", cart.Equals("42"))
+	assert.Equal(tt, dcb.Tag{Key: "cart", Value: "42"}, cart.Tag("42"))
+	assert.Equal(tt, "cart:", cart.Equals(""))
+	assert.Equal(tt, "cart:", dcb.NewTag("cart", "").String())
+	assert.Equal(tt, "mail:a:b", dcb.TagEquals("mail", "a:b"))
+}
+
+func TestParseTag_RejectsNonCanonical(tt *testing.T) {
+	tt.Parallel()
+
+	for _, s := range []string{"", ":value", "bare", "my key:value"} {
+		_, err := dcb.ParseTag(s)
+		assert.ErrorIs(tt, err, dcb.ErrInvalidTag, "tag %q", s)
+	}
+}
+
+func TestParseTag_KeepsEmptyValues(tt *testing.T) {
+	tt.Parallel()
+
+	// When
+	parsed, err := dcb.ParseTag("cart:")
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.NewTag("cart", ""), parsed)
+	assert.NoError(tt, dcb.ValidateTag("bare"))
+}
+
+func TestAppendRejectsNonCanonicalTag(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(tt)
+
+	// When
+	err := store.Append(context.Background(), []dcb.Event{{Type: "a", Tags: []string{":42"}}})
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrInvalidTag)
+	assert.Empty(tt, dcb.CollectEvents(tt, store.ReadAll(context.Background())))
+}
+
+func TestRead_TagKeysMatchAnyValue(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	status := dcb.TagKey("status")
+	require.NoError(tt, store.Append(ctx, []dcb.Event{
+		{Type: "opened", Tags: []string{status.Equals("open")}},
+		{Type: "closed", Tags: []string{status.Equals("closed"), "cart:1"}},
+		{Type: "reset", Tags: []string{status.Equals("")}},
+		{Type: "moved", Tags: []string{status.Equals("closed"), status.Equals("open")}},
+		{Type: "other", Tags: []string{"statuses:1", "status"}},
+	}))
+
+	// When
+	byKey := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{TagKeys: []string{"status"}}}}, nil))
+	byKeyAndTag := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"cart:1"}, TagKeys: []string{"status"}}}}, nil))
+	plan, err := store.ExplainQuery(ctx, dcb.Query{Items: []dcb.QueryItem{{Types: []string{"opened"}, TagKeys: []string{"status"}}}})
+
+	// Then - every value once, the bare tag and other keys left out
+	require.NoError(tt, err)
+	assert.Equal(tt, []string{"opened", "closed", "reset", "moved"}, eventTypes(byKey))
+	assert.True(tt, dcb.EventsAreStriclyOrdered(byKey))
+	assert.Equal(tt, []string{"closed"}, eventTypes(byKeyAndTag))
+	require.Len(tt, plan.Items, 1)
+	assert.Len(tt, plan.Items[0].Ranges, 3) // status:, status:closed, status:open
+}
+
+func TestAppendCondition_TagKeysFailOnAnyValue(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	condition := dcb.AppendCondition{Query: dcb.Query{Items: []dcb.QueryItem{{Types: []string{"claimed"}, TagKeys: []string{"seat"}}}}}
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "claimed", Tags: []string{"row:1"}}}, condition))
+
+	// When
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "claimed", Tags: []string{"seat:"}}}))
+	err := store.Append(ctx, []dcb.Event{{Type: "claimed", Tags: []string{"seat:2"}}}, condition)
+
+	// Then
+	assert.ErrorIs(tt, err, dcb.ErrAppendConditionFailed)
+}
+
+func eventTypes(events []dcb.StoredEvent) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestCanonicalTags(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		tags := rapid.SliceOf(rapid.SampledFrom([]string{"a", "b:1", "b:2", "c"})).Draw(t, "tags")
+
+		// When
+		canonical := dcb.CanonicalTags(tags)
+
+		// Then - sorted, deduplicated, same set of tags
+		assert.True(t, slices.IsSorted(canonical))
+		assert.Equal(t, canonical, slices.Compact(slices.Clone(canonical)))
+		assert.ElementsMatch(t, canonical, uniqueTags(tags))
+		assert.Equal(t, canonical, dcb.CanonicalTags(canonical))
+	})
+}
+
+func uniqueTags(tags []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+func TestAppend_StoresCanonicalTags(tt *testing.T) {
+	tt.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	events := []dcb.Event{{Type: "item_added", Tags: []string{"list:2", "cart:1", "list:2"}}}
+
+	// When
+	require.NoError(tt, store.Append(ctx, events))
+
+	// Then - stored canonical, the caller's slice untouched
+	stored := dcb.CollectEvents(tt, store.Read(ctx, dcb.Query{Items: []dcb.QueryItem{
+		{Types: []string{"item_added"}, Tags: []string{"list:2", "cart:1"}},
+	}}, nil))
+	require.Len(tt, stored, 1)
+	assert.Equal(tt, []string{"cart:1", "list:2"}, stored[0].Tags)
+	assert.Equal(tt, []string{"list:2", "cart:1", "list:2"}, events[0].Tags)
+
+	report, err := dcb.CheckTagNormalization(ctx, store)
+	require.NoError(tt, err)
+	assert.Equal(tt, dcb.TagNormalizationReport{Events: 1}, report)
+}
+
+func TestCheckTagNormalization_ReportsLegacyEvents(tt *testing.T) {
+	tt.Parallel()
+
+	// Given - an event stored with unsorted tags, before tags were normalized at append
+	ctx := context.Background()
+	store := dcb.SetupTestStore(tt)
+	require.NoError(tt, store.Append(ctx, []dcb.Event{{Type: "item_added", Tags: []string{"a"}}}))
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		key, err := subspace.Sub(store.Namespace()).Sub("e").PackWithVersionstamp(tuple.Tuple{tuple.IncompleteVersionstamp(0)})
+		if err != nil {
+			return nil, err
+		}
+		tr.SetVersionstampedKey(key, tuple.Tuple{"legacy", tuple.Tuple{"list:2", "cart:1"}, []byte("{}")}.Pack())
+		return nil, nil
+	})
+	require.NoError(tt, err)
+
+	// When
+	report, err := dcb.CheckTagNormalization(ctx, store)
+
+	// Then
+	require.NoError(tt, err)
+	assert.Equal(tt, 2, report.Events)
+	assert.Equal(tt, 1, report.NonCanonical)
+	require.Len(tt, report.Positions, 1)
+	legacy := dcb.CollectEvents(tt, store.ReadAll(ctx))[1]
+	assert.Equal(tt, legacy.Position, report.Positions[0])
+	assert.Equal(tt, []string{"cart:1", "list:2"}, legacy.CanonicalTags())
+}
@@ -12,7 +12,7 @@ import (
 	"pgregory.net/rapid"
 )
 
-func SetupTestStore(t *testing.T) *fdbStore {
+func SetupTestStore(t testing.TB) *fdbStore {
 	t.Helper()
 
 	fdb.MustAPIVersion(740)
@@ -61,7 +61,8 @@ func randomEventGen() *rapid.Generator[Event] {
 	return rapid.Custom(func(t *rapid.T) Event {
 		return Event{
 			Type: RandomEventType(t),
-			Tags: rapid.SliceOfNDistinct(randomEventTagGen(), 1, 3, func(i string) string { return i }).Draw(t, "tags"),
+			// in canonical form, as stored
+			Tags: CanonicalTags(rapid.SliceOfNDistinct(randomEventTagGen(), 1, 3, func(i string) string { return i }).Draw(t, "tags")),
 			Data: nillifyEmptySlice(rapid.SliceOfN(rapid.Byte(), 0, 1000).Draw(t, "data")),
 		}
 	})
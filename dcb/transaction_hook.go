@@ -0,0 +1,38 @@
+package dcb
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// ErrTransactionHooksUnsupported is returned by stores without FDB transactions (see OpenEmbeddedStore)
+// for appends made with a context carrying transaction hooks
+var ErrTransactionHooksUnsupported = errors.New("transaction hooks require an FDB store")
+
+// TransactionHook adds writes to the transactions appending events with a context returned by WithTransactionHook,
+// so state kept beside the events (e.g. an idempotency key) commits with them, or not at all.
+type TransactionHook struct {
+	// Write runs in each attempt of the append transaction, after the append conditions are checked.
+	// An error aborts the append and is returned by Append.
+	Write func(tr fdb.Transaction) error
+	// Committed runs once the transaction committed (optional)
+	Committed func()
+}
+
+type transactionHooksKey struct{}
+
+// WithTransactionHook returns a context adding hook to the transactions of the appends made with it,
+// after the hooks already carried by ctx. Split appends (see StoreOptions.WithAutoSplit) run it in each transaction.
+func WithTransactionHook(ctx context.Context, hook TransactionHook) context.Context {
+	hooks := transactionHooksFrom(ctx)
+	return context.WithValue(ctx, transactionHooksKey{}, append(slices.Clip(hooks), hook))
+}
+
+// transactionHooksFrom returns the hooks attached to the context, nil if none
+func transactionHooksFrom(ctx context.Context) []TransactionHook {
+	hooks, _ := ctx.Value(transactionHooksKey{}).([]TransactionHook)
+	return hooks
+}
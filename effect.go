@@ -0,0 +1,138 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrEffectInProgress is returned when another attempt recorded the intent to run the effect
+	// and has not completed it yet (concurrent worker, or a crash between intent and completion)
+	ErrEffectInProgress = errors.New("effect already in progress")
+	// ErrNoTriggerPosition is returned by OnceForTrigger outside of an automation
+	ErrNoTriggerPosition = errors.New("no trigger position in context")
+)
+
+// EffectKey identifies a side effect: the event that triggered it and the effect name
+type EffectKey struct {
+	Position dcb.Versionstamp
+	Name     string
+}
+
+// EffectLog records side effects in FDB so that retries and lease steals don't execute them twice.
+// Before running an effect, its intent is recorded; once it succeeds, it is marked done.
+type EffectLog struct {
+	log claimLog // dcb's namespace/effects, intents never expire by default
+}
+
+// EffectLogOption configures an EffectLog
+type EffectLogOption func(*EffectLog)
+
+// WithEffectIntentTTL lets an effect whose intent is older than d be executed again.
+// Use it for effects where a rare duplicate beats never running (the attempt that recorded
+// the intent probably crashed). By default, a pending intent blocks re-execution for good.
+// The attempt whose intent expired can't mark the effect done or clear it anymore (see ErrClaimLost).
+func WithEffectIntentTTL(d time.Duration) EffectLogOption {
+	return func(l *EffectLog) {
+		if d > 0 {
+			l.log.ttl = d
+		}
+	}
+}
+
+// WithEffectClock replaces the clock judging intent expiries and dating records (see VirtualClock)
+func WithEffectClock(c Clock) EffectLogOption {
+	return func(l *EffectLog) {
+		if c != nil {
+			l.log.now = c.Now
+		}
+	}
+}
+
+// NewEffectLog creates an effect log stored alongside the store's events
+func NewEffectLog(store dcb.DcbStore, opts ...EffectLogOption) *EffectLog {
+	l := &EffectLog{log: claimLog{
+		db:   store.Database(),
+		dir:  subspace.Sub(store.Namespace() + "/effects"),
+		held: ErrEffectInProgress,
+		now:  time.Now,
+	}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Once runs effect unless it already succeeded for key.
+// Returns ErrEffectInProgress if another attempt recorded its intent without completing it.
+// If effect fails, its intent is cleared so a later retry runs it again.
+func (l *EffectLog) Once(ctx context.Context, key EffectKey, effect func(ctx context.Context) error) error {
+	effectKey := l.key(key)
+	token := uuid.New()
+
+	run, err := l.log.claim(effectKey, token)
+	if err != nil {
+		return fmt.Errorf("recording intent of effect %q: %w", key.Name, err)
+	}
+	if !run {
+		return nil // already done
+	}
+
+	if err := effect(ctx); err != nil {
+		if clearErr := l.log.release(effectKey, token); clearErr != nil {
+			return errors.Join(err, fmt.Errorf("clearing intent of effect %q: %w", key.Name, clearErr))
+		}
+		return err
+	}
+
+	if err := l.log.done(effectKey, token); err != nil {
+		return fmt.Errorf("marking effect %q done: %w", key.Name, err)
+	}
+	return nil
+}
+
+// OnceForTrigger runs Once keyed by the position of the event that triggered the current automation job
+func (l *EffectLog) OnceForTrigger(ctx context.Context, name string, effect func(ctx context.Context) error) error {
+	pos, ok := TriggerPosition(ctx)
+	if !ok {
+		return ErrNoTriggerPosition
+	}
+	return l.Once(ctx, EffectKey{Position: pos, Name: name}, effect)
+}
+
+// Done reports whether the effect already succeeded
+func (l *EffectLog) Done(key EffectKey) (bool, error) {
+	return l.log.isDone(l.key(key))
+}
+
+// Purge removes the records of the effects done before the given time, and returns how many it removed.
+// Records are kept until purged: purge them once their triggers can't be processed again (see WithProcessedRetention).
+func (l *EffectLog) Purge(ctx context.Context, before time.Time) (int, error) {
+	return l.log.purge(ctx, before)
+}
+
+// key packs effects/<position>/<name>
+func (l *EffectLog) key(key EffectKey) fdb.Key {
+	return l.log.dir.Pack(tuple.Tuple{tupleVersionstamp(key.Position), key.Name})
+}
+
+type triggerPositionKey struct{}
+
+// withTriggerPosition returns a context carrying the position of the event that triggered a job
+func withTriggerPosition(ctx context.Context, pos dcb.Versionstamp) context.Context {
+	return context.WithValue(ctx, triggerPositionKey{}, pos)
+}
+
+// TriggerPosition returns the position of the event that triggered the current automation job
+func TriggerPosition(ctx context.Context) (dcb.Versionstamp, bool) {
+	pos, ok := ctx.Value(triggerPositionKey{}).(dcb.Versionstamp)
+	return pos, ok
+}
@@ -0,0 +1,216 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectLog_RunsEffectOnce(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "send-email"}
+	calls := 0
+	effect := func(context.Context) error { calls++; return nil }
+
+	// When - the same effect is run on two retries
+	require.NoError(t, log.Once(t.Context(), key, effect))
+	require.NoError(t, log.Once(t.Context(), key, effect))
+
+	// Then
+	assert.Equal(t, 1, calls)
+	done, err := log.Done(key)
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestEffectLog_FailedEffectCanBeRetried(t *testing.T) {
+	t.Parallel()
+
+	// Given - an effect failing the first time
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "charge"}
+	errDown := errors.New("payment provider down")
+	calls := 0
+	effect := func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return errDown
+		}
+		return nil
+	}
+
+	// When
+	firstErr := log.Once(t.Context(), key, effect)
+	secondErr := log.Once(t.Context(), key, effect)
+
+	// Then
+	assert.ErrorIs(t, firstErr, errDown)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, 2, calls)
+}
+
+func TestEffectLog_ConcurrentAttemptIsRejected(t *testing.T) {
+	t.Parallel()
+
+	// Given - an attempt stuck inside the effect (as when its lease is stolen)
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "send-email"}
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = log.Once(t.Context(), key, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// When
+	err := log.Once(t.Context(), key, func(context.Context) error {
+		t.Error("effect must not run twice")
+		return nil
+	})
+	close(release)
+	wg.Wait()
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrEffectInProgress)
+}
+
+func TestEffectLog_ExpiredIntentRunsAgain(t *testing.T) {
+	t.Parallel()
+
+	// Given - an intent left behind by a crashed attempt
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t), fairway.WithEffectIntentTTL(10*time.Millisecond))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "send-email"}
+	started := make(chan struct{})
+	go func() {
+		_ = log.Once(t.Context(), key, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done() // does not complete before the test ends
+			return ctx.Err()
+		})
+	}()
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	// When
+	calls := 0
+	err := log.Once(t.Context(), key, func(context.Context) error { calls++; return nil })
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestEffectLog_OnceForTriggerRequiresAutomation(t *testing.T) {
+	t.Parallel()
+
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t))
+
+	err := log.OnceForTrigger(t.Context(), "send-email", func(context.Context) error { return nil })
+
+	assert.ErrorIs(t, err, fairway.ErrNoTriggerPosition)
+}
+
+func TestEffectLog_AttemptWhoseIntentExpiredCannotClearTheNextOne(t *testing.T) {
+	t.Parallel()
+
+	// Given - a first attempt stalling past the intent TTL
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t), fairway.WithEffectIntentTTL(50*time.Millisecond))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "charge"}
+	started, release := make(chan struct{}), make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- log.Once(t.Context(), key, func(context.Context) error {
+			close(started)
+			<-release
+			return errors.New("timed out")
+		})
+	}()
+	<-started
+	time.Sleep(100 * time.Millisecond)
+
+	// When - a second attempt takes over and is still running when the first one fails
+	secondStarted, secondRelease := make(chan struct{}), make(chan struct{})
+	second := make(chan error, 1)
+	go func() {
+		second <- log.Once(t.Context(), key, func(context.Context) error {
+			close(secondStarted)
+			<-secondRelease
+			return nil
+		})
+	}()
+	<-secondStarted
+	close(release)
+	firstErr := <-first
+
+	// Then - the second intent still blocks a third attempt, and completes
+	assert.ErrorIs(t, firstErr, fairway.ErrClaimLost)
+	third := log.Once(t.Context(), key, func(context.Context) error { return nil })
+	assert.ErrorIs(t, third, fairway.ErrEffectInProgress)
+	close(secondRelease)
+	require.NoError(t, <-second)
+	done, err := log.Done(key)
+	require.NoError(t, err)
+	assert.True(t, done)
+}
+
+func TestEffectLog_IntentExpiresOnTheClock(t *testing.T) {
+	t.Parallel()
+
+	// Given - an attempt stalling in its effect
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t), fairway.WithEffectIntentTTL(time.Minute), fairway.WithEffectClock(clock))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "charge"}
+
+	var held, takenOver error
+	calls := 0
+	first := log.Once(t.Context(), key, func(ctx context.Context) error {
+		calls++
+		held = log.Once(ctx, key, func(context.Context) error { calls++; return nil })
+
+		// When - its intent expires on the clock
+		clock.Advance(2 * time.Minute)
+		takenOver = log.Once(ctx, key, func(context.Context) error { calls++; return nil })
+		return nil
+	})
+
+	// Then - the intent held until then, the next attempt ran and the first one can't complete
+	assert.ErrorIs(t, held, fairway.ErrEffectInProgress)
+	assert.NoError(t, takenOver)
+	assert.ErrorIs(t, first, fairway.ErrClaimLost)
+	assert.Equal(t, 2, calls)
+}
+
+func TestEffectLog_PurgeRemovesOldRecords(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	log := fairway.NewEffectLog(dcb.SetupTestStore(t))
+	key := fairway.EffectKey{Position: dcb.Versionstamp{1}, Name: "send-email"}
+	require.NoError(t, log.Once(t.Context(), key, func(context.Context) error { return nil }))
+
+	// When
+	purged, err := log.Purge(t.Context(), time.Now().Add(time.Minute))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	done, err := log.Done(key)
+	require.NoError(t, err)
+	assert.False(t, done)
+}
@@ -0,0 +1,141 @@
+package fairway
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrorHandler receives the background errors of a component (automation, exporter, supervisor...).
+// It is called from the component's goroutines: it must be safe for concurrent use and return quickly.
+type ErrorHandler func(err error)
+
+// ErrorReporter is implemented by components reporting their background errors to handlers.
+// Lifecycle and Supervisor register their own handler through it.
+type ErrorReporter interface {
+	// OnError adds h to the handlers of the background errors
+	OnError(h ErrorHandler)
+	// ErrorStats counts the background errors reported, and those the error channel dropped
+	ErrorStats() ErrorStats
+}
+
+// ErrorStats counts the background errors of a component
+type ErrorStats struct {
+	Reported uint64 // errors reported
+	Dropped  uint64 // errors not sent on the error channel, full (see Errors)
+}
+
+// closedErrCh is the Errors channel of components without error channel: ranging over it returns at once
+var closedErrCh = func() chan error {
+	ch := make(chan error)
+	close(ch)
+	return ch
+}()
+
+// errorReporter delivers the background errors of a component to its handlers and, opt-in, a buffered channel.
+// Errors nobody handles are logged with slog, so none are silently lost.
+type errorReporter struct {
+	source string // the component, logged with unhandled errors
+
+	mu       sync.RWMutex
+	handlers []ErrorHandler
+	ch       chan error // nil = no channel
+	closed   bool       // errors reported after close are dropped
+
+	reported atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+func newErrorReporter(source string) *errorReporter {
+	return &errorReporter{source: source}
+}
+
+// OnError adds h to the handlers of the background errors
+func (r *errorReporter) OnError(h ErrorHandler) {
+	if h == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// ErrorStats counts the background errors reported, and those the error channel dropped
+func (r *errorReporter) ErrorStats() ErrorStats {
+	return ErrorStats{Reported: r.reported.Load(), Dropped: r.dropped.Load()}
+}
+
+// withChannel makes the errors also sent on a channel buffering size of them, dropped when full
+func (r *errorReporter) withChannel(size int) {
+	r.ch = make(chan error, max(size, 1))
+}
+
+// report delivers err to the handlers and the channel, without blocking on the channel.
+// Errors reported after close are dropped.
+func (r *errorReporter) report(err error) {
+	r.reported.Add(1)
+	r.mu.RLock()
+	handlers, closed := r.handlers, r.closed
+	r.mu.RUnlock()
+	if closed {
+		r.dropped.Add(1)
+		return
+	}
+
+	if len(handlers) == 0 && r.ch == nil {
+		slog.Error("background error", "component", r.source, "error", err)
+		return
+	}
+	for _, h := range handlers {
+		h(err)
+	}
+	if r.ch != nil {
+		r.send(err)
+	}
+}
+
+// send sends err on the channel unless it is full or closed
+func (r *errorReporter) send(err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	select {
+	case r.ch <- err:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// channel returns the error channel, closed at once when not enabled
+func (r *errorReporter) channel() <-chan error {
+	if r.ch == nil {
+		return closedErrCh
+	}
+	return r.ch
+}
+
+// close closes the error channel, its unread errors can still be received. Safe to call twice.
+func (r *errorReporter) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.ch != nil {
+		close(r.ch)
+	}
+}
+
+// closeAndDrain closes the error channel and returns the errors left unread in it
+func (r *errorReporter) closeAndDrain() []error {
+	r.close()
+	var unread []error
+	for err := range r.channel() {
+		unread = append(unread, err)
+	}
+	return unread
+}
@@ -13,8 +13,25 @@ import (
 type Event struct {
 	OccurredAt time.Time `json:"occurredAt"`
 	Data       any       `json:"data"`
+	// IdempotencyKey is the key of the request or message that appended the event, stamped from the
+	// context when appended by a command (see WithIdempotencyKey). Empty when appended without one.
+	// It is stored in the event's metadata, under IdempotencyKeyMetadata.
+	IdempotencyKey string `json:"-"`
+	// CommittedAt is the store's clock when the event was committed, set on events read from the store.
+	// Unlike OccurredAt, it doesn't depend on the producer's clock (zero for events stored before it was recorded).
+	CommittedAt time.Time `json:"-"`
+	// Position is the event's position in the store, set on events read from the store
+	Position dcb.Versionstamp `json:"-"`
+	// Superseded is set on events read from the store once superseded (see dcb.Superseder), nil otherwise
+	Superseded *dcb.Supersession `json:"-"`
+	// Sequence is the event's logical sequence number, set on events read from a store with dcb.WithSequences
+	// once numbered (0 otherwise)
+	Sequence int64 `json:"-"`
 }
 
+// IdempotencyKeyMetadata is the dcb.Event metadata key reserved for Event.IdempotencyKey
+const IdempotencyKeyMetadata = "fairway.idempotencyKey"
+
 // NewEvent creates an event with auto-generated timestamp
 func NewEvent(data any) Event {
 	return Event{OccurredAt: time.Now(), Data: data}
@@ -30,6 +47,9 @@ func (e Event) OccuredAt() time.Time { return e.OccurredAt }
 
 // Tags returns tags from the underlying data if it implements Tags() []string
 func (e Event) Tags() []string {
+	if raw, ok := e.Data.(RawEvent); ok {
+		return raw.Tags
+	}
 	if tagger, ok := e.Data.(interface{ Tags() []string }); ok {
 		return tagger.Tags()
 	}
@@ -38,22 +58,31 @@ func (e Event) Tags() []string {
 
 // typeString returns the type name for registry lookup
 func (e Event) typeString() string {
-	if typer, ok := e.Data.(interface{ TypeString() string }); ok {
-		return typer.TypeString()
-	}
-	return reflect.TypeOf(e.Data).Name()
+	return resolveEventTypeName(e.Data)
 }
 
 // ToDcbEvent serializes an Event to dcb.Event
 func ToDcbEvent(e Event) (dcb.Event, error) {
+	if raw, ok := e.Data.(RawEvent); ok {
+		if err := raw.validate(); err != nil {
+			return dcb.Event{}, err
+		}
+	}
 	data, err := json.Marshal(e)
 	if err != nil {
 		return dcb.Event{}, fmt.Errorf("failed to serialize event: %w", err)
 	}
+	registerPIIType(e.typeString(), reflect.TypeOf(e.Data))
+
+	var metadata map[string]string
+	if e.IdempotencyKey != "" {
+		metadata = map[string]string{IdempotencyKeyMetadata: e.IdempotencyKey}
+	}
 
 	return dcb.Event{
-		Type: e.typeString(),
-		Data: data,
-		Tags: e.Tags(),
+		Type:     e.typeString(),
+		Data:     data,
+		Tags:     e.Tags(),
+		Metadata: metadata,
 	}, nil
 }
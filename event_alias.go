@@ -0,0 +1,94 @@
+package fairway
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+)
+
+// DeprecatedTypeObserver is notified every time an event stored under a deprecated type name is read
+type DeprecatedTypeObserver func(deprecated, current string)
+
+// typeAliases holds the deprecated event type names, shared by every reader
+var typeAliases = struct {
+	sync.RWMutex
+	types    map[string]reflect.Type // deprecated name -> current Go type
+	names    map[string]string       // deprecated name -> current name
+	aliases  map[string][]string     // current name -> deprecated names
+	observer DeprecatedTypeObserver
+}{
+	types:    make(map[string]reflect.Type),
+	names:    make(map[string]string),
+	aliases:  make(map[string][]string),
+	observer: warnOnceDeprecated(),
+}
+
+// RegisterEventTypeAlias declares that events stored under the deprecated type name
+// deserialize into current's Go type. Queries on current also match the deprecated name,
+// so renaming an event struct doesn't hide historical events from consumers.
+// Call it at init time; it panics if deprecated is already an alias of another type.
+func RegisterEventTypeAlias(deprecated string, current any) {
+	currentName := resolveEventTypeName(current)
+	if deprecated == "" || deprecated == currentName {
+		panic(fmt.Sprintf("fairway: invalid alias %q for event type %q", deprecated, currentName))
+	}
+
+	typeAliases.Lock()
+	defer typeAliases.Unlock()
+
+	if existing, ok := typeAliases.names[deprecated]; ok {
+		if existing != currentName {
+			panic(fmt.Sprintf("fairway: event type alias %q already points to %q", deprecated, existing))
+		}
+		return
+	}
+	typeAliases.types[deprecated] = reflect.TypeOf(current)
+	typeAliases.names[deprecated] = currentName
+	typeAliases.aliases[currentName] = append(typeAliases.aliases[currentName], deprecated)
+}
+
+// SetDeprecatedTypeObserver replaces the observer notified when deprecated types are read
+// (default: a warning logged with slog once per deprecated name). nil disables notifications.
+func SetDeprecatedTypeObserver(o DeprecatedTypeObserver) {
+	typeAliases.Lock()
+	defer typeAliases.Unlock()
+	typeAliases.observer = o
+}
+
+// typeAliasesOf returns the deprecated names of the current type name
+func typeAliasesOf(current string) []string {
+	typeAliases.RLock()
+	defer typeAliases.RUnlock()
+	return typeAliases.aliases[current]
+}
+
+// aliasedType returns the Go type registered for a deprecated type name
+func aliasedType(deprecated string) (reflect.Type, bool) {
+	typeAliases.RLock()
+	defer typeAliases.RUnlock()
+	typ, ok := typeAliases.types[deprecated]
+	return typ, ok
+}
+
+// notifyIfDeprecated calls the observer when typeName is a deprecated alias
+func notifyIfDeprecated(typeName string) {
+	typeAliases.RLock()
+	current, ok := typeAliases.names[typeName]
+	observer := typeAliases.observer
+	typeAliases.RUnlock()
+
+	if ok && observer != nil {
+		observer(typeName, current)
+	}
+}
+
+// warnOnceDeprecated logs a warning the first time each deprecated type is read
+func warnOnceDeprecated() DeprecatedTypeObserver {
+	var warned sync.Map
+	return func(deprecated, current string) {
+		if _, loaded := warned.LoadOrStore(deprecated, struct{}{}); !loaded {
+			slog.Warn("reading events of deprecated type", "deprecated", deprecated, "current", current)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package fairway_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MemberJoined was formerly named UserSignedUp
+type MemberJoined struct {
+	Name string `json:"name"`
+}
+
+func TestEventTypeAlias_ReadsHistoricalEvents(t *testing.T) {
+	// Given - an event stored before the struct was renamed
+	ctx := context.Background()
+	store := dcb.SetupTestStore(t)
+	fairway.RegisterEventTypeAlias("UserSignedUp", MemberJoined{})
+
+	var mu sync.Mutex
+	var deprecatedReads []string
+	fairway.SetDeprecatedTypeObserver(func(deprecated, current string) {
+		mu.Lock()
+		defer mu.Unlock()
+		deprecatedReads = append(deprecatedReads, deprecated+"->"+current)
+	})
+	t.Cleanup(func() { fairway.SetDeprecatedTypeObserver(nil) })
+
+	historical, err := fairway.ToDcbEvent(fairway.NewEvent(MemberJoined{Name: "john"}))
+	require.NoError(t, err)
+	historical.Type = "UserSignedUp"
+	current, err := fairway.ToDcbEvent(fairway.NewEvent(MemberJoined{Name: "jane"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, []dcb.Event{historical, current}))
+
+	// When
+	var names []string
+	err = fairway.NewReader(store).ReadEvents(ctx,
+		fairway.QueryItems(fairway.NewQueryItem().Types(MemberJoined{})),
+		func(e fairway.Event) bool {
+			names = append(names, e.Data.(MemberJoined).Name)
+			return true
+		})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"john", "jane"}, names)
+	assert.Equal(t, []string{"UserSignedUp->MemberJoined"}, deprecatedReads)
+}
+
+func TestEventTypeAlias_ConflictingAliasPanics(t *testing.T) {
+	type Other struct{}
+	fairway.RegisterEventTypeAlias("LegacyThing", MemberJoined{})
+
+	assert.NotPanics(t, func() { fairway.RegisterEventTypeAlias("LegacyThing", MemberJoined{}) })
+	assert.Panics(t, func() { fairway.RegisterEventTypeAlias("LegacyThing", Other{}) })
+	assert.Panics(t, func() { fairway.RegisterEventTypeAlias("MemberJoined", MemberJoined{}) })
+}
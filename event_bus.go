@@ -0,0 +1,112 @@
+package fairway
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// defaultSubscriptionBuffer is used when Subscribe is called with a non-positive buffer
+const defaultSubscriptionBuffer = 64
+
+// EventNotification tells subscribers that an event was committed
+type EventNotification struct {
+	Type     string
+	Tags     []string
+	Position dcb.Versionstamp
+}
+
+// EventBus dispatches post-commit notifications to in-process subscribers
+// (cache invalidation, websocket fan-out) without maintaining a read model.
+// Only appends made by this process through a store configured with Hook are published.
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives the notifications of the events it subscribed to
+type Subscription struct {
+	C <-chan EventNotification
+
+	ch      chan EventNotification
+	types   map[string]bool // nil = every type
+	dropped atomic.Uint64
+	bus     *EventBus
+	once    sync.Once
+}
+
+// NewEventBus creates an event bus, register its Hook on the store to publish commits
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Hook returns the post-append hook publishing committed events:
+//
+//	dcb.NewDcbStore(db, "myapp", dcb.StoreOptions{}.WithPostAppendHook(bus.Hook()))
+func (b *EventBus) Hook() dcb.PostAppendHook {
+	return func(_ context.Context, events []dcb.StoredEvent) {
+		for _, ev := range events {
+			b.Publish(EventNotification{Type: ev.Type, Tags: ev.Tags, Position: ev.Position})
+		}
+	}
+}
+
+// Subscribe returns a subscription to the given event types (every type if none).
+// Publishing never blocks appends: when the buffer is full, notifications are dropped
+// (see Subscription.Dropped). Close the subscription when done.
+func (b *EventBus) Subscribe(buffer int, types ...any) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+	ch := make(chan EventNotification, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+	if len(types) > 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			name := resolveEventTypeName(t)
+			sub.types[name] = true
+			for _, alias := range typeAliasesOf(name) {
+				sub.types[alias] = true
+			}
+		}
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish notifies the subscribers of n's type
+func (b *EventBus) Publish(n EventNotification) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if sub.types != nil && !sub.types[n.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- n:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of notifications lost because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
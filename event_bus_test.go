@@ -0,0 +1,64 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus_NotifiesSubscribersAfterCommit(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	bus := fairway.NewEventBus()
+	store := dcb.SetupTestStore(t)
+	dcb.StoreOptions{}.WithPostAppendHook(bus.Hook())(store)
+	items := bus.Subscribe(10, PageItem{})
+	defer items.Close()
+	all := bus.Subscribe(10)
+	defer all.Close()
+
+	// When
+	runner := fairway.NewCommandRunner(store)
+	err := runner.RunPure(context.Background(), commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}), fairway.NewEvent(PageNote{}))
+	}))
+	require.NoError(t, err)
+
+	// Then - each subscriber gets the committed events of its types, with their positions
+	stored := dcb.CollectEvents(t, store.ReadAll(context.Background()))
+	require.Len(t, stored, 2)
+
+	require.Len(t, items.C, 1)
+	n := <-items.C
+	assert.Equal(t, "PageItem", n.Type)
+	assert.Equal(t, stored[0].Position, n.Position)
+
+	require.Len(t, all.C, 2)
+	assert.Equal(t, stored[0].Position, (<-all.C).Position)
+	assert.Equal(t, stored[1].Position, (<-all.C).Position)
+}
+
+func TestEventBus_FullBufferDropsInsteadOfBlocking(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	bus := fairway.NewEventBus()
+	sub := bus.Subscribe(1)
+
+	// When
+	bus.Publish(fairway.EventNotification{Type: "A"})
+	bus.Publish(fairway.EventNotification{Type: "B"})
+	sub.Close()
+	bus.Publish(fairway.EventNotification{Type: "C"})
+
+	// Then
+	assert.Equal(t, uint64(1), sub.Dropped())
+	assert.Equal(t, "A", (<-sub.C).Type)
+	_, open := <-sub.C
+	assert.False(t, open)
+}
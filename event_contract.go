@@ -0,0 +1,217 @@
+package fairway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ErrEventContract is returned by CheckEventFixtures and VerifyEventFixtures when a contract is broken
+var ErrEventContract = errors.New("event contract broken")
+
+// eventFixture is the file recording how a producer serializes an event type: <dir>/<type name>.json
+type eventFixture struct {
+	Type string          `json:"type"`
+	Tags []string        `json:"tags"`
+	Data json.RawMessage `json:"data"`
+}
+
+// eventFixturePath returns the path of the fixture of the type
+func eventFixturePath(dir, typeName string) string {
+	return filepath.Join(dir, strings.ReplaceAll(typeName, string(filepath.Separator), "_")+".json")
+}
+
+// marshalEventFixture returns the fixture of e, indented so that diffs are readable in reviews
+func marshalEventFixture(e Event) (string, []byte, error) {
+	de, err := ToDcbEvent(e)
+	if err != nil {
+		return "", nil, err
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(de.Data, &envelope); err != nil {
+		return "", nil, err
+	}
+	tags := de.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	content, err := json.MarshalIndent(eventFixture{Type: de.Type, Tags: tags, Data: envelope.Data}, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	return de.Type, append(content, '\n'), nil
+}
+
+// WriteEventFixtures writes the fixture of each event to dir, one file per type:
+// the producer's side of the contract, committed with its code (see CheckEventFixtures).
+// Pick examples with every field set: fields left empty with omitempty are absent from the fixture.
+func WriteEventFixtures(dir string, events ...Event) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(events))
+	for _, e := range events {
+		typeName, content, err := marshalEventFixture(e)
+		if err != nil {
+			return err
+		}
+		if seen[typeName] {
+			return fmt.Errorf("several fixtures of event type %q", typeName)
+		}
+		seen[typeName] = true
+		if err := os.WriteFile(eventFixturePath(dir, typeName), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyEventFixtures fails with ErrEventContract if the fixtures of dir don't match the serialization
+// of events: the producer changed its structs without updating its fixtures (see WriteEventFixtures).
+func VerifyEventFixtures(dir string, events ...Event) error {
+	var errs []error
+	for _, e := range events {
+		typeName, content, err := marshalEventFixture(e)
+		if err != nil {
+			return err
+		}
+		stored, err := os.ReadFile(eventFixturePath(dir, typeName))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			errs = append(errs, fmt.Errorf("%w: no fixture of %q", ErrEventContract, typeName))
+		case err != nil:
+			return err
+		case !bytes.Equal(stored, content):
+			errs = append(errs, fmt.Errorf("%w: the fixture of %q is stale, its events are now serialized as:\n%s", ErrEventContract, typeName, content))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckEventFixtures fails with ErrEventContract unless the fixtures of dir, written by a producer,
+// deserialize into the example types of a consumer the way it reads them from the store:
+//   - a fixture exists under the type name of each example, or under one of its aliases (see RegisterEventTypeAlias);
+//   - its payload decodes into the example's type;
+//   - it has every field of the example's type, except those tagged omitempty: a field renamed or
+//     removed by the producer would otherwise be read as its zero value.
+//
+// Consumers run it against the producer's fixtures, and producers against the fixtures of their
+// previous releases, to check that their current structs still read the events already stored.
+func CheckEventFixtures(dir string, examples ...any) error {
+	var errs []error
+	for _, example := range examples {
+		if err := checkEventFixture(dir, example); err != nil {
+			if !errors.Is(err, ErrEventContract) {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkEventFixture(dir string, example any) error {
+	typeName := resolveEventTypeName(example)
+	fixture, found, err := readEventFixture(dir, typeName)
+	if err != nil {
+		return err
+	}
+	for _, alias := range typeAliasesOf(typeName) {
+		if found {
+			break
+		}
+		if fixture, found, err = readEventFixture(dir, alias); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: no fixture of %q in %s", ErrEventContract, typeName, dir)
+	}
+
+	envelope, err := json.Marshal(struct {
+		Data json.RawMessage `json:"data"`
+	}{Data: fixture.Data})
+	if err != nil {
+		return err
+	}
+	registry := newEventRegistry()
+	registry.types[typeName] = reflect.TypeOf(example)
+	if _, err := registry.deserialize(dcb.Event{Type: fixture.Type, Tags: fixture.Tags, Data: envelope}); err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrEventContract, typeName, err)
+	}
+
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(fixture.Data, &payload); err != nil {
+		return nil // not an object: decoding it was the whole check
+	}
+	sent := make(map[string]bool, len(payload))
+	for name := range payload {
+		sent[strings.ToLower(name)] = true // decoding matches names case-insensitively
+	}
+	var missing []string
+	for _, field := range requiredJSONFields(reflect.TypeOf(example)) {
+		if !sent[strings.ToLower(field)] {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %q: the producer doesn't send %s", ErrEventContract, typeName, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func readEventFixture(dir, typeName string) (eventFixture, bool, error) {
+	content, err := os.ReadFile(eventFixturePath(dir, typeName))
+	if errors.Is(err, os.ErrNotExist) {
+		return eventFixture{}, false, nil
+	}
+	if err != nil {
+		return eventFixture{}, false, err
+	}
+	var fixture eventFixture
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return eventFixture{}, false, fmt.Errorf("fixture of %q: %w", typeName, err)
+	}
+	return fixture, true, nil
+}
+
+// requiredJSONFields returns the JSON names of the fields of the struct t that are always marshaled,
+// those of embedded structs included
+func requiredJSONFields(t reflect.Type) []string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			fields = append(fields, requiredJSONFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() || strings.Contains(","+opts+",", ",omitempty,") || strings.Contains(","+opts+",", ",omitzero,") {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
@@ -0,0 +1,117 @@
+package fairway_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OrderPlaced is the producer's event of the contract tests
+type OrderPlaced struct {
+	OrderId string `json:"orderId"`
+	Amount  int    `json:"amount"`
+	Note    string `json:"note,omitempty"`
+}
+
+func (e OrderPlaced) Tags() []string { return []string{"order_id:" + e.OrderId} }
+
+// consumerOrderPlaced is a consumer's copy of OrderPlaced
+type consumerOrderPlaced struct {
+	OrderId string `json:"orderId"`
+	Amount  int    `json:"amount"`
+}
+
+func (consumerOrderPlaced) TypeString() string { return "OrderPlaced" }
+
+// consumerOrderPlacedWithCurrency expects a field the producer doesn't send
+type consumerOrderPlacedWithCurrency struct {
+	OrderId  string `json:"orderId"`
+	Currency string `json:"currency"`
+	Coupon   string `json:"coupon,omitempty"`
+}
+
+func (consumerOrderPlacedWithCurrency) TypeString() string { return "OrderPlaced" }
+
+// consumerOrderPlacedWithTextAmount reads the amount with another type
+type consumerOrderPlacedWithTextAmount struct {
+	Amount string `json:"amount"`
+}
+
+func (consumerOrderPlacedWithTextAmount) TypeString() string { return "OrderPlaced" }
+
+func writeOrderFixtures(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, fairway.WriteEventFixtures(dir, fairway.NewEvent(OrderPlaced{OrderId: "o-1", Amount: 42, Note: "gift"})))
+	return dir
+}
+
+func TestEventFixtures_ConsumerReadsTheProducerFixtures(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	dir := writeOrderFixtures(t)
+
+	// When
+	err := fairway.CheckEventFixtures(dir, consumerOrderPlaced{}, OrderPlaced{})
+
+	// Then
+	assert.NoError(t, err)
+	content, readErr := os.ReadFile(filepath.Join(dir, "OrderPlaced.json"))
+	require.NoError(t, readErr)
+	assert.JSONEq(t, `{"type":"OrderPlaced","tags":["order_id:o-1"],"data":{"orderId":"o-1","amount":42,"note":"gift"}}`, string(content))
+}
+
+func TestEventFixtures_ReportBreakingChanges(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	dir := writeOrderFixtures(t)
+
+	// When
+	missingField := fairway.CheckEventFixtures(dir, consumerOrderPlacedWithCurrency{})
+	wrongType := fairway.CheckEventFixtures(dir, consumerOrderPlacedWithTextAmount{})
+	missingFixture := fairway.CheckEventFixtures(t.TempDir(), consumerOrderPlaced{})
+
+	// Then - a field left empty with omitempty (coupon) isn't required
+	assert.ErrorIs(t, missingField, fairway.ErrEventContract)
+	assert.ErrorContains(t, missingField, "the producer doesn't send currency")
+	assert.NotContains(t, missingField.Error(), "coupon")
+	assert.ErrorIs(t, wrongType, fairway.ErrEventContract)
+	assert.ErrorIs(t, missingFixture, fairway.ErrEventContract)
+}
+
+// OrderPlacedRenamed is OrderPlaced renamed by a consumer, reading it through an alias
+type OrderPlacedRenamed struct {
+	OrderId string `json:"orderId"`
+}
+
+func TestEventFixtures_FollowAliases(t *testing.T) {
+	// Given
+	dir := writeOrderFixtures(t)
+	fairway.RegisterEventTypeAlias("OrderPlaced", OrderPlacedRenamed{})
+
+	// When
+	err := fairway.CheckEventFixtures(dir, OrderPlacedRenamed{})
+
+	// Then
+	assert.NoError(t, err)
+}
+
+func TestEventFixtures_VerifyDetectsStaleFixtures(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	dir := writeOrderFixtures(t)
+
+	// When
+	upToDate := fairway.VerifyEventFixtures(dir, fairway.NewEvent(OrderPlaced{OrderId: "o-1", Amount: 42, Note: "gift"}))
+	stale := fairway.VerifyEventFixtures(dir, fairway.NewEvent(OrderPlaced{OrderId: "o-1", Amount: 42}))
+
+	// Then
+	assert.NoError(t, upToDate)
+	assert.ErrorIs(t, stale, fairway.ErrEventContract)
+}
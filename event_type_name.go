@@ -0,0 +1,93 @@
+package fairway
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// EventTypeNaming names the stored type of event structs not implementing TypeString()
+type EventTypeNaming func(t reflect.Type) string
+
+// VersionedEvent is implemented by events whose schema version is part of their type name
+// (see PackageQualifiedNaming)
+type VersionedEvent interface {
+	EventVersion() int
+}
+
+// StructNameNaming names events by their struct name, e.g. "UserRegistered" (the default)
+func StructNameNaming(t reflect.Type) string {
+	return t.Name()
+}
+
+// PackageQualifiedNaming prefixes the struct name with the name of its package, e.g. "user.UserRegistered",
+// and suffixes it with the version of events implementing VersionedEvent, e.g. "user.UserRegistered.v1".
+// Events of different packages sharing a struct name get distinct types.
+func PackageQualifiedNaming(t reflect.Type) string {
+	name := t.Name()
+	if pkg := path.Base(t.PkgPath()); pkg != "." && pkg != "/" {
+		name = pkg + "." + name
+	}
+	if v, ok := reflect.Zero(t).Interface().(VersionedEvent); ok {
+		name += ".v" + strconv.Itoa(v.EventVersion())
+	}
+	return name
+}
+
+// eventTypeNames holds the naming strategy and the Go type owning each name it produced
+var eventTypeNames = struct {
+	sync.RWMutex
+	naming EventTypeNaming
+	owners map[string]reflect.Type // type name -> Go type
+}{
+	naming: StructNameNaming,
+	owners: make(map[string]reflect.Type),
+}
+
+// SetEventTypeNaming replaces the strategy naming event types (default: StructNameNaming).
+// Call it at init time, before any event is appended or queried: events already stored keep their
+// type names, register them with RegisterEventTypeAlias to keep reading them under the new names.
+func SetEventTypeNaming(naming EventTypeNaming) {
+	if naming == nil {
+		naming = StructNameNaming
+	}
+
+	eventTypeNames.Lock()
+	defer eventTypeNames.Unlock()
+	eventTypeNames.naming = naming
+	eventTypeNames.owners = make(map[string]reflect.Type)
+}
+
+// nameEventType names t with the naming strategy.
+// It panics if the name was already given to another Go type: both types would read each other's events.
+func nameEventType(t reflect.Type) string {
+	eventTypeNames.RLock()
+	name := eventTypeNames.naming(t)
+	owner, ok := eventTypeNames.owners[name]
+	eventTypeNames.RUnlock()
+
+	if name == "" {
+		return name // unnamed types are rejected by query validation
+	}
+	if ok {
+		if owner != t {
+			panic(eventTypeCollision(name, owner, t))
+		}
+		return name
+	}
+
+	eventTypeNames.Lock()
+	defer eventTypeNames.Unlock()
+	if owner, ok := eventTypeNames.owners[name]; ok && owner != t {
+		panic(eventTypeCollision(name, owner, t))
+	}
+	eventTypeNames.owners[name] = t
+	return name
+}
+
+func eventTypeCollision(name string, owner, t reflect.Type) string {
+	return fmt.Sprintf("fairway: event types %s.%s and %s.%s are both named %q: use PackageQualifiedNaming or implement TypeString()",
+		owner.PkgPath(), owner.Name(), t.PkgPath(), t.Name(), name)
+}
@@ -0,0 +1,77 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type AccountOpened struct {
+	ID string `json:"id"`
+}
+
+func (AccountOpened) EventVersion() int { return 2 }
+
+func TestEventTypeNaming_PackageQualified(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(t)
+	fairway.SetEventTypeNaming(fairway.PackageQualifiedNaming)
+	t.Cleanup(func() { fairway.SetEventTypeNaming(nil) })
+
+	// When
+	stored, err := fairway.ToDcbEvent(fairway.NewEvent(AccountOpened{ID: "42"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, []dcb.Event{stored}))
+
+	var read []AccountOpened
+	err = fairway.NewReader(store).ReadEvents(ctx,
+		fairway.QueryItems(fairway.NewQueryItem().Types(AccountOpened{})),
+		func(e fairway.Event) bool {
+			read = append(read, e.Data.(AccountOpened))
+			return true
+		})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "fairway_test.AccountOpened.v2", stored.Type)
+	assert.Equal(t, []AccountOpened{{ID: "42"}}, read)
+}
+
+func TestEventTypeNaming_CollisionsPanic(t *testing.T) {
+	t.Cleanup(func() { fairway.SetEventTypeNaming(nil) })
+
+	// Given - two event structs named Event, in fairway and in dcb
+	fairway.SetEventTypeNaming(nil)
+	_, err := fairway.ToDcbEvent(fairway.NewEvent(fairway.Event{}))
+	require.NoError(t, err)
+
+	// Then - struct names collide
+	assert.Panics(t, func() { _, _ = fairway.ToDcbEvent(fairway.NewEvent(dcb.Event{})) })
+	assert.Panics(t, func() { fairway.NewQueryItem().Types(dcb.Event{}) })
+
+	// When - names are qualified by package
+	fairway.SetEventTypeNaming(fairway.PackageQualifiedNaming)
+
+	// Then
+	assert.NotPanics(t, func() {
+		fairway.NewQueryItem().Types(fairway.Event{}, dcb.Event{})
+	})
+}
+
+func TestEventTypeNaming_TypeStringTakesPrecedence(t *testing.T) {
+	// Given
+	fairway.SetEventTypeNaming(fairway.PackageQualifiedNaming)
+	t.Cleanup(func() { fairway.SetEventTypeNaming(nil) })
+
+	// When
+	stored, err := fairway.ToDcbEvent(fairway.NewEvent(fairway.RawEvent{Type: "AccountOpened", Data: []byte(`{}`)}))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "AccountOpened", stored.Type)
+}
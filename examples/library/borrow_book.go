@@ -10,13 +10,18 @@ import (
 
 // EVENTS
 
+var (
+	bookIdTag     = dcb.TagKey("book_id")
+	borrowerIdTag = dcb.TagKey("borrower_id")
+)
+
 type BookBorrowed struct {
 	BookId     string `json:"book_id"`
 	BorrowerId string `json:"borrower_id"`
 }
 
 func (e BookBorrowed) Tags() []string {
-	return []string{"book_id:" + e.BookId, "borrower_id:" + e.BorrowerId}
+	return []string{bookIdTag.Equals(e.BookId), borrowerIdTag.Equals(e.BorrowerId)}
 }
 
 type BookReturned struct {
@@ -26,8 +31,8 @@ type BookReturned struct {
 
 func (e BookReturned) Tags() []string {
 	return []string{
-		"book_id:" + e.BookId,
-		"borrower_id:" + e.BorrowerId,
+		bookIdTag.Equals(e.BookId),
+		borrowerIdTag.Equals(e.BorrowerId),
 	}
 }
 
@@ -67,12 +72,14 @@ func (cmd BorrowBook) ensureBookNotCurrentlyBorrowed(ctx context.Context, ev fai
 		fairway.QueryItems(
 			fairway.NewQueryItem().
 				Types(BookBorrowed{}, BookReturned{}).
-				Tags("book_id:"+cmd.BookId),
-		).WithOptions(dcb.ReadOptions{Reverse: true, Limit: 1}),
+				Tags(bookIdTag.Equals(cmd.BookId)),
+		),
 		func(e fairway.Event) bool {
 			_, isBorrowed = e.Data.(BookBorrowed)
 			return false // only need first event
-		}); err != nil {
+		},
+		fairway.ReadLatest(1),
+	); err != nil {
 		return err
 	}
 
@@ -89,7 +96,7 @@ func (cmd BorrowBook) ensureReaderBelowMaxBorrowedBooksLimit(ctx context.Context
 		fairway.QueryItems(
 			fairway.NewQueryItem().
 				Types(BookBorrowed{}, BookReturned{}).
-				Tags("borrower_id:"+cmd.BorrowerId),
+				Tags(borrowerIdTag.Equals(cmd.BorrowerId)),
 		),
 		func(e fairway.Event) bool {
 			switch data := e.Data.(type) {
@@ -17,6 +17,7 @@ func init() {
 // Deps for this automation
 type Deps struct {
 	EmailSender automate.EmailSender
+	Effects     *fairway.EffectLog
 }
 
 type command struct {
@@ -28,11 +29,14 @@ func Register(registry *fairway.AutomationRegistry[automate.AllDeps]) {
 	registry.RegisterAutomation(
 		func(store dcb.DcbStore, deps automate.AllDeps) (fairway.Startable, error) {
 			return fairway.NewAutomation(
-				store,                               // DCB store
-				Deps{EmailSender: deps.EmailSender}, // provide the dependencies implementations to the command
-				"welcome-email",                     // unique queue identifier
-				event.UserRegistered{},              // the event-type that triggers the automation
-				eventToCommand,                      // mapping to construct the command from the trigger event
+				store, // DCB store
+				Deps{ // provide the dependencies implementations to the command
+					EmailSender: deps.EmailSender,
+					Effects:     fairway.NewEffectLog(store),
+				},
+				"welcome-email",        // unique queue identifier
+				event.UserRegistered{}, // the event-type that triggers the automation
+				eventToCommand,         // mapping to construct the command from the trigger event
 			)
 		},
 	)
@@ -60,7 +64,10 @@ func (c command) Run(ctx context.Context, ra fairway.EventReadAppenderExtended,
 		return nil
 	}
 
-	if err := deps.EmailSender.SendWelcomeEmail(ctx, c.Email, c.Name); err != nil {
+	// the effect log prevents sending twice if the job is retried after the email went out
+	if err := deps.Effects.OnceForTrigger(ctx, "send-welcome-email", func(ctx context.Context) error {
+		return deps.EmailSender.SendWelcomeEmail(ctx, c.Email, c.Name)
+	}); err != nil {
 		log.Println(err)
 		return err
 	}
@@ -47,9 +47,9 @@ func TestUserRegistered_SendsWelcomeEmail(t *testing.T) {
 	emailSender := &InMemoryEmailSender{}
 	registry := &fairway.AutomationRegistry[automate.AllDeps]{}
 	userregistered.Register(registry)
-	stopFn, err := registry.StartAll(t.Context(), store, automate.AllDeps{EmailSender: emailSender})
+	lifecycle, err := registry.StartAll(t.Context(), store, automate.AllDeps{EmailSender: emailSender})
 	require.NoError(t, err)
-	defer stopFn()
+	defer lifecycle.Stop()
 
 	// Given/When: UserRegistered event
 	initialEvent := event.UserRegistered{
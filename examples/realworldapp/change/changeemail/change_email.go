@@ -2,7 +2,6 @@ package changeemail
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
@@ -35,16 +34,16 @@ type reqBody struct {
 
 func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := crypto.JwtService.ExtractUserID(r)
+		claims, err := crypto.Tokens.FromRequest(r)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			fairway.WriteProblem(w, fairway.NewProblem(http.StatusUnauthorized, ""))
 			return
 		}
+		userID := claims.UserId
 
 		var req reqBody
 		if err := utils.JsonParse(r, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -54,16 +53,15 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 			now:    time.Now(),
 		}); err != nil {
 			if errors.Is(err, conflictErr) {
-				w.WriteHeader(http.StatusConflict)
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusConflict, err.Error()))
 				return
 			}
 			if errors.Is(err, notFoundErr) {
-				w.WriteHeader(http.StatusNotFound)
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusNotFound, err.Error()))
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -2,7 +2,6 @@ package registeruser
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
@@ -38,8 +37,7 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req reqBody
 		if err := utils.JsonParse(r, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -51,12 +49,11 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 			now:            time.Now(),
 		}); err != nil {
 			if errors.Is(err, conflictErr) {
-				w.WriteHeader(http.StatusConflict)
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusConflict, err.Error()))
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -1,18 +1,16 @@
 package crypto
 
-import (
-	"golang.org/x/crypto/bcrypt"
-)
+import "github.com/err0r500/fairway/contrib/auth"
 
 func Hash(cleartextPassword string) string {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cleartextPassword), bcrypt.DefaultCost)
+	hashedPassword, err := auth.HashPassword(cleartextPassword)
 	if err != nil {
 		panic(err)
 	}
 
-	return string(hashedPassword)
+	return hashedPassword
 }
 
 func HashMatchesCleartext(hashedPassword, cleartextPassword string) bool {
-	return nil == bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(cleartextPassword))
+	return auth.PasswordMatches(hashedPassword, cleartextPassword)
 }
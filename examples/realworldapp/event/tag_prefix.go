@@ -1,13 +1,21 @@
 package event
 
+import "github.com/err0r500/fairway/dcb"
+
+var (
+	userIdTag    = dcb.TagKey("user_id")
+	userNameTag  = dcb.TagKey("username")
+	userEmailTag = dcb.TagKey("email")
+)
+
 func UserIdTag(id string) string {
-	return "user_id:" + id
+	return userIdTag.Equals(id)
 }
 
 func UserNameTag(name string) string {
-	return "username:" + name
+	return userNameTag.Equals(name)
 }
 
 func UserEmailTag(email string) string {
-	return "email:" + email
+	return userEmailTag.Equals(email)
 }
@@ -5,11 +5,13 @@ go 1.24.1
 require (
 	github.com/apple/foundationdb/bindings/go v0.0.0-20250911184653-27f7192f47c3
 	github.com/err0r500/fairway v0.0.0
-	github.com/golang-jwt/jwt/v5 v5.3.0
+	github.com/err0r500/fairway/contrib/auth v0.0.0
 )
 
 require (
 	github.com/avast/retry-go/v4 v4.7.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
+	go.etcd.io/bbolt v1.4.0 // indirect
 	golang.org/x/net v0.47.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
 	resty.dev/v3 v3.0.0-beta.6 // indirect
@@ -17,6 +19,8 @@ require (
 
 replace github.com/err0r500/fairway => ../../
 
+replace github.com/err0r500/fairway/contrib/auth => ../../contrib/auth
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.12 // indirect
@@ -27,7 +31,7 @@ require (
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/testify v1.11.1
-	golang.org/x/crypto v0.46.0
+	golang.org/x/crypto v0.46.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
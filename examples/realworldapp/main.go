@@ -30,14 +30,20 @@ func main() {
 	// core
 	coreStore := dcb.NewDcbStore(db, "realworldapp", dcb.StoreOptions{}.WithLogger(logger))
 
-	// Start automations
-	stopAutomations, err := automate.Registry.StartAll(context.Background(), coreStore, automate.AllDeps{
+	// Start automations under supervision
+	supervisor := fairway.NewSupervisor(fairway.WithSupervisorErrorHandler(func(err error) {
+		logger.Error("background failure", "error", err)
+	}))
+	automate.Registry.Supervise(supervisor, coreStore, automate.AllDeps{
 		EmailSender: &LoggingEmailSender{},
 	})
-	if err != nil {
+	if err := supervisor.Start(context.Background()); err != nil {
 		log.Fatal(err)
 	}
-	defer stopAutomations()
+	defer func() {
+		supervisor.Stop()
+		supervisor.Wait()
+	}()
 
 	// Setup router
 	mux := http.NewServeMux()
@@ -27,11 +27,12 @@ type respBody struct {
 
 func httpHandler(reader fairway.EventsReader) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := crypto.JwtService.ExtractUserID(r)
+		claims, err := crypto.Tokens.FromRequest(r)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			fairway.WriteProblem(w, fairway.NewProblem(http.StatusUnauthorized, ""))
 			return
 		}
+		userID := claims.UserId
 
 		var user *userState
 		if err := reader.ReadEvents(r.Context(),
@@ -67,13 +68,12 @@ func httpHandler(reader fairway.EventsReader) http.HandlerFunc {
 				}
 				return true
 			}); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
 		if user == nil {
-			w.WriteHeader(http.StatusNotFound)
+			fairway.WriteProblem(w, fairway.NewProblem(http.StatusNotFound, ""))
 			return
 		}
 
@@ -77,7 +77,7 @@ func TestGetCurrentUser_UnauthenticatedFails(t *testing.T) {
 }
 
 func generateToken(t *testing.T, userID string) string {
-	token, err := crypto.JwtService.Token(userID)
+	token, err := crypto.Tokens.Issue(userID, "")
 	assert.NoError(t, err)
 	return token
 }
@@ -32,8 +32,7 @@ func httpHandler(reader fairway.EventsReader) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req reqBody
 		if err := utils.JsonParse(r, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -51,25 +50,23 @@ func httpHandler(reader fairway.EventsReader) http.HandlerFunc {
 				}
 				return true
 			}); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
 		if foundUser == nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			fairway.WriteProblem(w, fairway.NewProblem(http.StatusUnauthorized, ""))
 			return
 		}
 
 		if !crypto.HashMatchesCleartext(foundUser.HashedPassword, req.Password) {
-			w.WriteHeader(http.StatusUnauthorized)
+			fairway.WriteProblem(w, fairway.NewProblem(http.StatusUnauthorized, ""))
 			return
 		}
 
-		token, err := crypto.JwtService.Token(foundUser.Id)
+		token, err := crypto.Tokens.Issue(foundUser.Id, "")
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -2,7 +2,6 @@ package additem
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -17,7 +16,11 @@ func init() {
 }
 
 func Register(registry *fairway.HttpChangeRegistry) {
-	registry.RegisterCommand("POST /api/lists/{listId}/items/{itemId}", httpHandler)
+	registry.RegisterCommand("POST /api/lists/{listId}/items/{itemId}", httpHandler,
+		fairway.WithRouteSummary("adds an item to a list"),
+		fairway.WithRouteCommands(command{}),
+		fairway.WithRouteRequest(reqBody{}),
+	)
 }
 
 var itemAlreadyExistsErr = errors.New("item already exists")
@@ -30,8 +33,7 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req reqBody
 		if err := utils.JsonParse(r, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -41,12 +43,11 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 			text:   req.Text,
 		}); err != nil {
 			if errors.Is(err, itemAlreadyExistsErr) {
-				w.WriteHeader(http.StatusConflict)
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusConflict, err.Error()))
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
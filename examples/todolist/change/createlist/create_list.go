@@ -2,7 +2,6 @@ package createlist
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -17,7 +16,11 @@ func init() {
 }
 
 func Register(registry *fairway.HttpChangeRegistry) {
-	registry.RegisterCommand("POST /api/lists/{listId}", httpHandler)
+	registry.RegisterCommand("POST /api/lists/{listId}", httpHandler,
+		fairway.WithRouteSummary("creates a list"),
+		fairway.WithRouteCommands(command{}),
+		fairway.WithRouteRequest(reqBody{}),
+	)
 }
 
 var listAlreadyExistsErr = errors.New("list already exists")
@@ -31,8 +34,7 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req reqBody
 		if err := utils.JsonParse(r, &req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -41,12 +43,11 @@ func httpHandler(runner fairway.CommandRunner) http.HandlerFunc {
 			name:   req.Name,
 		}); err != nil {
 			if errors.Is(err, listAlreadyExistsErr) {
-				w.WriteHeader(http.StatusConflict)
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusConflict, err.Error()))
 				return
 			}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
+			fairway.WriteError(w, err)
 			return
 		}
 
@@ -1,9 +1,16 @@
 package event
 
+import "github.com/err0r500/fairway/dcb"
+
+var (
+	listIdTag = dcb.TagKey("list_id")
+	itemIdTag = dcb.TagKey("item_id")
+)
+
 func ListTagPrefix(listId string) string {
-	return "list_id:" + listId
+	return listIdTag.Equals(listId)
 }
 
 func ItemTagPrefix(itemId string) string {
-	return "item_id:" + itemId
+	return itemIdTag.Equals(itemId)
 }
@@ -17,16 +17,27 @@ import (
 )
 
 func main() {
-	// Setup FDB
-	fdb.MustAPIVersion(740)
-	db := fdb.MustOpenDefault()
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
 	slog.SetDefault(logger)
 
 	// core
-	coreStore := dcb.NewDcbStore(db, "core", dcb.StoreOptions{}.WithLogger(logger))
+	var coreStore dcb.DcbStore
+	if path := os.Getenv("FAIRWAY_EMBEDDED_PATH"); path != "" {
+		// Embedded mode: events are kept in a local file, no FDB cluster needed
+		store, err := dcb.OpenEmbeddedStore(path, "core")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		coreStore = store
+	} else {
+		// Setup FDB
+		fdb.MustAPIVersion(740)
+		db := fdb.MustOpenDefault()
+		coreStore = dcb.NewDcbStore(db, "core", dcb.StoreOptions{}.WithLogger(logger))
+	}
 
 	// Setup router
 	mux := http.NewServeMux()
@@ -43,8 +43,8 @@ func httpHandler(runner fairway.EventsReader) http.HandlerFunc {
 				}
 				return true
 			}); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			fairway.WriteError(w, err)
+			return
 		}
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(list)
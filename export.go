@@ -0,0 +1,362 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// sampleResolution is the granularity of sample rates (1 = every event, 1/sampleResolution = the smallest rate)
+const sampleResolution = 1_000_000
+
+// ExportSink writes exported events to an analytics store (e.g. Parquet or NDJSON files on S3).
+// Batches are delivered at least once: a batch is written again when the exporter stops before saving
+// its cursor, so sinks should overwrite rather than append (e.g. name files after the batch's Until position).
+type ExportSink interface {
+	Write(ctx context.Context, batch ExportBatch) error
+}
+
+// ExportSinkFunc adapts a function to ExportSink
+type ExportSinkFunc func(ctx context.Context, batch ExportBatch) error
+
+func (f ExportSinkFunc) Write(ctx context.Context, batch ExportBatch) error { return f(ctx, batch) }
+
+// ExportBatch is the events selected among a range of the log, in position order
+type ExportBatch struct {
+	Events []dcb.StoredEvent
+	After  *dcb.Versionstamp // the range start (exclusive), nil for the start of the log
+	Until  dcb.Versionstamp  // the range end (inclusive): the last position scanned, exported or not
+}
+
+// EventExporter feeds a sample of the event log to an ExportSink (data lakes, analytics),
+// tracking its cursor in FoundationDB like automations. Events are selected deterministically:
+// a restarted exporter, or another one with the same options, selects the same events.
+type EventExporter struct {
+	queueId      string
+	sink         ExportSink
+	sampleRate   float64         // < 0 = every event without exported types, none with
+	types        map[string]bool // exported whatever the sample rate
+	batchSize    int
+	pollInterval time.Duration
+	catchUpRate  float64 // events scanned per second, 0 = unlimited
+
+	// FDB
+	db             fdb.Database
+	eventsSubspace subspace.Subspace // dcb's namespace/e
+	cursorKey      fdb.Key           // exporter namespace/cursor
+	cursorMetaKey  fdb.Key           // exporter namespace/cursor_meta
+
+	// Runtime
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	errs    *errorReporter
+	catchUp *catchUpLimiter // nil = unlimited
+	clock   Clock
+}
+
+// ExporterOption configures an EventExporter
+type ExporterOption func(*EventExporter)
+
+// WithSampleRate exports the given fraction of the events, between 0 and 1 (e.g. 0.01 for 1%)
+func WithSampleRate(rate float64) ExporterOption {
+	return func(e *EventExporter) {
+		if rate >= 0 && rate <= 1 {
+			e.sampleRate = rate
+		}
+	}
+}
+
+// WithExportedTypes exports every event of the given types, whatever the sample rate.
+// Without WithSampleRate, only these types are exported.
+func WithExportedTypes(types ...any) ExporterOption {
+	return func(e *EventExporter) {
+		for _, t := range types {
+			name := resolveEventTypeName(t)
+			e.types[name] = true
+			for _, alias := range typeAliasesOf(name) {
+				e.types[alias] = true
+			}
+		}
+	}
+}
+
+// WithExportBatchSize sets the number of events scanned per batch (default: 1000)
+func WithExportBatchSize(n int) ExporterOption {
+	return func(e *EventExporter) {
+		if n > 0 {
+			e.batchSize = n
+		}
+	}
+}
+
+// WithExportErrorHandler adds a handler of the exporter's background errors (failed batches).
+// Without handler nor error channel, they are logged with slog.
+func WithExportErrorHandler(h ErrorHandler) ExporterOption {
+	return func(e *EventExporter) {
+		e.errs.OnError(h)
+	}
+}
+
+// WithExportErrorChannel makes Errors return a channel buffering size background errors.
+// Errors are dropped when it is full (see ErrorStats): prefer WithExportErrorHandler.
+func WithExportErrorChannel(size int) ExporterOption {
+	return func(e *EventExporter) {
+		e.errs.withChannel(size)
+	}
+}
+
+// WithExportPollInterval sets the interval at which new events are looked for once caught up (default: 1s)
+func WithExportPollInterval(d time.Duration) ExporterOption {
+	return func(e *EventExporter) {
+		if d > 0 {
+			e.pollInterval = d
+		}
+	}
+}
+
+// WithExportCatchUpRateLimit bounds the events the exporter scans per second, so that exporting
+// the history doesn't saturate FDB or the sink. Once caught up, batches below the limit are not delayed.
+func WithExportCatchUpRateLimit(eventsPerSecond float64) ExporterOption {
+	return func(e *EventExporter) {
+		if eventsPerSecond > 0 {
+			e.catchUpRate = eventsPerSecond
+		}
+	}
+}
+
+// WithExportClock replaces the clock of the exporter's poll loop, catch-up rate limit and cursor metadata (see VirtualClock)
+func WithExportClock(c Clock) ExporterOption {
+	return func(e *EventExporter) {
+		if c != nil {
+			e.clock = c
+		}
+	}
+}
+
+// NewEventExporter creates an exporter of the store's events to sink.
+// By default every event is exported; see WithSampleRate and WithExportedTypes.
+// queueId names the exporter's cursor: it must be unique among the automations and exporters of the store.
+func NewEventExporter(store dcb.DcbStore, queueId string, sink ExportSink, opts ...ExporterOption) (*EventExporter, error) {
+	if store == nil {
+		return nil, errors.New("store is required")
+	}
+	if sink == nil {
+		return nil, errors.New("sink is required")
+	}
+
+	dcbNamespace := store.Namespace()
+	exporterRoot := subspace.Sub(dcbNamespace + "/" + queueId)
+	e := &EventExporter{
+		queueId:        queueId,
+		sink:           sink,
+		sampleRate:     -1,
+		types:          make(map[string]bool),
+		batchSize:      1000,
+		pollInterval:   time.Second,
+		db:             store.Database(),
+		eventsSubspace: subspace.Sub(dcbNamespace).Sub("e"),
+		cursorKey:      exporterRoot.Pack(tuple.Tuple{"cursor"}),
+		cursorMetaKey:  exporterRoot.Pack(tuple.Tuple{"cursor_meta"}),
+		errs:           newErrorReporter(queueId),
+		clock:          systemClock{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.sampleRate < 0 && len(e.types) == 0 {
+		e.sampleRate = 1
+	}
+	return e, nil
+}
+
+// Start begins exporting in the background, in a goroutine carrying pprof labels naming the exporter
+func (e *EventExporter) Start(ctx context.Context) error {
+	e.ctx, e.cancel = context.WithCancel(withProfileLabels(ctx, "exporter", e.queueId))
+	e.catchUp = newCatchUpLimiter(e.catchUpRate, time.Second, e.clock.Now)
+
+	e.wg.Add(1)
+	goLabeled(e.ctx, "exporter", e.run)
+	return nil
+}
+
+// Stop signals the exporter to stop, the batch being written is written again on the next start
+func (e *EventExporter) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+// Wait blocks until the exporter has stopped.
+// With an error channel, it returns the errors left unread in it.
+func (e *EventExporter) Wait() error {
+	e.wg.Wait()
+	return errors.Join(e.errs.closeAndDrain()...)
+}
+
+// QueueId returns the identifier of the exporter's cursor
+func (e *EventExporter) QueueId() string {
+	return e.queueId
+}
+
+// Running reports whether the export loop is alive
+func (e *EventExporter) Running() bool {
+	return e.ctx != nil && e.ctx.Err() == nil
+}
+
+// Errors returns the error channel enabled by WithExportErrorChannel, closed at once without it
+func (e *EventExporter) Errors() <-chan error {
+	return e.errs.channel()
+}
+
+// OnError adds a handler of the exporter's background errors, see WithExportErrorHandler
+func (e *EventExporter) OnError(h ErrorHandler) {
+	e.errs.OnError(h)
+}
+
+// ErrorStats counts the exporter's background errors
+func (e *EventExporter) ErrorStats() ErrorStats {
+	return e.errs.ErrorStats()
+}
+
+// CaughtUp reports whether the cursor has reached the last event of the log
+func (e *EventExporter) CaughtUp() (bool, error) {
+	caughtUp, err := e.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		kvs, err := tr.GetRange(e.eventsSubspace, fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceWithError()
+		if err != nil || len(kvs) == 0 {
+			return true, err // nothing to export
+		}
+		last := extractVersionstampFromTypeIndex(e.eventsSubspace, kvs[0].Key)
+
+		cursor := decodeCursor(tr.Get(e.cursorKey).MustGet())
+		return cursor != nil && cursor.Compare(last) >= 0, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return caughtUp.(bool), nil
+}
+
+// Cursor returns the exporter's cursor: the last event scanned, when and by which host
+func (e *EventExporter) Cursor() (CursorInfo, error) {
+	return readCursorInfo(e.db, e.cursorKey, e.cursorMetaKey)
+}
+
+// run exports batches back to back while catching up (within the catch-up rate limit), then polls
+func (e *EventExporter) run() {
+	defer e.wg.Done()
+
+	ticker := e.clock.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+	for e.ctx.Err() == nil {
+		limit := e.catchUp.allowance(e.batchSize)
+		if limit == 0 {
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-e.clock.After(e.catchUp.delay()):
+				continue
+			}
+		}
+
+		scanned, err := e.exportBatch(limit)
+		e.catchUp.spend(scanned)
+		if err != nil && e.ctx.Err() == nil {
+			e.errs.report(fmt.Errorf("export batch: %w", err))
+		}
+		if err == nil && scanned == limit {
+			continue // more events are waiting
+		}
+
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+// exportBatch writes the selected events of the next batch of at most limit events to the sink,
+// then moves the cursor past the batch. Returns the number of events scanned.
+func (e *EventExporter) exportBatch(limit int) (int, error) {
+	batch, lastType, scanned, err := e.nextBatch(limit)
+	if err != nil || scanned == 0 {
+		return 0, err
+	}
+
+	if len(batch.Events) > 0 {
+		if err := e.sink.Write(e.ctx, batch); err != nil {
+			return 0, fmt.Errorf("sink: %w", err)
+		}
+	}
+
+	_, err = e.db.Transact(func(tr fdb.Transaction) (any, error) {
+		current := decodeCursor(tr.Get(e.cursorKey).MustGet())
+		if !sameCursor(current, batch.After) {
+			return nil, nil // exported concurrently by another process
+		}
+		setCursorInTx(tr, e.cursorKey, e.cursorMetaKey, batch.Until, lastType, e.clock.Now())
+		return nil, nil
+	})
+	return scanned, err
+}
+
+// nextBatch scans up to limit events after the cursor and selects those to export.
+// Returns the type of the last event scanned and the number of events scanned.
+func (e *EventExporter) nextBatch(limit int) (ExportBatch, string, int, error) {
+	var batch ExportBatch
+	var lastType string
+	scanned := 0
+	_, err := e.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		batch.After = decodeCursor(tr.Get(e.cursorKey).MustGet())
+		var r fdb.Range = e.eventsSubspace
+		if batch.After != nil {
+			rng, err := rangeAfterVersionstamp(e.eventsSubspace, *batch.After)
+			if err != nil {
+				return nil, err
+			}
+			r = rng
+		}
+
+		kvs, err := tr.GetRange(r, fdb.RangeOptions{Limit: limit}).GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvs {
+			vs := extractVersionstampFromTypeIndex(e.eventsSubspace, kv.Key)
+			event, err := dcb.DecodeStoredEvent(vs, kv.Value)
+			if err != nil {
+				return nil, fmt.Errorf("event at versionstamp %x: %w", vs[:], err)
+			}
+			if e.selects(event) {
+				batch.Events = append(batch.Events, event)
+			}
+			batch.Until = vs
+			lastType = event.Type
+		}
+		scanned = len(kvs)
+		return nil, nil
+	})
+	return batch, lastType, scanned, err
+}
+
+// selects reports whether event is exported: its type is exported, or its position is in the sample
+func (e *EventExporter) selects(event dcb.StoredEvent) bool {
+	if e.types[event.Type] {
+		return true
+	}
+	if e.sampleRate <= 0 {
+		return false
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(event.Position[:])
+	return float64(h.Sum64()%sampleResolution) < e.sampleRate*sampleResolution
+}
@@ -0,0 +1,201 @@
+package fairway_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink keeps the positions of the events it was given
+type recordingSink struct {
+	mu        sync.Mutex
+	positions []dcb.Versionstamp
+	types     []string
+}
+
+func (s *recordingSink) Write(_ context.Context, batch fairway.ExportBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range batch.Events {
+		s.positions = append(s.positions, ev.Position)
+		s.types = append(s.types, ev.Type)
+	}
+	return nil
+}
+
+func (s *recordingSink) exported() ([]dcb.Versionstamp, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]dcb.Versionstamp(nil), s.positions...), append([]string(nil), s.types...)
+}
+
+func setupExportStore(t *testing.T, userEvents, translatedEvents int) dcb.DcbStore {
+	t.Helper()
+	dcbNs := fmt.Sprintf("test-dcb-%s", uuid.NewString())
+	db := fdb.MustOpenDefault()
+	store := dcb.NewDcbStore(db, dcbNs)
+	t.Cleanup(func() {
+		_, _ = db.Transact(func(tr fdb.Transaction) (any, error) {
+			tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(dcbNs), End: fdb.Key(dcbNs + "\xff")})
+			return nil, nil
+		})
+	})
+
+	var events []dcb.Event
+	for i := range userEvents {
+		ev, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: fmt.Sprint(i)}))
+		require.NoError(t, err)
+		events = append(events, ev)
+	}
+	for i := range translatedEvents {
+		ev, err := fairway.ToDcbEvent(fairway.NewEvent(TestTranslatedEvent{UserID: fmt.Sprint(i)}))
+		require.NoError(t, err)
+		events = append(events, ev)
+	}
+	require.NoError(t, store.Append(context.Background(), events))
+	return store
+}
+
+// runExporter runs an exporter until it caught up
+func runExporter(t *testing.T, store dcb.DcbStore, sink fairway.ExportSink, opts ...fairway.ExporterOption) {
+	t.Helper()
+	opts = append(opts, fairway.WithExportBatchSize(7), fairway.WithExportPollInterval(10*time.Millisecond))
+	exporter, err := fairway.NewEventExporter(store, "export", sink, opts...)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background()))
+	assert.Eventually(t, func() bool {
+		caughtUp, err := exporter.CaughtUp()
+		return err == nil && caughtUp
+	}, 3*time.Second, 10*time.Millisecond)
+	exporter.Stop()
+	require.NoError(t, exporter.Wait())
+}
+
+func TestEventExporter_ExportsSelectedTypes(t *testing.T) {
+	// Given
+	store := setupExportStore(t, 10, 5)
+	sink := &recordingSink{}
+
+	// When
+	runExporter(t, store, sink, fairway.WithExportedTypes(TestTranslatedEvent{}))
+
+	// Then
+	positions, types := sink.exported()
+	assert.Len(t, positions, 5)
+	for _, typ := range types {
+		assert.Equal(t, "TestTranslatedEvent", typ)
+	}
+}
+
+func TestEventExporter_SamplesDeterministically(t *testing.T) {
+	// Given
+	store := setupExportStore(t, 200, 0)
+	first, second := &recordingSink{}, &recordingSink{}
+	runExporter(t, store, first, fairway.WithSampleRate(0.5))
+
+	// When - exported again from scratch, with a new cursor
+	_, err := store.Database().Transact(func(tr fdb.Transaction) (any, error) {
+		tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(store.Namespace() + "/export"), End: fdb.Key(store.Namespace() + "/export\xff")})
+		return nil, nil
+	})
+	require.NoError(t, err)
+	runExporter(t, store, second, fairway.WithSampleRate(0.5))
+
+	// Then - the same events were sampled
+	firstPositions, _ := first.exported()
+	secondPositions, _ := second.exported()
+	assert.Equal(t, firstPositions, secondPositions)
+	assert.Greater(t, len(firstPositions), 50)
+	assert.Less(t, len(firstPositions), 150)
+}
+
+func TestEventExporter_ResumesFromCursor(t *testing.T) {
+	// Given - every event was exported once
+	store := setupExportStore(t, 10, 0)
+	sink := &recordingSink{}
+	runExporter(t, store, sink)
+	ev, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "late"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{ev}))
+
+	// When
+	runExporter(t, store, sink)
+
+	// Then - only the new event was exported again
+	positions, _ := sink.exported()
+	assert.Len(t, positions, 11)
+	assert.True(t, dcb.EventsAreStriclyOrdered(toStored(positions)))
+}
+
+func TestEventExporter_CursorRecordsTheLastEventScanned(t *testing.T) {
+	// Given - the last event of the log is not exported
+	store := setupExportStore(t, 5, 3)
+	runExporter(t, store, &recordingSink{}, fairway.WithExportedTypes(TestAutomationEvent{}))
+
+	// When
+	exporter, err := fairway.NewEventExporter(store, "export", &recordingSink{})
+	require.NoError(t, err)
+	cursor, err := exporter.Cursor()
+
+	// Then
+	require.NoError(t, err)
+	events := dcb.CollectEvents(t, store.ReadAll(context.Background()))
+	require.NotNil(t, cursor.Position)
+	assert.Equal(t, events[len(events)-1].Position, *cursor.Position)
+	assert.Equal(t, "TestTranslatedEvent", cursor.LastEventType)
+	assert.NotZero(t, cursor.UpdatedAt)
+}
+
+func toStored(positions []dcb.Versionstamp) []dcb.StoredEvent {
+	events := make([]dcb.StoredEvent, len(positions))
+	for i, p := range positions {
+		events[i].Position = p
+	}
+	return events
+}
+
+func TestEventExporter_PollsOnTheClock(t *testing.T) {
+	// Given - an exporter on a virtual clock, caught up
+	store := setupExportStore(t, 1, 0)
+	sink := &recordingSink{}
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	exporter, err := fairway.NewEventExporter(store, "export", sink,
+		fairway.WithExportClock(clock), fairway.WithExportPollInterval(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, exporter.Start(context.Background()))
+	t.Cleanup(func() {
+		exporter.Stop()
+		_ = exporter.Wait()
+	})
+	require.Eventually(t, func() bool {
+		positions, _ := sink.exported()
+		return len(positions) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	cursor, err := exporter.Cursor()
+	require.NoError(t, err)
+	assert.True(t, cursor.UpdatedAt.Equal(clock.Now()), "the cursor moved at the clock's time")
+
+	// When - an event is appended
+	ev, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "late"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{ev}))
+
+	// Then - it is exported on the next tick of the clock only
+	time.Sleep(100 * time.Millisecond)
+	positions, _ := sink.exported()
+	assert.Len(t, positions, 1)
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		positions, _ := sink.exported()
+		return len(positions) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,283 @@
+// Package fairwayclient is an HTTP client for fairway services, for service-to-service calls.
+// It speaks the conventions of fairway's HTTP layer: idempotency keys on changes, position headers,
+// problem+json errors and conflict retries.
+//
+// It doesn't import fairway nor dcb, so clients don't link the FoundationDB library.
+package fairwayclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// PositionHeader carries the position token of the last event appended by a change (fairway.PositionHeader)
+	PositionHeader = "Fairway-Position"
+	// IdempotencyHeader carries the idempotency key of a change, so retries are applied once
+	IdempotencyHeader = "Idempotency-Key"
+	// ProblemContentType is the media type of error responses (fairway.ProblemContentType)
+	ProblemContentType = "application/problem+json"
+)
+
+// Client calls a fairway service
+type Client struct {
+	baseURL      string
+	http         *http.Client
+	header       http.Header
+	maxAttempts  int
+	baseWait     time.Duration
+	maxWait      time.Duration
+	newIdemToken func() string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the client sending the requests (default: http.DefaultClient)
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.http = hc
+		}
+	}
+}
+
+// WithHeader sets a header on every request (e.g. Authorization or the tenant header)
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		c.header.Set(name, value)
+	}
+}
+
+// WithMaxAttempts sets the number of attempts of retryable requests (default: 3)
+func WithMaxAttempts(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxAttempts = n
+		}
+	}
+}
+
+// WithRetryWait sets the backoff between attempts: base doubles on each retry, up to max,
+// which also caps the Retry-After of responses (defaults: 100ms, 5s)
+func WithRetryWait(base, max time.Duration) Option {
+	return func(c *Client) {
+		if base > 0 {
+			c.baseWait = base
+		}
+		if max > 0 {
+			c.maxWait = max
+		}
+	}
+}
+
+// New creates a client of the service at baseURL (e.g. "http://users:8080")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		http:         http.DefaultClient,
+		header:       make(http.Header),
+		maxAttempts:  3,
+		baseWait:     100 * time.Millisecond,
+		maxWait:      5 * time.Second,
+		newIdemToken: uuid.NewString,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Response describes a successful response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	// Position is the token of the last event the change appended ("" if none), to pass back to views
+	Position string
+	// Attempts is the number of requests sent
+	Attempts int
+}
+
+// RequestOption configures a single request
+type RequestOption func(*request)
+
+type request struct {
+	header         http.Header
+	idempotencyKey string
+}
+
+// WithIdempotencyKey sets the idempotency key of a change, e.g. derived from the message being handled,
+// so the change is applied once even when the call itself is repeated (default: a new key per call)
+func WithIdempotencyKey(key string) RequestOption {
+	return func(r *request) {
+		r.idempotencyKey = key
+	}
+}
+
+// WithRequestHeader sets a header on this request only
+func WithRequestHeader(name, value string) RequestOption {
+	return func(r *request) {
+		r.header.Set(name, value)
+	}
+}
+
+// Get reads the view at path, decoding its JSON response into out (ignored if nil)
+func (c *Client) Get(ctx context.Context, path string, out any, opts ...RequestOption) (Response, error) {
+	return c.Do(ctx, http.MethodGet, path, nil, out, opts...)
+}
+
+// Post sends a change to path with body as JSON, decoding the JSON response into out (ignored if nil)
+func (c *Client) Post(ctx context.Context, path string, body, out any, opts ...RequestOption) (Response, error) {
+	return c.Do(ctx, http.MethodPost, path, body, out, opts...)
+}
+
+// Put sends a change to path with body as JSON, decoding the JSON response into out (ignored if nil)
+func (c *Client) Put(ctx context.Context, path string, body, out any, opts ...RequestOption) (Response, error) {
+	return c.Do(ctx, http.MethodPut, path, body, out, opts...)
+}
+
+// Patch sends a change to path with body as JSON, decoding the JSON response into out (ignored if nil)
+func (c *Client) Patch(ctx context.Context, path string, body, out any, opts ...RequestOption) (Response, error) {
+	return c.Do(ctx, http.MethodPatch, path, body, out, opts...)
+}
+
+// Delete sends a change to path, decoding the JSON response into out (ignored if nil)
+func (c *Client) Delete(ctx context.Context, path string, out any, opts ...RequestOption) (Response, error) {
+	return c.Do(ctx, http.MethodDelete, path, nil, out, opts...)
+}
+
+// Do sends the request, retrying it on transport errors, 409 Conflict (contention, honoring Retry-After),
+// 429 and 502-504 responses. Changes (any method but GET and HEAD) carry an idempotency key,
+// the same on every attempt, so they are applied at most once by services using idempotency.
+//
+// Error responses are returned as *Problem.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any, opts ...RequestOption) (Response, error) {
+	req := request{header: c.header.Clone()}
+	for _, opt := range opts {
+		opt(&req)
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		if req.idempotencyKey == "" {
+			req.idempotencyKey = c.newIdemToken()
+		}
+		req.header.Set(IdempotencyHeader, req.idempotencyKey)
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return Response{}, fmt.Errorf("encoding request body: %w", err)
+		}
+		req.header.Set("Content-Type", "application/json")
+	}
+	req.header.Set("Accept", "application/json, "+ProblemContentType)
+
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, err := c.send(ctx, method, path, req.header, payload, out)
+		resp.Attempts = attempt
+		if err == nil || !retryable(err) || attempt >= c.maxAttempts {
+			return resp, err
+		}
+
+		wait := c.backoff(attempt, retryAfter)
+		select {
+		case <-ctx.Done():
+			return resp, errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send sends a single attempt, returns the Retry-After of the response (0 if none)
+func (c *Client) send(ctx context.Context, method, path string, header http.Header, payload []byte, out any) (Response, time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return Response{}, 0, err
+	}
+	httpReq.Header = header.Clone()
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return Response{}, 0, &transportError{err: err}
+	}
+	defer httpResp.Body.Close()
+
+	resp := Response{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Position:   httpResp.Header.Get(PositionHeader),
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return resp, 0, &transportError{err: err}
+	}
+
+	if httpResp.StatusCode >= 300 {
+		return resp, retryAfter(httpResp.Header), decodeProblem(httpResp, data)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, 0, fmt.Errorf("decoding response body: %w", err)
+		}
+	}
+	return resp, 0, nil
+}
+
+// backoff is the wait before the retry following attempt: the response's Retry-After if set, else exponential
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := retryAfter
+	if wait <= 0 {
+		wait = time.Duration(float64(c.baseWait) * math.Pow(2, float64(attempt-1)))
+	}
+	return min(wait, c.maxWait)
+}
+
+// transportError is a request that got no response
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// retryable reports whether a failed attempt may succeed when sent again
+func retryable(err error) bool {
+	var te *transportError
+	if errors.As(err, &te) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	var p *Problem
+	if errors.As(err, &p) {
+		switch p.Status {
+		case http.StatusConflict, http.StatusTooManyRequests,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header given in seconds (0 if absent or a date)
+func retryAfter(h http.Header) time.Duration {
+	seconds, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
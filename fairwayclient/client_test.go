@@ -0,0 +1,165 @@
+package fairwayclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway/fairwayclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingServer answers with the given handlers in turn, the last one repeatedly
+type recordingServer struct {
+	mu       sync.Mutex
+	handlers []http.HandlerFunc
+	requests []*http.Request
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	handler := s.handlers[min(len(s.requests), len(s.handlers))-1]
+	s.mu.Unlock()
+	handler(w, r)
+}
+
+func newServer(t *testing.T, handlers ...http.HandlerFunc) (*recordingServer, *fairwayclient.Client) {
+	t.Helper()
+	s := &recordingServer{handlers: handlers}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, fairwayclient.New(srv.URL, fairwayclient.WithRetryWait(time.Millisecond, 10*time.Millisecond))
+}
+
+func conflict(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", fairwayclient.ProblemContentType)
+	w.WriteHeader(http.StatusConflict)
+	_, _ = w.Write([]byte(`{"title":"Conflict","status":409,"detail":"retry later"}`))
+}
+
+func created(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set(fairwayclient.PositionHeader, "AAAAAAAAAAEAAAAA")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(`{"id":"42"}`))
+}
+
+func TestClient_RetriesConflictsWithTheSameIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server, client := newServer(t, conflict, conflict, created)
+
+	// When
+	var out struct{ Id string }
+	resp, err := client.Post(context.Background(), "/users", map[string]string{"name": "alice"}, &out)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, 3, resp.Attempts)
+	assert.Equal(t, "AAAAAAAAAAEAAAAA", resp.Position)
+	assert.Equal(t, "42", out.Id)
+
+	require.Len(t, server.requests, 3)
+	key := server.requests[0].Header.Get(fairwayclient.IdempotencyHeader)
+	assert.NotEmpty(t, key)
+	for _, r := range server.requests {
+		assert.Equal(t, key, r.Header.Get(fairwayclient.IdempotencyHeader))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	}
+}
+
+func TestClient_UsesTheGivenIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server, client := newServer(t, created)
+
+	// When
+	_, err := client.Post(context.Background(), "/users", nil, nil, fairwayclient.WithIdempotencyKey("msg-1"))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, "msg-1", server.requests[0].Header.Get(fairwayclient.IdempotencyHeader))
+}
+
+func TestClient_ReadsDoNotCarryIdempotencyKeys(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server, client := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]int{"count": 3})
+	})
+
+	// When
+	var out struct{ Count int }
+	_, err := client.Get(context.Background(), "/users/count", &out)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 3, out.Count)
+	assert.Empty(t, server.requests[0].Header.Get(fairwayclient.IdempotencyHeader))
+}
+
+func TestClient_ReturnsProblems(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server, client := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", fairwayclient.ProblemContentType)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"title":"Unprocessable Entity","status":422,"detail":"invalid user","errors":{"name":"required"}}`))
+	})
+
+	// When
+	_, err := client.Post(context.Background(), "/users", map[string]string{}, nil)
+
+	// Then
+	var problem *fairwayclient.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "invalid user", problem.Detail)
+	assert.Equal(t, map[string]any{"name": "required"}, problem.Extensions["errors"])
+	assert.Len(t, server.requests, 1, "client errors are not retried")
+}
+
+func TestClient_GivesUpAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server, client := newServer(t, conflict)
+
+	// When
+	resp, err := client.Post(context.Background(), "/users", nil, nil)
+
+	// Then
+	var problem *fairwayclient.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusConflict, problem.Status)
+	assert.Equal(t, 3, resp.Attempts)
+	assert.Len(t, server.requests, 3)
+}
+
+func TestClient_ErrorsWithoutProblemBodyKeepTheirStatus(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	_, client := newServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	// When
+	_, err := client.Get(context.Background(), "/users", nil)
+
+	// Then
+	var problem *fairwayclient.Problem
+	require.ErrorAs(t, err, &problem)
+	assert.Equal(t, http.StatusBadGateway, problem.Status)
+	assert.Equal(t, "Bad Gateway", problem.Error())
+}
@@ -0,0 +1,77 @@
+package fairwayclient
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details error response (fairway.Problem as received by clients)
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are the additional members of the object, e.g. "errors" for invalid fields
+	Extensions map[string]any
+}
+
+func (p *Problem) Error() string {
+	title := p.Title
+	if title == "" {
+		title = http.StatusText(p.Status)
+	}
+	if p.Detail == "" {
+		return title
+	}
+	return title + ": " + p.Detail
+}
+
+// UnmarshalJSON reads the standard members, the others are kept as extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	standard := map[string]any{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+	for name, raw := range members {
+		if target, ok := standard[name]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return err
+			}
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any)
+		}
+		p.Extensions[name] = value
+	}
+	return nil
+}
+
+// decodeProblem returns the problem described by an error response.
+// Responses that are not problem+json (e.g. from a proxy) get a problem with their status only.
+func decodeProblem(resp *http.Response, body []byte) *Problem {
+	p := &Problem{}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == ProblemContentType {
+		if err := json.Unmarshal(body, p); err != nil {
+			p = &Problem{}
+		}
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	return p
+}
@@ -0,0 +1,31 @@
+package featureflag
+
+import (
+	"context"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ChangeHandler reacts to a flag being turned on or off (e.g. warming a cache, notifying a channel)
+type ChangeHandler func(ctx context.Context, flag string, enabled bool) error
+
+// NewChangeAutomation returns an automation calling onChange once per change of a flag, in any process,
+// with the guarantees of automations: retries, DLQ, one delivery per queueId across instances.
+// Start it like any other automation.
+func NewChangeAutomation(store dcb.DcbStore, queueId string, onChange ChangeHandler, opts ...fairway.AutomationOption[ChangeHandler]) (*fairway.Automation[ChangeHandler], error) {
+	return fairway.NewAutomation(store, onChange, queueId, FlagSet{},
+		func(ev fairway.Event) fairway.CommandWithEffect[ChangeHandler] {
+			return notifyCommand{set: ev.Data.(FlagSet)}
+		},
+		opts...,
+	)
+}
+
+type notifyCommand struct {
+	set FlagSet
+}
+
+func (cmd notifyCommand) Run(ctx context.Context, _ fairway.EventReadAppenderExtended, onChange ChangeHandler) error {
+	return onChange(ctx, cmd.set.Flag, cmd.set.Enabled)
+}
@@ -0,0 +1,55 @@
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/err0r500/fairway"
+)
+
+// ErrInvalidFlag is returned for flag names that aren't 1 to 128 letters, digits, '.', '_' or '-'
+var ErrInvalidFlag = errors.New("invalid feature flag name")
+
+var flagName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+func validateFlag(flag string) error {
+	if !flagName.MatchString(flag) {
+		return fmt.Errorf("%w: %q", ErrInvalidFlag, flag)
+	}
+	return nil
+}
+
+// Enable returns the command turning flag on
+func Enable(flag string) fairway.Command {
+	return setCommand{flag: flag, enabled: true}
+}
+
+// Disable returns the command turning flag off
+func Disable(flag string) fairway.Command {
+	return setCommand{flag: flag, enabled: false}
+}
+
+type setCommand struct {
+	flag    string
+	enabled bool
+}
+
+// Run appends a FlagSet unless the flag is already in the requested state,
+// so that change automations only see actual changes
+func (cmd setCommand) Run(ctx context.Context, ev fairway.EventReadAppender) error {
+	if err := validateFlag(cmd.flag); err != nil {
+		return err
+	}
+
+	enabled, err := readFlag(ctx, ev, cmd.flag)
+	if err != nil {
+		return err
+	}
+	if enabled == cmd.enabled {
+		return nil
+	}
+
+	return ev.AppendEvents(ctx, fairway.NewEvent(FlagSet{Flag: cmd.flag, Enabled: cmd.enabled}))
+}
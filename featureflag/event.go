@@ -0,0 +1,24 @@
+package featureflag
+
+import "github.com/err0r500/fairway/dcb"
+
+var flagTag = dcb.TagKey("feature_flag")
+
+// FlagTag is the tag of the events of flag
+func FlagTag(flag string) string {
+	return flagTag.Equals(flag)
+}
+
+// FlagSet records that a flag was turned on or off: the latest one of a flag is its state.
+// A flag never set is off.
+type FlagSet struct {
+	Flag    string `json:"flag"`
+	Enabled bool   `json:"enabled"`
+}
+
+// TypeString names the event after this package, so that it doesn't collide with the application's events
+func (FlagSet) TypeString() string { return "featureflag.FlagSet" }
+
+func (e FlagSet) Tags() []string {
+	return []string{FlagTag(e.Flag)}
+}
@@ -0,0 +1,118 @@
+package featureflag_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/featureflag"
+	"github.com/err0r500/fairway/testing/given"
+	"github.com/err0r500/fairway/testing/then"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommands_OnlyRecordChanges(t *testing.T) {
+	t.Parallel()
+	store, server, httpClient := given.FreshSetup(t, featureflag.RegisterCommands)
+
+	// Given
+	given.EventsInStore(store, fairway.NewEvent(featureflag.FlagSet{Flag: "new-checkout", Enabled: true}))
+
+	// When - the flag is enabled again, then disabled twice
+	for _, action := range []string{"enable", "disable", "disable"} {
+		resp, err := httpClient.R().Post(server.URL + "/feature-flags/new-checkout/" + action)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+	}
+
+	// Then
+	then.ExpectEventsInStore(t, store,
+		fairway.NewEvent(featureflag.FlagSet{Flag: "new-checkout", Enabled: true}),
+		fairway.NewEvent(featureflag.FlagSet{Flag: "new-checkout", Enabled: false}),
+	)
+}
+
+func TestCommands_RejectInvalidNames(t *testing.T) {
+	t.Parallel()
+	store, server, httpClient := given.FreshSetup(t, featureflag.RegisterCommands)
+
+	// When
+	resp, err := httpClient.R().Post(server.URL + "/feature-flags/new%20checkout/enable")
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	then.ExpectEventsInStore(t, store)
+}
+
+func TestViews_ReturnTheLatestState(t *testing.T) {
+	t.Parallel()
+	store, server, httpClient := given.FreshSetup(t, featureflag.RegisterViews)
+
+	// Given
+	given.EventsInStore(store,
+		fairway.NewEvent(featureflag.FlagSet{Flag: "new-checkout", Enabled: true}),
+		fairway.NewEvent(featureflag.FlagSet{Flag: "dark-mode", Enabled: true}),
+		fairway.NewEvent(featureflag.FlagSet{Flag: "new-checkout", Enabled: false}),
+	)
+
+	// When
+	var all featureflag.FlagsResponse
+	allResp, err := httpClient.R().SetResult(&all).Get(server.URL + "/feature-flags")
+	require.NoError(t, err)
+	var darkMode, unknown featureflag.FlagResponse
+	_, err = httpClient.R().SetResult(&darkMode).Get(server.URL + "/feature-flags/dark-mode")
+	require.NoError(t, err)
+	_, err = httpClient.R().SetResult(&unknown).Get(server.URL + "/feature-flags/unknown")
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, http.StatusOK, allResp.StatusCode())
+	assert.Equal(t, map[string]bool{"new-checkout": false, "dark-mode": true}, all.Flags)
+	assert.Equal(t, featureflag.FlagResponse{Flag: "dark-mode", Enabled: true}, darkMode)
+	assert.Equal(t, featureflag.FlagResponse{Flag: "unknown", Enabled: false}, unknown)
+}
+
+func TestChangeAutomation_NotifiesChanges(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given
+	store := given.SetupTestStore(t)
+	var mu sync.Mutex
+	var changes []featureflag.FlagSet
+	automation, err := featureflag.NewChangeAutomation(store, "flag-changes",
+		func(_ context.Context, flag string, enabled bool) error {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, featureflag.FlagSet{Flag: flag, Enabled: enabled})
+			return nil
+		},
+		fairway.WithPollInterval[featureflag.ChangeHandler](10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.NoError(t, automation.Start(ctx))
+	t.Cleanup(func() {
+		automation.Stop()
+		_ = automation.Wait()
+	})
+
+	// When
+	runner := fairway.NewCommandRunner(store)
+	require.NoError(t, runner.RunPure(ctx, featureflag.Enable("dark-mode")))
+	require.NoError(t, runner.RunPure(ctx, featureflag.Enable("dark-mode")))
+
+	// Then - a single change was recorded, and notified
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []featureflag.FlagSet{{Flag: "dark-mode", Enabled: true}}, changes)
+}
@@ -0,0 +1,55 @@
+package featureflag
+
+import (
+	"context"
+
+	"github.com/err0r500/fairway"
+)
+
+// Flags reads the state of the feature flags from the event store.
+// Flags are checked on hot paths: wrap the reader with fairway.NewCachedReader to serve
+// them from memory, at the cost of seeing changes up to its ttl late.
+type Flags struct {
+	reader fairway.EventsReader
+}
+
+// NewFlags returns the flags read through reader
+func NewFlags(reader fairway.EventsReader) Flags {
+	return Flags{reader: reader}
+}
+
+// IsEnabled tells whether flag is on, unknown flags are off
+func (f Flags) IsEnabled(ctx context.Context, flag string) (bool, error) {
+	if err := validateFlag(flag); err != nil {
+		return false, err
+	}
+	return readFlag(ctx, f.reader, flag)
+}
+
+// All returns the state of every flag ever set
+func (f Flags) All(ctx context.Context) (map[string]bool, error) {
+	return fairway.FoldView(ctx, f.reader,
+		fairway.QueryItems(fairway.NewQueryItem().Types(FlagSet{})),
+		map[string]bool{},
+		func(flags map[string]bool, e fairway.Event) map[string]bool {
+			if set, ok := e.Data.(FlagSet); ok {
+				flags[set.Flag] = set.Enabled
+			}
+			return flags
+		})
+}
+
+// readFlag returns the state of flag: that of its latest event
+func readFlag(ctx context.Context, reader fairway.EventsReader, flag string) (bool, error) {
+	enabled := false
+	err := reader.ReadEvents(ctx,
+		fairway.QueryItems(fairway.NewQueryItem().Types(FlagSet{}).Tags(FlagTag(flag))),
+		func(e fairway.Event) bool {
+			if set, ok := e.Data.(FlagSet); ok {
+				enabled = set.Enabled
+			}
+			return false
+		},
+		fairway.ReadLatest(1))
+	return enabled, err
+}
@@ -0,0 +1,94 @@
+package featureflag
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/err0r500/fairway"
+)
+
+// FlagResponse is the body of GET /feature-flags/{flag}
+type FlagResponse struct {
+	Flag    string `json:"flag"`
+	Enabled bool   `json:"enabled"`
+}
+
+// FlagsResponse is the body of GET /feature-flags
+type FlagsResponse struct {
+	Flags map[string]bool `json:"flags"`
+}
+
+// RegisterCommands registers the endpoints turning flags on and off:
+//   - POST /feature-flags/{flag}/enable
+//   - POST /feature-flags/{flag}/disable
+//
+// Both answer 204 No Content, even when the flag already was in the requested state.
+// Mount them behind the application's admin authentication.
+func RegisterCommands(registry *fairway.HttpChangeRegistry) {
+	registry.RegisterCommand("POST /feature-flags/{flag}/enable", setHandler(Enable),
+		fairway.WithRouteSummary("turns a feature flag on"),
+		fairway.WithRouteCommands(setCommand{}),
+	)
+	registry.RegisterCommand("POST /feature-flags/{flag}/disable", setHandler(Disable),
+		fairway.WithRouteSummary("turns a feature flag off"),
+		fairway.WithRouteCommands(setCommand{}),
+	)
+}
+
+// RegisterViews registers the endpoints reading the flags:
+//   - GET /feature-flags: the state of every flag ever set (FlagsResponse)
+//   - GET /feature-flags/{flag}: the state of a flag, off if never set (FlagResponse)
+func RegisterViews(registry *fairway.HttpViewRegistry) {
+	registry.RegisterView("GET /feature-flags", listHandler)
+	registry.RegisterView("GET /feature-flags/{flag}", getHandler)
+}
+
+func setHandler(command func(flag string) fairway.Command) func(fairway.CommandRunner) http.HandlerFunc {
+	return func(runner fairway.CommandRunner) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := runner.RunPure(r.Context(), command(r.PathValue("flag"))); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func listHandler(reader fairway.EventsReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flags, err := NewFlags(reader).All(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, FlagsResponse{Flags: flags})
+	}
+}
+
+func getHandler(reader fairway.EventsReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flag := r.PathValue("flag")
+		enabled, err := NewFlags(reader).IsEnabled(r.Context(), flag)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, FlagResponse{Flag: flag, Enabled: enabled})
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidFlag) {
+		fairway.WriteProblem(w, fairway.NewProblem(http.StatusBadRequest, err.Error()))
+		return
+	}
+	fairway.WriteError(w, err)
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,203 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrIdempotencyKeyInProgress is returned by IdempotencyStore.Claim while another execution holds the key
+	ErrIdempotencyKeyInProgress = errors.New("idempotency key in progress")
+	// ErrIdempotencyTimeout is returned when the execution holding the key did not complete in time
+	ErrIdempotencyTimeout = errors.New("timed out waiting for idempotency key")
+	// ErrNoIdempotencyStore is returned by RunPureIdempotent when the runner has no IdempotencyStore
+	ErrNoIdempotencyStore = errors.New("no idempotency store configured")
+)
+
+const (
+	// idempotencyWaitTimeout bounds how long a duplicate waits for the execution holding the key
+	idempotencyWaitTimeout = 10 * time.Second
+	// idempotencyPollInterval is the delay between two claims of a key held by another execution
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// IdempotencyStore records the idempotency keys of the commands already applied,
+// so retried requests, redelivered messages or re-run CLI invocations don't apply them twice.
+// Each execution claims the key with its own token: only the execution holding the claim can complete or release it.
+type IdempotencyStore interface {
+	// Claim reserves key for the execution of token, returns false if key was already completed
+	// and ErrIdempotencyKeyInProgress while another execution holds it
+	Claim(ctx context.Context, key string, token uuid.UUID) (bool, error)
+	// CompleteInTx marks key as applied within tr, typically the transaction appending the command's events.
+	// It fails with ErrClaimLost if token doesn't hold the key anymore.
+	CompleteInTx(tr fdb.Transaction, key string, token uuid.UUID) error
+	// Complete marks key as applied, for commands appending nothing
+	Complete(ctx context.Context, key string, token uuid.UUID) error
+	// Release frees the key of a failed execution, so it can be retried
+	Release(ctx context.Context, key string, token uuid.UUID) error
+	// Purge removes the keys completed before the given time, and returns how many it removed
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// fdbIdempotencyStore stores idempotency keys alongside the store's events
+type fdbIdempotencyStore struct {
+	log claimLog // namespace/idempotency
+}
+
+// IdempotencyStoreOption configures the store returned by NewIdempotencyStore
+type IdempotencyStoreOption func(*fdbIdempotencyStore)
+
+// WithIdempotencyClaimTTL lets a key claimed more than d ago be claimed again
+// (the execution holding it probably crashed). By default, a claim is held until completed or released.
+func WithIdempotencyClaimTTL(d time.Duration) IdempotencyStoreOption {
+	return func(s *fdbIdempotencyStore) {
+		if d > 0 {
+			s.log.ttl = d
+		}
+	}
+}
+
+// NewIdempotencyStore creates an idempotency store in the store's namespace.
+// Keys are completed in the transactions appending the commands' events: the store must be on their cluster.
+func NewIdempotencyStore(store dcb.DcbStore, opts ...IdempotencyStoreOption) IdempotencyStore {
+	s := &fdbIdempotencyStore{log: claimLog{
+		db:   store.Database(),
+		dir:  subspace.Sub(store.Namespace() + "/idempotency"),
+		held: ErrIdempotencyKeyInProgress,
+		now:  time.Now,
+	}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *fdbIdempotencyStore) key(key string) fdb.Key {
+	return s.log.dir.Pack(tuple.Tuple{key})
+}
+
+func (s *fdbIdempotencyStore) Claim(ctx context.Context, key string, token uuid.UUID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.log.claim(s.key(key), token)
+}
+
+func (s *fdbIdempotencyStore) CompleteInTx(tr fdb.Transaction, key string, token uuid.UUID) error {
+	return s.log.doneInTx(tr, s.key(key), token)
+}
+
+func (s *fdbIdempotencyStore) Complete(ctx context.Context, key string, token uuid.UUID) error {
+	return s.log.done(s.key(key), token)
+}
+
+func (s *fdbIdempotencyStore) Release(ctx context.Context, key string, token uuid.UUID) error {
+	return s.log.release(s.key(key), token)
+}
+
+func (s *fdbIdempotencyStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	return s.log.purge(ctx, before)
+}
+
+type idempotencyKeyCtxKey struct{}
+
+// WithIdempotencyKey returns ctx carrying the idempotency key of the request or message being handled
+// (set by utils.IdempotencyMiddleware and RunPureIdempotent). Commands run with it stamp the key
+// on the events they append (see Event.IdempotencyKey), so the causal chain can be audited.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtxKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key set by WithIdempotencyKey, e.g. to deduplicate
+// a command with RunPureIdempotent under the key of the HTTP request
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyCtxKey{}).(string)
+	return key, ok && key != ""
+}
+
+// stampIdempotencyKey sets the context's idempotency key on the events not carrying one
+func stampIdempotencyKey(ctx context.Context, events []Event) []Event {
+	key, ok := IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return events
+	}
+	for i := range events {
+		if events[i].IdempotencyKey == "" {
+			events[i].IdempotencyKey = key
+		}
+	}
+	return events
+}
+
+// runIdempotent runs run once per key: duplicates of a completed key return nil without running,
+// concurrent duplicates wait for the execution holding the key. A failed run releases the key.
+// An empty key runs without deduplication. run gets ctx carrying the key (see WithIdempotencyKey).
+//
+// The key is completed in the transaction of the first append of run, so the events and the key commit together
+// (or in a transaction of its own when run appends nothing). An execution whose claim was taken over
+// (see WithIdempotencyClaimTTL) can't append anymore: its appends fail with ErrClaimLost.
+func runIdempotent(ctx context.Context, store IdempotencyStore, key string, run func(ctx context.Context) error) error {
+	if key == "" {
+		return run(ctx)
+	}
+	if store == nil {
+		return ErrNoIdempotencyStore
+	}
+
+	token := uuid.New()
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		claimed, err := store.Claim(ctx, key, token)
+		if errors.Is(err, ErrIdempotencyKeyInProgress) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%w %q", ErrIdempotencyTimeout, key)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(idempotencyPollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("claiming idempotency key %q: %w", key, err)
+		}
+		if !claimed {
+			return nil // already applied
+		}
+		break
+	}
+
+	var completed atomic.Bool
+	ctx = dcb.WithTransactionHook(WithIdempotencyKey(ctx, key), dcb.TransactionHook{
+		Write:     func(tr fdb.Transaction) error { return store.CompleteInTx(tr, key, token) },
+		Committed: func() { completed.Store(true) },
+	})
+
+	if err := run(ctx); err != nil {
+		if completed.Load() {
+			return err // events committed with the key: the command is applied, a retry must not run it again
+		}
+		if releaseErr := store.Release(ctx, key, token); releaseErr != nil {
+			return errors.Join(err, fmt.Errorf("releasing idempotency key %q: %w", key, releaseErr))
+		}
+		return err
+	}
+
+	if completed.Load() {
+		return nil
+	}
+	if err := store.Complete(ctx, key, token); err != nil {
+		return fmt.Errorf("completing idempotency key %q: %w", key, err)
+	}
+	return nil
+}
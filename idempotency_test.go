@@ -0,0 +1,243 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPureIdempotent_AppliesCommandOnce(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+	})
+
+	// When - the same command is delivered twice
+	require.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+	require.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+
+	// Then
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 1)
+}
+
+// readIdempotencyKeys returns the idempotency keys of the stored PageItem events
+func readIdempotencyKeys(t *testing.T, store dcb.DcbStore) []string {
+	var keys []string
+	err := fairway.NewReader(store).ReadEvents(t.Context(),
+		fairway.QueryItems(fairway.NewQueryItem().Types(PageItem{})),
+		func(e fairway.Event) bool {
+			keys = append(keys, e.IdempotencyKey)
+			return true
+		})
+	require.NoError(t, err)
+	return keys
+}
+
+func TestRunPureIdempotent_StampsTheKeyOnAppendedEvents(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+	})
+
+	// When
+	require.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+
+	// Then
+	assert.Equal(t, []string{"order-42"}, readIdempotencyKeys(t, store))
+}
+
+func TestRunPureIdempotent_StoresTheKeyInTheEventMetadata(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+	})
+
+	// When
+	require.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+
+	// Then - the key is a header of the event, not part of its payload
+	stored := dcb.CollectEvents(t, store.ReadAll(context.Background()))
+	require.Len(t, stored, 1)
+	assert.Equal(t, map[string]string{fairway.IdempotencyKeyMetadata: "order-42"}, stored[0].Metadata)
+	assert.NotContains(t, string(stored[0].Data), "order-42")
+}
+
+func TestWithIdempotencyKey_StampsEventsNotCarryingOne(t *testing.T) {
+	t.Parallel()
+
+	// Given - a request handled under a key, appending an event with its own key
+	store := dcb.SetupTestStore(t)
+	ctx := fairway.WithIdempotencyKey(t.Context(), "request-1")
+	own := fairway.NewEvent(PageItem{N: 2})
+	own.IdempotencyKey = "upstream-7"
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}), own)
+	})
+
+	// When
+	require.NoError(t, fairway.NewCommandRunner(store).RunPure(ctx, cmd))
+
+	// Then
+	assert.Equal(t, []string{"request-1", "upstream-7"}, readIdempotencyKeys(t, store))
+}
+
+func TestRunPureIdempotent_FailedCommandCanBeRetried(t *testing.T) {
+	t.Parallel()
+
+	// Given - a command failing the first time
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	errRejected := errors.New("rejected")
+	calls := 0
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		calls++
+		if calls == 1 {
+			return errRejected
+		}
+		return nil
+	})
+
+	// When
+	firstErr := runner.RunPureIdempotent(t.Context(), "order-42", cmd)
+	secondErr := runner.RunPureIdempotent(t.Context(), "order-42", cmd)
+
+	// Then
+	assert.ErrorIs(t, firstErr, errRejected)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRunPureIdempotent_ConcurrentDuplicateWaitsForTheFirstExecution(t *testing.T) {
+	t.Parallel()
+
+	// Given - a first execution stuck inside the command
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls int
+	var mu sync.Mutex
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+		return nil
+	})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+	}()
+	<-started
+
+	// When
+	duplicate := make(chan error, 1)
+	go func() { duplicate <- runner.RunPureIdempotent(t.Context(), "order-42", cmd) }()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Then - the duplicate returned once the first execution completed, without running
+	require.NoError(t, <-duplicate)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRunPureIdempotent_KeyCommitsWithTheEvents(t *testing.T) {
+	t.Parallel()
+
+	// Given - a command failing after its append committed
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(fairway.NewIdempotencyStore(store)))
+	errAfterAppend := errors.New("failed after append")
+	calls := 0
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		calls++
+		if err := ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1})); err != nil {
+			return err
+		}
+		return errAfterAppend
+	})
+
+	// When
+	firstErr := runner.RunPureIdempotent(t.Context(), "order-42", cmd)
+	secondErr := runner.RunPureIdempotent(t.Context(), "order-42", cmd)
+
+	// Then - the key completed with the append: the retry doesn't apply the command again
+	assert.ErrorIs(t, firstErr, errAfterAppend)
+	assert.NoError(t, secondErr)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 1)
+}
+
+func TestRunPureIdempotent_TakenOverExecutionCannotAppend(t *testing.T) {
+	t.Parallel()
+
+	// Given - a first execution stalling past the claim TTL
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store, fairway.WithIdempotencyStore(
+		fairway.NewIdempotencyStore(store, fairway.WithIdempotencyClaimTTL(50*time.Millisecond))))
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls int
+	var mu sync.Mutex
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+	})
+	first := make(chan error, 1)
+	go func() { first <- runner.RunPureIdempotent(t.Context(), "order-42", cmd) }()
+	<-started
+	time.Sleep(100 * time.Millisecond)
+
+	// When - a duplicate takes the expired claim over, then the first execution resumes
+	require.NoError(t, runner.RunPureIdempotent(t.Context(), "order-42", cmd))
+	close(release)
+
+	// Then
+	assert.ErrorIs(t, <-first, fairway.ErrClaimLost)
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 1)
+}
+
+func TestRunPureIdempotent_RequiresAStore(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	runner := fairway.NewCommandRunner(&mockStore{})
+	cmd := commandFunc(func(context.Context, fairway.EventReadAppender) error { return nil })
+
+	// When
+	err := runner.RunPureIdempotent(t.Context(), "order-42", cmd)
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrNoIdempotencyStore)
+}
@@ -0,0 +1,343 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownComponent is returned when no component of a lifecycle or registry has the queue id
+	ErrUnknownComponent = errors.New("unknown component")
+	// ErrLifecycleStopped is returned when starting a component of a stopped lifecycle
+	ErrLifecycleStopped = errors.New("lifecycle stopped")
+)
+
+// readyPollInterval is how often Lifecycle.Ready re-checks component cursors
+const readyPollInterval = 50 * time.Millisecond
+
+// ComponentStatus is a point-in-time view of a started component
+type ComponentStatus struct {
+	QueueId   string
+	Running   bool        // run loops alive
+	CaughtUp  bool        // cursor reached the last matching event
+	LastError error       // last error reported by the component
+	Cursor    *CursorInfo // nil for components without cursor
+}
+
+// Lifecycle is the handle returned by StartAll: it stops the components,
+// reports readiness and aggregates their background errors.
+// Components are keyed by queue id, to be listed, stopped and restarted individually.
+type Lifecycle struct {
+	errs *errorReporter // the components' errors, on the Err channel
+	wg   sync.WaitGroup
+
+	mu         sync.Mutex
+	components []*lifecycleComponent // in start order
+	lastErrors map[string]error
+	stopped    bool
+}
+
+// lifecycleComponent is a started component and the factory rebuilding it on restart
+type lifecycleComponent struct {
+	Startable
+	factory  ComponentFactory // nil = can't be restarted
+	running  bool             // not stopped through the lifecycle
+	starting bool             // being rebuilt by StartComponent
+}
+
+// newLifecycle creates a lifecycle without components
+func newLifecycle() *Lifecycle {
+	l := &Lifecycle{
+		errs:       newErrorReporter("lifecycle"),
+		lastErrors: make(map[string]error),
+	}
+	l.errs.withChannel(100)
+	return l
+}
+
+// handleErrors relays the errors of c to the Err channel when c is an ErrorReporter.
+// Called before c starts, without mu held: c may report errors while starting.
+func (l *Lifecycle) handleErrors(c Startable) {
+	if reporter, ok := c.(ErrorReporter); ok {
+		reporter.OnError(l.recordError(c.QueueId()))
+	}
+}
+
+// drainErrors relays the Errors channel of c, started, until c is stopped, when c is not an ErrorReporter.
+// Called with mu held (or before sharing l).
+func (l *Lifecycle) drainErrors(c Startable) {
+	if _, ok := c.(ErrorReporter); ok {
+		return
+	}
+	withErrors, ok := c.(interface{ Errors() <-chan error })
+	if !ok {
+		return
+	}
+	record := l.recordError(c.QueueId())
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		for err := range withErrors.Errors() {
+			record(err)
+		}
+	}()
+}
+
+// recordError returns the handler keeping the last error of the component and sending it on the Err channel
+func (l *Lifecycle) recordError(queueId string) ErrorHandler {
+	return func(err error) {
+		l.mu.Lock()
+		l.lastErrors[queueId] = err
+		l.mu.Unlock()
+		l.errs.report(err)
+	}
+}
+
+// Stop stops every component, waits for them and closes the Err channel. Safe to call twice.
+func (l *Lifecycle) Stop() {
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return
+	}
+	l.stopped = true
+	var running []Startable
+	for _, c := range l.components {
+		if c.running {
+			running = append(running, c.Startable)
+			c.running = false
+		}
+	}
+	l.mu.Unlock()
+
+	for _, c := range running {
+		c.Stop()
+	}
+	for _, c := range running {
+		c.Wait()
+	}
+	l.wg.Wait()
+	l.errs.close()
+}
+
+// List returns the queue ids of the components, in start order
+func (l *Lifecycle) List() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids := make([]string, len(l.components))
+	for i, c := range l.components {
+		ids[i] = c.QueueId()
+	}
+	return ids
+}
+
+// Get returns the current instance of the component with queueId
+func (l *Lifecycle) Get(queueId string) (Startable, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.component(queueId)
+	if c == nil {
+		return nil, false
+	}
+	return c.Startable, true
+}
+
+// StopComponent stops the component with queueId and waits for it, the others keep running.
+// Stopping a stopped component is a no-op.
+func (l *Lifecycle) StopComponent(queueId string) error {
+	l.mu.Lock()
+	c := l.component(queueId)
+	if c == nil {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrUnknownComponent, queueId)
+	}
+	if !c.running {
+		l.mu.Unlock()
+		return nil
+	}
+	c.running = false
+	instance := c.Startable
+	l.mu.Unlock()
+
+	instance.Stop()
+	return instance.Wait()
+}
+
+// StartComponent restarts the component with queueId, stopped by StopComponent, from a fresh instance.
+// Starting a running (or starting) component is a no-op.
+// The instance is built and started without holding mu: its errors can be reported while it starts.
+func (l *Lifecycle) StartComponent(ctx context.Context, queueId string) error {
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return ErrLifecycleStopped
+	}
+	c := l.component(queueId)
+	if c == nil {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrUnknownComponent, queueId)
+	}
+	if c.running || c.starting {
+		l.mu.Unlock()
+		return nil
+	}
+	if c.factory == nil {
+		l.mu.Unlock()
+		return fmt.Errorf("component %q can't be restarted: no factory", queueId)
+	}
+	c.starting = true
+	delete(l.lastErrors, queueId)
+	l.mu.Unlock()
+
+	instance, err := l.startInstance(ctx, c.factory, queueId)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.starting = false
+	if err != nil {
+		return err
+	}
+	if l.stopped {
+		// stopped while starting: Stop didn't see the instance
+		instance.Stop()
+		_ = instance.Wait()
+		return ErrLifecycleStopped
+	}
+	c.Startable = instance
+	c.running = true
+	l.drainErrors(instance)
+	return nil
+}
+
+// startInstance builds an instance of the component with queueId and starts it, its errors relayed
+func (l *Lifecycle) startInstance(ctx context.Context, factory ComponentFactory, queueId string) (Startable, error) {
+	instance, err := factory()
+	if err != nil {
+		return nil, err
+	}
+	if instance.QueueId() != queueId {
+		return nil, fmt.Errorf("component %q rebuilt with queueId %q", queueId, instance.QueueId())
+	}
+	l.handleErrors(instance)
+	if err := instance.Start(ctx); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// component returns the component with queueId, nil if unknown. Called with mu held.
+func (l *Lifecycle) component(queueId string) *lifecycleComponent {
+	for _, c := range l.components {
+		if c.QueueId() == queueId {
+			return c
+		}
+	}
+	return nil
+}
+
+// snapshot returns the current instances of the components, with their running state
+func (l *Lifecycle) snapshot() ([]Startable, []bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	instances := make([]Startable, len(l.components))
+	running := make([]bool, len(l.components))
+	for i, c := range l.components {
+		instances[i], running[i] = c.Startable, c.running
+	}
+	return instances, running
+}
+
+// Err returns the aggregated background errors of all components.
+// Errors are dropped when nobody reads it and its buffer is full (see ErrorStats): prefer OnError.
+func (l *Lifecycle) Err() <-chan error {
+	return l.errs.channel()
+}
+
+// OnError adds a handler of the background errors of all components
+func (l *Lifecycle) OnError(h ErrorHandler) {
+	l.errs.OnError(h)
+}
+
+// ErrorStats counts the background errors of all components, and those the Err channel dropped
+func (l *Lifecycle) ErrorStats() ErrorStats {
+	return l.errs.ErrorStats()
+}
+
+// Ready blocks until every component cursor has caught up with the event log, or ctx is done
+func (l *Lifecycle) Ready(ctx context.Context) error {
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ready := true
+		instances, running := l.snapshot()
+		for i, c := range instances {
+			cu, ok := c.(interface{ CaughtUp() (bool, error) })
+			if !ok || !running[i] {
+				continue // stopped components don't hold readiness back
+			}
+			caughtUp, err := cu.CaughtUp()
+			if err != nil {
+				return err
+			}
+			if !caughtUp {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the current status of every component, in start order
+func (l *Lifecycle) Status() []ComponentStatus {
+	instances, running := l.snapshot()
+	l.mu.Lock()
+	lastErrors := maps.Clone(l.lastErrors)
+	l.mu.Unlock()
+
+	statuses := make([]ComponentStatus, len(instances))
+	for i, c := range instances {
+		st := ComponentStatus{
+			QueueId:   c.QueueId(),
+			Running:   running[i],
+			CaughtUp:  true,
+			LastError: lastErrors[c.QueueId()],
+		}
+		if r, ok := c.(interface{ Running() bool }); ok && st.Running {
+			st.Running = r.Running()
+		}
+		if cur, ok := c.(interface{ Cursor() (CursorInfo, error) }); ok {
+			info, err := cur.Cursor()
+			if err != nil {
+				st.LastError = err
+			} else {
+				st.Cursor = &info
+			}
+		}
+		if cu, ok := c.(interface{ CaughtUp() (bool, error) }); ok {
+			caughtUp, err := cu.CaughtUp()
+			st.CaughtUp = caughtUp
+			if err != nil {
+				st.LastError = err
+			}
+		}
+		statuses[i] = st
+	}
+	return statuses
+}
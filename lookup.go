@@ -0,0 +1,83 @@
+package fairway
+
+import (
+	"context"
+	"sync"
+)
+
+// Lookup reads reference data kept outside the event log (a product catalog, exchange rates...),
+// for views joining it with events. See ReferenceData for reference data stored in FoundationDB,
+// StaticLookup for tests, and Pin to call a lookup from fold and read handlers.
+type Lookup[V any] interface {
+	// Get returns the value of key, false if there is none
+	Get(ctx context.Context, key string) (V, bool, error)
+}
+
+// LookupFunc adapts a function to Lookup (e.g. a call to another service)
+type LookupFunc[V any] func(ctx context.Context, key string) (V, bool, error)
+
+func (f LookupFunc[V]) Get(ctx context.Context, key string) (V, bool, error) { return f(ctx, key) }
+
+// StaticLookup returns a lookup serving values, for tests and fixed reference data
+func StaticLookup[V any](values map[string]V) Lookup[V] {
+	return LookupFunc[V](func(_ context.Context, key string) (V, bool, error) {
+		v, ok := values[key]
+		return v, ok, nil
+	})
+}
+
+// PinnedLookup is a lookup bound to a single read, see Pin
+type PinnedLookup[V any] struct {
+	ctx    context.Context
+	lookup Lookup[V]
+
+	mu     sync.Mutex
+	values map[string]pinnedValue[V]
+	err    error
+}
+
+type pinnedValue[V any] struct {
+	value V
+	found bool
+}
+
+// Pin returns lookup bound to ctx for the duration of one read, e.g. a FoldView:
+//   - each key is looked up once: every event of the read sees the same value, even if the
+//     reference data changes meanwhile, so that the view is a deterministic function of its events
+//     and of one version of the data it joins;
+//   - Get doesn't return errors, which fold and read handlers can't report: the first one is kept
+//     for Err, and later Gets return zero values.
+//
+// Check Err once the read returns.
+func Pin[V any](ctx context.Context, lookup Lookup[V]) *PinnedLookup[V] {
+	return &PinnedLookup[V]{ctx: ctx, lookup: lookup, values: make(map[string]pinnedValue[V])}
+}
+
+// Get returns the value of key, false if there is none or the lookup failed (see Err)
+func (p *PinnedLookup[V]) Get(key string) (V, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		var zero V
+		return zero, false
+	}
+	if v, ok := p.values[key]; ok {
+		return v.value, v.found
+	}
+
+	value, found, err := p.lookup.Get(p.ctx, key)
+	if err != nil {
+		p.err = err
+		var zero V
+		return zero, false
+	}
+	p.values[key] = pinnedValue[V]{value: value, found: found}
+	return value, found
+}
+
+// Err returns the first error of the lookup, the read must then be considered failed
+func (p *PinnedLookup[V]) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
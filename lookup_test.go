@@ -0,0 +1,128 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// priceOf joins the page items with a price list keyed by their N
+func priceOf(prices *fairway.PinnedLookup[int]) func(int, fairway.Event) int {
+	return func(total int, e fairway.Event) int {
+		item, ok := e.Data.(PageItem)
+		if !ok {
+			return total
+		}
+		price, _ := prices.Get(strconv.Itoa(item.N))
+		return total + price
+	}
+}
+
+func TestPin_JoinsReferenceDataInAFold(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendPageItems(t, store, 3)
+	appendPageItems(t, store, 1) // a second N=0
+	calls := 0
+	prices := fairway.LookupFunc[int](func(ctx context.Context, key string) (int, bool, error) {
+		calls++
+		return map[string]int{"0": 10, "1": 20}[key] + calls*1000, true, nil // changes on every call
+	})
+
+	// When
+	pinned := fairway.Pin[int](context.Background(), prices)
+	total, err := fairway.FoldView(context.Background(), fairway.NewReader(store), pageItemsQuery(), 0, priceOf(pinned))
+
+	// Then - each key was looked up once: both N=0 items got the same price
+	require.NoError(t, err)
+	require.NoError(t, pinned.Err())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2*1010+2020+3000, total)
+}
+
+func TestPin_KeepsTheFirstError(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendPageItems(t, store, 2)
+	unavailable := errors.New("catalog unavailable")
+	prices := fairway.LookupFunc[int](func(context.Context, string) (int, bool, error) {
+		return 0, false, unavailable
+	})
+
+	// When
+	pinned := fairway.Pin[int](context.Background(), prices)
+	_, err := fairway.FoldView(context.Background(), fairway.NewReader(store), pageItemsQuery(), 0, priceOf(pinned))
+
+	// Then
+	require.NoError(t, err)
+	assert.ErrorIs(t, pinned.Err(), unavailable)
+}
+
+func TestStaticLookup(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	lookup := fairway.StaticLookup(map[string]int{"a": 1})
+
+	// When
+	a, aFound, aErr := lookup.Get(context.Background(), "a")
+	_, bFound, bErr := lookup.Get(context.Background(), "b")
+
+	// Then
+	require.NoError(t, aErr)
+	require.NoError(t, bErr)
+	assert.Equal(t, 1, a)
+	assert.True(t, aFound)
+	assert.False(t, bFound)
+}
+
+type product struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func TestReferenceData_CachesUntilInvalidated(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Given - two processes sharing a catalog
+	store := dcb.SetupTestStore(t)
+	writer := fairway.NewReferenceData[product](store, "catalog")
+	reader := fairway.NewReferenceData[product](store, "catalog", fairway.WithReferenceDataCacheTTL(time.Hour))
+	require.NoError(t, writer.Put(ctx, "p1", product{Name: "pen", Price: 2}))
+
+	// When
+	first, found, err := reader.Get(ctx, "p1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NoError(t, writer.Put(ctx, "p1", product{Name: "pen", Price: 3}))
+	cached, _, err := reader.Get(ctx, "p1")
+	require.NoError(t, err)
+	reader.Invalidate("p1")
+	fresh, _, err := reader.Get(ctx, "p1")
+	require.NoError(t, err)
+
+	// Then - the reader served its cached value until invalidated
+	assert.Equal(t, product{Name: "pen", Price: 2}, first)
+	assert.Equal(t, first, cached)
+	assert.Equal(t, product{Name: "pen", Price: 3}, fresh)
+
+	// When - the writer deletes the product
+	require.NoError(t, writer.Delete(ctx, "p1"))
+	_, found, err = writer.Get(ctx, "p1")
+
+	// Then - its own cache was invalidated
+	require.NoError(t, err)
+	assert.False(t, found)
+}
@@ -1,33 +1,243 @@
 package fairway
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
 )
 
+// PositionHeader is set by change endpoints to the token (see dcb.Versionstamp.Token) of the last event
+// appended while handling the request, so clients can read their own writes from views
+const PositionHeader = "Fairway-Position"
+
+// defaultConflictRetryAfter is the Retry-After of conflict responses when HttpChangeRegistry.ConflictRetryAfter is not set
+const defaultConflictRetryAfter = time.Second
+
 type HttpChangeRegistry struct {
 	// registeredCommands stores all registered command routes
 	registeredCommands []changeRegistration
+	// PositionField, when set, also adds the position token under this field of JSON object responses
+	PositionField string
+	// ConflictRetryAfter is sent as Retry-After when a command still fails with dcb.ErrAppendConditionFailed
+	// after the runner's retries (default: 1s)
+	ConflictRetryAfter time.Duration
 }
 
 // changeRegistration represents a command route registration
 type changeRegistration struct {
 	Pattern string
 	Handler func(CommandRunner) http.HandlerFunc
+	Info    RouteInfo
 }
 
-// RegisterCommand registers a command handler
-func (registry *HttpChangeRegistry) RegisterCommand(pattern string, handler func(CommandRunner) http.HandlerFunc) {
+// RegisterCommand registers a command handler.
+// opts declare what the route does (commands, request body...), as returned by Routes.
+func (registry *HttpChangeRegistry) RegisterCommand(pattern string, handler func(CommandRunner) http.HandlerFunc, opts ...RouteOption) {
 	registry.registeredCommands = append(registry.registeredCommands, changeRegistration{
 		Pattern: pattern,
 		Handler: handler,
+		Info:    newRouteInfo(pattern, opts),
 	})
 }
 
-// RegisterRoutes registers all command routes to the mux
+// RegisterRoutes registers all command routes to the mux.
+// Handlers get a runner reporting contention to the registry: when a command still fails with
+// dcb.ErrAppendConditionFailed after the runner's retries, the registry responds 409 Conflict with
+// a Retry-After header, whatever the handler writes.
 func (registry HttpChangeRegistry) RegisterRoutes(mux *http.ServeMux, runner CommandRunner) {
+	runner = conflictReportingRunner{runner: runner}
 	for _, reg := range registry.registeredCommands {
-		mux.HandleFunc(reg.Pattern, reg.Handler(runner))
+		handler := registry.wrap(reg.Handler(runner))
+		if reg.Info.RequiresIdempotencyKey {
+			handler = requireIdempotencyKey(handler)
+		}
+		mux.HandleFunc(reg.Pattern, handler)
+	}
+}
+
+// changeRequest is what the registry learns about a request while its handler runs
+type changeRequest struct {
+	positions *dcb.AppendedPositions
+
+	mu       sync.Mutex
+	conflict error // the last command failed on contention
+}
+
+type changeRequestKey struct{}
+
+func (req *changeRequest) setResult(err error) {
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	req.conflict = nil
+	if errors.Is(err, dcb.ErrAppendConditionFailed) {
+		req.conflict = err
+	}
+}
+
+func (req *changeRequest) conflicted() error {
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	return req.conflict
+}
+
+// conflictReportingRunner reports the outcome of commands to the request's changeRequest
+type conflictReportingRunner struct {
+	runner CommandRunner
+}
+
+func (r conflictReportingRunner) RunPure(ctx context.Context, cmd Command) error {
+	err := r.runner.RunPure(ctx, cmd)
+	if req, ok := ctx.Value(changeRequestKey{}).(*changeRequest); ok {
+		req.setResult(err)
+	}
+	return err
+}
+
+func (r conflictReportingRunner) RunPureIdempotent(ctx context.Context, key string, cmd Command) error {
+	err := r.runner.RunPureIdempotent(ctx, key, cmd)
+	if req, ok := ctx.Value(changeRequestKey{}).(*changeRequest); ok {
+		req.setResult(err)
+	}
+	return err
+}
+
+// RunBatch leaves the outcome to the handler: the commands of a batch succeed or fail on their own
+func (r conflictReportingRunner) RunBatch(ctx context.Context, cmds []Command, opts ...BatchOption) BatchResult {
+	return r.runner.RunBatch(ctx, cmds, opts...)
+}
+
+// wrap records the positions of the events appended by the handler and returns the last one to the client,
+// and replaces the response of commands failing on contention
+func (registry HttpChangeRegistry) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, positions := dcb.WithAppendedPositions(r.Context())
+		req := &changeRequest{positions: positions}
+		r = r.WithContext(context.WithValue(ctx, changeRequestKey{}, req))
+
+		if registry.PositionField == "" {
+			cw := &changeWriter{ResponseWriter: w, req: req, registry: registry}
+			next(cw, r)
+			if err := req.conflicted(); err != nil && !cw.wroteHeader {
+				registry.writeConflict(w, err)
+			}
+			return
+		}
+
+		// The body is buffered to add the field once the handler is done
+		rec := &bufferedWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next(rec, r)
+		if err := req.conflicted(); err != nil {
+			registry.writeConflict(w, err)
+			return
+		}
+
+		body := rec.body.Bytes()
+		if pos, ok := positions.Last(); ok {
+			w.Header().Set(PositionHeader, pos.Token())
+			if isJSON(w.Header()) {
+				body = withJSONField(body, registry.PositionField, pos.Token())
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			}
+		}
+		w.WriteHeader(rec.statusCode)
+		_, _ = w.Write(body)
+	}
+}
+
+// writeConflict responds 409 Conflict, inviting the client to retry
+func (registry HttpChangeRegistry) writeConflict(w http.ResponseWriter, err error) {
+	retryAfter := registry.ConflictRetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultConflictRetryAfter
 	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteProblem(w, ProblemFor(err))
+}
+
+// changeWriter sets PositionHeader when the handler starts writing its response,
+// or replaces the response when the command failed on contention
+type changeWriter struct {
+	http.ResponseWriter
+	req         *changeRequest
+	registry    HttpChangeRegistry
+	wroteHeader bool
+	discard     bool // the handler's response was replaced
+}
+
+func (w *changeWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if err := w.req.conflicted(); err != nil {
+		w.discard = true
+		w.registry.writeConflict(w.ResponseWriter, err)
+		return
+	}
+	if pos, ok := w.req.positions.Last(); ok {
+		w.Header().Set(PositionHeader, pos.Token())
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *changeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *changeWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// bufferedWriter holds the status code and body written by the handler, headers are set directly
+type bufferedWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(statusCode int)  { w.statusCode = statusCode }
+func (w *bufferedWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+
+// isJSON reports whether the response is declared as JSON
+func isJSON(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// withJSONField adds a string field to a JSON object, other bodies are returned unchanged
+func withJSONField(body []byte, name, value string) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) < 2 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return body
+	}
+	field, err := json.Marshal(map[string]string{name: value})
+	if err != nil {
+		return body
+	}
+
+	out := make([]byte, 0, len(trimmed)+len(field))
+	out = append(out, trimmed[:len(trimmed)-1]...)
+	if len(bytes.TrimSpace(trimmed[1:len(trimmed)-1])) > 0 {
+		out = append(out, ',')
+	}
+	out = append(out, field[1:]...) // the field and the closing brace
+	return out
 }
 
 func (registry HttpChangeRegistry) RegisteredRoutes() []string {
@@ -38,6 +248,15 @@ func (registry HttpChangeRegistry) RegisteredRoutes() []string {
 	return result
 }
 
+// Routes describes the registered command routes, in registration order
+func (registry HttpChangeRegistry) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(registry.registeredCommands))
+	for i, c := range registry.registeredCommands {
+		routes[i] = c.Info.clone()
+	}
+	return routes
+}
+
 type HttpViewRegistry struct {
 	registeredViews []viewRegistration
 }
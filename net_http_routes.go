@@ -0,0 +1,104 @@
+package fairway
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// idempotencyKeyHeader is the request header deduplicating retried requests (see utils.IdempotencyMiddleware)
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// RouteInfo describes a change route, for self-documenting index pages and API description generators.
+// The metadata beyond the pattern is declared with RouteOptions when registering the route.
+type RouteInfo struct {
+	Pattern    string   // as registered, e.g. "POST /api/lists/{listId}"
+	Method     string   // empty when the pattern matches any method
+	Path       string   // the pattern without its method (and host, if any)
+	PathParams []string // names of the wildcards of Path, in order
+
+	Summary                string
+	Commands               []reflect.Type // types of the commands the handler runs
+	Request                reflect.Type   // type of the body the handler decodes, nil if none declared
+	Response               reflect.Type   // type of the body of successful responses, nil if none declared
+	RequiresIdempotencyKey bool           // requests without an Idempotency-Key header are rejected
+}
+
+// RouteOption declares metadata of a change route (see HttpChangeRegistry.Routes)
+type RouteOption func(*RouteInfo)
+
+// WithRouteSummary sets a one-line description of the route
+func WithRouteSummary(summary string) RouteOption {
+	return func(info *RouteInfo) {
+		info.Summary = summary
+	}
+}
+
+// WithRouteCommands declares the commands the handler runs, by example values (e.g. createList{})
+func WithRouteCommands(cmds ...Command) RouteOption {
+	return func(info *RouteInfo) {
+		for _, cmd := range cmds {
+			info.Commands = append(info.Commands, reflect.TypeOf(cmd))
+		}
+	}
+}
+
+// WithRouteRequest declares the body the handler decodes, by an example value (e.g. reqBody{})
+func WithRouteRequest(body any) RouteOption {
+	return func(info *RouteInfo) {
+		info.Request = reflect.TypeOf(body)
+	}
+}
+
+// WithRouteResponse declares the body of successful responses, by an example value
+func WithRouteResponse(body any) RouteOption {
+	return func(info *RouteInfo) {
+		info.Response = reflect.TypeOf(body)
+	}
+}
+
+// WithRouteIdempotencyKey requires requests to carry an Idempotency-Key header,
+// so that clients can retry them safely: the registry answers 400 Bad Request to those that don't
+func WithRouteIdempotencyKey() RouteOption {
+	return func(info *RouteInfo) {
+		info.RequiresIdempotencyKey = true
+	}
+}
+
+// newRouteInfo describes pattern, a net/http pattern: "[METHOD ][HOST]/[PATH]"
+func newRouteInfo(pattern string, opts []RouteOption) RouteInfo {
+	info := RouteInfo{Pattern: pattern, Path: pattern}
+	if method, rest, found := strings.Cut(pattern, " "); found {
+		info.Method, info.Path = method, strings.TrimLeft(rest, " \t")
+	}
+	if i := strings.IndexByte(info.Path, '/'); i > 0 {
+		info.Path = info.Path[i:] // host
+	}
+	for _, segment := range strings.Split(info.Path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok && strings.HasSuffix(name, "}") && name != "$}" {
+			info.PathParams = append(info.PathParams, strings.TrimSuffix(strings.TrimSuffix(name, "}"), "..."))
+		}
+	}
+	for _, opt := range opts {
+		opt(&info)
+	}
+	return info
+}
+
+// clone copies the slices of info, so that callers of Routes can't alter the registry
+func (info RouteInfo) clone() RouteInfo {
+	info.PathParams = append([]string(nil), info.PathParams...)
+	info.Commands = append([]reflect.Type(nil), info.Commands...)
+	return info
+}
+
+// requireIdempotencyKey rejects the requests without an Idempotency-Key header
+func requireIdempotencyKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(idempotencyKeyHeader) == "" {
+			WriteProblem(w, NewProblem(http.StatusBadRequest, "missing "+idempotencyKeyHeader+" header"))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,195 @@
+package fairway
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// defaultEventStreamFlushEvery is the number of events written between two flushes by default
+const defaultEventStreamFlushEvery = 100
+
+// streamedEvent is a line of an event stream
+type streamedEvent struct {
+	Position    string            `json:"position"` // cursor to resume after this event (after=)
+	Type        string            `json:"type"`
+	Tags        []string          `json:"tags"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Data        json.RawMessage   `json:"data,omitempty"`
+	DataBase64  string            `json:"dataBase64,omitempty"` // payloads that aren't JSON
+	CommittedAt *time.Time        `json:"committedAt,omitempty"`
+}
+
+// streamError is the last line of a stream interrupted by an error
+type streamError struct {
+	Error string `json:"error"`
+}
+
+// EventStreamOption configures a route registered with RegisterEventStream
+type EventStreamOption func(*eventStreamSettings)
+
+type eventStreamSettings struct {
+	flushEvery int
+	maxLimit   int
+}
+
+// WithEventStreamFlushEvery sets the number of events written between two flushes to the client (default: 100)
+func WithEventStreamFlushEvery(n int) EventStreamOption {
+	return func(s *eventStreamSettings) {
+		if n > 0 {
+			s.flushEvery = n
+		}
+	}
+}
+
+// WithEventStreamMaxLimit caps the number of events of a request, whatever its limit parameter (default: no cap)
+func WithEventStreamMaxLimit(n int) EventStreamOption {
+	return func(s *eventStreamSettings) {
+		if n > 0 {
+			s.maxLimit = n
+		}
+	}
+}
+
+// RegisterEventStream registers a GET route streaming the raw events of store as NDJSON (application/x-ndjson),
+// one event per line, for operational exports triggered over HTTP. Query parameters:
+//   - q: the query, in the text syntax of dcb.ParseQuery; the whole log when absent;
+//   - after: the position of a line of a previous response, to resume after it;
+//   - limit: the maximum number of events.
+//
+// Events are read as they are written: the client's pace holds the read back, nothing is buffered
+// beyond a flush. A stream interrupted by an error ends with an {"error": ...} line; resume it after
+// the position of the last event received. Without q, resuming scans the log from its start.
+//
+// The payloads are those stored: mount the route behind the application's admin authentication.
+func (registry *HttpViewRegistry) RegisterEventStream(pattern string, store dcb.DcbStore, opts ...EventStreamOption) {
+	settings := eventStreamSettings{flushEvery: defaultEventStreamFlushEvery}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	registry.RegisterView(pattern, func(EventsReader) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			streamEvents(w, r, store, settings)
+		}
+	})
+}
+
+func streamEvents(w http.ResponseWriter, r *http.Request, store dcb.DcbStore, settings eventStreamSettings) {
+	events, limit, err := eventStreamRead(r, store, settings)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+
+	written := 0
+	for ev, err := range events {
+		if err != nil {
+			_ = enc.Encode(streamError{Error: err.Error()})
+			_ = flush()
+			return
+		}
+		if err := enc.Encode(toStreamedEvent(ev)); err != nil {
+			return // the client is gone
+		}
+		written++
+		if written%settings.flushEvery == 0 {
+			if err := flush(); err != nil {
+				return
+			}
+		}
+		if limit > 0 && written == limit {
+			break
+		}
+	}
+	_ = flush()
+}
+
+// eventStreamRead returns the events requested by r, and the maximum number to write (0 for all)
+func eventStreamRead(r *http.Request, store dcb.DcbStore, settings eventStreamSettings) (iter.Seq2[dcb.StoredEvent, error], int, error) {
+	params := r.URL.Query()
+
+	limit := settings.maxLimit
+	if raw := params.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, 0, NewProblem(http.StatusBadRequest, fmt.Sprintf("limit must be a positive integer, got %q", raw))
+		}
+		if limit == 0 || n < limit {
+			limit = n
+		}
+	}
+
+	var after *dcb.Versionstamp
+	if raw := params.Get("after"); raw != "" {
+		pos, err := dcb.ParsePositionToken(raw)
+		if err != nil {
+			return nil, 0, NewProblem(http.StatusBadRequest, err.Error())
+		}
+		after = &pos
+	}
+
+	if raw := params.Get("q"); raw != "" {
+		query, err := dcb.ParseQuery(raw)
+		if err != nil {
+			return nil, 0, NewProblem(http.StatusBadRequest, err.Error())
+		}
+		return store.Read(r.Context(), query, &dcb.ReadOptions{After: after, Limit: limit}), limit, nil
+	}
+	return afterPosition(store.ReadAll(r.Context()), after), limit, nil
+}
+
+// afterPosition skips the events of events up to after (included), if not nil
+func afterPosition(events iter.Seq2[dcb.StoredEvent, error], after *dcb.Versionstamp) iter.Seq2[dcb.StoredEvent, error] {
+	if after == nil {
+		return events
+	}
+	return func(yield func(dcb.StoredEvent, error) bool) {
+		for ev, err := range events {
+			if err == nil && ev.Position.Compare(*after) <= 0 {
+				continue
+			}
+			if !yield(ev, err) {
+				return
+			}
+		}
+	}
+}
+
+func toStreamedEvent(ev dcb.StoredEvent) streamedEvent {
+	line := streamedEvent{Position: ev.Position.Token(), Type: ev.Type, Tags: ev.Tags, Metadata: ev.Metadata}
+	if line.Tags == nil {
+		line.Tags = []string{}
+	}
+	if json.Valid(ev.Data) {
+		line.Data = ev.Data
+	} else {
+		line.DataBase64 = base64.StdEncoding.EncodeToString(ev.Data)
+	}
+	if !ev.CommittedAt.IsZero() {
+		line.CommittedAt = &ev.CommittedAt
+	}
+	return line
+}
@@ -0,0 +1,248 @@
+package fairway_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendItemHandler appends a PageItem and answers with a JSON object
+func appendItemHandler(runner fairway.CommandRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := runner.RunPure(r.Context(), commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+			return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+		}))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "42"})
+	}
+}
+
+func TestHttpChangeRegistry_ReturnsAppendedPosition(t *testing.T) {
+	t.Parallel()
+
+	for name, field := range map[string]string{"header only": "", "header and JSON field": "position"} {
+		t.Run(name, func(t *testing.T) {
+			// Given
+			store := dcb.SetupTestStore(t)
+			registry := fairway.HttpChangeRegistry{PositionField: field}
+			registry.RegisterCommand("POST /items", appendItemHandler)
+			mux := http.NewServeMux()
+			registry.RegisterRoutes(mux, fairway.NewCommandRunner(store))
+
+			// When
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+			// Then
+			require.Equal(t, http.StatusCreated, rec.Code)
+			stored := dcb.CollectEvents(t, store.ReadAll(context.Background()))
+			require.Len(t, stored, 1)
+			token := stored[0].Position.Token()
+			assert.Equal(t, token, rec.Header().Get(fairway.PositionHeader))
+
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, "42", body["id"])
+			if field != "" {
+				assert.Equal(t, token, body[field])
+			} else {
+				assert.NotContains(t, body, "position")
+			}
+		})
+	}
+}
+
+func TestHttpChangeRegistry_RespondsConflictOnContention(t *testing.T) {
+	t.Parallel()
+
+	for name, field := range map[string]string{"streamed": "", "buffered": "position"} {
+		t.Run(name, func(t *testing.T) {
+			// Given - a command that keeps conflicting, whose handler answers with an ad-hoc body
+			registry := fairway.HttpChangeRegistry{PositionField: field, ConflictRetryAfter: 1500 * time.Millisecond}
+			registry.RegisterCommand("POST /items", func(runner fairway.CommandRunner) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					err := runner.RunPure(r.Context(), commandFunc(func(context.Context, fairway.EventReadAppender) error {
+						return dcb.ErrAppendConditionFailed
+					}))
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+			})
+			mux := http.NewServeMux()
+			registry.RegisterRoutes(mux, fairway.NewCommandRunner(&mockStore{}, fairway.WithRetryOptions(retry.Attempts(1))))
+
+			// When
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+			// Then
+			assert.Equal(t, http.StatusConflict, rec.Code)
+			assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+			var problem map[string]any
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, float64(http.StatusConflict), problem["status"])
+		})
+	}
+}
+
+type itemBody struct {
+	Name string `json:"name"`
+}
+
+func TestHttpChangeRegistry_DescribesRoutes(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	registry := fairway.HttpChangeRegistry{}
+	registry.RegisterCommand("POST /lists/{listId}/items/{itemId...}", appendItemHandler,
+		fairway.WithRouteSummary("adds an item"),
+		fairway.WithRouteCommands(commandFunc(nil)),
+		fairway.WithRouteRequest(itemBody{}),
+		fairway.WithRouteIdempotencyKey(),
+	)
+	registry.RegisterCommand("/ping", appendItemHandler)
+
+	// When
+	routes := registry.Routes()
+
+	// Then
+	require.Len(t, routes, 2)
+	assert.Equal(t, fairway.RouteInfo{
+		Pattern:                "POST /lists/{listId}/items/{itemId...}",
+		Method:                 http.MethodPost,
+		Path:                   "/lists/{listId}/items/{itemId...}",
+		PathParams:             []string{"listId", "itemId"},
+		Summary:                "adds an item",
+		Commands:               []reflect.Type{reflect.TypeOf(commandFunc(nil))},
+		Request:                reflect.TypeOf(itemBody{}),
+		RequiresIdempotencyKey: true,
+	}, routes[0])
+	assert.Equal(t, fairway.RouteInfo{Pattern: "/ping", Path: "/ping"}, routes[1])
+	assert.Equal(t, []string{routes[0].Pattern, routes[1].Pattern}, registry.RegisteredRoutes())
+
+	// When - the description returned is altered
+	routes[0].PathParams[0] = "altered"
+
+	// Then
+	assert.Equal(t, "listId", registry.Routes()[0].PathParams[0])
+}
+
+func TestHttpChangeRegistry_RequiresIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	registry := fairway.HttpChangeRegistry{}
+	registry.RegisterCommand("POST /items", appendItemHandler, fairway.WithRouteIdempotencyKey())
+	mux := http.NewServeMux()
+	registry.RegisterRoutes(mux, fairway.NewCommandRunner(store))
+
+	// When
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+	// Then - rejected before the handler runs
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, dcb.CollectEvents(t, store.ReadAll(context.Background())))
+
+	// When
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	// Then
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 1)
+}
+
+// streamLines requests path on mux and decodes the NDJSON lines of the response
+func streamLines(t *testing.T, mux *http.ServeMux, path string) []map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestHttpViewRegistry_StreamsEventsAsNDJSON(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctx := context.Background()
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store)
+	require.NoError(t, runner.RunPure(ctx, commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx,
+			fairway.NewEvent(PageItem{N: 1}), fairway.NewEvent(PageNote{}),
+			fairway.NewEvent(PageItem{N: 2}), fairway.NewEvent(PageItem{N: 3}))
+	})))
+	stored := dcb.CollectEvents(t, store.ReadAll(ctx))
+	require.Len(t, stored, 4)
+
+	var registry fairway.HttpViewRegistry
+	registry.RegisterEventStream("GET /admin/events", store, fairway.WithEventStreamFlushEvery(1))
+	mux := http.NewServeMux()
+	registry.RegisterRoutes(mux, fairway.NewReader(store))
+
+	positions := func(lines []map[string]any) []any {
+		var res []any
+		for _, line := range lines {
+			res = append(res, line["position"])
+		}
+		return res
+	}
+
+	// When - the whole log is streamed by pages of 2
+	first := streamLines(t, mux, "/admin/events?limit=2")
+	rest := streamLines(t, mux, "/admin/events?after="+first[1]["position"].(string))
+
+	// Then
+	assert.Equal(t, []any{stored[0].Position.Token(), stored[1].Position.Token()}, positions(first))
+	assert.Equal(t, []any{stored[2].Position.Token(), stored[3].Position.Token()}, positions(rest))
+	assert.Equal(t, "PageItem", first[0]["type"])
+	assert.Equal(t, "PageNote", first[1]["type"])
+	assert.Contains(t, first[0], "data")
+	assert.Contains(t, first[0], "committedAt")
+
+	// When - a query is streamed
+	items := streamLines(t, mux, "/admin/events?q="+url.QueryEscape("type:PageItem")+"&after="+stored[0].Position.Token())
+
+	// Then
+	assert.Equal(t, []any{stored[2].Position.Token(), stored[3].Position.Token()}, positions(items))
+
+	// When - the parameters are invalid
+	for _, query := range []string{"q=type:", "after=nope", "limit=0"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/events?"+query, nil))
+
+		// Then
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
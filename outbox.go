@@ -0,0 +1,78 @@
+package fairway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ErrUnknownOutboxKind is returned by an outbox for an intent without executor: the job is retried, then dead-lettered
+var ErrUnknownOutboxKind = errors.New("no executor for outbox intent kind")
+
+// outboxTagKey tags intents with their kind, so the intents of a kind can be queried
+const outboxTagKey dcb.TagKey = "outbox"
+
+// OutboxIntent is the intent of an external side effect (HTTP call, message publish...).
+// A command appends it with its domain events, so both are committed in the same transaction,
+// and an outbox (see NewOutbox) executes it afterwards: the effect runs if and only if the command succeeded.
+type OutboxIntent struct {
+	Kind    string          `json:"kind"`    // selects the executor
+	Payload json.RawMessage `json:"payload"` // the effect's parameters
+}
+
+// Tags tags the intent with its kind ("outbox:<kind>")
+func (i OutboxIntent) Tags() []string {
+	return []string{outboxTagKey.Equals(i.Kind)}
+}
+
+// Decode unmarshals the payload into v
+func (i OutboxIntent) Decode(v any) error {
+	return json.Unmarshal(i.Payload, v)
+}
+
+// NewOutboxIntent returns the event of an intent of kind, payload being encoded to JSON
+func NewOutboxIntent(kind string, payload any) (Event, error) {
+	if kind == "" {
+		return Event{}, errors.New("outbox intent kind is required")
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("encoding payload of outbox intent %q: %w", kind, err)
+	}
+	return NewEvent(OutboxIntent{Kind: kind, Payload: data}), nil
+}
+
+// OutboxExecutor executes the intents of a kind. Delivery is at-least-once: an intent is executed again
+// when its job fails or its lease expires. Make the effect idempotent downstream (TriggerPosition(ctx)
+// identifies the intent), or run it through EffectLog.OnceForTrigger.
+type OutboxExecutor func(ctx context.Context, intent OutboxIntent) error
+
+// OutboxExecutors maps intent kinds to their executor
+type OutboxExecutors map[string]OutboxExecutor
+
+// NewOutbox returns the automation executing the intents appended to store, with the executor of their kind.
+// Failed executions are retried with backoff, then dead-lettered like any automation job (see the options).
+func NewOutbox(store dcb.DcbStore, queueId string, executors OutboxExecutors, opts ...AutomationOption[OutboxExecutors]) (*Automation[OutboxExecutors], error) {
+	if len(executors) == 0 {
+		return nil, errors.New("outbox executors are required")
+	}
+	return NewAutomation(store, executors, queueId, OutboxIntent{}, func(ev Event) CommandWithEffect[OutboxExecutors] {
+		return outboxCommand{intent: ev.Data.(OutboxIntent)}
+	}, opts...)
+}
+
+// outboxCommand executes an intent
+type outboxCommand struct {
+	intent OutboxIntent
+}
+
+func (c outboxCommand) Run(ctx context.Context, _ EventReadAppenderExtended, executors OutboxExecutors) error {
+	execute, ok := executors[c.intent.Kind]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownOutboxKind, c.intent.Kind)
+	}
+	return execute(ctx, c.intent)
+}
@@ -0,0 +1,90 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type OrderConfirmed struct {
+	OrderID string `json:"orderId"`
+}
+
+type confirmationEmail struct {
+	OrderID string `json:"orderId"`
+	To      string `json:"to"`
+}
+
+func TestOutbox_ExecutesIntentsAppendedWithDomainEvents(t *testing.T) {
+	t.Parallel()
+
+	// Given - an executor failing its first attempt
+	store := dcb.SetupTestStore(t)
+	var mu sync.Mutex
+	var sent []confirmationEmail
+	attempts := 0
+	outbox, err := fairway.NewOutbox(store, "outbox", fairway.OutboxExecutors{
+		"confirmation-email": func(ctx context.Context, intent fairway.OutboxIntent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts++
+			if attempts == 1 {
+				return errors.New("smtp unavailable")
+			}
+			var email confirmationEmail
+			if err := intent.Decode(&email); err != nil {
+				return err
+			}
+			sent = append(sent, email)
+			return nil
+		},
+	},
+		fairway.WithPollInterval[fairway.OutboxExecutors](10*time.Millisecond),
+		fairway.WithRetryBaseWait[fairway.OutboxExecutors](10*time.Millisecond),
+		fairway.WithErrorHandler[fairway.OutboxExecutors](func(error) {}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, outbox.Start(t.Context()))
+	t.Cleanup(outbox.Stop)
+
+	// When - a command confirms an order, and intends to email it
+	cmd := commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		intent, err := fairway.NewOutboxIntent("confirmation-email", confirmationEmail{OrderID: "42", To: "jane@example.com"})
+		if err != nil {
+			return err
+		}
+		return ra.AppendEvents(ctx, fairway.NewEvent(OrderConfirmed{OrderID: "42"}), intent)
+	})
+	require.NoError(t, fairway.NewCommandRunner(store).RunPure(t.Context(), cmd))
+
+	// Then - the email is sent once it succeeds
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(sent) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []confirmationEmail{{OrderID: "42", To: "jane@example.com"}}, sent)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestNewOutboxIntent_TagsTheKind(t *testing.T) {
+	t.Parallel()
+
+	// When
+	intent, err := fairway.NewOutboxIntent("publish", map[string]string{"topic": "orders"})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outbox:publish"}, intent.Tags())
+	_, err = fairway.NewOutboxIntent("", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package fairway
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// RedactedValue replaces personal data in logs
+const RedactedValue = "[REDACTED]"
+
+// piiTagValue marks a field holding personal data: `fairway:"pii"`
+const piiTagValue = "pii"
+
+var (
+	// piiPathsByType caches the PII paths of each Go type
+	piiPathsByType sync.Map // reflect.Type -> [][]string
+	// piiPathsByName holds the PII paths of the event types serialized or read by this process
+	piiPathsByName sync.Map // event type name -> [][]string
+)
+
+// PIIFields returns the JSON paths (dot separated) of the fields of v tagged `fairway:"pii"`,
+// including fields of nested structs and of struct slices.
+// Encryption and crypto-shredding layers use them to find the values to protect.
+func PIIFields(v any) []string {
+	paths := piiPaths(reflect.TypeOf(v))
+	fields := make([]string, len(paths))
+	for i, p := range paths {
+		fields[i] = strings.Join(p, ".")
+	}
+	return fields
+}
+
+// Redact returns the JSON representation of v (decoded as maps and slices) with its PII fields replaced by RedactedValue
+func Redact(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return RedactedValue
+	}
+	return redactJSON(data, piiPaths(reflect.TypeOf(v)))
+}
+
+// LogValue implements slog.LogValuer: events are logged with their PII fields redacted
+func (e Event) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("type", e.typeString()),
+		slog.Time("occurredAt", e.OccurredAt),
+		slog.Any("data", Redact(e.Data)),
+	}
+	if e.Position != (dcb.Versionstamp{}) {
+		attrs = append(attrs, slog.String("position", e.Position.String()))
+	}
+	if e.IdempotencyKey != "" {
+		attrs = append(attrs, slog.String("idempotencyKey", e.IdempotencyKey))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// PIIRedactor returns a dcb.DataRedactor hiding the PII fields of the event types this process
+// serialized or read, to install with dcb.StoreOptions{}.WithDataRedactor.
+// Payloads of other types are logged verbatim.
+func PIIRedactor() dcb.DataRedactor {
+	return func(e dcb.Event) []byte {
+		paths, ok := piiPathsByName.Load(e.Type)
+		if !ok {
+			return e.Data
+		}
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(e.Data, &envelope); err != nil {
+			return []byte(`"` + RedactedValue + `"`)
+		}
+		redacted, err := json.Marshal(redactJSON(envelope["data"], paths.([][]string)))
+		if err != nil {
+			return []byte(`"` + RedactedValue + `"`)
+		}
+		envelope["data"] = redacted
+		out, err := json.Marshal(envelope)
+		if err != nil {
+			return []byte(`"` + RedactedValue + `"`)
+		}
+		return out
+	}
+}
+
+// registerPIIType records the PII paths of an event type for PIIRedactor
+func registerPIIType(name string, typ reflect.Type) {
+	if paths := piiPaths(typ); len(paths) > 0 {
+		piiPathsByName.Store(name, paths)
+	}
+}
+
+// piiPaths returns the PII paths of typ, as JSON field names
+func piiPaths(typ reflect.Type) [][]string {
+	if typ == nil {
+		return nil
+	}
+	if cached, ok := piiPathsByType.Load(typ); ok {
+		return cached.([][]string)
+	}
+	paths := collectPIIPaths(typ, nil, map[reflect.Type]bool{})
+	piiPathsByType.Store(typ, paths)
+	return paths
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func collectPIIPaths(typ reflect.Type, prefix []string, visiting map[reflect.Type]bool) [][]string {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType || visiting[typ] {
+		return nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	var paths [][]string
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			// promoted fields share the parent's level (even from unexported embedded structs)
+			paths = append(paths, collectPIIPaths(field.Type, prefix, visiting)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		path := append(append([]string{}, prefix...), name)
+		if field.Tag.Get("fairway") == piiTagValue {
+			paths = append(paths, path)
+			continue
+		}
+		paths = append(paths, collectPIIPaths(field.Type, path, visiting)...)
+	}
+	return paths
+}
+
+// jsonFieldName returns the JSON name of the field ("" for the default one), false if it is not serialized
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, true
+}
+
+// redactJSON decodes data and replaces the values at paths
+func redactJSON(data []byte, paths [][]string) any {
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return RedactedValue
+	}
+	for _, path := range paths {
+		decoded = redactPath(decoded, path)
+	}
+	return decoded
+}
+
+func redactPath(v any, path []string) any {
+	switch node := v.(type) {
+	case []any:
+		for i := range node {
+			node[i] = redactPath(node[i], path)
+		}
+		return node
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok || child == nil {
+			return node
+		}
+		if len(path) == 1 {
+			node[path[0]] = RedactedValue
+		} else {
+			node[path[0]] = redactPath(child, path[1:])
+		}
+		return node
+	default:
+		return v
+	}
+}
@@ -0,0 +1,105 @@
+package fairway_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type piiAddress struct {
+	Street string `json:"street" fairway:"pii"`
+	City   string `json:"city"`
+}
+
+type piiAudit struct {
+	By string `fairway:"pii"`
+}
+
+// CustomerRegistered is an event with personal data
+type CustomerRegistered struct {
+	piiAudit
+	Id        string       `json:"id"`
+	Email     string       `json:"email" fairway:"pii"`
+	Addresses []piiAddress `json:"addresses"`
+	Internal  string       `json:"-" fairway:"pii"`
+}
+
+func newCustomerRegistered() CustomerRegistered {
+	return CustomerRegistered{
+		piiAudit:  piiAudit{By: "admin@example.com"},
+		Id:        "c1",
+		Email:     "jane@example.com",
+		Addresses: []piiAddress{{Street: "1 Main St", City: "Springfield"}},
+	}
+}
+
+func TestPIIFields(t *testing.T) {
+	assert.ElementsMatch(t, []string{"By", "email", "addresses.street"}, fairway.PIIFields(CustomerRegistered{}))
+	assert.Empty(t, fairway.PIIFields(PageItem{}))
+}
+
+func TestEventLogValue_RedactsPII(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// When
+	logger.Info("registered", "event", fairway.NewEvent(newCustomerRegistered()))
+
+	// Then
+	out := buf.String()
+	assert.NotContains(t, out, "jane@example.com")
+	assert.NotContains(t, out, "admin@example.com")
+	assert.NotContains(t, out, "1 Main St")
+	assert.Contains(t, out, "Springfield")
+	assert.Contains(t, out, fairway.RedactedValue)
+}
+
+// dataLogger keeps the payloads logged at debug level
+type dataLogger struct {
+	mu   sync.Mutex
+	data []string
+}
+
+func (l *dataLogger) Debug(_ string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "data" {
+			l.data = append(l.data, args[i+1].(string))
+		}
+	}
+}
+func (l *dataLogger) Info(string, ...any)  {}
+func (l *dataLogger) Warn(string, ...any)  {}
+func (l *dataLogger) Error(string, ...any) {}
+
+func TestPIIRedactor_RedactsStoreTraces(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	logger := &dataLogger{}
+	opts := dcb.StoreOptions{}
+	opts.WithLogger(logger)(store)
+	opts.WithDebugTracing(func(dcb.Event) bool { return true })(store)
+	opts.WithDataRedactor(fairway.PIIRedactor())(store)
+
+	// When
+	ev, err := fairway.ToDcbEvent(fairway.NewEvent(newCustomerRegistered()))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{ev}))
+
+	// Then
+	require.Len(t, logger.data, 1)
+	assert.NotContains(t, logger.data[0], "jane@example.com")
+	assert.Contains(t, logger.data[0], `"id":"c1"`)
+	assert.Contains(t, logger.data[0], fairway.RedactedValue)
+}
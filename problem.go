@@ -0,0 +1,133 @@
+package fairway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ProblemContentType is the media type of problem details responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, the body of every error response written by fairway.
+// It is an error itself, so commands can return it to choose their response.
+// Problems are not comparable: match them with errors.As, not errors.Is.
+type Problem struct {
+	Type     string // URI identifying the problem type (default: "about:blank")
+	Title    string // short summary of the problem type (default: the status text)
+	Status   int
+	Detail   string // explanation specific to this occurrence
+	Instance string // URI identifying this occurrence
+	// Extensions are additional members of the object, e.g. "errors" for invalid fields
+	Extensions map[string]any
+}
+
+// ProblemError is implemented by errors describing the response they should produce,
+// the extension point for app-specific error types: ProblemFor returns the Problem
+// of the first error implementing it in the chain.
+type ProblemError interface {
+	error
+	Problem() Problem
+}
+
+// NewProblem creates a problem of the default type
+func NewProblem(status int, detail string) Problem {
+	return Problem{Status: status, Detail: detail}
+}
+
+func (p Problem) Problem() Problem { return p }
+
+func (p Problem) Error() string {
+	if p.Detail == "" {
+		return p.title()
+	}
+	return p.title() + ": " + p.Detail
+}
+
+func (p Problem) title() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return http.StatusText(p.Status)
+}
+
+// MarshalJSON writes the extensions next to the standard members, which take precedence
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = "about:blank"
+	}
+	members["title"] = p.title()
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	} else {
+		delete(members, "detail")
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	} else {
+		delete(members, "instance")
+	}
+	return json.Marshal(members)
+}
+
+// ProblemFor maps an error to the problem describing it:
+//   - errors implementing ProblemError describe themselves
+//   - contention (dcb.ErrAppendConditionFailed) and duplicates still in progress are 409 Conflict
+//   - missing or invalid tenants and malformed JSON bodies are 400 Bad Request
+//   - timeouts are 504 Gateway Timeout
+//
+// Anything else is a 500 Internal Server Error, without detail so internals don't leak to clients.
+func ProblemFor(err error) Problem {
+	var pe ProblemError
+	if errors.As(err, &pe) {
+		return pe.Problem()
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, dcb.ErrAppendConditionFailed):
+		return NewProblem(http.StatusConflict, "the command conflicted with concurrent changes, retry later")
+	case errors.Is(err, ErrIdempotencyKeyInProgress):
+		return NewProblem(http.StatusConflict, "a request with the same idempotency key is in progress")
+	case errors.Is(err, ErrNoTenant), errors.Is(err, ErrInvalidTenant):
+		return NewProblem(http.StatusBadRequest, err.Error())
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return NewProblem(http.StatusBadRequest, "malformed JSON body: "+err.Error())
+	case errors.Is(err, ErrIdempotencyTimeout), errors.Is(err, context.DeadlineExceeded):
+		return NewProblem(http.StatusGatewayTimeout, err.Error())
+	}
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// WriteProblem writes p as the response, a problem without status is a 500 Internal Server Error
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		// unsupported extension values: fall back to the standard members
+		body, _ = json.Marshal(Problem{Type: p.Type, Title: p.Title, Status: p.Status, Detail: p.Detail, Instance: p.Instance})
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// WriteError writes the problem describing err (see ProblemFor) as the response
+func WriteError(w http.ResponseWriter, err error) {
+	WriteProblem(w, ProblemFor(err))
+}
@@ -0,0 +1,101 @@
+package fairway_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outOfStockError is an app-specific error describing its response
+type outOfStockError struct {
+	sku string
+}
+
+func (e outOfStockError) Error() string { return "out of stock: " + e.sku }
+
+func (e outOfStockError) Problem() fairway.Problem {
+	return fairway.Problem{
+		Type:       "https://example.com/problems/out-of-stock",
+		Title:      "Out of stock",
+		Status:     http.StatusUnprocessableEntity,
+		Detail:     e.Error(),
+		Extensions: map[string]any{"sku": e.sku},
+	}
+}
+
+func TestProblemFor_MapsErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("running: %w", dcb.ErrAppendConditionFailed), http.StatusConflict},
+		{fairway.ErrIdempotencyKeyInProgress, http.StatusConflict},
+		{fmt.Errorf("%w: %q", fairway.ErrInvalidTenant, "a/b"), http.StatusBadRequest},
+		{json.Unmarshal([]byte("{"), &struct{}{}), http.StatusBadRequest},
+		{fairway.ErrIdempotencyTimeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("placing order: %w", outOfStockError{sku: "A-1"}), http.StatusUnprocessableEntity},
+		{fairway.NewProblem(http.StatusNotFound, "no such list"), http.StatusNotFound},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	} {
+		// When
+		problem := fairway.ProblemFor(tc.err)
+
+		// Then
+		assert.Equal(t, tc.status, problem.Status, tc.err.Error())
+	}
+
+	// Then - internal errors don't leak
+	assert.Empty(t, fairway.ProblemFor(errors.New("connection refused")).Detail)
+}
+
+func TestWriteError_WritesProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	rec := httptest.NewRecorder()
+
+	// When
+	fairway.WriteError(rec, fmt.Errorf("placing order: %w", outOfStockError{sku: "A-1"}))
+
+	// Then
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, fairway.ProblemContentType, rec.Header().Get("Content-Type"))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"type":   "https://example.com/problems/out-of-stock",
+		"title":  "Out of stock",
+		"status": float64(http.StatusUnprocessableEntity),
+		"detail": "out of stock: A-1",
+		"sku":    "A-1",
+	}, body)
+}
+
+func TestWriteProblem_DefaultsTypeAndTitle(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	rec := httptest.NewRecorder()
+
+	// When
+	fairway.WriteProblem(rec, fairway.NewProblem(http.StatusNotFound, ""))
+
+	// Then
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"type":   "about:blank",
+		"title":  "Not Found",
+		"status": float64(http.StatusNotFound),
+	}, body)
+}
@@ -0,0 +1,25 @@
+package fairway
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// pprof label keys set on the goroutines of background components, so CPU and heap profiles
+// attribute their cost to a specific automation or exporter, e.g. `go tool pprof -tagfocus queue_id=send-email`
+const (
+	ProfileLabelComponent = "component" // "automation", "exporter" or "watch_group"
+	ProfileLabelQueueId   = "queue_id"  // the component's queueId
+	ProfileLabelRole      = "role"      // the goroutine: "watcher", "worker", "control", "dlq_retrier", "exporter" or "tailer"
+)
+
+// withProfileLabels returns ctx carrying the pprof labels of a component, seen by the commands it runs
+// (see pprof.Label) and inherited by the goroutines started with goLabeled
+func withProfileLabels(ctx context.Context, component, queueId string) context.Context {
+	return pprof.WithLabels(ctx, pprof.Labels(ProfileLabelComponent, component, ProfileLabelQueueId, queueId))
+}
+
+// goLabeled runs f in a goroutine labeled with the labels of ctx and its role
+func goLabeled(ctx context.Context, role string, f func()) {
+	go pprof.Do(ctx, pprof.Labels(ProfileLabelRole, role), func(context.Context) { f() })
+}
@@ -23,18 +23,33 @@ func (q *Query) WithOptions(opts dcb.ReadOptions) *Query {
 type QueryItem struct {
 	typeList     []string                // used for building dbc.Query
 	tagList      []string                // used for building dbc.Query
+	tagKeyList   []string                // used for building dbc.Query
+	after        *dcb.Versionstamp       // used for building dbc.Query
 	typeRegistry map[string]reflect.Type // used for deserialization of events based on their type
 }
 
 // EventHandlerFunc processes an event. Return false to stop iteration.
 type EventHandlerFunc func(Event) bool
 
-// resolveEventTypeName determines the event type name for an event instance.
+// EventHandler binds a query to the handler of its events (see ReadEventsParallel)
+type EventHandler struct {
+	Query  *Query
+	Handle EventHandlerFunc
+	Opts   []ReadOption
+}
+
+// NewEventHandler creates an EventHandler reading query with opts
+func NewEventHandler(query *Query, handle EventHandlerFunc, opts ...ReadOption) *EventHandler {
+	return &EventHandler{Query: query, Handle: handle, Opts: opts}
+}
+
+// resolveEventTypeName determines the event type name for an event instance:
+// its TypeString() if implemented, else the name given by the naming strategy (see SetEventTypeNaming).
 func resolveEventTypeName(event any) string {
 	if typer, ok := event.(interface{ TypeString() string }); ok {
 		return typer.TypeString()
 	}
-	return reflect.TypeOf(event).Name()
+	return nameEventType(reflect.TypeOf(event))
 }
 
 // convertQueryToDcb converts fairway.HandlerQuery to dcb.Query
@@ -56,6 +71,12 @@ func (q QueryItem) Types(events ...any) QueryItem {
 		typeName := resolveEventTypeName(e)
 		q.typeList = append(q.typeList, typeName)
 		q.typeRegistry[typeName] = reflect.TypeOf(e)
+
+		// Historical events stored under deprecated names
+		for _, alias := range typeAliasesOf(typeName) {
+			q.typeList = append(q.typeList, alias)
+			q.typeRegistry[alias] = reflect.TypeOf(e)
+		}
 	}
 	return q
 }
@@ -66,11 +87,27 @@ func (q QueryItem) Tags(tags ...string) QueryItem {
 	return q
 }
 
+// TagKeys adds required tag keys, whatever their value (AND semantics)
+func (q QueryItem) TagKeys(keys ...dcb.TagKey) QueryItem {
+	for _, key := range keys {
+		q.tagKeyList = append(q.tagKeyList, string(key))
+	}
+	return q
+}
+
+// After only matches events strictly after pos
+func (q QueryItem) After(pos dcb.Versionstamp) QueryItem {
+	q.after = &pos
+	return q
+}
+
 // toDcb converts to dcb.QueryItem
 func (q QueryItem) toDcb() dcb.QueryItem {
 	return dcb.QueryItem{
-		Types: q.typeList,
-		Tags:  q.tagList,
+		Types:   q.typeList,
+		Tags:    q.tagList,
+		TagKeys: q.tagKeyList,
+		After:   q.after,
 	}
 }
 
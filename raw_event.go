@@ -0,0 +1,59 @@
+package fairway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidRawEvent is returned when appending a RawEvent without type or with a non-JSON payload
+var ErrInvalidRawEvent = errors.New("invalid raw event")
+
+// RawEvent carries a pre-serialized JSON payload under an explicit type name, bypassing the Go type registry.
+// It lets non-Go producers (or ingestion adapters relaying them) share the store without fake Go types:
+//
+//	ra.AppendEvents(ctx, fairway.NewEvent(fairway.RawEvent{Type: "InvoicePaid", Tags: tags, Data: payload}))
+//
+// Read raw events back with QueryItem.RawTypes: their Data is a RawEvent holding the payload as stored.
+type RawEvent struct {
+	Type string
+	Tags []string
+	Data json.RawMessage // the event payload, without the envelope
+}
+
+var rawEventType = reflect.TypeOf(RawEvent{})
+
+// TypeString returns the explicit type name
+func (e RawEvent) TypeString() string { return e.Type }
+
+// MarshalJSON writes the payload verbatim
+func (e RawEvent) MarshalJSON() ([]byte, error) {
+	if e.Data == nil {
+		return []byte("null"), nil
+	}
+	return e.Data, nil
+}
+
+// validate checks the raw event can be stored
+func (e RawEvent) validate() error {
+	if e.Type == "" {
+		return fmt.Errorf("%w: missing type", ErrInvalidRawEvent)
+	}
+	if e.Data != nil && !json.Valid(e.Data) {
+		return fmt.Errorf("%w: payload of type %q is not valid JSON", ErrInvalidRawEvent, e.Type)
+	}
+	return nil
+}
+
+// RawTypes adds event types to match by name (OR semantics), delivered as RawEvent instead of being deserialized
+func (q QueryItem) RawTypes(names ...string) QueryItem {
+	if q.typeRegistry == nil {
+		q.typeRegistry = make(map[string]reflect.Type)
+	}
+	for _, name := range names {
+		q.typeList = append(q.typeList, name)
+		q.typeRegistry[name] = rawEventType
+	}
+	return q
+}
@@ -0,0 +1,65 @@
+package fairway_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawEvent_RoundTripsWithoutGoType(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	runner := fairway.NewCommandRunner(store)
+	payload := json.RawMessage(`{"invoiceId":"42","amount":1200}`)
+
+	// When - a raw event and a Go event are appended
+	err := runner.RunPure(context.Background(), commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx,
+			fairway.NewEvent(fairway.RawEvent{Type: "InvoicePaid", Tags: []string{"invoice:42"}, Data: payload}),
+			fairway.NewEvent(PageItem{N: 7}),
+		)
+	}))
+	require.NoError(t, err)
+
+	// Then - both are read back as raw bytes by name
+	var raws []fairway.RawEvent
+	reader := fairway.NewReader(store)
+	query := fairway.QueryItems(fairway.NewQueryItem().RawTypes("InvoicePaid", "PageItem"))
+	require.NoError(t, reader.ReadEvents(context.Background(), query, func(e fairway.Event) bool {
+		raws = append(raws, e.Data.(fairway.RawEvent))
+		return true
+	}))
+	require.Len(t, raws, 2)
+	assert.Equal(t, "InvoicePaid", raws[0].Type)
+	assert.Equal(t, []string{"invoice:42"}, raws[0].Tags)
+	assert.JSONEq(t, string(payload), string(raws[0].Data))
+	assert.Equal(t, "PageItem", raws[1].Type)
+	assert.JSONEq(t, `{"n":7}`, string(raws[1].Data))
+
+	// Then - tags of raw events are queryable
+	stored := dcb.CollectEvents(t, store.Read(context.Background(), dcb.Query{Items: []dcb.QueryItem{{Tags: []string{"invoice:42"}}}}, nil))
+	require.Len(t, stored, 1)
+	assert.Equal(t, "InvoicePaid", stored[0].Type)
+}
+
+func TestRawEvent_RejectsInvalidEvents(t *testing.T) {
+	t.Parallel()
+
+	for name, raw := range map[string]fairway.RawEvent{
+		"missing type": {Data: json.RawMessage(`{}`)},
+		"invalid JSON": {Type: "InvoicePaid", Data: json.RawMessage(`{`)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fairway.ToDcbEvent(fairway.NewEvent(raw))
+
+			assert.ErrorIs(t, err, fairway.ErrInvalidRawEvent)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ErrReversePagination is returned by ReadPage for reverse queries (pages are in ascending order)
+var ErrReversePagination = errors.New("pagination does not support reverse queries")
+
+// StoredEvent is a deserialized event with its position in the store
+type StoredEvent struct {
+	Event
+	Position dcb.Versionstamp
+}
+
+// Page is a slice of the events matching a query
+type Page struct {
+	Events  []StoredEvent
+	Next    string // cursor of the next page, empty when HasMore is false
+	HasMore bool
+}
+
+// Reader is the read API for views
+type Reader interface {
+	EventsReader
+	// Stream returns the events matching the query, with their positions
+	Stream(ctx context.Context, query *Query, opts ...ReadOption) iter.Seq2[StoredEvent, error]
+	// ReadPage returns up to size events following cursor ("" for the first page)
+	ReadPage(ctx context.Context, query *Query, cursor string, size int, opts ...ReadOption) (Page, error)
+}
+
+// ReadOption bounds a single read
+type ReadOption func(*readSettings)
+
+type readSettings struct {
+	after   *dcb.Versionstamp
+	until   *dcb.Versionstamp
+	timeout time.Duration
+	limit   int
+	reverse bool
+}
+
+func newReadSettings(opts []ReadOption) readSettings {
+	var s readSettings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// readOptions returns the query's read options overridden by the settings
+func (s readSettings) readOptions(query *Query) dcb.ReadOptions {
+	readOpts := dcb.ReadOptions{}
+	if query.opts != nil {
+		readOpts = *query.opts
+	}
+	if s.after != nil {
+		readOpts.After = s.after
+	}
+	if s.limit > 0 {
+		readOpts.Limit = s.limit
+	}
+	if s.reverse {
+		readOpts.Reverse = true
+	}
+	return readOpts
+}
+
+// ReadAfter only returns events strictly after pos (overrides the query's After)
+func ReadAfter(pos dcb.Versionstamp) ReadOption {
+	return func(s *readSettings) {
+		s.after = &pos
+	}
+}
+
+// ReadUntil only returns events up to pos, inclusive (e.g. a position returned by a command)
+func ReadUntil(pos dcb.Versionstamp) ReadOption {
+	return func(s *readSettings) {
+		s.until = &pos
+	}
+}
+
+// ReadLimit returns at most n events (overrides the query's Limit)
+func ReadLimit(n int) ReadOption {
+	return func(s *readSettings) {
+		if n > 0 {
+			s.limit = n
+		}
+	}
+}
+
+// ReadReverse returns events from the newest to the oldest
+func ReadReverse() ReadOption {
+	return func(s *readSettings) {
+		s.reverse = true
+	}
+}
+
+// ReadLatest returns the n most recent events, newest first
+// (e.g. decisions that only depend on the last state change)
+func ReadLatest(n int) ReadOption {
+	return func(s *readSettings) {
+		s.reverse = true
+		ReadLimit(n)(s)
+	}
+}
+
+// ReadTimeout bounds the duration of the read, on top of the context deadline
+func ReadTimeout(d time.Duration) ReadOption {
+	return func(s *readSettings) {
+		if d > 0 {
+			s.timeout = d
+		}
+	}
+}
+
+// Stream returns the events matching the query, with their positions
+func (ra viewReader) Stream(ctx context.Context, query *Query, opts ...ReadOption) iter.Seq2[StoredEvent, error] {
+	return func(yield func(StoredEvent, error) bool) {
+		settings := newReadSettings(opts)
+		if settings.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, settings.timeout)
+			defer cancel()
+		}
+
+		// Auto-register types from query
+		for _, item := range query.items {
+			ra.eventRegistry.registerTypes(item.typeRegistry)
+		}
+
+		readOpts := settings.readOptions(query)
+		dcbQuery := *query.toDcb()
+		if ra.cache == nil {
+			ra.stream(ctx, dcbQuery, readOpts, settings.until, yield)
+			return
+		}
+
+		// Cached results are served as long as no matching event was appended since
+		key := readCacheKey(dcbQuery, readOpts, settings.until)
+		latest, err := ra.latestPosition(ctx, dcbQuery)
+		if err != nil {
+			yield(StoredEvent{}, readError(ctx, err))
+			return
+		}
+		if events, ok := ra.cache.get(key, latest); ok {
+			for _, ev := range events {
+				if !yield(ev, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		var events []StoredEvent
+		complete := ra.stream(ctx, dcbQuery, readOpts, settings.until, func(ev StoredEvent, err error) bool {
+			if err == nil {
+				events = append(events, ev)
+			}
+			return yield(ev, err)
+		})
+		if complete {
+			ra.cache.put(key, latest, events)
+		}
+	}
+}
+
+// stream yields the deserialized events of the query, returns false if it stopped early (consumer or error)
+func (ra viewReader) stream(ctx context.Context, query dcb.Query, readOpts dcb.ReadOptions, until *dcb.Versionstamp, yield func(StoredEvent, error) bool) bool {
+	for dcbStoredEvent, err := range ra.store.Read(ctx, query, &readOpts) {
+		if err != nil {
+			yield(StoredEvent{}, readError(ctx, err))
+			return false
+		}
+
+		if until != nil && dcbStoredEvent.Position.Compare(*until) > 0 {
+			if readOpts.Reverse {
+				continue // newer than the bound, older ones follow
+			}
+			return true
+		}
+
+		// Deserialize dcb.Event → Event
+		ev, ok, err := ra.eventRegistry.decode(dcbStoredEvent.Event)
+		if err != nil {
+			yield(StoredEvent{}, fmt.Errorf("deserializing event at position %x: %w", dcbStoredEvent.Position[:], err))
+			return false
+		}
+		if !ok {
+			continue
+		}
+		ev.CommittedAt = dcbStoredEvent.CommittedAt
+		ev.Position = dcbStoredEvent.Position
+		ev.Superseded = dcbStoredEvent.Superseded
+		ev.Sequence = dcbStoredEvent.Sequence
+
+		if !yield(StoredEvent{Event: ev, Position: dcbStoredEvent.Position}, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// readError wraps a store read error
+func readError(ctx context.Context, err error) error {
+	// context errors already have context
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("reading events: %w", err)
+}
+
+// ReadPage returns up to size events following cursor ("" for the first page).
+// Pass Page.Next as the cursor of the following call. Cursors are opaque URL-safe tokens.
+func (ra viewReader) ReadPage(ctx context.Context, query *Query, cursor string, size int, opts ...ReadOption) (Page, error) {
+	return readPage(query, cursor, size, opts, func(opts []ReadOption) iter.Seq2[StoredEvent, error] {
+		return ra.Stream(ctx, query, opts...)
+	})
+}
+
+// readPage collects the page following cursor from the events stream returns for the page's read options
+func readPage(query *Query, cursor string, size int, opts []ReadOption, stream func([]ReadOption) iter.Seq2[StoredEvent, error]) (Page, error) {
+	if size <= 0 {
+		return Page{}, fmt.Errorf("page size must be positive, got %d", size)
+	}
+	if newReadSettings(opts).readOptions(query).Reverse {
+		return Page{}, ErrReversePagination
+	}
+	if cursor != "" {
+		after, err := dcb.ParsePositionToken(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		opts = append(opts, ReadAfter(after))
+	}
+
+	var page Page
+	for ev, err := range stream(opts) {
+		if err != nil {
+			return Page{}, err
+		}
+		if len(page.Events) == size {
+			page.HasMore = true
+			page.Next = page.Events[size-1].Position.Token()
+			break
+		}
+		page.Events = append(page.Events, ev)
+	}
+	return page, nil
+}
+
+// StreamOf streams the Data of the events matching the query that are of type T
+func StreamOf[T any](ctx context.Context, r Reader, query *Query, opts ...ReadOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for ev, err := range r.Stream(ctx, query, opts...) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if data, ok := ev.Data.(T); ok {
+				if !yield(data, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package fairway
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// readCache keeps the results of complete reads, keyed by query and read bounds.
+// An entry is valid as long as no event matching the query was appended since it was read:
+// it records the position of the latest matching event at that time.
+type readCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // of *readCacheEntry, most recently used first
+}
+
+type readCacheEntry struct {
+	key       string
+	latest    *dcb.Versionstamp // latest event matching the query when read (nil = none)
+	checkedAt time.Time         // when latest was last confirmed
+	events    []StoredEvent
+}
+
+// WithReadCache caches the results of up to maxEntries reads in process.
+// A cached result is served after a single-event lookup confirms no matching event was appended since,
+// instead of reading every event again. Reads stopped early (e.g. pages followed by more events) are not cached.
+// Cached events are shared by every read serving them: handlers must not mutate their Data.
+func WithReadCache(maxEntries int) ReaderOption {
+	return func(r *viewReader) {
+		if maxEntries > 0 {
+			r.cache = newReadCache(maxEntries)
+		}
+	}
+}
+
+// newReadCache returns an empty cache of maxEntries reads
+func newReadCache(maxEntries int) *readCache {
+	return &readCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// fresh returns the events cached under key if their latest position was confirmed within ttl
+func (c *readCache) fresh(key string, ttl time.Duration) ([]StoredEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*readCacheEntry)
+	if time.Since(entry.checkedAt) >= ttl {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.events, true
+}
+
+// get returns the events cached under key if they were read at latest
+func (c *readCache) get(key string, latest *dcb.Versionstamp) ([]StoredEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*readCacheEntry)
+	if !samePosition(entry.latest, latest) {
+		// events were appended: the entry can't serve anymore
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	entry.checkedAt = time.Now()
+	c.lru.MoveToFront(elem)
+	return entry.events, true
+}
+
+// put caches events read when latest was the latest matching event, evicting the least recently used entry if full
+func (c *readCache) put(key string, latest *dcb.Versionstamp, events []StoredEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&readCacheEntry{key: key, latest: latest, checkedAt: time.Now(), events: events})
+	if c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*readCacheEntry).key)
+	}
+}
+
+func samePosition(a, b *dcb.Versionstamp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// latestPosition returns the position of the latest event matching the query, nil if there is none
+func (ra viewReader) latestPosition(ctx context.Context, query dcb.Query) (*dcb.Versionstamp, error) {
+	for ev, err := range ra.store.Read(ctx, query, &dcb.ReadOptions{Reverse: true, Limit: 1}) {
+		if err != nil {
+			return nil, err
+		}
+		return &ev.Position, nil
+	}
+	return nil, nil
+}
+
+// readCacheKey identifies a read: its query items and every bound applied to them
+func readCacheKey(query dcb.Query, opts dcb.ReadOptions, until *dcb.Versionstamp) string {
+	var b strings.Builder
+	for _, item := range query.Items {
+		b.WriteString(queryItemIdentity(item))
+		if item.After != nil {
+			b.WriteString("\x01" + item.After.String())
+		}
+		b.WriteString("\x02")
+	}
+	if opts.After != nil {
+		b.WriteString("a" + opts.After.String())
+	}
+	if until != nil {
+		b.WriteString("u" + until.String())
+	}
+	b.WriteString("l" + strconv.Itoa(opts.Limit))
+	b.WriteString("r" + strconv.FormatBool(opts.Reverse))
+	return b.String()
+}
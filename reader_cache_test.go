@@ -0,0 +1,163 @@
+package fairway_test
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readCountingStore counts the reads hitting the store
+type readCountingStore struct {
+	dcb.DcbStore
+	reads atomic.Int32
+}
+
+func (s *readCountingStore) Read(ctx context.Context, query dcb.Query, opts *dcb.ReadOptions) iter.Seq2[dcb.StoredEvent, error] {
+	s.reads.Add(1)
+	return s.DcbStore.Read(ctx, query, opts)
+}
+
+func TestReadCache_ServesUntilAMatchingEventIsAppended(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	store := &readCountingStore{DcbStore: base}
+	reader := fairway.NewReader(store, fairway.WithReadCache(10))
+	appendPageItems(t, base, 3)
+	sum := func() int {
+		total, err := fairway.FoldView(context.Background(), reader, pageItemsQuery(), 0, sumPageItems)
+		require.NoError(t, err)
+		return total
+	}
+
+	// When - the same view is read twice
+	first := sum()
+	readsAfterFirst := store.reads.Load()
+	second := sum()
+
+	// Then - the second read only checked the latest matching position
+	assert.Equal(t, 0+1+2, first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, readsAfterFirst+1, store.reads.Load())
+
+	// When - a matching event is appended
+	appendPageItems(t, base, 4)
+
+	// Then - the view is read again
+	assert.Equal(t, 3+(0+1+2+3), sum())
+}
+
+func TestReadCache_DistinguishesReadBounds(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	reader := fairway.NewReader(store, fairway.WithReadCache(10))
+	appendPageItems(t, store, 3)
+	count := func(opts ...fairway.ReadOption) int {
+		n := 0
+		require.NoError(t, reader.ReadEvents(context.Background(), pageItemsQuery(), func(fairway.Event) bool {
+			n++
+			return true
+		}, opts...))
+		return n
+	}
+
+	// When/Then - reads with different bounds don't share results
+	assert.Equal(t, 3, count())
+	assert.Equal(t, 1, count(fairway.ReadLimit(1)))
+	assert.Equal(t, 3, count())
+}
+
+func TestCachedReader_ServesFromMemoryWithinTTL(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	store := &readCountingStore{DcbStore: base}
+	reader := fairway.NewCachedReader(fairway.NewReader(store), 100*time.Millisecond)
+	appendPageItems(t, base, 3)
+	sum := func() int {
+		total, err := fairway.FoldView(context.Background(), reader, pageItemsQuery(), 0, sumPageItems)
+		require.NoError(t, err)
+		return total
+	}
+
+	// When - the same view is read twice within the ttl
+	first := sum()
+	readsAfterFirst := store.reads.Load()
+	second := sum()
+
+	// Then - the second read doesn't hit the store
+	assert.Equal(t, 0+1+2, first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, readsAfterFirst, store.reads.Load())
+
+	// When - a matching event is appended, once the ttl elapsed
+	appendPageItems(t, base, 4)
+	time.Sleep(150 * time.Millisecond)
+
+	// Then - the view is read again
+	assert.Equal(t, 3+(0+1+2+3), sum())
+}
+
+func TestCachedReader_ChecksTheLatestPositionAfterTTL(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	store := &readCountingStore{DcbStore: base}
+	reader := fairway.NewCachedReader(fairway.NewReader(store), 0)
+	appendPageItems(t, base, 3)
+	sum := func() int {
+		total, err := fairway.FoldView(context.Background(), reader, pageItemsQuery(), 0, sumPageItems)
+		require.NoError(t, err)
+		return total
+	}
+
+	// When
+	first := sum()
+	readsAfterFirst := store.reads.Load()
+	second := sum()
+
+	// Then - nothing was appended: only the latest matching position was read
+	assert.Equal(t, first, second)
+	assert.Equal(t, readsAfterFirst+1, store.reads.Load())
+}
+
+func TestCachedReader_CachesPages(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	store := &readCountingStore{DcbStore: base}
+	reader := fairway.NewCachedReader(fairway.NewReader(store), time.Minute)
+	appendPageItems(t, base, 5)
+	ctx := context.Background()
+
+	// When - the first two pages are read twice
+	first, err := reader.ReadPage(ctx, pageItemsQuery(), "", 2)
+	require.NoError(t, err)
+	second, err := reader.ReadPage(ctx, pageItemsQuery(), first.Next, 2)
+	require.NoError(t, err)
+	reads := store.reads.Load()
+	firstAgain, err := reader.ReadPage(ctx, pageItemsQuery(), "", 2)
+	require.NoError(t, err)
+	secondAgain, err := reader.ReadPage(ctx, pageItemsQuery(), first.Next, 2)
+	require.NoError(t, err)
+
+	// Then - the pages are served from memory, with the same cursors
+	assert.Equal(t, reads, store.reads.Load())
+	assert.Equal(t, first, firstAgain)
+	assert.Equal(t, second, secondAgain)
+	assert.True(t, second.HasMore)
+	assert.Len(t, second.Events, 2)
+}
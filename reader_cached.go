@@ -0,0 +1,164 @@
+package fairway
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// defaultCachedReaderEntries is the number of reads a cached reader keeps by default
+const defaultCachedReaderEntries = 1000
+
+// cachedReader serves the reads of a Reader from memory, see NewCachedReader
+type cachedReader struct {
+	reader Reader
+	ttl    time.Duration
+	cache  *readCache
+}
+
+// CachedReaderOption configures a reader created with NewCachedReader
+type CachedReaderOption func(*cachedReader)
+
+// WithCachedReaderMaxEntries sets the number of reads kept, the least recently used are evicted (default: 1000)
+func WithCachedReaderMaxEntries(n int) CachedReaderOption {
+	return func(r *cachedReader) {
+		if n > 0 {
+			r.cache = newReadCache(n)
+		}
+	}
+}
+
+// NewCachedReader decorates reader with an in-process cache of deserialized read results, keyed by
+// query and read bounds, for views called at high frequency with identical queries (e.g. homepage feeds).
+//
+// A result is served from memory, without any read, for ttl after the position of the latest event
+// matching its query was last confirmed: views may then lag behind the log by up to ttl. After that,
+// a single-event read checks the position again; the result is served as long as it didn't move,
+// and read again otherwise. A ttl of 0 checks on every read (see WithReadCache).
+//
+// Reads stopped early are not cached. Cached events are shared by every read serving them:
+// handlers must not mutate their Data.
+func NewCachedReader(reader Reader, ttl time.Duration, opts ...CachedReaderOption) Reader {
+	r := cachedReader{reader: reader, ttl: ttl, cache: newReadCache(defaultCachedReaderEntries)}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+// Stream returns the events matching the query, from the cache when still valid
+func (r cachedReader) Stream(ctx context.Context, query *Query, opts ...ReadOption) iter.Seq2[StoredEvent, error] {
+	return func(yield func(StoredEvent, error) bool) {
+		key := cachedReadKey(query, opts)
+		events, latest, ok, err := r.lookup(ctx, key, query, opts)
+		if err != nil {
+			yield(StoredEvent{}, err)
+			return
+		}
+		if ok {
+			yieldAll(events, yield)
+			return
+		}
+
+		for ev, err := range r.reader.Stream(ctx, query, opts...) {
+			if err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+			events = append(events, ev)
+			if !yield(ev, nil) {
+				return
+			}
+		}
+		r.cache.put(key, latest, events)
+	}
+}
+
+// lookup returns the events cached under key if still valid,
+// otherwise the position of the latest event to cache the read with
+func (r cachedReader) lookup(ctx context.Context, key string, query *Query, opts []ReadOption) ([]StoredEvent, *dcb.Versionstamp, bool, error) {
+	if events, ok := r.cache.fresh(key, r.ttl); ok {
+		return events, nil, true, nil
+	}
+	latest, err := r.latestPosition(ctx, query, opts)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if events, ok := r.cache.get(key, latest); ok {
+		return events, nil, true, nil
+	}
+	return nil, latest, false, nil
+}
+
+// cachedReadKey identifies a read of query bounded by opts
+func cachedReadKey(query *Query, opts []ReadOption) string {
+	settings := newReadSettings(opts)
+	return readCacheKey(*query.toDcb(), settings.readOptions(query), settings.until)
+}
+
+// latestPosition returns the position of the latest event within the bounds of the read, nil if there is none
+func (r cachedReader) latestPosition(ctx context.Context, query *Query, opts []ReadOption) (*dcb.Versionstamp, error) {
+	latestOpts := append(opts[:len(opts):len(opts)], ReadLatest(1))
+	for ev, err := range r.reader.Stream(ctx, query, latestOpts...) {
+		if err != nil {
+			return nil, err
+		}
+		return &ev.Position, nil
+	}
+	return nil, nil
+}
+
+// ReadEvents dispatches the events matching the query to handler, from the cache when still valid
+func (r cachedReader) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error {
+	if handler == nil {
+		return nil
+	}
+	for ev, err := range r.Stream(ctx, query, opts...) {
+		if err != nil {
+			return err
+		}
+		if !handler(ev.Event) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// ReadPage returns up to size events following cursor, each page cached as its own read
+func (r cachedReader) ReadPage(ctx context.Context, query *Query, cursor string, size int, opts ...ReadOption) (Page, error) {
+	return readPage(query, cursor, size, opts, func(opts []ReadOption) iter.Seq2[StoredEvent, error] {
+		return func(yield func(StoredEvent, error) bool) {
+			// the page and the event telling whether another one follows
+			key := cachedReadKey(query, opts) + "p" + strconv.Itoa(size)
+			events, latest, ok, err := r.lookup(ctx, key, query, opts)
+			if err != nil {
+				yield(StoredEvent{}, err)
+				return
+			}
+			if !ok {
+				for ev, err := range r.reader.Stream(ctx, query, opts...) {
+					if err != nil {
+						yield(StoredEvent{}, err)
+						return
+					}
+					if events = append(events, ev); len(events) > size {
+						break
+					}
+				}
+				r.cache.put(key, latest, events)
+			}
+			yieldAll(events, yield)
+		}
+	})
+}
+
+func yieldAll(events []StoredEvent, yield func(StoredEvent, error) bool) {
+	for _, ev := range events {
+		if !yield(ev, nil) {
+			return
+		}
+	}
+}
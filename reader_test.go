@@ -0,0 +1,144 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+// PageItem is an event used by reader tests
+type PageItem struct {
+	N int `json:"n"`
+}
+
+// PageNote is an event used by reader tests
+type PageNote struct{}
+
+func pageItemsQuery() *fairway.Query {
+	return fairway.QueryItems(fairway.NewQueryItem().Types(PageItem{}, PageNote{}))
+}
+
+func appendPageItems(t require.TestingT, store dcb.DcbStore, count int) {
+	for i := range count {
+		ev, err := fairway.ToDcbEvent(fairway.NewEvent(PageItem{N: i}))
+		require.NoError(t, err)
+		require.NoError(t, store.Append(context.Background(), []dcb.Event{ev}))
+	}
+}
+
+func TestReader_ReadPageWalksEveryEventOnce(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		store := dcb.SetupTestStore(tt)
+		count := rapid.IntRange(0, 12).Draw(t, "count")
+		size := rapid.IntRange(1, 5).Draw(t, "size")
+		appendPageItems(t, store, count)
+		reader := fairway.NewReader(store)
+
+		// When
+		var seen []int
+		pages := 0
+		cursor := ""
+		for {
+			page, err := reader.ReadPage(context.Background(), pageItemsQuery(), cursor, size)
+			require.NoError(t, err)
+			pages++
+			for _, ev := range page.Events {
+				seen = append(seen, ev.Data.(PageItem).N)
+			}
+			if !page.HasMore {
+				break
+			}
+			cursor = page.Next
+		}
+
+		// Then
+		expected := make([]int, count)
+		for i := range expected {
+			expected[i] = i
+		}
+		assert.Equal(t, expected, append([]int{}, seen...))
+		assert.Equal(t, max(1, (count+size-1)/size), pages)
+	})
+}
+
+func TestReader_StreamIsPositionBounded(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendPageItems(t, store, 5)
+	reader := fairway.NewReader(store)
+	var positions []dcb.Versionstamp
+	for ev, err := range reader.Stream(context.Background(), pageItemsQuery()) {
+		require.NoError(t, err)
+		positions = append(positions, ev.Position)
+	}
+	require.Len(t, positions, 5)
+
+	// When
+	var bounded []int
+	for n, err := range fairway.StreamOf[PageItem](context.Background(), reader, pageItemsQuery(),
+		fairway.ReadAfter(positions[0]), fairway.ReadUntil(positions[3])) {
+		require.NoError(t, err)
+		bounded = append(bounded, n.N)
+	}
+
+	// Then
+	assert.Equal(t, []int{1, 2, 3}, bounded)
+}
+
+func TestReader_ReadTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendPageItems(t, store, 1)
+
+	// When
+	var err error
+	for _, readErr := range fairway.NewReader(store).Stream(context.Background(), pageItemsQuery(), fairway.ReadTimeout(time.Nanosecond)) {
+		err = readErr
+	}
+
+	// Then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestReader_ReadPageRejectsInvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	_, err := fairway.NewReader(dcb.SetupTestStore(t)).ReadPage(context.Background(), pageItemsQuery(), "garbage!", 10)
+
+	assert.ErrorIs(t, err, dcb.ErrInvalidPositionToken)
+}
+
+func TestReader_EventsCarryTheirCommitTime(t *testing.T) {
+	t.Parallel()
+
+	// Given - a producer with a clock in the past
+	store := dcb.SetupTestStore(t)
+	ev, err := fairway.ToDcbEvent(fairway.NewEventAt(PageItem{N: 1}, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	before := time.Now()
+	require.NoError(t, store.Append(context.Background(), []dcb.Event{ev}))
+
+	// When
+	var read []fairway.StoredEvent
+	for ev, err := range fairway.NewReader(store).Stream(context.Background(), pageItemsQuery()) {
+		require.NoError(t, err)
+		read = append(read, ev)
+	}
+
+	// Then - the commit time comes from the store, not from the payload
+	require.Len(t, read, 1)
+	assert.Equal(t, 2000, read[0].OccurredAt.Year())
+	assert.False(t, read[0].CommittedAt.Before(before))
+}
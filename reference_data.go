@@ -0,0 +1,156 @@
+package fairway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// defaultReferenceDataCacheTTL is how long looked up values are served from memory by default
+const defaultReferenceDataCacheTTL = time.Minute
+
+// ReferenceData is a Lookup over values stored as JSON in FoundationDB, beside the event log:
+// namespace/reference/<name>/<key>. Values are set by Put and Delete (an import job, an admin endpoint...),
+// they are not events: views joining them show their current version.
+//
+// Values looked up are cached in process, misses included. Put and Delete invalidate the cache of
+// their process at once, other processes see changes within the cache TTL.
+// The cache isn't bounded: it suits reference data, small next to the event log.
+type ReferenceData[V any] struct {
+	db  fdb.Database
+	dir subspace.Subspace
+	ttl time.Duration
+
+	mu          sync.Mutex
+	cache       map[string]referenceEntry[V]
+	invalidated uint64 // counts invalidations, so that a Get racing one doesn't cache the previous value
+}
+
+type referenceEntry[V any] struct {
+	value    V
+	found    bool
+	cachedAt time.Time
+}
+
+// ReferenceDataOption configures a ReferenceData
+type ReferenceDataOption func(*referenceDataSettings)
+
+type referenceDataSettings struct {
+	ttl time.Duration
+}
+
+// WithReferenceDataCacheTTL sets how long values are served from memory (default: 1m, 0 reads FoundationDB on every Get)
+func WithReferenceDataCacheTTL(d time.Duration) ReferenceDataOption {
+	return func(s *referenceDataSettings) {
+		if d >= 0 {
+			s.ttl = d
+		}
+	}
+}
+
+// NewReferenceData returns the reference data called name in the store's namespace
+func NewReferenceData[V any](store dcb.DcbStore, name string, opts ...ReferenceDataOption) *ReferenceData[V] {
+	settings := referenceDataSettings{ttl: defaultReferenceDataCacheTTL}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+	return &ReferenceData[V]{
+		db:    store.Database(),
+		dir:   subspace.Sub(store.Namespace() + "/reference/" + name),
+		ttl:   settings.ttl,
+		cache: make(map[string]referenceEntry[V]),
+	}
+}
+
+// Get returns the value of key, from the cache while fresh
+func (r *ReferenceData[V]) Get(ctx context.Context, key string) (V, bool, error) {
+	var zero V
+	entry, invalidated, ok := r.cached(key)
+	if ok {
+		return entry.value, entry.found, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return zero, false, err
+	}
+
+	raw, err := r.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		return tr.Get(r.dir.Pack(tuple.Tuple{key})).Get()
+	})
+	if err != nil {
+		return zero, false, err
+	}
+	entry = referenceEntry[V]{cachedAt: time.Now()}
+	if data := raw.([]byte); data != nil {
+		if err := json.Unmarshal(data, &entry.value); err != nil {
+			return zero, false, fmt.Errorf("reference data %q: %w", key, err)
+		}
+		entry.found = true
+	}
+
+	r.mu.Lock()
+	if r.ttl > 0 && r.invalidated == invalidated {
+		r.cache[key] = entry
+	}
+	r.mu.Unlock()
+	return entry.value, entry.found, nil
+}
+
+// cached returns the fresh cached entry of key, and the number of invalidations so far
+func (r *ReferenceData[V]) cached(key string) (referenceEntry[V], uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.cache[key]
+	if !ok || time.Since(entry.cachedAt) >= r.ttl {
+		return referenceEntry[V]{}, r.invalidated, false
+	}
+	return entry, r.invalidated, true
+}
+
+// Put sets the value of key
+func (r *ReferenceData[V]) Put(ctx context.Context, key string, value V) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("reference data %q: %w", key, err)
+	}
+	return r.write(ctx, key, func(tr fdb.Transaction, k fdb.Key) { tr.Set(k, data) })
+}
+
+// Delete removes key
+func (r *ReferenceData[V]) Delete(ctx context.Context, key string) error {
+	return r.write(ctx, key, func(tr fdb.Transaction, k fdb.Key) { tr.Clear(k) })
+}
+
+func (r *ReferenceData[V]) write(ctx context.Context, key string, op func(fdb.Transaction, fdb.Key)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := r.db.Transact(func(tr fdb.Transaction) (any, error) {
+		op(tr, r.dir.Pack(tuple.Tuple{key}))
+		return nil, nil
+	})
+	r.Invalidate(key)
+	return err
+}
+
+// Invalidate drops the cached value of key, e.g. when notified of a change made by another process
+func (r *ReferenceData[V]) Invalidate(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, key)
+	r.invalidated++
+}
+
+// InvalidateAll drops every cached value
+func (r *ReferenceData[V]) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.cache)
+	r.invalidated++
+}
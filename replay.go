@@ -0,0 +1,141 @@
+package fairway
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ReplayReport describes a command re-executed against historical store state
+type ReplayReport struct {
+	At      dcb.Versionstamp
+	Reads   []ReplayRead   // in the order the command issued them (parallel reads in handler order)
+	Appends []ReplayAppend // what the command tried to append, nothing was stored
+}
+
+// ReplayRead is a read made by the replayed command and the events it was given
+type ReplayRead struct {
+	Query  *Query
+	Events []Event
+}
+
+// ReplayAppend is an append made by the replayed command
+type ReplayAppend struct {
+	Events []Event
+	// Condition the append would have been checked against, nil for AppendEventsNoCondition or appends without reads
+	Condition *dcb.AppendCondition
+}
+
+// Emitted returns every event the replayed command tried to append
+func (r ReplayReport) Emitted() []Event {
+	var events []Event
+	for _, a := range r.Appends {
+		events = append(events, a.Events...)
+	}
+	return events
+}
+
+// ReplayCommand re-executes cmd against the store as it was at position at (e.g. the position
+// just before the events the command emitted), to answer "why did this command emit X".
+// Reads only see events up to at and appends are recorded instead of stored, so it is safe against production stores.
+// The report is returned even when the command fails.
+func ReplayCommand(ctx context.Context, store dcb.DcbStore, cmd Command, at dcb.Versionstamp) (ReplayReport, error) {
+	ra := newReplayReadAppender(store, at)
+	err := cmd.Run(ctx, ra)
+	return ra.report, err
+}
+
+// ReplayCommandWithEffect is ReplayCommand for commands with side effects.
+// deps are called again: pass stubs unless the effects are harmless.
+func ReplayCommandWithEffect[Deps any](ctx context.Context, store dcb.DcbStore, cmd CommandWithEffect[Deps], deps Deps, at dcb.Versionstamp) (ReplayReport, error) {
+	ra := newReplayReadAppender(store, at)
+	err := cmd.Run(ctx, ra, deps)
+	return ra.report, err
+}
+
+// replayReadAppender reads through a commandReadAppender bounded at a position and records everything
+type replayReadAppender struct {
+	inner  *commandReadAppender
+	report ReplayReport
+}
+
+func newReplayReadAppender(store dcb.DcbStore, at dcb.Versionstamp) *replayReadAppender {
+	return &replayReadAppender{
+		inner:  newReadAppenderExtended(store),
+		report: ReplayReport{At: at},
+	}
+}
+
+// readUntilAtMost bounds the read at pos, unless it is already bounded before
+func readUntilAtMost(pos dcb.Versionstamp) ReadOption {
+	return func(s *readSettings) {
+		if s.until == nil || s.until.Compare(pos) > 0 {
+			s.until = &pos
+		}
+	}
+}
+
+func (ra *replayReadAppender) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error {
+	if handler == nil {
+		return nil
+	}
+	read := ReplayRead{Query: query}
+	err := ra.inner.ReadEvents(ctx, query, func(e Event) bool {
+		read.Events = append(read.Events, e)
+		return handler(e)
+	}, append(slices.Clip(opts), readUntilAtMost(ra.report.At))...)
+	ra.report.Reads = append(ra.report.Reads, read)
+	return err
+}
+
+func (ra *replayReadAppender) ReadEventsParallel(ctx context.Context, handlers ...*EventHandler) error {
+	var mu sync.Mutex
+	reads := make([]*ReplayRead, len(handlers))
+	recorded := make([]*EventHandler, len(handlers))
+	for i, h := range handlers {
+		if h == nil || h.Handle == nil {
+			continue
+		}
+		read := &ReplayRead{Query: h.Query}
+		reads[i] = read
+		recorded[i] = NewEventHandler(h.Query, func(e Event) bool {
+			mu.Lock()
+			read.Events = append(read.Events, e)
+			mu.Unlock()
+			return h.Handle(e)
+		}, append(slices.Clip(h.Opts), readUntilAtMost(ra.report.At))...)
+	}
+
+	err := ra.inner.ReadEventsParallel(ctx, recorded...)
+	for _, read := range reads {
+		if read != nil {
+			ra.report.Reads = append(ra.report.Reads, *read)
+		}
+	}
+	return err
+}
+
+func (ra *replayReadAppender) AppendEvents(ctx context.Context, event Event, remainingEvents ...Event) error {
+	events := append([]Event{event}, remainingEvents...)
+	if _, err := serializeEvents(events); err != nil {
+		return err
+	}
+	appended := ReplayAppend{Events: events}
+	if len(ra.inner.reads) > 0 {
+		condition := ra.inner.condition()
+		appended.Condition = &condition
+	}
+	ra.report.Appends = append(ra.report.Appends, appended)
+	return nil
+}
+
+func (ra *replayReadAppender) AppendEventsNoCondition(ctx context.Context, event Event, remainingEvents ...Event) error {
+	events := append([]Event{event}, remainingEvents...)
+	if _, err := serializeEvents(events); err != nil {
+		return err
+	}
+	ra.report.Appends = append(ra.report.Appends, ReplayAppend{Events: events})
+	return nil
+}
@@ -0,0 +1,60 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noteOnSecondItem emits a PageNote when exactly two items exist
+var noteOnSecondItem = commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+	count := 0
+	if err := ra.ReadEvents(ctx, pageItemsQuery(), func(fairway.Event) bool {
+		count++
+		return true
+	}); err != nil {
+		return err
+	}
+	if count != 2 {
+		return nil
+	}
+	return ra.AppendEvents(ctx, fairway.NewEvent(PageNote{}))
+})
+
+func TestReplayCommand_SeesTheStoreAsOfThePosition(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	appendPageItems(t, store, 3)
+	stored := dcb.CollectEvents(t, store.ReadAll(context.Background()))
+	require.Len(t, stored, 3)
+
+	// When
+	report, err := fairway.ReplayCommand(context.Background(), store, noteOnSecondItem, stored[1].Position)
+
+	// Then - the command saw the first two items and emitted its note
+	require.NoError(t, err)
+	require.Len(t, report.Reads, 1)
+	require.Len(t, report.Reads[0].Events, 2)
+	assert.Equal(t, stored[1].Position, report.Reads[0].Events[1].Position)
+	assert.Equal(t, []fairway.Event{report.Appends[0].Events[0]}, report.Emitted())
+	assert.IsType(t, PageNote{}, report.Emitted()[0].Data)
+	require.NotNil(t, report.Appends[0].Condition)
+	assert.Equal(t, &stored[1].Position, report.Appends[0].Condition.Query.Items[0].After)
+
+	// Then - nothing was stored
+	assert.Len(t, dcb.CollectEvents(t, store.ReadAll(context.Background())), 3)
+
+	// When - replayed at the current state
+	report, err = fairway.ReplayCommand(context.Background(), store, noteOnSecondItem, stored[2].Position)
+
+	// Then
+	require.NoError(t, err)
+	assert.Len(t, report.Reads[0].Events, 3)
+	assert.Empty(t, report.Emitted())
+}
@@ -0,0 +1,164 @@
+package fairway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/err0r500/fairway/dcb"
+)
+
+// SnapshotKey identifies a snapshot: the decision model name and the tags of the events it folds.
+// Change the name (e.g. "cart-v2") when the model's fold logic changes.
+type SnapshotKey struct {
+	Name string
+	Tags []string
+}
+
+// SnapshotStore saves folded states so commands don't re-read thousands of events.
+// A snapshot records the position of the last event it folded: only the events after it are read again.
+type SnapshotStore interface {
+	// Save stores state as folded up to pos, unless a snapshot of a later position already exists
+	Save(ctx context.Context, key SnapshotKey, pos dcb.Versionstamp, state any) error
+	// Load decodes the snapshot into state and returns its position, false if there is no valid snapshot
+	Load(ctx context.Context, key SnapshotKey, state any) (dcb.Versionstamp, bool, error)
+	// InvalidateBefore discards the snapshots of name folded before pos (e.g. after fixing the fold logic)
+	InvalidateBefore(ctx context.Context, name string, pos dcb.Versionstamp) error
+}
+
+// Snapshot value format:
+// [position:12][state:variable]
+const snapshotPositionSize = 12
+
+// fdbSnapshotStore stores snapshots alongside the store's events
+type fdbSnapshotStore struct {
+	db         fdb.Database
+	states     subspace.Subspace // namespace/snapshots/s/<name>/<tags...>
+	watermarks subspace.Subspace // namespace/snapshots/w/<name>
+}
+
+// NewSnapshotStore creates a snapshot store in the store's namespace
+func NewSnapshotStore(store dcb.DcbStore) SnapshotStore {
+	dir := subspace.Sub(store.Namespace() + "/snapshots")
+	return fdbSnapshotStore{
+		db:         store.Database(),
+		states:     dir.Sub("s"),
+		watermarks: dir.Sub("w"),
+	}
+}
+
+func (s fdbSnapshotStore) Save(ctx context.Context, key SnapshotKey, pos dcb.Versionstamp, state any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("serializing snapshot %q: %w", key.Name, err)
+	}
+	value := make([]byte, snapshotPositionSize+len(data))
+	copy(value, pos[:])
+	copy(value[snapshotPositionSize:], data)
+
+	_, err = s.db.Transact(func(tr fdb.Transaction) (any, error) {
+		stateKey := s.key(key)
+		if current := tr.Get(stateKey).MustGet(); len(current) >= snapshotPositionSize &&
+			dcb.Versionstamp(current[:snapshotPositionSize]).Compare(pos) > 0 {
+			return nil, nil // a later snapshot won
+		}
+		tr.Set(stateKey, value)
+		return nil, nil
+	})
+	return err
+}
+
+func (s fdbSnapshotStore) Load(ctx context.Context, key SnapshotKey, state any) (dcb.Versionstamp, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return dcb.Versionstamp{}, false, err
+	}
+	res, err := s.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+		value := tr.Get(s.key(key)).MustGet()
+		if len(value) < snapshotPositionSize {
+			return nil, nil
+		}
+		watermark := tr.Get(s.watermarks.Pack(tuple.Tuple{key.Name})).MustGet()
+		if len(watermark) == snapshotPositionSize &&
+			dcb.Versionstamp(value[:snapshotPositionSize]).Compare(dcb.Versionstamp(watermark)) < 0 {
+			return nil, nil // invalidated
+		}
+		return value, nil
+	})
+	if err != nil || res == nil {
+		return dcb.Versionstamp{}, false, err
+	}
+
+	value := res.([]byte)
+	if err := json.Unmarshal(value[snapshotPositionSize:], state); err != nil {
+		return dcb.Versionstamp{}, false, fmt.Errorf("deserializing snapshot %q: %w", key.Name, err)
+	}
+	return dcb.Versionstamp(value[:snapshotPositionSize]), true, nil
+}
+
+func (s fdbSnapshotStore) InvalidateBefore(ctx context.Context, name string, pos dcb.Versionstamp) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := s.db.Transact(func(tr fdb.Transaction) (any, error) {
+		watermarkKey := s.watermarks.Pack(tuple.Tuple{name})
+		if current := tr.Get(watermarkKey).MustGet(); len(current) == snapshotPositionSize &&
+			dcb.Versionstamp(current).Compare(pos) > 0 {
+			return nil, nil // already invalidated further
+		}
+		tr.Set(watermarkKey, pos[:])
+		return nil, nil
+	})
+	return err
+}
+
+// key packs snapshots/s/<name>/<sorted tags...>
+func (s fdbSnapshotStore) key(key SnapshotKey) fdb.Key {
+	tags := slices.Sorted(slices.Values(key.Tags))
+	t := make(tuple.Tuple, 0, len(tags)+1)
+	t = append(t, key.Name)
+	for _, tag := range tags {
+		t = append(t, tag)
+	}
+	return s.states.Pack(t)
+}
+
+// FoldWithSnapshot folds the events of query into the state snapshotted under key: only the events
+// after the snapshot are read, and the new state is saved once at least saveEvery events were folded.
+// Inside a command, pass its EventReadAppender: the append stays protected, as the read starts at the snapshot position.
+func FoldWithSnapshot[T any](ctx context.Context, reader EventsReader, snapshots SnapshotStore, key SnapshotKey, query *Query, initial T, apply func(T, Event) T, saveEvery int) (T, error) {
+	state := initial
+	var opts []ReadOption
+	if pos, ok, err := snapshots.Load(ctx, key, &state); err != nil {
+		return initial, err
+	} else if ok {
+		opts = append(opts, ReadAfter(pos))
+	}
+
+	folded := 0
+	var last dcb.Versionstamp
+	err := reader.ReadEvents(ctx, query, func(e Event) bool {
+		state = apply(state, e)
+		if e.Position.Compare(last) > 0 {
+			last = e.Position
+		}
+		folded++
+		return true
+	}, opts...)
+	if err != nil {
+		return initial, err
+	}
+
+	if folded > 0 && folded >= saveEvery {
+		if err := snapshots.Save(ctx, key, last, state); err != nil {
+			return initial, fmt.Errorf("saving snapshot %q: %w", key.Name, err)
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,118 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sumPageItems(sum int, e fairway.Event) int {
+	if item, ok := e.Data.(PageItem); ok {
+		sum += item.N
+	}
+	return sum
+}
+
+func TestFoldWithSnapshot_OnlyReadsEventsAfterTheSnapshot(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Given - a snapshot of the first events
+	store := dcb.SetupTestStore(t)
+	snapshots := fairway.NewSnapshotStore(store)
+	reader := fairway.NewReader(store)
+	key := fairway.SnapshotKey{Name: "sum", Tags: []string{"b:1", "a:1"}}
+	appendPageItems(t, store, 4) // 0+1+2+3
+
+	sum, err := fairway.FoldWithSnapshot(ctx, reader, snapshots, key, pageItemsQuery(), 0, sumPageItems, 1)
+	require.NoError(t, err)
+	require.Equal(t, 6, sum)
+
+	// When - more events are appended
+	appendPageItems(t, store, 3) // 0+1+2
+	var read int
+	counting := fairway.NewReader(store)
+	sum, err = fairway.FoldWithSnapshot(ctx, countingReader{counting, &read}, snapshots, key, pageItemsQuery(), 0, sumPageItems, 100)
+	require.NoError(t, err)
+
+	// Then - the snapshot is reused, whatever the tags order
+	assert.Equal(t, 9, sum)
+	assert.Equal(t, 3, read)
+}
+
+func TestSnapshotStore_InvalidationAndStaleSaves(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	snapshots := fairway.NewSnapshotStore(store)
+	key := fairway.SnapshotKey{Name: "model", Tags: []string{"a:1"}}
+	older := dcb.Versionstamp{1}
+	newer := dcb.Versionstamp{2}
+	require.NoError(t, snapshots.Save(ctx, key, newer, "newer"))
+
+	// When - a slower writer saves an older state
+	require.NoError(t, snapshots.Save(ctx, key, older, "older"))
+
+	// Then - the later snapshot is kept
+	var state string
+	pos, ok, err := snapshots.Load(ctx, key, &state)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, newer, pos)
+	assert.Equal(t, "newer", state)
+
+	// When - snapshots before a later position are invalidated
+	require.NoError(t, snapshots.InvalidateBefore(ctx, "model", dcb.Versionstamp{3}))
+
+	// Then
+	_, ok, err = snapshots.Load(ctx, key, &state)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFoldWithSnapshot_CommandAppendStaysProtected(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Given - a snapshot covering the existing events
+	store := dcb.SetupTestStore(t)
+	snapshots := fairway.NewSnapshotStore(store)
+	key := fairway.SnapshotKey{Name: "sum"}
+	appendPageItems(t, store, 3)
+	_, err := fairway.FoldWithSnapshot(ctx, fairway.NewReader(store), snapshots, key, pageItemsQuery(), 0, sumPageItems, 1)
+	require.NoError(t, err)
+
+	runner := fairway.NewCommandRunner(store, fairway.WithRetryOptions(retry.Attempts(1)))
+
+	// When - an event is appended concurrently with the command
+	err = runner.RunPure(ctx, commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		if _, err := fairway.FoldWithSnapshot(ctx, ra, snapshots, key, pageItemsQuery(), 0, sumPageItems, 1); err != nil {
+			return err
+		}
+		appendPageItems(t, store, 1)
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageNote{}))
+	}))
+
+	// Then
+	assert.ErrorIs(t, err, dcb.ErrAppendConditionFailed)
+}
+
+// countingReader counts the events dispatched by the wrapped reader
+type countingReader struct {
+	fairway.EventsReader
+	count *int
+}
+
+func (r countingReader) ReadEvents(ctx context.Context, query *fairway.Query, handler fairway.EventHandlerFunc, opts ...fairway.ReadOption) error {
+	return r.EventsReader.ReadEvents(ctx, query, func(e fairway.Event) bool {
+		*r.count++
+		return handler(e)
+	}, opts...)
+}
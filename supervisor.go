@@ -0,0 +1,250 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRestartsExhausted is returned by Supervisor.Wait for the components that kept dying past the RestartPolicy
+var ErrRestartsExhausted = errors.New("restarts exhausted")
+
+// RestartPolicy configures how a Supervisor restarts components whose run loops died
+type RestartPolicy struct {
+	MaxRestarts int           // restarts before giving up (0 = never restart, -1 = unlimited)
+	Backoff     time.Duration // delay before the first restart, doubled on each subsequent one
+	MaxBackoff  time.Duration // upper bound of the restart delay
+}
+
+// defaultRestartPolicy returns the default restart policy
+func defaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRestarts: -1,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+// delay returns the backoff before the given restart (1-based)
+func (p RestartPolicy) delay(restart int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < restart && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// exhausted reports whether the given restart (1-based) is beyond the policy
+func (p RestartPolicy) exhausted(restart int) bool {
+	return p.MaxRestarts >= 0 && restart > p.MaxRestarts
+}
+
+// ComponentFactory builds a fresh component instance, called on every (re)start
+type ComponentFactory func() (Startable, error)
+
+// Supervisor owns background components (automations, projections), restarts them
+// with backoff when their run loops die, and aggregates their errors in one channel.
+type Supervisor struct {
+	factories []ComponentFactory
+	policy    RestartPolicy
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	ready  chan struct{}
+	errs   *errorReporter // the components' errors, on the Errors channel
+
+	closeErrs sync.Once
+	mu        sync.Mutex
+	gaveUp    []error // terminal errors of the components given up on
+}
+
+// SupervisorOption configures a Supervisor
+type SupervisorOption func(*Supervisor)
+
+// WithRestartPolicy sets the restart policy applied to every supervised component
+func WithRestartPolicy(p RestartPolicy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.policy = p
+	}
+}
+
+// WithSupervisorErrorHandler adds a handler of the errors of every supervised component,
+// and of their deaths and restarts
+func WithSupervisorErrorHandler(h ErrorHandler) SupervisorOption {
+	return func(s *Supervisor) {
+		s.errs.OnError(h)
+	}
+}
+
+// NewSupervisor creates a supervisor with no components
+func NewSupervisor(opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		policy: defaultRestartPolicy(),
+		ready:  make(chan struct{}),
+		errs:   newErrorReporter("supervisor"),
+	}
+	s.errs.withChannel(100)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add registers a component factory. Must be called before Start.
+func (s *Supervisor) Add(f ComponentFactory) {
+	s.factories = append(s.factories, f)
+}
+
+// Start builds and starts every component.
+// If any component fails its first start, already started ones are stopped and the error is returned.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, f := range s.factories {
+		started := make(chan error, 1)
+		s.wg.Add(1)
+		go s.supervise(f, started)
+		if err := <-started; err != nil {
+			s.Stop()
+			s.wg.Wait()
+			return err
+		}
+	}
+
+	close(s.ready)
+	return nil
+}
+
+// Stop signals every component to stop
+func (s *Supervisor) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Wait blocks until every component has stopped, then closes the Errors channel. Safe to call twice.
+// It returns the terminal errors of the components given up on (see ErrRestartsExhausted), joined.
+func (s *Supervisor) Wait() error {
+	s.wg.Wait()
+	s.closeErrs.Do(s.errs.close)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.gaveUp...)
+}
+
+// Ready is closed once every component has started for the first time
+func (s *Supervisor) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Errors returns the aggregated error channel of all supervised components.
+// Errors are dropped when nobody reads it and its buffer is full (see ErrorStats): prefer WithSupervisorErrorHandler.
+func (s *Supervisor) Errors() <-chan error {
+	return s.errs.channel()
+}
+
+// OnError adds a handler of the errors of every supervised component. Must be called before Start.
+func (s *Supervisor) OnError(h ErrorHandler) {
+	s.errs.OnError(h)
+}
+
+// ErrorStats counts the errors of the supervised components, and those the Errors channel dropped
+func (s *Supervisor) ErrorStats() ErrorStats {
+	return s.errs.ErrorStats()
+}
+
+// supervise runs a component until the supervisor stops, restarting it when it dies.
+// The first start result is reported on started.
+func (s *Supervisor) supervise(f ComponentFactory, started chan<- error) {
+	defer s.wg.Done()
+
+	for restart := 0; ; restart++ {
+		component, err := f()
+		if err == nil {
+			if reporter, ok := component.(ErrorReporter); ok {
+				queueId := component.QueueId()
+				reporter.OnError(func(err error) { s.report(fmt.Errorf("%s: %w", queueId, err)) })
+			}
+			err = component.Start(s.ctx)
+		}
+		if started != nil {
+			started <- err
+			started = nil
+			if err != nil {
+				return
+			}
+		}
+
+		if err == nil {
+			err = s.run(component)
+			if s.ctx.Err() != nil {
+				return // stopped on purpose
+			}
+			err = fmt.Errorf("%s died: %w", component.QueueId(), orStopped(err))
+		}
+		s.report(err)
+
+		if s.policy.exhausted(restart + 1) {
+			gaveUp := fmt.Errorf("%w: giving up after %d restarts: %w", ErrRestartsExhausted, restart, err)
+			s.mu.Lock()
+			s.gaveUp = append(s.gaveUp, gaveUp)
+			s.mu.Unlock()
+			s.report(gaveUp)
+			return
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(s.policy.delay(restart + 1)):
+		}
+	}
+}
+
+// run forwards the errors of components not implementing ErrorReporter,
+// and blocks until the component stops or the supervisor is stopped
+func (s *Supervisor) run(component Startable) error {
+	_, reporter := component.(ErrorReporter)
+	if withErrors, ok := component.(interface{ Errors() <-chan error }); ok && !reporter {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for err := range withErrors.Errors() {
+				s.report(fmt.Errorf("%s: %w", component.QueueId(), err))
+			}
+		}()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- component.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-s.ctx.Done():
+		component.Stop()
+		if err := <-done; err != nil {
+			s.report(fmt.Errorf("%s: %w", component.QueueId(), err))
+		}
+		return nil
+	}
+}
+
+// report delivers err to the handlers and the aggregated channel without blocking
+func (s *Supervisor) report(err error) {
+	s.errs.report(err)
+}
+
+var errStoppedUnexpectedly = errors.New("stopped unexpectedly")
+
+// orStopped returns err, or a generic error if the component exited without one
+func orStopped(err error) error {
+	if err == nil {
+		return errStoppedUnexpectedly
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package fairway_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeComponent runs until stopped, or dies right away when die is set
+type fakeComponent struct {
+	die   bool
+	done  chan struct{}
+	errCh chan error
+}
+
+func newFakeComponent(die bool) *fakeComponent {
+	return &fakeComponent{die: die, done: make(chan struct{}), errCh: make(chan error, 1)}
+}
+
+func (c *fakeComponent) QueueId() string { return "fake" }
+
+func (c *fakeComponent) Start(ctx context.Context) error {
+	go func() {
+		if c.die {
+			c.errCh <- errors.New("boom")
+		} else {
+			<-ctx.Done()
+		}
+		close(c.done)
+	}()
+	return nil
+}
+
+func (c *fakeComponent) Stop() {}
+
+func (c *fakeComponent) Wait() error {
+	<-c.done
+	close(c.errCh)
+	return nil
+}
+
+func (c *fakeComponent) Errors() <-chan error { return c.errCh }
+
+func TestSupervisor_RestartsDeadComponent(t *testing.T) {
+	// Given - a component that dies on its first two runs
+	var starts atomic.Int32
+	sup := fairway.NewSupervisor(fairway.WithRestartPolicy(fairway.RestartPolicy{
+		MaxRestarts: -1,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	}))
+	sup.Add(func() (fairway.Startable, error) {
+		return newFakeComponent(starts.Add(1) <= 2), nil
+	})
+
+	// When
+	require.NoError(t, sup.Start(context.Background()))
+
+	// Then - restarted until it stays up, errors are aggregated
+	<-sup.Ready()
+	assert.Eventually(t, func() bool { return starts.Load() == 3 }, time.Second, time.Millisecond)
+	sup.Stop()
+	require.NoError(t, sup.Wait())
+
+	var errs []error
+	for err := range sup.Errors() {
+		errs = append(errs, err)
+	}
+	assert.NotEmpty(t, errs)
+	assert.ErrorContains(t, errors.Join(errs...), "boom")
+	assert.ErrorContains(t, errors.Join(errs...), "fake died")
+}
+
+func TestSupervisor_GivesUpAfterMaxRestarts(t *testing.T) {
+	// Given - a component that always dies, one restart allowed
+	var starts atomic.Int32
+	sup := fairway.NewSupervisor(fairway.WithRestartPolicy(fairway.RestartPolicy{
+		MaxRestarts: 1,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	}))
+	sup.Add(func() (fairway.Startable, error) {
+		starts.Add(1)
+		return newFakeComponent(true), nil
+	})
+
+	// When
+	require.NoError(t, sup.Start(context.Background()))
+	err := sup.Wait()
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrRestartsExhausted)
+	assert.ErrorContains(t, err, "fake died: boom")
+	assert.Equal(t, err, sup.Wait(), "Wait can be called again")
+	assert.Equal(t, int32(2), starts.Load())
+	var errs []error
+	for err := range sup.Errors() {
+		errs = append(errs, err)
+	}
+	assert.ErrorContains(t, errors.Join(errs...), "giving up after 1 restarts")
+}
+
+func TestSupervisor_StartFailureIsReturned(t *testing.T) {
+	// Given
+	sup := fairway.NewSupervisor()
+	sup.Add(func() (fairway.Startable, error) { return newFakeComponent(false), nil })
+	sup.Add(func() (fairway.Startable, error) { return nil, errors.New("cannot build") })
+
+	// When
+	err := sup.Start(context.Background())
+
+	// Then - first component is stopped again
+	assert.ErrorContains(t, err, "cannot build")
+	assert.NoError(t, sup.Wait())
+}
+
+func TestSupervisor_ErrorHandlerReceivesComponentErrors(t *testing.T) {
+	// Given - a component dying once
+	var starts atomic.Int32
+	var mu sync.Mutex
+	var handled []error
+	sup := fairway.NewSupervisor(
+		fairway.WithRestartPolicy(fairway.RestartPolicy{MaxRestarts: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+		fairway.WithSupervisorErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, err)
+		}),
+	)
+	sup.Add(func() (fairway.Startable, error) {
+		return newFakeComponent(starts.Add(1) == 1), nil
+	})
+
+	// When
+	require.NoError(t, sup.Start(context.Background()))
+	assert.Eventually(t, func() bool { return starts.Load() == 2 }, time.Second, time.Millisecond)
+	sup.Stop()
+	require.NoError(t, sup.Wait())
+
+	// Then
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ErrorContains(t, errors.Join(handled...), "fake: boom")
+	assert.ErrorContains(t, errors.Join(handled...), "fake died")
+	assert.Equal(t, uint64(len(handled)), sup.ErrorStats().Reported)
+}
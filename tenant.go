@@ -0,0 +1,230 @@
+package fairway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/err0r500/fairway/dcb"
+)
+
+var (
+	// ErrNoTenant is returned when no tenant is attached to the context or request
+	ErrNoTenant = errors.New("no tenant")
+	// ErrInvalidTenant is returned for tenant IDs that can't be used in a namespace
+	ErrInvalidTenant = errors.New("invalid tenant id")
+)
+
+// tenantIDPattern keeps tenant IDs from escaping their namespace
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// TenantResolver extracts the tenant ID of a request (header, subdomain, token claim...)
+type TenantResolver func(r *http.Request) (string, error)
+
+// TenantFromHeader resolves the tenant from a request header
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		if id := r.Header.Get(name); id != "" {
+			return id, nil
+		}
+		return "", fmt.Errorf("%w: missing %s header", ErrNoTenant, name)
+	}
+}
+
+// TenantMiddleware resolves the tenant of every request and attaches it to the request context.
+// Requests without a valid tenant are rejected with a 400 problem.
+func TenantMiddleware(resolve TenantResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := resolve(r)
+		if err == nil && !tenantIDPattern.MatchString(id) {
+			err = fmt.Errorf("%w: %q", ErrInvalidTenant, id)
+		}
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), id)))
+	})
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoped to the tenant (e.g. for automations or CLI tools)
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant attached to the context
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok
+}
+
+// TenantStoreFactory maps tenant IDs to stores namespaced under <namespace>/tenants/<id>.
+// Stores are created on first use and cached.
+type TenantStoreFactory struct {
+	db        fdb.Database
+	namespace string
+	opts      []dcb.StoreOption
+
+	mu     sync.Mutex
+	stores map[string]dcb.DcbStore
+}
+
+// NewTenantStoreFactory creates a factory whose stores share opts
+func NewTenantStoreFactory(db fdb.Database, namespace string, opts ...dcb.StoreOption) *TenantStoreFactory {
+	return &TenantStoreFactory{
+		db:        db,
+		namespace: namespace,
+		opts:      opts,
+		stores:    make(map[string]dcb.DcbStore),
+	}
+}
+
+// Store returns the store of the tenant
+func (f *TenantStoreFactory) Store(tenantID string) (dcb.DcbStore, error) {
+	if !tenantIDPattern.MatchString(tenantID) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTenant, tenantID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	store, ok := f.stores[tenantID]
+	if !ok {
+		store = dcb.NewDcbStore(f.db, f.namespace+"/tenants/"+tenantID, f.opts...)
+		f.stores[tenantID] = store
+	}
+	return store, nil
+}
+
+// StoreFor returns the store of the tenant attached to the context
+func (f *TenantStoreFactory) StoreFor(ctx context.Context) (dcb.DcbStore, error) {
+	id, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return f.Store(id)
+}
+
+// tenantCache lazily builds one value per tenant store
+type tenantCache[T any] struct {
+	stores *TenantStoreFactory
+	build  func(dcb.DcbStore) T
+
+	mu     sync.Mutex
+	values map[dcb.DcbStore]T
+}
+
+func newTenantCache[T any](stores *TenantStoreFactory, build func(dcb.DcbStore) T) *tenantCache[T] {
+	return &tenantCache[T]{stores: stores, build: build, values: make(map[dcb.DcbStore]T)}
+}
+
+func (c *tenantCache[T]) get(ctx context.Context) (T, error) {
+	store, err := c.stores.StoreFor(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.values[store]
+	if !ok {
+		v = c.build(store)
+		c.values[store] = v
+	}
+	return v, nil
+}
+
+// tenantCommandRunner runs each command against the store of the context's tenant
+type tenantCommandRunner struct {
+	runners *tenantCache[CommandRunner]
+}
+
+// NewTenantCommandRunner creates a CommandRunner running commands against the store of the context's tenant.
+// It can be passed to HttpChangeRegistry.RegisterRoutes behind TenantMiddleware.
+func NewTenantCommandRunner(stores *TenantStoreFactory, opts ...CommandRunnerOption) CommandRunner {
+	return tenantCommandRunner{runners: newTenantCache(stores, func(store dcb.DcbStore) CommandRunner {
+		return NewCommandRunner(store, opts...)
+	})}
+}
+
+func (r tenantCommandRunner) RunPure(ctx context.Context, cmd Command) error {
+	runner, err := r.runners.get(ctx)
+	if err != nil {
+		return err
+	}
+	return runner.RunPure(ctx, cmd)
+}
+
+// RunPureIdempotent scopes key to the tenant, so tenants sharing an IdempotencyStore can't collide
+func (r tenantCommandRunner) RunPureIdempotent(ctx context.Context, key string, cmd Command) error {
+	runner, err := r.runners.get(ctx)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		id, _ := TenantFromContext(ctx)
+		key = id + "/" + key
+	}
+	return runner.RunPureIdempotent(ctx, key, cmd)
+}
+
+// RunBatch runs the commands against the store of the context's tenant
+func (r tenantCommandRunner) RunBatch(ctx context.Context, cmds []Command, opts ...BatchOption) BatchResult {
+	runner, err := r.runners.get(ctx)
+	if err != nil {
+		result := BatchResult{Errors: make([]error, len(cmds))}
+		for i := range result.Errors {
+			result.Errors[i] = err
+		}
+		return result
+	}
+	return runner.RunBatch(ctx, cmds, opts...)
+}
+
+// tenantReader reads from the store of the context's tenant
+type tenantReader struct {
+	readers *tenantCache[Reader]
+}
+
+// NewTenantReader creates a Reader reading from the store of the context's tenant.
+// It can be passed to HttpViewRegistry.RegisterRoutes behind TenantMiddleware.
+func NewTenantReader(stores *TenantStoreFactory, opts ...ReaderOption) Reader {
+	return tenantReader{readers: newTenantCache(stores, func(store dcb.DcbStore) Reader {
+		return NewReader(store, opts...)
+	})}
+}
+
+func (r tenantReader) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error {
+	reader, err := r.readers.get(ctx)
+	if err != nil {
+		return err
+	}
+	return reader.ReadEvents(ctx, query, handler, opts...)
+}
+
+func (r tenantReader) Stream(ctx context.Context, query *Query, opts ...ReadOption) iter.Seq2[StoredEvent, error] {
+	reader, err := r.readers.get(ctx)
+	if err != nil {
+		return func(yield func(StoredEvent, error) bool) {
+			yield(StoredEvent{}, err)
+		}
+	}
+	return reader.Stream(ctx, query, opts...)
+}
+
+func (r tenantReader) ReadPage(ctx context.Context, query *Query, cursor string, size int, opts ...ReadOption) (Page, error) {
+	reader, err := r.readers.get(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+	return reader.ReadPage(ctx, query, cursor, size, opts...)
+}
@@ -0,0 +1,100 @@
+package fairway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantRunnerAndReader_IsolateTenants(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	stores := fairway.NewTenantStoreFactory(base.Database(), base.Namespace())
+	runner := fairway.NewTenantCommandRunner(stores)
+	reader := fairway.NewTenantReader(stores)
+	acme := fairway.WithTenant(context.Background(), "acme")
+	globex := fairway.WithTenant(context.Background(), "globex")
+
+	// When - a command runs for acme only
+	err := runner.RunPure(acme, commandFunc(func(ctx context.Context, ra fairway.EventReadAppender) error {
+		return ra.AppendEvents(ctx, fairway.NewEvent(PageItem{N: 1}))
+	}))
+	require.NoError(t, err)
+
+	// Then
+	count := func(ctx context.Context) int {
+		n := 0
+		require.NoError(t, reader.ReadEvents(ctx, pageItemsQuery(), func(fairway.Event) bool { n++; return true }))
+		return n
+	}
+	assert.Equal(t, 1, count(acme))
+	assert.Equal(t, 0, count(globex))
+
+	acmeStore, err := stores.Store("acme")
+	require.NoError(t, err)
+	again, err := stores.Store("acme")
+	require.NoError(t, err)
+	assert.Same(t, acmeStore, again, "stores are cached")
+}
+
+func TestTenantRunner_RequiresTenant(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	base := dcb.SetupTestStore(t)
+	stores := fairway.NewTenantStoreFactory(base.Database(), base.Namespace())
+
+	// When
+	err := fairway.NewTenantCommandRunner(stores).RunPure(context.Background(), commandFunc(func(context.Context, fairway.EventReadAppender) error {
+		return nil
+	}))
+	_, invalidErr := stores.Store("../other")
+
+	// Then
+	assert.ErrorIs(t, err, fairway.ErrNoTenant)
+	assert.ErrorIs(t, invalidErr, fairway.ErrInvalidTenant)
+}
+
+func TestTenantMiddleware_AttachesTenant(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	var seen string
+	handler := fairway.TenantMiddleware(fairway.TenantFromHeader("X-Tenant"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = fairway.TenantFromContext(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		tenant string
+		status int
+	}{
+		{"acme", http.StatusOK},
+		{"", http.StatusBadRequest},
+		{"a/b", http.StatusBadRequest},
+	} {
+		// When
+		seen = ""
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.tenant != "" {
+			req.Header.Set("X-Tenant", tc.tenant)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// Then
+		assert.Equal(t, tc.status, rec.Code, tc.tenant)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, tc.tenant, seen)
+		} else {
+			assert.Equal(t, fairway.ProblemContentType, rec.Header().Get("Content-Type"), tc.tenant)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package contract
+
+import (
+	"os"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/stretchr/testify/require"
+)
+
+// UpdateEnv is the environment variable making ProducerFixtures rewrite the fixtures:
+//
+//	FAIRWAY_UPDATE_FIXTURES=1 go test ./...
+const UpdateEnv = "FAIRWAY_UPDATE_FIXTURES"
+
+// ProducerFixtures checks that the fixtures of dir are the serialization of events by the producer's
+// current structs, or rewrites them when UpdateEnv is set. Commit the fixtures: consumers check them
+// with ConsumerCanRead, and a change of the producer's structs fails its own tests until they are updated.
+func ProducerFixtures(t *testing.T, dir string, events ...fairway.Event) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		require.NoError(t, fairway.WriteEventFixtures(dir, events...))
+		return
+	}
+	require.NoError(t, fairway.VerifyEventFixtures(dir, events...), "run the tests with %s=1 to update the fixtures", UpdateEnv)
+}
+
+// ConsumerCanRead asserts that the consumer's example types deserialize the producer's fixtures of dir
+// (see fairway.CheckEventFixtures). Producers also call it on the fixtures of their previous releases,
+// to check that their current structs still read the events already stored.
+func ConsumerCanRead(t *testing.T, dir string, examples ...any) {
+	t.Helper()
+	require.NoError(t, fairway.CheckEventFixtures(dir, examples...))
+}
@@ -0,0 +1,72 @@
+package fairway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/err0r500/fairway/dcb"
+)
+
+// ErrUnknownEventType is returned when reading an event whose type is not registered
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// UnknownEventMode selects what a consumer does with events whose type it doesn't know
+type UnknownEventMode int
+
+const (
+	// FailOnUnknownEvent stops the read with ErrUnknownEventType (default)
+	FailOnUnknownEvent UnknownEventMode = iota
+	// SkipUnknownEvent ignores the event
+	SkipUnknownEvent
+	// DeliverUnknownEvent passes the event to the handler with an UnknownEvent as Data
+	DeliverUnknownEvent
+)
+
+// UnknownEventPolicy configures how a consumer handles unknown event types,
+// so adding new producers doesn't wedge old consumers
+type UnknownEventPolicy struct {
+	Mode    UnknownEventMode
+	Observe func(eventType string) // optional, called for every unknown event (e.g. to increment a metric)
+}
+
+// UnknownEvent is the Data of events delivered with DeliverUnknownEvent
+type UnknownEvent struct {
+	Type string
+	Tags []string
+	Data json.RawMessage // the event payload, without the envelope
+}
+
+// decode deserializes de following the registry's unknown event policy.
+// Returns false if the event must be skipped.
+func (r eventRegistry) decode(de dcb.Event) (Event, bool, error) {
+	ev, err := r.deserialize(de)
+	if !errors.Is(err, ErrUnknownEventType) {
+		return ev, err == nil, err
+	}
+
+	if r.unknown.Observe != nil {
+		r.unknown.Observe(de.Type)
+	}
+
+	switch r.unknown.Mode {
+	case SkipUnknownEvent:
+		return Event{}, false, nil
+	case DeliverUnknownEvent:
+		var envelope struct {
+			OccurredAt time.Time       `json:"occurredAt"`
+			Data       json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(de.Data, &envelope); err != nil {
+			return Event{}, false, fmt.Errorf("json unmarshal envelope for event type %q: %s", de.Type, err)
+		}
+		return Event{
+			OccurredAt:     envelope.OccurredAt,
+			Data:           UnknownEvent{Type: de.Type, Tags: de.Tags, Data: envelope.Data},
+			IdempotencyKey: de.Metadata[IdempotencyKeyMetadata],
+		}, true, nil
+	default:
+		return Event{}, false, err
+	}
+}
@@ -0,0 +1,85 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CartOpened is known by the consumer under test
+type CartOpened struct {
+	CartId string `json:"cartId"`
+}
+
+func (e CartOpened) Tags() []string { return []string{"cart:" + e.CartId} }
+
+// CartShared comes from a producer the consumer doesn't know about
+type CartShared struct {
+	CartId string `json:"cartId"`
+}
+
+func (e CartShared) Tags() []string { return []string{"cart:" + e.CartId} }
+
+// readCart reads every event of the cart with a tag-only query, knowing only CartOpened
+func readCart(t *testing.T, store dcb.DcbStore, policy fairway.UnknownEventPolicy) ([]any, error) {
+	t.Helper()
+	var data []any
+	err := fairway.NewReader(store, fairway.WithUnknownEventPolicy(policy)).ReadEvents(context.Background(),
+		fairway.QueryItems(
+			fairway.NewQueryItem().Types(CartOpened{}),
+			fairway.NewQueryItem().Tags("cart:1"),
+		),
+		func(e fairway.Event) bool {
+			data = append(data, e.Data)
+			return true
+		})
+	return data, err
+}
+
+func TestUnknownEventPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	store := dcb.SetupTestStore(t)
+	events := make([]dcb.Event, 0, 2)
+	for _, data := range []any{CartOpened{CartId: "1"}, CartShared{CartId: "1"}} {
+		ev, err := fairway.ToDcbEvent(fairway.NewEvent(data))
+		require.NoError(t, err)
+		events = append(events, ev)
+	}
+	require.NoError(t, store.Append(context.Background(), events))
+
+	t.Run("fail by default", func(t *testing.T) {
+		_, err := readCart(t, store, fairway.UnknownEventPolicy{})
+
+		assert.ErrorIs(t, err, fairway.ErrUnknownEventType)
+	})
+
+	t.Run("skip and observe", func(t *testing.T) {
+		var observed []string
+		data, err := readCart(t, store, fairway.UnknownEventPolicy{
+			Mode:    fairway.SkipUnknownEvent,
+			Observe: func(eventType string) { observed = append(observed, eventType) },
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []any{CartOpened{CartId: "1"}}, data)
+		assert.Equal(t, []string{"CartShared"}, observed)
+	})
+
+	t.Run("deliver raw", func(t *testing.T) {
+		data, err := readCart(t, store, fairway.UnknownEventPolicy{Mode: fairway.DeliverUnknownEvent})
+
+		require.NoError(t, err)
+		require.Len(t, data, 2)
+		unknown, ok := data[1].(fairway.UnknownEvent)
+		require.True(t, ok)
+		assert.Equal(t, "CartShared", unknown.Type)
+		assert.Equal(t, []string{"cart:1"}, unknown.Tags)
+		assert.JSONEq(t, `{"cartId":"1"}`, string(unknown.Data))
+	})
+}
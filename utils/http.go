@@ -3,44 +3,92 @@ package utils
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
-	"github.com/go-playground/validator/v10"
+	"github.com/err0r500/fairway"
+	"github.com/google/uuid"
 )
 
 const (
-	idempotencyHeader         = "Idempotency-Key"
-	idempotencyDefaultTimeout = 10 * time.Second
-	idempotencyPollInterval   = 50 * time.Millisecond
+	idempotencyHeader           = "Idempotency-Key"
+	idempotencyDefaultMarkerTTL = 30 * time.Second
+	idempotencyPollInterval     = 50 * time.Millisecond
 
-	// Marker value stored while the request is being processed.
+	// Prefix of the marker stored while the request is being processed.
 	// Once complete, the value is replaced with the actual response.
 	idempotencyProcessingMarker = "__processing__"
 )
 
-// JsonParse decodes JSON and validates struct
-// Returns error for caller to handle (decode or validation errors)
-func JsonParse[T any](r *http.Request, v *T) error {
-	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
-		return err
+// Processing marker format:
+// [idempotencyProcessingMarker][fencing_token:16][heartbeat_ns:8]
+// Markers written before fencing are the bare prefix: they are stale at once.
+const idempotencyMarkerSize = len(idempotencyProcessingMarker) + 16 + 8
+
+// errIdempotencyClaimLost is returned when the marker of a request was reclaimed by another one
+var errIdempotencyClaimLost = errors.New("idempotency key reclaimed by another request")
+
+type idempotencyConfig struct {
+	markerTTL   time.Duration
+	waitTimeout time.Duration // 0 = as long as the request holding the key is alive
+	now         func() time.Time
+}
+
+// IdempotencyOption configures IdempotencyMiddleware
+type IdempotencyOption func(*idempotencyConfig)
+
+// WithIdempotencyMarkerTTL sets how long the marker of a request being processed survives without heartbeat
+// (default: 30s). The request holding a key refreshes its marker every third of d: a marker older than d
+// belongs to a crashed process, and the next duplicate reclaims the key and runs the handler.
+func WithIdempotencyMarkerTTL(d time.Duration) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		if d > 0 {
+			c.markerTTL = d
+		}
+	}
+}
+
+// WithIdempotencyWaitTimeout answers duplicates with a 504 Gateway Timeout problem once they waited d for
+// the request holding the key. By default, they wait as long as it is alive, or until their own context ends.
+func WithIdempotencyWaitTimeout(d time.Duration) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		if d > 0 {
+			c.waitTimeout = d
+		}
 	}
-	if err := validator.New().Struct(v); err != nil {
-		return err
+}
+
+// WithIdempotencyClock replaces the clock stamping markers and judging their age (default: time.Now).
+// Tests use it to simulate a crashed or partitioned process deterministically: a middleware whose
+// clock is frozen writes heartbeats that another one, with a later clock, sees as stale.
+func WithIdempotencyClock(now func() time.Time) IdempotencyOption {
+	return func(c *idempotencyConfig) {
+		if now != nil {
+			c.now = now
+		}
 	}
-	return nil
 }
 
 // IdempotencyMiddleware returns an http.Handler that deduplicates requests
 // sharing the same Idempotency-Key header. The first request with a given key
-// executes next; concurrent duplicates wait (up to 10s) for that result.
+// executes next; concurrent duplicates wait for that result.
 // Responses (status code + body) are stored in a dedicated FDB subspace.
-func IdempotencyMiddleware(db fdb.Database, namespace string, next http.Handler) http.Handler {
+// The key is set on the request's context (see fairway.WithIdempotencyKey): the commands next runs
+// stamp it on the events they append.
+//
+// While next runs, its marker carries a fencing token and a heartbeat: a duplicate reclaims the keys
+// of crashed processes (see WithIdempotencyMarkerTTL), and a request whose key was reclaimed doesn't
+// overwrite the response stored by the one that reclaimed it. A key whose handler panics is released.
+func IdempotencyMiddleware(db fdb.Database, namespace string, next http.Handler, opts ...IdempotencyOption) http.Handler {
 	ss := subspace.Sub(namespace).Sub("idempotency")
+	cfg := idempotencyConfig{markerTTL: idempotencyDefaultMarkerTTL, now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := r.Header.Get(idempotencyHeader)
@@ -50,62 +98,86 @@ func IdempotencyMiddleware(db fdb.Database, namespace string, next http.Handler)
 		}
 
 		fdbKey := ss.Pack(tuple.Tuple{key})
+		token := uuid.New()
+		started := time.Now()
+		for {
+			// Try to claim the key atomically, or reclaim it from a crashed process.
+			claimed, existingValue, err := tryClaim(db, fdbKey, token, cfg)
+			if err != nil {
+				fairway.WriteError(w, err)
+				return
+			}
 
-		// Try to claim the key atomically.
-		claimed, existingValue, err := tryClaim(db, fdbKey)
-		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
-			return
-		}
-
-		if claimed {
-			// We own this key — execute the real handler.
-			rec := &responseRecorder{header: make(http.Header), body: &bytes.Buffer{}, statusCode: http.StatusOK}
-			next.ServeHTTP(rec, r)
+			if claimed {
+				// We own this key — execute the real handler.
+				serveClaimed(db, fdbKey, token, cfg, w, r.WithContext(fairway.WithIdempotencyKey(r.Context(), key)), next)
+				return
+			}
 
-			encoded := encodeResponse(rec.statusCode, rec.body.Bytes())
-			if err := storeResult(db, fdbKey, encoded); err != nil {
-				http.Error(w, "internal error", http.StatusInternalServerError)
+			// Another request is processing this key. If the value is already
+			// the final response (not the processing marker), return it directly.
+			if !isProcessing(existingValue) {
+				code, body := decodeResponse(existingValue)
+				writeRecordedResponse(w, code, body)
 				return
 			}
 
-			writeRecordedResponse(w, rec.statusCode, rec.body.Bytes())
-			return
+			if cfg.waitTimeout > 0 && time.Since(started) >= cfg.waitTimeout {
+				fairway.WriteProblem(w, fairway.NewProblem(http.StatusGatewayTimeout, "timed out waiting for the request with the same Idempotency-Key"))
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				fairway.WriteError(w, r.Context().Err())
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
 		}
+	})
+}
 
-		// Another request is processing this key. If the value is already
-		// the final response (not the processing marker), return it directly.
-		if !isProcessing(existingValue) {
-			code, body := decodeResponse(existingValue)
-			writeRecordedResponse(w, code, body)
-			return
+// serveClaimed runs next for the request holding fdbKey, heartbeating its marker, then stores the response
+// unless the key was reclaimed meanwhile
+func serveClaimed(db fdb.Database, fdbKey fdb.Key, token uuid.UUID, cfg idempotencyConfig, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	stopHeartbeat := heartbeat(db, fdbKey, token, cfg)
+	defer func() {
+		stopHeartbeat()
+		if p := recover(); p != nil {
+			_ = releaseClaim(db, fdbKey, token)
+			panic(p)
 		}
+	}()
 
-		// Wait for the result using polling + timeout.
-		result, err := waitForResult(db, fdbKey, idempotencyDefaultTimeout)
-		if err != nil {
-			http.Error(w, "idempotency timeout", http.StatusGatewayTimeout)
-			return
-		}
+	rec := &responseRecorder{header: make(http.Header), body: &bytes.Buffer{}, statusCode: http.StatusOK}
+	next.ServeHTTP(rec, r)
 
-		code, body := decodeResponse(result)
-		writeRecordedResponse(w, code, body)
-	})
+	encoded := encodeResponse(rec.statusCode, rec.body.Bytes())
+	// A reclaimed key holds the response of the request that reclaimed it: this one is still answered.
+	if err := storeResult(db, fdbKey, token, encoded); err != nil && !errors.Is(err, errIdempotencyClaimLost) {
+		fairway.WriteError(w, err)
+		return
+	}
+
+	writeRecordedResponse(w, rec.statusCode, rec.body.Bytes())
 }
 
-// tryClaim attempts to atomically set the processing marker on the key.
+// tryClaim attempts to atomically set our processing marker on the key, replacing a stale one.
 // Returns (true, nil, nil) if claimed, (false, existingValue, nil) if already taken.
-func tryClaim(db fdb.Database, fdbKey fdb.Key) (bool, []byte, error) {
+func tryClaim(db fdb.Database, fdbKey fdb.Key, token uuid.UUID, cfg idempotencyConfig) (bool, []byte, error) {
 	type claimResult struct {
 		claimed  bool
 		existing []byte
 	}
 	res, err := db.Transact(func(tr fdb.Transaction) (any, error) {
+		now := cfg.now()
 		val := tr.Get(fdbKey).MustGet()
 		if val != nil {
-			return claimResult{claimed: false, existing: val}, nil
+			_, heartbeatAt, processing := decodeMarker(val)
+			if !processing || now.Sub(heartbeatAt) < cfg.markerTTL {
+				return claimResult{claimed: false, existing: val}, nil
+			}
 		}
-		tr.Set(fdbKey, []byte(idempotencyProcessingMarker))
+		tr.Set(fdbKey, encodeMarker(token, now))
 		return claimResult{claimed: true}, nil
 	})
 	if err != nil {
@@ -115,51 +187,96 @@ func tryClaim(db fdb.Database, fdbKey fdb.Key) (bool, []byte, error) {
 	return cr.claimed, cr.existing, nil
 }
 
-// storeResult overwrites the processing marker with the encoded response.
-func storeResult(db fdb.Database, fdbKey fdb.Key, encoded []byte) error {
+// heartbeat refreshes our marker every third of the marker TTL until the returned function is called,
+// or until the key is reclaimed
+func heartbeat(db fdb.Database, fdbKey fdb.Key, token uuid.UUID, cfg idempotencyConfig) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(cfg.markerTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			_, err := db.Transact(func(tr fdb.Transaction) (any, error) {
+				if err := checkClaim(tr, fdbKey, token); err != nil {
+					return nil, err
+				}
+				tr.Set(fdbKey, encodeMarker(token, cfg.now()))
+				return nil, nil
+			})
+			if errors.Is(err, errIdempotencyClaimLost) {
+				return
+			}
+			// other errors: the next beat retries, before the marker gets stale
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// checkClaim fails with errIdempotencyClaimLost unless the key holds our marker
+func checkClaim(tr fdb.Transaction, fdbKey fdb.Key, token uuid.UUID) error {
+	holder, _, processing := decodeMarker(tr.Get(fdbKey).MustGet())
+	if !processing || holder != token {
+		return errIdempotencyClaimLost
+	}
+	return nil
+}
+
+// storeResult overwrites our processing marker with the encoded response.
+func storeResult(db fdb.Database, fdbKey fdb.Key, token uuid.UUID, encoded []byte) error {
 	_, err := db.Transact(func(tr fdb.Transaction) (any, error) {
+		if err := checkClaim(tr, fdbKey, token); err != nil {
+			return nil, err
+		}
 		tr.Set(fdbKey, encoded)
 		return nil, nil
 	})
 	return err
 }
 
-// waitForResult polls FDB until the value is no longer the processing marker
-// or until the timeout expires.
-func waitForResult(db fdb.Database, fdbKey fdb.Key, timeout time.Duration) ([]byte, error) {
-	deadline := time.Now().Add(timeout)
-
-	for {
-		res, err := db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
-			futureVal := tr.Get(fdbKey)
-			val := futureVal.MustGet()
-			return val, nil
-		})
-		if err != nil {
+// releaseClaim clears our processing marker, so that a retry runs the handler again.
+func releaseClaim(db fdb.Database, fdbKey fdb.Key, token uuid.UUID) error {
+	_, err := db.Transact(func(tr fdb.Transaction) (any, error) {
+		if err := checkClaim(tr, fdbKey, token); err != nil {
 			return nil, err
 		}
+		tr.Clear(fdbKey)
+		return nil, nil
+	})
+	return err
+}
 
-		val := res.([]byte)
-		if val != nil && !isProcessing(val) {
-			return val, nil
-		}
-
-		if time.Now().After(deadline) {
-			return nil, http.ErrHandlerTimeout
-		}
+func encodeMarker(token uuid.UUID, heartbeatAt time.Time) []byte {
+	buf := make([]byte, 0, idempotencyMarkerSize)
+	buf = append(buf, idempotencyProcessingMarker...)
+	buf = append(buf, token[:]...)
+	return binary.BigEndian.AppendUint64(buf, uint64(heartbeatAt.UnixNano()))
+}
 
-		// Brief sleep before next poll.
-		remaining := time.Until(deadline)
-		sleep := idempotencyPollInterval
-		if sleep > remaining {
-			sleep = remaining
-		}
-		time.Sleep(sleep)
+// decodeMarker returns the fencing token and last heartbeat of a processing marker,
+// processing is false for stored responses
+func decodeMarker(val []byte) (token uuid.UUID, heartbeatAt time.Time, processing bool) {
+	if !isProcessing(val) {
+		return uuid.UUID{}, time.Time{}, false
+	}
+	if len(val) != idempotencyMarkerSize {
+		return uuid.UUID{}, time.Time{}, true // legacy marker, without heartbeat
 	}
+	rest := val[len(idempotencyProcessingMarker):]
+	copy(token[:], rest[:16])
+	return token, time.Unix(0, int64(binary.BigEndian.Uint64(rest[16:]))), true
 }
 
 func isProcessing(val []byte) bool {
-	return bytes.Equal(val, []byte(idempotencyProcessingMarker))
+	return bytes.HasPrefix(val, []byte(idempotencyProcessingMarker))
 }
 
 // encodeResponse packs a status code (4 bytes big-endian) followed by the body.
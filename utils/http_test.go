@@ -6,11 +6,14 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/err0r500/fairway"
 	"github.com/err0r500/fairway/testing/given"
 	"github.com/err0r500/fairway/utils"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIdempotencyMiddleware_ConcurrentSameKey(t *testing.T) {
@@ -75,3 +78,160 @@ func TestIdempotencyMiddleware_ConcurrentSameKey(t *testing.T) {
 		assert.Equal(t, `{"ok":true}`, bodies[i], "request %d body", i)
 	}
 }
+
+func TestIdempotencyMiddleware_SetsTheKeyOnTheContext(t *testing.T) {
+	// given
+	store := given.SetupTestStore(t)
+	var contextKey string
+	handler := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextKey, _ = fairway.IdempotencyKeyFromContext(r.Context())
+			w.WriteHeader(http.StatusCreated)
+		}))
+	idempotencyKey := uuid.New().String()
+	req := httptest.NewRequest(http.MethodPost, "/test", nil)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	// when
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// then
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, idempotencyKey, contextKey)
+}
+
+// idempotentRequest sends a POST with the idempotency key to handler
+func idempotentRequest(handler http.Handler, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/test", nil)
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddleware_ReclaimsTheKeyOfACrashedProcess(t *testing.T) {
+	// given - a process whose clock stopped while handling a request: its heartbeats look a minute old to another
+	store := given.SetupTestStore(t)
+	frozen := time.Now()
+	started, release := make(chan struct{}), make(chan struct{})
+	var handlerCalls atomic.Int32
+	stuck := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerCalls.Add(1)
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("stuck"))
+		}),
+		utils.WithIdempotencyMarkerTTL(30*time.Second),
+		utils.WithIdempotencyClock(func() time.Time { return frozen }),
+	)
+	healthy := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerCalls.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("healthy"))
+		}),
+		utils.WithIdempotencyMarkerTTL(30*time.Second),
+		utils.WithIdempotencyClock(func() time.Time { return frozen.Add(time.Minute) }),
+	)
+	key := uuid.New().String()
+	stuckDone := make(chan *httptest.ResponseRecorder)
+	go func() { stuckDone <- idempotentRequest(stuck, key) }()
+	<-started
+
+	// when - a duplicate reaches the healthy process
+	reclaimed := idempotentRequest(healthy, key)
+
+	// then - it reclaims the stale key and runs the handler
+	assert.Equal(t, http.StatusCreated, reclaimed.Code)
+	assert.Equal(t, "healthy", reclaimed.Body.String())
+
+	// when - the stuck request completes
+	close(release)
+	stuckResp := <-stuckDone
+
+	// then - it is answered, but the stored response stays that of the request holding the key
+	assert.Equal(t, "stuck", stuckResp.Body.String())
+	replayed := idempotentRequest(healthy, key)
+	assert.Equal(t, "healthy", replayed.Body.String())
+	assert.Equal(t, int32(2), handlerCalls.Load())
+}
+
+func TestIdempotencyMiddleware_WaitsForHandlersSlowerThanTheMarkerTTL(t *testing.T) {
+	// given - a handler running longer than the marker TTL
+	store := given.SetupTestStore(t)
+	started := make(chan struct{})
+	var handlerCalls atomic.Int32
+	handler := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handlerCalls.Add(1) == 1 {
+				close(started)
+			}
+			time.Sleep(time.Second)
+			w.WriteHeader(http.StatusCreated)
+		}),
+		utils.WithIdempotencyMarkerTTL(300*time.Millisecond),
+	)
+	key := uuid.New().String()
+	firstDone := make(chan *httptest.ResponseRecorder)
+	go func() { firstDone <- idempotentRequest(handler, key) }()
+	<-started
+
+	// when
+	duplicate := idempotentRequest(handler, key)
+
+	// then - the heartbeat kept the key: the duplicate got the response of the first request
+	assert.Equal(t, http.StatusCreated, (<-firstDone).Code)
+	assert.Equal(t, http.StatusCreated, duplicate.Code)
+	assert.Equal(t, int32(1), handlerCalls.Load())
+}
+
+func TestIdempotencyMiddleware_WaitTimeout(t *testing.T) {
+	// given
+	store := given.SetupTestStore(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}),
+		utils.WithIdempotencyWaitTimeout(100*time.Millisecond),
+	)
+	key := uuid.New().String()
+	firstDone := make(chan *httptest.ResponseRecorder)
+	go func() { firstDone <- idempotentRequest(handler, key) }()
+	<-started
+
+	// when
+	duplicate := idempotentRequest(handler, key)
+	close(release)
+
+	// then
+	assert.Equal(t, http.StatusGatewayTimeout, duplicate.Code)
+	assert.Equal(t, http.StatusCreated, (<-firstDone).Code)
+}
+
+func TestIdempotencyMiddleware_ReleasesTheKeyWhenTheHandlerPanics(t *testing.T) {
+	// given - a handler panicking once
+	store := given.SetupTestStore(t)
+	var handlerCalls atomic.Int32
+	handler := utils.IdempotencyMiddleware(store.Database(), store.Namespace(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if handlerCalls.Add(1) == 1 {
+				panic("boom")
+			}
+			w.WriteHeader(http.StatusCreated)
+		}))
+	key := uuid.New().String()
+	require.Panics(t, func() { idempotentRequest(handler, key) })
+
+	// when - the request is retried
+	retried := idempotentRequest(handler, key)
+
+	// then - it runs the handler again
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	assert.Equal(t, int32(2), handlerCalls.Load())
+}
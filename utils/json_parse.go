@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/err0r500/fairway"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+)
+
+// JsonParser decodes request bodies and validates them with a validator shared by every request
+type JsonParser struct {
+	validate     *validator.Validate
+	translations *ut.UniversalTranslator
+}
+
+// JsonParserOption configures a JsonParser
+type JsonParserOption func(*JsonParser)
+
+// WithValidator validates with v (custom rules, tag name function...) instead of the default validator.
+// Register the translations of its rules for every locale of the parser's translator.
+func WithValidator(v *validator.Validate) JsonParserOption {
+	return func(p *JsonParser) {
+		if v != nil {
+			p.validate = v
+		}
+	}
+}
+
+// WithTranslations translates field error messages to the locale best matching the request's
+// Accept-Language header, falling back to the translator's fallback locale.
+// By default, messages are in English.
+func WithTranslations(translations *ut.UniversalTranslator) JsonParserOption {
+	return func(p *JsonParser) {
+		if translations != nil {
+			p.translations = translations
+		}
+	}
+}
+
+// NewJsonParser creates a parser. The default validator names fields after their JSON name
+// (or parameter name, see ParseParams) and has English messages for the built-in rules.
+func NewJsonParser(opts ...JsonParserOption) *JsonParser {
+	p := &JsonParser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.translations == nil {
+		locale := en.New()
+		p.translations = ut.New(locale, locale)
+	}
+	if p.validate == nil {
+		p.validate = validator.New()
+		p.validate.RegisterTagNameFunc(fieldName)
+		if trans, found := p.translations.GetTranslator("en"); found {
+			_ = entranslations.RegisterDefaultTranslations(p.validate, trans)
+		}
+	}
+	return p
+}
+
+// defaultJsonParser is the parser of JsonParse
+var defaultJsonParser = NewJsonParser()
+
+// JsonParse decodes JSON and validates struct
+// Returns error for caller to handle (decode or validation errors),
+// fairway.WriteError responds to it with a 400 problem
+func JsonParse[T any](r *http.Request, v *T) error {
+	return defaultJsonParser.Parse(r, v)
+}
+
+// Parse decodes the request body into v and validates it.
+// Validation failures are returned as a *ValidationError.
+func (p *JsonParser) Parse(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return invalidBodyError{err: err}
+	}
+	if err := p.validate.Struct(v); err != nil {
+		fieldErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return invalidBodyError{err: err}
+		}
+		return p.validationError(r, fieldErrs, "Invalid request body", fieldPath)
+	}
+	return nil
+}
+
+// FieldError is a field failing a validation rule
+type FieldError struct {
+	Field   string `json:"field"`           // path of the field, e.g. "address.city"
+	Rule    string `json:"rule"`            // validation tag, e.g. "required"
+	Param   string `json:"param,omitempty"` // parameter of the rule, e.g. "3" for "min=3"
+	Message string `json:"message"`
+}
+
+// ValidationError is a request body or request parameters failing validation.
+// fairway.WriteError responds to it with a 400 problem listing the fields under "errors".
+type ValidationError struct {
+	Fields []FieldError
+	err    validator.ValidationErrors
+	title  string // title of the problem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the validator.ValidationErrors
+func (e *ValidationError) Unwrap() error { return e.err }
+
+func (e *ValidationError) Problem() fairway.Problem {
+	return fairway.Problem{
+		Status:     http.StatusBadRequest,
+		Title:      e.title,
+		Detail:     e.Error(),
+		Extensions: map[string]any{"errors": e.Fields},
+	}
+}
+
+func (p *JsonParser) validationError(r *http.Request, errs validator.ValidationErrors, title string, field func(validator.FieldError) string) *ValidationError {
+	trans, _ := p.translations.FindTranslator(acceptedLocales(r)...)
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		fields[i] = FieldError{
+			Field:   field(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(trans),
+		}
+	}
+	return &ValidationError{Fields: fields, err: errs, title: title}
+}
+
+// fieldPath is the namespace of the field without the top-level struct
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+// fieldName names struct fields after their JSON name, or their query or path parameter name
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		if param, _ := paramName(f); param != "" {
+			return param
+		}
+		return f.Name
+	}
+	return name
+}
+
+// acceptedLocales lists the locales of the Accept-Language header, in the client's order
+// ("fr-CA" is tried as "fr_CA" then "fr")
+func acceptedLocales(r *http.Request) []string {
+	var locales []string
+	for _, lang := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(lang), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		locales = append(locales, strings.ReplaceAll(tag, "-", "_"))
+		if base, _, found := strings.Cut(tag, "-"); found {
+			locales = append(locales, base)
+		}
+	}
+	return locales
+}
+
+// invalidBodyError is a request body that can't be decoded
+type invalidBodyError struct {
+	err error
+}
+
+func (e invalidBodyError) Error() string { return e.err.Error() }
+func (e invalidBodyError) Unwrap() error { return e.err }
+
+func (e invalidBodyError) Problem() fairway.Problem {
+	return fairway.Problem{Status: http.StatusBadRequest, Title: "Invalid request body", Detail: e.err.Error()}
+}
@@ -0,0 +1,126 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/utils"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	frtranslations "github.com/go-playground/validator/v10/translations/fr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signupBody struct {
+	Name    string  `json:"name" validate:"required,min=3"`
+	Address address `json:"address"`
+}
+
+func jsonRequest(body string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+}
+
+func TestJsonParse_InvalidBodyIsABadRequestProblem(t *testing.T) {
+	for _, payload := range []string{`{`, `{"name":""}`} {
+		// when
+		var v signupBody
+		err := utils.JsonParse(jsonRequest(payload), &v)
+
+		// then
+		assert.Equal(t, http.StatusBadRequest, fairway.ProblemFor(err).Status, payload)
+	}
+}
+
+func TestJsonParse_ReportsEveryInvalidField(t *testing.T) {
+	// when
+	var v signupBody
+	err := utils.JsonParse(jsonRequest(`{"name":"ab"}`), &v)
+
+	// then
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []utils.FieldError{
+		{Field: "name", Rule: "min", Param: "3", Message: "name must be at least 3 characters in length"},
+		{Field: "address.city", Rule: "required", Message: "city is a required field"},
+	}, validationErr.Fields)
+
+	// then - the problem lists them
+	rec := httptest.NewRecorder()
+	fairway.WriteError(rec, err)
+	var problem struct {
+		Status int                `json:"status"`
+		Errors []utils.FieldError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, validationErr.Fields, problem.Errors)
+}
+
+func TestJsonParser_CustomRule(t *testing.T) {
+	// given
+	validate := validator.New()
+	require.NoError(t, validate.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return !strings.ContainsAny(fl.Field().String(), " /")
+	}))
+	parser := utils.NewJsonParser(utils.WithValidator(validate))
+	var v struct {
+		Slug string `json:"slug" validate:"slug"`
+	}
+
+	// when
+	err := parser.Parse(jsonRequest(`{"slug":"a b"}`), &v)
+
+	// then
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "slug", validationErr.Fields[0].Rule)
+}
+
+func TestJsonParser_TranslatesToTheAcceptedLanguage(t *testing.T) {
+	// given
+	translations := ut.New(en.New(), en.New(), fr.New())
+	frTrans, _ := translations.GetTranslator("fr")
+	validate := validator.New()
+	require.NoError(t, frtranslations.RegisterDefaultTranslations(validate, frTrans))
+	parser := utils.NewJsonParser(utils.WithValidator(validate), utils.WithTranslations(translations))
+	req := jsonRequest(`{"address":{"city":"Paris"}}`)
+	req.Header.Set("Accept-Language", "fr-CA, en;q=0.8")
+
+	// when
+	var v signupBody
+	err := parser.Parse(req, &v)
+
+	// then
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "Name est un champ obligatoire", validationErr.Fields[0].Message)
+}
+
+func BenchmarkJsonParse(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		var v signupBody
+		_ = utils.JsonParse(jsonRequest(`{"name":"alice","address":{"city":"Paris"}}`), &v)
+	}
+}
+
+// BenchmarkJsonParse_NewValidatorPerRequest is the former behaviour of JsonParse, for comparison
+func BenchmarkJsonParse_NewValidatorPerRequest(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		var v signupBody
+		_ = json.NewDecoder(jsonRequest(`{"name":"alice","address":{"city":"Paris"}}`).Body).Decode(&v)
+		_ = validator.New().Struct(&v)
+	}
+}
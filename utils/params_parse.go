@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	durationType        = reflect.TypeFor[time.Duration]()
+)
+
+// ParamsParse binds the query and path parameters of the request to the fields of v
+// tagged `query:"name"` or `path:"name"`, then validates it like JsonParse
+func ParamsParse[T any](r *http.Request, v *T) error {
+	return defaultJsonParser.ParseParams(r, v)
+}
+
+// ParseParams binds the request parameters to the tagged fields of v, a pointer to struct,
+// and validates it. Fields of embedded structs are bound too, so filters and pagination can be shared.
+//
+// Strings, booleans, numbers, time.Duration and encoding.TextUnmarshaler (e.g. time.Time in RFC 3339) are supported,
+// as pointers (nil when absent) and slices (repeated query parameters). A `default:"value"` tag applies when absent.
+// Unparsable values are returned as an error fairway.WriteError responds to with a 400 problem,
+// validation failures as a *ValidationError.
+func (p *JsonParser) ParseParams(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("parsing params: expected a pointer to struct, got %T", v)
+	}
+	if err := bindParams(r, rv.Elem()); err != nil {
+		return err
+	}
+	if err := p.validate.Struct(v); err != nil {
+		fieldErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		return p.validationError(r, fieldErrs, "Invalid request parameters", paramField)
+	}
+	return nil
+}
+
+// paramName returns the name and source ("query" or "path") of the parameter bound to f
+func paramName(f reflect.StructField) (string, string) {
+	if name := f.Tag.Get("query"); name != "" {
+		return name, "query"
+	}
+	if name := f.Tag.Get("path"); name != "" {
+		return name, "path"
+	}
+	return "", ""
+}
+
+// paramField names the field of a parameter after the parameter alone: parameters are flat,
+// whether declared in v or in an embedded struct
+func paramField(fe validator.FieldError) string {
+	return fe.Field()
+}
+
+// bindParams sets the tagged fields of v, recursing into embedded structs
+func bindParams(r *http.Request, v reflect.Value) error {
+	query := r.URL.Query()
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := bindParams(r, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name, source := paramName(f)
+		if name == "" || !f.IsExported() {
+			continue
+		}
+
+		var values []string
+		switch source {
+		case "query":
+			values = query[name]
+		case "path":
+			if value := r.PathValue(name); value != "" {
+				values = []string{value}
+			}
+		}
+		if len(values) == 0 {
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			values = []string{def}
+		}
+
+		if err := setParam(v.Field(i), values); err != nil {
+			var invalid invalidValueError
+			if !errors.As(err, &invalid) {
+				return fmt.Errorf("binding %s parameter %q to field %s: %w", source, name, f.Name, err)
+			}
+			return invalidParamError{source: source, name: name, invalid: invalid}
+		}
+	}
+	return nil
+}
+
+// setParam sets v from the parameter values, the last one for non-slice fields
+func setParam(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[len(values)-1])
+}
+
+// setValue parses value into v
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return invalidValueError{value: value, kind: v.Type().String()}
+		}
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return invalidValueError{value: value, kind: "duration"}
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalidValueError{value: value, kind: "boolean"}
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return invalidValueError{value: value, kind: "integer"}
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return invalidValueError{value: value, kind: "positive integer"}
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return invalidValueError{value: value, kind: "number"}
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// invalidValueError is a parameter value that can't be parsed into its field
+type invalidValueError struct {
+	value string
+	kind  string
+}
+
+func (e invalidValueError) Error() string {
+	return fmt.Sprintf("%q is not a valid %s", e.value, e.kind)
+}
+
+// invalidParamError is a request parameter that can't be parsed
+type invalidParamError struct {
+	source  string // "query" or "path"
+	name    string
+	invalid invalidValueError
+}
+
+func (e invalidParamError) Error() string {
+	return fmt.Sprintf("%s parameter %q: %s", e.source, e.name, e.invalid)
+}
+
+func (e invalidParamError) Unwrap() error { return e.invalid }
+
+func (e invalidParamError) Problem() fairway.Problem {
+	return fairway.Problem{Status: http.StatusBadRequest, Title: "Invalid request parameters", Detail: e.Error()}
+}
@@ -0,0 +1,94 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pagination struct {
+	Limit int    `query:"limit" default:"20" validate:"min=1,max=100"`
+	After string `query:"after"`
+}
+
+type listItemsParams struct {
+	pagination
+	ListID   string        `path:"listId" validate:"required"`
+	Status   []string      `query:"status" validate:"dive,oneof=open done"`
+	Since    *time.Time    `query:"since"`
+	MaxAge   time.Duration `query:"maxAge"`
+	Archived bool          `query:"archived"`
+}
+
+// paramsRequest serves the request through a mux so its path values are set
+func paramsRequest(t *testing.T, target string, v *listItemsParams) error {
+	t.Helper()
+	var err error
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lists/{listId}/items", func(w http.ResponseWriter, r *http.Request) {
+		err = utils.ParamsParse(r, v)
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	return err
+}
+
+func TestParamsParse_BindsQueryAndPathParameters(t *testing.T) {
+	// when
+	var v listItemsParams
+	err := paramsRequest(t, "/lists/l1/items?limit=5&status=open&status=done&since=2026-01-02T03:04:05Z&maxAge=1h&archived=true", &v)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "l1", v.ListID)
+	assert.Equal(t, 5, v.Limit)
+	assert.Equal(t, []string{"open", "done"}, v.Status)
+	require.NotNil(t, v.Since)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), *v.Since)
+	assert.Equal(t, time.Hour, v.MaxAge)
+	assert.True(t, v.Archived)
+}
+
+func TestParamsParse_AppliesDefaults(t *testing.T) {
+	// when
+	var v listItemsParams
+	err := paramsRequest(t, "/lists/l1/items", &v)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 20, v.Limit)
+	assert.Nil(t, v.Since)
+	assert.Empty(t, v.Status)
+}
+
+func TestParamsParse_UnparsableValueIsABadRequestProblem(t *testing.T) {
+	// when
+	var v listItemsParams
+	err := paramsRequest(t, "/lists/l1/items?limit=ten", &v)
+
+	// then
+	problem := fairway.ProblemFor(err)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "Invalid request parameters", problem.Title)
+	assert.Equal(t, `query parameter "limit": "ten" is not a valid integer`, problem.Detail)
+}
+
+func TestParamsParse_ReportsEveryInvalidParameter(t *testing.T) {
+	// when
+	var v listItemsParams
+	err := paramsRequest(t, "/lists/l1/items?limit=500&status=closed", &v)
+
+	// then
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Fields, 2)
+	assert.Equal(t, "limit", validationErr.Fields[0].Field)
+	assert.Equal(t, "max", validationErr.Fields[0].Rule)
+	assert.Equal(t, "status[0]", validationErr.Fields[1].Field)
+	assert.Equal(t, "Invalid request parameters", fairway.ProblemFor(err).Title)
+}
@@ -12,51 +12,53 @@ import (
 )
 
 type EventsReader interface {
-	ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc) error
+	// ReadEvents dispatches the events matching the query to handler, opts bound this call only
+	ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error
 }
 
 // commandReadAppender provides read-then-conditional-append for commands
 type viewReader struct {
 	store         dcb.DcbStore
 	eventRegistry eventRegistry
+	cache         *readCache // nil = no caching
 }
 
-// NewReader creates a Events with given store
-func NewReader(store dcb.DcbStore) EventsReader {
-	return viewReader{
+// ReaderOption configures a reader created with NewReader
+type ReaderOption func(*viewReader)
+
+// WithUnknownEventPolicy sets how the reader handles events of types absent from the query
+// (e.g. tag-only queries matching events of a new producer)
+func WithUnknownEventPolicy(p UnknownEventPolicy) ReaderOption {
+	return func(r *viewReader) {
+		r.eventRegistry.unknown = p
+	}
+}
+
+// NewReader creates a Reader with given store
+func NewReader(store dcb.DcbStore, opts ...ReaderOption) Reader {
+	r := viewReader{
 		store:         store,
 		eventRegistry: newEventRegistry(),
 	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
 }
 
 // ReadEvents reads events using the eventHandler's query and dispatches to handlers
-func (ra viewReader) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc) error {
+func (ra viewReader) ReadEvents(ctx context.Context, query *Query, handler EventHandlerFunc, opts ...ReadOption) error {
 	if handler == nil {
 		return nil
 	}
 
-	// Auto-register types from query
-	for _, item := range query.items {
-		ra.eventRegistry.registerTypes(item.typeRegistry)
-	}
-
-	for dcbStoredEvent, err := range ra.store.Read(ctx, *query.toDcb(), nil) {
-		if err != nil {
-			// context errors already have context
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			return fmt.Errorf("reading events: %s", err)
-		}
-
-		// Deserialize dcb.Event → Event
-		ev, err := ra.eventRegistry.deserialize(dcbStoredEvent.Event)
+	for ev, err := range ra.Stream(ctx, query, opts...) {
 		if err != nil {
-			return fmt.Errorf("deserializing event at position %x: %s", dcbStoredEvent.Position[:], err)
+			return err
 		}
 
 		// Dispatch Event to handler
-		if !handler(ev) {
+		if !handler(ev.Event) {
 			return nil
 		}
 	}
@@ -66,7 +68,8 @@ func (ra viewReader) ReadEvents(ctx context.Context, query *Query, handler Event
 
 // eventRegistry maps event type names to their Go types for deserialization
 type eventRegistry struct {
-	types map[string]reflect.Type
+	types   map[string]reflect.Type
+	unknown UnknownEventPolicy
 }
 
 // newEventRegistry creates a new event registry
@@ -77,6 +80,9 @@ func newEventRegistry() eventRegistry {
 // registerTypes registers event types from a type registry map
 func (r *eventRegistry) registerTypes(types map[string]reflect.Type) {
 	maps.Copy(r.types, types)
+	for name, typ := range types {
+		registerPIIType(name, typ)
+	}
 }
 
 // registeredTypeNames returns list of registered type names for error context
@@ -92,8 +98,12 @@ func (r eventRegistry) registeredTypeNames() []string {
 func (r eventRegistry) deserialize(de dcb.Event) (Event, error) {
 	typ, ok := r.types[de.Type]
 	if !ok {
-		return Event{}, fmt.Errorf("unknown event type %q (registered: %v)", de.Type, r.registeredTypeNames())
+		typ, ok = aliasedType(de.Type)
 	}
+	if !ok {
+		return Event{}, fmt.Errorf("%w %q (registered: %v)", ErrUnknownEventType, de.Type, r.registeredTypeNames())
+	}
+	notifyIfDeprecated(de.Type)
 
 	// Unmarshal envelope to get timestamp and raw data
 	var envelope struct {
@@ -104,6 +114,14 @@ func (r eventRegistry) deserialize(de dcb.Event) (Event, error) {
 		return Event{}, fmt.Errorf("json unmarshal envelope for event type %q: %s", de.Type, err)
 	}
 
+	if typ == rawEventType {
+		return Event{
+			OccurredAt:     envelope.OccurredAt,
+			Data:           RawEvent{Type: de.Type, Tags: de.Tags, Data: envelope.Data},
+			IdempotencyKey: de.Metadata[IdempotencyKeyMetadata],
+		}, nil
+	}
+
 	// Create new instance of user's data type
 	ptr := reflect.New(typ)
 
@@ -113,7 +131,22 @@ func (r eventRegistry) deserialize(de dcb.Event) (Event, error) {
 	}
 
 	return Event{
-		OccurredAt: envelope.OccurredAt,
-		Data:       ptr.Elem().Interface(),
+		OccurredAt:     envelope.OccurredAt,
+		Data:           ptr.Elem().Interface(),
+		IdempotencyKey: de.Metadata[IdempotencyKeyMetadata],
 	}, nil
 }
+
+// FoldView computes a view on demand by folding the events matching the query into initial.
+// Suited to low-traffic queries where maintaining a persistent read model is overkill.
+func FoldView[T any](ctx context.Context, reader EventsReader, query *Query, initial T, apply func(T, Event) T) (T, error) {
+	state := initial
+	err := reader.ReadEvents(ctx, query, func(e Event) bool {
+		state = apply(state, e)
+		return true
+	})
+	if err != nil {
+		return initial, err
+	}
+	return state, nil
+}
@@ -0,0 +1,48 @@
+package fairway_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestFoldView_FoldsMatchingEvents(tt *testing.T) {
+	tt.Parallel()
+	rapid.Check(tt, func(t *rapid.T) {
+		// Given
+		store := dcb.SetupTestStore(tt)
+		count := rapid.IntRange(0, 10).Draw(t, "count")
+		appendPageItems(t, store, count)
+
+		// When
+		sum, err := fairway.FoldView(context.Background(), fairway.NewReader(store), pageItemsQuery(), 0,
+			func(acc int, e fairway.Event) int {
+				return acc + e.Data.(PageItem).N
+			})
+
+		// Then
+		require.NoError(t, err)
+		assert.Equal(t, count*(count-1)/2, sum)
+	})
+}
+
+func TestFoldView_ReturnsInitialOnError(t *testing.T) {
+	t.Parallel()
+
+	// Given - a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When
+	state, err := fairway.FoldView(ctx, fairway.NewReader(dcb.SetupTestStore(t)), pageItemsQuery(), "initial",
+		func(string, fairway.Event) string { return "folded" })
+
+	// Then
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "initial", state)
+}
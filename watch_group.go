@@ -0,0 +1,155 @@
+package fairway
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+)
+
+// defaultWatchGroupPollInterval is how often a WatchGroup reads the head of each type index by default
+const defaultWatchGroupPollInterval = 100 * time.Millisecond
+
+// WatchGroup tails the type indexes watched by the automations of a process, so that they don't all poll them.
+// Each type index has a single loop reading its last entry every poll interval; when it moved,
+// the automations watching that type are woken to enqueue the new events.
+// Caught up automations then cost one read per type and interval, instead of one per automation.
+//
+// Automations join a group with WithWatchGroup. Query automations keep polling on their own.
+type WatchGroup struct {
+	pollInterval time.Duration
+	clock        Clock
+
+	mu      sync.Mutex
+	tailers map[string]*typeTailer // by type index prefix
+}
+
+// typeTailer polls the head of a type index for its subscribers
+type typeTailer struct {
+	db          fdb.Database
+	typeIndex   subspace.Subspace
+	subscribers map[chan struct{}]struct{}
+	cancel      context.CancelFunc
+}
+
+// WatchGroupOption configures a WatchGroup
+type WatchGroupOption func(*WatchGroup)
+
+// WithWatchGroupPollInterval sets how often the head of each type index is read (default: 100ms)
+func WithWatchGroupPollInterval(d time.Duration) WatchGroupOption {
+	return func(g *WatchGroup) {
+		if d > 0 {
+			g.pollInterval = d
+		}
+	}
+}
+
+// WithWatchGroupClock replaces the clock of the group's poll loops (see VirtualClock)
+func WithWatchGroupClock(c Clock) WatchGroupOption {
+	return func(g *WatchGroup) {
+		if c != nil {
+			g.clock = c
+		}
+	}
+}
+
+// NewWatchGroup creates a group for the automations of one database.
+// Its loops run while automations subscribed to them are running.
+func NewWatchGroup(opts ...WatchGroupOption) *WatchGroup {
+	g := &WatchGroup{
+		pollInterval: defaultWatchGroupPollInterval,
+		clock:        systemClock{},
+		tailers:      make(map[string]*typeTailer),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// WithWatchGroup makes the automation's watcher wait for g to report new events of its type, rather than
+// polling the type index every PollInterval. It still polls on its own while catching up.
+// Ignored by query automations.
+func WithWatchGroup[Deps any](g *WatchGroup) AutomationOption[Deps] {
+	return func(a *Automation[Deps]) {
+		a.watchGroup = g
+	}
+}
+
+// subscribe returns a channel receiving a value when the type index got new entries, and the function
+// ending the subscription. The first subscriber of a type index starts its loop, the last one stops it.
+func (g *WatchGroup) subscribe(db fdb.Database, typeIndex subspace.Subspace) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+	id := string(typeIndex.Bytes())
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.tailers[id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		t = &typeTailer{db: db, typeIndex: typeIndex, subscribers: make(map[chan struct{}]struct{}), cancel: cancel}
+		g.tailers[id] = t
+		goLabeled(pprof.WithLabels(ctx, pprof.Labels(ProfileLabelComponent, "watch_group")), "tailer", func() { g.tail(ctx, t) })
+	}
+	t.subscribers[wake] = struct{}{}
+
+	var once sync.Once
+	return wake, func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			delete(t.subscribers, wake)
+			if len(t.subscribers) == 0 {
+				t.cancel()
+				delete(g.tailers, id)
+			}
+		})
+	}
+}
+
+// tail reads the last entry of the type index every poll interval, and wakes the subscribers when it changed.
+// A failed read wakes them too: their own poll reports the error.
+func (g *WatchGroup) tail(ctx context.Context, t *typeTailer) {
+	ticker := g.clock.NewTicker(g.pollInterval)
+	defer ticker.Stop()
+
+	var head fdb.Key
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+		}
+
+		last, err := t.db.ReadTransact(func(tr fdb.ReadTransaction) (any, error) {
+			kvs, err := tr.GetRange(t.typeIndex, fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceWithError()
+			if err != nil || len(kvs) == 0 {
+				return fdb.Key(nil), err
+			}
+			return kvs[0].Key, nil
+		})
+		if err == nil && bytes.Equal(last.(fdb.Key), head) {
+			continue
+		}
+		if err == nil {
+			head = last.(fdb.Key)
+		}
+		g.wake(t)
+	}
+}
+
+// wake notifies the subscribers of t, without blocking: a pending notification covers the new one
+func (g *WatchGroup) wake(t *typeTailer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for wake := range t.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package fairway_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/err0r500/fairway"
+	"github.com/err0r500/fairway/dcb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchGroup_WakesCaughtUpAutomations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given - two automations watching the same type through a group
+	store := dcb.SetupTestStore(t)
+	group := fairway.NewWatchGroup(fairway.WithWatchGroupPollInterval(10 * time.Millisecond))
+	appendEvent := func(userID string) {
+		dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: userID}))
+		require.NoError(t, err)
+		require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+	}
+	appendEvent("user-1")
+
+	handled := make([]*atomic.Int32, 2)
+	metrics := make([]*recordingAutomationMetrics, 2)
+	for i, queueId := range []string{"queue-a", "queue-b"} {
+		var lastEvent fairway.Event
+		handled[i] = &atomic.Int32{}
+		metrics[i] = &recordingAutomationMetrics{enqueued: map[string]int{}}
+		deps := TestDeps{HandlerCalled: handled[i], LastEvent: &lastEvent, LastEventMu: &sync.Mutex{}}
+		automation, err := fairway.NewAutomation(store, deps, queueId, TestAutomationEvent{},
+			func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+				return &TestCommand{Event: ev}
+			},
+			fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+			fairway.WithAutomationMetrics[TestDeps](metrics[i]),
+			fairway.WithWatchGroup[TestDeps](group),
+		)
+		require.NoError(t, err)
+		require.NoError(t, automation.Start(ctx))
+		t.Cleanup(func() {
+			automation.Stop()
+			_ = automation.Wait()
+		})
+	}
+
+	// When - they catch up with the existing event
+	require.Eventually(t, func() bool {
+		return handled[0].Load() == 1 && handled[1].Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	pollsA, _, _ := metrics[0].snapshot()
+	pollsB, _, _ := metrics[1].snapshot()
+
+	// Then - caught up, they don't poll on their own
+	time.Sleep(200 * time.Millisecond)
+	idleA, _, _ := metrics[0].snapshot()
+	idleB, _, _ := metrics[1].snapshot()
+	assert.Equal(t, pollsA, idleA)
+	assert.Equal(t, pollsB, idleB)
+
+	// When - a new event is appended
+	appendEvent("user-2")
+
+	// Then - the group wakes both
+	assert.Eventually(t, func() bool {
+		return handled[0].Load() == 2 && handled[1].Load() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	_, _, enqueuedA := metrics[0].snapshot()
+	_, _, enqueuedB := metrics[1].snapshot()
+	assert.Equal(t, map[string]int{"queue-a": 2}, enqueuedA)
+	assert.Equal(t, map[string]int{"queue-b": 2}, enqueuedB)
+}
+
+func TestWatchGroup_TailsOnTheClock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Given - a caught-up automation in a group on a virtual clock
+	store := dcb.SetupTestStore(t)
+	clock := fairway.NewVirtualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	group := fairway.NewWatchGroup(fairway.WithWatchGroupPollInterval(time.Minute), fairway.WithWatchGroupClock(clock))
+	handled := &atomic.Int32{}
+	var lastEvent fairway.Event
+	deps := TestDeps{HandlerCalled: handled, LastEvent: &lastEvent, LastEventMu: &sync.Mutex{}}
+	automation, err := fairway.NewAutomation(store, deps, "queue-a", TestAutomationEvent{},
+		func(ev fairway.Event) fairway.CommandWithEffect[TestDeps] {
+			return &TestCommand{Event: ev}
+		},
+		fairway.WithPollInterval[TestDeps](10*time.Millisecond),
+		fairway.WithWatchGroup[TestDeps](group),
+	)
+	require.NoError(t, err)
+	require.NoError(t, automation.Start(ctx))
+	t.Cleanup(func() {
+		automation.Stop()
+		_ = automation.Wait()
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// When - an event is appended
+	dcbEvent, err := fairway.ToDcbEvent(fairway.NewEvent(TestAutomationEvent{UserID: "user-1"}))
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, []dcb.Event{dcbEvent}))
+
+	// Then - the group reports it on the next tick of the clock only
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, handled.Load())
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		return handled.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}